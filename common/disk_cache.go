@@ -20,6 +20,9 @@ const (
 // 统一的缓存目录名
 const diskCacheDir = "new-api-body-cache"
 
+// 持久化文件存储子目录（位于缓存目录下，不会被 CleanupOldDiskCacheFiles 清理）
+const diskFileStoreDir = "files"
+
 // GetDiskCacheDir 获取统一的磁盘缓存目录
 // 注意：每次调用都会重新计算，以响应配置变化
 func GetDiskCacheDir() string {
@@ -30,6 +33,12 @@ func GetDiskCacheDir() string {
 	return filepath.Join(cachePath, diskCacheDir)
 }
 
+// GetDiskFileStoreDir 获取持久化文件存储目录
+// 用于 Files API 等需要长期保存的上传文件，CleanupOldDiskCacheFiles 只清理顶层文件，不会影响该目录
+func GetDiskFileStoreDir() string {
+	return filepath.Join(GetDiskCacheDir(), diskFileStoreDir)
+}
+
 // EnsureDiskCacheDir 确保缓存目录存在
 func EnsureDiskCacheDir() error {
 	dir := GetDiskCacheDir()
@@ -1,13 +1,16 @@
 -- 令牌桶限流器
 -- KEYS[1]: 限流器唯一标识
--- ARGV[1]: 请求令牌数 (通常为1)
+-- ARGV[1]: 请求令牌数 (通常为1，为负数时表示归还令牌)
 -- ARGV[2]: 令牌生成速率 (每秒)
 -- ARGV[3]: 桶容量
+-- ARGV[4]: 是否强制扣减 (可选，为 1 时令牌不足也扣减，用于按实际用量补扣)
+-- 返回: {是否允许, 剩余令牌数}
 
 local key = KEYS[1]
 local requested = tonumber(ARGV[1])
 local rate = tonumber(ARGV[2])
 local capacity = tonumber(ARGV[3])
+local force = ARGV[4] == '1'
 
 -- 获取当前时间（Redis服务器时间）
 local now = redis.call('TIME')
@@ -32,13 +35,16 @@ end
 
 -- 判断是否允许请求
 local allowed = false
-if tokens >= requested then
-    tokens = tokens - requested
+if tokens >= requested or force then
+    tokens = math.min(capacity, tokens - requested)
     allowed = true
 end
 
 ---- 更新桶状态并设置过期时间
 redis.call('HMSET', key, 'tokens', tokens, 'last_time', last_time)
---redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60) -- 适当延长过期时间
+if rate > 0 then
+    -- 桶在该时间后必然已满，过期后按满桶重新初始化
+    redis.call('EXPIRE', key, math.ceil((capacity - math.min(tokens, 0)) / rate) + 60)
+end
 
-return allowed and 1 or 0
\ No newline at end of file
+return {allowed and 1 or 0, math.floor(tokens)}
@@ -0,0 +1,75 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter 未启用 Redis 时使用的本地令牌桶，行为与 rate_limit.lua 一致
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep int64
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	tokens   float64
+	lastTime int64
+	expireAt int64
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+// Take 执行限流并返回桶内剩余令牌数
+func (ml *MemoryLimiter) Take(key string, opts ...Option) Result {
+	config := newConfig(opts...)
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	nowInSeconds := ml.now().Unix()
+	ml.sweep(nowInSeconds)
+
+	capacity := float64(config.Capacity)
+	requested := float64(config.Requested)
+	b, ok := ml.buckets[key]
+	if !ok || nowInSeconds >= b.expireAt {
+		b = &memoryBucket{tokens: capacity, lastTime: nowInSeconds}
+		ml.buckets[key] = b
+	} else {
+		elapsed := nowInSeconds - b.lastTime
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed*config.Rate))
+		b.lastTime = nowInSeconds
+	}
+
+	allowed := false
+	if b.tokens >= requested || config.Force {
+		b.tokens = math.Min(capacity, b.tokens-requested)
+		allowed = true
+	}
+	if config.Rate > 0 {
+		b.expireAt = nowInSeconds + int64(math.Ceil((capacity-math.Min(b.tokens, 0))/float64(config.Rate))) + 60
+	} else {
+		b.expireAt = math.MaxInt64
+	}
+	return Result{Allowed: allowed, Remaining: int64(math.Floor(b.tokens))}
+}
+
+// sweep 每分钟清理一次已过期的桶
+func (ml *MemoryLimiter) sweep(nowInSeconds int64) {
+	if nowInSeconds-ml.lastSweep < 60 {
+		return
+	}
+	ml.lastSweep = nowInSeconds
+	for key, b := range ml.buckets {
+		if nowInSeconds >= b.expireAt {
+			delete(ml.buckets, key)
+		}
+	}
+}
@@ -19,6 +19,11 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenHedge             ContextKey = "token_hedge"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenRateLimit         ContextKey = "token_rate_limit"
+	ContextKeyTokenCallbackUrl       ContextKey = "token_callback_url"
+	ContextKeyTokenOrgId             ContextKey = "token_org_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -36,6 +41,7 @@ const (
 	ContextKeyChannelStatusCodeMapping ContextKey = "status_code_mapping"
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
+	ContextKeyChannelMultiKeyMode      ContextKey = "channel_multi_key_mode"
 	ContextKeyChannelKey               ContextKey = "channel_key"
 
 	ContextKeyAutoGroup           ContextKey = "auto_group"
@@ -43,14 +49,15 @@ const (
 	ContextKeyAutoGroupRetryIndex ContextKey = "auto_group_retry_index"
 
 	/* user related keys */
-	ContextKeyUserId      ContextKey = "id"
-	ContextKeyUserSetting ContextKey = "user_setting"
-	ContextKeyUserQuota   ContextKey = "user_quota"
-	ContextKeyUserStatus  ContextKey = "user_status"
-	ContextKeyUserEmail   ContextKey = "user_email"
-	ContextKeyUserGroup   ContextKey = "user_group"
-	ContextKeyUsingGroup  ContextKey = "group"
-	ContextKeyUserName    ContextKey = "username"
+	ContextKeyUserId        ContextKey = "id"
+	ContextKeyUserSetting   ContextKey = "user_setting"
+	ContextKeyUserQuota     ContextKey = "user_quota"
+	ContextKeyUserStatus    ContextKey = "user_status"
+	ContextKeyUserEmail     ContextKey = "user_email"
+	ContextKeyUserGroup     ContextKey = "user_group"
+	ContextKeyUsingGroup    ContextKey = "group"
+	ContextKeyUserName      ContextKey = "username"
+	ContextKeyUserRateLimit ContextKey = "user_rate_limit"
 
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
@@ -63,6 +70,15 @@ const (
 	// It is not returned to end users, but can be persisted into consume/error logs for debugging.
 	ContextKeyAdminRejectReason ContextKey = "admin_reject_reason"
 
+	// ContextKeyResponseCapture stores the writer recording the response body for the response cache
+	ContextKeyResponseCapture ContextKey = "response_capture"
+
+	// ContextKeyRelayRateLimit stores the rate limit lease held by this request, reconciled with actual usage when billed
+	ContextKeyRelayRateLimit ContextKey = "relay_rate_limit"
+
+	// ContextKeyMultiKeyLeases stores the in-flight counters held by this request, released when the request ends
+	ContextKeyMultiKeyLeases ContextKey = "multi_key_leases"
+
 	// ContextKeyLanguage stores the user's language preference for i18n
 	ContextKeyLanguage ContextKey = "language"
 )
@@ -3,6 +3,10 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom        MultiKeyMode = "random"          // 随机
+	MultiKeyModePolling       MultiKeyMode = "polling"         // 轮询
+	MultiKeyModeLRU           MultiKeyMode = "lru"             // 最久未使用
+	MultiKeyModeLeastInFlight MultiKeyMode = "least_in_flight" // 进行中请求最少
+	MultiKeyModeWeighted      MultiKeyMode = "weighted"        // 按 Key 权重随机
+	MultiKeyModeQuotaAware    MultiKeyMode = "quota_aware"     // 按 Key 的 RPM/TPM 额度选择，429 后暂停使用
 )
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	batchListDefaultLimit = 20
+	batchListMaxLimit     = 100
+)
+
+func checkBatchEnabled(c *gin.Context) bool {
+	if !operation_setting.GetBatchSetting().Enabled || !operation_setting.GetFileSetting().Enabled {
+		RelayNotImplemented(c)
+		return false
+	}
+	return true
+}
+
+// getRequestBatch 获取当前用户的批处理任务，失败时直接写入错误响应
+func getRequestBatch(c *gin.Context) (*model.Batch, bool) {
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatchByBatchId(c.GetInt("id"), batchId)
+	if err == nil && batch.IsClaudeBatch() {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fileError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such Batch object: %s", batchId))
+			return nil, false
+		}
+		logger.LogError(c, fmt.Sprintf("failed to query batch %s: %s", batchId, err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to query batch")
+		return nil, false
+	}
+	return batch, true
+}
+
+// CreateBatch POST /v1/batches
+func CreateBatch(c *gin.Context) {
+	if !checkBatchEnabled(c) {
+		return
+	}
+	var req dto.OpenAIBatchCreateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+	if req.InputFileId == "" {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", "input_file_id is required")
+		return
+	}
+	if !operation_setting.IsBatchEndpointAllowed(req.Endpoint) {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("endpoint %s is not supported", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != service.BatchCompletionWindow {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("completion_window must be %s", service.BatchCompletionWindow))
+		return
+	}
+	file, err := model.GetUserFileByFileId(c.GetInt("id"), req.InputFileId)
+	if err != nil || file.IsExpired() {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("No such File object: %s", req.InputFileId))
+		return
+	}
+	if file.Purpose != service.FilePurposeBatch {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("File %s must have purpose 'batch'", req.InputFileId))
+		return
+	}
+
+	batch, err := service.CreateBatch(c.GetInt("id"), c.GetInt("token_id"), c.ClientIP(), &req)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to create batch: %s", err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to create batch")
+		return
+	}
+	c.JSON(http.StatusOK, service.BatchToOpenAI(batch))
+}
+
+// ListBatches GET /v1/batches
+func ListBatches(c *gin.Context) {
+	if !checkBatchEnabled(c) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = batchListDefaultLimit
+	}
+	if limit > batchListMaxLimit {
+		limit = batchListMaxLimit
+	}
+	// 多查一条用于判断 has_more
+	batches, err := model.GetUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to list batches: %s", err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to list batches")
+		return
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	resp := dto.OpenAIBatchList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIBatch, 0, len(batches)),
+		HasMore: hasMore,
+	}
+	for _, batch := range batches {
+		resp.Data = append(resp.Data, service.BatchToOpenAI(batch))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = resp.Data[0].ID
+		resp.LastID = resp.Data[len(resp.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetrieveBatch GET /v1/batches/:id
+func RetrieveBatch(c *gin.Context) {
+	if !checkBatchEnabled(c) {
+		return
+	}
+	batch, ok := getRequestBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, service.BatchToOpenAI(batch))
+}
+
+// CancelBatch POST /v1/batches/:id/cancel
+func CancelBatch(c *gin.Context) {
+	if !checkBatchEnabled(c) {
+		return
+	}
+	batch, ok := getRequestBatch(c)
+	if !ok {
+		return
+	}
+	if batch.IsFinished() || batch.Status == model.BatchStatusFinalizing {
+		fileError(c, http.StatusConflict, "invalid_request_error",
+			fmt.Sprintf("Cannot cancel a batch with status '%s'.", batch.Status))
+		return
+	}
+	batch, err := service.CancelBatch(batch)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to cancel batch: %s", err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to cancel batch")
+		return
+	}
+	c.JSON(http.StatusOK, service.BatchToOpenAI(batch))
+}
@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type budgetRequest struct {
+	Scope           string `json:"scope"`
+	TargetId        int    `json:"target_id"`
+	GroupName       string `json:"group_name"`
+	Period          string `json:"period"`
+	QuotaLimit      int    `json:"quota_limit"`
+	HardLimit       bool   `json:"hard_limit"`
+	AlertThresholds string `json:"alert_thresholds"`
+	Enabled         bool   `json:"enabled"`
+}
+
+// normalizeBudgetThresholds 校验并规范化提醒阈值，返回升序的逗号分隔字符串
+func normalizeBudgetThresholds(raw string) (string, bool) {
+	parts := make([]string, 0)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil || v <= 0 || v > 100 {
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	thresholds := model.ParseBudgetAlertThresholds(strings.Join(parts, ","))
+	normalized := make([]string, len(thresholds))
+	for i, v := range thresholds {
+		normalized[i] = strconv.Itoa(v)
+	}
+	return strings.Join(normalized, ","), true
+}
+
+// validateBudgetSettings 校验周期、额度与提醒阈值，失败时已写出响应
+func validateBudgetSettings(c *gin.Context, req *budgetRequest) bool {
+	if !model.IsValidBudgetPeriod(req.Period) {
+		common.ApiErrorI18n(c, i18n.MsgBudgetInvalidPeriod)
+		return false
+	}
+	if req.QuotaLimit <= 0 {
+		common.ApiErrorI18n(c, i18n.MsgBudgetLimitInvalid)
+		return false
+	}
+	thresholds, ok := normalizeBudgetThresholds(req.AlertThresholds)
+	if !ok {
+		common.ApiErrorI18n(c, i18n.MsgBudgetThresholdInvalid)
+		return false
+	}
+	req.AlertThresholds = thresholds
+	return true
+}
+
+// buildBudget 根据请求构造预算并解析预算对象，失败时已写出响应
+func buildBudget(c *gin.Context, req *budgetRequest) (*model.Budget, bool) {
+	if !validateBudgetSettings(c, req) {
+		return nil, false
+	}
+	budget := &model.Budget{
+		Scope:           req.Scope,
+		Period:          req.Period,
+		QuotaLimit:      req.QuotaLimit,
+		HardLimit:       req.HardLimit,
+		AlertThresholds: req.AlertThresholds,
+		Enabled:         req.Enabled,
+	}
+	switch req.Scope {
+	case model.BudgetScopeToken:
+		token, err := model.GetTokenById(req.TargetId)
+		if err != nil || req.TargetId <= 0 {
+			common.ApiErrorI18n(c, i18n.MsgBudgetTargetNotFound)
+			return nil, false
+		}
+		budget.TargetId = token.Id
+		budget.UserId = token.UserId
+	case model.BudgetScopeUser:
+		user, err := model.GetUserById(req.TargetId, false)
+		if err != nil || req.TargetId <= 0 {
+			common.ApiErrorI18n(c, i18n.MsgBudgetTargetNotFound)
+			return nil, false
+		}
+		budget.TargetId = user.Id
+		budget.UserId = user.Id
+	case model.BudgetScopeGroup:
+		req.GroupName = strings.TrimSpace(req.GroupName)
+		if req.GroupName == "" || !ratio_setting.ContainsGroupRatio(req.GroupName) {
+			common.ApiErrorI18n(c, i18n.MsgBudgetTargetNotFound)
+			return nil, false
+		}
+		budget.GroupName = req.GroupName
+	default:
+		common.ApiErrorI18n(c, i18n.MsgBudgetInvalidScope)
+		return nil, false
+	}
+	return budget, true
+}
+
+// loadBudget 读取路径中的预算，失败时已写出响应
+func loadBudget(c *gin.Context) (*model.Budget, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	budget, err := model.GetBudgetById(id)
+	if err != nil {
+		if errors.Is(err, model.ErrBudgetNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgBudgetNotFound)
+		} else {
+			common.ApiError(c, err)
+		}
+		return nil, false
+	}
+	return budget, true
+}
+
+// applyBudgetUpdate 更新预算的周期、额度与提醒设置，预算范围与对象不可修改
+func applyBudgetUpdate(c *gin.Context, budget *model.Budget) {
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !validateBudgetSettings(c, &req) {
+		return
+	}
+	periodChanged := budget.Period != req.Period
+	budget.Period = req.Period
+	budget.QuotaLimit = req.QuotaLimit
+	budget.HardLimit = req.HardLimit
+	budget.AlertThresholds = req.AlertThresholds
+	budget.Enabled = req.Enabled
+	if err := budget.Update(periodChanged); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, budget)
+}
+
+// GetSelfBudgets 获取当前用户令牌的预算与管理员为其设置的用户预算
+func GetSelfBudgets(c *gin.Context) {
+	budgets, err := model.GetUserVisibleBudgets(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, budgets)
+}
+
+// AddSelfBudget 为自己的令牌创建预算
+func AddSelfBudget(c *gin.Context) {
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Scope != model.BudgetScopeToken {
+		common.ApiErrorI18n(c, i18n.MsgBudgetPermissionDenied)
+		return
+	}
+	if _, err := model.GetTokenByIds(req.TargetId, c.GetInt("id")); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgBudgetTargetNotFound)
+		return
+	}
+	budget, ok := buildBudget(c, &req)
+	if !ok {
+		return
+	}
+	budget.CreatorId = c.GetInt("id")
+	if err := budget.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, budget)
+}
+
+// loadSelfBudget 读取当前用户自己创建的令牌预算，管理员设置的预算不可由用户修改或删除，失败时已写出响应
+func loadSelfBudget(c *gin.Context) (*model.Budget, bool) {
+	budget, ok := loadBudget(c)
+	if !ok {
+		return nil, false
+	}
+	userId := c.GetInt("id")
+	if budget.Scope != model.BudgetScopeToken || budget.UserId != userId || budget.CreatorId != userId {
+		common.ApiErrorI18n(c, i18n.MsgBudgetPermissionDenied)
+		return nil, false
+	}
+	return budget, true
+}
+
+// UpdateSelfBudget 修改自己令牌的预算
+func UpdateSelfBudget(c *gin.Context) {
+	budget, ok := loadSelfBudget(c)
+	if !ok {
+		return
+	}
+	applyBudgetUpdate(c, budget)
+}
+
+// DeleteSelfBudget 删除自己令牌的预算
+func DeleteSelfBudget(c *gin.Context) {
+	budget, ok := loadSelfBudget(c)
+	if !ok {
+		return
+	}
+	if err := budget.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetAllBudgets 管理员分页查询预算
+func GetAllBudgets(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	budgets, total, err := model.GetAllBudgets(c.Query("scope"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(budgets)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AddBudget 管理员为令牌、用户或分组创建预算
+func AddBudget(c *gin.Context) {
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	budget, ok := buildBudget(c, &req)
+	if !ok {
+		return
+	}
+	budget.CreatorId = c.GetInt("id")
+	if err := budget.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, budget)
+}
+
+// UpdateBudget 管理员修改预算
+func UpdateBudget(c *gin.Context) {
+	budget, ok := loadBudget(c)
+	if !ok {
+		return
+	}
+	applyBudgetUpdate(c, budget)
+}
+
+// DeleteBudget 管理员删除预算
+func DeleteBudget(c *gin.Context) {
+	budget, ok := loadBudget(c)
+	if !ok {
+		return
+	}
+	if err := budget.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveSelfBudget(t *testing.T, userId int, method string, budgetId int, body string) bool {
+	t.Helper()
+	engine := gin.New()
+	asUser := func(c *gin.Context) { c.Set("id", userId) }
+	engine.PUT("/api/budget/self/:id", asUser, UpdateSelfBudget)
+	engine.DELETE("/api/budget/self/:id", asUser, DeleteSelfBudget)
+	req := httptest.NewRequest(method, "/api/budget/self/"+strconv.Itoa(budgetId), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Success bool `json:"success"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Success
+}
+
+func TestSelfBudgetCannotModifyAdminBudget(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM budgets") })
+	const userId, adminId, tokenId = 10, 1, 20
+
+	adminBudget := &model.Budget{
+		Scope: model.BudgetScopeToken, TargetId: tokenId, UserId: userId, CreatorId: adminId,
+		Period: model.BudgetPeriodDaily, QuotaLimit: 1000, HardLimit: true, Enabled: true,
+	}
+	require.NoError(t, adminBudget.Insert())
+	selfBudget := &model.Budget{
+		Scope: model.BudgetScopeToken, TargetId: tokenId, UserId: userId, CreatorId: userId,
+		Period: model.BudgetPeriodDaily, QuotaLimit: 1000, HardLimit: true, Enabled: true,
+	}
+	require.NoError(t, selfBudget.Insert())
+
+	raise := `{"period":"daily","quota_limit":100000000,"hard_limit":false,"enabled":true}`
+	assert.False(t, serveSelfBudget(t, userId, http.MethodPut, adminBudget.Id, raise))
+	assert.False(t, serveSelfBudget(t, userId, http.MethodDelete, adminBudget.Id, ""))
+
+	stored, err := model.GetBudgetById(adminBudget.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, stored.QuotaLimit)
+	assert.True(t, stored.HardLimit)
+
+	// 用户自己创建的预算仍可修改与删除
+	assert.True(t, serveSelfBudget(t, userId, http.MethodPut, selfBudget.Id, raise))
+	assert.True(t, serveSelfBudget(t, userId, http.MethodDelete, selfBudget.Id, ""))
+	_, err = model.GetBudgetById(selfBudget.Id)
+	assert.ErrorIs(t, err, model.ErrBudgetNotFound)
+}
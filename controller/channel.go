@@ -666,6 +666,9 @@ func RefreshCodexChannelCredential(c *gin.Context) {
 type AddChannelRequest struct {
 	Mode                      string                `json:"mode"`
 	MultiKeyMode              constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyWeights           map[int]int           `json:"multi_key_weights"`
+	MultiKeyRpmLimits         map[int]int           `json:"multi_key_rpm_limits"`
+	MultiKeyTpmLimits         map[int]int           `json:"multi_key_tpm_limits"`
 	BatchAddSetKeyPrefix2Name bool                  `json:"batch_add_set_key_prefix_2_name"`
 	Channel                   *model.Channel        `json:"channel"`
 }
@@ -725,6 +728,9 @@ func AddChannel(c *gin.Context) {
 	case "multi_to_single":
 		addChannelRequest.Channel.ChannelInfo.IsMultiKey = true
 		addChannelRequest.Channel.ChannelInfo.MultiKeyMode = addChannelRequest.MultiKeyMode
+		addChannelRequest.Channel.ChannelInfo.MultiKeyWeights = addChannelRequest.MultiKeyWeights
+		addChannelRequest.Channel.ChannelInfo.MultiKeyRpmLimits = addChannelRequest.MultiKeyRpmLimits
+		addChannelRequest.Channel.ChannelInfo.MultiKeyTpmLimits = addChannelRequest.MultiKeyTpmLimits
 		if addChannelRequest.Channel.Type == constant.ChannelTypeVertexAi && addChannelRequest.Channel.GetOtherSettings().VertexKeyType != dto.VertexKeyTypeAPIKey {
 			array, err := getVertexArrayKeys(addChannelRequest.Channel.Key)
 			if err != nil {
@@ -974,8 +980,11 @@ func DeleteChannelBatch(c *gin.Context) {
 
 type PatchChannel struct {
 	model.Channel
-	MultiKeyMode *string `json:"multi_key_mode"`
-	KeyMode      *string `json:"key_mode"` // 多key模式下密钥覆盖或者追加
+	MultiKeyMode      *string     `json:"multi_key_mode"`
+	MultiKeyWeights   map[int]int `json:"multi_key_weights"`    // 不为 nil 时替换原有的 Key 权重
+	MultiKeyRpmLimits map[int]int `json:"multi_key_rpm_limits"` // 不为 nil 时替换原有的 Key RPM 上限
+	MultiKeyTpmLimits map[int]int `json:"multi_key_tpm_limits"` // 不为 nil 时替换原有的 Key TPM 上限
+	KeyMode           *string     `json:"key_mode"`             // 多key模式下密钥覆盖或者追加
 }
 
 func UpdateChannel(c *gin.Context) {
@@ -1011,6 +1020,15 @@ func UpdateChannel(c *gin.Context) {
 	if channel.MultiKeyMode != nil && *channel.MultiKeyMode != "" {
 		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(*channel.MultiKeyMode)
 	}
+	if channel.MultiKeyWeights != nil {
+		channel.ChannelInfo.MultiKeyWeights = channel.MultiKeyWeights
+	}
+	if channel.MultiKeyRpmLimits != nil {
+		channel.ChannelInfo.MultiKeyRpmLimits = channel.MultiKeyRpmLimits
+	}
+	if channel.MultiKeyTpmLimits != nil {
+		channel.ChannelInfo.MultiKeyTpmLimits = channel.MultiKeyTpmLimits
+	}
 
 	// 处理多key模式下的密钥追加/覆盖逻辑
 	if channel.KeyMode != nil && channel.ChannelInfo.IsMultiKey {
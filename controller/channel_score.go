@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/channelscore"
+	"github.com/QuantumNous/new-api/pkg/circuitbreaker"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type channelScoreItem struct {
+	channelscore.Stats
+	ChannelName string  `json:"channel_name"`
+	Factor      float64 `json:"factor"` // 与同模型其他渠道比较得到的权重调整系数
+}
+
+// GetChannelScores 查看渠道实时健康统计（当前实例）
+// GET /api/channel/scores?channel_id=&model=
+func GetChannelScores(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	modelName := c.Query("model")
+
+	// 系数需要与同模型的全部渠道比较，先取全量再过滤
+	all := channelscore.Snapshot(0)
+	modelChannels := make(map[string][]int)
+	for _, s := range all {
+		modelChannels[s.Model] = append(modelChannels[s.Model], s.ChannelId)
+	}
+	params := model.GetChannelScoreParams()
+	modelFactors := make(map[string]map[int]float64, len(modelChannels))
+	for m, ids := range modelChannels {
+		factors := channelscore.Factors(ids, m, params)
+		modelFactors[m] = make(map[int]float64, len(ids))
+		for i, id := range ids {
+			modelFactors[m][id] = factors[i]
+		}
+	}
+
+	items := make([]channelScoreItem, 0, len(all))
+	for _, s := range all {
+		if channelId > 0 && s.ChannelId != channelId {
+			continue
+		}
+		if modelName != "" && s.Model != modelName {
+			continue
+		}
+		item := channelScoreItem{Stats: s, Factor: modelFactors[s.Model][s.ChannelId]}
+		if channel, err := model.CacheGetChannel(s.ChannelId); err == nil && channel != nil {
+			item.ChannelName = channel.Name
+		}
+		items = append(items, item)
+	}
+	routingSetting := operation_setting.GetChannelRoutingSetting()
+	common.ApiSuccess(c, gin.H{
+		"default_strategy": routingSetting.DefaultStrategy,
+		"group_strategies": routingSetting.GroupStrategies,
+		"items":            items,
+	})
+}
+
+// ResetChannelScores 清除渠道健康统计，未指定 channel_id 时清除全部
+// DELETE /api/channel/scores?channel_id=
+func ResetChannelScores(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	channelscore.Reset(channelId)
+	common.ApiSuccess(c, nil)
+}
+
+// GetChannelCircuitBreakers 查看渠道熔断器状态
+// GET /api/channel/breakers?channel_id=
+func GetChannelCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	common.ApiSuccess(c, gin.H{
+		"enabled": operation_setting.GetCircuitBreakerSetting().Enabled,
+		"items":   circuitbreaker.Snapshot(channelId),
+	})
+}
+
+// ResetChannelCircuitBreakers 手动恢复渠道熔断器，未指定 channel_id 时恢复全部
+// DELETE /api/channel/breakers?channel_id=
+func ResetChannelCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	circuitbreaker.Reset(channelId)
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const claudeBatchListMaxLimit = 1000
+
+func claudeBatchError(c *gin.Context, status int, errType string, message string) {
+	c.JSON(status, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errType,
+			"message": message,
+		},
+	})
+}
+
+func checkClaudeBatchEnabled(c *gin.Context) bool {
+	if !operation_setting.GetBatchSetting().Enabled || !operation_setting.GetFileSetting().Enabled {
+		claudeBatchError(c, http.StatusNotImplemented, "api_error", "Message batches are not enabled")
+		return false
+	}
+	return true
+}
+
+// getRequestClaudeBatch 获取当前用户的 Message Batch，失败时直接写入错误响应
+func getRequestClaudeBatch(c *gin.Context) (*model.Batch, bool) {
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatchByBatchId(c.GetInt("id"), batchId)
+	if err == nil && !batch.IsClaudeBatch() {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			claudeBatchError(c, http.StatusNotFound, "not_found_error", fmt.Sprintf("No such message batch: %s", batchId))
+			return nil, false
+		}
+		logger.LogError(c, fmt.Sprintf("failed to query message batch %s: %s", batchId, err.Error()))
+		claudeBatchError(c, http.StatusInternalServerError, "api_error", "Failed to query message batch")
+		return nil, false
+	}
+	return batch, true
+}
+
+// CreateClaudeBatch POST /v1/messages/batches
+func CreateClaudeBatch(c *gin.Context) {
+	if !checkClaudeBatchEnabled(c) {
+		return
+	}
+	if !operation_setting.IsBatchEndpointAllowed(service.ClaudeBatchEndpoint) {
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("endpoint %s is not supported for batches", service.ClaudeBatchEndpoint))
+		return
+	}
+	var req dto.ClaudeMessageBatchCreateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+	if err := service.ValidateClaudeBatchRequest(&req); err != nil {
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	batch, err := service.CreateClaudeBatch(c.GetInt("id"), c.GetInt("token_id"), c.ClientIP(), &req)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to create message batch: %s", err.Error()))
+		claudeBatchError(c, http.StatusInternalServerError, "api_error", "Failed to create message batch")
+		return
+	}
+	c.JSON(http.StatusOK, service.ClaudeBatchToAnthropic(batch))
+}
+
+// ListClaudeBatches GET /v1/messages/batches
+func ListClaudeBatches(c *gin.Context) {
+	if !checkClaudeBatchEnabled(c) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = batchListDefaultLimit
+	}
+	if limit > claudeBatchListMaxLimit {
+		limit = claudeBatchListMaxLimit
+	}
+	beforeId := c.Query("before_id")
+	// 多查一条用于判断 has_more
+	batches, err := model.GetUserClaudeBatches(c.GetInt("id"), beforeId, c.Query("after_id"), limit+1)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to list message batches: %s", err.Error()))
+		claudeBatchError(c, http.StatusInternalServerError, "api_error", "Failed to list message batches")
+		return
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		// before_id 方向多出的一条是最新的一条，位于列表头部
+		if beforeId != "" {
+			batches = batches[1:]
+		} else {
+			batches = batches[:limit]
+		}
+	}
+	resp := dto.ClaudeMessageBatchList{
+		Data:    make([]dto.ClaudeMessageBatch, 0, len(batches)),
+		HasMore: hasMore,
+	}
+	for _, batch := range batches {
+		resp.Data = append(resp.Data, service.ClaudeBatchToAnthropic(batch))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = &resp.Data[0].Id
+		resp.LastId = &resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetrieveClaudeBatch GET /v1/messages/batches/:id
+func RetrieveClaudeBatch(c *gin.Context) {
+	if !checkClaudeBatchEnabled(c) {
+		return
+	}
+	batch, ok := getRequestClaudeBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, service.ClaudeBatchToAnthropic(batch))
+}
+
+// CancelClaudeBatch POST /v1/messages/batches/:id/cancel
+func CancelClaudeBatch(c *gin.Context) {
+	if !checkClaudeBatchEnabled(c) {
+		return
+	}
+	batch, ok := getRequestClaudeBatch(c)
+	if !ok {
+		return
+	}
+	if batch.IsFinished() || batch.Status == model.BatchStatusFinalizing {
+		claudeBatchError(c, http.StatusConflict, "invalid_request_error",
+			fmt.Sprintf("Cannot cancel a message batch that is already %s.", service.ClaudeBatchToAnthropic(batch).ProcessingStatus))
+		return
+	}
+	batch, err := service.CancelBatch(batch)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to cancel message batch: %s", err.Error()))
+		claudeBatchError(c, http.StatusInternalServerError, "api_error", "Failed to cancel message batch")
+		return
+	}
+	c.JSON(http.StatusOK, service.ClaudeBatchToAnthropic(batch))
+}
+
+// RetrieveClaudeBatchResults GET /v1/messages/batches/:id/results
+func RetrieveClaudeBatchResults(c *gin.Context) {
+	if !checkClaudeBatchEnabled(c) {
+		return
+	}
+	batch, ok := getRequestClaudeBatch(c)
+	if !ok {
+		return
+	}
+	if !batch.IsFinished() {
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("Message batch %s has not ended yet; results are not available.", batch.BatchId))
+		return
+	}
+	c.Header("Content-Type", "application/x-jsonl")
+	c.Status(http.StatusOK)
+	if err := service.WriteClaudeBatchResults(c.Writer, batch); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write message batch results %s: %s", batch.BatchId, err.Error()))
+	}
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaudeCountTokens 对应 Anthropic POST /v1/messages/count_tokens，仅统计不计费
+func ClaudeCountTokens(c *gin.Context) {
+	requestId := c.GetString(common.RequestIdKey)
+	request, err := helper.GetAndValidateClaudeRequest(c)
+	if err != nil {
+		respondClaudeError(c, requestId, types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	info := relaycommon.GenRelayInfoClaude(c, request)
+	tokens, newAPIError := relay.ClaudeCountTokensHelper(c, info)
+	if newAPIError != nil {
+		respondClaudeError(c, requestId, newAPIError)
+		return
+	}
+	c.JSON(http.StatusOK, dto.ClaudeCountTokensResponse{InputTokens: tokens})
+}
+
+func respondClaudeError(c *gin.Context, requestId string, newAPIError *types.NewAPIError) {
+	logger.LogError(c, fmt.Sprintf("relay error: %s", newAPIError.Error()))
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"type":  "error",
+		"error": newAPIError.ToClaudeError(),
+	})
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	fileListDefaultLimit = 10000
+	fileListMaxLimit     = 10000
+)
+
+func fileError(c *gin.Context, status int, errType string, message string) {
+	c.JSON(status, gin.H{
+		"error": types.OpenAIError{
+			Message: message,
+			Type:    errType,
+		},
+	})
+}
+
+// getRequestFile 获取当前用户的文件，失败时直接写入错误响应
+func getRequestFile(c *gin.Context) (*model.File, bool) {
+	fileId := c.Param("id")
+	file, err := model.GetUserFileByFileId(c.GetInt("id"), fileId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fileError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such File object: %s", fileId))
+			return nil, false
+		}
+		logger.LogError(c, fmt.Sprintf("failed to query file %s: %s", fileId, err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to query file")
+		return nil, false
+	}
+	if file.IsExpired() {
+		fileError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such File object: %s", fileId))
+		return nil, false
+	}
+	return file, true
+}
+
+func checkFileEnabled(c *gin.Context) bool {
+	if !operation_setting.GetFileSetting().Enabled {
+		RelayNotImplemented(c)
+		return false
+	}
+	return true
+}
+
+// UploadFile POST /v1/files
+func UploadFile(c *gin.Context) {
+	if !checkFileEnabled(c) {
+		return
+	}
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", "purpose is required")
+		return
+	}
+	if !operation_setting.IsFilePurposeAllowed(purpose) {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("purpose %s is not allowed", purpose))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		fileError(c, http.StatusBadRequest, "invalid_request_error", "file is required")
+		return
+	}
+
+	file, err := service.UploadFile(c.GetInt("id"), c.GetInt("token_id"), header, purpose)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrFileTooLarge):
+			fileError(c, http.StatusRequestEntityTooLarge, "invalid_request_error",
+				fmt.Sprintf("file exceeds the maximum allowed size of %d MB", operation_setting.GetFileSetting().MaxFileSizeMB))
+		case errors.Is(err, service.ErrFileStorageQuotaExceed):
+			fileError(c, http.StatusForbidden, "insufficient_quota",
+				fmt.Sprintf("file storage quota of %d MB exceeded", operation_setting.GetFileSetting().UserStorageQuotaMB))
+		default:
+			logger.LogError(c, fmt.Sprintf("failed to upload file: %s", err.Error()))
+			fileError(c, http.StatusInternalServerError, "server_error", "Failed to save file")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, service.FileToOpenAI(file))
+}
+
+// ListFiles GET /v1/files
+func ListFiles(c *gin.Context) {
+	if !checkFileEnabled(c) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = fileListDefaultLimit
+	}
+	if limit > fileListMaxLimit {
+		limit = fileListMaxLimit
+	}
+	// 多查一条用于判断 has_more
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1, c.Query("order") == "asc")
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to list files: %s", err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to list files")
+		return
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	resp := dto.OpenAIFileList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIFile, 0, len(files)),
+		HasMore: hasMore,
+	}
+	for _, file := range files {
+		resp.Data = append(resp.Data, service.FileToOpenAI(file))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = resp.Data[0].ID
+		resp.LastID = resp.Data[len(resp.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetrieveFile GET /v1/files/:id
+func RetrieveFile(c *gin.Context) {
+	if !checkFileEnabled(c) {
+		return
+	}
+	file, ok := getRequestFile(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, service.FileToOpenAI(file))
+}
+
+// RetrieveFileContent GET /v1/files/:id/content
+func RetrieveFileContent(c *gin.Context) {
+	if !checkFileEnabled(c) {
+		return
+	}
+	file, ok := getRequestFile(c)
+	if !ok {
+		return
+	}
+	content, err := service.OpenFileContent(file)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to open file %s: %s", file.FileId, err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to read file content")
+		return
+	}
+	defer content.Close()
+	c.Header("Content-Type", file.MimeType)
+	c.Header("Content-Length", strconv.FormatInt(file.Bytes, 10))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write file %s content: %s", file.FileId, err.Error()))
+	}
+}
+
+// DeleteFile DELETE /v1/files/:id
+func DeleteFile(c *gin.Context) {
+	if !checkFileEnabled(c) {
+		return
+	}
+	file, ok := getRequestFile(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteFile(file); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to delete file %s: %s", file.FileId, err.Error()))
+		fileError(c, http.StatusInternalServerError, "server_error", "Failed to delete file")
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		ID:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
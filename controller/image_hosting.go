@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHostedImage GET /v1/images/hosted/:id，公开访问网关临时托管的生成图片
+func GetHostedImage(c *gin.Context) {
+	file, err := model.GetFileByFileId(c.Param("id"))
+	if err != nil || file.Purpose != service.FilePurposeImageHosting || file.IsExpired() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	content, err := service.OpenFileContent(file)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to open hosted image %s: %s", file.FileId, err.Error()))
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer content.Close()
+	c.Header("Content-Type", file.MimeType)
+	c.Header("Content-Length", strconv.FormatInt(file.Bytes, 10))
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write hosted image %s: %s", file.FileId, err.Error()))
+	}
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReconcileLedger 比对所有用户的缓存额度与账本余额，返回存在差异的用户
+func ReconcileLedger(c *gin.Context) {
+	drifts, err := model.ReconcileUserLedger()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"drift_count": len(drifts),
+		"drifts":      drifts,
+	})
+}
+
+// GetLedgerAccount 获取账户的账本余额与分录
+func GetLedgerAccount(c *gin.Context) {
+	accountType := c.Param("type")
+	accountId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || !model.IsValidLedgerAccountType(accountType) {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	balance, err := model.GetLedgerBalance(accountType, accountId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	entries, total, err := model.GetLedgerEntries(accountType, accountId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(entries)
+	common.ApiSuccess(c, gin.H{
+		"balance": balance,
+		"entries": pageInfo,
+	})
+}
@@ -151,6 +151,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ImageSizeRatio":
+		err = ratio_setting.UpdateImageSizeRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "图片分辨率倍率设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "AudioRatio":
 		err = ratio_setting.UpdateAudioRatioByJSONString(option.Value.(string))
 		if err != nil {
@@ -0,0 +1,457 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+type organizationMemberRequest struct {
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	QuotaLimit     int    `json:"quota_limit"`
+	ResetUsedQuota bool   `json:"reset_used_quota"`
+}
+
+type organizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+type organizationStatusRequest struct {
+	Status int `json:"status"`
+}
+
+// loadOrganizationAccess 读取路径中的组织并校验当前用户的成员身份，失败时已写出响应
+func loadOrganizationAccess(c *gin.Context) (*model.Organization, *model.OrganizationMember, bool) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		if errors.Is(err, model.ErrOrgNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgOrgNotFound)
+		} else {
+			common.ApiError(c, err)
+		}
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(org.Id, c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrOrgNotMember) {
+			common.ApiErrorI18n(c, i18n.MsgOrgNotMember)
+		} else {
+			common.ApiError(c, err)
+		}
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+// canManageOrgMember 管理员只能管理普通成员与账单查看者，所有者可以管理除自己以外的全部成员
+func canManageOrgMember(operator *model.OrganizationMember, targetRole string) bool {
+	switch operator.Role {
+	case model.OrgRoleOwner:
+		return targetRole != model.OrgRoleOwner
+	case model.OrgRoleAdmin:
+		return targetRole == model.OrgRoleMember || targetRole == model.OrgRoleBillingViewer
+	}
+	return false
+}
+
+// GetSelfOrganizations 获取当前用户加入的组织
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		common.ApiErrorI18n(c, i18n.MsgOrgNameEmpty)
+		return
+	}
+	org := &model.Organization{Name: req.Name}
+	if err := org.Insert(c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// GetOrganization 获取组织详情与当前用户的成员信息
+func GetOrganization(c *gin.Context) {
+	org, member, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, &model.UserOrganization{
+		Organization:    *org,
+		Role:            member.Role,
+		MemberLimit:     member.QuotaLimit,
+		MemberUsedQuota: member.UsedQuota,
+	})
+}
+
+// UpdateOrganization 修改组织名称
+func UpdateOrganization(c *gin.Context) {
+	org, member, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	if !model.OrgRoleCanManage(member.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		common.ApiErrorI18n(c, i18n.MsgOrgNameEmpty)
+		return
+	}
+	org.Name = req.Name
+	if err := org.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// DeleteOrganization 删除组织，仅所有者可操作，剩余额度退回所有者钱包
+func DeleteOrganization(c *gin.Context) {
+	org, member, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	if member.Role != model.OrgRoleOwner {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	if err := model.DeleteOrganization(org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if org.Quota > 0 {
+		model.RecordLog(org.OwnerId, model.LogTypeManage,
+			fmt.Sprintf("删除组织 %s，剩余额度 %s 退回钱包", org.Name, logger.LogQuota(org.Quota)))
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationMembers 获取组织成员列表
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+// AddOrganizationMember 按用户名添加成员
+func AddOrganizationMember(c *gin.Context) {
+	org, operator, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !model.IsValidOrgRole(req.Role) || req.Role == model.OrgRoleOwner || req.QuotaLimit < 0 {
+		common.ApiErrorI18n(c, i18n.MsgOrgInvalidRole)
+		return
+	}
+	if !canManageOrgMember(operator, req.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	userId, err := model.GetUserIdByUsername(strings.TrimSpace(req.Username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgOrgUserNotFound)
+		} else {
+			common.ApiError(c, err)
+		}
+		return
+	}
+	member, err := model.AddOrganizationMember(org.Id, userId, req.Role, req.QuotaLimit)
+	if err != nil {
+		if errors.Is(err, model.ErrOrgMemberExists) {
+			common.ApiErrorI18n(c, i18n.MsgOrgMemberExists)
+		} else {
+			common.ApiError(c, err)
+		}
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// UpdateOrganizationMember 修改成员角色与消费上限
+func UpdateOrganizationMember(c *gin.Context) {
+	org, operator, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	member, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOrgNotMember)
+		return
+	}
+	if member.Role == model.OrgRoleOwner {
+		common.ApiErrorI18n(c, i18n.MsgOrgOwnerImmutable)
+		return
+	}
+	if !model.IsValidOrgRole(req.Role) || req.Role == model.OrgRoleOwner || req.QuotaLimit < 0 {
+		common.ApiErrorI18n(c, i18n.MsgOrgInvalidRole)
+		return
+	}
+	if !canManageOrgMember(operator, member.Role) || !canManageOrgMember(operator, req.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	member.Role = req.Role
+	member.QuotaLimit = req.QuotaLimit
+	if err := model.UpdateOrganizationMember(member, req.ResetUsedQuota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以主动退出组织（所有者除外）
+func RemoveOrganizationMember(c *gin.Context) {
+	org, operator, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	member, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOrgNotMember)
+		return
+	}
+	if member.Role == model.OrgRoleOwner {
+		common.ApiErrorI18n(c, i18n.MsgOrgOwnerImmutable)
+		return
+	}
+	if member.UserId != operator.UserId && !canManageOrgMember(operator, member.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	if err := model.RemoveOrganizationMember(org.Id, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// TransferQuotaToOrganization 将当前用户钱包额度转入组织钱包
+func TransferQuotaToOrganization(c *gin.Context) {
+	org, _, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Quota <= 0 {
+		common.ApiErrorI18n(c, i18n.MsgOrgQuotaInvalid)
+		return
+	}
+	userId := c.GetInt("id")
+	if err := model.TransferUserQuotaToOrganization(userId, org.Id, req.Quota); err != nil {
+		if errors.Is(err, model.ErrUserQuotaInsufficient) {
+			common.ApiErrorI18n(c, i18n.MsgOrgQuotaInsufficient)
+		} else {
+			common.ApiError(c, err)
+		}
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage,
+		fmt.Sprintf("向组织 %s 划转额度 %s", org.Name, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationTokens 获取组织令牌，管理员与账单查看者可查看全部，成员只能查看自己创建的
+func GetOrganizationTokens(c *gin.Context) {
+	org, member, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	userId := 0
+	if !model.OrgRoleCanViewBilling(member.Role) {
+		userId = member.UserId
+	}
+	tokens, err := model.GetOrganizationTokens(org.Id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	for _, token := range tokens {
+		if token.UserId != member.UserId {
+			token.Clean()
+		}
+	}
+	common.ApiSuccess(c, tokens)
+}
+
+// DeleteOrganizationToken 组织管理员删除组织令牌
+func DeleteOrganizationToken(c *gin.Context) {
+	org, member, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	if !model.OrgRoleCanManage(member.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	tokenId, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeleteOrganizationToken(org.Id, tokenId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationUsage 组织用量报表，按成员与模型聚合
+func GetOrganizationUsage(c *gin.Context) {
+	org, member, ok := loadOrganizationAccess(c)
+	if !ok {
+		return
+	}
+	if !model.OrgRoleCanViewBilling(member.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrgPermissionDenied)
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	items, err := model.GetOrganizationUsage(org.Id, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"quota":      org.Quota,
+		"used_quota": org.UsedQuota,
+		"items":      items,
+	})
+}
+
+// AdminGetAllOrganizations 管理员获取全部组织
+func AdminGetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AdminUpdateOrganizationStatus 管理员启用或禁用组织，禁用后组织令牌无法消费
+func AdminUpdateOrganizationStatus(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil ||
+		(req.Status != model.OrgStatusEnabled && req.Status != model.OrgStatusDisabled) {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOrgNotFound)
+		return
+	}
+	org.Status = req.Status
+	if err := org.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// AdminAdjustOrganizationQuota 管理员增减组织钱包额度
+func AdminAdjustOrganizationQuota(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOrgNotFound)
+		return
+	}
+	if err := model.AdjustOrganizationQuota(org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(org.OwnerId, model.LogTypeManage,
+		fmt.Sprintf("管理员调整组织 %s 额度 %s", org.Name, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
@@ -15,6 +15,11 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/channelscore"
+	"github.com/QuantumNous/new-api/pkg/circuitbreaker"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/pkg/multikey"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
@@ -22,12 +27,14 @@ import (
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/bytedance/gopkg/util/gopool"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
@@ -121,6 +128,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	defer func() {
+		observeRelayMetrics(c, relayInfo, newAPIError)
+	}()
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
 	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
@@ -156,6 +167,16 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	// common.SetContextKey(c, constant.ContextKeyTokenCountMeta, meta)
 
+	estimatedTokens := tokens
+	if meta != nil {
+		estimatedTokens += meta.MaxTokens
+	}
+	newAPIError = service.AcquireRelayRateLimit(c, estimatedTokens)
+	if newAPIError != nil {
+		return
+	}
+	defer service.ReleaseRelayRateLimit(c)
+
 	if priceData.FreeModel {
 		logger.LogInfo(c, fmt.Sprintf("模型 %s 免费，跳过预扣费", relayInfo.OriginModelName))
 	} else {
@@ -176,6 +197,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	if relay.TryResponseCache(c, relayInfo) {
+		return
+	}
+
 	retryParam := &service.RetryParam{
 		Ctx:        c,
 		TokenGroup: relayInfo.TokenGroup,
@@ -187,48 +212,22 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		relayInfo.RetryIndex = retryParam.GetRetry()
-		channel, channelErr := getChannel(c, relayInfo, retryParam)
-		if channelErr != nil {
-			logger.LogError(c, channelErr.Error())
-			newAPIError = channelErr
-			break
+		if relayInfo.RetryIndex > 0 {
+			metrics.IncRelayRetry(string(relayFormat), relayInfo.OriginModelName, relayInfo.UsingGroup)
 		}
-
-		addUsedChannel(c, channel.Id)
-		bodyStorage, bodyErr := common.GetBodyStorage(c)
-		if bodyErr != nil {
-			// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
-			if common.IsRequestBodyTooLargeError(bodyErr) || errors.Is(bodyErr, common.ErrRequestBodyTooLarge) {
-				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry())
-			} else {
-				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
-			}
-			break
+		if capture := service.GetResponseCapture(c); capture != nil {
+			capture.Reset()
 		}
-		c.Request.Body = io.NopCloser(bodyStorage)
-
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
+		var retry bool
+		if shouldHedge(c, relayFormat, relayInfo) {
+			newAPIError, retry = relayHedgedAttempt(c, relayFormat, relayInfo, retryParam)
+		} else {
+			newAPIError, retry = relayAttempt(c, relayFormat, relayInfo, retryParam)
 		}
-
 		if newAPIError == nil {
-			relayInfo.LastError = nil
 			return
 		}
-
-		newAPIError = service.NormalizeViolationFeeError(newAPIError)
-		relayInfo.LastError = newAPIError
-
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
-
-		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
+		if !retry {
 			break
 		}
 	}
@@ -240,6 +239,154 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 }
 
+// relayAttempt 选择渠道并完成一次中继尝试，每次尝试对应一个 relay.attempt span
+// retry 表示失败后是否可以继续重试
+func relayAttempt(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam) (newAPIError *types.NewAPIError, retry bool) {
+	attemptStart := time.Now()
+	span, endSpan := tracing.StartGinScope(c, "relay.attempt",
+		attribute.Int("relay.retry_index", relayInfo.RetryIndex),
+		attribute.String("model", relayInfo.OriginModelName),
+	)
+	defer func() {
+		if newAPIError != nil {
+			tracing.RecordError(span, newAPIError)
+			span.SetAttributes(
+				attribute.Int("upstream.status_code", newAPIError.StatusCode),
+				attribute.String("error.code", string(newAPIError.GetErrorCode())),
+			)
+		} else {
+			span.SetAttributes(attribute.Int("upstream.status_code", c.Writer.Status()))
+		}
+		endSpan()
+	}()
+
+	channel, channelErr := getChannel(c, relayInfo, retryParam)
+	if channelErr != nil {
+		logger.LogError(c, channelErr.Error())
+		return channelErr, false
+	}
+	span.SetAttributes(
+		attribute.Int("channel.id", channel.Id),
+		attribute.Int("channel.type", channel.Type),
+		attribute.String("model.upstream", c.GetString("original_model")),
+	)
+	defer func() {
+		observeChannelHealth(c, relayInfo, channel.Id, attemptStart, newAPIError)
+	}()
+
+	addUsedChannel(c, channel.Id)
+	bodyStorage, bodyErr := common.GetBodyStorage(c)
+	if bodyErr != nil {
+		// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
+		if common.IsRequestBodyTooLargeError(bodyErr) || errors.Is(bodyErr, common.ErrRequestBodyTooLarge) {
+			return types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry()), false
+		}
+		return types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry()), false
+	}
+	c.Request.Body = io.NopCloser(bodyStorage)
+
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		newAPIError = relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		newAPIError = relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		newAPIError = geminiRelayHandler(c, relayInfo)
+	default:
+		newAPIError = relayHandler(c, relayInfo)
+	}
+
+	if newAPIError == nil {
+		relayInfo.LastError = nil
+		return nil, false
+	}
+
+	// 对冲竞速中落败的尝试不是渠道错误
+	if relayInfo.Hedge.Lost() {
+		return newAPIError, false
+	}
+
+	newAPIError = service.NormalizeViolationFeeError(newAPIError)
+	relayInfo.LastError = newAPIError
+
+	processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+
+	return newAPIError, shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry())
+}
+
+// isChannelSideFailure 是否为渠道侧的问题（5xx、429、超时、渠道错误）
+// 其余 4xx 多为请求本身的问题，不应影响渠道健康度
+func isChannelSideFailure(newAPIError *types.NewAPIError) bool {
+	code := newAPIError.StatusCode
+	return types.IsChannelError(newAPIError) || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= http.StatusInternalServerError
+}
+
+// observeChannelHealth 将一次渠道尝试的结果计入渠道健康统计与熔断器
+func observeChannelHealth(c *gin.Context, relayInfo *relaycommon.RelayInfo, channelId int, attemptStart time.Time, newAPIError *types.NewAPIError) {
+	if newAPIError != nil && !relayInfo.Hedge.Lost() {
+		parkRateLimitedKey(c, channelId, newAPIError)
+	}
+	if newAPIError != nil && (!isChannelSideFailure(newAPIError) || relayInfo.Hedge.Lost()) {
+		return
+	}
+	breakerKey := circuitbreaker.Key{ChannelId: channelId, Model: relayInfo.OriginModelName}
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		breakerKey.KeyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	circuitbreaker.Record(breakerKey, newAPIError == nil)
+
+	observation := channelscore.Observation{
+		Latency: time.Since(attemptStart),
+		Success: newAPIError == nil,
+	}
+	if newAPIError == nil && relayInfo.IsStream && relayInfo.FirstResponseTime.After(attemptStart) {
+		observation.TTFT = relayInfo.FirstResponseTime.Sub(attemptStart)
+	}
+	channelscore.Observe(channelId, relayInfo.OriginModelName, observation, operation_setting.GetChannelRoutingSetting().EwmaAlpha)
+}
+
+// parkRateLimitedKey quota_aware 模式下上游返回 429 时，在 Retry-After 指定的时间内暂停使用该 Key
+func parkRateLimitedKey(c *gin.Context, channelId int, newAPIError *types.NewAPIError) {
+	if newAPIError.StatusCode != http.StatusTooManyRequests && newAPIError.RetryAfter <= 0 {
+		return
+	}
+	if common.GetContextKeyString(c, constant.ContextKeyChannelMultiKeyMode) != string(constant.MultiKeyModeQuotaAware) {
+		return
+	}
+	setting := operation_setting.GetMultiKeySetting()
+	parkFor := newAPIError.RetryAfter
+	if parkFor <= 0 {
+		parkFor = time.Duration(setting.ParkSeconds) * time.Second
+	}
+	if setting.MaxParkSeconds > 0 {
+		parkFor = min(parkFor, time.Duration(setting.MaxParkSeconds)*time.Second)
+	}
+	keyIndex := common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	multikey.Park(channelId, keyIndex, parkFor)
+	logger.LogInfo(c, fmt.Sprintf("channel #%d key #%d rate limited, parked for %s", channelId, keyIndex, parkFor))
+}
+
+// observeRelayMetrics 上报中继请求的最终结果、耗时与首字耗时
+func observeRelayMetrics(c *gin.Context, relayInfo *relaycommon.RelayInfo, newAPIError *types.NewAPIError) {
+	labels := metrics.RelayLabels{
+		RelayFormat: string(relayInfo.RelayFormat),
+		Model:       relayInfo.OriginModelName,
+		Group:       relayInfo.UsingGroup,
+		ChannelId:   c.GetInt("channel_id"),
+		ChannelType: c.GetInt("channel_type"),
+	}
+	statusCode := http.StatusOK
+	errorCode := ""
+	if newAPIError != nil {
+		statusCode = newAPIError.StatusCode
+		errorCode = string(newAPIError.GetErrorCode())
+	}
+	metrics.ObserveRelayRequest(labels, statusCode, errorCode, time.Since(relayInfo.StartTime))
+	if newAPIError == nil && relayInfo.IsStream && relayInfo.FirstResponseTime.After(relayInfo.StartTime) {
+		metrics.ObserveFirstToken(labels, relayInfo.FirstResponseTime.Sub(relayInfo.StartTime))
+	}
+}
+
 var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"realtime"}, // WS 握手支持的协议，如果有使用 Sec-WebSocket-Protocol，则必须在此声明对应的 Protocol TODO add other protocol
 	CheckOrigin: func(r *http.Request) bool {
@@ -273,7 +420,9 @@ func fastTokenCountMetaForPricing(request dto.Request) *types.TokenCountMeta {
 		meta.MaxTokens = int(r.MaxTokens)
 	case *dto.ImageRequest:
 		// Pricing for image requests depends on ImagePriceRatio; safe to compute even when CountToken is disabled.
-		return r.GetTokenCountMeta()
+		imageMeta := r.GetTokenCountMeta()
+		imageMeta.ImagePriceRatio *= ratio_setting.GetImageSizeRatio(r.Model, r.Size, r.Quality)
+		return imageMeta
 	default:
 		// Best-effort: leave CombineText empty to avoid large allocations.
 	}
@@ -343,6 +492,7 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
+	metrics.IncChannelError(c.GetString("original_model"), channelError.ChannelId, channelError.ChannelType, err.StatusCode, string(err.GetErrorCode()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
@@ -400,6 +550,22 @@ func RelayMidjourney(c *gin.Context) {
 		return
 	}
 
+	// 提交类请求占用 RPM 与并发限制，查询与回调不计入；按次计费，不占用 TPM
+	switch relayInfo.RelayMode {
+	case relayconstant.RelayModeMidjourneyNotify, relayconstant.RelayModeMidjourneyTaskFetch,
+		relayconstant.RelayModeMidjourneyTaskFetchByCondition, relayconstant.RelayModeMidjourneyTaskImageSeed:
+	default:
+		if rateErr := service.AcquireRelayRateLimit(c, 0); rateErr != nil {
+			c.JSON(rateErr.StatusCode, gin.H{
+				"description": rateErr.Error(),
+				"type":        "new_api_error",
+				"code":        constant.MjRequestError,
+			})
+			return
+		}
+		defer service.ReleaseRelayRateLimit(c)
+	}
+
 	var mjErr *dto.MidjourneyResponse
 	switch relayInfo.RelayMode {
 	case relayconstant.RelayModeMidjourneyNotify:
@@ -481,11 +647,31 @@ func RelayTask(c *gin.Context) {
 		return
 	}
 
+	// 任务按次计费，占用 RPM 与并发限制，不占用 TPM
+	if rateErr := service.AcquireRelayRateLimit(c, 0); rateErr != nil {
+		// 本地限流不使用 respondTaskError，避免被改写为上游负载饱和的提示
+		c.JSON(rateErr.StatusCode, service.TaskErrorWrapperLocal(rateErr.Err, string(rateErr.GetErrorCode()), rateErr.StatusCode))
+		return
+	}
+	defer service.ReleaseRelayRateLimit(c)
+
 	if taskErr := relay.ResolveOriginTask(c, relayInfo); taskErr != nil {
 		respondTaskError(c, taskErr)
 		return
 	}
 
+	// 任务完成回调地址：请求中的 callback_url 优先，否则使用令牌的默认回调地址
+	callbackURL := relaycommon.GetTaskCallbackURL(c)
+	if callbackURL == "" {
+		callbackURL = common.GetContextKeyString(c, constant.ContextKeyTokenCallbackUrl)
+	}
+	if callbackURL != "" {
+		if err := service.ValidateTaskCallbackURL(callbackURL); err != nil {
+			respondTaskError(c, service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest))
+			return
+		}
+	}
+
 	var result *relay.TaskSubmitResult
 	var taskErr *dto.TaskError
 	defer func() {
@@ -568,6 +754,7 @@ func RelayTask(c *gin.Context) {
 		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
 		task.PrivateData.BillingSource = relayInfo.BillingSource
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+		task.PrivateData.OrgId = relayInfo.OrgId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
 			ModelPrice:      relayInfo.PriceData.ModelPrice,
@@ -580,6 +767,13 @@ func RelayTask(c *gin.Context) {
 		task.Quota = result.Quota
 		task.Data = result.TaskData
 		task.Action = relayInfo.Action
+		if taskReq, err := relaycommon.GetTaskRequest(c); err == nil {
+			task.Properties.Input = taskReq.VideoInputJSON()
+		}
+		task.Properties.RemixedFrom = relayInfo.OriginTaskID
+		task.PrivateData.CallbackURL = callbackURL
+		task.PrivateData.UpstreamCallback = relayInfo.UpstreamCallback
+		service.ScheduleTaskFallbackPoll(task)
 		if insertErr := task.Insert(); insertErr != nil {
 			common.SysError("insert task error: " + insertErr.Error())
 		}
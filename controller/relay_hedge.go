@@ -0,0 +1,407 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hedgeChannelSelectTries 随机选择对冲渠道时，避开首个渠道的最大尝试次数
+const hedgeChannelSelectTries = 3
+
+// shouldHedge 令牌或分组开启对冲时，普通 HTTP 中继会并发竞速两个渠道
+func shouldHedge(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo) bool {
+	if relayFormat == types.RelayFormatOpenAIRealtime {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return operation_setting.ShouldHedge(relayInfo.UsingGroup, common.GetContextKeyBool(c, constant.ContextKeyTokenHedge))
+}
+
+// hedgeAttempt 对冲竞速中的一次尝试，使用独立的 gin.Context、RelayInfo 与请求体
+type hedgeAttempt struct {
+	ctx        *gin.Context
+	info       *relaycommon.RelayInfo
+	retryParam *service.RetryParam
+	storage    common.BodyStorage
+	cancel     context.CancelFunc
+	err        *types.NewAPIError
+	retry      bool
+}
+
+type hedgeResult struct {
+	attempt *hedgeAttempt
+	err     *types.NewAPIError
+	retry   bool
+}
+
+func newHedgeAttempt(c *gin.Context, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam, race *relaycommon.HedgeRace, output *hedgeOutput, body []byte) (*hedgeAttempt, error) {
+	storage, err := common.CreateBodyStorage(body)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	writer := newHedgeWriter(c.Writer, output)
+	hc := c.Copy()
+	hc.Request = c.Request.Clone(ctx)
+	hc.Writer = writer
+	hc.Set(common.KeyBodyStorage, storage)
+
+	param := *retryParam
+	param.Ctx = hc
+	param.Retry = common.GetPointer(retryParam.GetRetry())
+
+	info := relayInfo.CloneForHedge()
+	info.Hedge = &relaycommon.HedgeAttempt{
+		Race:  race,
+		Index: race.Join(cancel),
+		OnWin: writer.promote,
+	}
+	writer.attempt = info.Hedge
+	return &hedgeAttempt{
+		ctx:        hc,
+		info:       info,
+		retryParam: &param,
+		storage:    storage,
+		cancel:     cancel,
+	}, nil
+}
+
+func (a *hedgeAttempt) run(relayFormat types.RelayFormat, results chan<- hedgeResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogError(a.ctx, fmt.Sprintf("hedge attempt panic: %v", r))
+			results <- hedgeResult{attempt: a, err: types.NewError(fmt.Errorf("hedge attempt panic: %v", r), types.ErrorCodeDoRequestFailed)}
+		}
+	}()
+	newAPIError, retry := relayAttempt(a.ctx, relayFormat, a.info, a.retryParam)
+	// 未经过上游请求即成功的尝试（无需竞速）在结束时补充认领
+	if newAPIError == nil {
+		a.info.Hedge.Claim()
+	}
+	results <- hedgeResult{attempt: a, err: newAPIError, retry: retry}
+}
+
+// relayHedgedAttempt 与 relayAttempt 语义一致，但在首个渠道超过对冲延迟仍未返回首字节时，
+// 向另一个渠道并发发出相同请求，先开始返回正文的尝试胜出并写入响应，其余尝试被取消
+// 落败的尝试不会进入响应处理，因此只有胜者通过 BillingSession 结算
+func relayHedgedAttempt(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam) (*types.NewAPIError, bool) {
+	bodyStorage, bodyErr := common.GetBodyStorage(c)
+	if bodyErr != nil {
+		return relayAttempt(c, relayFormat, relayInfo, retryParam)
+	}
+	body, bodyErr := bodyStorage.Bytes()
+	if bodyErr != nil {
+		return relayAttempt(c, relayFormat, relayInfo, retryParam)
+	}
+
+	race := relaycommon.NewHedgeRace()
+	output := &hedgeOutput{}
+	primary, err := newHedgeAttempt(c, relayInfo, retryParam, race, output, body)
+	if err != nil {
+		return relayAttempt(c, relayFormat, relayInfo, retryParam)
+	}
+	attempts := []*hedgeAttempt{primary}
+	defer func() {
+		for _, attempt := range attempts {
+			attempt.cancel()
+			_ = attempt.storage.Close()
+		}
+	}()
+
+	results := make(chan hedgeResult, 2)
+	go primary.run(relayFormat, results)
+
+	timer := time.NewTimer(time.Duration(operation_setting.GetHedgeSetting().DelayMs) * time.Millisecond)
+	defer timer.Stop()
+	timerC := timer.C
+	pending := 1
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			result.attempt.err = result.err
+			result.attempt.retry = result.retry
+			// 首个渠道在对冲前已结束，按普通尝试处理
+			timerC = nil
+		case <-timerC:
+			timerC = nil
+			if race.Winner() >= 0 {
+				continue
+			}
+			hedge := startHedgeAttempt(c, relayFormat, relayInfo, retryParam, race, output, body, primary, results)
+			if hedge != nil {
+				attempts = append(attempts, hedge)
+				pending++
+			}
+		}
+	}
+
+	final := primary
+	if winner := race.Winner(); winner >= 0 {
+		final = attempts[winner]
+	}
+	mergeHedgeAttempt(c, relayInfo, retryParam, attempts, final, primary)
+	return final.err, final.retry
+}
+
+// startHedgeAttempt 选择与首个渠道不同的渠道发出对冲请求，没有可用渠道时返回 nil
+func startHedgeAttempt(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam,
+	race *relaycommon.HedgeRace, output *hedgeOutput, body []byte, primary *hedgeAttempt, results chan<- hedgeResult) *hedgeAttempt {
+	primaryChannelId := primary.ctx.GetInt("channel_id")
+	hedge, err := newHedgeAttempt(c, relayInfo, retryParam, race, output, body)
+	if err != nil {
+		return nil
+	}
+	// 对冲尝试的日志中同时记录首个渠道
+	useChannel := append([]string(nil), primary.ctx.GetStringSlice("use_channel")...)
+	hedge.ctx.Set("use_channel", useChannel)
+	channel := selectHedgeChannel(hedge, primaryChannelId)
+	if channel == nil {
+		hedge.cancel()
+		_ = hedge.storage.Close()
+		return nil
+	}
+	hedge.info.PriceData.GroupRatioInfo = helper.HandleGroupRatio(hedge.ctx, hedge.info)
+	if newAPIError := middleware.SetupContextForSelectedChannel(hedge.ctx, channel, hedge.info.OriginModelName); newAPIError != nil {
+		hedge.cancel()
+		_ = hedge.storage.Close()
+		return nil
+	}
+	// 渠道已在此处选定，relayAttempt 直接使用上下文中的渠道
+	hedge.info.ChannelMeta = nil
+	logger.LogInfo(c, fmt.Sprintf("hedging request: channel #%d has not responded after %dms, racing channel #%d",
+		primaryChannelId, operation_setting.GetHedgeSetting().DelayMs, channel.Id))
+	go hedge.run(relayFormat, results)
+	return hedge
+}
+
+// selectHedgeChannel 先在当前优先级中选择，若只有首个渠道则降级到下一优先级
+func selectHedgeChannel(hedge *hedgeAttempt, excludeChannelId int) *model.Channel {
+	param := *hedge.retryParam
+	for _, retry := range []int{hedge.retryParam.GetRetry(), hedge.retryParam.GetRetry() + 1} {
+		for i := 0; i < hedgeChannelSelectTries; i++ {
+			param.SetRetry(retry)
+			channel, _, err := service.CacheGetRandomSatisfiedChannel(&param)
+			if err != nil || channel == nil {
+				break
+			}
+			if channel.Id != excludeChannelId {
+				return channel
+			}
+		}
+	}
+	return nil
+}
+
+// mergeHedgeAttempt 将最终采用的尝试的上下文与 RelayInfo 写回原请求，供后续重试与日志使用
+func mergeHedgeAttempt(c *gin.Context, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam, attempts []*hedgeAttempt, final *hedgeAttempt, primary *hedgeAttempt) {
+	useChannel := append([]string(nil), final.ctx.GetStringSlice("use_channel")...)
+	for _, attempt := range attempts {
+		attemptChannels := attempt.ctx.GetStringSlice("use_channel")
+		if attempt == final || len(attemptChannels) == 0 {
+			continue
+		}
+		if channelId := attemptChannels[len(attemptChannels)-1]; !slices.Contains(useChannel, channelId) {
+			useChannel = append(useChannel, channelId)
+		}
+	}
+	for k, v := range final.ctx.Keys {
+		if k == common.KeyBodyStorage {
+			continue
+		}
+		c.Set(k, v)
+	}
+	c.Set("use_channel", useChannel)
+
+	hedged := final.info.Hedge.Hedged()
+	*relayInfo = *final.info
+	relayInfo.Hedge = nil
+
+	*retryParam = *primary.retryParam
+	retryParam.Ctx = c
+
+	if hedged && final.err == nil {
+		logger.LogInfo(c, fmt.Sprintf("hedged request won by channel #%d (%s)", c.GetInt("channel_id"), hedgeAttemptName(final.info.Hedge)))
+	}
+}
+
+func hedgeAttemptName(attempt *relaycommon.HedgeAttempt) string {
+	if attempt.Index == 0 {
+		return "primary"
+	}
+	return "hedge " + strconv.Itoa(attempt.Index)
+}
+
+// hedgeOutput 同一次竞速中各尝试共享的真实响应，胜负未分时的 ping 与胜者接管响应互斥
+type hedgeOutput struct {
+	mu       sync.Mutex
+	lastPing time.Time
+}
+
+// hedgeWriter 对冲尝试的响应输出
+// 胜出前写入的响应头与正文暂存在本地，胜出后一次性写入真实响应并直接透传；落败的尝试输出被丢弃
+// 胜负未分时的 ping 直接写入真实响应以保持客户端连接，落败的尝试不再发送 ping
+type hedgeWriter struct {
+	gin.ResponseWriter
+	output      *hedgeOutput
+	attempt     *relaycommon.HedgeAttempt
+	header      http.Header
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	promoted    bool
+}
+
+func newHedgeWriter(w gin.ResponseWriter, output *hedgeOutput) *hedgeWriter {
+	return &hedgeWriter{
+		ResponseWriter: w,
+		output:         output,
+		header:         w.Header().Clone(),
+		status:         http.StatusOK,
+	}
+}
+
+// promote 胜出后由该尝试自身调用，此后独占真实响应
+func (w *hedgeWriter) promote() {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+	if w.promoted {
+		return
+	}
+	w.promoted = true
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// WritePing 胜出后直接透传；胜负未分时写入真实响应，多个尝试在半个 ping 间隔内只发送一次
+func (w *hedgeWriter) WritePing(data []byte) error {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+	if !w.promoted {
+		if w.attempt.Lost() {
+			return nil
+		}
+		interval := time.Duration(operation_setting.GetGeneralSetting().PingIntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = helper.DefaultPingInterval
+		}
+		if !w.output.lastPing.IsZero() && time.Since(w.output.lastPing) < interval/2 {
+			return nil
+		}
+		w.output.lastPing = time.Now()
+		// ping 只用于流式请求，先于胜者提交 SSE 响应头
+		if !w.ResponseWriter.Written() {
+			header := w.ResponseWriter.Header()
+			header.Set("Content-Type", "text/event-stream")
+			header.Set("Cache-Control", "no-cache")
+			header.Set("Connection", "keep-alive")
+			header.Set("X-Accel-Buffering", "no")
+		}
+	}
+	if _, err := w.ResponseWriter.Write(data); err != nil {
+		return fmt.Errorf("write ping data failed: %w", err)
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.promoted {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.promoted {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 && !w.wroteHeader {
+		w.status = code
+	}
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.promoted {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.wroteHeader = true
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	if w.promoted {
+		return w.ResponseWriter.Write(data)
+	}
+	w.wroteHeader = true
+	return w.buf.Write(data)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if w.promoted {
+		return w.ResponseWriter.WriteString(s)
+	}
+	w.wroteHeader = true
+	return w.buf.WriteString(s)
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.promoted {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.promoted {
+		return w.ResponseWriter.Size()
+	}
+	if !w.wroteHeader {
+		return -1
+	}
+	return w.buf.Len()
+}
+
+func (w *hedgeWriter) Written() bool {
+	if w.promoted {
+		return w.ResponseWriter.Written()
+	}
+	return w.wroteHeader
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.promoted {
+		w.ResponseWriter.Flush()
+	}
+}
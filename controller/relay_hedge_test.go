@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// 通过 InitDB 初始化列名引用与全部表结构，使用共享的内存 SQLite
+	_ = os.Unsetenv("SQL_DSN")
+	_ = os.Unsetenv("LOG_SQL_DSN")
+	_ = os.Setenv("SQL_MAX_OPEN_CONNS", "1")
+	common.SQLitePath = "file:controller_test?mode=memory&cache=shared"
+	common.IsMasterNode = true
+	common.RedisEnabled = false
+	common.MemoryCacheEnabled = false
+	common.BatchUpdateEnabled = false
+	common.LogConsumeEnabled = true
+	gin.SetMode(gin.TestMode)
+	ratio_setting.InitRatioSettings()
+	service.InitHttpClient()
+
+	if err := model.InitDB(); err != nil {
+		panic("failed to init test db: " + err.Error())
+	}
+	if err := model.InitLogDB(); err != nil {
+		panic("failed to init test log db: " + err.Error())
+	}
+
+	os.Exit(m.Run())
+}
+
+const hedgeTestCompletion = `{"id":"chatcmpl-fast","object":"chat.completion","created":1,"model":"gpt-4o",` +
+	`"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],` +
+	`"usage":{"prompt_tokens":10,"completion_tokens":20,"total_tokens":30}}`
+
+func truncate(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM users")
+		model.DB.Exec("DELETE FROM tokens")
+		model.DB.Exec("DELETE FROM channels")
+		model.DB.Exec("DELETE FROM abilities")
+		model.DB.Exec("DELETE FROM logs")
+		model.DB.Exec("DELETE FROM ledger_journals")
+		model.DB.Exec("DELETE FROM ledger_entries")
+	})
+}
+
+func seedHedgeChannel(t *testing.T, id int, baseURL string, priority int64) {
+	t.Helper()
+	weight := uint(0)
+	ch := &model.Channel{
+		Id:       id,
+		Type:     1, // OpenAI
+		Name:     "hedge_channel",
+		Key:      "sk-upstream",
+		Status:   common.ChannelStatusEnabled,
+		Group:    "default",
+		Models:   "gpt-4o",
+		BaseURL:  &baseURL,
+		Priority: &priority,
+		Weight:   &weight,
+	}
+	require.NoError(t, ch.Insert())
+}
+
+func TestRelayHedgedAttempt_CancelsLoserAndBillsWinnerOnce(t *testing.T) {
+	truncate(t)
+	slowCancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才会感知客户端断开
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			close(slowCancelled)
+		case <-time.After(5 * time.Second):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(strings.Replace(hedgeTestCompletion, "chatcmpl-fast", "chatcmpl-slow", 1)))
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(hedgeTestCompletion))
+	}))
+	defer fast.Close()
+
+	const userId, slowChannelId, fastChannelId = 1, 1, 2
+	const initQuota = 100_000_000
+	require.NoError(t, model.DB.Create(&model.User{
+		Id: userId, Username: "hedge_user", Quota: initQuota, Status: common.UserStatusEnabled, Group: "default",
+	}).Error)
+	require.NoError(t, model.DB.Create(&model.Token{
+		Id: 1, UserId: userId, Key: "hedgetestkey", Name: "hedge_token", Status: common.TokenStatusEnabled,
+		UnlimitedQuota: true, ExpiredTime: -1,
+	}).Error)
+	// 首个渠道优先级更高，对冲时回落到次优先级的快速渠道
+	seedHedgeChannel(t, slowChannelId, slow.URL, 10)
+	seedHedgeChannel(t, fastChannelId, fast.URL, 0)
+
+	hedgeSetting := operation_setting.GetHedgeSetting()
+	saved := *hedgeSetting
+	hedgeSetting.Enabled = true
+	hedgeSetting.DelayMs = 50
+	hedgeSetting.Groups = []string{"default"}
+	t.Cleanup(func() { *hedgeSetting = saved })
+
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", middleware.TokenAuth(), middleware.Distribute(), func(c *gin.Context) {
+		Relay(c, types.RelayFormatOpenAI)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer sk-hedgetestkey")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "chatcmpl-fast")
+
+	select {
+	case <-slowCancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("losing upstream request was not cancelled")
+	}
+
+	var logs []model.Log
+	require.NoError(t, model.DB.Where("type = ?", model.LogTypeConsume).Find(&logs).Error)
+	require.Len(t, logs, 1, "only the winner should be billed")
+	assert.Equal(t, fastChannelId, logs[0].ChannelId)
+	assert.Positive(t, logs[0].Quota)
+
+	var user model.User
+	require.NoError(t, model.DB.First(&user, userId).Error)
+	assert.Equal(t, initQuota-logs[0].Quota, user.Quota)
+
+	var slowCh, fastCh model.Channel
+	require.NoError(t, model.DB.First(&slowCh, slowChannelId).Error)
+	require.NoError(t, model.DB.First(&fastCh, fastChannelId).Error)
+	assert.Zero(t, slowCh.UsedQuota)
+	assert.Equal(t, int64(logs[0].Quota), fastCh.UsedQuota)
+}
+
+func TestHedgeWriter_PingsUntilLostAndPassesThroughForWinner(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	race := relaycommon.NewHedgeRace()
+	output := &hedgeOutput{}
+	primary := newHedgeWriter(c.Writer, output)
+	primary.attempt = &relaycommon.HedgeAttempt{Race: race, Index: race.Join(func() {}), OnWin: primary.promote}
+	hedge := newHedgeWriter(c.Writer, output)
+	hedge.attempt = &relaycommon.HedgeAttempt{Race: race, Index: race.Join(func() {}), OnWin: hedge.promote}
+	ping := []byte(": PING\n\n")
+
+	// 胜负未分时 ping 直接写入真实响应，同一间隔内只发送一次
+	require.NoError(t, primary.WritePing(ping))
+	require.NoError(t, hedge.WritePing(ping))
+	assert.Equal(t, ": PING\n\n", recorder.Body.String())
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+
+	require.True(t, hedge.attempt.Claim())
+	_, _ = hedge.Write([]byte("data: hi\n\n"))
+	require.NoError(t, primary.WritePing(ping))
+	require.NoError(t, hedge.WritePing(ping))
+	assert.Equal(t, ": PING\n\ndata: hi\n\n: PING\n\n", recorder.Body.String())
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveRateLimited 以 RPM 为 1 的令牌限流调用中继入口，返回响应状态码
+func serveRateLimited(t *testing.T, tokenId int, method string, path string, handler gin.HandlerFunc) int {
+	t.Helper()
+	engine := gin.New()
+	engine.Handle(method, path, func(c *gin.Context) {
+		c.Set("token_id", tokenId)
+		common.SetContextKey(c, constant.ContextKeyTokenRateLimit, ratelimit.Limits{RPM: 1})
+	}, handler)
+	req := httptest.NewRequest(method, path, strings.NewReader(`{"model":"test-model","prompt":"hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRelayTaskEnforcesRateLimit(t *testing.T) {
+	const tokenId = 9101
+	first := serveRateLimited(t, tokenId, http.MethodPost, "/v1/video/generations", RelayTask)
+	assert.NotEqual(t, http.StatusTooManyRequests, first)
+	second := serveRateLimited(t, tokenId, http.MethodPost, "/v1/video/generations", RelayTask)
+	assert.Equal(t, http.StatusTooManyRequests, second)
+}
+
+func TestRelayMidjourneyEnforcesRateLimitOnSubmit(t *testing.T) {
+	const tokenId = 9102
+	first := serveRateLimited(t, tokenId, http.MethodPost, "/mj/submit/imagine", RelayMidjourney)
+	assert.NotEqual(t, http.StatusTooManyRequests, first)
+	second := serveRateLimited(t, tokenId, http.MethodPost, "/mj/submit/imagine", RelayMidjourney)
+	assert.Equal(t, http.StatusTooManyRequests, second)
+
+	// 查询任务不占用限流
+	for i := 0; i < 2; i++ {
+		code := serveRateLimited(t, tokenId, http.MethodPost, "/mj/task/list-by-condition", RelayMidjourney)
+		assert.NotEqual(t, http.StatusTooManyRequests, code)
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadStatement 读取路径中的月份与查询参数中的令牌，生成或读取对账单，失败时已写出响应
+func loadStatement(c *gin.Context, userId int) (*model.StatementData, *model.Statement, bool) {
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	data, statement, err := model.GetStatement(userId, tokenId, c.Param("period"))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrStatementPeriodInvalid):
+			common.ApiErrorI18n(c, i18n.MsgStatementPeriodInvalid)
+		case errors.Is(err, model.ErrStatementNotFound):
+			common.ApiErrorI18n(c, i18n.MsgStatementNotFound)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			common.ApiErrorI18n(c, i18n.MsgStatementTokenNotFound)
+		default:
+			common.ApiError(c, err)
+		}
+		return nil, nil, false
+	}
+	return data, statement, true
+}
+
+// writeStatement 按 format 参数输出 JSON、CSV 或 PDF
+func writeStatement(c *gin.Context, data *model.StatementData) {
+	switch c.Query("format") {
+	case "csv":
+		content, err := service.RenderStatementCSV(data)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.StatementFileName(data, "csv")))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", content)
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.StatementFileName(data, "pdf")))
+		c.Data(http.StatusOK, "application/pdf", service.RenderStatementPDF(data))
+	default:
+		common.ApiSuccess(c, data)
+	}
+}
+
+// GetSelfStatements 获取当前用户已结账的对账单列表
+func GetSelfStatements(c *gin.Context) {
+	statements, err := model.GetUserStatements(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statements)
+}
+
+// GetSelfStatement 获取当前用户指定月份的对账单，支持 token_id 与 format=json|csv|pdf
+func GetSelfStatement(c *gin.Context) {
+	data, _, ok := loadStatement(c, c.GetInt("id"))
+	if !ok {
+		return
+	}
+	writeStatement(c, data)
+}
+
+// EmailSelfStatement 将指定月份的对账单发送到当前用户的邮箱
+func EmailSelfStatement(c *gin.Context) {
+	userId := c.GetInt("id")
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Email == "" {
+		common.ApiErrorI18n(c, i18n.MsgStatementEmailEmpty)
+		return
+	}
+	data, statement, ok := loadStatement(c, userId)
+	if !ok {
+		return
+	}
+	if err := service.SendStatementEmail(user.Email, data); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if statement != nil {
+		_ = model.MarkStatementEmailed(statement.Id)
+	}
+	common.ApiSuccessI18n(c, i18n.MsgStatementEmailSent, nil)
+}
+
+// AdminGetUserStatement 管理员获取指定用户的对账单
+func AdminGetUserStatement(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	data, _, ok := loadStatement(c, userId)
+	if !ok {
+		return
+	}
+	writeStatement(c, data)
+}
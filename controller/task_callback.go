@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const taskCallbackMaxBodySize = 1 << 20
+
+func isTaskFinished(task *model.Task) bool {
+	return task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+}
+
+// TaskUpstreamCallback 接收提交任务时注册给上游的回调（Kling、Doubao、Hailuo、Vidu、Suno 等），
+// 校验回调地址中的签名后立即向上游查询任务最新状态
+func TaskUpstreamCallback(c *gin.Context) {
+	taskId := c.Param("task_id")
+	sign := c.Query("sign")
+	if sign == "" || !hmac.Equal([]byte(sign), []byte(relaycommon.UpstreamTaskCallbackSign(taskId))) {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "invalid signature"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, taskCallbackMaxBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	// MiniMax（海螺）校验回调地址时发送 challenge，需原样返回
+	if challenge := gjson.GetBytes(body, "challenge"); challenge.Exists() {
+		c.JSON(http.StatusOK, gin.H{"challenge": challenge.String()})
+		return
+	}
+	task, exist, err := model.GetByOnlyTaskId(taskId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if !exist {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "task not found"})
+		return
+	}
+	refreshTaskOnCallback(c, task)
+}
+
+// TaskChannelCallback 接收渠道级的上游 webhook（如 Sora/OpenAI 项目 webhook，Standard Webhooks 签名），
+// 签名密钥为渠道设置中的 task_webhook_secret
+func TaskChannelCallback(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid channel id"})
+		return
+	}
+	ch, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "channel not found"})
+		return
+	}
+	secret := ch.GetOtherSettings().TaskWebhookSecret
+	if secret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "channel webhook is not configured"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, taskCallbackMaxBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if err := service.VerifyStandardWebhook(secret, c.Request.Header, body); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	upstreamTaskId := gjson.GetBytes(body, "data.id").String()
+	if upstreamTaskId == "" {
+		// 与任务无关的事件直接确认
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	task, exist, err := model.GetUnfinishedChannelTaskByUpstreamID(channelId, upstreamTaskId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if !exist {
+		// 任务已结束或不属于本网关，确认以避免上游重试
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	refreshTaskOnCallback(c, task)
+}
+
+func refreshTaskOnCallback(c *gin.Context, task *model.Task) {
+	if isTaskFinished(task) {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	if err := service.RefreshTaskFromUpstream(c.Request.Context(), task); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("refresh task %s on upstream callback failed: %v", task.TaskID, err))
+		// 返回错误让上游重试，兜底轮询也会继续处理
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "refresh task failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTaskWebhooks 管理员查询任务回调投递记录，可按状态（如 dead 死信）与任务 ID 过滤
+func GetTaskWebhooks(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	webhooks, total, err := model.GetTaskWebhooks(c.Query("status"), c.Query("task_id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(webhooks)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RetryTaskWebhook 将死信回调重新放回投递队列
+func RetryTaskWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	ok, err := model.RequeueTaskWebhook(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !ok {
+		common.ApiErrorMsg(c, "只能重试已转入死信的回调")
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetTaskWebhookSecret 用户查询任务回调的签名密钥，用于校验回调的 webhook-signature
+func GetTaskWebhookSecret(c *gin.Context) {
+	secret, err := service.GetTaskWebhookSecret(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"secret": secret})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
@@ -148,6 +149,20 @@ func AddToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if token.CallbackUrl != "" {
+		if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgTokenCallbackUrlInvalid, map[string]any{"Error": err.Error()})
+			return
+		}
+	}
+	// 组织令牌从组织钱包扣费，创建者必须是可消费的组织成员
+	if token.OrgId > 0 {
+		member, err := model.GetOrganizationMember(token.OrgId, c.GetInt("id"))
+		if err != nil || !model.OrgRoleCanSpend(member.Role) {
+			common.ApiErrorI18n(c, i18n.MsgOrgTokenForbidden)
+			return
+		}
+	}
 	// 非无限额度时，检查额度值是否超出有效范围
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
@@ -194,6 +209,13 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		Hedge:              token.Hedge,
+		ResponseCache:      token.ResponseCache,
+		RpmLimit:           token.RpmLimit,
+		TpmLimit:           token.TpmLimit,
+		ConcurrencyLimit:   token.ConcurrencyLimit,
+		CallbackUrl:        token.CallbackUrl,
+		OrgId:              token.OrgId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -235,6 +257,12 @@ func UpdateToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if token.CallbackUrl != "" {
+		if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgTokenCallbackUrlInvalid, map[string]any{"Error": err.Error()})
+			return
+		}
+	}
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
 			common.ApiErrorI18n(c, i18n.MsgTokenQuotaNegative)
@@ -274,6 +302,12 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.Hedge = token.Hedge
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.ConcurrencyLimit = token.ConcurrencyLimit
+		cleanToken.CallbackUrl = token.CallbackUrl
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -299,7 +299,7 @@ func EpayNotify(c *gin.Context) {
 			dAmount := decimal.NewFromInt(int64(topUp.Amount))
 			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 			quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
-			err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true)
+			err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true, model.TopUpLedgerRef(topUp.TradeNo))
 			if err != nil {
 				log.Printf("易支付回调更新用户失败: %v", topUp)
 				return
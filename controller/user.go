@@ -1043,6 +1043,7 @@ type UpdateUserSettingRequest struct {
 	GotifyPriority             int     `json:"gotify_priority,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	StatementEmailEnabled      bool    `json:"statement_email_enabled"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1139,6 +1140,9 @@ func UpdateUserSetting(c *gin.Context) {
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		StatementEmailEnabled: req.StatementEmailEnabled,
+		// 自动生成的任务回调签名密钥不随通知设置变更
+		TaskWebhookSecret: user.GetSetting().TaskWebhookSecret,
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
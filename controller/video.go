@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	videoListDefaultLimit = 20
+	videoListMaxLimit     = 100
+)
+
+// ListVideos GET /v1/videos
+func ListVideos(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = videoListDefaultLimit
+	}
+	if limit > videoListMaxLimit {
+		limit = videoListMaxLimit
+	}
+	after := c.Query("after")
+	// 多查一条用于判断 has_more
+	tasks, err := model.GetUserVideoTasks(c.GetInt("id"), after, limit+1, c.Query("order") == "asc")
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			videoProxyError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such video: %s", after))
+			return
+		}
+		logger.LogError(c, fmt.Sprintf("failed to list videos: %s", err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to list videos")
+		return
+	}
+	hasMore := len(tasks) > limit
+	if hasMore {
+		tasks = tasks[:limit]
+	}
+	resp := dto.OpenAIVideoList{
+		Object:  "list",
+		Data:    make([]json.RawMessage, 0, len(tasks)),
+		HasMore: hasMore,
+	}
+	for _, task := range tasks {
+		video, err := relay.OpenAIVideoResponse(task)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to convert video %s: %s", task.TaskID, err.Error()))
+			video, _ = common.Marshal(task.ToOpenAIVideo())
+		}
+		resp.Data = append(resp.Data, video)
+	}
+	if len(tasks) > 0 {
+		resp.FirstId = &tasks[0].TaskID
+		resp.LastId = &tasks[len(tasks)-1].TaskID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteVideo DELETE /v1/videos/:task_id
+func DeleteVideo(c *gin.Context) {
+	taskId := c.Param("task_id")
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), taskId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to query video %s: %s", taskId, err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to query video")
+		return
+	}
+	if !exist {
+		videoProxyError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such video: %s", taskId))
+		return
+	}
+	// 未完成的任务仍在轮询与结算中，不允许删除
+	if task.Status != model.TaskStatusSuccess && task.Status != model.TaskStatusFailure {
+		videoProxyError(c, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("Video %s is still being generated and cannot be deleted", taskId))
+		return
+	}
+	if err := relay.DeleteUpstreamVideo(task); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to delete upstream video %s: %s", taskId, err.Error()))
+	}
+	if err := model.DeleteUserTask(task.UserId, task.TaskID); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to delete video %s: %s", taskId, err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to delete video")
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIVideoDeleted{
+		Id:      task.TaskID,
+		Object:  "video.deleted",
+		Deleted: true,
+	})
+}
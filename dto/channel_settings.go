@@ -23,6 +23,16 @@ const (
 	AwsKeyTypeApiKey AwsKeyType = "api_key"
 )
 
+// RerankFormat 上游重排序接口的请求 / 响应格式
+type RerankFormat string
+
+const (
+	RerankFormatJina       RerankFormat = "jina" // 默认
+	RerankFormatCohere     RerankFormat = "cohere"
+	RerankFormatVoyage     RerankFormat = "voyage"
+	RerankFormatXinference RerankFormat = "xinference"
+)
+
 type ChannelOtherSettings struct {
 	AzureResponsesVersion   string        `json:"azure_responses_version,omitempty"`
 	VertexKeyType           VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
@@ -34,6 +44,8 @@ type ChannelOtherSettings struct {
 	AllowSafetyIdentifier   bool          `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
 	AllowIncludeObfuscation bool          `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
 	AwsKeyType              AwsKeyType    `json:"aws_key_type,omitempty"`
+	RerankFormat            RerankFormat  `json:"rerank_format,omitempty"`       // OpenAI 兼容渠道的上游重排序格式，为空时按渠道类型推断
+	TaskWebhookSecret       string        `json:"task_webhook_secret,omitempty"` // 上游任务 webhook 的签名密钥（Sora 等 Standard Webhooks 格式，whsec_ 开头）
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
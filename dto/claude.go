@@ -574,3 +574,30 @@ func (u *ClaudeUsage) GetCacheCreationTotalTokens() int {
 type ClaudeServerToolUse struct {
 	WebSearchRequests int `json:"web_search_requests"`
 }
+
+// ClaudeCountTokensRequest /v1/messages/count_tokens 请求体，仅保留上游接受的字段
+type ClaudeCountTokensRequest struct {
+	Model      string          `json:"model,omitempty"`
+	System     any             `json:"system,omitempty"`
+	Messages   []ClaudeMessage `json:"messages"`
+	Tools      any             `json:"tools,omitempty"`
+	ToolChoice any             `json:"tool_choice,omitempty"`
+	Thinking   *Thinking       `json:"thinking,omitempty"`
+	McpServers json.RawMessage `json:"mcp_servers,omitempty"`
+}
+
+func NewClaudeCountTokensRequest(request *ClaudeRequest) *ClaudeCountTokensRequest {
+	return &ClaudeCountTokensRequest{
+		Model:      request.Model,
+		System:     request.System,
+		Messages:   request.Messages,
+		Tools:      request.Tools,
+		ToolChoice: request.ToolChoice,
+		Thinking:   request.Thinking,
+		McpServers: request.McpServers,
+	}
+}
+
+type ClaudeCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
@@ -480,7 +480,23 @@ type GeminiImageRequest struct {
 }
 
 type GeminiImageInstance struct {
-	Prompt string `json:"prompt"`
+	Prompt          string                 `json:"prompt"`
+	ReferenceImages []GeminiImageReference `json:"referenceImages,omitempty"` // 仅 Vertex Imagen 编辑模型
+}
+
+type GeminiImageReference struct {
+	ReferenceType   string                 `json:"referenceType"`
+	ReferenceId     int                    `json:"referenceId"`
+	ReferenceImage  GeminiImageBytes       `json:"referenceImage"`
+	MaskImageConfig *GeminiMaskImageConfig `json:"maskImageConfig,omitempty"`
+}
+
+type GeminiImageBytes struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded"`
+}
+
+type GeminiMaskImageConfig struct {
+	MaskMode string `json:"maskMode"`
 }
 
 type GeminiImageParameters struct {
@@ -488,6 +504,7 @@ type GeminiImageParameters struct {
 	AspectRatio      string `json:"aspectRatio,omitempty"`
 	PersonGeneration string `json:"personGeneration,omitempty"`
 	ImageSize        string `json:"imageSize,omitempty"`
+	EditMode         string `json:"editMode,omitempty"`
 }
 
 type GeminiImageResponse struct {
@@ -0,0 +1,81 @@
+package dto
+
+import "encoding/json"
+
+type OpenAIBatchCreateRequest struct {
+	InputFileId      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type OpenAIBatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+type OpenAIBatchErrors struct {
+	Object string             `json:"object"`
+	Data   []OpenAIBatchError `json:"data"`
+}
+
+type OpenAIBatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type OpenAIBatch struct {
+	ID               string                   `json:"id"`
+	Object           string                   `json:"object"`
+	Endpoint         string                   `json:"endpoint"`
+	Errors           *OpenAIBatchErrors       `json:"errors"`
+	InputFileId      string                   `json:"input_file_id"`
+	CompletionWindow string                   `json:"completion_window"`
+	Status           string                   `json:"status"`
+	OutputFileId     *string                  `json:"output_file_id"`
+	ErrorFileId      *string                  `json:"error_file_id"`
+	CreatedAt        int64                    `json:"created_at"`
+	InProgressAt     *int64                   `json:"in_progress_at"`
+	ExpiresAt        *int64                   `json:"expires_at"`
+	FinalizingAt     *int64                   `json:"finalizing_at"`
+	CompletedAt      *int64                   `json:"completed_at"`
+	FailedAt         *int64                   `json:"failed_at"`
+	ExpiredAt        *int64                   `json:"expired_at"`
+	CancellingAt     *int64                   `json:"cancelling_at"`
+	CancelledAt      *int64                   `json:"cancelled_at"`
+	RequestCounts    OpenAIBatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string        `json:"metadata"`
+}
+
+type OpenAIBatchList struct {
+	Object  string        `json:"object"`
+	Data    []OpenAIBatch `json:"data"`
+	FirstID string        `json:"first_id,omitempty"`
+	LastID  string        `json:"last_id,omitempty"`
+	HasMore bool          `json:"has_more"`
+}
+
+// OpenAIBatchRequestLine 批处理输入文件中的一行
+type OpenAIBatchRequestLine struct {
+	CustomId string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	Url      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type OpenAIBatchResponseBody struct {
+	StatusCode int             `json:"status_code"`
+	RequestId  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// OpenAIBatchResponseLine 批处理输出 / 错误文件中的一行
+type OpenAIBatchResponseLine struct {
+	ID       string                   `json:"id"`
+	CustomId string                   `json:"custom_id"`
+	Response *OpenAIBatchResponseBody `json:"response"`
+	Error    *OpenAIBatchError        `json:"error"`
+}
@@ -124,7 +124,8 @@ func indexComma(s string) int {
 	return -1
 }
 
-func (i *ImageRequest) GetTokenCountMeta() *types.TokenCountMeta {
+// GetPriceRatio 返回 dall-e 系列按尺寸与品质的价格倍率，不含生成数量
+func (i *ImageRequest) GetPriceRatio() float64 {
 	var sizeRatio = 1.0
 	var qualityRatio = 1.0
 
@@ -147,12 +148,15 @@ func (i *ImageRequest) GetTokenCountMeta() *types.TokenCountMeta {
 			}
 		}
 	}
+	return sizeRatio * qualityRatio
+}
 
+func (i *ImageRequest) GetTokenCountMeta() *types.TokenCountMeta {
 	// not support token count for dalle
 	return &types.TokenCountMeta{
 		CombineText:     i.Prompt,
 		MaxTokens:       1584,
-		ImagePriceRatio: sizeRatio * qualityRatio * float64(i.N),
+		ImagePriceRatio: i.GetPriceRatio() * float64(i.N),
 	}
 }
 
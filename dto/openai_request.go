@@ -59,7 +59,8 @@ type GeneralOpenAIRequest struct {
 	// ServiceTier specifies upstream service level and may affect billing.
 	// This field is filtered by default and can be enabled via channel setting allow_service_tier.
 	ServiceTier string          `json:"service_tier,omitempty"`
-	LogProbs    bool            `json:"logprobs,omitempty"`
+	LogProbs    json.RawMessage `json:"logprobs,omitempty"` // chat 为布尔值，旧版 completions 为候选数量
+	Echo        bool            `json:"echo,omitempty"`     // 旧版 completions：输出前回显 prompt
 	TopLogProbs int             `json:"top_logprobs,omitempty"`
 	Dimensions  int             `json:"dimensions,omitempty"`
 	Modalities  json.RawMessage `json:"modalities,omitempty"`
@@ -299,15 +300,16 @@ func (r *GeneralOpenAIRequest) ParseInput() []string {
 }
 
 type Message struct {
-	Role             string          `json:"role"`
-	Content          any             `json:"content"`
-	Name             *string         `json:"name,omitempty"`
-	Prefix           *bool           `json:"prefix,omitempty"`
-	ReasoningContent string          `json:"reasoning_content,omitempty"`
-	Reasoning        string          `json:"reasoning,omitempty"`
-	ToolCalls        json.RawMessage `json:"tool_calls,omitempty"`
-	ToolCallId       string          `json:"tool_call_id,omitempty"`
-	parsedContent    []MediaContent
+	Role               string          `json:"role"`
+	Content            any             `json:"content"`
+	Name               *string         `json:"name,omitempty"`
+	Prefix             *bool           `json:"prefix,omitempty"`
+	ReasoningContent   string          `json:"reasoning_content,omitempty"`
+	Reasoning          string          `json:"reasoning,omitempty"`
+	ReasoningSignature string          `json:"reasoning_signature,omitempty"` // 思考签名（Claude signature / Gemini thoughtSignature），多轮对话原样回传
+	ToolCalls          json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallId         string          `json:"tool_call_id,omitempty"`
+	parsedContent      []MediaContent
 	//parsedStringContent *string
 }
 
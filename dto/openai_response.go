@@ -51,6 +51,23 @@ func (o *OpenAITextResponse) GetOpenAIError() *types.OpenAIError {
 	return GetOpenAIError(o.Error)
 }
 
+// CompletionsResponse 旧版 /v1/completions 的 text_completion 响应，流式时每个分块结构相同
+type CompletionsResponse struct {
+	Id      string              `json:"id"`
+	Object  string              `json:"object"`
+	Created int64               `json:"created"`
+	Model   string              `json:"model"`
+	Choices []CompletionsChoice `json:"choices"`
+	Usage   *Usage              `json:"usage,omitempty"`
+}
+
+type CompletionsChoice struct {
+	Text         string  `json:"text"`
+	Index        int     `json:"index"`
+	Logprobs     any     `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
 type OpenAIEmbeddingResponseItem struct {
 	Object    string    `json:"object"`
 	Index     int       `json:"index"`
@@ -85,11 +102,12 @@ type ChatCompletionsStreamResponseChoice struct {
 }
 
 type ChatCompletionsStreamResponseChoiceDelta struct {
-	Content          *string            `json:"content,omitempty"`
-	ReasoningContent *string            `json:"reasoning_content,omitempty"`
-	Reasoning        *string            `json:"reasoning,omitempty"`
-	Role             string             `json:"role,omitempty"`
-	ToolCalls        []ToolCallResponse `json:"tool_calls,omitempty"`
+	Content            *string            `json:"content,omitempty"`
+	ReasoningContent   *string            `json:"reasoning_content,omitempty"`
+	Reasoning          *string            `json:"reasoning,omitempty"`
+	ReasoningSignature *string            `json:"reasoning_signature,omitempty"` // 思考签名，随思考结束或首个函数调用下发
+	Role               string             `json:"role,omitempty"`
+	ToolCalls          []ToolCallResponse `json:"tool_calls,omitempty"`
 }
 
 func (c *ChatCompletionsStreamResponseChoiceDelta) SetContentString(s string) {
@@ -330,7 +348,8 @@ func (o *OpenAIResponsesResponse) GetSize() string {
 }
 
 type IncompleteDetails struct {
-	Reasoning string `json:"reasoning"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Reason    string `json:"reason,omitempty"`
 }
 
 type ResponsesOutput struct {
@@ -344,6 +363,9 @@ type ResponsesOutput struct {
 	CallId    string                   `json:"call_id,omitempty"`
 	Name      string                   `json:"name,omitempty"`
 	Arguments string                   `json:"arguments,omitempty"`
+	// reasoning
+	Summary          []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
+	EncryptedContent string                          `json:"encrypted_content,omitempty"` // 思考签名
 }
 
 type ResponsesOutputContent struct {
@@ -384,6 +406,10 @@ type ResponsesStreamResponse struct {
 	SummaryIndex *int                           `json:"summary_index,omitempty"`
 	ItemID       string                         `json:"item_id,omitempty"`
 	Part         *ResponsesReasoningSummaryPart `json:"part,omitempty"`
+	// - response.output_text.done
+	// - response.reasoning_summary_text.done
+	Text      string `json:"text,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
 )
@@ -51,3 +52,17 @@ type OpenAIVideoError struct {
 	Message string `json:"message"`
 	Code    string `json:"code"`
 }
+
+type OpenAIVideoList struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	FirstId *string           `json:"first_id"`
+	LastId  *string           `json:"last_id"`
+	HasMore bool              `json:"has_more"`
+}
+
+type OpenAIVideoDeleted struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
@@ -7,7 +7,10 @@ const (
 	RealtimeEventTypeSessionUpdate      = "session.update"
 	RealtimeEventTypeConversationCreate = "conversation.item.create"
 	RealtimeEventTypeResponseCreate     = "response.create"
+	RealtimeEventTypeResponseCancel     = "response.cancel"
 	RealtimeEventInputAudioBufferAppend = "input_audio_buffer.append"
+	RealtimeEventInputAudioBufferCommit = "input_audio_buffer.commit"
+	RealtimeEventInputAudioBufferClear  = "input_audio_buffer.clear"
 )
 
 const (
@@ -19,6 +22,20 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+	RealtimeEventTypeResponseCreated                = "response.created"
+	RealtimeEventResponseOutputItemAdded            = "response.output_item.added"
+	RealtimeEventResponseOutputItemDone             = "response.output_item.done"
+	RealtimeEventResponseContentPartAdded           = "response.content_part.added"
+	RealtimeEventResponseContentPartDone            = "response.content_part.done"
+	RealtimeEventResponseAudioDone                  = "response.audio.done"
+	RealtimeEventResponseAudioTranscriptionDone     = "response.audio_transcript.done"
+	RealtimeEventResponseTextDelta                  = "response.text.delta"
+	RealtimeEventResponseTextDone                   = "response.text.done"
+	RealtimeEventInputAudioBufferCommitted          = "input_audio_buffer.committed"
+	RealtimeEventInputAudioBufferCleared            = "input_audio_buffer.cleared"
+	RealtimeEventInputAudioBufferSpeechStarted      = "input_audio_buffer.speech_started"
+	RealtimeEventInputAudioTranscriptionDelta       = "conversation.item.input_audio_transcription.delta"
+	RealtimeEventInputAudioTranscriptionCompleted   = "conversation.item.input_audio_transcription.completed"
 )
 
 type RealtimeEvent struct {
@@ -31,10 +48,25 @@ type RealtimeEvent struct {
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+	// 服务端事件
+	ResponseId   string           `json:"response_id,omitempty"`
+	ItemId       string           `json:"item_id,omitempty"`
+	OutputIndex  *int             `json:"output_index,omitempty"`
+	ContentIndex *int             `json:"content_index,omitempty"`
+	Part         *RealtimeContent `json:"part,omitempty"`
+	Text         string           `json:"text,omitempty"`
+	Transcript   string           `json:"transcript,omitempty"`
+	CallId       string           `json:"call_id,omitempty"`
+	Name         string           `json:"name,omitempty"`
+	Arguments    string           `json:"arguments,omitempty"`
 }
 
 type RealtimeResponse struct {
-	Usage *RealtimeUsage `json:"usage"`
+	Id     string         `json:"id,omitempty"`
+	Object string         `json:"object,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Output []RealtimeItem `json:"output,omitempty"`
+	Usage  *RealtimeUsage `json:"usage"`
 }
 
 type RealtimeUsage struct {
@@ -79,6 +111,8 @@ type RealtimeItem struct {
 	Name      *string           `json:"name,omitempty"`
 	ToolCalls any               `json:"tool_calls,omitempty"`
 	CallId    string            `json:"call_id,omitempty"`
+	Arguments string            `json:"arguments,omitempty"`
+	Output    string            `json:"output,omitempty"`
 }
 type RealtimeContent struct {
 	Type       string `json:"type"`
@@ -14,6 +14,7 @@ type SunoSubmitReq struct {
 	TaskID               string  `json:"task_id,omitempty"`
 	ContinueClipId       string  `json:"continue_clip_id,omitempty"`
 	MakeInstrumental     bool    `json:"make_instrumental"`
+	NotifyHook           string  `json:"notify_hook,omitempty"` // 由网关设置的上游完成回调地址
 }
 
 type SunoDataResponse struct {
@@ -55,3 +55,30 @@ type TaskDto struct {
 type FetchReq struct {
 	IDs []string `json:"ids"`
 }
+
+const (
+	TaskWebhookEventSucceeded = "task.succeeded"
+	TaskWebhookEventFailed    = "task.failed"
+)
+
+// TaskWebhookPayload 异步任务到达终态时发送给 callback_url 的回调内容
+type TaskWebhookPayload struct {
+	Id        string          `json:"id"` // 事件 ID，重试时保持不变，可用于幂等去重
+	Type      string          `json:"type"`
+	CreatedAt int64           `json:"created_at"`
+	Data      TaskWebhookData `json:"data"`
+}
+
+type TaskWebhookData struct {
+	TaskId     string   `json:"task_id"`
+	Platform   string   `json:"platform"`
+	Action     string   `json:"action"`
+	Model      string   `json:"model,omitempty"`
+	Status     string   `json:"status"`
+	Progress   string   `json:"progress"`
+	FailReason string   `json:"fail_reason,omitempty"`
+	ResultUrls []string `json:"result_urls,omitempty"`
+	Quota      int      `json:"quota"` // 实际扣除的额度，失败退款后为 0
+	SubmitTime int64    `json:"submit_time"`
+	FinishTime int64    `json:"finish_time"`
+}
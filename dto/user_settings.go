@@ -15,6 +15,8 @@ type UserSetting struct {
 	SidebarModules        string  `json:"sidebar_modules,omitempty"`                // SidebarModules 左侧边栏模块配置
 	BillingPreference     string  `json:"billing_preference,omitempty"`             // BillingPreference 扣费策略（订阅/钱包）
 	Language              string  `json:"language,omitempty"`                       // Language 用户语言偏好 (zh, en)
+	StatementEmailEnabled bool    `json:"statement_email_enabled,omitempty"`        // StatementEmailEnabled 每月邮件发送上月对账单
+	TaskWebhookSecret     string  `json:"task_webhook_secret,omitempty"`            // TaskWebhookSecret 未设置 WebhookSecret 时自动生成的任务回调签名密钥
 }
 
 var (
@@ -42,6 +42,7 @@ const (
 	MsgTokenExhausted            = "token.exhausted"
 	MsgTokenStatusUnavailable    = "token.status_unavailable"
 	MsgTokenDbError              = "token.db_error"
+	MsgTokenCallbackUrlInvalid   = "token.callback_url_invalid"
 )
 
 // Redemption related messages
@@ -304,6 +305,41 @@ const (
 	MsgDistributorInvalidParseModel   = "distributor.invalid_request_parse_model"
 )
 
+// Organization related messages
+const (
+	MsgOrgNameEmpty         = "org.name_empty"
+	MsgOrgNotFound          = "org.not_found"
+	MsgOrgNotMember         = "org.not_member"
+	MsgOrgPermissionDenied  = "org.permission_denied"
+	MsgOrgInvalidRole       = "org.invalid_role"
+	MsgOrgUserNotFound      = "org.user_not_found"
+	MsgOrgMemberExists      = "org.member_exists"
+	MsgOrgOwnerImmutable    = "org.owner_immutable"
+	MsgOrgQuotaInvalid      = "org.quota_invalid"
+	MsgOrgQuotaInsufficient = "org.quota_insufficient"
+	MsgOrgTokenForbidden    = "org.token_forbidden"
+)
+
+// Budget related messages
+const (
+	MsgBudgetNotFound         = "budget.not_found"
+	MsgBudgetInvalidScope     = "budget.invalid_scope"
+	MsgBudgetInvalidPeriod    = "budget.invalid_period"
+	MsgBudgetLimitInvalid     = "budget.limit_invalid"
+	MsgBudgetThresholdInvalid = "budget.threshold_invalid"
+	MsgBudgetTargetNotFound   = "budget.target_not_found"
+	MsgBudgetPermissionDenied = "budget.permission_denied"
+)
+
+// Statement related messages
+const (
+	MsgStatementPeriodInvalid = "statement.period_invalid"
+	MsgStatementTokenNotFound = "statement.token_not_found"
+	MsgStatementNotFound      = "statement.not_found"
+	MsgStatementEmailEmpty    = "statement.email_empty"
+	MsgStatementEmailSent     = "statement.email_sent"
+)
+
 // Custom OAuth provider related messages
 const (
 	MsgCustomOAuthNotFound          = "custom_oauth.not_found"
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -19,6 +20,8 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/pkg/circuitbreaker"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	"github.com/QuantumNous/new-api/router"
 	"github.com/QuantumNous/new-api/service"
@@ -49,6 +52,10 @@ func main() {
 		return
 	}
 
+	if *common.ReconcileLedger {
+		os.Exit(reconcileLedger())
+	}
+
 	common.SysLog("New API " + common.Version + " started")
 	if os.Getenv("GIN_MODE") != "debug" {
 		gin.SetMode(gin.ReleaseMode)
@@ -112,6 +119,15 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Expired files cleanup task (Files API)
+	service.StartFileCleanupTask()
+
+	// Monthly statement close & email task
+	service.StartStatementTask()
+
+	// Channel circuit breaker state sync across instances (Redis)
+	circuitbreaker.StartSync()
+
 	// Wire task polling adaptor factory (breaks service -> relay import cycle)
 	service.GetTaskAdaptorFunc = func(platform constant.TaskPlatform) service.TaskPollingAdaptor {
 		a := relay.GetTaskAdaptor(platform)
@@ -148,6 +164,18 @@ func main() {
 		common.SysError(fmt.Sprintf("start pyroscope error : %v", err))
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		common.SysError(fmt.Sprintf("init tracing error : %v", err))
+	} else {
+		if tracing.Enabled() {
+			common.SysLog("OpenTelemetry tracing enabled")
+		}
+		defer func() {
+			_ = shutdownTracing(context.Background())
+		}()
+	}
+
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
@@ -161,6 +189,7 @@ func main() {
 	}))
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
+	server.Use(middleware.Tracing())
 	server.Use(middleware.RequestId())
 	server.Use(middleware.PoweredBy())
 	server.Use(middleware.I18n())
@@ -181,6 +210,11 @@ func main() {
 
 	// 设置路由
 	router.SetRouter(server, buildFS, indexPage)
+
+	// Batch API worker executes each batch line through the full relay router
+	service.BatchRelayHandler = server
+	service.StartBatchWorker()
+	service.StartTaskWebhookWorker()
 	var port = os.Getenv("PORT")
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
@@ -236,6 +270,24 @@ func InjectGoogleAnalytics() {
 	indexPage = bytes.ReplaceAll(indexPage, []byte("<!--Google Analytics-->\n"), []byte(analyticsInject))
 }
 
+// reconcileLedger 命令行对账：输出缓存额度与账本余额不一致的用户，存在差异时以非零状态退出
+func reconcileLedger() int {
+	drifts, err := model.ReconcileUserLedger()
+	if err != nil {
+		common.SysError("failed to reconcile ledger: " + err.Error())
+		return 2
+	}
+	for _, d := range drifts {
+		fmt.Printf("user_id=%d username=%s cached=%d pending=%d ledger=%d drift=%d unledgered=%t\n",
+			d.UserId, d.Username, d.CachedQuota, d.PendingQuota, d.LedgerQuota, d.Drift, d.Unledgered)
+	}
+	fmt.Printf("%d user(s) drifted from the ledger\n", len(drifts))
+	if len(drifts) > 0 {
+		return 1
+	}
+	return 0
+}
+
 func InitResources() error {
 	// Initialize resources here if needed
 	// This is a placeholder function for future resource initialization
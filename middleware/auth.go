@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net"
 	"net/http"
@@ -11,12 +12,16 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/ratelimit"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func validUserInfo(username string, role int) bool {
@@ -247,6 +252,9 @@ func TokenAuthReadOnly() func(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		// span 只覆盖鉴权本身，进入后续处理前结束
+		span := tracing.StartGin(c, "middleware.token_auth")
+		defer span.End()
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -365,6 +373,8 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		span.SetAttributes(attribute.Int("user.id", token.UserId), attribute.Int("token.id", token.Id), attribute.String("group", userGroup))
+		span.End()
 		c.Next()
 	}
 }
@@ -389,6 +399,15 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenHedge, token.Hedge)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCache)
+	common.SetContextKey(c, constant.ContextKeyTokenCallbackUrl, token.CallbackUrl)
+	common.SetContextKey(c, constant.ContextKeyTokenOrgId, token.OrgId)
+	common.SetContextKey(c, constant.ContextKeyTokenRateLimit, ratelimit.Limits{
+		RPM:         token.RpmLimit,
+		TPM:         token.TpmLimit,
+		Concurrency: token.ConcurrencyLimit,
+	})
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -400,3 +419,22 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	return nil
 }
+
+// MetricsAuth /metrics 端点鉴权：配置了抓取密钥时允许 Bearer 密钥访问，否则要求管理员身份
+func MetricsAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		setting := operation_setting.GetMetricsSetting()
+		if !setting.Enabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if setting.ScrapeSecret != "" {
+			key := strings.TrimSpace(strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "))
+			if subtle.ConstantTimeCompare([]byte(key), []byte(setting.ScrapeSecret)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		authHelper(c, common.RoleAdminUser)
+	}
+}
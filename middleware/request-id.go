@@ -4,7 +4,10 @@ import (
 	"context"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func RequestId() func(c *gin.Context) {
@@ -14,6 +17,11 @@ func RequestId() func(c *gin.Context) {
 		ctx := context.WithValue(c.Request.Context(), common.RequestIdKey, id)
 		c.Request = c.Request.WithContext(ctx)
 		c.Header(common.RequestIdKey, id)
+		// 关联链路追踪：span 上记录请求 ID，响应头返回 trace id
+		if traceId := tracing.TraceId(ctx); traceId != "" {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+			c.Header("X-Trace-Id", traceId)
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,252 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusFailed     = "failed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// ClaudeBatchIdPrefix 通过 /v1/messages/batches 创建的 Anthropic 批处理 ID 前缀
+const ClaudeBatchIdPrefix = "msgbatch_"
+
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// BatchErrors 批处理校验阶段产生的错误列表
+type BatchErrors []BatchError
+
+func (e *BatchErrors) Scan(val interface{}) error {
+	var bytesValue []byte
+	switch v := val.(type) {
+	case []byte:
+		bytesValue = v
+	case string:
+		bytesValue = []byte(v)
+	}
+	if len(bytesValue) == 0 {
+		*e = nil
+		return nil
+	}
+	return common.Unmarshal(bytesValue, e)
+}
+
+func (e BatchErrors) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	return common.Marshal(e)
+}
+
+// BatchMetadata 用户自定义的批处理元数据
+type BatchMetadata map[string]string
+
+func (m *BatchMetadata) Scan(val interface{}) error {
+	var bytesValue []byte
+	switch v := val.(type) {
+	case []byte:
+		bytesValue = v
+	case string:
+		bytesValue = []byte(v)
+	}
+	if len(bytesValue) == 0 {
+		*m = nil
+		return nil
+	}
+	return common.Unmarshal(bytesValue, m)
+}
+
+func (m BatchMetadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return common.Marshal(m)
+}
+
+// Batch 通过 /v1/batches 创建的批处理任务，由网关后台 worker 逐行执行
+type Batch struct {
+	Id               int           `json:"-" gorm:"primaryKey"`
+	BatchId          string        `json:"id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int           `json:"-" gorm:"index"`
+	TokenId          int           `json:"-" gorm:"index"`
+	ClientIp         string        `json:"-" gorm:"type:varchar(64)"`
+	Endpoint         string        `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string        `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string        `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string        `json:"error_file_id" gorm:"type:varchar(64)"`
+	CompletionWindow string        `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string        `json:"status" gorm:"type:varchar(20);index"`
+	Errors           BatchErrors   `json:"errors" gorm:"type:json"`
+	Metadata         BatchMetadata `json:"metadata" gorm:"type:json"`
+	TotalCount       int           `json:"total_count"`
+	CompletedCount   int           `json:"completed_count"`
+	FailedCount      int           `json:"failed_count"`
+	UnprocessedCount int           `json:"-"` // 因取消或过期未执行的请求数，计入 FailedCount
+	CreatedAt        int64         `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64         `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt        int64         `json:"expires_at" gorm:"bigint"`
+	FinalizingAt     int64         `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64         `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64         `json:"failed_at" gorm:"bigint"`
+	ExpiredAt        int64         `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64         `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64         `json:"cancelled_at" gorm:"bigint"`
+}
+
+// GenerateBatchId 生成对外暴露的 batch_xxxx 格式 ID
+func GenerateBatchId() string {
+	key, _ := common.GenerateRandomCharsKey(24)
+	return "batch_" + key
+}
+
+// GenerateClaudeBatchId 生成 Anthropic 格式的 msgbatch_xxxx ID
+func GenerateClaudeBatchId() string {
+	key, _ := common.GenerateRandomCharsKey(24)
+	return ClaudeBatchIdPrefix + key
+}
+
+// IsClaudeBatch 是否为 Anthropic Message Batches 创建的任务
+func (b *Batch) IsClaudeBatch() bool {
+	return strings.HasPrefix(b.BatchId, ClaudeBatchIdPrefix)
+}
+
+func (b *Batch) Insert() error {
+	if b.CreatedAt == 0 {
+		b.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(b).Error
+}
+
+// IsFinished 是否已处于终态
+func (b *Batch) IsFinished() bool {
+	switch b.Status {
+	case BatchStatusCompleted, BatchStatusFailed, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// GetUserBatchByBatchId 获取用户的批处理任务，不存在或不属于该用户时返回 gorm.ErrRecordNotFound
+func GetUserBatchByBatchId(userId int, batchId string) (*Batch, error) {
+	if batchId == "" {
+		return nil, errors.New("batch id is empty")
+	}
+	var batch Batch
+	err := DB.Where("batch_id = ? AND user_id = ?", batchId, userId).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetBatchById 按主键获取批处理任务
+func GetBatchById(id int) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("id = ?", id).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetUserBatches 按 OpenAI 分页语义（after 游标 + limit）列出用户批处理任务，按创建时间倒序
+func GetUserBatches(userId int, after string, limit int) ([]*Batch, error) {
+	query := DB.Where("user_id = ? AND batch_id NOT LIKE ?", userId, ClaudeBatchIdPrefix+"%")
+	if after != "" {
+		cursor, err := GetUserBatchByBatchId(userId, after)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return []*Batch{}, nil
+			}
+			return nil, err
+		}
+		query = query.Where("id < ?", cursor.Id)
+	}
+	var batches []*Batch
+	err := query.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// GetUserClaudeBatches 按 Anthropic 分页语义（before_id / after_id + limit）列出用户的 Message Batches，按创建时间倒序
+func GetUserClaudeBatches(userId int, beforeId string, afterId string, limit int) ([]*Batch, error) {
+	query := DB.Where("user_id = ? AND batch_id LIKE ?", userId, ClaudeBatchIdPrefix+"%")
+	cursorId, desc := afterId, true
+	if beforeId != "" {
+		cursorId, desc = beforeId, false
+	}
+	if cursorId != "" {
+		cursor, err := GetUserBatchByBatchId(userId, cursorId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return []*Batch{}, nil
+			}
+			return nil, err
+		}
+		if desc {
+			query = query.Where("id < ?", cursor.Id)
+		} else {
+			query = query.Where("id > ?", cursor.Id)
+		}
+	}
+	var batches []*Batch
+	if desc {
+		err := query.Order("id desc").Limit(limit).Find(&batches).Error
+		return batches, err
+	}
+	// before_id 取紧邻游标之前（更新）的一页，查询后翻转为倒序
+	err := query.Order("id asc").Limit(limit).Find(&batches).Error
+	slices.Reverse(batches)
+	return batches, err
+}
+
+// GetBatchesByStatus 按状态获取批处理任务，按创建顺序返回
+func GetBatchesByStatus(statuses []string, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status IN ?", statuses).Order("id asc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// UpdateBatchWithStatus 仅当当前状态为 fromStatus 时更新（CAS），返回是否更新成功
+// 多节点或取消请求与 worker 并发时，保证状态流转不会互相覆盖
+func UpdateBatchWithStatus(id int, fromStatus string, updates map[string]interface{}) (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? AND status = ?", id, fromStatus).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateBatchCounts 更新批处理请求计数
+func UpdateBatchCounts(id int, total int, completed int, failed int) error {
+	return DB.Model(&Batch{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"total_count":     total,
+		"completed_count": completed,
+		"failed_count":    failed,
+	}).Error
+}
+
+// GetBatchStatus 获取批处理任务的当前状态
+func GetBatchStatus(id int) (string, error) {
+	var status string
+	err := DB.Model(&Batch{}).Where("id = ?", id).Select("status").Scan(&status).Error
+	return status, err
+}
@@ -0,0 +1,369 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+
+	"github.com/samber/hot"
+	"gorm.io/gorm"
+)
+
+// 预算作用范围
+const (
+	BudgetScopeToken = "token"
+	BudgetScopeUser  = "user"
+	BudgetScopeGroup = "group" // 分组内全部用户共享同一预算
+)
+
+// 预算周期，按服务器时区在每日零点、每周一零点、每月一日零点重置
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+)
+
+var ErrBudgetNotFound = errors.New("budget not found")
+
+const activeBudgetCacheNamespace = "new-api:active_budgets:v1"
+
+var (
+	activeBudgetCacheOnce sync.Once
+	activeBudgetCache     *cachex.HybridCache[[]Budget]
+)
+
+func activeBudgetCacheTTL() time.Duration {
+	ttlSeconds := common.GetEnvOrDefault("ACTIVE_BUDGET_CACHE_TTL", 60)
+	if ttlSeconds <= 0 {
+		ttlSeconds = 60
+	}
+	return time.Duration(ttlSeconds) * time.Second
+}
+
+func activeBudgetCacheCapacity() int {
+	capacity := common.GetEnvOrDefault("ACTIVE_BUDGET_CACHE_CAP", 10000)
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return capacity
+}
+
+// getActiveBudgetCache 缓存令牌、用户与分组适用的预算配置；用量与周期以数据库为准，不从缓存读取
+func getActiveBudgetCache() *cachex.HybridCache[[]Budget] {
+	activeBudgetCacheOnce.Do(func() {
+		ttl := activeBudgetCacheTTL()
+		activeBudgetCache = cachex.NewHybridCache[[]Budget](cachex.HybridCacheConfig[[]Budget]{
+			Namespace: cachex.Namespace(activeBudgetCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[[]Budget]{},
+			Memory: func() *hot.HotCache[string, []Budget] {
+				return hot.NewHotCache[string, []Budget](hot.LRU, activeBudgetCacheCapacity()).
+					WithTTL(ttl).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return activeBudgetCache
+}
+
+// InvalidateActiveBudgetCache 预算新增、修改或删除后清空缓存。一个预算可能被多个缓存键引用，因此整体清空
+func InvalidateActiveBudgetCache() {
+	_ = getActiveBudgetCache().Purge()
+}
+
+// Budget 按周期重置的消费预算。HardLimit 为 true 时达到 QuotaLimit 后拒绝请求，
+// AlertThresholds 为逗号分隔的百分比（如 "80,95"），用量跨过阈值时发送一次提醒
+type Budget struct {
+	Id              int    `json:"id"`
+	Scope           string `json:"scope" gorm:"type:varchar(16);index:idx_budget_scope_target,priority:1"`
+	TargetId        int    `json:"target_id" gorm:"index:idx_budget_scope_target,priority:2"` // 令牌 ID 或用户 ID
+	GroupName       string `json:"group_name" gorm:"type:varchar(64);index;default:''"`       // 分组预算的分组名
+	UserId          int    `json:"user_id" gorm:"index"`                                      // 令牌预算的令牌所有者
+	CreatorId       int    `json:"creator_id" gorm:"index;default:0"`                         // 创建者，用户只能管理自己创建的预算
+	Period          string `json:"period" gorm:"type:varchar(16)"`
+	QuotaLimit      int    `json:"quota_limit"`
+	HardLimit       bool   `json:"hard_limit"`
+	AlertThresholds string `json:"alert_thresholds" gorm:"type:varchar(64);default:''"`
+	UsedQuota       int    `json:"used_quota" gorm:"default:0"`
+	PeriodStart     int64  `json:"period_start" gorm:"bigint;default:0"`
+	NotifiedPercent int    `json:"notified_percent" gorm:"default:0"`
+	Enabled         bool   `json:"enabled"`
+	CreatedTime     int64  `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidBudgetPeriod(period string) bool {
+	switch period {
+	case BudgetPeriodDaily, BudgetPeriodWeekly, BudgetPeriodMonthly:
+		return true
+	}
+	return false
+}
+
+// BudgetPeriodStart 返回 now 所在预算周期的开始时间
+func BudgetPeriodStart(period string, now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case BudgetPeriodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为一周的开始
+		return day.AddDate(0, 0, -offset)
+	case BudgetPeriodMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	default:
+		return day
+	}
+}
+
+// BudgetPeriodEnd 返回 now 所在预算周期的结束时间（即下次重置时间）
+func BudgetPeriodEnd(period string, now time.Time) time.Time {
+	start := BudgetPeriodStart(period, now)
+	switch period {
+	case BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case BudgetPeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// ParseBudgetAlertThresholds 解析提醒阈值百分比，忽略非法值并升序去重
+func ParseBudgetAlertThresholds(raw string) []int {
+	seen := make(map[int]bool)
+	thresholds := make([]int, 0)
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || v <= 0 || v > 100 || seen[v] {
+			continue
+		}
+		seen[v] = true
+		thresholds = append(thresholds, v)
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+// PendingAlertThreshold 返回当前用量已跨过但尚未提醒的最高阈值，没有时返回 0
+func (b *Budget) PendingAlertThreshold() int {
+	if b.QuotaLimit <= 0 {
+		return 0
+	}
+	percent := b.UsedQuota * 100 / b.QuotaLimit
+	pending := 0
+	for _, threshold := range ParseBudgetAlertThresholds(b.AlertThresholds) {
+		if percent >= threshold && threshold > b.NotifiedPercent {
+			pending = threshold
+		}
+	}
+	return pending
+}
+
+func (b *Budget) Insert() error {
+	b.CreatedTime = common.GetTimestamp()
+	b.PeriodStart = BudgetPeriodStart(b.Period, time.Now()).Unix()
+	if err := DB.Create(b).Error; err != nil {
+		return err
+	}
+	InvalidateActiveBudgetCache()
+	return nil
+}
+
+// Update 更新预算配置，周期变化时重新开始计算用量
+func (b *Budget) Update(periodChanged bool) error {
+	fields := []string{"period", "quota_limit", "hard_limit", "alert_thresholds", "enabled", "notified_percent"}
+	if periodChanged {
+		b.UsedQuota = 0
+		b.PeriodStart = BudgetPeriodStart(b.Period, time.Now()).Unix()
+		fields = append(fields, "used_quota", "period_start")
+	}
+	// 修改预算后重新评估提醒阈值
+	b.NotifiedPercent = 0
+	if err := DB.Model(b).Select(fields).Updates(b).Error; err != nil {
+		return err
+	}
+	if periodChanged {
+		resetBudgetCounters(b.Id, b.PeriodStart)
+	}
+	InvalidateActiveBudgetCache()
+	return nil
+}
+
+func (b *Budget) Delete() error {
+	if err := DB.Delete(b).Error; err != nil {
+		return err
+	}
+	InvalidateActiveBudgetCache()
+	return nil
+}
+
+func GetBudgetById(id int) (*Budget, error) {
+	var budget Budget
+	if err := DB.First(&budget, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBudgetNotFound
+		}
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func GetAllBudgets(scope string, startIdx int, num int) (budgets []*Budget, total int64, err error) {
+	tx := DB.Model(&Budget{})
+	if scope != "" {
+		tx = tx.Where("scope = ?", scope)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&budgets).Error
+	return budgets, total, err
+}
+
+// GetUserVisibleBudgets 返回用户自己令牌的预算与管理员为该用户设置的预算
+func GetUserVisibleBudgets(userId int) ([]*Budget, error) {
+	var budgets []*Budget
+	err := DB.Where("(scope = ? AND user_id = ?) OR (scope = ? AND target_id = ?)",
+		BudgetScopeToken, userId, BudgetScopeUser, userId).
+		Order("id desc").Find(&budgets).Error
+	return budgets, err
+}
+
+// GetActiveBudgets 返回请求适用的全部启用预算
+func GetActiveBudgets(tokenId int, userId int, group string) ([]*Budget, error) {
+	var budgets []*Budget
+	err := DB.Where("enabled = ? AND ((scope = ? AND target_id = ?) OR (scope = ? AND target_id = ?) OR (scope = ? AND group_name = ?))",
+		true, BudgetScopeToken, tokenId, BudgetScopeUser, userId, BudgetScopeGroup, group).
+		Find(&budgets).Error
+	return budgets, err
+}
+
+// GetActiveBudgetsCached 带缓存的 GetActiveBudgets，供每个请求的预算预留使用。
+// 返回的是副本，其中的 UsedQuota、PeriodStart 等运行时字段可能已过期，预留与提醒需以数据库为准
+func GetActiveBudgetsCached(tokenId int, userId int, group string) ([]*Budget, error) {
+	cache := getActiveBudgetCache()
+	key := fmt.Sprintf("%d:%d:%s", tokenId, userId, group)
+	cached, found, err := cache.Get(key)
+	if err != nil || !found {
+		budgets, err := GetActiveBudgets(tokenId, userId, group)
+		if err != nil {
+			return nil, err
+		}
+		cached = make([]Budget, 0, len(budgets))
+		for _, budget := range budgets {
+			cached = append(cached, *budget)
+		}
+		_ = cache.SetWithTTL(key, cached, activeBudgetCacheTTL())
+	}
+	budgets := make([]*Budget, 0, len(cached))
+	for i := range cached {
+		budget := cached[i]
+		budgets = append(budgets, &budget)
+	}
+	return budgets, nil
+}
+
+// RollBudgetPeriod 进入新周期时清零用量与提醒记录，并同步内存中的预算
+func RollBudgetPeriod(b *Budget, now time.Time) error {
+	start := BudgetPeriodStart(b.Period, now).Unix()
+	if b.PeriodStart >= start {
+		return nil
+	}
+	if _, err := rollBudgetPeriodTo(b.Id, start); err != nil {
+		return err
+	}
+	b.UsedQuota = 0
+	b.NotifiedPercent = 0
+	b.PeriodStart = start
+	return nil
+}
+
+func rollBudgetPeriodTo(id int, start int64) (bool, error) {
+	result := DB.Model(&Budget{}).Where("id = ? AND period_start < ?", id, start).
+		Updates(map[string]interface{}{
+			"used_quota":       0,
+			"notified_percent": 0,
+			"period_start":     start,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReserveBudget 在 now 所在周期中预留 amount 额度，预算仍停留在旧周期时先重置再预留。
+// 硬上限预算在预留后会超出上限（或已用尽）时返回 false；b 可以来自缓存，只使用其 Id、Period、HardLimit 与 QuotaLimit。
+// 批量更新模式下在计数器上预留，见 budget_usage.go
+func ReserveBudget(b *Budget, now time.Time, amount int) (bool, error) {
+	start := BudgetPeriodStart(b.Period, now).Unix()
+	if budgetCountersEnabled() {
+		return reserveBudgetCounter(b, start, amount)
+	}
+	ok, err := reserveBudgetInPeriod(b, start, amount)
+	if err != nil || ok {
+		return ok, err
+	}
+	rolled, err := rollBudgetPeriodTo(b.Id, start)
+	if err != nil || !rolled {
+		return false, err
+	}
+	return reserveBudgetInPeriod(b, start, amount)
+}
+
+func reserveBudgetInPeriod(b *Budget, periodStart int64, amount int) (bool, error) {
+	tx := DB.Model(&Budget{}).Where("id = ? AND period_start = ?", b.Id, periodStart)
+	if b.HardLimit {
+		tx = tx.Where("used_quota < quota_limit AND used_quota + ? <= quota_limit", amount)
+	}
+	result := tx.Update("used_quota", gorm.Expr("used_quota + ?", amount))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// AdjustBudgetUsage 按差额调整预算用量，仅在预算仍处于 periodStart 所在周期时生效
+func AdjustBudgetUsage(id int, periodStart int64, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	if budgetCountersEnabled() {
+		return adjustBudgetCounter(budgetCounterKey{id: id, periodStart: periodStart}, delta)
+	}
+	return adjustBudgetUsageDB(id, periodStart, delta)
+}
+
+func adjustBudgetUsageDB(id int, periodStart int64, delta int) error {
+	return DB.Model(&Budget{}).Where("id = ? AND period_start = ?", id, periodStart).
+		Update("used_quota", gorm.Expr("used_quota + ?", delta)).Error
+}
+
+// RefreshBudgetUsage 批量更新模式下用计数器中的实时用量覆盖尚未写库的 UsedQuota
+func RefreshBudgetUsage(b *Budget) {
+	if !budgetCountersEnabled() {
+		return
+	}
+	if used, ok := budgetCounterUsage(budgetCounterKey{id: b.Id, periodStart: b.PeriodStart}); ok {
+		b.UsedQuota = used
+	}
+}
+
+// MarkBudgetNotified 记录已提醒的阈值，返回 false 表示已被其他请求提醒过
+func MarkBudgetNotified(b *Budget, threshold int) (bool, error) {
+	result := DB.Model(&Budget{}).
+		Where("id = ? AND period_start = ? AND notified_percent < ?", b.Id, b.PeriodStart, threshold).
+		Update("notified_percent", threshold)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
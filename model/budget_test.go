@@ -0,0 +1,178 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetPeriodStart(t *testing.T) {
+	// 2026-10-15 为周四
+	now := time.Date(2026, 10, 15, 13, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2026, 10, 15, 0, 0, 0, 0, time.Local), BudgetPeriodStart(BudgetPeriodDaily, now))
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local), BudgetPeriodStart(BudgetPeriodWeekly, now))
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), BudgetPeriodStart(BudgetPeriodMonthly, now))
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local), BudgetPeriodEnd(BudgetPeriodMonthly, now))
+
+	sunday := time.Date(2026, 10, 18, 23, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local), BudgetPeriodStart(BudgetPeriodWeekly, sunday))
+}
+
+func TestBudgetAlertThreshold(t *testing.T) {
+	assert.Equal(t, []int{50, 80, 95}, ParseBudgetAlertThresholds("95, 80,abc,50,80,120"))
+
+	b := &Budget{QuotaLimit: 1000, UsedQuota: 850, AlertThresholds: "50,80,95"}
+	assert.Equal(t, 80, b.PendingAlertThreshold())
+	b.NotifiedPercent = 80
+	assert.Equal(t, 0, b.PendingAlertThreshold())
+	b.UsedQuota = 990
+	assert.Equal(t, 95, b.PendingAlertThreshold())
+}
+
+func TestBudgetReserveAndRoll(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM budgets")
+	})
+
+	hard := &Budget{Scope: BudgetScopeToken, TargetId: 7, UserId: 1, Period: BudgetPeriodDaily, QuotaLimit: 100, HardLimit: true, Enabled: true}
+	require.NoError(t, hard.Insert())
+	soft := &Budget{Scope: BudgetScopeGroup, GroupName: "default", Period: BudgetPeriodMonthly, QuotaLimit: 100, Enabled: true}
+	require.NoError(t, soft.Insert())
+	disabled := &Budget{Scope: BudgetScopeUser, TargetId: 1, UserId: 1, Period: BudgetPeriodDaily, QuotaLimit: 100}
+	require.NoError(t, disabled.Insert())
+
+	budgets, err := GetActiveBudgets(7, 1, "default")
+	require.NoError(t, err)
+	assert.Len(t, budgets, 2)
+
+	ok, err := ReserveBudget(hard, time.Now(), 80)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ReserveBudget(hard, time.Now(), 30)
+	require.NoError(t, err)
+	assert.False(t, ok, "hard limit must reject reservations beyond the limit")
+	ok, err = ReserveBudget(soft, time.Now(), 150)
+	require.NoError(t, err)
+	assert.True(t, ok, "soft budgets only alert")
+
+	// 结算时多退少补，跨周期后旧周期的结算不再生效
+	require.NoError(t, AdjustBudgetUsage(hard.Id, hard.PeriodStart, -30))
+	reloaded, err := GetBudgetById(hard.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 50, reloaded.UsedQuota)
+
+	require.NoError(t, RollBudgetPeriod(reloaded, time.Now().AddDate(0, 0, 1)))
+	assert.Equal(t, 0, reloaded.UsedQuota)
+	require.NoError(t, AdjustBudgetUsage(hard.Id, hard.PeriodStart, 20))
+	reloaded, err = GetBudgetById(hard.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, reloaded.UsedQuota)
+
+	reloaded.UsedQuota = 90
+	reloaded.AlertThresholds = "80"
+	require.NoError(t, DB.Model(reloaded).Update("used_quota", 90).Error)
+	marked, err := MarkBudgetNotified(reloaded, 80)
+	require.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = MarkBudgetNotified(reloaded, 80)
+	require.NoError(t, err)
+	assert.False(t, marked)
+}
+
+func TestBudgetReserveRollsStalePeriod(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM budgets")
+	})
+
+	hard := &Budget{Scope: BudgetScopeUser, TargetId: 1, UserId: 1, Period: BudgetPeriodDaily, QuotaLimit: 100, HardLimit: true, Enabled: true}
+	require.NoError(t, hard.Insert())
+	// 用量停留在上一周期，预留时应先重置而不是累加到旧周期
+	yesterday := BudgetPeriodStart(BudgetPeriodDaily, time.Now().AddDate(0, 0, -1)).Unix()
+	require.NoError(t, DB.Model(hard).Updates(map[string]interface{}{"used_quota": 100, "period_start": yesterday}).Error)
+
+	ok, err := ReserveBudget(hard, time.Now(), 40)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	reloaded, err := GetBudgetById(hard.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 40, reloaded.UsedQuota)
+	assert.Equal(t, BudgetPeriodStart(BudgetPeriodDaily, time.Now()).Unix(), reloaded.PeriodStart)
+}
+
+func TestBudgetReserveBatchCounters(t *testing.T) {
+	truncateTables(t)
+	common.BatchUpdateEnabled = true
+	t.Cleanup(func() {
+		common.BatchUpdateEnabled = false
+		batchUpdate()
+		DB.Exec("DELETE FROM budgets")
+	})
+
+	hard := &Budget{Scope: BudgetScopeGroup, GroupName: "default", Period: BudgetPeriodDaily, QuotaLimit: 100, HardLimit: true, Enabled: true}
+	require.NoError(t, hard.Insert())
+	require.NoError(t, DB.Model(hard).Update("used_quota", 10).Error)
+
+	// 批量更新模式下在计数器上预留，不逐请求更新预算行
+	ok, err := ReserveBudget(hard, time.Now(), 70)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ReserveBudget(hard, time.Now(), 30)
+	require.NoError(t, err)
+	assert.False(t, ok, "counter starts from the persisted usage")
+	reloaded, err := GetBudgetById(hard.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 10, reloaded.UsedQuota)
+	RefreshBudgetUsage(reloaded)
+	assert.Equal(t, 80, reloaded.UsedQuota)
+
+	require.NoError(t, AdjustBudgetUsage(hard.Id, hard.PeriodStart, -30))
+	ok, err = ReserveBudget(hard, time.Now(), 30)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	batchUpdate()
+	reloaded, err = GetBudgetById(hard.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 80, reloaded.UsedQuota)
+
+	// 预算删除后不再预留，由调用方按数据库状态处理
+	require.NoError(t, hard.Delete())
+	batchUpdate()
+	batchUpdate()
+	ok, err = ReserveBudget(hard, time.Now(), 1)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestGetActiveBudgetsCachedInvalidatedOnEdit(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM budgets")
+		InvalidateActiveBudgetCache()
+	})
+	InvalidateActiveBudgetCache()
+
+	budget := &Budget{Scope: BudgetScopeToken, TargetId: 9, UserId: 1, Period: BudgetPeriodDaily, QuotaLimit: 100, Enabled: true}
+	require.NoError(t, budget.Insert())
+	budgets, err := GetActiveBudgetsCached(9, 1, "default")
+	require.NoError(t, err)
+	require.Len(t, budgets, 1)
+
+	// 返回副本，修改不影响缓存
+	budgets[0].QuotaLimit = 1
+	budgets, err = GetActiveBudgetsCached(9, 1, "default")
+	require.NoError(t, err)
+	assert.Equal(t, 100, budgets[0].QuotaLimit)
+
+	budget.Enabled = false
+	require.NoError(t, budget.Update(false))
+	budgets, err = GetActiveBudgetsCached(9, 1, "default")
+	require.NoError(t, err)
+	assert.Empty(t, budgets)
+}
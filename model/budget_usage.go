@@ -0,0 +1,243 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// 批量更新模式下预算用量不再逐请求更新 budgets 表：启用 Redis 时在 Redis 计数器上原子预留，多个节点共享同一计数器；
+// 否则在本进程的内存计数器上预留，多节点部署时各节点之间可能超出硬上限。
+// 用量变动先暂存，随 batchUpdate 写库；未启用批量更新时直接更新数据库
+
+// budgetCounterKey 计数器按预算与周期区分，进入新周期自然使用新的计数器
+type budgetCounterKey struct {
+	id          int
+	periodStart int64
+}
+
+func (k budgetCounterKey) redisKey() string {
+	return fmt.Sprintf("budget_usage:%d:%d", k.id, k.periodStart)
+}
+
+type budgetLocalCounter struct {
+	used    int
+	touched bool // 上次写库以来是否被使用，长期未使用的计数器在写库时清理
+}
+
+var (
+	budgetUsageLock    sync.Mutex
+	budgetUsagePending = make(map[budgetCounterKey]int)
+	budgetUsageLocal   = make(map[budgetCounterKey]*budgetLocalCounter)
+)
+
+// budgetReserveScript 计数器不存在时返回 -1，由调用方从数据库读取用量后带初始值重试；
+// ARGV: 初始用量（可为空）、预留额度、硬上限（-1 表示不限制）、过期秒数
+var budgetReserveScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	if ARGV[1] == '' then
+		return -1
+	end
+	redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[4], 'NX')
+end
+local used = tonumber(redis.call('GET', KEYS[1]))
+local amount = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+if limit >= 0 and (used >= limit or used + amount > limit) then
+	return 0
+end
+redis.call('INCRBY', KEYS[1], amount)
+return 1
+`)
+
+// budgetAdjustScript 仅在计数器存在时调整，不存在时下次预留会从数据库重新读取
+var budgetAdjustScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+func budgetCountersEnabled() bool {
+	return common.BatchUpdateEnabled
+}
+
+// loadBudgetPeriodUsage 从数据库读取预算在指定周期已写库的用量，加上本进程尚未写库的部分
+func loadBudgetPeriodUsage(key budgetCounterKey) (int, error) {
+	var budget Budget
+	if err := DB.Select("id", "used_quota", "period_start").First(&budget, "id = ?", key.id).Error; err != nil {
+		return 0, err
+	}
+	used := 0
+	if budget.PeriodStart == key.periodStart {
+		used = budget.UsedQuota
+	}
+	budgetUsageLock.Lock()
+	defer budgetUsageLock.Unlock()
+	return used + budgetUsagePending[key], nil
+}
+
+// reserveBudgetCounter 在计数器上预留额度，成功后暂存待写库的用量
+func reserveBudgetCounter(b *Budget, periodStart int64, amount int) (bool, error) {
+	key := budgetCounterKey{id: b.Id, periodStart: periodStart}
+	limit := -1
+	if b.HardLimit {
+		limit = b.QuotaLimit
+	}
+	var ok bool
+	var err error
+	if common.RedisEnabled {
+		ttl := time.Until(BudgetPeriodEnd(b.Period, time.Unix(periodStart, 0))) + 24*time.Hour
+		ok, err = reserveBudgetRedis(key, amount, limit, ttl)
+	} else {
+		ok, err = reserveBudgetLocal(key, amount, limit)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 缓存中的预算已被删除，由调用方按数据库状态处理
+		return false, nil
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+	addBudgetUsagePending(key, amount)
+	return true, nil
+}
+
+func reserveBudgetRedis(key budgetCounterKey, amount int, limit int, ttl time.Duration) (bool, error) {
+	ctx := context.Background()
+	keys := []string{key.redisKey()}
+	seconds := int64(ttl.Seconds())
+	result, err := budgetReserveScript.Run(ctx, common.RDB, keys, "", amount, limit, seconds).Int()
+	if err != nil {
+		return false, err
+	}
+	if result == -1 {
+		used, err := loadBudgetPeriodUsage(key)
+		if err != nil {
+			return false, err
+		}
+		result, err = budgetReserveScript.Run(ctx, common.RDB, keys, used, amount, limit, seconds).Int()
+		if err != nil {
+			return false, err
+		}
+	}
+	return result == 1, nil
+}
+
+func reserveBudgetLocal(key budgetCounterKey, amount int, limit int) (bool, error) {
+	budgetUsageLock.Lock()
+	_, exists := budgetUsageLocal[key]
+	budgetUsageLock.Unlock()
+	used := 0
+	if !exists {
+		var err error
+		if used, err = loadBudgetPeriodUsage(key); err != nil {
+			return false, err
+		}
+	}
+	budgetUsageLock.Lock()
+	defer budgetUsageLock.Unlock()
+	counter, ok := budgetUsageLocal[key]
+	if !ok {
+		counter = &budgetLocalCounter{used: used}
+		budgetUsageLocal[key] = counter
+	}
+	counter.touched = true
+	if limit >= 0 && (counter.used >= limit || counter.used+amount > limit) {
+		return false, nil
+	}
+	counter.used += amount
+	return true, nil
+}
+
+// adjustBudgetCounter 调整计数器并暂存待写库的差额
+func adjustBudgetCounter(key budgetCounterKey, delta int) error {
+	if common.RedisEnabled {
+		if err := budgetAdjustScript.Run(context.Background(), common.RDB, []string{key.redisKey()}, delta).Err(); err != nil {
+			return err
+		}
+	} else {
+		budgetUsageLock.Lock()
+		if counter, ok := budgetUsageLocal[key]; ok {
+			counter.used += delta
+			counter.touched = true
+		}
+		budgetUsageLock.Unlock()
+	}
+	addBudgetUsagePending(key, delta)
+	return nil
+}
+
+func addBudgetUsagePending(key budgetCounterKey, delta int) {
+	budgetUsageLock.Lock()
+	defer budgetUsageLock.Unlock()
+	budgetUsagePending[key] += delta
+}
+
+// budgetCounterUsage 返回计数器中的实时用量，计数器不存在时返回 false
+func budgetCounterUsage(key budgetCounterKey) (int, bool) {
+	if common.RedisEnabled {
+		used, err := common.RDB.Get(context.Background(), key.redisKey()).Int()
+		return used, err == nil
+	}
+	budgetUsageLock.Lock()
+	defer budgetUsageLock.Unlock()
+	if counter, ok := budgetUsageLocal[key]; ok {
+		return counter.used, true
+	}
+	return 0, false
+}
+
+// resetBudgetCounters 预算周期变更并清零用量后丢弃该预算的计数器与暂存用量
+func resetBudgetCounters(id int, periodStart int64) {
+	budgetUsageLock.Lock()
+	for key := range budgetUsagePending {
+		if key.id == id {
+			delete(budgetUsagePending, key)
+		}
+	}
+	for key := range budgetUsageLocal {
+		if key.id == id {
+			delete(budgetUsageLocal, key)
+		}
+	}
+	budgetUsageLock.Unlock()
+	if common.RedisEnabled {
+		_ = common.RedisDelKey(budgetCounterKey{id: id, periodStart: periodStart}.redisKey())
+	}
+}
+
+// flushBudgetUsage 将暂存的预算用量写库，并清理上次写库以来未使用的内存计数器
+func flushBudgetUsage() {
+	budgetUsageLock.Lock()
+	pending := budgetUsagePending
+	budgetUsagePending = make(map[budgetCounterKey]int)
+	for key, counter := range budgetUsageLocal {
+		if !counter.touched {
+			delete(budgetUsageLocal, key)
+			continue
+		}
+		counter.touched = false
+	}
+	budgetUsageLock.Unlock()
+	for key, delta := range pending {
+		if delta == 0 {
+			continue
+		}
+		// 预算仍停留在旧周期时先进入新周期，已进入更新周期的旧用量不再写入
+		if _, err := rollBudgetPeriodTo(key.id, key.periodStart); err != nil {
+			common.SysLog(fmt.Sprintf("failed to roll budget %d period: %s", key.id, err.Error()))
+			continue
+		}
+		if err := adjustBudgetUsageDB(key.id, key.periodStart, delta); err != nil {
+			common.SysLog(fmt.Sprintf("failed to batch update budget %d usage: %s", key.id, err.Error()))
+		}
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/circuitbreaker"
+	"github.com/QuantumNous/new-api/pkg/multikey"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/samber/lo"
@@ -65,6 +67,9 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyWeights        map[int]int           `json:"multi_key_weights,omitempty"`    // weighted 模式下各 Key 的权重，未设置时为 1
+	MultiKeyRpmLimits      map[int]int           `json:"multi_key_rpm_limits,omitempty"` // quota_aware 模式下各 Key 每分钟请求数上限，0 或未设置表示不限
+	MultiKeyTpmLimits      map[int]int           `json:"multi_key_tpm_limits,omitempty"` // quota_aware 模式下各 Key 每分钟 Token 数上限，0 或未设置表示不限
 }
 
 // Value implements driver.Valuer interface
@@ -103,8 +108,24 @@ func (channel *Channel) GetKeys() []string {
 }
 
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
+	return channel.GetNextEnabledKeyForModel("")
+}
+
+// GetNextEnabledKeyForModel 选择下一个可用 Key，modelName 不为空时跳过该模型下处于熔断中的 Key
+// 所有启用的 Key 都在熔断中时仍按原有规则选择，避免熔断导致渠道完全不可用
+func (channel *Channel) GetNextEnabledKeyForModel(modelName string) (key string, index int, apiErr *types.NewAPIError) {
+	return channel.GetNextEnabledKeyWithLeases(modelName, nil)
+}
+
+// GetNextEnabledKeyWithLeases 与 GetNextEnabledKeyForModel 相同，least_in_flight 模式下同时占用所选 Key 的进行中计数，
+// 占用记录在 leases 中，由调用方在请求结束后释放
+func (channel *Channel) GetNextEnabledKeyWithLeases(modelName string, leases *multikey.Leases) (key string, index int, apiErr *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
+		if modelName != "" {
+			// 通常已在选择渠道时占用探测机会，这里补充占用未经渠道选择直接指定的渠道；结果不影响单 Key 渠道的使用
+			circuitbreaker.TryAcquire(circuitbreaker.Key{ChannelId: channel.Id, Model: modelName})
+		}
 		return channel.Key, 0, nil
 	}
 
@@ -120,8 +141,13 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	defer lock.Unlock()
 
 	statusList := channel.ChannelInfo.MultiKeyStatusList
+	// 熔断中或探测机会已被占用的 Key 视为不可用
+	excluded := make(map[int]bool)
 	// helper to get key status, default to enabled when missing
 	getStatus := func(idx int) int {
+		if excluded[idx] {
+			return common.ChannelStatusAutoDisabled
+		}
 		if statusList == nil {
 			return common.ChannelStatusEnabled
 		}
@@ -144,18 +170,55 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	if len(enabledIdx) == 0 {
 		return "", 0, types.NewError(errors.New("no enabled keys"), types.ErrorCodeChannelNoAvailableKey)
 	}
+	if modelName != "" {
+		availableIdx := make([]int, 0, len(enabledIdx))
+		for _, idx := range enabledIdx {
+			if circuitbreaker.Available(circuitbreaker.Key{ChannelId: channel.Id, Model: modelName, KeyIndex: idx}) {
+				availableIdx = append(availableIdx, idx)
+			} else {
+				excluded[idx] = true
+			}
+		}
+		if len(availableIdx) > 0 {
+			enabledIdx = availableIdx
+		} else {
+			clear(excluded)
+		}
+	}
+
+	for {
+		selectedIdx, apiErr := channel.selectEnabledKey(len(keys), enabledIdx, getStatus)
+		if apiErr != nil {
+			return "", 0, apiErr
+		}
+		// 选中后在同一次加锁内占用 half_open 的探测机会；被其他请求抢先占用时排除该 Key 重新选择，
+		// 没有其他 Key 可选时仍使用该 Key，与全部熔断时的处理一致
+		claimed := modelName == "" || circuitbreaker.TryAcquire(circuitbreaker.Key{ChannelId: channel.Id, Model: modelName, KeyIndex: selectedIdx})
+		if !claimed && len(enabledIdx) > 1 {
+			excluded[selectedIdx] = true
+			enabledIdx = lo.Without(enabledIdx, selectedIdx)
+			continue
+		}
+		if channel.ChannelInfo.MultiKeyMode == constant.MultiKeyModeLeastInFlight && leases != nil {
+			// 在渠道锁内占用计数，避免同一实例的并发请求选中同一个 Key
+			leases.Add(multikey.Acquire(channel.Id, selectedIdx))
+		}
+		return keys[selectedIdx], selectedIdx, nil
+	}
+}
 
+// selectEnabledKey 按多 Key 模式在 enabledIdx 中选择一个 Key，调用方需持有渠道轮询锁
+func (channel *Channel) selectEnabledKey(keyCount int, enabledIdx []int, getStatus func(idx int) int) (int, *types.NewAPIError) {
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
 		// Randomly pick one enabled key
-		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
-		return keys[selectedIdx], selectedIdx, nil
+		return enabledIdx[rand.Intn(len(enabledIdx))], nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
 
 		channelInfo, err := CacheGetChannelInfo(channel.Id)
 		if err != nil {
-			return "", 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+			return 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 		}
 		//println("before polling index:", channel.ChannelInfo.MultiKeyPollingIndex)
 		defer func() {
@@ -170,23 +233,56 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}()
 		// Start from the saved polling index and look for the next enabled key
 		start := channelInfo.MultiKeyPollingIndex
-		if start < 0 || start >= len(keys) {
+		if start < 0 || start >= keyCount {
 			start = 0
 		}
-		for i := 0; i < len(keys); i++ {
-			idx := (start + i) % len(keys)
+		for i := 0; i < keyCount; i++ {
+			idx := (start + i) % keyCount
 			if getStatus(idx) == common.ChannelStatusEnabled {
 				// update polling index for next call (point to the next position)
-				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
-				return keys[idx], idx, nil
+				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % keyCount
+				return idx, nil
 			}
 		}
 		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return enabledIdx[0], nil
+	case constant.MultiKeyModeLRU:
+		return channel.selectLeastRecentlyUsedKey(enabledIdx), nil
+	case constant.MultiKeyModeLeastInFlight:
+		return channel.selectLeastInFlightKey(enabledIdx), nil
+	case constant.MultiKeyModeWeighted:
+		return channel.selectWeightedKey(enabledIdx), nil
+	case constant.MultiKeyModeQuotaAware:
+		return channel.selectQuotaAwareKey(enabledIdx), nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return enabledIdx[0], nil
+	}
+}
+
+// ClaimCircuit 选中渠道时占用熔断器：单 Key 渠道在同一次加锁内判断并占用 half_open 的探测机会，
+// 多 Key 渠道在选择 Key 时再逐个占用，这里只判断是否存在可用的 Key
+func (channel *Channel) ClaimCircuit(modelName string) bool {
+	if channel.ChannelInfo.IsMultiKey {
+		return channel.IsCircuitAvailable(modelName)
 	}
+	return circuitbreaker.TryAcquire(circuitbreaker.Key{ChannelId: channel.Id, Model: modelName})
+}
+
+// IsCircuitAvailable 渠道在该模型下是否存在未熔断的启用 Key
+func (channel *Channel) IsCircuitAvailable(modelName string) bool {
+	if !channel.ChannelInfo.IsMultiKey {
+		return circuitbreaker.Available(circuitbreaker.Key{ChannelId: channel.Id, Model: modelName})
+	}
+	for idx := 0; idx < channel.ChannelInfo.MultiKeySize; idx++ {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[idx]; ok && status != common.ChannelStatusEnabled {
+			continue
+		}
+		if circuitbreaker.Available(circuitbreaker.Key{ChannelId: channel.Id, Model: modelName, KeyIndex: idx}) {
+			return true
+		}
+	}
+	return false
 }
 
 func (channel *Channel) SaveChannelInfo() error {
@@ -403,7 +499,15 @@ func BatchDeleteChannels(ids []int) error {
 			return err
 		}
 	}
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id > 0 {
+			circuitbreaker.Reset(id)
+		}
+	}
+	return nil
 }
 
 func (channel *Channel) GetPriority() int64 {
@@ -531,6 +635,9 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
+	if err == nil && channel.Id > 0 {
+		circuitbreaker.Reset(channel.Id)
+	}
 	return err
 }
 
@@ -11,7 +11,12 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/channelscore"
+	"github.com/QuantumNous/new-api/pkg/circuitbreaker"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/samber/lo"
 )
 
 var group2model2channels map[string]map[string][]int // enabled channel
@@ -82,6 +87,10 @@ func InitChannelCache() {
 	}
 	channelsIDM = newChannelId2channel
 	channelSyncLock.Unlock()
+	circuitbreaker.Prune(func(channelId int) bool {
+		_, ok := newChannelId2channel[channelId]
+		return ok
+	})
 	common.SysLog("channels synced from database")
 }
 
@@ -99,36 +108,55 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		return GetChannel(group, model, retry)
 	}
 
+	// Key 额度状态可能需要访问 Redis，在读锁之外查询
+	keyQuotaUnavailable := keyQuotaUnavailableChannels(cachedQuotaAwareChannels(group, model))
+
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
 
-	// First, try to find channels with the exact model name.
-	channels := group2model2channels[group][model]
+	channelIds := cachedSatisfiedChannelIds(group, model)
+	if len(channelIds) == 0 {
+		return nil, nil
+	}
+	channels := make([]*Channel, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		channel, ok := channelsIDM[channelId]
+		if !ok {
+			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+		}
+		channels = append(channels, channel)
+	}
+	channels = filterCircuitAvailableChannels(channels, model)
+	channels = filterKeyQuotaAvailableChannels(channels, keyQuotaUnavailable)
+	return pickSatisfiedChannel(group, model, retry, channels)
+}
 
-	// If no channels found, try to find channels with the normalized model name.
-	if len(channels) == 0 {
-		normalizedModel := ratio_setting.FormatMatchingModelName(model)
-		channels = group2model2channels[group][normalizedModel]
+// pickSatisfiedChannel 在候选渠道中按 retry 选择优先级，再按权重（或自适应权重）随机选择渠道。
+// 内存缓存与数据库两条路径共用，保证熔断、Key 额度与自适应权重的行为一致。
+// 选中的渠道需要占用熔断器，half_open 的探测机会已被其他请求占用时排除该渠道重新选择，
+// 没有其他渠道可选时仍返回该渠道，与全部熔断时的处理一致
+func pickSatisfiedChannel(group string, model string, retry int, channels []*Channel) (*Channel, error) {
+	for {
+		channel, err := pickWeightedChannel(group, model, retry, channels)
+		if err != nil || channel == nil || channel.ClaimCircuit(model) || len(channels) == 1 {
+			return channel, err
+		}
+		channels = lo.Without(channels, channel)
 	}
+}
 
+// pickWeightedChannel 在候选渠道中按 retry 选择优先级，再按权重（或自适应权重）随机选择渠道
+func pickWeightedChannel(group string, model string, retry int, channels []*Channel) (*Channel, error) {
 	if len(channels) == 0 {
 		return nil, nil
 	}
-
 	if len(channels) == 1 {
-		if channel, ok := channelsIDM[channels[0]]; ok {
-			return channel, nil
-		}
-		return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channels[0])
+		return channels[0], nil
 	}
 
 	uniquePriorities := make(map[int]bool)
-	for _, channelId := range channels {
-		if channel, ok := channelsIDM[channelId]; ok {
-			uniquePriorities[int(channel.GetPriority())] = true
-		} else {
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
-		}
+	for _, channel := range channels {
+		uniquePriorities[int(channel.GetPriority())] = true
 	}
 	var sortedUniquePriorities []int
 	for priority := range uniquePriorities {
@@ -144,14 +172,10 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	// get the priority for the given retry number
 	var sumWeight = 0
 	var targetChannels []*Channel
-	for _, channelId := range channels {
-		if channel, ok := channelsIDM[channelId]; ok {
-			if channel.GetPriority() == targetPriority {
-				sumWeight += channel.GetWeight()
-				targetChannels = append(targetChannels, channel)
-			}
-		} else {
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+	for _, channel := range channels {
+		if channel.GetPriority() == targetPriority {
+			sumWeight += channel.GetWeight()
+			targetChannels = append(targetChannels, channel)
 		}
 	}
 
@@ -173,6 +197,10 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		smoothingFactor = 100
 	}
 
+	if operation_setting.IsAdaptiveRouting(group) {
+		return pickAdaptiveChannel(targetChannels, model, smoothingFactor, smoothingAdjustment), nil
+	}
+
 	// Calculate the total weight of all channels up to endIdx
 	totalWeight := sumWeight * smoothingFactor
 
@@ -190,6 +218,103 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	return nil, errors.New("channel not found")
 }
 
+// cachedSatisfiedChannelIds 返回分组下支持该模型的渠道，精确匹配不到时按规范化的模型名查找
+// 调用方需持有 channelSyncLock 读锁
+func cachedSatisfiedChannelIds(group string, model string) []int {
+	channels := group2model2channels[group][model]
+	if len(channels) == 0 {
+		normalizedModel := ratio_setting.FormatMatchingModelName(model)
+		channels = group2model2channels[group][normalizedModel]
+	}
+	return channels
+}
+
+// cachedQuotaAwareChannels 返回候选渠道中 quota_aware 模式的多 Key 渠道
+func cachedQuotaAwareChannels(group string, model string) []*Channel {
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	var result []*Channel
+	for _, channelId := range cachedSatisfiedChannelIds(group, model) {
+		if channel, ok := channelsIDM[channelId]; ok && channel.isQuotaAwareMultiKey() {
+			result = append(result, channel)
+		}
+	}
+	return result
+}
+
+// filterCircuitAvailableChannels 排除在该模型下处于熔断中的渠道，全部熔断时保留原列表
+func filterCircuitAvailableChannels(channels []*Channel, model string) []*Channel {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return channels
+	}
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if channel.IsCircuitAvailable(model) {
+			available = append(available, channel)
+		}
+	}
+	if len(available) == 0 {
+		return channels
+	}
+	return available
+}
+
+// filterKeyQuotaAvailableChannels 排除全部 Key 都已暂停或额度用尽的 quota_aware 渠道，全部不可用时保留原列表
+func filterKeyQuotaAvailableChannels(channels []*Channel, unavailable map[int]bool) []*Channel {
+	if len(unavailable) == 0 {
+		return channels
+	}
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !unavailable[channel.Id] {
+			available = append(available, channel)
+		}
+	}
+	if len(available) == 0 {
+		return channels
+	}
+	return available
+}
+
+// GetChannelScoreParams 根据渠道选择策略配置生成健康系数计算参数
+func GetChannelScoreParams() channelscore.Params {
+	routingSetting := operation_setting.GetChannelRoutingSetting()
+	return channelscore.Params{
+		Window:        time.Duration(routingSetting.WindowSeconds) * time.Second,
+		MinSamples:    int64(routingSetting.MinSamples),
+		LatencyWeight: routingSetting.LatencyWeight,
+		ErrorPenalty:  routingSetting.ErrorPenalty,
+		MinFactor:     routingSetting.MinWeightFactor,
+	}
+}
+
+// pickAdaptiveChannel 在静态权重的基础上乘以渠道实时健康系数后加权随机选择
+// 健康系数来自真实中继流量的 EWMA 统计，劣化渠道只降低权重而不会被完全排除
+func pickAdaptiveChannel(targetChannels []*Channel, model string, smoothingFactor int, smoothingAdjustment int) *Channel {
+	channelIds := make([]int, len(targetChannels))
+	for i, channel := range targetChannels {
+		channelIds[i] = channel.Id
+	}
+	factors := channelscore.Factors(channelIds, model, GetChannelScoreParams())
+	weights := make([]float64, len(targetChannels))
+	totalWeight := 0.0
+	for i, channel := range targetChannels {
+		weights[i] = float64(channel.GetWeight()*smoothingFactor+smoothingAdjustment) * factors[i]
+		totalWeight += weights[i]
+	}
+	if totalWeight <= 0 {
+		return targetChannels[rand.Intn(len(targetChannels))]
+	}
+	randomWeight := rand.Float64() * totalWeight
+	for i, channel := range targetChannels {
+		randomWeight -= weights[i]
+		if randomWeight < 0 {
+			return channel
+		}
+	}
+	return targetChannels[len(targetChannels)-1]
+}
+
 func CacheGetChannel(id int) (*Channel, error) {
 	if !common.MemoryCacheEnabled {
 		return GetChannelById(id, true)
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/circuitbreaker"
+	"github.com/QuantumNous/new-api/pkg/multikey"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRandomSatisfiedChannelSkipsExhaustedQuotaAwareChannel(t *testing.T) {
+	parked := &Channel{Id: 901, ChannelInfo: ChannelInfo{IsMultiKey: true, MultiKeySize: 1, MultiKeyMode: constant.MultiKeyModeQuotaAware}}
+	healthy := &Channel{Id: 902}
+	multikey.Park(parked.Id, 0, time.Minute)
+
+	common.MemoryCacheEnabled = true
+	channelSyncLock.Lock()
+	group2model2channels = map[string]map[string][]int{"default": {"gpt-test": {parked.Id, healthy.Id}}}
+	channelsIDM = map[int]*Channel{parked.Id: parked, healthy.Id: healthy}
+	channelSyncLock.Unlock()
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = false
+		keyQuotaAvailableCache.Delete(parked.Id)
+	})
+
+	for i := 0; i < 20; i++ {
+		channel, err := GetRandomSatisfiedChannel("default", "gpt-test", 0)
+		require.NoError(t, err)
+		assert.Equal(t, healthy.Id, channel.Id)
+	}
+}
+
+func TestGetChannelFromDBSkipsOpenCircuit(t *testing.T) {
+	truncateTables(t)
+	setting := operation_setting.GetCircuitBreakerSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.ConsecutiveFailures = 1
+	setting.OpenSeconds = 60
+	t.Cleanup(func() {
+		*setting = saved
+		circuitbreaker.Reset(0)
+	})
+
+	tripped := &Channel{Id: 911, Name: "tripped", Key: "sk-1", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-test"}
+	healthy := &Channel{Id: 912, Name: "healthy", Key: "sk-2", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-test"}
+	for _, channel := range []*Channel{tripped, healthy} {
+		require.NoError(t, DB.Create(channel).Error)
+		require.NoError(t, channel.AddAbilities(nil))
+	}
+	circuitbreaker.Record(circuitbreaker.Key{ChannelId: tripped.Id, Model: "gpt-test"}, false)
+
+	// 未启用内存缓存时从数据库选择渠道，同样跳过熔断中的渠道
+	for i := 0; i < 20; i++ {
+		channel, err := GetChannel("default", "gpt-test", 0)
+		require.NoError(t, err)
+		require.NotNil(t, channel)
+		assert.Equal(t, healthy.Id, channel.Id)
+	}
+}
+
+func TestPickSatisfiedChannelReselectsWhenCircuitNotClaimed(t *testing.T) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.ConsecutiveFailures = 1
+	setting.OpenSeconds = 60
+	t.Cleanup(func() {
+		*setting = saved
+		circuitbreaker.Reset(0)
+	})
+
+	// 模拟筛选后熔断器被其他请求占用：候选列表中仍有不可占用的渠道
+	blocked := &Channel{Id: 921}
+	healthy := &Channel{Id: 922}
+	circuitbreaker.Record(circuitbreaker.Key{ChannelId: blocked.Id, Model: "gpt-test"}, false)
+
+	for i := 0; i < 20; i++ {
+		channel, err := pickSatisfiedChannel("default", "gpt-test", 0, []*Channel{blocked, healthy})
+		require.NoError(t, err)
+		assert.Equal(t, healthy.Id, channel.Id)
+	}
+	// 没有其他渠道可选时仍返回该渠道
+	channel, err := pickSatisfiedChannel("default", "gpt-test", 0, []*Channel{blocked})
+	require.NoError(t, err)
+	assert.Equal(t, blocked.Id, channel.Id)
+}
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/multikey"
+)
+
+// pickLowest 在得分最低的 Key 中随机选择一个
+func pickLowest(indexes []int, score func(idx int) float64) int {
+	best := make([]int, 0, len(indexes))
+	var bestScore float64
+	for _, idx := range indexes {
+		s := score(idx)
+		switch {
+		case len(best) == 0 || s < bestScore:
+			best = append(best[:0], idx)
+			bestScore = s
+		case s == bestScore:
+			best = append(best, idx)
+		}
+	}
+	return best[rand.Intn(len(best))]
+}
+
+// selectLeastRecentlyUsedKey 选择最久未使用的 Key
+func (channel *Channel) selectLeastRecentlyUsedKey(enabledIdx []int) int {
+	lastUsed := multikey.LastUsed(channel.Id, enabledIdx)
+	selectedIdx := pickLowest(enabledIdx, func(idx int) float64 {
+		return float64(lastUsed[idx])
+	})
+	multikey.TouchLastUsed(channel.Id, selectedIdx)
+	return selectedIdx
+}
+
+// selectLeastInFlightKey 选择进行中请求最少的 Key
+func (channel *Channel) selectLeastInFlightKey(enabledIdx []int) int {
+	inFlight := multikey.InFlight(channel.Id, enabledIdx)
+	return pickLowest(enabledIdx, func(idx int) float64 {
+		return float64(inFlight[idx])
+	})
+}
+
+func (channel *Channel) multiKeyWeight(idx int) int {
+	weight, ok := channel.ChannelInfo.MultiKeyWeights[idx]
+	if !ok {
+		return 1
+	}
+	return max(weight, 0)
+}
+
+// selectWeightedKey 按权重随机选择 Key，全部权重为 0 时等概率选择
+func (channel *Channel) selectWeightedKey(enabledIdx []int) int {
+	totalWeight := 0
+	for _, idx := range enabledIdx {
+		totalWeight += channel.multiKeyWeight(idx)
+	}
+	if totalWeight <= 0 {
+		return enabledIdx[rand.Intn(len(enabledIdx))]
+	}
+	r := rand.Intn(totalWeight)
+	for _, idx := range enabledIdx {
+		r -= channel.multiKeyWeight(idx)
+		if r < 0 {
+			return idx
+		}
+	}
+	return enabledIdx[len(enabledIdx)-1]
+}
+
+// keyQuotaUtilization 返回 Key 当前分钟的额度使用率（RPM 与 TPM 中较高者），未设置上限时为 0
+func (channel *Channel) keyQuotaUtilization(idx int, usage multikey.Usage) float64 {
+	utilization := 0.0
+	if limit := channel.ChannelInfo.MultiKeyRpmLimits[idx]; limit > 0 {
+		utilization = max(utilization, float64(usage.Requests)/float64(limit))
+	}
+	if limit := channel.ChannelInfo.MultiKeyTpmLimits[idx]; limit > 0 {
+		utilization = max(utilization, float64(usage.Tokens)/float64(limit))
+	}
+	return utilization
+}
+
+// quotaAvailableKeys 返回未暂停且当前分钟额度未用尽的 Key
+func (channel *Channel) quotaAvailableKeys(enabledIdx []int) (available []int, usage map[int]multikey.Usage, parked map[int]int64) {
+	usage = multikey.MinuteUsage(channel.Id, enabledIdx)
+	parked = multikey.ParkedUntil(channel.Id, enabledIdx)
+	available = make([]int, 0, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		if _, ok := parked[idx]; ok {
+			continue
+		}
+		if channel.keyQuotaUtilization(idx, usage[idx]) >= 1 {
+			continue
+		}
+		available = append(available, idx)
+	}
+	return available, usage, parked
+}
+
+// selectQuotaAwareKey 在未暂停且额度未用尽的 Key 中选择使用率最低的一个
+// 全部不可用时选择未暂停中使用率最低的 Key，全部暂停时选择最早恢复的 Key
+func (channel *Channel) selectQuotaAwareKey(enabledIdx []int) int {
+	available, usage, parked := channel.quotaAvailableKeys(enabledIdx)
+	var selectedIdx int
+	if len(available) > 0 {
+		selectedIdx = pickLowest(available, func(idx int) float64 {
+			return channel.keyQuotaUtilization(idx, usage[idx])
+		})
+	} else {
+		selectedIdx = pickLowest(enabledIdx, func(idx int) float64 {
+			if until, ok := parked[idx]; ok {
+				// 暂停中的 Key 排在所有未暂停的 Key 之后
+				return float64(until)
+			}
+			return channel.keyQuotaUtilization(idx, usage[idx])
+		})
+	}
+	multikey.AddRequest(channel.Id, selectedIdx)
+	return selectedIdx
+}
+
+func (channel *Channel) isQuotaAwareMultiKey() bool {
+	return channel.ChannelInfo.IsMultiKey && channel.ChannelInfo.MultiKeyMode == constant.MultiKeyModeQuotaAware
+}
+
+// IsKeyQuotaAvailable quota_aware 模式的多 Key 渠道是否还有未暂停且额度未用尽的启用 Key，其他渠道始终返回 true
+func (channel *Channel) IsKeyQuotaAvailable() bool {
+	if !channel.isQuotaAwareMultiKey() {
+		return true
+	}
+	enabledIdx := channel.enabledKeyIndexes()
+	if len(enabledIdx) == 0 {
+		return true
+	}
+	available, _, _ := channel.quotaAvailableKeys(enabledIdx)
+	return len(available) > 0
+}
+
+func (channel *Channel) enabledKeyIndexes() []int {
+	enabledIdx := make([]int, 0, channel.ChannelInfo.MultiKeySize)
+	for idx := 0; idx < channel.ChannelInfo.MultiKeySize; idx++ {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[idx]; ok && status != common.ChannelStatusEnabled {
+			continue
+		}
+		enabledIdx = append(enabledIdx, idx)
+	}
+	return enabledIdx
+}
+
+// keyQuotaAvailableTTL 渠道 Key 额度可用状态的缓存时间，选择渠道时不必为每个候选渠道访问 Redis
+const keyQuotaAvailableTTL = time.Second
+
+type keyQuotaAvailableEntry struct {
+	available bool
+	expireAt  time.Time
+}
+
+var keyQuotaAvailableCache sync.Map // channelId -> keyQuotaAvailableEntry
+
+// isKeyQuotaAvailableCached 带短时缓存的 IsKeyQuotaAvailable
+func (channel *Channel) isKeyQuotaAvailableCached() bool {
+	if !channel.isQuotaAwareMultiKey() {
+		return true
+	}
+	now := time.Now()
+	if v, ok := keyQuotaAvailableCache.Load(channel.Id); ok {
+		if entry := v.(keyQuotaAvailableEntry); now.Before(entry.expireAt) {
+			return entry.available
+		}
+	}
+	available := channel.IsKeyQuotaAvailable()
+	keyQuotaAvailableCache.Store(channel.Id, keyQuotaAvailableEntry{available: available, expireAt: now.Add(keyQuotaAvailableTTL)})
+	return available
+}
+
+// keyQuotaUnavailableChannels 返回全部 Key 都已暂停或额度用尽的渠道
+func keyQuotaUnavailableChannels(channels []*Channel) map[int]bool {
+	var unavailable map[int]bool
+	for _, channel := range channels {
+		if channel.isKeyQuotaAvailableCached() {
+			continue
+		}
+		if unavailable == nil {
+			unavailable = make(map[int]bool)
+		}
+		unavailable[channel.Id] = true
+	}
+	return unavailable
+}
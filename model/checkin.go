@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
 	"time"
 
@@ -91,6 +92,15 @@ func UserCheckin(userId int) (*Checkin, error) {
 	return userCheckinWithTransaction(checkin, userId, quotaAwarded)
 }
 
+// checkinLedgerRef 每个用户每天的签到奖励只入账一次
+func checkinLedgerRef(checkin *Checkin) LedgerRef {
+	return LedgerRef{
+		Reason:         LedgerReasonCheckin,
+		RefId:          checkin.CheckinDate,
+		IdempotencyKey: fmt.Sprintf("checkin:%d:%s", checkin.UserId, checkin.CheckinDate),
+	}
+}
+
 // userCheckinWithTransaction 使用事务执行签到（适用于 MySQL 和 PostgreSQL）
 func userCheckinWithTransaction(checkin *Checkin, userId int, quotaAwarded int) (*Checkin, error) {
 	err := DB.Transaction(func(tx *gorm.DB) error {
@@ -105,6 +115,10 @@ func userCheckinWithTransaction(checkin *Checkin, userId int, quotaAwarded int)
 			Update("quota", gorm.Expr("quota + ?", quotaAwarded)).Error; err != nil {
 			return errors.New("签到失败：更新额度出错")
 		}
+		if err := PostLedger(tx, checkinLedgerRef(checkin),
+			LedgerPosting{AccountType: LedgerAccountUser, AccountId: userId, Delta: int64(quotaAwarded)}); err != nil {
+			return errors.New("签到失败：更新额度出错")
+		}
 
 		return nil
 	})
@@ -131,7 +145,7 @@ func userCheckinWithoutTransaction(checkin *Checkin, userId int, quotaAwarded in
 
 	// 步骤2: 增加用户额度
 	// 使用 db=true 强制直接写入数据库，不使用批量更新
-	if err := IncreaseUserQuota(userId, quotaAwarded, true); err != nil {
+	if err := IncreaseUserQuota(userId, quotaAwarded, true, checkinLedgerRef(checkin)); err != nil {
 		// 如果增加额度失败，需要回滚签到记录
 		DB.Delete(checkin)
 		return nil, errors.New("签到失败：更新额度出错")
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FileStatusUploaded  = "uploaded"
+	FileStatusProcessed = "processed"
+	FileStatusError     = "error"
+)
+
+// FileUpstreamIds 记录文件在各渠道上游的文件 ID，key 为 "渠道 ID:密钥摘要"。
+// 上游文件归属于具体的账号，多 Key 渠道的不同 Key 不能互相复用
+type FileUpstreamIds map[string]string
+
+func (m *FileUpstreamIds) Scan(val interface{}) error {
+	var bytesValue []byte
+	switch v := val.(type) {
+	case []byte:
+		bytesValue = v
+	case string:
+		bytesValue = []byte(v)
+	}
+	if len(bytesValue) == 0 {
+		*m = FileUpstreamIds{}
+		return nil
+	}
+	return common.Unmarshal(bytesValue, m)
+}
+
+func (m FileUpstreamIds) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return common.Marshal(m)
+}
+
+// File 通过 /v1/files 上传的文件，归属于上传时的用户与令牌
+type File struct {
+	Id             int             `json:"-" gorm:"primaryKey"`
+	FileId         string          `json:"id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int             `json:"-" gorm:"index"`
+	TokenId        int             `json:"-" gorm:"index"`
+	Filename       string          `json:"filename" gorm:"type:varchar(255)"`
+	Purpose        string          `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes          int64           `json:"bytes"`
+	MimeType       string          `json:"-" gorm:"type:varchar(128)"`
+	Status         string          `json:"status" gorm:"type:varchar(20)"`
+	StorageBackend string          `json:"-" gorm:"type:varchar(32)"`
+	StorageKey     string          `json:"-" gorm:"type:varchar(255)"`
+	CreatedAt      int64           `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt      int64           `json:"expires_at" gorm:"bigint;index"`
+	UpstreamIds    FileUpstreamIds `json:"-" gorm:"column:upstream_ids;type:json"`
+}
+
+// GenerateFileId 生成对外暴露的 file-xxxx 格式 ID
+func GenerateFileId() string {
+	key, _ := common.GenerateRandomCharsKey(24)
+	return "file-" + key
+}
+
+func (f *File) Insert() error {
+	if f.CreatedAt == 0 {
+		f.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(f).Error
+}
+
+// upstreamFileKey 上游文件记录的 key，使用密钥的 SHA-256 摘要而非 Key 序号，避免 Key 列表调整后错配
+func upstreamFileKey(channelId int, apiKey string) string {
+	return strconv.Itoa(channelId) + ":" + hex.EncodeToString(common.Sha256Raw([]byte(apiKey)))[:16]
+}
+
+// GetUpstreamFileId 获取文件在指定渠道、指定密钥下的上游文件 ID
+func (f *File) GetUpstreamFileId(channelId int, apiKey string) string {
+	if f.UpstreamIds == nil {
+		return ""
+	}
+	return f.UpstreamIds[upstreamFileKey(channelId, apiKey)]
+}
+
+// SetUpstreamFileId 记录文件在指定渠道、指定密钥下的上游文件 ID
+func (f *File) SetUpstreamFileId(channelId int, apiKey string, upstreamId string) error {
+	if f.UpstreamIds == nil {
+		f.UpstreamIds = FileUpstreamIds{}
+	}
+	f.UpstreamIds[upstreamFileKey(channelId, apiKey)] = upstreamId
+	return DB.Model(&File{}).Where("id = ?", f.Id).Update("upstream_ids", f.UpstreamIds).Error
+}
+
+func (f *File) IsExpired() bool {
+	return f.ExpiresAt > 0 && f.ExpiresAt <= common.GetTimestamp()
+}
+
+// GetUserFileByFileId 获取用户的文件，不存在或不属于该用户时返回 gorm.ErrRecordNotFound
+func GetUserFileByFileId(userId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+	var file File
+	err := DB.Where("file_id = ? AND user_id = ?", fileId, userId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetFileByFileId 不区分用户获取文件，仅用于公开访问的托管内容
+func GetFileByFileId(fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+	var file File
+	err := DB.Where("file_id = ?", fileId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFiles 按 OpenAI 分页语义（after 游标 + limit）列出用户文件
+func GetUserFiles(userId int, purpose string, after string, limit int, ascending bool) ([]*File, error) {
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	order := "id desc"
+	if ascending {
+		order = "id asc"
+	}
+	if after != "" {
+		cursor, err := GetUserFileByFileId(userId, after)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return []*File{}, nil
+			}
+			return nil, err
+		}
+		if ascending {
+			query = query.Where("id > ?", cursor.Id)
+		} else {
+			query = query.Where("id < ?", cursor.Id)
+		}
+	}
+	var files []*File
+	err := query.Order(order).Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetUserFileStorageUsage 统计用户当前占用的文件存储（字节）
+func GetUserFileStorageUsage(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&File{}).Where("user_id = ?", userId).
+		Select("COALESCE(SUM(bytes), 0)").Scan(&total).Error
+	return total, err
+}
+
+// UpdateFileStatus 更新文件状态
+func UpdateFileStatus(id int, status string) error {
+	return DB.Model(&File{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// DeleteFileById 删除文件记录
+func DeleteFileById(id int) error {
+	return DB.Delete(&File{}, id).Error
+}
+
+// GetExpiredFiles 获取已过期的文件，用于后台清理
+func GetExpiredFiles(limit int) ([]*File, error) {
+	var files []*File
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", common.GetTimestamp()).
+		Order("id asc").Limit(limit).Find(&files).Error
+	return files, err
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func insertFile(t *testing.T, userId int, bytes int64, expiresAt int64) *File {
+	t.Helper()
+	file := &File{
+		FileId:    GenerateFileId(),
+		UserId:    userId,
+		Filename:  "data.jsonl",
+		Purpose:   "batch",
+		Bytes:     bytes,
+		Status:    FileStatusProcessed,
+		ExpiresAt: expiresAt,
+	}
+	require.NoError(t, file.Insert())
+	return file
+}
+
+func TestFile_UserIsolationAndUsage(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM files") })
+
+	f1 := insertFile(t, 1, 100, 0)
+	insertFile(t, 1, 50, 0)
+	insertFile(t, 2, 1000, 0)
+
+	_, err := GetUserFileByFileId(2, f1.FileId)
+	assert.Error(t, err, "other users must not see the file")
+
+	got, err := GetUserFileByFileId(1, f1.FileId)
+	require.NoError(t, err)
+	assert.Equal(t, f1.Id, got.Id)
+
+	used, err := GetUserFileStorageUsage(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(150), used)
+}
+
+func TestFile_ListPagination(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM files") })
+
+	f1 := insertFile(t, 1, 1, 0)
+	f2 := insertFile(t, 1, 1, 0)
+	f3 := insertFile(t, 1, 1, 0)
+
+	files, err := GetUserFiles(1, "", "", 2, false)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, f3.FileId, files[0].FileId)
+	assert.Equal(t, f2.FileId, files[1].FileId)
+
+	files, err = GetUserFiles(1, "", f2.FileId, 2, false)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, f1.FileId, files[0].FileId)
+
+	files, err = GetUserFiles(1, "", f1.FileId, 10, true)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestFile_UpstreamIdsAndExpiry(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM files") })
+
+	file := insertFile(t, 1, 1, 0)
+	require.NoError(t, file.SetUpstreamFileId(7, "sk-a", "file-upstream"))
+
+	reloaded, err := GetUserFileByFileId(1, file.FileId)
+	require.NoError(t, err)
+	assert.Equal(t, "file-upstream", reloaded.GetUpstreamFileId(7, "sk-a"))
+	assert.Empty(t, reloaded.GetUpstreamFileId(8, "sk-a"))
+	// 多 Key 渠道的其他 Key 属于不同的上游账号，不能复用
+	assert.Empty(t, reloaded.GetUpstreamFileId(7, "sk-b"))
+
+	expired := insertFile(t, 1, 1, common.GetTimestamp()-10)
+	assert.True(t, expired.IsExpired())
+	files, err := GetExpiredFiles(10)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, expired.Id, files[0].Id)
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLedgerUserQuotaReconcile(t *testing.T) {
+	truncateTables(t)
+
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "alice", Password: "password123", AffCode: "a1", Quota: 100}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "bob", Password: "password123", AffCode: "b1"}).Error)
+
+	ref := LedgerRef{Reason: LedgerReasonTopUp, RefId: "t1", IdempotencyKey: "topup:t1"}
+	require.NoError(t, IncreaseUserQuota(1, 50, true, ref))
+	// 相同幂等键重复入账不改变余额
+	require.NoError(t, IncreaseUserQuota(1, 50, true, ref))
+	require.NoError(t, DecreaseUserQuota(1, 30, NewLedgerRef(LedgerReasonConsume, "req-1")))
+
+	quota, err := GetUserQuota(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, 120, quota)
+	balance, err := GetLedgerBalance(LedgerAccountUser, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 120, balance)
+
+	drifts, err := ReconcileUserLedger()
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// 每张凭证借贷相抵
+	var total int64
+	require.NoError(t, DB.Model(&LedgerEntry{}).Select("COALESCE(SUM(delta), 0)").Scan(&total).Error)
+	assert.Zero(t, total)
+
+	// 批量更新模式下尚未写库的部分计入待写额度
+	common.BatchUpdateEnabled = true
+	t.Cleanup(func() {
+		common.BatchUpdateEnabled = false
+		batchUpdate()
+	})
+	ref = NewEventLedgerRef(LedgerReasonConsume, LedgerEventSettle, "req-2")
+	require.NoError(t, DecreaseUserQuota(2, 20, ref))
+	require.NoError(t, DecreaseUserQuota(2, 20, ref))
+	drifts, err = ReconcileUserLedger()
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+	// 凭证随批量更新写库，不在请求路径上同步记账
+	balance, err = GetLedgerBalance(LedgerAccountUser, 2)
+	require.NoError(t, err)
+	assert.Zero(t, balance)
+	batchUpdate()
+	balance, err = GetLedgerBalance(LedgerAccountUser, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, -20, balance)
+	quota, err = GetUserQuota(2, true)
+	require.NoError(t, err)
+	assert.Equal(t, -20, quota, "balance is written with its journal")
+	// 已写库的事件再次提交时不进入暂存，也不变更缓存
+	require.NoError(t, DecreaseUserQuota(2, 20, ref))
+	ledgerBatchLock.Lock()
+	assert.Empty(t, ledgerBatchPending)
+	ledgerBatchLock.Unlock()
+
+	// 预检之后才由其他节点写库的事件，写库时不计入余额并撤回缓存
+	racedRef := NewEventLedgerRef(LedgerReasonConsume, LedgerEventSettle, "req-3")
+	racedPosting := LedgerPosting{AccountType: LedgerAccountUser, AccountId: 2, Delta: -5}
+	undone := false
+	added, err := addLedgerBatchRecord(racedRef, racedPosting, func() { undone = true })
+	require.NoError(t, err)
+	require.True(t, added)
+	require.NoError(t, DB.Transaction(func(tx *gorm.DB) error {
+		if err := PostLedger(tx, racedRef, racedPosting); err != nil {
+			return err
+		}
+		return applyLedgerBalance(tx, LedgerAccountUser, 2, -5)
+	}))
+	batchUpdate()
+	assert.True(t, undone)
+	quota, err = GetUserQuota(2, true)
+	require.NoError(t, err)
+	assert.Equal(t, -25, quota)
+	drifts, err = ReconcileUserLedger()
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// 绕过账本直接修改余额会被标记
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", 1).Update("quota", 200).Error)
+	drifts, err = ReconcileUserLedger()
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, LedgerDrift{UserId: 1, Username: "alice", CachedQuota: 200, LedgerQuota: 120, Drift: 80}, drifts[0])
+}
+
+func TestLedgerSeedOpeningBalances(t *testing.T) {
+	truncateTables(t)
+
+	// 启用账本前的存量数据没有分录
+	require.NoError(t, DB.Exec("INSERT INTO users (id, username, password, aff_code, quota) VALUES (3, 'carol', 'password123', 'c1', 500)").Error)
+	require.NoError(t, DB.Exec("INSERT INTO tokens (id, user_id, `key`, name, remain_quota) VALUES (7, 3, 'k7', 'ci', 40)").Error)
+
+	t.Cleanup(func() { DB.Exec("DELETE FROM options") })
+
+	require.NoError(t, seedLedgerOpeningBalancesOnce())
+
+	balance, err := GetLedgerBalance(LedgerAccountUser, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 500, balance)
+	balance, err = GetLedgerBalance(LedgerAccountToken, 7)
+	require.NoError(t, err)
+	assert.EqualValues(t, 40, balance)
+
+	require.NoError(t, DecreaseTokenQuota(7, "k7", 15, NewLedgerRef(LedgerReasonConsume, "req-3")))
+	entries, total, err := GetLedgerEntries(LedgerAccountToken, 7, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	require.Len(t, entries, 2)
+	assert.EqualValues(t, -15, entries[0].Delta)
+	assert.Equal(t, LedgerReasonConsume, entries[0].Reason)
+	assert.Equal(t, "req-3", entries[0].RefId)
+	assert.Equal(t, LedgerReasonOpening, entries[1].Reason)
+
+	// 迁移记录写入后不再补记，绕过账本写入的余额作为差异报告
+	require.NoError(t, DB.Exec("INSERT INTO users (id, username, password, aff_code, quota) VALUES (4, 'dave', 'password123', 'd1', 300)").Error)
+	require.NoError(t, seedLedgerOpeningBalancesOnce())
+	balance, err = GetLedgerBalance(LedgerAccountUser, 4)
+	require.NoError(t, err)
+	assert.Zero(t, balance)
+	drifts, err := ReconcileUserLedger()
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, LedgerDrift{UserId: 4, Username: "dave", CachedQuota: 300, Drift: 300, Unledgered: true}, drifts[0])
+}
+
+func TestPostLedgerDuplicateKeepsTransaction(t *testing.T) {
+	truncateTables(t)
+
+	ref := LedgerRef{Reason: LedgerReasonAdjustment, IdempotencyKey: "adjustment:dup"}
+	posting := LedgerPosting{AccountType: LedgerAccountUser, AccountId: 5, Delta: 10}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, PostLedger(tx, ref, posting))
+		assert.ErrorIs(t, PostLedger(tx, ref, posting), ErrLedgerDuplicate)
+		// 幂等键冲突后事务仍可继续使用
+		return PostLedger(tx, NewLedgerRef(LedgerReasonAdjustment, ""), posting)
+	})
+	require.NoError(t, err)
+
+	balance, err := GetLedgerBalance(LedgerAccountUser, 5)
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, balance)
+}
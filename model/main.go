@@ -276,6 +276,15 @@ func migrateDB() error {
 		&SubscriptionPreConsumeRecord{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
+		&File{},
+		&Batch{},
+		&TaskWebhook{},
+		&Organization{},
+		&OrganizationMember{},
+		&Budget{},
+		&Statement{},
+		&LedgerJournal{},
+		&LedgerEntry{},
 	)
 	if err != nil {
 		return err
@@ -289,7 +298,7 @@ func migrateDB() error {
 			return err
 		}
 	}
-	return nil
+	return seedLedgerOpeningBalancesOnce()
 }
 
 func migrateDBFast() error {
@@ -324,6 +333,15 @@ func migrateDBFast() error {
 		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
 		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
 		{&UserOAuthBinding{}, "UserOAuthBinding"},
+		{&File{}, "File"},
+		{&Batch{}, "Batch"},
+		{&TaskWebhook{}, "TaskWebhook"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&Budget{}, "Budget"},
+		{&Statement{}, "Statement"},
+		{&LedgerJournal{}, "LedgerJournal"},
+		{&LedgerEntry{}, "LedgerEntry"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -357,6 +375,9 @@ func migrateDBFast() error {
 			return err
 		}
 	}
+	if err := seedLedgerOpeningBalancesOnce(); err != nil {
+		return err
+	}
 	common.SysLog("database migrated")
 	return nil
 }
@@ -23,6 +23,11 @@ type Midjourney struct {
 	Quota       int    `json:"quota"`
 	Buttons     string `json:"buttons"`
 	Properties  string `json:"properties"`
+	// 计费来源，失败退款时原路退回；旧数据为空，按钱包处理
+	BillingSource  string `json:"-" gorm:"type:varchar(20)"`
+	SubscriptionId int    `json:"-"`
+	OrgId          int    `json:"-"`
+	TokenId        int    `json:"-"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -0,0 +1,461 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 组织成员角色
+const (
+	OrgRoleOwner         = "owner"          // 所有者：全部权限，可删除组织
+	OrgRoleAdmin         = "admin"          // 管理员：管理成员与组织令牌，查看用量
+	OrgRoleMember        = "member"         // 成员：使用组织钱包创建令牌
+	OrgRoleBillingViewer = "billing_viewer" // 账单查看者：仅查看余额与用量，不能消费
+)
+
+const (
+	OrgStatusEnabled  = 1
+	OrgStatusDisabled = 2
+)
+
+var (
+	ErrOrgNotFound            = errors.New("organization not found")
+	ErrOrgDisabled            = errors.New("organization is disabled")
+	ErrOrgNotMember           = errors.New("user is not a member of the organization")
+	ErrOrgPermissionDenied    = errors.New("organization permission denied")
+	ErrOrgQuotaInsufficient   = errors.New("organization quota insufficient")
+	ErrOrgMemberQuotaExceeded = errors.New("organization member spending limit exceeded")
+	ErrOrgMemberExists        = errors.New("user is already a member of the organization")
+	ErrUserQuotaInsufficient  = errors.New("user quota insufficient")
+)
+
+// Organization 组织（团队），成员共享组织钱包额度
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员累计消费上限（0 表示不限），UsedQuota 为成员累计消费
+type OrganizationMember struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id" gorm:"uniqueIndex:idx_org_member_user,priority:1"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_org_member_user,priority:2;index"`
+	Role        string `json:"role" gorm:"type:varchar(32)"`
+	QuotaLimit  int    `json:"quota_limit" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	Username    string `json:"username" gorm:"-"`
+}
+
+// UserOrganization 用户所属组织及其角色
+type UserOrganization struct {
+	Organization
+	Role            string `json:"role"`
+	MemberLimit     int    `json:"member_quota_limit"`
+	MemberUsedQuota int    `json:"member_used_quota"`
+}
+
+// OrgUsageItem 组织用量报表行，按成员与模型聚合
+type OrgUsageItem struct {
+	UserId           int    `json:"user_id"`
+	Username         string `json:"username"`
+	ModelName        string `json:"model_name"`
+	Quota            int    `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Count            int    `json:"count"`
+}
+
+func IsValidOrgRole(role string) bool {
+	switch role {
+	case OrgRoleOwner, OrgRoleAdmin, OrgRoleMember, OrgRoleBillingViewer:
+		return true
+	}
+	return false
+}
+
+// OrgRoleCanManage 是否可以管理成员、组织令牌与组织信息
+func OrgRoleCanManage(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+// OrgRoleCanViewBilling 是否可以查看组织用量报表与全部组织令牌
+func OrgRoleCanViewBilling(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleBillingViewer
+}
+
+// OrgRoleCanSpend 是否可以使用组织钱包消费
+func OrgRoleCanSpend(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+func (org *Organization) Insert(ownerId int) error {
+	org.OwnerId = ownerId
+	org.Status = OrgStatusEnabled
+	org.CreatedTime = common.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrgId:       org.Id,
+			UserId:      ownerId,
+			Role:        OrgRoleOwner,
+			CreatedTime: org.CreatedTime,
+		}).Error
+	})
+}
+
+func (org *Organization) Update() error {
+	return DB.Model(org).Select("name", "status").Updates(org).Error
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, ErrOrgNotFound
+	}
+	var org Organization
+	if err := DB.First(&org, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrgNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// GetUserOrganizations 返回用户加入的全部组织
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	var members []OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []*UserOrganization{}, nil
+	}
+	orgIds := make([]int, 0, len(members))
+	for _, m := range members {
+		orgIds = append(orgIds, m.OrgId)
+	}
+	var orgs []Organization
+	if err := DB.Where("id IN ?", orgIds).Order("id desc").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	memberByOrg := make(map[int]OrganizationMember, len(members))
+	for _, m := range members {
+		memberByOrg[m.OrgId] = m
+	}
+	result := make([]*UserOrganization, 0, len(orgs))
+	for _, org := range orgs {
+		m := memberByOrg[org.Id]
+		result = append(result, &UserOrganization{
+			Organization:    org,
+			Role:            m.Role,
+			MemberLimit:     m.QuotaLimit,
+			MemberUsedQuota: m.UsedQuota,
+		})
+	}
+	return result, nil
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := DB.Where("org_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrgNotMember
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("org_id = ?", orgId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+	userIds := make([]int, 0, len(members))
+	for _, m := range members {
+		userIds = append(userIds, m.UserId)
+	}
+	var users []User
+	if err := DB.Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[int]string, len(users))
+	for _, u := range users {
+		usernames[u.Id] = u.Username
+	}
+	for _, m := range members {
+		m.Username = usernames[m.UserId]
+	}
+	return members, nil
+}
+
+func AddOrganizationMember(orgId int, userId int, role string, quotaLimit int) (*OrganizationMember, error) {
+	if _, err := GetOrganizationMember(orgId, userId); err == nil {
+		return nil, ErrOrgMemberExists
+	} else if !errors.Is(err, ErrOrgNotMember) {
+		return nil, err
+	}
+	member := &OrganizationMember{
+		OrgId:       orgId,
+		UserId:      userId,
+		Role:        role,
+		QuotaLimit:  quotaLimit,
+		CreatedTime: common.GetTimestamp(),
+	}
+	if err := DB.Create(member).Error; err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// UpdateOrganizationMember 更新成员角色与消费上限，resetUsed 为 true 时清零成员累计消费
+func UpdateOrganizationMember(member *OrganizationMember, resetUsed bool) error {
+	updates := map[string]interface{}{
+		"role":        member.Role,
+		"quota_limit": member.QuotaLimit,
+	}
+	if resetUsed {
+		updates["used_quota"] = 0
+		member.UsedQuota = 0
+	}
+	return DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(updates).Error
+}
+
+func RemoveOrganizationMember(orgId int, userId int) error {
+	return DB.Where("org_id = ? AND user_id = ?", orgId, userId).Delete(&OrganizationMember{}).Error
+}
+
+// DeleteOrganization 删除组织及其成员与组织令牌，剩余额度退回所有者钱包
+func DeleteOrganization(org *Organization) error {
+	var tokens []Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var current Organization
+		if err := tx.First(&current, "id = ?", org.Id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", org.Id).Find(&tokens).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", org.Id).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", org.Id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&current).Error; err != nil {
+			return err
+		}
+		if current.Quota > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", current.OwnerId).
+				Update("quota", gorm.Expr("quota + ?", current.Quota)).Error; err != nil {
+				return err
+			}
+			org.Quota = current.Quota
+			return PostLedger(tx, NewLedgerRef(LedgerReasonTransfer, strconv.Itoa(current.Id)),
+				LedgerPosting{AccountType: LedgerAccountOrg, AccountId: current.Id, Delta: -int64(current.Quota)},
+				LedgerPosting{AccountType: LedgerAccountUser, AccountId: current.OwnerId, Delta: int64(current.Quota)})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		for _, t := range tokens {
+			_ = cacheDeleteToken(t.Key)
+		}
+		if org.Quota > 0 {
+			_ = invalidateUserCache(org.OwnerId)
+		}
+	}
+	return nil
+}
+
+// TransferUserQuotaToOrganization 将用户钱包额度转入组织钱包
+func TransferUserQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("quota must be greater than 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserQuotaInsufficient
+		}
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).
+			Update("quota", gorm.Expr("quota + ?", quota)).Error; err != nil {
+			return err
+		}
+		return PostLedger(tx, NewLedgerRef(LedgerReasonTransfer, strconv.Itoa(orgId)),
+			LedgerPosting{AccountType: LedgerAccountUser, AccountId: userId, Delta: -int64(quota)},
+			LedgerPosting{AccountType: LedgerAccountOrg, AccountId: orgId, Delta: int64(quota)})
+	})
+	if err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		_ = invalidateUserCache(userId)
+	}
+	return nil
+}
+
+// AdjustOrganizationQuota 管理员直接调整组织钱包余额，delta 可为负数
+func AdjustOrganizationQuota(orgId int, delta int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).
+			Update("quota", gorm.Expr("quota + ?", delta)).Error; err != nil {
+			return err
+		}
+		return PostLedger(tx, NewLedgerRef(LedgerReasonAdjustment, ""),
+			LedgerPosting{AccountType: LedgerAccountOrg, AccountId: orgId, Delta: int64(delta)})
+	})
+}
+
+// CheckOrganizationFunding 检查成员是否可以使用组织钱包预扣 amount 额度
+func CheckOrganizationFunding(orgId int, userId int, amount int) (*Organization, *OrganizationMember, error) {
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if org.Status != OrgStatusEnabled {
+		return nil, nil, ErrOrgDisabled
+	}
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !OrgRoleCanSpend(member.Role) {
+		return nil, nil, ErrOrgPermissionDenied
+	}
+	if org.Quota <= 0 || org.Quota < amount {
+		return org, member, ErrOrgQuotaInsufficient
+	}
+	if member.QuotaLimit > 0 && member.UsedQuota+amount > member.QuotaLimit {
+		return org, member, ErrOrgMemberQuotaExceeded
+	}
+	return org, member, nil
+}
+
+// PreConsumeOrganizationQuota 从组织钱包预扣额度并计入成员消费，余额或成员上限不足时整体失败
+func PreConsumeOrganizationQuota(orgId int, userId int, amount int, ref LedgerRef) error {
+	if amount <= 0 {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrganizationMember{}).
+			Where("org_id = ? AND user_id = ? AND (quota_limit = 0 OR used_quota + ? <= quota_limit)", orgId, userId, amount).
+			Update("used_quota", gorm.Expr("used_quota + ?", amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrgMemberQuotaExceeded
+		}
+		result = tx.Model(&Organization{}).
+			Where("id = ? AND status = ? AND quota >= ?", orgId, OrgStatusEnabled, amount).
+			Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", amount),
+				"used_quota": gorm.Expr("used_quota + ?", amount),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrgQuotaInsufficient
+		}
+		return PostLedger(tx, ref, LedgerPosting{AccountType: LedgerAccountOrg, AccountId: orgId, Delta: -int64(amount)})
+	})
+	return ignoreLedgerDuplicate(err)
+}
+
+// PostConsumeOrganizationQuota 按差额调整组织钱包与成员消费，delta > 0 补扣，delta < 0 退还
+func PostConsumeOrganizationQuota(orgId int, userId int, delta int, ref LedgerRef) error {
+	if delta == 0 {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).
+			Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", delta),
+				"used_quota": gorm.Expr("used_quota + ?", delta),
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&OrganizationMember{}).Where("org_id = ? AND user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", delta)).Error; err != nil {
+			return err
+		}
+		return PostLedger(tx, ref, LedgerPosting{AccountType: LedgerAccountOrg, AccountId: orgId, Delta: -int64(delta)})
+	})
+	return ignoreLedgerDuplicate(err)
+}
+
+// GetOrganizationTokens 返回组织令牌，userId 不为 0 时只返回该成员创建的令牌
+func GetOrganizationTokens(orgId int, userId int) ([]*Token, error) {
+	var tokens []*Token
+	tx := DB.Where("org_id = ?", orgId)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err := tx.Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteOrganizationToken 删除组织令牌，供组织管理员删除成员创建的令牌
+func DeleteOrganizationToken(orgId int, tokenId int) error {
+	var token Token
+	if err := DB.Where("id = ? AND org_id = ?", tokenId, orgId).First(&token).Error; err != nil {
+		return err
+	}
+	return token.Delete()
+}
+
+// GetOrganizationUsage 统计组织令牌在时间范围内的净消费（扣除异步任务退款），按成员与模型聚合
+func GetOrganizationUsage(orgId int, startTimestamp int64, endTimestamp int64) ([]*OrgUsageItem, error) {
+	var items []*OrgUsageItem
+	tx := LOG_DB.Model(&Log{}).
+		Select("user_id, username, model_name, "+
+			"sum(CASE WHEN type = ? THEN -quota ELSE quota END) as quota, "+
+			"sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, "+
+			"sum(CASE WHEN type = ? THEN 1 ELSE 0 END) as count", LogTypeRefund, LogTypeConsume).
+		Where("org_id = ? AND type IN ?", orgId, []int{LogTypeConsume, LogTypeRefund})
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err := tx.Group("user_id, username, model_name").Order("quota desc").Scan(&items).Error
+	return items, err
+}
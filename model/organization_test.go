@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizationFunding(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM organizations")
+		DB.Exec("DELETE FROM organization_members")
+	})
+
+	org := &Organization{Name: "acme"}
+	require.NoError(t, org.Insert(1))
+	require.NoError(t, AdjustOrganizationQuota(org.Id, 1000))
+	_, err := AddOrganizationMember(org.Id, 2, OrgRoleMember, 300)
+	require.NoError(t, err)
+	_, err = AddOrganizationMember(org.Id, 3, OrgRoleBillingViewer, 0)
+	require.NoError(t, err)
+	_, err = AddOrganizationMember(org.Id, 2, OrgRoleAdmin, 0)
+	assert.ErrorIs(t, err, ErrOrgMemberExists)
+
+	_, _, err = CheckOrganizationFunding(org.Id, 3, 10)
+	assert.ErrorIs(t, err, ErrOrgPermissionDenied)
+	_, _, err = CheckOrganizationFunding(org.Id, 4, 10)
+	assert.ErrorIs(t, err, ErrOrgNotMember)
+
+	// 成员上限 300：预扣 200 后再补扣 50，剩余 50
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 2, 200, NewLedgerRef(LedgerReasonConsume, "")))
+	require.NoError(t, PostConsumeOrganizationQuota(org.Id, 2, 50, NewLedgerRef(LedgerReasonConsume, "")))
+	assert.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 2, 100, NewLedgerRef(LedgerReasonConsume, "")), ErrOrgMemberQuotaExceeded)
+	_, _, err = CheckOrganizationFunding(org.Id, 2, 100)
+	assert.ErrorIs(t, err, ErrOrgMemberQuotaExceeded)
+
+	// 所有者不限额，但受组织余额限制
+	assert.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 1, 800, NewLedgerRef(LedgerReasonConsume, "")), ErrOrgQuotaInsufficient)
+	require.NoError(t, PostConsumeOrganizationQuota(org.Id, 2, -50, NewLedgerRef(LedgerReasonConsume, "")))
+
+	current, err := GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 800, current.Quota)
+	assert.Equal(t, 200, current.UsedQuota)
+	balance, err := GetLedgerBalance(LedgerAccountOrg, org.Id)
+	require.NoError(t, err)
+	assert.EqualValues(t, current.Quota, balance)
+	member, err := GetOrganizationMember(org.Id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 200, member.UsedQuota)
+	// 失败的预扣不应计入成员消费
+	owner, err := GetOrganizationMember(org.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, owner.UsedQuota)
+
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 2, Username: "bob", Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 250, OrgId: org.Id, CreatedAt: 100}).Error)
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 2, Username: "bob", Type: LogTypeRefund, ModelName: "gpt-4o", Quota: 50, OrgId: org.Id, CreatedAt: 101}).Error)
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 2, Username: "bob", Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 999, CreatedAt: 102}).Error)
+	items, err := GetOrganizationUsage(org.Id, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, 200, items[0].Quota)
+	assert.Equal(t, 1, items[0].Count)
+}
@@ -144,6 +144,14 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if err != nil {
 			return err
 		}
+		err = PostLedger(tx, LedgerRef{
+			Reason:         LedgerReasonRedemption,
+			RefId:          strconv.Itoa(redemption.Id),
+			IdempotencyKey: fmt.Sprintf("redemption:%d", redemption.Id),
+		}, LedgerPosting{AccountType: LedgerAccountUser, AccountId: userId, Delta: int64(redemption.Quota)})
+		if err != nil {
+			return err
+		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
 		redemption.UsedUserId = userId
@@ -0,0 +1,474 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const StatementPeriodLayout = "2006-01"
+
+var (
+	ErrStatementPeriodInvalid = errors.New("invalid statement period")
+	ErrStatementNotFound      = errors.New("statement not found")
+)
+
+// statementLedgerReasons 计入对账单消费合计的账本事由
+var statementLedgerReasons = []string{LedgerReasonConsume, LedgerReasonTaskAdjust, LedgerReasonRefund, LedgerReasonViolationFee}
+
+// Statement 已结账月份的对账单快照。月份结束后由定时任务写入，之后始终返回同一份内容，
+// 保证结账后的数字不再变化；Checksum 为 Content 的 SHA-256，用于核验
+type Statement struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_statement_owner_period,priority:1"`
+	TokenId     int    `json:"token_id" gorm:"default:0;uniqueIndex:idx_statement_owner_period,priority:2"` // 0 表示用户整体对账单
+	Period      string `json:"period" gorm:"type:varchar(7);uniqueIndex:idx_statement_owner_period,priority:3"`
+	PeriodStart int64  `json:"period_start" gorm:"bigint"`
+	PeriodEnd   int64  `json:"period_end" gorm:"bigint"`
+	Content     string `json:"-" gorm:"type:text"`
+	Checksum    string `json:"checksum" gorm:"type:varchar(64)"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	EmailedTime int64  `json:"emailed_time" gorm:"bigint;default:0"`
+}
+
+type StatementTopUp struct {
+	TradeNo       string  `json:"trade_no"`
+	PaymentMethod string  `json:"payment_method"`
+	Amount        int64   `json:"amount"`
+	Money         float64 `json:"money"`
+	CompleteTime  int64   `json:"complete_time"`
+}
+
+type StatementRedemption struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Quota        int    `json:"quota"`
+	RedeemedTime int64  `json:"redeemed_time"`
+}
+
+type StatementSubscription struct {
+	TradeNo       string  `json:"trade_no"`
+	PlanId        int     `json:"plan_id"`
+	PlanTitle     string  `json:"plan_title"`
+	PaymentMethod string  `json:"payment_method"`
+	Money         float64 `json:"money"`
+	CompleteTime  int64   `json:"complete_time"`
+}
+
+type StatementModelUsage struct {
+	ModelName        string `json:"model_name"`
+	Count            int    `json:"count"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+}
+
+type StatementSummary struct {
+	TopUpMoney        float64 `json:"top_up_money"`
+	TopUpAmount       int64   `json:"top_up_amount"`
+	RedemptionQuota   int     `json:"redemption_quota"`
+	SubscriptionMoney float64 `json:"subscription_money"`
+	RequestCount      int     `json:"request_count"`
+	PromptTokens      int     `json:"prompt_tokens"`
+	CompletionTokens  int     `json:"completion_tokens"`
+	ConsumeQuota      int     `json:"consume_quota"`
+	RefundCount       int     `json:"refund_count"`
+	RefundQuota       int     `json:"refund_quota"`
+	ViolationFeeCount int     `json:"violation_fee_count"`
+	ViolationFeeQuota int     `json:"violation_fee_quota"`
+	NetConsumeQuota   int     `json:"net_consume_quota"` // 账本中的净消费（消费 + 违规扣费 - 退款）
+}
+
+// StatementData 对账单内容。令牌对账单只包含该令牌的消费、退款与违规扣费，
+// 充值、兑换码与订阅属于用户钱包，仅出现在用户对账单中。
+// 额度与笔数均取自额度账本，token 用量来自消费日志，日志关闭或被清理时可能不完整
+type StatementData struct {
+	UserId        int                     `json:"user_id"`
+	Username      string                  `json:"username"`
+	TokenId       int                     `json:"token_id"`
+	TokenName     string                  `json:"token_name,omitempty"`
+	Period        string                  `json:"period"`
+	PeriodStart   int64                   `json:"period_start"`
+	PeriodEnd     int64                   `json:"period_end"`
+	Closed        bool                    `json:"closed"`
+	GeneratedAt   int64                   `json:"generated_at"`
+	Checksum      string                  `json:"checksum,omitempty"`
+	TopUps        []StatementTopUp        `json:"top_ups"`
+	Redemptions   []StatementRedemption   `json:"redemptions"`
+	Subscriptions []StatementSubscription `json:"subscriptions"`
+	Consumption   []StatementModelUsage   `json:"consumption"`
+	Summary       StatementSummary        `json:"summary"`
+}
+
+// ParseStatementPeriod 解析 "2006-01" 格式的月份，返回服务器时区下的起止时间
+func ParseStatementPeriod(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(StatementPeriodLayout, period, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrStatementPeriodInvalid
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// BuildStatementData 从充值、兑换、订阅订单与额度账本实时汇总指定月份的对账单
+func BuildStatementData(userId int, tokenId int, period string) (*StatementData, error) {
+	start, end, err := ParseStatementPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	startUnix, endUnix := start.Unix(), end.Unix()
+	data := &StatementData{
+		UserId:        userId,
+		TokenId:       tokenId,
+		Period:        period,
+		PeriodStart:   startUnix,
+		PeriodEnd:     endUnix,
+		Closed:        !time.Now().Before(end),
+		GeneratedAt:   common.GetTimestamp(),
+		TopUps:        make([]StatementTopUp, 0),
+		Redemptions:   make([]StatementRedemption, 0),
+		Subscriptions: make([]StatementSubscription, 0),
+		Consumption:   make([]StatementModelUsage, 0),
+	}
+	if data.Username, err = GetUsernameById(userId, false); err != nil {
+		return nil, err
+	}
+	if tokenId > 0 {
+		token, err := GetTokenByIds(tokenId, userId)
+		if err != nil {
+			return nil, err
+		}
+		data.TokenName = token.Name
+	} else if err := fillStatementWallet(data, startUnix, endUnix); err != nil {
+		return nil, err
+	}
+	if err := fillStatementLedger(data, startUnix, endUnix); err != nil {
+		return nil, err
+	}
+	if err := fillStatementTokens(data, startUnix, endUnix); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func fillStatementWallet(data *StatementData, start int64, end int64) error {
+	// 订阅订单完成时也会写入一条同单号的充值记录，这里按订单单独列出，避免重复计入充值
+	subscriptionTradeNos := DB.Model(&SubscriptionOrder{}).Select("trade_no").Where("user_id = ?", data.UserId)
+	var topUps []TopUp
+	tx := DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?", data.UserId, common.TopUpStatusSuccess, start, end).
+		Where("trade_no NOT IN (?)", subscriptionTradeNos)
+	if err := tx.Order("complete_time asc").Find(&topUps).Error; err != nil {
+		return err
+	}
+	for _, topUp := range topUps {
+		data.TopUps = append(data.TopUps, StatementTopUp{
+			TradeNo:       topUp.TradeNo,
+			PaymentMethod: topUp.PaymentMethod,
+			Amount:        topUp.Amount,
+			Money:         topUp.Money,
+			CompleteTime:  topUp.CompleteTime,
+		})
+		data.Summary.TopUpAmount += topUp.Amount
+		data.Summary.TopUpMoney += topUp.Money
+	}
+
+	var redemptions []Redemption
+	if err := DB.Unscoped().Where("used_user_id = ? AND redeemed_time >= ? AND redeemed_time < ?", data.UserId, start, end).
+		Order("redeemed_time asc").Find(&redemptions).Error; err != nil {
+		return err
+	}
+	for _, redemption := range redemptions {
+		data.Redemptions = append(data.Redemptions, StatementRedemption{
+			Id:           redemption.Id,
+			Name:         redemption.Name,
+			Quota:        redemption.Quota,
+			RedeemedTime: redemption.RedeemedTime,
+		})
+		data.Summary.RedemptionQuota += redemption.Quota
+	}
+
+	var orders []SubscriptionOrder
+	if err := DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?", data.UserId, common.TopUpStatusSuccess, start, end).
+		Order("complete_time asc").Find(&orders).Error; err != nil {
+		return err
+	}
+	for _, order := range orders {
+		item := StatementSubscription{
+			TradeNo:       order.TradeNo,
+			PlanId:        order.PlanId,
+			PaymentMethod: order.PaymentMethod,
+			Money:         order.Money,
+			CompleteTime:  order.CompleteTime,
+		}
+		if plan, err := GetSubscriptionPlanById(order.PlanId); err == nil {
+			item.PlanTitle = plan.Title
+		}
+		data.Subscriptions = append(data.Subscriptions, item)
+		data.Summary.SubscriptionMoney += order.Money
+	}
+	return nil
+}
+
+// statementLedgerScope 返回对账单所属账户在时间范围内的消费类分录：用户对账单统计钱包与订阅账户，令牌对账单统计令牌账户
+func statementLedgerScope(data *StatementData, start int64, end int64) *gorm.DB {
+	tx := DB.Table("ledger_entries").
+		Joins("JOIN ledger_journals ON ledger_journals.id = ledger_entries.journal_id").
+		Where("ledger_journals.reason IN ?", statementLedgerReasons).
+		Where("ledger_entries.created_at >= ? AND ledger_entries.created_at < ?", start, end)
+	if data.TokenId > 0 {
+		return tx.Where("ledger_entries.account_type = ? AND ledger_entries.account_id = ?", LedgerAccountToken, data.TokenId)
+	}
+	subscriptionIds := DB.Model(&UserSubscription{}).Select("id").Where("user_id = ?", data.UserId)
+	return tx.Where("(ledger_entries.account_type = ? AND ledger_entries.account_id = ?) OR (ledger_entries.account_type = ? AND ledger_entries.account_id IN (?))",
+		LedgerAccountUser, data.UserId, LedgerAccountSubscription, subscriptionIds)
+}
+
+// fillStatementLedger 从额度账本汇总消费、退款与违规扣费，消费按凭证关联的模型分组。
+// 同一请求的预扣、结算等凭证共用业务单号，按单号计为一次；三项合计等于账本净消费
+func fillStatementLedger(data *StatementData, start int64, end int64) error {
+	var rows []struct {
+		Reason    string
+		ModelName string
+		Count     int
+		Delta     int64
+	}
+	err := statementLedgerScope(data, start, end).
+		Select("ledger_journals.reason, ledger_journals.model_name, " +
+			"COUNT(DISTINCT NULLIF(ledger_journals.ref_id, '')) + COUNT(DISTINCT CASE WHEN ledger_journals.ref_id = '' THEN ledger_journals.id END) AS count, " +
+			"COALESCE(SUM(ledger_entries.delta), 0) AS delta").
+		Group("ledger_journals.reason, ledger_journals.model_name").
+		Order("ledger_journals.model_name asc").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	usageIndex := make(map[string]int)
+	for _, row := range rows {
+		switch row.Reason {
+		case LedgerReasonConsume, LedgerReasonTaskAdjust:
+			i, ok := usageIndex[row.ModelName]
+			if !ok {
+				i = len(data.Consumption)
+				usageIndex[row.ModelName] = i
+				data.Consumption = append(data.Consumption, StatementModelUsage{ModelName: row.ModelName})
+			}
+			// 任务差额结算是对已计数请求的调整，只计额度
+			if row.Reason == LedgerReasonConsume {
+				data.Consumption[i].Count += row.Count
+				data.Summary.RequestCount += row.Count
+			}
+			data.Consumption[i].Quota -= int(row.Delta)
+			data.Summary.ConsumeQuota -= int(row.Delta)
+		case LedgerReasonRefund:
+			data.Summary.RefundCount += row.Count
+			data.Summary.RefundQuota += int(row.Delta)
+		case LedgerReasonViolationFee:
+			data.Summary.ViolationFeeCount += row.Count
+			data.Summary.ViolationFeeQuota -= int(row.Delta)
+		}
+	}
+
+	var total int64
+	if err := statementLedgerScope(data, start, end).Select("COALESCE(SUM(ledger_entries.delta), 0)").Scan(&total).Error; err != nil {
+		return err
+	}
+	data.Summary.NetConsumeQuota = int(-total)
+	return nil
+}
+
+// fillStatementTokens 从消费日志补充各模型的 token 用量，仅供参考，不影响额度数字
+func fillStatementTokens(data *StatementData, start int64, end int64) error {
+	if len(data.Consumption) == 0 {
+		return nil
+	}
+	tx := LOG_DB.Model(&Log{}).Where("user_id = ? AND created_at >= ? AND created_at < ? AND type = ?", data.UserId, start, end, LogTypeConsume)
+	if data.TokenId > 0 {
+		tx = tx.Where("token_id = ?", data.TokenId)
+	} else {
+		// 组织令牌由组织钱包支付，不计入个人对账单
+		tx = tx.Where("org_id = ?", 0)
+	}
+	var tokens []struct {
+		ModelName        string
+		PromptTokens     int
+		CompletionTokens int
+	}
+	if err := tx.Select("model_name, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens").
+		Group("model_name").Scan(&tokens).Error; err != nil {
+		return err
+	}
+	byModel := make(map[string]int, len(data.Consumption))
+	for i, usage := range data.Consumption {
+		byModel[usage.ModelName] = i
+	}
+	for _, t := range tokens {
+		i, ok := byModel[t.ModelName]
+		if !ok {
+			continue
+		}
+		data.Consumption[i].PromptTokens += t.PromptTokens
+		data.Consumption[i].CompletionTokens += t.CompletionTokens
+		data.Summary.PromptTokens += t.PromptTokens
+		data.Summary.CompletionTokens += t.CompletionTokens
+	}
+	return nil
+}
+
+// GetStatement 返回指定月份的对账单。已结账月份只读取定时任务生成的快照，没有快照时返回 ErrStatementNotFound；
+// 未结账的当月返回实时预览，不做持久化
+func GetStatement(userId int, tokenId int, period string) (*StatementData, *Statement, error) {
+	_, end, err := ParseStatementPeriod(period)
+	if err != nil {
+		return nil, nil, err
+	}
+	if time.Now().Before(end) {
+		data, err := BuildStatementData(userId, tokenId, period)
+		return data, nil, err
+	}
+	statement, err := getStatementSnapshot(userId, tokenId, period)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrStatementNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := statement.Data()
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, statement, nil
+}
+
+func getStatementSnapshot(userId int, tokenId int, period string) (*Statement, error) {
+	var statement Statement
+	err := DB.Where("user_id = ? AND token_id = ? AND period = ?", userId, tokenId, period).First(&statement).Error
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+type statementOwner struct {
+	UserId  int
+	TokenId int
+}
+
+// statementOwners 返回在时间范围内有账本变动的用户（钱包或订阅）与令牌
+func statementOwners(start int64, end int64) ([]statementOwner, error) {
+	var owners []statementOwner
+	err := DB.Raw(`SELECT DISTINCT account_id AS user_id, 0 AS token_id FROM ledger_entries
+		WHERE account_type = ? AND created_at >= ? AND created_at < ?
+		UNION SELECT DISTINCT user_subscriptions.user_id AS user_id, 0 AS token_id FROM ledger_entries
+		JOIN user_subscriptions ON user_subscriptions.id = ledger_entries.account_id
+		WHERE ledger_entries.account_type = ? AND ledger_entries.created_at >= ? AND ledger_entries.created_at < ?
+		UNION SELECT DISTINCT tokens.user_id AS user_id, tokens.id AS token_id FROM ledger_entries
+		JOIN tokens ON tokens.id = ledger_entries.account_id
+		WHERE ledger_entries.account_type = ? AND ledger_entries.created_at >= ? AND ledger_entries.created_at < ?`,
+		LedgerAccountUser, start, end,
+		LedgerAccountSubscription, start, end,
+		LedgerAccountToken, start, end).Scan(&owners).Error
+	return owners, err
+}
+
+// CloseStatements 为已结束月份内有账本变动的用户与令牌生成结账快照，已生成的跳过，返回新生成的数量
+func CloseStatements(period string) (int, error) {
+	start, end, err := ParseStatementPeriod(period)
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().Before(end) {
+		return 0, fmt.Errorf("statement period %s has not ended", period)
+	}
+	owners, err := statementOwners(start.Unix(), end.Unix())
+	if err != nil {
+		return 0, err
+	}
+	var existing []statementOwner
+	if err := DB.Model(&Statement{}).Select("user_id", "token_id").Where("period = ?", period).Scan(&existing).Error; err != nil {
+		return 0, err
+	}
+	closed := make(map[statementOwner]bool, len(existing))
+	for _, owner := range existing {
+		closed[owner] = true
+	}
+	count := 0
+	for _, owner := range owners {
+		if closed[owner] {
+			continue
+		}
+		if _, err := closeStatement(owner.UserId, owner.TokenId, period); err != nil {
+			common.SysLog(fmt.Sprintf("close statement %s for user %d token %d failed: %s", period, owner.UserId, owner.TokenId, err.Error()))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// closeStatement 生成并写入结账快照；并发生成时以先写入的快照为准
+func closeStatement(userId int, tokenId int, period string) (*Statement, error) {
+	data, err := BuildStatementData(userId, tokenId, period)
+	if err != nil {
+		return nil, err
+	}
+	content, err := common.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	statement := &Statement{
+		UserId:      userId,
+		TokenId:     tokenId,
+		Period:      period,
+		PeriodStart: data.PeriodStart,
+		PeriodEnd:   data.PeriodEnd,
+		Content:     string(content),
+		Checksum:    hex.EncodeToString(sum[:]),
+		CreatedTime: common.GetTimestamp(),
+	}
+	if err := DB.Create(statement).Error; err != nil {
+		if existing, getErr := getStatementSnapshot(userId, tokenId, period); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return statement, nil
+}
+
+// Data 解析快照内容并校验 Checksum
+func (s *Statement) Data() (*StatementData, error) {
+	sum := sha256.Sum256([]byte(s.Content))
+	if hex.EncodeToString(sum[:]) != s.Checksum {
+		return nil, fmt.Errorf("statement %d checksum mismatch", s.Id)
+	}
+	var data StatementData
+	if err := common.Unmarshal([]byte(s.Content), &data); err != nil {
+		return nil, err
+	}
+	data.Checksum = s.Checksum
+	return &data, nil
+}
+
+// GetUserStatements 返回用户已生成的结账快照（不含内容）
+func GetUserStatements(userId int) ([]*Statement, error) {
+	var statements []*Statement
+	err := DB.Omit("content").Where("user_id = ?", userId).Order("period desc, token_id asc").Find(&statements).Error
+	return statements, err
+}
+
+func MarkStatementEmailed(id int) error {
+	return DB.Model(&Statement{}).Where("id = ?", id).Update("emailed_time", common.GetTimestamp()).Error
+}
+
+// GetStatementEmailUsers 返回开启了对账单邮件的用户（粗筛，调用方需再检查设置）
+func GetStatementEmailUsers() ([]*User, error) {
+	var users []*User
+	err := DB.Select("id", "email", "setting").
+		Where("status = ? AND setting LIKE ?", common.UserStatusEnabled, `%"statement_email_enabled":true%`).
+		Find(&users).Error
+	return users, err
+}
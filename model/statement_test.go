@@ -0,0 +1,128 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementClosedSnapshot(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM statements")
+		DB.Exec("DELETE FROM top_ups")
+		DB.Exec("DELETE FROM redemptions")
+		DB.Exec("DELETE FROM subscription_orders")
+	})
+
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	at := start.Add(36 * time.Hour).Unix()
+	after := start.AddDate(0, 1, 0).Unix()
+
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "alice", Password: "password123", AffCode: "a1"}).Error)
+	require.NoError(t, DB.Create(&Token{Id: 9, UserId: 1, Key: "k9", Name: "ci"}).Error)
+
+	require.NoError(t, DB.Create(&TopUp{UserId: 1, Amount: 10, Money: 72, TradeNo: "t1", Status: common.TopUpStatusSuccess, CompleteTime: at}).Error)
+	require.NoError(t, DB.Create(&TopUp{UserId: 1, Money: 30, TradeNo: "sub1", Status: common.TopUpStatusSuccess, CompleteTime: at}).Error)
+	require.NoError(t, DB.Create(&TopUp{UserId: 1, Amount: 5, Money: 36, TradeNo: "t2", Status: common.TopUpStatusSuccess, CompleteTime: after}).Error)
+	require.NoError(t, DB.Create(&SubscriptionOrder{UserId: 1, PlanId: 3, Money: 30, TradeNo: "sub1", Status: common.TopUpStatusSuccess, CompleteTime: at}).Error)
+	require.NoError(t, DB.Create(&Redemption{Key: "r1", Name: "gift", Quota: 5000, UsedUserId: 1, RedeemedTime: at}).Error)
+
+	logs := []Log{
+		{UserId: 1, TokenId: 9, CreatedAt: at, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 1000, PromptTokens: 100, CompletionTokens: 50},
+		{UserId: 1, TokenId: 9, CreatedAt: at, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 500, PromptTokens: 40, CompletionTokens: 10},
+		{UserId: 1, TokenId: 8, CreatedAt: at, Type: LogTypeConsume, ModelName: "claude", Quota: 300},
+		{UserId: 1, TokenId: 9, CreatedAt: at, Type: LogTypeConsume, ModelName: "grok", Quota: 200, Other: `{"violation_fee":true}`},
+		{UserId: 1, TokenId: 9, CreatedAt: at, Type: LogTypeRefund, ModelName: "gpt-4o", Quota: 100},
+		{UserId: 1, TokenId: 7, OrgId: 2, CreatedAt: at, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 9999},
+	}
+	require.NoError(t, DB.Create(&logs).Error)
+
+	// 额度明细取自账本：同一请求的预扣与结算按单号计为一次，组织钱包与其他月份不计入；日志只补充 token 用量
+	postAt := func(createdAt int64, ref LedgerRef, postings ...LedgerPosting) {
+		t.Helper()
+		require.NoError(t, PostLedger(DB, ref, postings...))
+		require.NoError(t, DB.Model(&LedgerEntry{}).Where("created_at <> ?", at).Update("created_at", createdAt).Error)
+	}
+	wallet := func(delta int64) LedgerPosting {
+		return LedgerPosting{AccountType: LedgerAccountUser, AccountId: 1, Delta: delta}
+	}
+	token9 := func(delta int64) LedgerPosting {
+		return LedgerPosting{AccountType: LedgerAccountToken, AccountId: 9, Delta: delta}
+	}
+	postAt(at, NewEventLedgerRef(LedgerReasonConsume, LedgerEventPostConsume, "req-1").WithModel("gpt-4o"), wallet(-1000), token9(-1000))
+	postAt(at, NewEventLedgerRef(LedgerReasonConsume, LedgerEventPreConsume, "req-2").WithModel("gpt-4o"), wallet(-300), token9(-300))
+	postAt(at, NewEventLedgerRef(LedgerReasonConsume, LedgerEventSettle, "req-2").WithModel("gpt-4o"), wallet(-200), token9(-200))
+	postAt(at, NewEventLedgerRef(LedgerReasonConsume, LedgerEventPostConsume, "req-3").WithModel("claude"), wallet(-300))
+	postAt(at, NewEventLedgerRef(LedgerReasonViolationFee, LedgerEventPostConsume, "req-4").WithModel("grok"), wallet(-200), token9(-200))
+	postAt(at, NewEventLedgerRef(LedgerReasonRefund, LedgerEventRefund, "req-5").WithModel("gpt-4o"), wallet(100), token9(100))
+	postAt(at, NewLedgerRef(LedgerReasonConsume, "").WithModel("gpt-4o"), LedgerPosting{AccountType: LedgerAccountOrg, AccountId: 2, Delta: -9999})
+	require.NoError(t, PostLedger(DB, NewLedgerRef(LedgerReasonConsume, ""), LedgerPosting{AccountType: LedgerAccountUser, AccountId: 1, Delta: -4000}))
+	require.NoError(t, DB.Model(&LedgerEntry{}).Where("created_at <> ?", at).Update("created_at", after).Error)
+
+	// 结账前已结束的月份没有快照
+	_, _, err := GetStatement(1, 0, "2026-03")
+	assert.ErrorIs(t, err, ErrStatementNotFound)
+
+	closed, err := CloseStatements("2026-03")
+	require.NoError(t, err)
+	assert.Equal(t, 2, closed, "one user statement and one token statement")
+	closed, err = CloseStatements("2026-03")
+	require.NoError(t, err)
+	assert.Zero(t, closed)
+
+	data, statement, err := GetStatement(1, 0, "2026-03")
+	require.NoError(t, err)
+	require.NotNil(t, statement)
+	assert.True(t, data.Closed)
+	assert.Equal(t, "alice", data.Username)
+	require.Len(t, data.TopUps, 1, "subscription top-ups and other months are excluded")
+	assert.Equal(t, "t1", data.TopUps[0].TradeNo)
+	assert.Len(t, data.Subscriptions, 1)
+	assert.Equal(t, 5000, data.Summary.RedemptionQuota)
+	require.Len(t, data.Consumption, 2)
+	assert.Equal(t, StatementModelUsage{ModelName: "claude", Count: 1, Quota: 300}, data.Consumption[0])
+	assert.Equal(t, StatementModelUsage{ModelName: "gpt-4o", Count: 2, PromptTokens: 140, CompletionTokens: 60, Quota: 1500}, data.Consumption[1])
+	assert.Equal(t, 3, data.Summary.RequestCount)
+	assert.Equal(t, 1800, data.Summary.ConsumeQuota)
+	assert.Equal(t, 1, data.Summary.ViolationFeeCount)
+	assert.Equal(t, 200, data.Summary.ViolationFeeQuota)
+	assert.Equal(t, 1, data.Summary.RefundCount)
+	assert.Equal(t, 100, data.Summary.RefundQuota)
+	assert.Equal(t, 1800+200-100, data.Summary.NetConsumeQuota)
+	assertStatementBalanced(t, data)
+
+	// 结账后新增或清理日志不会改变快照
+	require.NoError(t, DB.Exec("DELETE FROM logs").Error)
+	again, _, err := GetStatement(1, 0, "2026-03")
+	require.NoError(t, err)
+	assert.Equal(t, data.Summary, again.Summary)
+	assert.Equal(t, statement.Checksum, again.Checksum)
+
+	tokenData, _, err := GetStatement(1, 9, "2026-03")
+	require.NoError(t, err)
+	assert.Equal(t, "ci", tokenData.TokenName)
+	assert.Empty(t, tokenData.TopUps)
+	require.Len(t, tokenData.Consumption, 1)
+	assert.Equal(t, StatementModelUsage{ModelName: "gpt-4o", Count: 2, PromptTokens: 140, CompletionTokens: 60, Quota: 1500}, tokenData.Consumption[0])
+	assert.Equal(t, 1500+200-100, tokenData.Summary.NetConsumeQuota)
+	assertStatementBalanced(t, tokenData)
+
+	_, _, err = GetStatement(1, 0, "2026-13")
+	assert.ErrorIs(t, err, ErrStatementPeriodInvalid)
+}
+
+// assertStatementBalanced 按模型的消费、违规扣费与退款合计应等于账本净消费
+func assertStatementBalanced(t *testing.T, data *StatementData) {
+	t.Helper()
+	consume := 0
+	for _, usage := range data.Consumption {
+		consume += usage.Quota
+	}
+	assert.Equal(t, data.Summary.ConsumeQuota, consume)
+	assert.Equal(t, data.Summary.NetConsumeQuota, consume+data.Summary.ViolationFeeQuota-data.Summary.RefundQuota)
+}
@@ -946,10 +946,15 @@ func maybeResetUserSubscriptionWithPlanTx(tx *gorm.DB, sub *UserSubscription, pl
 		}
 		return nil
 	}
+	usedBefore := sub.AmountUsed
 	sub.AmountUsed = 0
 	sub.LastResetTime = base.Unix()
 	sub.NextResetTime = next
-	return tx.Save(sub).Error
+	if err := tx.Save(sub).Error; err != nil {
+		return err
+	}
+	return PostLedger(tx, NewLedgerRef(LedgerReasonSubscriptionReset, strconv.Itoa(sub.Id)),
+		LedgerPosting{AccountType: LedgerAccountSubscription, AccountId: sub.Id, Delta: usedBefore})
 }
 
 // PreConsumeUserSubscription pre-consumes from any active subscription total quota.
@@ -1041,6 +1046,14 @@ func PreConsumeUserSubscription(requestId string, userId int, modelName string,
 			if err := tx.Save(&sub).Error; err != nil {
 				return err
 			}
+			if err := PostLedger(tx, LedgerRef{
+				Reason:         LedgerReasonConsume,
+				RefId:          requestId,
+				IdempotencyKey: "subscription:" + requestId,
+				ModelName:      modelName,
+			}, LedgerPosting{AccountType: LedgerAccountSubscription, AccountId: sub.Id, Delta: -amount}); err != nil {
+				return err
+			}
 			returnValue.UserSubscriptionId = sub.Id
 			returnValue.PreConsumed = amount
 			returnValue.AmountTotal = sub.AmountTotal
@@ -1074,7 +1087,13 @@ func RefundSubscriptionPreConsume(requestId string) error {
 			record.Status = "refunded"
 			return tx.Save(&record).Error
 		}
-		if err := PostConsumeUserSubscriptionDelta(record.UserSubscriptionId, -record.PreConsumed); err != nil {
+		ref := LedgerRef{
+			Reason:         LedgerReasonRefund,
+			RefId:          requestId,
+			IdempotencyKey: "subscription_refund:" + requestId,
+		}
+		// 幂等键已存在说明额度已退还，只需补记状态
+		if err := PostConsumeUserSubscriptionDelta(record.UserSubscriptionId, -record.PreConsumed, ref); err != nil {
 			return err
 		}
 		record.Status = "refunded"
@@ -1165,14 +1184,14 @@ func GetSubscriptionPlanInfoByUserSubscriptionId(userSubscriptionId int) (*Subsc
 }
 
 // Update subscription used amount by delta (positive consume more, negative refund).
-func PostConsumeUserSubscriptionDelta(userSubscriptionId int, delta int64) error {
+func PostConsumeUserSubscriptionDelta(userSubscriptionId int, delta int64, ref LedgerRef) error {
 	if userSubscriptionId <= 0 {
 		return errors.New("invalid userSubscriptionId")
 	}
 	if delta == 0 {
 		return nil
 	}
-	return DB.Transaction(func(tx *gorm.DB) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
 		var sub UserSubscription
 		if err := tx.Set("gorm:query_option", "FOR UPDATE").
 			Where("id = ?", userSubscriptionId).
@@ -1186,7 +1205,12 @@ func PostConsumeUserSubscriptionDelta(userSubscriptionId int, delta int64) error
 		if sub.AmountTotal > 0 && newUsed > sub.AmountTotal {
 			return fmt.Errorf("subscription used exceeds total, used=%d total=%d", newUsed, sub.AmountTotal)
 		}
+		applied := newUsed - sub.AmountUsed
 		sub.AmountUsed = newUsed
-		return tx.Save(&sub).Error
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		return PostLedger(tx, ref, LedgerPosting{AccountType: LedgerAccountSubscription, AccountId: sub.Id, Delta: -applied})
 	})
+	return ignoreLedgerDuplicate(err)
 }
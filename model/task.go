@@ -63,6 +63,10 @@ type Task struct {
 	// 禁止返回给用户，内部可能包含key等隐私信息
 	PrivateData TaskPrivateData `json:"-" gorm:"column:private_data;type:json"`
 	Data        json.RawMessage `json:"data" gorm:"type:json"`
+	// UpstreamTaskId 上游任务 ID 的索引列，供渠道级上游回调按 ID 查找；为空的旧数据以 TaskID 作为上游 ID
+	UpstreamTaskId string `json:"-" gorm:"type:varchar(191);index"`
+	// NextPollAt 下一次允许轮询的时间，已注册上游回调的任务按兜底间隔推迟，0 表示每轮都轮询
+	NextPollAt int64 `json:"-" gorm:"index;default:0"`
 }
 
 func (t *Task) SetData(data any) {
@@ -78,6 +82,7 @@ type Properties struct {
 	Input             string `json:"input"`
 	UpstreamModelName string `json:"upstream_model_name,omitempty"`
 	OriginModelName   string `json:"origin_model_name,omitempty"`
+	RemixedFrom       string `json:"remixed_from,omitempty"` // remix 的原始任务 ID
 }
 
 func (m *Properties) Scan(val interface{}) error {
@@ -101,10 +106,13 @@ type TaskPrivateData struct {
 	UpstreamTaskID string `json:"upstream_task_id,omitempty"` // 上游真实 task ID
 	ResultURL      string `json:"result_url,omitempty"`       // 任务成功后的结果 URL（视频地址等）
 	// 计费上下文：用于异步退款/差额结算（轮询阶段读取）
-	BillingSource  string              `json:"billing_source,omitempty"`  // "wallet" 或 "subscription"
-	SubscriptionId int                 `json:"subscription_id,omitempty"` // 订阅 ID，用于订阅退款
-	TokenId        int                 `json:"token_id,omitempty"`        // 令牌 ID，用于令牌额度退款
-	BillingContext *TaskBillingContext `json:"billing_context,omitempty"` // 计费参数快照（用于轮询阶段重新计算）
+	BillingSource    string              `json:"billing_source,omitempty"`    // "wallet"、"subscription" 或 "org"
+	SubscriptionId   int                 `json:"subscription_id,omitempty"`   // 订阅 ID，用于订阅退款
+	OrgId            int                 `json:"org_id,omitempty"`            // 组织 ID，用于组织钱包退款
+	TokenId          int                 `json:"token_id,omitempty"`          // 令牌 ID，用于令牌额度退款
+	BillingContext   *TaskBillingContext `json:"billing_context,omitempty"`   // 计费参数快照（用于轮询阶段重新计算）
+	CallbackURL      string              `json:"callback_url,omitempty"`      // 任务到达终态时的回调地址
+	UpstreamCallback bool                `json:"upstream_callback,omitempty"` // 已向上游注册完成回调，轮询仅作为兜底
 }
 
 // TaskBillingContext 记录任务提交时的计费参数，以便轮询阶段可以重新计算额度。
@@ -303,17 +311,35 @@ func GetTimedOutUnfinishedTasks(cutoffUnix int64, limit int) []*Task {
 	return tasks
 }
 
-func GetAllUnFinishSyncTasks(limit int) []*Task {
+// GetAllUnFinishSyncTasks 获取需要轮询的未完成任务，未到兜底轮询时间的回调任务在 SQL 中排除，避免占满 limit
+func GetAllUnFinishSyncTasks(now int64, limit int) []*Task {
 	var tasks []*Task
 	var err error
 	// get all tasks progress is not 100%
-	err = DB.Where("progress != ?", "100%").Where("status != ?", TaskStatusFailure).Where("status != ?", TaskStatusSuccess).Limit(limit).Order("id").Find(&tasks).Error
+	err = DB.Where("progress != ?", "100%").Where("status != ?", TaskStatusFailure).Where("status != ?", TaskStatusSuccess).Where("next_poll_at <= ?", now).Limit(limit).Order("id").Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
 	return tasks
 }
 
+// GetUnfinishedChannelTaskByUpstreamID 按上游任务 ID 查找渠道上未完成的任务（用于渠道级的上游回调）
+func GetUnfinishedChannelTaskByUpstreamID(channelId int, upstreamTaskId string) (*Task, bool, error) {
+	if upstreamTaskId == "" {
+		return nil, false, nil
+	}
+	var task *Task
+	err := DB.Where("channel_id = ?", channelId).
+		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess}).
+		Where(DB.Where("upstream_task_id = ?", upstreamTaskId).Or("(upstream_task_id = ? OR upstream_task_id IS NULL) AND task_id = ?", "", upstreamTaskId)).
+		First(&task).Error
+	exist, err := RecordExist(err)
+	if err != nil {
+		return nil, false, err
+	}
+	return task, exist, nil
+}
+
 func GetByOnlyTaskId(taskId string) (*Task, bool, error) {
 	if taskId == "" {
 		return nil, false, nil
@@ -343,6 +369,34 @@ func GetByTaskId(userId int, taskId string) (*Task, bool, error) {
 	return task, exist, err
 }
 
+// GetUserVideoTasks 按 OpenAI /v1/videos 列表语义分页查询用户的视频任务，after 为游标任务 ID
+func GetUserVideoTasks(userId int, after string, limit int, asc bool) ([]*Task, error) {
+	query := DB.Where("user_id = ? AND platform <> ?", userId, constant.TaskPlatformSuno)
+	if after != "" {
+		var cursor Task
+		if err := DB.Select("id").Where("user_id = ? AND task_id = ?", userId, after).First(&cursor).Error; err != nil {
+			return nil, err
+		}
+		if asc {
+			query = query.Where("id > ?", cursor.ID)
+		} else {
+			query = query.Where("id < ?", cursor.ID)
+		}
+	}
+	order := "id desc"
+	if asc {
+		order = "id asc"
+	}
+	var tasks []*Task
+	err := query.Order(order).Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// DeleteUserTask 删除用户的任务记录
+func DeleteUserTask(userId int, taskId string) error {
+	return DB.Where("user_id = ? AND task_id = ?", userId, taskId).Delete(&Task{}).Error
+}
+
 func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	if len(taskIds) == 0 {
 		return nil, nil
@@ -359,6 +413,7 @@ func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 
 func (Task *Task) Insert() error {
 	var err error
+	Task.UpstreamTaskId = Task.GetUpstreamTaskID()
 	err = DB.Create(Task).Error
 	return err
 }
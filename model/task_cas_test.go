@@ -26,6 +26,7 @@ func TestMain(m *testing.M) {
 	common.RedisEnabled = false
 	common.BatchUpdateEnabled = false
 	common.LogConsumeEnabled = true
+	initCol()
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -33,7 +34,7 @@ func TestMain(m *testing.M) {
 	}
 	sqlDB.SetMaxOpenConns(1)
 
-	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}); err != nil {
+	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}, &Ability{}, &File{}, &Organization{}, &OrganizationMember{}, &Budget{}, &Statement{}, &TopUp{}, &Redemption{}, &SubscriptionOrder{}, &SubscriptionPlan{}, &UserSubscription{}, &LedgerJournal{}, &LedgerEntry{}, &Option{}); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
 
@@ -48,6 +49,9 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM tokens")
 		DB.Exec("DELETE FROM logs")
 		DB.Exec("DELETE FROM channels")
+		DB.Exec("DELETE FROM abilities")
+		DB.Exec("DELETE FROM ledger_journals")
+		DB.Exec("DELETE FROM ledger_entries")
 	})
 }
 
@@ -215,3 +219,28 @@ func TestUpdateWithStatus_ConcurrentWinner(t *testing.T) {
 	}
 	assert.Equal(t, 1, winCount, "exactly one goroutine should win the CAS")
 }
+
+func TestGetUnfinishedChannelTaskByUpstreamID(t *testing.T) {
+	truncateTables(t)
+
+	task := &Task{TaskID: "task_public_1", ChannelId: 7, Status: TaskStatusInProgress, Data: json.RawMessage(`{}`),
+		PrivateData: TaskPrivateData{UpstreamTaskID: "video_upstream_1"}}
+	require.NoError(t, task.Insert())
+	// 旧数据没有 upstream_task_id 列，TaskID 即上游 ID
+	legacy := &Task{TaskID: "video_legacy", ChannelId: 7, Status: TaskStatusInProgress, Data: json.RawMessage(`{}`)}
+	insertTask(t, legacy)
+
+	found, exist, err := GetUnfinishedChannelTaskByUpstreamID(7, "video_upstream_1")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, task.ID, found.ID)
+
+	found, exist, err = GetUnfinishedChannelTaskByUpstreamID(7, "video_legacy")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, legacy.ID, found.ID)
+
+	_, exist, err = GetUnfinishedChannelTaskByUpstreamID(8, "video_upstream_1")
+	require.NoError(t, err)
+	assert.False(t, exist)
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	TaskWebhookStatusPending   = "pending"
+	TaskWebhookStatusDelivered = "delivered"
+	TaskWebhookStatusDead      = "dead" // 超过最大重试次数，作为死信保留
+)
+
+// TaskWebhook 异步任务完成回调的投递记录，同时作为重试队列与死信记录
+type TaskWebhook struct {
+	Id            int64  `json:"id" gorm:"primaryKey"`
+	EventId       string `json:"event_id" gorm:"type:varchar(64);uniqueIndex"`
+	TaskId        string `json:"task_id" gorm:"type:varchar(191);index"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Url           string `json:"url" gorm:"type:varchar(1024)"`
+	Payload       string `json:"payload" gorm:"type:text"`
+	Status        string `json:"status" gorm:"type:varchar(20);index:idx_task_webhook_status_next,priority:1"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `json:"next_attempt_at" gorm:"bigint;index:idx_task_webhook_status_next,priority:2"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt     int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// GenerateTaskWebhookEventId 生成回调事件 ID，接收方可用于幂等去重
+func GenerateTaskWebhookEventId() string {
+	key, _ := common.GenerateRandomCharsKey(24)
+	return "evt_" + key
+}
+
+func (w *TaskWebhook) Insert() error {
+	now := common.GetTimestamp()
+	if w.CreatedAt == 0 {
+		w.CreatedAt = now
+	}
+	w.UpdatedAt = now
+	return DB.Create(w).Error
+}
+
+// GetDueTaskWebhooks 获取已到投递时间的待投递回调
+func GetDueTaskWebhooks(now int64, limit int) ([]*TaskWebhook, error) {
+	var webhooks []*TaskWebhook
+	err := DB.Where("status = ? AND next_attempt_at <= ?", TaskWebhookStatusPending, now).
+		Order("next_attempt_at asc").Limit(limit).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ClaimTaskWebhook 以 next_attempt_at 作为 CAS 条件占用一次投递，避免多节点重复投递
+func ClaimTaskWebhook(id int64, fromNextAttemptAt int64, leaseUntil int64) (bool, error) {
+	result := DB.Model(&TaskWebhook{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", id, TaskWebhookStatusPending, fromNextAttemptAt).
+		Update("next_attempt_at", leaseUntil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateTaskWebhookResult 记录一次投递结果
+func UpdateTaskWebhookResult(id int64, updates map[string]interface{}) error {
+	updates["updated_at"] = common.GetTimestamp()
+	return DB.Model(&TaskWebhook{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// GetTaskWebhooks 管理端分页查询回调记录，status 为空时不过滤
+func GetTaskWebhooks(status string, taskId string, startIdx int, num int) ([]*TaskWebhook, int64, error) {
+	query := DB.Model(&TaskWebhook{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var webhooks []*TaskWebhook
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&webhooks).Error
+	return webhooks, total, err
+}
+
+// RequeueTaskWebhook 将死信重新放回投递队列
+func RequeueTaskWebhook(id int64) (bool, error) {
+	now := common.GetTimestamp()
+	result := DB.Model(&TaskWebhook{}).Where("id = ? AND status = ?", id, TaskWebhookStatusDead).Updates(map[string]interface{}{
+		"status":          TaskWebhookStatusPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"updated_at":      now,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -27,7 +27,14 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry"`                                 // 跨分组重试，仅auto分组有效
+	Hedge              bool           `json:"hedge"`                                             // 对冲请求，需在系统设置中开启
+	ResponseCache      int            `json:"response_cache"`                                    // 响应缓存：0 跟随系统设置，1 启用，2 禁用
+	RpmLimit           int            `json:"rpm_limit" gorm:"default:0"`                        // 每分钟请求数上限，0 表示不限
+	TpmLimit           int            `json:"tpm_limit" gorm:"default:0"`                        // 每分钟 Token 数上限，0 表示不限
+	ConcurrencyLimit   int            `json:"concurrency_limit" gorm:"default:0"`                // 同时进行的请求数上限，0 表示不限
+	CallbackUrl        string         `json:"callback_url" gorm:"type:varchar(1024);default:''"` // 异步任务完成回调的默认地址
+	OrgId              int            `json:"org_id" gorm:"default:0;index"`                     // 组织令牌：消费从组织钱包扣除，0 表示个人令牌
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -270,6 +277,12 @@ func (token *Token) Insert() error {
 	return err
 }
 
+// AfterCreate 在创建令牌的同一事务中记录期初额度
+func (token *Token) AfterCreate(tx *gorm.DB) error {
+	return PostLedger(tx, NewLedgerRef(LedgerReasonOpening, ""),
+		LedgerPosting{AccountType: LedgerAccountToken, AccountId: token.Id, Delta: int64(token.RemainQuota)})
+}
+
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (token *Token) Update() (err error) {
 	defer func() {
@@ -282,8 +295,21 @@ func (token *Token) Update() (err error) {
 			})
 		}
 	}()
-	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var remainQuota int
+		if err := tx.Model(&Token{}).Where("id = ?", token.Id).Select("remain_quota").Scan(&remainQuota).Error; err != nil {
+			return err
+		}
+		err := tx.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
+			"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "hedge", "response_cache",
+			"rpm_limit", "tpm_limit", "concurrency_limit", "callback_url").Updates(token).Error
+		if err != nil {
+			return err
+		}
+		// 用户直接修改剩余额度时按差额记一笔调整
+		return PostLedger(tx, NewLedgerRef(LedgerReasonAdjustment, ""),
+			LedgerPosting{AccountType: LedgerAccountToken, AccountId: token.Id, Delta: int64(token.RemainQuota - remainQuota)})
+	})
 	return err
 }
 
@@ -360,64 +386,66 @@ func DeleteTokenById(id int, userId int) (err error) {
 	return token.Delete()
 }
 
-func IncreaseTokenQuota(tokenId int, key string, quota int) (err error) {
+func IncreaseTokenQuota(tokenId int, key string, quota int, ref LedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
-	if common.RedisEnabled {
-		gopool.Go(func() {
-			err := cacheIncrTokenQuota(key, int64(quota))
-			if err != nil {
-				common.SysLog("failed to increase token quota: " + err.Error())
-			}
-		})
-	}
-	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeTokenQuota, tokenId, quota)
-		return nil
-	}
-	return increaseTokenQuota(tokenId, quota)
+	return changeTokenQuota(tokenId, key, quota, ref)
 }
 
 func increaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
+	return increaseTokenQuotaTx(DB, id, quota)
+}
+
+func increaseTokenQuotaTx(tx *gorm.DB, id int, quota int) error {
+	return tx.Model(&Token{}).Where("id = ?", id).Updates(
 		map[string]interface{}{
 			"remain_quota":  gorm.Expr("remain_quota + ?", quota),
 			"used_quota":    gorm.Expr("used_quota - ?", quota),
 			"accessed_time": common.GetTimestamp(),
 		},
 	).Error
-	return err
 }
 
-func DecreaseTokenQuota(id int, key string, quota int) (err error) {
+func DecreaseTokenQuota(id int, key string, quota int, ref LedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
+	return changeTokenQuota(id, key, -quota, ref)
+}
+
+// changeTokenQuota 先记账再变更令牌剩余额度，批量更新模式下凭证与余额在同一事务中延迟写库
+func changeTokenQuota(id int, key string, delta int, ref LedgerRef) error {
+	posting := LedgerPosting{AccountType: LedgerAccountToken, AccountId: id, Delta: int64(delta)}
+	if common.BatchUpdateEnabled {
+		added, err := addLedgerBatchRecord(ref, posting, func() {
+			if !common.RedisEnabled {
+				return
+			}
+			if err := cacheIncrTokenQuota(key, int64(-delta)); err != nil {
+				common.SysLog("failed to roll back token quota cache: " + err.Error())
+			}
+		})
+		if err != nil || !added {
+			return err
+		}
+	} else {
+		applied, err := postLedgerInTx(ref, posting, func(tx *gorm.DB) error {
+			return increaseTokenQuotaTx(tx, id, delta)
+		})
+		if err != nil || !applied {
+			return err
+		}
+	}
 	if common.RedisEnabled {
 		gopool.Go(func() {
-			err := cacheDecrTokenQuota(key, int64(quota))
+			err := cacheIncrTokenQuota(key, int64(delta))
 			if err != nil {
-				common.SysLog("failed to decrease token quota: " + err.Error())
+				common.SysLog("failed to update token quota cache: " + err.Error())
 			}
 		})
 	}
-	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeTokenQuota, id, -quota)
-		return nil
-	}
-	return decreaseTokenQuota(id, quota)
-}
-
-func decreaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
-			"used_quota":    gorm.Expr("used_quota + ?", quota),
-			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+	return nil
 }
 
 // CountUserTokens returns total number of tokens for the given user, used for pagination
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
@@ -55,6 +56,11 @@ func GetTopUpByTradeNo(tradeNo string) *TopUp {
 	return topUp
 }
 
+// TopUpLedgerRef 同一充值订单只入账一次
+func TopUpLedgerRef(tradeNo string) LedgerRef {
+	return LedgerRef{Reason: LedgerReasonTopUp, RefId: tradeNo, IdempotencyKey: "topup:" + tradeNo}
+}
+
 func Recharge(referenceId string, customerId string) (err error) {
 	if referenceId == "" {
 		return errors.New("未提供支付单号")
@@ -91,7 +97,9 @@ func Recharge(referenceId string, customerId string) (err error) {
 			return err
 		}
 
-		return nil
+		// 数据库写入整数列时四舍五入，账本保持一致
+		return PostLedger(tx, TopUpLedgerRef(topUp.TradeNo),
+			LedgerPosting{AccountType: LedgerAccountUser, AccountId: topUp.UserId, Delta: int64(math.Round(quota))})
 	})
 
 	if err != nil {
@@ -292,6 +300,10 @@ func ManualCompleteTopUp(tradeNo string) error {
 		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
 			return err
 		}
+		if err := PostLedger(tx, TopUpLedgerRef(topUp.TradeNo),
+			LedgerPosting{AccountType: LedgerAccountUser, AccountId: topUp.UserId, Delta: int64(quotaToAdd)}); err != nil {
+			return err
+		}
 
 		userId = topUp.UserId
 		payMoney = topUp.Money
@@ -364,7 +376,8 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 			return err
 		}
 
-		return nil
+		return PostLedger(tx, TopUpLedgerRef(topUp.TradeNo),
+			LedgerPosting{AccountType: LedgerAccountUser, AccountId: topUp.UserId, Delta: quota})
 	})
 
 	if err != nil {
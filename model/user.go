@@ -50,6 +50,9 @@ type User struct {
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	RpmLimit         int            `json:"rpm_limit" gorm:"type:int;default:0"`         // 每分钟请求数上限，0 表示不限
+	TpmLimit         int            `json:"tpm_limit" gorm:"type:int;default:0"`         // 每分钟 Token 数上限，0 表示不限
+	ConcurrencyLimit int            `json:"concurrency_limit" gorm:"type:int;default:0"` // 同时进行的请求数上限，0 表示不限
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -61,6 +64,10 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		RpmLimit:         user.RpmLimit,
+		TpmLimit:         user.TpmLimit,
+		ConcurrencyLimit: user.ConcurrencyLimit,
 	}
 	return cache
 }
@@ -324,8 +331,12 @@ func HardDeleteUserById(id int) error {
 	if id == 0 {
 		return errors.New("id 为空！")
 	}
-	err := DB.Unscoped().Delete(&User{}, "id = ?", id).Error
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&User{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return closeLedgerAccount(tx, LedgerAccountUser, id)
+	})
 }
 
 func inviteUser(inviterId int) (err error) {
@@ -371,6 +382,11 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 	if err := tx.Save(user).Error; err != nil {
 		return err
 	}
+	err = PostLedger(tx, NewLedgerRef(LedgerReasonAffTransfer, ""),
+		LedgerPosting{AccountType: LedgerAccountUser, AccountId: user.Id, Delta: int64(quota)})
+	if err != nil {
+		return err
+	}
 
 	// 提交事务
 	return tx.Commit().Error
@@ -420,7 +436,7 @@ func (user *User) Insert(inviterId int) error {
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true, inviteeLedgerRef(user.Id, inviterId))
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -460,6 +476,21 @@ func (user *User) InsertWithTx(tx *gorm.DB, inviterId int) error {
 	return nil
 }
 
+// AfterCreate 在创建用户的同一事务中记录期初余额
+func (user *User) AfterCreate(tx *gorm.DB) error {
+	return PostLedger(tx, NewLedgerRef(LedgerReasonOpening, ""),
+		LedgerPosting{AccountType: LedgerAccountUser, AccountId: user.Id, Delta: int64(user.Quota)})
+}
+
+// inviteeLedgerRef 邀请奖励每个被邀请用户只记一次
+func inviteeLedgerRef(userId int, inviterId int) LedgerRef {
+	return LedgerRef{
+		Reason:         LedgerReasonInvite,
+		RefId:          strconv.Itoa(inviterId),
+		IdempotencyKey: fmt.Sprintf("invite:%d", userId),
+	}
+}
+
 // FinalizeOAuthUserCreation performs post-transaction tasks for OAuth user creation.
 // This should be called after the transaction commits successfully.
 func (user *User) FinalizeOAuthUserCreation(inviterId int) {
@@ -481,7 +512,7 @@ func (user *User) FinalizeOAuthUserCreation(inviterId int) {
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true, inviteeLedgerRef(user.Id, inviterId))
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -525,13 +556,28 @@ func (user *User) Edit(updatePassword bool) error {
 		"group":        newUser.Group,
 		"quota":        newUser.Quota,
 		"remark":       newUser.Remark,
+
+		"rpm_limit":         newUser.RpmLimit,
+		"tpm_limit":         newUser.TpmLimit,
+		"concurrency_limit": newUser.ConcurrencyLimit,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
 	}
 
-	DB.First(&user, user.Id)
-	if err = DB.Model(user).Updates(updates).Error; err != nil {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, user.Id).Error; err != nil {
+			return err
+		}
+		// 管理员直接修改额度时按差额记一笔调整
+		delta := int64(newUser.Quota - user.Quota)
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return PostLedger(tx, NewLedgerRef(LedgerReasonAdjustment, ""),
+			LedgerPosting{AccountType: LedgerAccountUser, AccountId: user.Id, Delta: delta})
+	})
+	if err != nil {
 		return err
 	}
 
@@ -586,8 +632,12 @@ func (user *User) HardDelete() error {
 	if user.Id == 0 {
 		return errors.New("id 为空！")
 	}
-	err := DB.Unscoped().Delete(user).Error
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(user).Error; err != nil {
+			return err
+		}
+		return closeLedgerAccount(tx, LedgerAccountUser, user.Id)
+	})
 }
 
 // ValidateAndFill check password & user status
@@ -834,6 +884,48 @@ func GetUserGroup(id int, fromDB bool) (group string, err error) {
 }
 
 // GetUserSetting gets setting from Redis first, falls back to DB if needed
+// EnsureUserTaskWebhookSecret 返回用户的任务回调签名密钥：优先使用用户设置的 WebhookSecret，
+// 都未设置时写入 generate 生成的新密钥。以原 setting 为条件更新，并发生成时以先写入者为准
+func EnsureUserTaskWebhookSecret(userId int, generate func() (string, error)) (string, error) {
+	for i := 0; i < 3; i++ {
+		user, err := GetUserById(userId, true)
+		if err != nil {
+			return "", err
+		}
+		setting := user.GetSetting()
+		if setting.WebhookSecret != "" {
+			return setting.WebhookSecret, nil
+		}
+		if setting.TaskWebhookSecret != "" {
+			return setting.TaskWebhookSecret, nil
+		}
+		secret, err := generate()
+		if err != nil {
+			return "", err
+		}
+		setting.TaskWebhookSecret = secret
+		updated := User{}
+		updated.SetSetting(setting)
+		query := DB.Model(&User{}).Where("id = ?", userId)
+		if user.Setting == "" {
+			query = query.Where("setting = ? OR setting IS NULL", "")
+		} else {
+			query = query.Where("setting = ?", user.Setting)
+		}
+		result := query.Update("setting", updated.Setting)
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected > 0 {
+			if err := updateUserSettingCache(userId, updated.Setting); err != nil {
+				common.SysLog("failed to update user setting cache: " + err.Error())
+			}
+			return secret, nil
+		}
+	}
+	return "", errors.New("failed to save task webhook secret")
+}
+
 func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error) {
 	var setting string
 	defer func() {
@@ -871,21 +963,11 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
-func IncreaseUserQuota(id int, quota int, db bool) (err error) {
+func IncreaseUserQuota(id int, quota int, db bool, ref LedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
-	gopool.Go(func() {
-		err := cacheIncrUserQuota(id, int64(quota))
-		if err != nil {
-			common.SysLog("failed to increase user quota: " + err.Error())
-		}
-	})
-	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
-		return nil
-	}
-	return increaseUserQuota(id, quota)
+	return changeUserQuota(id, quota, db, ref)
 }
 
 func increaseUserQuota(id int, quota int) (err error) {
@@ -896,42 +978,53 @@ func increaseUserQuota(id int, quota int) (err error) {
 	return err
 }
 
-func DecreaseUserQuota(id int, quota int) (err error) {
+func DecreaseUserQuota(id int, quota int, ref LedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
-	gopool.Go(func() {
-		err := cacheDecrUserQuota(id, int64(quota))
-		if err != nil {
-			common.SysLog("failed to decrease user quota: " + err.Error())
-		}
-	})
-	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
-		return nil
-	}
-	return decreaseUserQuota(id, quota)
-}
-
-func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+	return changeUserQuota(id, -quota, false, ref)
 }
 
-func DeltaUpdateUserQuota(id int, delta int) (err error) {
+func DeltaUpdateUserQuota(id int, delta int, ref LedgerRef) (err error) {
 	if delta == 0 {
 		return nil
 	}
 	if delta > 0 {
-		return IncreaseUserQuota(id, delta, false)
+		return IncreaseUserQuota(id, delta, false, ref)
 	} else {
-		return DecreaseUserQuota(id, -delta)
+		return DecreaseUserQuota(id, -delta, ref)
 	}
 }
 
+// changeUserQuota 先记账再变更用户额度；批量更新模式下凭证与余额在同一事务中延迟写库
+func changeUserQuota(id int, delta int, db bool, ref LedgerRef) error {
+	posting := LedgerPosting{AccountType: LedgerAccountUser, AccountId: id, Delta: int64(delta)}
+	if !db && common.BatchUpdateEnabled {
+		added, err := addLedgerBatchRecord(ref, posting, func() {
+			if err := cacheIncrUserQuota(id, int64(-delta)); err != nil {
+				common.SysLog("failed to roll back user quota cache: " + err.Error())
+			}
+		})
+		if err != nil || !added {
+			return err
+		}
+	} else {
+		applied, err := postLedgerInTx(ref, posting, func(tx *gorm.DB) error {
+			return tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta)).Error
+		})
+		if err != nil || !applied {
+			return err
+		}
+	}
+	gopool.Go(func() {
+		err := cacheIncrUserQuota(id, int64(delta))
+		if err != nil {
+			common.SysLog("failed to update user quota cache: " + err.Error())
+		}
+	})
+	return nil
+}
+
 //func GetRootUserEmail() (email string) {
 //	DB.Model(&User{}).Where("role = ?", common.RoleRootUser).Select("email").Find(&email)
 //	return email
@@ -1015,6 +1108,15 @@ func GetUsernameById(id int, fromDB bool) (username string, err error) {
 	return username, nil
 }
 
+// GetUserIdByUsername 按用户名查询用户 ID，用户不存在时返回 gorm.ErrRecordNotFound
+func GetUserIdByUsername(username string) (int, error) {
+	var user User
+	if err := DB.Select("id").Where("username = ?", username).First(&user).Error; err != nil {
+		return 0, err
+	}
+	return user.Id, nil
+}
+
 func IsLinuxDOIdAlreadyTaken(linuxDOId string) bool {
 	var user User
 	err := DB.Unscoped().Where("linux_do_id = ?", linuxDOId).First(&user).Error
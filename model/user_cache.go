@@ -7,6 +7,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
 
@@ -22,6 +23,10 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+
+	RpmLimit         int `json:"rpm_limit"`
+	TpmLimit         int `json:"tpm_limit"`
+	ConcurrencyLimit int `json:"concurrency_limit"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -31,6 +36,11 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserRateLimit, ratelimit.Limits{
+		RPM:         user.RpmLimit,
+		TPM:         user.TpmLimit,
+		Concurrency: user.ConcurrencyLimit,
+	})
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -99,15 +109,7 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 	}
 
 	// Create cache object from user data
-	userCache = &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
-	}
+	userCache = user.ToBaseUser()
 
 	return userCache, nil
 }
@@ -50,6 +50,10 @@ func addNewRecord(type_ int, id int, value int) {
 }
 
 func batchUpdate() {
+	// 用户与令牌额度随暂存凭证一起写库
+	flushLedgerBatch()
+	flushBudgetUsage()
+
 	// check if there's any data to update
 	hasData := false
 	for i := 0; i < BatchUpdateTypeCount; i++ {
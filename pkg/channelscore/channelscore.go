@@ -0,0 +1,193 @@
+// Package channelscore 基于真实中继流量维护渠道 + 模型维度的 EWMA 健康统计
+// 统计仅保存在当前实例内存中，多实例部署时各节点独立收敛
+package channelscore
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Observation 一次渠道请求的观测结果
+type Observation struct {
+	Latency time.Duration // 本次尝试的总耗时
+	TTFT    time.Duration // 流式请求首字耗时，非流式或未产生输出时为 0
+	Success bool
+}
+
+// Stats 渠道 + 模型的 EWMA 统计
+type Stats struct {
+	ChannelId   int     `json:"channel_id"`
+	Model       string  `json:"model"`
+	LatencyMs   float64 `json:"latency_ms"`
+	TTFTMs      float64 `json:"ttft_ms"`
+	ErrorRate   float64 `json:"error_rate"`
+	Samples     int64   `json:"samples"`
+	TTFTSamples int64   `json:"ttft_samples"`
+	Failures    int64   `json:"failures"`
+	UpdatedAt   int64   `json:"updated_at"`
+}
+
+// Params 权重调整参数
+type Params struct {
+	Window        time.Duration // 统计窗口，超过窗口未更新的数据影响逐渐衰减
+	MinSamples    int64         // 样本数不足时视为未知渠道，不调整权重
+	LatencyWeight float64       // 延迟因子指数
+	ErrorPenalty  float64       // 错误率因子指数
+	MinFactor     float64       // 调整系数下限
+}
+
+type statsKey struct {
+	channelId int
+	model     string
+}
+
+var (
+	mu    sync.RWMutex
+	stats = make(map[statsKey]*Stats)
+	now   = time.Now
+)
+
+func ewma(prev float64, sample float64, alpha float64, first bool) float64 {
+	if first {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*prev
+}
+
+// Observe 记录一次请求结果，alpha 为 EWMA 平滑系数
+func Observe(channelId int, model string, o Observation, alpha float64) {
+	if channelId <= 0 {
+		return
+	}
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.2
+	}
+	key := statsKey{channelId: channelId, model: model}
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := stats[key]
+	if !ok {
+		s = &Stats{ChannelId: channelId, Model: model}
+		stats[key] = s
+	}
+	first := s.Samples == 0
+	errSample := 0.0
+	if !o.Success {
+		errSample = 1
+		s.Failures++
+	}
+	s.ErrorRate = ewma(s.ErrorRate, errSample, alpha, first)
+	// 失败请求的耗时通常不代表正常响应速度，只计入错误率
+	if o.Success && o.Latency > 0 {
+		s.LatencyMs = ewma(s.LatencyMs, float64(o.Latency.Milliseconds()), alpha, s.LatencyMs == 0)
+	}
+	if o.Success && o.TTFT > 0 {
+		s.TTFTMs = ewma(s.TTFTMs, float64(o.TTFT.Milliseconds()), alpha, s.TTFTSamples == 0)
+		s.TTFTSamples++
+	}
+	s.Samples++
+	s.UpdatedAt = now().Unix()
+}
+
+// Get 获取渠道 + 模型的统计
+func Get(channelId int, model string) (Stats, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := stats[statsKey{channelId: channelId, model: model}]
+	if !ok {
+		return Stats{}, false
+	}
+	return *s, true
+}
+
+// Snapshot 返回全部统计，按渠道 ID 与模型排序；channelId > 0 时只返回该渠道
+func Snapshot(channelId int) []Stats {
+	mu.RLock()
+	result := make([]Stats, 0, len(stats))
+	for _, s := range stats {
+		if channelId > 0 && s.ChannelId != channelId {
+			continue
+		}
+		result = append(result, *s)
+	}
+	mu.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// Reset 清除渠道的统计，channelId <= 0 时清除全部
+func Reset(channelId int) {
+	mu.Lock()
+	defer mu.Unlock()
+	for key := range stats {
+		if channelId <= 0 || key.channelId == channelId {
+			delete(stats, key)
+		}
+	}
+}
+
+// responseMs 用于比较快慢的耗时：有首字耗时优先使用首字耗时，否则使用总耗时
+func (s Stats) responseMs() float64 {
+	if s.TTFTSamples > 0 && s.TTFTMs > 0 {
+		return s.TTFTMs
+	}
+	return s.LatencyMs
+}
+
+// confidence 统计的可信度，随距上次更新的时间指数衰减
+func (s Stats) confidence(p Params) float64 {
+	if p.Window <= 0 {
+		return 1
+	}
+	age := now().Sub(time.Unix(s.UpdatedAt, 0))
+	if age <= 0 {
+		return 1
+	}
+	return math.Exp(-float64(age) / float64(p.Window))
+}
+
+// Factors 计算候选渠道的权重调整系数，返回值与 channelIds 一一对应，取值范围 [MinFactor, 1]
+// 延迟因子以候选中最快的渠道为基准，错误率因子为 (1 - errorRate)^ErrorPenalty；
+// 样本不足的渠道系数为 1，保证新渠道能获得流量
+func Factors(channelIds []int, model string, p Params) []float64 {
+	factors := make([]float64, len(channelIds))
+	known := make([]*Stats, len(channelIds))
+	bestMs := 0.0
+	for i, id := range channelIds {
+		factors[i] = 1
+		s, ok := Get(id, model)
+		if !ok || s.Samples < p.MinSamples {
+			continue
+		}
+		known[i] = &s
+		if ms := s.responseMs(); ms > 0 && (bestMs == 0 || ms < bestMs) {
+			bestMs = ms
+		}
+	}
+	for i, s := range known {
+		if s == nil {
+			continue
+		}
+		conf := s.confidence(p)
+		latencyFactor := 1.0
+		if ms := s.responseMs(); bestMs > 0 && ms > 0 && p.LatencyWeight > 0 {
+			latencyFactor = math.Pow(bestMs/ms, p.LatencyWeight)
+		}
+		errorFactor := 1.0
+		if p.ErrorPenalty > 0 {
+			errorFactor = math.Pow(1-s.ErrorRate, p.ErrorPenalty)
+		}
+		factor := latencyFactor * errorFactor
+		// 数据越旧越接近 1，避免渠道因一段历史故障长期拿不到流量
+		factor = 1 - conf*(1-factor)
+		factors[i] = math.Max(p.MinFactor, math.Min(1, factor))
+	}
+	return factors
+}
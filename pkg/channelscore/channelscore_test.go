@@ -0,0 +1,65 @@
+package channelscore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testParams = Params{
+	Window:        10 * time.Minute,
+	MinSamples:    3,
+	LatencyWeight: 1,
+	ErrorPenalty:  2,
+	MinFactor:     0.05,
+}
+
+func observeN(channelId int, model string, n int, o Observation) {
+	for i := 0; i < n; i++ {
+		Observe(channelId, model, o, 0.5)
+	}
+}
+
+func TestObserveEwma(t *testing.T) {
+	Reset(0)
+	Observe(1, "gpt-4o", Observation{Latency: 1000 * time.Millisecond, Success: true}, 0.5)
+	Observe(1, "gpt-4o", Observation{Latency: 2000 * time.Millisecond, Success: true}, 0.5)
+	Observe(1, "gpt-4o", Observation{Latency: 9000 * time.Millisecond, Success: false}, 0.5)
+
+	s, ok := Get(1, "gpt-4o")
+	require.True(t, ok)
+	assert.InDelta(t, 1500, s.LatencyMs, 0.001, "failed requests must not affect latency")
+	assert.InDelta(t, 0.5, s.ErrorRate, 0.001)
+	assert.EqualValues(t, 3, s.Samples)
+	assert.EqualValues(t, 1, s.Failures)
+}
+
+func TestFactorsPreferHealthyChannels(t *testing.T) {
+	Reset(0)
+	observeN(1, "gpt-4o", 5, Observation{Latency: time.Second, TTFT: 200 * time.Millisecond, Success: true})
+	observeN(2, "gpt-4o", 5, Observation{Latency: time.Second, TTFT: 800 * time.Millisecond, Success: true})
+	observeN(3, "gpt-4o", 5, Observation{Success: false})
+	observeN(4, "gpt-4o", 1, Observation{Success: false})
+
+	factors := Factors([]int{1, 2, 3, 4, 5}, "gpt-4o", testParams)
+	assert.InDelta(t, 1, factors[0], 0.001)
+	assert.InDelta(t, 0.25, factors[1], 0.001, "slower TTFT gets proportionally less weight")
+	assert.InDelta(t, 0.05, factors[2], 0.001, "failing channel keeps the minimum share")
+	assert.InDelta(t, 1, factors[3], 0.001, "too few samples")
+	assert.InDelta(t, 1, factors[4], 0.001, "unknown channel")
+}
+
+func TestFactorsDecayWithAge(t *testing.T) {
+	Reset(0)
+	observeN(1, "claude", 5, Observation{Success: false})
+	fresh := Factors([]int{1}, "claude", testParams)[0]
+
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(30 * time.Minute) }
+	stale := Factors([]int{1}, "claude", testParams)[0]
+
+	assert.Less(t, fresh, stale)
+	assert.Greater(t, stale, 0.9)
+}
@@ -0,0 +1,319 @@
+// Package circuitbreaker 渠道熔断器
+// 熔断粒度为 渠道 + 模型 + Key 下标（单 Key 渠道下标为 0）：
+//   - closed：正常放行，连续失败或窗口错误率超过阈值后进入 open
+//   - open：拒绝选择，熔断时长到期后进入 half_open
+//   - half_open：按探测间隔放行少量真实请求，连续成功后恢复为 closed，失败则以更长时长重新熔断
+//
+// 失败计数保存在各实例本地，熔断状态在启用 Redis 时跨实例共享
+package circuitbreaker
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// Key 熔断器标识
+type Key struct {
+	ChannelId int
+	Model     string
+	KeyIndex  int
+}
+
+// Status 熔断器状态快照
+type Status struct {
+	ChannelId           int    `json:"channel_id"`
+	Model               string `json:"model"`
+	KeyIndex            int    `json:"key_index"`
+	State               State  `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	WindowTotal         int    `json:"window_total"`
+	WindowFailures      int    `json:"window_failures"`
+	Trips               int    `json:"trips"`
+	HalfOpenSuccesses   int    `json:"half_open_successes"`
+	OpenedAt            int64  `json:"opened_at"`
+	OpenUntil           int64  `json:"open_until"`
+	NextProbeAt         int64  `json:"next_probe_at"`
+}
+
+type breaker struct {
+	// 共享状态
+	state             State
+	trips             int
+	halfOpenSuccesses int
+	openedAt          int64
+	openUntil         int64
+	nextProbeAt       int64
+	// 本地计数
+	consecutiveFailures int
+	windowStart         int64
+	windowTotal         int
+	windowFailures      int
+}
+
+var (
+	mu       sync.Mutex
+	breakers = make(map[Key]*breaker)
+	now      = time.Now
+)
+
+func enabled() bool {
+	return operation_setting.GetCircuitBreakerSetting().Enabled
+}
+
+// effectiveState 熔断到期的 open 视为 half_open
+func (b *breaker) effectiveState(ts int64) State {
+	if b.state == StateOpen && ts >= b.openUntil {
+		return StateHalfOpen
+	}
+	if b.state == "" {
+		return StateClosed
+	}
+	return b.state
+}
+
+func (b *breaker) status(k Key, ts int64) Status {
+	return Status{
+		ChannelId:           k.ChannelId,
+		Model:               k.Model,
+		KeyIndex:            k.KeyIndex,
+		State:               b.effectiveState(ts),
+		ConsecutiveFailures: b.consecutiveFailures,
+		WindowTotal:         b.windowTotal,
+		WindowFailures:      b.windowFailures,
+		Trips:               b.trips,
+		HalfOpenSuccesses:   b.halfOpenSuccesses,
+		OpenedAt:            b.openedAt,
+		OpenUntil:           b.openUntil,
+		NextProbeAt:         b.nextProbeAt,
+	}
+}
+
+// Available 是否可以选择该 Key：closed 放行，open 拒绝，half_open 在探测间隔到达后放行
+// 只读判断，用于筛选候选；真正选中时需要调用 TryAcquire 占用探测机会
+func Available(k Key) bool {
+	if !enabled() {
+		return true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	b, ok := breakers[k]
+	if !ok {
+		return true
+	}
+	ts := now().Unix()
+	switch b.effectiveState(ts) {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return ts >= b.nextProbeAt
+	default:
+		return true
+	}
+}
+
+// TryAcquire 在同一次加锁内判断并占用 Key：closed 直接放行，half_open 到达探测时间时占用本次探测机会，
+// 探测间隔内的其他请求返回 false，避免并发请求同时探测
+func TryAcquire(k Key) bool {
+	if !enabled() {
+		return true
+	}
+	setting := operation_setting.GetCircuitBreakerSetting()
+	mu.Lock()
+	b, ok := breakers[k]
+	if !ok {
+		mu.Unlock()
+		return true
+	}
+	ts := now().Unix()
+	switch b.effectiveState(ts) {
+	case StateOpen:
+		mu.Unlock()
+		return false
+	case StateHalfOpen:
+		if ts < b.nextProbeAt {
+			mu.Unlock()
+			return false
+		}
+	default:
+		mu.Unlock()
+		return true
+	}
+	b.nextProbeAt = ts + int64(max(setting.ProbeIntervalSeconds, 1))
+	b.state = StateHalfOpen
+	nextProbeAt := b.nextProbeAt
+	mu.Unlock()
+	persistProbe(k, nextProbeAt)
+	return true
+}
+
+// Record 记录一次请求结果，只应传入渠道侧的成功或失败
+func Record(k Key, success bool) {
+	if !enabled() {
+		return
+	}
+	setting := operation_setting.GetCircuitBreakerSetting()
+	mu.Lock()
+	b, ok := breakers[k]
+	if !ok {
+		b = &breaker{state: StateClosed}
+		breakers[k] = b
+	}
+	ts := now().Unix()
+	if setting.WindowSeconds > 0 && ts-b.windowStart >= int64(setting.WindowSeconds) {
+		b.windowStart = ts
+		b.windowTotal = 0
+		b.windowFailures = 0
+	}
+	b.windowTotal++
+	state := b.effectiveState(ts)
+	var change *transition
+	if success {
+		b.consecutiveFailures = 0
+		if state == StateHalfOpen {
+			b.halfOpenSuccesses++
+			if b.halfOpenSuccesses >= max(setting.HalfOpenSuccesses, 1) {
+				change = b.close(k)
+			} else {
+				change = &transition{key: k, state: StateHalfOpen, snapshot: *b}
+			}
+		}
+	} else {
+		b.consecutiveFailures++
+		b.windowFailures++
+		switch state {
+		case StateHalfOpen:
+			change = b.open(k, ts, setting)
+		case StateClosed:
+			if shouldTrip(b, setting) {
+				change = b.open(k, ts, setting)
+			}
+		}
+	}
+	mu.Unlock()
+	if change != nil {
+		persist(change)
+	}
+}
+
+func shouldTrip(b *breaker, setting *operation_setting.CircuitBreakerSetting) bool {
+	if setting.ConsecutiveFailures > 0 && b.consecutiveFailures >= setting.ConsecutiveFailures {
+		return true
+	}
+	if setting.ErrorRateThreshold > 0 && b.windowTotal >= max(setting.MinRequests, 1) {
+		return float64(b.windowFailures)/float64(b.windowTotal) >= setting.ErrorRateThreshold
+	}
+	return false
+}
+
+// transition 需要同步到共享存储的状态变化
+type transition struct {
+	key      Key
+	state    State
+	snapshot breaker
+}
+
+// open 进入熔断，熔断时长随连续熔断次数翻倍
+func (b *breaker) open(k Key, ts int64, setting *operation_setting.CircuitBreakerSetting) *transition {
+	b.trips++
+	openSeconds := float64(max(setting.OpenSeconds, 1)) * math.Pow(2, float64(b.trips-1))
+	if setting.MaxOpenSeconds > 0 {
+		openSeconds = math.Min(openSeconds, float64(setting.MaxOpenSeconds))
+	}
+	b.state = StateOpen
+	b.openedAt = ts
+	b.openUntil = ts + int64(openSeconds)
+	b.nextProbeAt = b.openUntil
+	b.halfOpenSuccesses = 0
+	b.consecutiveFailures = 0
+	b.windowStart = ts
+	b.windowTotal = 0
+	b.windowFailures = 0
+	metrics.IncCircuitBreakerTransition(k.ChannelId, string(StateOpen))
+	return &transition{key: k, state: StateOpen, snapshot: *b}
+}
+
+// close 恢复正常
+func (b *breaker) close(k Key) *transition {
+	*b = breaker{state: StateClosed}
+	metrics.IncCircuitBreakerTransition(k.ChannelId, string(StateClosed))
+	return &transition{key: k, state: StateClosed}
+}
+
+// Snapshot 返回非 closed 或有失败计数的熔断器状态，channelId > 0 时只返回该渠道
+func Snapshot(channelId int) []Status {
+	mu.Lock()
+	ts := now().Unix()
+	result := make([]Status, 0)
+	for k, b := range breakers {
+		if channelId > 0 && k.ChannelId != channelId {
+			continue
+		}
+		if b.effectiveState(ts) == StateClosed && b.consecutiveFailures == 0 && b.windowFailures == 0 {
+			continue
+		}
+		result = append(result, b.status(k, ts))
+	}
+	mu.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		if result[i].Model != result[j].Model {
+			return result[i].Model < result[j].Model
+		}
+		return result[i].KeyIndex < result[j].KeyIndex
+	})
+	return result
+}
+
+// Reset 手动恢复渠道的全部熔断器，channelId <= 0 时恢复全部
+func Reset(channelId int) {
+	mu.Lock()
+	for k := range breakers {
+		if channelId <= 0 || k.ChannelId == channelId {
+			delete(breakers, k)
+		}
+	}
+	mu.Unlock()
+	resetShared(channelId)
+}
+
+// Prune 清理已不存在的渠道的熔断器，以及 closed 且没有失败记录、统计窗口已过期的空闲熔断器，
+// 避免长期运行后熔断器条目无限增长；exists 为 nil 时只清理空闲熔断器
+func Prune(exists func(channelId int) bool) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	removed := make(map[int]bool)
+	mu.Lock()
+	ts := now().Unix()
+	for k, b := range breakers {
+		if exists != nil && !exists(k.ChannelId) {
+			delete(breakers, k)
+			removed[k.ChannelId] = true
+			continue
+		}
+		if b.effectiveState(ts) != StateClosed || b.consecutiveFailures > 0 {
+			continue
+		}
+		windowExpired := setting.WindowSeconds > 0 && ts-b.windowStart >= int64(setting.WindowSeconds)
+		if b.windowFailures == 0 || windowExpired {
+			delete(breakers, k)
+		}
+	}
+	mu.Unlock()
+	for channelId := range removed {
+		resetShared(channelId)
+	}
+}
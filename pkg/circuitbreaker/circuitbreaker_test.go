@@ -0,0 +1,164 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBreakerTest(t *testing.T) *time.Time {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.ConsecutiveFailures = 3
+	setting.ErrorRateThreshold = 0.5
+	setting.MinRequests = 10
+	setting.WindowSeconds = 60
+	setting.OpenSeconds = 30
+	setting.MaxOpenSeconds = 100
+	setting.ProbeIntervalSeconds = 5
+	setting.HalfOpenSuccesses = 2
+
+	current := time.Unix(1_700_000_000, 0)
+	now = func() time.Time { return current }
+	Reset(0)
+	t.Cleanup(func() {
+		*setting = saved
+		now = time.Now
+		Reset(0)
+	})
+	return &current
+}
+
+func TestOpensOnConsecutiveFailures(t *testing.T) {
+	setupBreakerTest(t)
+	k := Key{ChannelId: 1, Model: "gpt-4o"}
+
+	Record(k, false)
+	Record(k, false)
+	assert.True(t, Available(k))
+	Record(k, false)
+	assert.False(t, Available(k))
+	assert.True(t, Available(Key{ChannelId: 1, Model: "gpt-4o-mini"}), "other models are not affected")
+	assert.True(t, Available(Key{ChannelId: 1, Model: "gpt-4o", KeyIndex: 1}), "other keys are not affected")
+}
+
+func TestOpensOnErrorRate(t *testing.T) {
+	setupBreakerTest(t)
+	k := Key{ChannelId: 2, Model: "claude"}
+
+	for i := 0; i < 5; i++ {
+		Record(k, true)
+		Record(k, false)
+	}
+	assert.False(t, Available(k))
+}
+
+func TestHalfOpenProbingAndRecovery(t *testing.T) {
+	current := setupBreakerTest(t)
+	k := Key{ChannelId: 3, Model: "gemini"}
+	for i := 0; i < 3; i++ {
+		Record(k, false)
+	}
+
+	*current = current.Add(30 * time.Second)
+	assert.True(t, Available(k), "half-open after cool-down")
+	assert.True(t, TryAcquire(k))
+	assert.False(t, Available(k), "only one probe per interval")
+	assert.False(t, TryAcquire(k))
+
+	*current = current.Add(5 * time.Second)
+	assert.True(t, Available(k))
+	Record(k, true)
+	assert.Equal(t, StateHalfOpen, Snapshot(3)[0].State)
+	Record(k, true)
+	assert.Empty(t, Snapshot(3), "closed after enough successful probes")
+}
+
+func TestHalfOpenFailureBacksOff(t *testing.T) {
+	current := setupBreakerTest(t)
+	k := Key{ChannelId: 4, Model: "gpt-4o"}
+	for i := 0; i < 3; i++ {
+		Record(k, false)
+	}
+	*current = current.Add(30 * time.Second)
+	assert.True(t, TryAcquire(k))
+	Record(k, false)
+
+	status := Snapshot(4)[0]
+	assert.Equal(t, StateOpen, status.State)
+	assert.Equal(t, 2, status.Trips)
+	assert.Equal(t, int64(60), status.OpenUntil-status.OpenedAt)
+}
+
+func TestTryAcquireClaimsProbeOnce(t *testing.T) {
+	current := setupBreakerTest(t)
+	k := Key{ChannelId: 6, Model: "gpt-4o"}
+	for i := 0; i < 3; i++ {
+		Record(k, false)
+	}
+	assert.False(t, TryAcquire(k), "open")
+	*current = current.Add(30 * time.Second)
+
+	var wg sync.WaitGroup
+	var acquired atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if TryAcquire(k) {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), acquired.Load(), "concurrent requests share a single probe")
+	assert.True(t, TryAcquire(Key{ChannelId: 6, Model: "gpt-4o-mini"}), "closed keys are always acquired")
+}
+
+func TestPrune(t *testing.T) {
+	current := setupBreakerTest(t)
+	healthy := Key{ChannelId: 8, Model: "gpt-4o"}
+	failing := Key{ChannelId: 8, Model: "claude"}
+	deleted := Key{ChannelId: 9, Model: "gpt-4o"}
+	Record(healthy, true)
+	Record(failing, false)
+	for i := 0; i < 3; i++ {
+		Record(deleted, false)
+	}
+
+	Prune(func(channelId int) bool { return channelId != deleted.ChannelId })
+	mu.Lock()
+	assert.NotContains(t, breakers, healthy, "idle closed breakers are pruned")
+	assert.Contains(t, breakers, failing, "breakers with recent failures are kept")
+	assert.NotContains(t, breakers, deleted, "breakers of deleted channels are pruned")
+	mu.Unlock()
+
+	Record(failing, true)
+	*current = current.Add(61 * time.Second)
+	Prune(nil)
+	mu.Lock()
+	assert.Empty(t, breakers, "failures outside the window no longer keep the breaker")
+	mu.Unlock()
+}
+
+func TestDisabledAlwaysAvailable(t *testing.T) {
+	setupBreakerTest(t)
+	k := Key{ChannelId: 5, Model: "gpt-4o"}
+	for i := 0; i < 3; i++ {
+		Record(k, false)
+	}
+	operation_setting.GetCircuitBreakerSetting().Enabled = false
+	assert.True(t, Available(k))
+}
+
+func TestEncodeKey(t *testing.T) {
+	k := Key{ChannelId: 7, KeyIndex: 2, Model: "org|model:v1"}
+	decoded, ok := decodeKey(encodeKey(k))
+	assert.True(t, ok)
+	assert.Equal(t, k, decoded)
+}
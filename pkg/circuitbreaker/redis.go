@@ -0,0 +1,202 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	redisTrippedSetKey = "channel_cb:tripped"
+	redisStatePrefix   = "channel_cb:state:"
+	syncInterval       = time.Second
+	// half_open 长时间无人探测时自动过期恢复
+	halfOpenTTL = time.Hour
+)
+
+var syncOnce sync.Once
+
+func redisEnabled() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+// encodeKey 模型名可能包含任意字符，放在最后
+func encodeKey(k Key) string {
+	return fmt.Sprintf("%d|%d|%s", k.ChannelId, k.KeyIndex, k.Model)
+}
+
+func decodeKey(s string) (Key, bool) {
+	parts := strings.SplitN(s, "|", 3)
+	if len(parts) != 3 {
+		return Key{}, false
+	}
+	channelId, err1 := strconv.Atoi(parts[0])
+	keyIndex, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return Key{}, false
+	}
+	return Key{ChannelId: channelId, KeyIndex: keyIndex, Model: parts[2]}, true
+}
+
+// persist 将状态变化写入 Redis，closed 时删除共享状态
+func persist(t *transition) {
+	if !redisEnabled() {
+		return
+	}
+	ctx := context.Background()
+	member := encodeKey(t.key)
+	stateKey := redisStatePrefix + member
+	if t.state == StateClosed {
+		pipe := common.RDB.TxPipeline()
+		pipe.Del(ctx, stateKey)
+		pipe.SRem(ctx, redisTrippedSetKey, member)
+		if _, err := pipe.Exec(ctx); err != nil {
+			common.SysError(fmt.Sprintf("circuit breaker: failed to close %s: %v", member, err))
+		}
+		return
+	}
+	s := t.snapshot
+	ttl := time.Duration(s.openUntil-s.openedAt)*time.Second + halfOpenTTL
+	pipe := common.RDB.TxPipeline()
+	pipe.HSet(ctx, stateKey, map[string]interface{}{
+		"state":               string(t.state),
+		"trips":               s.trips,
+		"half_open_successes": s.halfOpenSuccesses,
+		"opened_at":           s.openedAt,
+		"open_until":          s.openUntil,
+		"next_probe_at":       s.nextProbeAt,
+	})
+	pipe.Expire(ctx, stateKey, ttl)
+	pipe.SAdd(ctx, redisTrippedSetKey, member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("circuit breaker: failed to persist %s: %v", member, err))
+	}
+}
+
+// persistProbe 记录下一次允许探测的时间，避免多个实例同时探测
+func persistProbe(k Key, nextProbeAt int64) {
+	if !redisEnabled() {
+		return
+	}
+	ctx := context.Background()
+	stateKey := redisStatePrefix + encodeKey(k)
+	pipe := common.RDB.TxPipeline()
+	pipe.HSet(ctx, stateKey, "state", string(StateHalfOpen), "next_probe_at", nextProbeAt)
+	pipe.Expire(ctx, stateKey, halfOpenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("circuit breaker: failed to persist probe %s: %v", encodeKey(k), err))
+	}
+}
+
+func resetShared(channelId int) {
+	if !redisEnabled() {
+		return
+	}
+	ctx := context.Background()
+	members, err := common.RDB.SMembers(ctx, redisTrippedSetKey).Result()
+	if err != nil {
+		return
+	}
+	for _, member := range members {
+		k, ok := decodeKey(member)
+		if !ok || (channelId > 0 && k.ChannelId != channelId) {
+			continue
+		}
+		persist(&transition{key: k, state: StateClosed})
+	}
+}
+
+// StartSync 启用 Redis 时定期从 Redis 拉取其他实例的熔断状态
+func StartSync() {
+	if !redisEnabled() {
+		return
+	}
+	syncOnce.Do(func() {
+		gopool.Go(func() {
+			ticker := time.NewTicker(syncInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if !enabled() {
+					continue
+				}
+				if err := syncFromRedis(context.Background()); err != nil {
+					common.SysError(fmt.Sprintf("circuit breaker: sync failed: %v", err))
+				}
+			}
+		})
+	})
+}
+
+func syncFromRedis(ctx context.Context) error {
+	members, err := common.RDB.SMembers(ctx, redisTrippedSetKey).Result()
+	if err != nil {
+		return err
+	}
+	pipe := common.RDB.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(members))
+	for i, member := range members {
+		cmds[i] = pipe.HGetAll(ctx, redisStatePrefix+member)
+	}
+	if len(members) > 0 {
+		if _, err = pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return err
+		}
+	}
+
+	shared := make(map[Key]map[string]string, len(members))
+	expired := make([]interface{}, 0)
+	for i, member := range members {
+		k, ok := decodeKey(member)
+		if !ok {
+			expired = append(expired, member)
+			continue
+		}
+		fields := cmds[i].Val()
+		if len(fields) == 0 {
+			expired = append(expired, member)
+			continue
+		}
+		shared[k] = fields
+	}
+	if len(expired) > 0 {
+		common.RDB.SRem(ctx, redisTrippedSetKey, expired...)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for k, fields := range shared {
+		b, ok := breakers[k]
+		if !ok {
+			b = &breaker{}
+			breakers[k] = b
+		}
+		b.state = State(fields["state"])
+		b.trips, _ = strconv.Atoi(fields["trips"])
+		b.halfOpenSuccesses, _ = strconv.Atoi(fields["half_open_successes"])
+		b.openedAt, _ = strconv.ParseInt(fields["opened_at"], 10, 64)
+		b.openUntil, _ = strconv.ParseInt(fields["open_until"], 10, 64)
+		b.nextProbeAt, _ = strconv.ParseInt(fields["next_probe_at"], 10, 64)
+	}
+	// 其他实例已恢复的熔断器；刚在本实例熔断、尚未写入 Redis 的跳过
+	ts := now().Unix()
+	for k, b := range breakers {
+		if _, ok := shared[k]; ok || b.state == StateClosed || b.state == "" || ts-b.openedAt < 2 {
+			continue
+		}
+		b.state = StateClosed
+		b.trips = 0
+		b.halfOpenSuccesses = 0
+		b.openedAt = 0
+		b.openUntil = 0
+		b.nextProbeAt = 0
+	}
+	return nil
+}
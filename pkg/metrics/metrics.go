@@ -0,0 +1,201 @@
+// Package metrics 提供 Prometheus 指标的注册与上报
+// 指标统一以 new_api_ 为前缀，通过 /metrics 端点导出
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "new_api"
+
+var relayLabelNames = []string{"relay_format", "model", "group", "channel_id", "channel_type"}
+var channelLabelNames = []string{"model", "group", "channel_id", "channel_type"}
+
+var (
+	relayRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Total relay requests by final status code.",
+	}, append(relayLabelNames, "status_code"))
+
+	relayErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_errors_total",
+		Help:      "Total failed relay requests by error code.",
+	}, append(relayLabelNames, "error_code"))
+
+	relayRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "End-to-end relay request latency.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, relayLabelNames)
+
+	relayFirstTokenDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_time_to_first_token_seconds",
+		Help:      "Time to first token for streaming relay requests.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, relayLabelNames)
+
+	relayRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Total relay retries after a failed channel attempt.",
+	}, []string{"relay_format", "model", "group"})
+
+	promptTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prompt_tokens_total",
+		Help:      "Total billed prompt tokens.",
+	}, channelLabelNames)
+
+	completionTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "completion_tokens_total",
+		Help:      "Total billed completion tokens.",
+	}, channelLabelNames)
+
+	quotaConsumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_consumed_total",
+		Help:      "Total quota consumed.",
+	}, channelLabelNames)
+
+	channelErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_errors_total",
+		Help:      "Total failed channel attempts, including retried ones.",
+	}, []string{"model", "channel_id", "channel_type", "status_code", "error_code"})
+
+	channelAutoDisabledTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_auto_disabled_total",
+		Help:      "Total channel auto-disable events.",
+	}, []string{"channel_id", "channel_type"})
+
+	circuitBreakerTransitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "Total channel circuit breaker transitions by target state.",
+	}, []string{"channel_id", "state"})
+
+	channelAffinityLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_affinity_cache_lookups_total",
+		Help:      "Channel affinity cache lookups by result (hit or miss).",
+	}, []string{"rule", "group", "result"})
+)
+
+// RelayLabels 中继请求的公共标签
+type RelayLabels struct {
+	RelayFormat string
+	Model       string
+	Group       string
+	ChannelId   int
+	ChannelType int
+}
+
+func (l RelayLabels) values() []string {
+	return []string{l.RelayFormat, l.Model, l.Group, strconv.Itoa(l.ChannelId), strconv.Itoa(l.ChannelType)}
+}
+
+// ObserveRelayRequest 记录一次中继请求的最终结果与耗时，errorCode 为空表示成功
+func ObserveRelayRequest(l RelayLabels, statusCode int, errorCode string, duration time.Duration) {
+	values := l.values()
+	relayRequestsTotal.WithLabelValues(append(values, strconv.Itoa(statusCode))...).Inc()
+	relayRequestDuration.WithLabelValues(values...).Observe(duration.Seconds())
+	if errorCode != "" {
+		relayErrorsTotal.WithLabelValues(append(values, errorCode)...).Inc()
+	}
+}
+
+// ObserveFirstToken 记录流式请求的首字耗时
+func ObserveFirstToken(l RelayLabels, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	relayFirstTokenDuration.WithLabelValues(l.values()...).Observe(duration.Seconds())
+}
+
+// IncRelayRetry 记录一次重试
+func IncRelayRetry(relayFormat string, model string, group string) {
+	relayRetriesTotal.WithLabelValues(relayFormat, model, group).Inc()
+}
+
+// AddUsage 记录计费的 token 数与消耗额度
+func AddUsage(model string, group string, channelId int, channelType int, promptTokens int, completionTokens int, quota int) {
+	values := []string{model, group, strconv.Itoa(channelId), strconv.Itoa(channelType)}
+	if promptTokens > 0 {
+		promptTokensTotal.WithLabelValues(values...).Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		completionTokensTotal.WithLabelValues(values...).Add(float64(completionTokens))
+	}
+	if quota > 0 {
+		quotaConsumedTotal.WithLabelValues(values...).Add(float64(quota))
+	}
+}
+
+// IncChannelError 记录一次渠道请求失败
+func IncChannelError(model string, channelId int, channelType int, statusCode int, errorCode string) {
+	channelErrorsTotal.WithLabelValues(model, strconv.Itoa(channelId), strconv.Itoa(channelType), strconv.Itoa(statusCode), errorCode).Inc()
+}
+
+// IncChannelAutoDisabled 记录一次渠道自动禁用
+func IncChannelAutoDisabled(channelId int, channelType int) {
+	channelAutoDisabledTotal.WithLabelValues(strconv.Itoa(channelId), strconv.Itoa(channelType)).Inc()
+}
+
+// IncCircuitBreakerTransition 记录一次熔断器状态切换
+func IncCircuitBreakerTransition(channelId int, state string) {
+	circuitBreakerTransitionsTotal.WithLabelValues(strconv.Itoa(channelId), state).Inc()
+}
+
+// IncChannelAffinityLookup 记录一次渠道亲和缓存查询
+func IncChannelAffinityLookup(rule string, group string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	channelAffinityLookupsTotal.WithLabelValues(rule, group, result).Inc()
+}
+
+var (
+	gaugeFuncsLock sync.Mutex
+	gaugeFuncs     = make(map[string]func() float64)
+)
+
+// RegisterGaugeFunc 注册按需计算的 gauge，例如活跃连接数
+// 同名 gauge 只向 Prometheus 注册一次，重复调用（如多次初始化路由）时替换取值函数
+func RegisterGaugeFunc(name string, help string, fn func() float64) {
+	gaugeFuncsLock.Lock()
+	defer gaugeFuncsLock.Unlock()
+	_, registered := gaugeFuncs[name]
+	gaugeFuncs[name] = fn
+	if registered {
+		return
+	}
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		gaugeFuncsLock.Lock()
+		current := gaugeFuncs[name]
+		gaugeFuncsLock.Unlock()
+		return current()
+	}))
+}
+
+// Handler 返回 Prometheus 抓取端点
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveRelayRequest(t *testing.T) {
+	labels := RelayLabels{RelayFormat: "openai", Model: "gpt-4o", Group: "default", ChannelId: 3, ChannelType: 1}
+
+	ObserveRelayRequest(labels, 200, "", time.Second)
+	ObserveRelayRequest(labels, 429, "bad_response_status_code", time.Second)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(relayRequestsTotal.WithLabelValues("openai", "gpt-4o", "default", "3", "1", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(relayRequestsTotal.WithLabelValues("openai", "gpt-4o", "default", "3", "1", "429")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(relayErrorsTotal.WithLabelValues("openai", "gpt-4o", "default", "3", "1", "bad_response_status_code")))
+}
+
+func TestAddUsageSkipsZeroValues(t *testing.T) {
+	AddUsage("gpt-4o-mini", "vip", 5, 1, 100, 0, 42)
+
+	assert.Equal(t, 100.0, testutil.ToFloat64(promptTokensTotal.WithLabelValues("gpt-4o-mini", "vip", "5", "1")))
+	assert.Equal(t, 42.0, testutil.ToFloat64(quotaConsumedTotal.WithLabelValues("gpt-4o-mini", "vip", "5", "1")))
+	assert.Equal(t, 0, testutil.CollectAndCount(completionTokensTotal))
+}
+
+func TestRegisterGaugeFuncTwice(t *testing.T) {
+	assert.NotPanics(t, func() {
+		RegisterGaugeFunc("test_gauge", "Test gauge.", func() float64 { return 1 })
+		RegisterGaugeFunc("test_gauge", "Test gauge.", func() float64 { return 2 })
+	})
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	var value float64
+	for _, family := range families {
+		if family.GetName() == "new_api_test_gauge" {
+			value = family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, 2.0, value, "the latest function is used")
+}
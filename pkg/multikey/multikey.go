@@ -0,0 +1,280 @@
+// Package multikey 多 Key 渠道的 Key 级计数
+// 记录每个 Key 的最近使用时间、进行中请求数、当前分钟的请求数与 Token 数，以及上游 429 后的暂停时间
+// 启用 Redis 时计数保存在 Redis 中，多个实例看到的是同一份数据，否则保存在本实例内存中
+package multikey
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// Usage 单个 Key 在当前分钟内的用量
+type Usage struct {
+	Requests int `json:"requests"`
+	Tokens   int `json:"tokens"`
+}
+
+type slot struct {
+	channelId int
+	index     int
+}
+
+type minuteUsage struct {
+	minute int64
+	Usage
+}
+
+// memoryStore 未启用 Redis 时使用的本地计数
+type memoryStore struct {
+	mu       sync.Mutex
+	lastUsed map[slot]int64
+	inFlight map[slot]map[string]int64 // lease id -> 过期时间
+	usage    map[slot]*minuteUsage
+	parked   map[slot]int64
+}
+
+var (
+	local = newMemoryStore()
+	now   = time.Now
+)
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		lastUsed: make(map[slot]int64),
+		inFlight: make(map[slot]map[string]int64),
+		usage:    make(map[slot]*minuteUsage),
+		parked:   make(map[slot]int64),
+	}
+}
+
+func currentMinute() int64 {
+	return now().Unix() / 60
+}
+
+func leaseTTL() time.Duration {
+	seconds := operation_setting.GetMultiKeySetting().InFlightLeaseSeconds
+	if seconds <= 0 {
+		seconds = 600
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// TouchLastUsed 记录 Key 的最近使用时间
+func TouchLastUsed(channelId int, index int) {
+	ts := now().UnixMilli()
+	if redisEnabled() {
+		redisTouchLastUsed(channelId, index, ts)
+		return
+	}
+	local.mu.Lock()
+	local.lastUsed[slot{channelId, index}] = ts
+	local.mu.Unlock()
+}
+
+// LastUsed 返回各 Key 的最近使用时间（毫秒），从未使用的 Key 为 0
+func LastUsed(channelId int, indexes []int) map[int]int64 {
+	if redisEnabled() {
+		return redisLastUsed(channelId, indexes)
+	}
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	result := make(map[int]int64, len(indexes))
+	for _, idx := range indexes {
+		result[idx] = local.lastUsed[slot{channelId, idx}]
+	}
+	return result
+}
+
+// Lease 一次占用的进行中计数
+type Lease struct {
+	channelId int
+	index     int
+	id        string
+	once      sync.Once
+}
+
+// Acquire 将 Key 的进行中请求数加一，请求结束后需要调用 Release
+// 占用超过 InFlightLeaseSeconds 未释放的计数会自动失效
+func Acquire(channelId int, index int) *Lease {
+	lease := &Lease{channelId: channelId, index: index, id: common.GetUUID()}
+	expireAt := now().Add(leaseTTL()).UnixMilli()
+	if redisEnabled() {
+		redisAcquire(lease, expireAt)
+		return lease
+	}
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	s := slot{channelId, index}
+	if local.inFlight[s] == nil {
+		local.inFlight[s] = make(map[string]int64)
+	}
+	local.inFlight[s][lease.id] = expireAt
+	return lease
+}
+
+// Release 释放进行中计数，可重复调用
+func (l *Lease) Release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		if redisEnabled() {
+			redisRelease(l)
+			return
+		}
+		local.mu.Lock()
+		defer local.mu.Unlock()
+		delete(local.inFlight[slot{l.channelId, l.index}], l.id)
+	})
+}
+
+// InFlight 返回各 Key 的进行中请求数
+func InFlight(channelId int, indexes []int) map[int]int {
+	if redisEnabled() {
+		return redisInFlight(channelId, indexes)
+	}
+	ts := now().UnixMilli()
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	result := make(map[int]int, len(indexes))
+	for _, idx := range indexes {
+		leases := local.inFlight[slot{channelId, idx}]
+		for id, expireAt := range leases {
+			if expireAt <= ts {
+				delete(leases, id)
+			}
+		}
+		result[idx] = len(leases)
+	}
+	return result
+}
+
+// AddRequest 记录 Key 在当前分钟的请求数
+func AddRequest(channelId int, index int) {
+	addUsage(channelId, index, Usage{Requests: 1})
+}
+
+// AddTokens 记录 Key 在当前分钟消耗的 Token 数
+func AddTokens(channelId int, index int, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	addUsage(channelId, index, Usage{Tokens: tokens})
+}
+
+func addUsage(channelId int, index int, delta Usage) {
+	minute := currentMinute()
+	if redisEnabled() {
+		redisAddUsage(channelId, index, minute, delta)
+		return
+	}
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	s := slot{channelId, index}
+	usage, ok := local.usage[s]
+	if !ok || usage.minute != minute {
+		usage = &minuteUsage{minute: minute}
+		local.usage[s] = usage
+	}
+	usage.Requests += delta.Requests
+	usage.Tokens += delta.Tokens
+}
+
+// MinuteUsage 返回各 Key 在当前分钟的用量
+func MinuteUsage(channelId int, indexes []int) map[int]Usage {
+	minute := currentMinute()
+	if redisEnabled() {
+		return redisMinuteUsage(channelId, indexes, minute)
+	}
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	result := make(map[int]Usage, len(indexes))
+	for _, idx := range indexes {
+		if usage, ok := local.usage[slot{channelId, idx}]; ok && usage.minute == minute {
+			result[idx] = usage.Usage
+		}
+	}
+	return result
+}
+
+// Park 上游限流后在 d 时间内暂停使用该 Key
+func Park(channelId int, index int, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	until := now().Add(d).UnixMilli()
+	if redisEnabled() {
+		redisPark(channelId, index, until, d)
+		return
+	}
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	s := slot{channelId, index}
+	if until > local.parked[s] {
+		local.parked[s] = until
+	}
+}
+
+// ParkedUntil 返回仍处于暂停中的 Key 及其恢复时间（毫秒）
+func ParkedUntil(channelId int, indexes []int) map[int]int64 {
+	ts := now().UnixMilli()
+	var parked map[int]int64
+	if redisEnabled() {
+		parked = redisParkedUntil(channelId, indexes)
+	} else {
+		local.mu.Lock()
+		parked = make(map[int]int64)
+		for _, idx := range indexes {
+			if until, ok := local.parked[slot{channelId, idx}]; ok {
+				parked[idx] = until
+			}
+		}
+		local.mu.Unlock()
+	}
+	for idx, until := range parked {
+		if until <= ts {
+			delete(parked, idx)
+		}
+	}
+	return parked
+}
+
+// Leases 一次请求内占用的进行中计数，请求结束时统一释放
+type Leases struct {
+	mu    sync.Mutex
+	items []*Lease
+}
+
+func NewLeases() *Leases {
+	return &Leases{}
+}
+
+func (ls *Leases) Add(lease *Lease) {
+	if ls == nil || lease == nil {
+		return
+	}
+	ls.mu.Lock()
+	ls.items = append(ls.items, lease)
+	ls.mu.Unlock()
+}
+
+func (ls *Leases) ReleaseAll() {
+	if ls == nil {
+		return
+	}
+	ls.mu.Lock()
+	items := ls.items
+	ls.items = nil
+	ls.mu.Unlock()
+	for _, lease := range items {
+		lease.Release()
+	}
+}
+
+// Reset 清空本地计数，仅用于测试
+func Reset() {
+	local = newMemoryStore()
+}
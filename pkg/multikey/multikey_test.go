@@ -0,0 +1,64 @@
+package multikey
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMultiKeyTest(t *testing.T) *time.Time {
+	current := time.Unix(1_700_000_000, 0)
+	now = func() time.Time { return current }
+	Reset()
+	t.Cleanup(func() {
+		now = time.Now
+		Reset()
+	})
+	return &current
+}
+
+func TestLeasesReleaseInFlight(t *testing.T) {
+	setupMultiKeyTest(t)
+	leases := NewLeases()
+	leases.Add(Acquire(1, 0))
+	leases.Add(Acquire(1, 0))
+	other := Acquire(1, 1)
+
+	assert.Equal(t, map[int]int{0: 2, 1: 1, 2: 0}, InFlight(1, []int{0, 1, 2}))
+
+	leases.ReleaseAll()
+	other.Release()
+	other.Release()
+	assert.Equal(t, map[int]int{0: 0, 1: 0}, InFlight(1, []int{0, 1}))
+}
+
+func TestInFlightLeaseExpires(t *testing.T) {
+	current := setupMultiKeyTest(t)
+	Acquire(1, 0)
+	*current = current.Add(leaseTTL() + time.Second)
+	assert.Equal(t, 0, InFlight(1, []int{0})[0])
+}
+
+func TestMinuteUsageResetsEachMinute(t *testing.T) {
+	current := setupMultiKeyTest(t)
+	AddRequest(1, 0)
+	AddRequest(1, 0)
+	AddTokens(1, 0, 120)
+	AddTokens(1, 1, 0)
+	assert.Equal(t, map[int]Usage{0: {Requests: 2, Tokens: 120}}, MinuteUsage(1, []int{0, 1}))
+
+	*current = current.Add(time.Minute)
+	assert.Empty(t, MinuteUsage(1, []int{0, 1}))
+}
+
+func TestParkKeepsLatestDeadline(t *testing.T) {
+	current := setupMultiKeyTest(t)
+	Park(1, 0, 30*time.Second)
+	Park(1, 0, 10*time.Second)
+	until := current.Add(30 * time.Second).UnixMilli()
+	assert.Equal(t, map[int]int64{0: until}, ParkedUntil(1, []int{0, 1}))
+
+	*current = current.Add(31 * time.Second)
+	assert.Empty(t, ParkedUntil(1, []int{0}))
+}
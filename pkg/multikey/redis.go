@@ -0,0 +1,208 @@
+package multikey
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	redisKeyPrefix = "multikey:"
+	// 最近使用时间只用于排序，长时间不用的渠道自动清理
+	lastUsedTTL = 24 * time.Hour
+	// 分钟用量保留到下一分钟结束
+	usageTTL = 2 * time.Minute
+)
+
+func redisEnabled() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+func lastUsedKey(channelId int) string {
+	return fmt.Sprintf("%s%d:last_used", redisKeyPrefix, channelId)
+}
+
+func inFlightKey(channelId int, index int) string {
+	return fmt.Sprintf("%s%d:in_flight:%d", redisKeyPrefix, channelId, index)
+}
+
+func usageKey(channelId int, minute int64) string {
+	return fmt.Sprintf("%s%d:usage:%d", redisKeyPrefix, channelId, minute)
+}
+
+func parkedKey(channelId int, index int) string {
+	return fmt.Sprintf("%s%d:parked:%d", redisKeyPrefix, channelId, index)
+}
+
+func redisTouchLastUsed(channelId int, index int, ts int64) {
+	ctx := context.Background()
+	pipe := common.RDB.Pipeline()
+	pipe.HSet(ctx, lastUsedKey(channelId), strconv.Itoa(index), ts)
+	pipe.Expire(ctx, lastUsedKey(channelId), lastUsedTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to touch channel %d key %d: %v", channelId, index, err))
+	}
+}
+
+func redisLastUsed(channelId int, indexes []int) map[int]int64 {
+	result := make(map[int]int64, len(indexes))
+	if len(indexes) == 0 {
+		return result
+	}
+	fields := make([]string, len(indexes))
+	for i, idx := range indexes {
+		fields[i] = strconv.Itoa(idx)
+	}
+	values, err := common.RDB.HMGet(context.Background(), lastUsedKey(channelId), fields...).Result()
+	if err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to get last used of channel %d: %v", channelId, err))
+		return result
+	}
+	for i, value := range values {
+		result[indexes[i]] = parseInt64(value)
+	}
+	return result
+}
+
+// 进行中计数使用有序集合，score 为过期时间，统计时忽略已过期的成员
+func redisAcquire(lease *Lease, expireAt int64) {
+	ctx := context.Background()
+	key := inFlightKey(lease.channelId, lease.index)
+	pipe := common.RDB.Pipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now().UnixMilli(), 10))
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(expireAt), Member: lease.id})
+	pipe.Expire(ctx, key, leaseTTL())
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to acquire channel %d key %d: %v", lease.channelId, lease.index, err))
+	}
+}
+
+func redisRelease(lease *Lease) {
+	err := common.RDB.ZRem(context.Background(), inFlightKey(lease.channelId, lease.index), lease.id).Err()
+	if err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to release channel %d key %d: %v", lease.channelId, lease.index, err))
+	}
+}
+
+func redisInFlight(channelId int, indexes []int) map[int]int {
+	result := make(map[int]int, len(indexes))
+	if len(indexes) == 0 {
+		return result
+	}
+	ctx := context.Background()
+	minScore := strconv.FormatInt(now().UnixMilli(), 10)
+	pipe := common.RDB.Pipeline()
+	cmds := make([]*redis.IntCmd, len(indexes))
+	for i, idx := range indexes {
+		cmds[i] = pipe.ZCount(ctx, inFlightKey(channelId, idx), "("+minScore, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		common.SysError(fmt.Sprintf("multikey: failed to get in-flight of channel %d: %v", channelId, err))
+		return result
+	}
+	for i, idx := range indexes {
+		result[idx] = int(cmds[i].Val())
+	}
+	return result
+}
+
+func usageFields(index int) (string, string) {
+	return fmt.Sprintf("%d:requests", index), fmt.Sprintf("%d:tokens", index)
+}
+
+func redisAddUsage(channelId int, index int, minute int64, delta Usage) {
+	ctx := context.Background()
+	key := usageKey(channelId, minute)
+	requestsField, tokensField := usageFields(index)
+	pipe := common.RDB.Pipeline()
+	if delta.Requests != 0 {
+		pipe.HIncrBy(ctx, key, requestsField, int64(delta.Requests))
+	}
+	if delta.Tokens != 0 {
+		pipe.HIncrBy(ctx, key, tokensField, int64(delta.Tokens))
+	}
+	pipe.Expire(ctx, key, usageTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to add usage of channel %d key %d: %v", channelId, index, err))
+	}
+}
+
+func redisMinuteUsage(channelId int, indexes []int, minute int64) map[int]Usage {
+	result := make(map[int]Usage, len(indexes))
+	if len(indexes) == 0 {
+		return result
+	}
+	fields := make([]string, 0, len(indexes)*2)
+	for _, idx := range indexes {
+		requestsField, tokensField := usageFields(idx)
+		fields = append(fields, requestsField, tokensField)
+	}
+	values, err := common.RDB.HMGet(context.Background(), usageKey(channelId, minute), fields...).Result()
+	if err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to get usage of channel %d: %v", channelId, err))
+		return result
+	}
+	for i, idx := range indexes {
+		usage := Usage{
+			Requests: int(parseInt64(values[i*2])),
+			Tokens:   int(parseInt64(values[i*2+1])),
+		}
+		if usage.Requests > 0 || usage.Tokens > 0 {
+			result[idx] = usage
+		}
+	}
+	return result
+}
+
+func redisPark(channelId int, index int, until int64, d time.Duration) {
+	ctx := context.Background()
+	key := parkedKey(channelId, index)
+	// 已有更晚的暂停时间时保留
+	current := parseInt64Str(common.RDB.Get(ctx, key).Val())
+	if current >= until {
+		return
+	}
+	if err := common.RDB.Set(ctx, key, until, d).Err(); err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to park channel %d key %d: %v", channelId, index, err))
+	}
+}
+
+func redisParkedUntil(channelId int, indexes []int) map[int]int64 {
+	result := make(map[int]int64)
+	if len(indexes) == 0 {
+		return result
+	}
+	keys := make([]string, len(indexes))
+	for i, idx := range indexes {
+		keys[i] = parkedKey(channelId, idx)
+	}
+	values, err := common.RDB.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		common.SysError(fmt.Sprintf("multikey: failed to get parked keys of channel %d: %v", channelId, err))
+		return result
+	}
+	for i, value := range values {
+		if until := parseInt64(value); until > 0 {
+			result[indexes[i]] = until
+		}
+	}
+	return result
+}
+
+func parseInt64(value interface{}) int64 {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	return parseInt64Str(s)
+}
+
+func parseInt64Str(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
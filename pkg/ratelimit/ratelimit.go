@@ -0,0 +1,334 @@
+// Package ratelimit 令牌与用户级的 RPM、TPM 与并发请求数限制
+// RPM 与 TPM 使用 common/limiter 的令牌桶实现，启用 Redis 时多个实例共享同一个桶：
+//   - RPM：每个请求扣减一次
+//   - TPM：请求前按预估 Token 数扣减，响应后按实际用量多退少补
+//   - 并发：请求开始时占用，结束时释放
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+)
+
+type Scope string
+
+const (
+	ScopeUser  Scope = "user"
+	ScopeToken Scope = "token"
+)
+
+const (
+	KindRequests    = "requests"
+	KindTokens      = "tokens"
+	KindConcurrency = "concurrency"
+)
+
+// 令牌桶按秒补充令牌且速率为整数，桶容量与扣减量都放大 60 倍，使每分钟的限额可以精确表示
+const bucketScale = 60
+
+// Limits 限流配置，0 表示不限制
+type Limits struct {
+	RPM         int `json:"rpm"`
+	TPM         int `json:"tpm"`
+	Concurrency int `json:"concurrency"`
+}
+
+func (l Limits) Empty() bool {
+	return l.RPM <= 0 && l.TPM <= 0 && l.Concurrency <= 0
+}
+
+// Subject 受限流约束的对象
+type Subject struct {
+	Scope  Scope
+	Id     int
+	Limits Limits
+}
+
+func (s Subject) bucketKey(kind string) string {
+	return fmt.Sprintf("ratelimit:%s:%d:%s", s.Scope, s.Id, kind)
+}
+
+// Rejection 被拒绝的原因
+type Rejection struct {
+	Subject    Subject
+	Kind       string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (r *Rejection) Error() string {
+	switch r.Kind {
+	case KindRequests:
+		return fmt.Sprintf("rate limit reached for %s: limit %d requests per minute", r.Subject.Scope, r.Limit)
+	case KindTokens:
+		return fmt.Sprintf("rate limit reached for %s: limit %d tokens per minute", r.Subject.Scope, r.Limit)
+	default:
+		return fmt.Sprintf("rate limit reached for %s: limit %d concurrent requests", r.Subject.Scope, r.Limit)
+	}
+}
+
+// bucketStatus 单个令牌桶的剩余额度
+type bucketStatus struct {
+	limit     int
+	remaining int
+	reset     time.Duration
+}
+
+// Lease 一次请求占用的限流额度
+type Lease struct {
+	mu          sync.Mutex
+	subjects    []Subject
+	charged     []int // 各对象已按 TPM 实际扣减的 Token 数，与 subjects 一一对应
+	used        int   // 已上报的实际 Token 数
+	reconciled  bool
+	concurrency []Subject
+	released    bool
+	requests    *bucketStatus
+	tokens      *bucketStatus
+}
+
+var (
+	memoryLimiter    = limiter.NewMemoryLimiter()
+	concurrencyMu    sync.Mutex
+	localConcurrency = make(map[string]int)
+)
+
+// 并发计数在实例异常退出时无法释放，超过该时间无新请求后自动清零
+const concurrencyTTL = 10 * time.Minute
+
+func redisEnabled() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+// take 扣减令牌桶，Redis 异常时放行
+func take(key string, perMinute int, requested int, force bool) limiter.Result {
+	opts := []limiter.Option{
+		limiter.WithCapacity(int64(perMinute) * bucketScale),
+		limiter.WithRate(int64(perMinute)),
+		limiter.WithRequested(int64(requested) * bucketScale),
+	}
+	if force {
+		opts = append(opts, limiter.WithForce())
+	}
+	if !redisEnabled() {
+		return memoryLimiter.Take(key, opts...)
+	}
+	ctx := context.Background()
+	result, err := limiter.New(ctx, common.RDB).Take(ctx, key, opts...)
+	if err != nil {
+		common.SysError("rate limit: " + err.Error())
+		return limiter.Result{Allowed: true, Remaining: int64(perMinute) * bucketScale}
+	}
+	return result
+}
+
+func newBucketStatus(perMinute int, result limiter.Result) *bucketStatus {
+	remaining := result.Remaining
+	capacity := int64(perMinute) * bucketScale
+	reset := time.Duration(float64(capacity-remaining) / float64(perMinute) * float64(time.Second))
+	return &bucketStatus{
+		limit:     perMinute,
+		remaining: int(max(remaining, 0) / bucketScale),
+		reset:     max(reset, 0),
+	}
+}
+
+// retryAfter 令牌桶补足 requested 个单位所需的时间
+func retryAfter(perMinute int, requested int, result limiter.Result) time.Duration {
+	missing := float64(int64(requested)*bucketScale - result.Remaining)
+	return time.Duration(math.Ceil(missing/float64(perMinute))) * time.Second
+}
+
+// tighter 取剩余比例更低的状态用于响应头
+func tighter(current *bucketStatus, next *bucketStatus) *bucketStatus {
+	if current == nil {
+		return next
+	}
+	if float64(next.remaining)/float64(next.limit) < float64(current.remaining)/float64(current.limit) {
+		return next
+	}
+	return current
+}
+
+// Acquire 按顺序检查各对象的并发、RPM 与 TPM 限制并占用额度，任意一项超限时退回已占用的额度
+// 返回的 Lease 需要在请求结束时调用 Release
+func Acquire(subjects []Subject, estimatedTokens int) (*Lease, *Rejection) {
+	lease := &Lease{subjects: subjects, charged: make([]int, len(subjects))}
+	for _, subject := range subjects {
+		if rejection := lease.acquireConcurrency(subject); rejection != nil {
+			lease.Release()
+			return lease, rejection
+		}
+	}
+
+	takenRequests := make([]Subject, 0, len(subjects))
+	for _, subject := range subjects {
+		if subject.Limits.RPM <= 0 {
+			continue
+		}
+		result := take(subject.bucketKey(KindRequests), subject.Limits.RPM, 1, false)
+		if !result.Allowed {
+			for _, taken := range takenRequests {
+				take(taken.bucketKey(KindRequests), taken.Limits.RPM, -1, true)
+			}
+			lease.Release()
+			lease.requests = newBucketStatus(subject.Limits.RPM, result)
+			return lease, &Rejection{Subject: subject, Kind: KindRequests, Limit: subject.Limits.RPM, RetryAfter: retryAfter(subject.Limits.RPM, 1, result)}
+		}
+		takenRequests = append(takenRequests, subject)
+		lease.requests = tighter(lease.requests, newBucketStatus(subject.Limits.RPM, result))
+	}
+
+	takenTokens := make([]int, 0, len(subjects))
+	for i, subject := range subjects {
+		if subject.Limits.TPM <= 0 {
+			continue
+		}
+		// 单个请求的预估超过整个桶时按满桶计算，避免永远无法通过
+		requested := min(max(estimatedTokens, 0), subject.Limits.TPM)
+		result := take(subject.bucketKey(KindTokens), subject.Limits.TPM, requested, false)
+		if !result.Allowed {
+			for _, j := range takenTokens {
+				taken := subjects[j]
+				take(taken.bucketKey(KindTokens), taken.Limits.TPM, -lease.charged[j], true)
+			}
+			for _, taken := range takenRequests {
+				take(taken.bucketKey(KindRequests), taken.Limits.RPM, -1, true)
+			}
+			lease.Release()
+			lease.tokens = newBucketStatus(subject.Limits.TPM, result)
+			return lease, &Rejection{Subject: subject, Kind: KindTokens, Limit: subject.Limits.TPM, RetryAfter: retryAfter(subject.Limits.TPM, requested, result)}
+		}
+		takenTokens = append(takenTokens, i)
+		lease.charged[i] = requested
+		lease.tokens = tighter(lease.tokens, newBucketStatus(subject.Limits.TPM, result))
+	}
+	return lease, nil
+}
+
+func concurrencyKey(subject Subject) string {
+	return subject.bucketKey(KindConcurrency)
+}
+
+func (l *Lease) acquireConcurrency(subject Subject) *Rejection {
+	limit := subject.Limits.Concurrency
+	if limit <= 0 {
+		return nil
+	}
+	key := concurrencyKey(subject)
+	var current int64
+	if redisEnabled() {
+		ctx := context.Background()
+		pipe := common.RDB.TxPipeline()
+		incr := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, concurrencyTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			common.SysError("rate limit: " + err.Error())
+			return nil
+		}
+		current = incr.Val()
+	} else {
+		concurrencyMu.Lock()
+		localConcurrency[key]++
+		current = int64(localConcurrency[key])
+		concurrencyMu.Unlock()
+	}
+	l.concurrency = append(l.concurrency, subject)
+	if current > int64(limit) {
+		return &Rejection{Subject: subject, Kind: KindConcurrency, Limit: limit, RetryAfter: time.Second}
+	}
+	return nil
+}
+
+func releaseConcurrency(subject Subject) {
+	key := concurrencyKey(subject)
+	if redisEnabled() {
+		if err := common.RDB.Decr(context.Background(), key).Err(); err != nil {
+			common.SysError("rate limit: " + err.Error())
+		}
+		return
+	}
+	concurrencyMu.Lock()
+	defer concurrencyMu.Unlock()
+	if localConcurrency[key] <= 1 {
+		delete(localConcurrency, key)
+		return
+	}
+	localConcurrency[key]--
+}
+
+// Reconcile 按实际消耗的 Token 数调整 TPM，多次调用时累加；在 Release 之后调用时补扣全部实际用量
+func (l *Lease) Reconcile(tokens int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used += max(tokens, 0)
+	l.reconciled = true
+	l.adjustTokens(l.used)
+}
+
+// adjustTokens 将各对象的 TPM 扣减量调整为 target，按与实际扣减量的差额补扣或退回
+func (l *Lease) adjustTokens(target int) {
+	for i, subject := range l.subjects {
+		if subject.Limits.TPM <= 0 {
+			continue
+		}
+		if delta := target - l.charged[i]; delta != 0 {
+			take(subject.bucketKey(KindTokens), subject.Limits.TPM, delta, true)
+		}
+		l.charged[i] = target
+	}
+}
+
+// Release 释放并发占用；未上报实际用量的请求退回预估的 TPM
+func (l *Lease) Release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return
+	}
+	l.released = true
+	for _, subject := range l.concurrency {
+		releaseConcurrency(subject)
+	}
+	if !l.reconciled {
+		l.adjustTokens(0)
+	}
+}
+
+func formatReset(d time.Duration) string {
+	if d < time.Second {
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// WriteHeaders 写入 OpenAI 风格的 x-ratelimit-* 响应头
+func (l *Lease) WriteHeaders(header http.Header) {
+	if l == nil {
+		return
+	}
+	if l.requests != nil {
+		header.Set("x-ratelimit-limit-requests", strconv.Itoa(l.requests.limit))
+		header.Set("x-ratelimit-remaining-requests", strconv.Itoa(l.requests.remaining))
+		header.Set("x-ratelimit-reset-requests", formatReset(l.requests.reset))
+	}
+	if l.tokens != nil {
+		header.Set("x-ratelimit-limit-tokens", strconv.Itoa(l.tokens.limit))
+		header.Set("x-ratelimit-remaining-tokens", strconv.Itoa(l.tokens.remaining))
+		header.Set("x-ratelimit-reset-tokens", formatReset(l.tokens.reset))
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireRejectsOverRPM(t *testing.T) {
+	subject := Subject{Scope: ScopeToken, Id: 1001, Limits: Limits{RPM: 2}}
+	for i := 0; i < 2; i++ {
+		lease, rejection := Acquire([]Subject{subject}, 0)
+		require.Nil(t, rejection)
+		lease.Release()
+	}
+
+	lease, rejection := Acquire([]Subject{subject}, 0)
+	require.NotNil(t, rejection)
+	assert.Equal(t, KindRequests, rejection.Kind)
+	assert.Positive(t, rejection.RetryAfter)
+
+	header := http.Header{}
+	lease.WriteHeaders(header)
+	assert.Equal(t, "2", header.Get("x-ratelimit-limit-requests"))
+	assert.Equal(t, "0", header.Get("x-ratelimit-remaining-requests"))
+}
+
+func TestAcquireRefundsEarlierSubjectsOnRejection(t *testing.T) {
+	user := Subject{Scope: ScopeUser, Id: 1002, Limits: Limits{RPM: 10}}
+	token := Subject{Scope: ScopeToken, Id: 1002, Limits: Limits{RPM: 1}}
+	lease, rejection := Acquire([]Subject{user, token}, 0)
+	require.Nil(t, rejection)
+	lease.Release()
+
+	_, rejection = Acquire([]Subject{user, token}, 0)
+	require.NotNil(t, rejection)
+	assert.Equal(t, ScopeToken, rejection.Subject.Scope)
+
+	// 令牌被拒绝的请求不占用用户的 RPM
+	result := take(user.bucketKey(KindRequests), user.Limits.RPM, 0, false)
+	// 令牌桶按秒补充，允许跨秒带来的误差
+	assert.InDelta(t, 9*bucketScale, result.Remaining, float64(user.Limits.RPM))
+}
+
+func TestTPMReconcileWithActualUsage(t *testing.T) {
+	subject := Subject{Scope: ScopeToken, Id: 1003, Limits: Limits{TPM: 1000}}
+	lease, rejection := Acquire([]Subject{subject}, 800)
+	require.Nil(t, rejection)
+
+	_, rejection = Acquire([]Subject{subject}, 300)
+	require.NotNil(t, rejection)
+	assert.Equal(t, KindTokens, rejection.Kind)
+
+	// 实际只用了 100，退回 700
+	lease.Reconcile(100)
+	lease.Release()
+	next, rejection := Acquire([]Subject{subject}, 300)
+	require.Nil(t, rejection)
+	next.Release()
+
+	// 未计费的请求退回预扣的额度
+	result := take(subject.bucketKey(KindTokens), subject.Limits.TPM, 0, false)
+	assert.InDelta(t, 900*bucketScale, result.Remaining, float64(subject.Limits.TPM))
+}
+
+func TestTPMReleaseReturnsOnlyCappedCharge(t *testing.T) {
+	subject := Subject{Scope: ScopeToken, Id: 1005, Limits: Limits{TPM: 1000}}
+	// 预估超过桶容量时只扣满桶，退回时也只退回实际扣减的部分
+	lease, rejection := Acquire([]Subject{subject}, 5000)
+	require.Nil(t, rejection)
+	lease.Release()
+
+	result := take(subject.bucketKey(KindTokens), subject.Limits.TPM, 0, false)
+	assert.InDelta(t, 1000*bucketScale, result.Remaining, float64(subject.Limits.TPM))
+
+	lease, rejection = Acquire([]Subject{subject}, 5000)
+	require.Nil(t, rejection)
+	lease.Reconcile(600)
+	lease.Release()
+	result = take(subject.bucketKey(KindTokens), subject.Limits.TPM, 0, false)
+	assert.InDelta(t, 400*bucketScale, result.Remaining, float64(subject.Limits.TPM))
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	subject := Subject{Scope: ScopeUser, Id: 1004, Limits: Limits{Concurrency: 1}}
+	first, rejection := Acquire([]Subject{subject}, 0)
+	require.Nil(t, rejection)
+
+	_, rejection = Acquire([]Subject{subject}, 0)
+	require.NotNil(t, rejection)
+	assert.Equal(t, KindConcurrency, rejection.Kind)
+
+	first.Release()
+	first.Release()
+	second, rejection := Acquire([]Subject{subject}, 0)
+	require.Nil(t, rejection)
+	second.Release()
+}
@@ -0,0 +1,142 @@
+// Package tracing 提供基于 OpenTelemetry 的链路追踪
+// 设置 OTEL_EXPORTER_OTLP_ENDPOINT（或 OTEL_EXPORTER_OTLP_TRACES_ENDPOINT）后启用 OTLP/HTTP 导出，
+// 未启用时全局 TracerProvider 为 noop，埋点开销可以忽略
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/QuantumNous/new-api"
+	defaultServiceName  = "new-api"
+)
+
+var enabled atomic.Bool
+
+// Enabled 是否已启用链路追踪导出
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Init 根据环境变量初始化 TracerProvider，返回退出时调用的 shutdown 函数
+// 导出地址、请求头、采样率等均遵循 OpenTelemetry 标准环境变量（OTEL_EXPORTER_OTLP_*、OTEL_TRACES_SAMPLER 等）
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName(defaultServiceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// 环境变量 OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES 优先于默认服务名
+	envRes, err := resource.New(ctx, resource.WithFromEnv())
+	if err == nil {
+		res, _ = resource.Merge(res, envRes)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled.Store(true)
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 以 ctx 中的 span 为父节点创建子 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartGin 以当前请求上下文为父节点创建子 span，不修改 c.Request
+// 适用于中间件等只需记录耗时、不需要向下传递的阶段
+func StartGin(c *gin.Context, name string, attrs ...attribute.KeyValue) trace.Span {
+	ctx := context.Background()
+	if c != nil && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	_, span := Start(ctx, name, attrs...)
+	return span
+}
+
+// StartGinScope 创建子 span 并将其写入 c.Request 的上下文，使后续阶段与上游请求成为其子节点
+// 返回的函数结束 span 并恢复原上下文
+func StartGinScope(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	parent := c.Request.Context()
+	ctx, span := Start(parent, name, attrs...)
+	c.Request = c.Request.WithContext(ctx)
+	return span, func() {
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// RecordError 将错误记录到 span 并标记为失败
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// End 记录错误（如有）并结束 span
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
+
+// ContextWithSpanFrom 将 src 中的 span 挂到 dst 上，不继承 src 的取消与超时
+// 用于上游请求沿用原有生命周期、同时保持链路父子关系
+func ContextWithSpanFrom(dst context.Context, src context.Context) context.Context {
+	span := trace.SpanFromContext(src)
+	if !span.SpanContext().IsValid() {
+		return dst
+	}
+	return trace.ContextWithSpan(dst, span)
+}
+
+// TraceId 返回 ctx 中的 trace id，没有有效 span 时返回空串
+func TraceId(ctx context.Context) string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.HasTraceID() {
+		return ""
+	}
+	return spanCtx.TraceID().String()
+}
+
+// Extract 从入站请求头中提取上游传入的 trace 上下文
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// StartServer 创建入站请求的 server span
+func StartServer(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
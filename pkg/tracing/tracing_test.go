@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled.Store(true)
+	t.Cleanup(func() {
+		enabled.Store(false)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestTransportInjectsTraceParent(t *testing.T) {
+	exporter := setupTestProvider(t)
+
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "relay.attempt")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/chat/completions", nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	parent.End()
+
+	assert.Contains(t, traceParent, parent.SpanContext().TraceID().String())
+	assert.Empty(t, req.Header.Get("traceparent"), "original request must not be mutated")
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "HTTP POST", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+}
+
+func TestContextWithSpanFromKeepsDestinationLifetime(t *testing.T) {
+	setupTestProvider(t)
+
+	src, span := Start(context.Background(), "parent")
+	defer span.End()
+	cancelled, cancel := context.WithCancel(src)
+	cancel()
+
+	ctx := ContextWithSpanFrom(context.Background(), cancelled)
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, TraceId(src), TraceId(ctx))
+}
+
+func TestEndRecordsError(t *testing.T) {
+	exporter := setupTestProvider(t)
+
+	_, span := Start(context.Background(), "billing.settle")
+	End(span, errors.New("insufficient quota"))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "insufficient quota", spans[0].Status.Description)
+}
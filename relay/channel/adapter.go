@@ -78,6 +78,16 @@ type TaskAdaptor interface {
 	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
 }
 
+// ClaudeTokenCounter 上游支持 Anthropic count_tokens 的适配器实现该接口，未实现时本地估算
+type ClaudeTokenCounter interface {
+	CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error)
+}
+
 type OpenAIVideoConverter interface {
 	ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error)
 }
+
+// OpenAIVideoDeleter 上游支持删除视频的适配器实现该接口，删除任务时同步删除上游视频
+type OpenAIVideoDeleter interface {
+	DeleteOpenAIVideo(baseUrl, key, proxy string, task *model.Task) error
+}
@@ -13,6 +13,7 @@ import (
 
 	common2 "github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
@@ -488,6 +489,14 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		client = service.GetHttpClient()
 	}
 
+	if info.Hedge != nil {
+		// 对冲请求落败后需要中止上游请求
+		req = req.WithContext(c.Request.Context())
+	} else {
+		// 仅沿用当前 span 作为出站请求的父节点，不改变上游请求的取消语义
+		req = req.WithContext(tracing.ContextWithSpanFrom(req.Context(), c.Request.Context()))
+	}
+
 	var stopPinger context.CancelFunc
 	if info.IsStream {
 		helper.SetEventStreamHeaders(c)
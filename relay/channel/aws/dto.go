@@ -142,3 +142,80 @@ func parseStopSequences(stop any) []string {
 	}
 	return nil
 }
+
+// AwsTitanEmbeddingRequest Amazon Titan Text Embeddings，每次调用仅支持一条输入
+type AwsTitanEmbeddingRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"`
+}
+
+type AwsTitanEmbeddingResponse struct {
+	Embedding           []float64 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// AwsCohereEmbeddingRequest Bedrock 上的 Cohere Embed 模型
+type AwsCohereEmbeddingRequest struct {
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types,omitempty"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+}
+
+type AwsCohereEmbeddingResponse struct {
+	Embeddings struct {
+		Float [][]float64 `json:"float"`
+	} `json:"embeddings"`
+}
+
+// AwsTitanImageRequest Amazon Titan Image Generator 与 Nova Canvas 共用的请求格式
+type AwsTitanImageRequest struct {
+	TaskType              string                        `json:"taskType"`
+	TextToImageParams     *AwsTitanTextToImageParams    `json:"textToImageParams,omitempty"`
+	InPaintingParams      *AwsTitanInPaintingParams     `json:"inPaintingParams,omitempty"`
+	ImageVariationParams  *AwsTitanImageVariationParams `json:"imageVariationParams,omitempty"`
+	ImageGenerationConfig AwsTitanImageGenerationConfig `json:"imageGenerationConfig"`
+}
+
+type AwsTitanTextToImageParams struct {
+	Text string `json:"text"`
+}
+
+type AwsTitanInPaintingParams struct {
+	Text      string `json:"text,omitempty"`
+	Image     string `json:"image"`
+	MaskImage string `json:"maskImage"`
+}
+
+type AwsTitanImageVariationParams struct {
+	Text   string   `json:"text,omitempty"`
+	Images []string `json:"images"`
+}
+
+type AwsTitanImageGenerationConfig struct {
+	NumberOfImages int    `json:"numberOfImages,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+}
+
+type AwsTitanImageResponse struct {
+	Images []string `json:"images"`
+	Error  *string  `json:"error,omitempty"`
+}
+
+// AwsStabilityImageRequest Bedrock 上的 Stability 图片模型，每次调用生成一张
+type AwsStabilityImageRequest struct {
+	Prompt       string  `json:"prompt"`
+	Mode         string  `json:"mode,omitempty"`
+	AspectRatio  string  `json:"aspect_ratio,omitempty"`
+	OutputFormat string  `json:"output_format,omitempty"`
+	Image        string  `json:"image,omitempty"`
+	Mask         string  `json:"mask,omitempty"`
+	Strength     float64 `json:"strength,omitempty"`
+}
+
+type AwsStabilityImageResponse struct {
+	Images        []string  `json:"images"`
+	FinishReasons []*string `json:"finish_reasons"`
+}
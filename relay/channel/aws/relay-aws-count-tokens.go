@@ -0,0 +1,127 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type awsCountTokensRequest struct {
+	Input struct {
+		InvokeModel struct {
+			Body string `json:"body"`
+		} `json:"invokeModel"`
+	} `json:"input"`
+}
+
+type awsCountTokensResponse struct {
+	InputTokens int `json:"inputTokens"`
+}
+
+// CountClaudeTokens 调用 Bedrock CountTokens，请求体与 InvokeModel 一致
+// 跨区域推理配置不支持 CountTokens，因此始终使用基础模型 ID
+func (a *Adaptor) CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error) {
+	if isNovaModel(info.UpstreamModelName) {
+		return 0, errors.New("count tokens is not supported for nova models")
+	}
+	awsSecret := strings.Split(info.ApiKey, "|")
+	var region string
+	switch len(awsSecret) {
+	case 2:
+		region = awsSecret[1]
+	case 3:
+		region = awsSecret[2]
+	default:
+		return 0, errors.New("invalid aws secret key")
+	}
+
+	convertedRequest, err := a.ConvertClaudeRequest(c, info, request)
+	if err != nil {
+		return 0, err
+	}
+	claudeRequest := convertedRequest.(*dto.ClaudeRequest)
+	if claudeRequest.MaxTokens == 0 {
+		claudeRequest.MaxTokens = uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(claudeRequest.Model))
+	}
+	claudeBody, err := common.Marshal(claudeRequest)
+	if err != nil {
+		return 0, errors.Wrap(err, "marshal claude request fail")
+	}
+	requestHeader := http.Header{}
+	a.SetupRequestHeader(c, &requestHeader, info)
+	awsClaudeReq, err := formatRequest(bytes.NewReader(claudeBody), requestHeader)
+	if err != nil {
+		return 0, errors.Wrap(err, "format aws request fail")
+	}
+	invokeBody, err := common.Marshal(awsClaudeReq)
+	if err != nil {
+		return 0, errors.Wrap(err, "marshal aws request fail")
+	}
+	countRequest := awsCountTokensRequest{}
+	countRequest.Input.InvokeModel.Body = base64.StdEncoding.EncodeToString(invokeBody)
+	payload, err := common.Marshal(countRequest)
+	if err != nil {
+		return 0, errors.Wrap(err, "marshal count tokens request fail")
+	}
+
+	ctx, cancel := newAwsInvokeContext()
+	defer cancel()
+	requestURL := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/count-tokens",
+		region, url.PathEscape(getAwsModelID(info.UpstreamModelName)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if len(awsSecret) == 2 {
+		req.Header.Set("Authorization", "Bearer "+awsSecret[0])
+	} else {
+		payloadHash := sha256.Sum256(payload)
+		credentials := aws.Credentials{AccessKeyID: awsSecret[0], SecretAccessKey: awsSecret[1]}
+		err = v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]), "bedrock", region, time.Now())
+		if err != nil {
+			return 0, errors.Wrap(err, "sign aws request fail")
+		}
+	}
+
+	httpClient, err := getAwsHttpClient(info)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "do aws count tokens request fail")
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errors.Wrap(err, "read aws count tokens response fail")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("aws count tokens failed with status %d: %s", resp.StatusCode, string(responseBody))
+	}
+	var countResponse awsCountTokensResponse
+	if err := common.Unmarshal(responseBody, &countResponse); err != nil {
+		return 0, errors.Wrap(err, "unmarshal aws count tokens response fail")
+	}
+	return countResponse.InputTokens, nil
+}
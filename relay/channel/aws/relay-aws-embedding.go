@@ -0,0 +1,108 @@
+package aws
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+func isTitanEmbeddingModel(modelId string) bool {
+	return strings.HasPrefix(modelId, "amazon.titan-embed")
+}
+
+func isCohereEmbeddingModel(modelId string) bool {
+	return strings.HasPrefix(modelId, "cohere.embed")
+}
+
+func convertEmbeddingRequest(info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	inputs := request.ParseInput()
+	if len(inputs) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	modelId := getAwsModelID(info.UpstreamModelName)
+	switch {
+	case isTitanEmbeddingModel(modelId):
+		requests := make([]AwsTitanEmbeddingRequest, 0, len(inputs))
+		for _, input := range inputs {
+			titanRequest := AwsTitanEmbeddingRequest{InputText: input}
+			// 仅 Titan Text Embeddings V2 支持指定维度
+			if strings.HasPrefix(modelId, "amazon.titan-embed-text-v2") {
+				titanRequest.Dimensions = request.Dimensions
+			}
+			requests = append(requests, titanRequest)
+		}
+		return requests, nil
+	case isCohereEmbeddingModel(modelId):
+		cohereRequest := AwsCohereEmbeddingRequest{
+			Texts:          inputs,
+			InputType:      "search_document",
+			EmbeddingTypes: []string{"float"},
+		}
+		// Embed v3 不支持指定维度
+		if strings.HasPrefix(modelId, "cohere.embed-v4") {
+			cohereRequest.OutputDimension = request.Dimensions
+		}
+		return cohereRequest, nil
+	}
+	return nil, errors.Errorf("embedding model %s is not supported", info.UpstreamModelName)
+}
+
+func awsEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor) (*dto.Usage, *types.NewAPIError) {
+	ctx, cancel := newAwsInvokeContext()
+	defer cancel()
+
+	modelId := getAwsModelID(info.UpstreamModelName)
+	openAIResponse := dto.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.OpenAIEmbeddingResponseItem, 0),
+		Model:  info.UpstreamModelName,
+	}
+	promptTokens := 0
+	for _, awsReq := range a.AwsReq.([]*bedrockruntime.InvokeModelInput) {
+		awsResp, err := a.AwsClient.InvokeModel(ctx, awsReq)
+		if err != nil {
+			return nil, types.NewOpenAIError(errors.Wrap(err, "InvokeModel"), types.ErrorCodeAwsInvokeError, getAwsErrorStatusCode(err))
+		}
+		var embeddings [][]float64
+		if isTitanEmbeddingModel(modelId) {
+			var titanResp AwsTitanEmbeddingResponse
+			if err := common.Unmarshal(awsResp.Body, &titanResp); err != nil {
+				return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+			}
+			embeddings = [][]float64{titanResp.Embedding}
+			promptTokens += titanResp.InputTextTokenCount
+		} else {
+			var cohereResp AwsCohereEmbeddingResponse
+			if err := common.Unmarshal(awsResp.Body, &cohereResp); err != nil {
+				return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+			}
+			embeddings = cohereResp.Embeddings.Float
+		}
+		for _, embedding := range embeddings {
+			openAIResponse.Data = append(openAIResponse.Data, dto.OpenAIEmbeddingResponseItem{
+				Object:    "embedding",
+				Index:     len(openAIResponse.Data),
+				Embedding: embedding,
+			})
+		}
+	}
+	// Cohere 模型不返回 token 用量，按预估值计费
+	usage := service.EmbeddingUsage(c, info, promptTokens)
+	openAIResponse.Usage = *usage
+
+	jsonResponse, err := service.MarshalEmbeddingResponse(info, &openAIResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.IOCopyBytesGracefully(c, nil, jsonResponse)
+	return usage, nil
+}
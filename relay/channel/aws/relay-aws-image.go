@@ -0,0 +1,219 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+func isImageRelayMode(relayMode int) bool {
+	return relayMode == constant.RelayModeImagesGenerations || relayMode == constant.RelayModeImagesEdits
+}
+
+// isTitanImageModel Titan Image Generator 与 Nova Canvas 使用相同的请求格式
+func isTitanImageModel(modelId string) bool {
+	return strings.HasPrefix(modelId, "amazon.titan-image-generator") || strings.HasPrefix(modelId, "amazon.nova-canvas")
+}
+
+func isStabilityImageModel(modelId string) bool {
+	return strings.HasPrefix(modelId, "stability.")
+}
+
+func parseImageSize(size string) (int, int, bool) {
+	parts := strings.Split(strings.TrimSpace(size), "x")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	w, err1 := strconv.Atoi(parts[0])
+	h, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+var stabilityAspectRatios = map[string]struct{}{
+	"1:1": {}, "16:9": {}, "21:9": {}, "2:3": {}, "3:2": {}, "4:5": {}, "5:4": {}, "9:16": {}, "9:21": {},
+}
+
+// stabilityAspectRatio 将 OpenAI size 转换为 Stability 支持的宽高比，无法识别时返回空（使用上游默认 1:1）
+func stabilityAspectRatio(size string) string {
+	if strings.Contains(size, ":") {
+		return size
+	}
+	switch size {
+	case "1792x1024":
+		return "16:9"
+	case "1024x1792":
+		return "9:16"
+	}
+	w, h, ok := parseImageSize(size)
+	if !ok {
+		return ""
+	}
+	a, b := w, h
+	for b != 0 {
+		a, b = b, a%b
+	}
+	ratio := fmt.Sprintf("%d:%d", w/a, h/a)
+	if _, ok := stabilityAspectRatios[ratio]; ok {
+		return ratio
+	}
+	return ""
+}
+
+func convertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	modelId := getAwsModelID(info.UpstreamModelName)
+	n := max(int(request.N), 1)
+	var image, mask *service.ImageInput
+	if info.RelayMode == constant.RelayModeImagesEdits {
+		var err error
+		image, mask, err = service.GetImageEditInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case isTitanImageModel(modelId):
+		titanRequest := AwsTitanImageRequest{
+			ImageGenerationConfig: AwsTitanImageGenerationConfig{
+				NumberOfImages: n,
+				Quality:        "standard",
+			},
+		}
+		if request.Quality == "hd" || request.Quality == "high" {
+			titanRequest.ImageGenerationConfig.Quality = "premium"
+		}
+		if w, h, ok := parseImageSize(request.Size); ok {
+			titanRequest.ImageGenerationConfig.Width = w
+			titanRequest.ImageGenerationConfig.Height = h
+		}
+		switch {
+		case image == nil:
+			titanRequest.TaskType = "TEXT_IMAGE"
+			titanRequest.TextToImageParams = &AwsTitanTextToImageParams{Text: request.Prompt}
+		case mask != nil:
+			// Titan 蒙版中黑色为待编辑区域
+			mask, err := service.ConvertImageMask(mask, false)
+			if err != nil {
+				return nil, err
+			}
+			titanRequest.TaskType = "INPAINTING"
+			titanRequest.InPaintingParams = &AwsTitanInPaintingParams{
+				Text:      request.Prompt,
+				Image:     image.Data,
+				MaskImage: mask.Data,
+			}
+		default:
+			titanRequest.TaskType = "IMAGE_VARIATION"
+			titanRequest.ImageVariationParams = &AwsTitanImageVariationParams{
+				Text:   request.Prompt,
+				Images: []string{image.Data},
+			}
+		}
+		// 编辑时输出尺寸跟随原图
+		if image != nil {
+			titanRequest.ImageGenerationConfig.Width = 0
+			titanRequest.ImageGenerationConfig.Height = 0
+		}
+		return titanRequest, nil
+	case isStabilityImageModel(modelId):
+		stabilityRequest := AwsStabilityImageRequest{
+			Prompt:       request.Prompt,
+			OutputFormat: "png",
+		}
+		if image == nil {
+			stabilityRequest.AspectRatio = stabilityAspectRatio(request.Size)
+		} else {
+			stabilityRequest.Image = image.Data
+			if mask != nil {
+				// Stability 蒙版中白色为待编辑区域
+				mask, err := service.ConvertImageMask(mask, true)
+				if err != nil {
+					return nil, err
+				}
+				stabilityRequest.Mask = mask.Data
+			} else {
+				stabilityRequest.Strength = 0.7
+				if strings.HasPrefix(modelId, "stability.sd3") {
+					stabilityRequest.Mode = "image-to-image"
+				}
+			}
+		}
+		// Stability 每次调用仅生成一张图片，按 n 拆分为多次调用
+		requests := make([]AwsStabilityImageRequest, n)
+		for i := range requests {
+			requests[i] = stabilityRequest
+		}
+		return requests, nil
+	}
+	return nil, errors.Errorf("image model %s is not supported", info.UpstreamModelName)
+}
+
+func awsImageHandler(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor) (*dto.Usage, *types.NewAPIError) {
+	ctx, cancel := newAwsInvokeContext()
+	defer cancel()
+
+	modelId := getAwsModelID(info.UpstreamModelName)
+	imageResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	for _, awsReq := range a.AwsReq.([]*bedrockruntime.InvokeModelInput) {
+		awsResp, err := a.AwsClient.InvokeModel(ctx, awsReq)
+		if err != nil {
+			return nil, types.NewOpenAIError(errors.Wrap(err, "InvokeModel"), types.ErrorCodeAwsInvokeError, getAwsErrorStatusCode(err))
+		}
+		if isTitanImageModel(modelId) {
+			var titanResp AwsTitanImageResponse
+			if err := common.Unmarshal(awsResp.Body, &titanResp); err != nil {
+				return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+			}
+			if titanResp.Error != nil && *titanResp.Error != "" {
+				return nil, types.NewOpenAIError(errors.New(*titanResp.Error), types.ErrorCodeBadResponse, http.StatusBadRequest)
+			}
+			for _, img := range titanResp.Images {
+				imageResponse.Data = append(imageResponse.Data, dto.ImageData{B64Json: img})
+			}
+			continue
+		}
+		var stabilityResp AwsStabilityImageResponse
+		if err := common.Unmarshal(awsResp.Body, &stabilityResp); err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		for i, img := range stabilityResp.Images {
+			// 被内容过滤的图片不返回
+			if i < len(stabilityResp.FinishReasons) && stabilityResp.FinishReasons[i] != nil {
+				continue
+			}
+			imageResponse.Data = append(imageResponse.Data, dto.ImageData{B64Json: img})
+		}
+	}
+	if len(imageResponse.Data) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no images generated"), types.ErrorCodeBadResponse, http.StatusBadRequest)
+	}
+
+	service.SetImageCountRatio(info, len(imageResponse.Data))
+	if err := service.ApplyImageResponseFormat(info, &imageResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	jsonResponse, err := common.Marshal(imageResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.IOCopyBytesGracefully(c, nil, jsonResponse)
+	return &dto.Usage{}, nil
+}
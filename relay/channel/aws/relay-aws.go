@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -46,18 +47,21 @@ func newAwsInvokeContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), time.Duration(common.RelayTimeout)*time.Second)
 }
 
-func newAwsClient(c *gin.Context, info *relaycommon.RelayInfo) (*bedrockruntime.Client, error) {
-	var (
-		httpClient *http.Client
-		err        error
-	)
+func getAwsHttpClient(info *relaycommon.RelayInfo) (*http.Client, error) {
 	if info.ChannelSetting.Proxy != "" {
-		httpClient, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
+		httpClient, err := service.NewProxyHttpClient(info.ChannelSetting.Proxy)
 		if err != nil {
 			return nil, fmt.Errorf("new proxy http client failed: %w", err)
 		}
-	} else {
-		httpClient = service.GetHttpClient()
+		return httpClient, nil
+	}
+	return service.GetHttpClient(), nil
+}
+
+func newAwsClient(c *gin.Context, info *relaycommon.RelayInfo) (*bedrockruntime.Client, error) {
+	httpClient, err := getAwsHttpClient(info)
+	if err != nil {
+		return nil, err
 	}
 
 	awsSecret := strings.Split(info.ApiKey, "|")
@@ -206,6 +210,38 @@ func awsModelCrossRegion(awsModelId, awsRegionPrefix string) string {
 	return modelPrefix + "." + awsModelId
 }
 
+// doAwsInvokeModelRequests 通过 SDK 调用 InvokeModel，请求体为 JSON 数组时逐条调用（如 Titan 向量、Stability 多张图片）
+func doAwsInvokeModelRequests(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor, requestBody io.Reader) (any, error) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeChannelAwsClientError)
+	}
+	a.AwsClient = awsCli
+
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, types.NewError(errors.Wrap(err, "read request fail"), types.ErrorCodeBadRequestBody)
+	}
+	bodies := []json.RawMessage{body}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := common.Unmarshal(trimmed, &bodies); err != nil {
+			return nil, types.NewError(errors.Wrap(err, "decode request fail"), types.ErrorCodeBadRequestBody)
+		}
+	}
+	modelId := getAwsModelID(info.UpstreamModelName)
+	awsReqs := make([]*bedrockruntime.InvokeModelInput, 0, len(bodies))
+	for _, item := range bodies {
+		awsReqs = append(awsReqs, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(modelId),
+			Accept:      aws.String("application/json"),
+			ContentType: aws.String("application/json"),
+			Body:        item,
+		})
+	}
+	a.AwsReq = awsReqs
+	return nil, nil
+}
+
 func getAwsModelID(requestModel string) string {
 	if awsModelIDName, ok := awsModelIDMap[requestModel]; ok {
 		return awsModelIDName
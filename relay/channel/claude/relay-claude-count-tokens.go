@@ -0,0 +1,42 @@
+package claude
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DoCountTokensRequest 按适配器的请求地址与请求头发送 count_tokens 请求，返回上游统计的 input_tokens
+// Anthropic 与 Vertex AI 的 count_tokens 响应格式一致
+func DoCountTokensRequest(a channel.Adaptor, c *gin.Context, info *relaycommon.RelayInfo, body any) (int, error) {
+	jsonData, err := common.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("marshal count tokens request failed: %w", err)
+	}
+	resp, err := channel.DoApiRequest(a, c, info, bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read count tokens response failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count tokens failed with status %d: %s", resp.StatusCode, string(responseBody))
+	}
+	var countResponse dto.ClaudeCountTokensResponse
+	if err := common.Unmarshal(responseBody, &countResponse); err != nil {
+		return 0, fmt.Errorf("unmarshal count tokens response failed: %w", err)
+	}
+	return countResponse.InputTokens, nil
+}
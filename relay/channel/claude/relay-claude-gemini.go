@@ -0,0 +1,521 @@
+package claude
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/reasonmap"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Claude 要求 budget_tokens 不小于 1024 且小于 max_tokens
+const (
+	claudeMinThinkingBudget = 1024
+	claudeMinThinkingTokens = 1280
+)
+
+type geminiFunctionDeclaration struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	Parameters           any    `json:"parameters,omitempty"`
+	ParametersJsonSchema any    `json:"parametersJsonSchema,omitempty"`
+}
+
+// claudeThinkingSignature Claude 的思考块没有对应的 Gemini 字段，编码后放入 thoughtSignature，
+// 客户端回传时还原，使开启思考后的工具调用可以继续多轮对话
+type claudeThinkingSignature struct {
+	ClaudeThinking []dto.ClaudeMediaMessage `json:"claude_thinking"`
+}
+
+func encodeClaudeThinkingSignature(blocks []dto.ClaudeMediaMessage) []byte {
+	data, err := common.Marshal(claudeThinkingSignature{ClaudeThinking: blocks})
+	if err != nil {
+		return nil
+	}
+	signature, _ := common.Marshal(base64.StdEncoding.EncodeToString(data))
+	return signature
+}
+
+func decodeClaudeThinkingSignature(raw []byte) []dto.ClaudeMediaMessage {
+	if common.GetJsonType(raw) != "string" {
+		return nil
+	}
+	var encoded string
+	if err := common.Unmarshal(raw, &encoded); err != nil {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	var signature claudeThinkingSignature
+	if err := common.Unmarshal(data, &signature); err != nil {
+		return nil
+	}
+	return signature.ClaudeThinking
+}
+
+// geminiSchemaToJSONSchema Gemini 的 Schema 类型为大写枚举（OBJECT、STRING），转换为 JSON Schema 的小写类型
+func geminiSchemaToJSONSchema(schema any) any {
+	switch v := schema.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			if key == "type" {
+				if t, ok := value.(string); ok {
+					out[key] = strings.ToLower(t)
+					continue
+				}
+			}
+			out[key] = geminiSchemaToJSONSchema(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = geminiSchemaToJSONSchema(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// geminiThinkingBudget 将 Gemini thinkingConfig 转换为思考预算，0 表示不开启思考
+func geminiThinkingBudget(config *dto.GeminiThinkingConfig, maxTokens uint) int {
+	if config == nil {
+		return 0
+	}
+	dynamicBudget := int(float64(maxTokens) * model_setting.GetClaudeSettings().ThinkingAdapterBudgetTokensPercentage)
+	if config.ThinkingBudget != nil {
+		budget := *config.ThinkingBudget
+		if budget < 0 {
+			// -1 表示由模型动态决定
+			return dynamicBudget
+		}
+		return budget
+	}
+	switch strings.ToLower(config.ThinkingLevel) {
+	case "minimal":
+		return 0
+	case "low":
+		return 1280
+	case "medium":
+		return 2048
+	case "high":
+		return 4096
+	}
+	if config.IncludeThoughts {
+		return dynamicBudget
+	}
+	return 0
+}
+
+func geminiMediaToClaude(mimeType string, base64Data string) (dto.ClaudeMediaMessage, error) {
+	mediaType := "image"
+	if mimeType == "application/pdf" {
+		mediaType = "document"
+	} else if !strings.HasPrefix(mimeType, "image/") {
+		return dto.ClaudeMediaMessage{}, fmt.Errorf("unsupported media type %s for claude", mimeType)
+	}
+	return dto.ClaudeMediaMessage{
+		Type: mediaType,
+		Source: &dto.ClaudeMessageSource{
+			Type:      "base64",
+			MediaType: mimeType,
+			Data:      base64Data,
+		},
+	}, nil
+}
+
+// RequestGemini2ClaudeMessage 将 Gemini generateContent 请求转换为 Claude Messages 请求
+func RequestGemini2ClaudeMessage(c *gin.Context, geminiRequest *dto.GeminiChatRequest, info *relaycommon.RelayInfo) (*dto.ClaudeRequest, error) {
+	if geminiRequest == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	config := geminiRequest.GenerationConfig
+	claudeRequest := &dto.ClaudeRequest{
+		Model:         info.UpstreamModelName,
+		MaxTokens:     config.MaxOutputTokens,
+		StopSequences: config.StopSequences,
+		Temperature:   config.Temperature,
+		TopP:          config.TopP,
+		TopK:          int(config.TopK),
+		Stream:        info.IsStream,
+	}
+	if claudeRequest.MaxTokens == 0 {
+		claudeRequest.MaxTokens = uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(claudeRequest.Model))
+	}
+	// Gemini 温度范围为 0-2，Claude 为 0-1
+	if claudeRequest.Temperature != nil && *claudeRequest.Temperature > 1 {
+		claudeRequest.Temperature = common.GetPointer[float64](1.0)
+	}
+
+	if budget := geminiThinkingBudget(config.ThinkingConfig, claudeRequest.MaxTokens); budget > 0 {
+		if claudeRequest.MaxTokens < claudeMinThinkingTokens {
+			claudeRequest.MaxTokens = claudeMinThinkingTokens
+		}
+		if budget >= int(claudeRequest.MaxTokens) {
+			budget = int(float64(claudeRequest.MaxTokens) * model_setting.GetClaudeSettings().ThinkingAdapterBudgetTokensPercentage)
+		}
+		budget = max(budget, claudeMinThinkingBudget)
+		if budget >= int(claudeRequest.MaxTokens) {
+			claudeRequest.MaxTokens = uint(budget) + 1
+		}
+		claudeRequest.Thinking = &dto.Thinking{
+			Type:         "enabled",
+			BudgetTokens: common.GetPointer[int](budget),
+		}
+		// 开启思考时不支持调整 temperature、top_p 与 top_k
+		claudeRequest.TopP = 0
+		claudeRequest.TopK = 0
+		claudeRequest.Temperature = common.GetPointer[float64](1.0)
+	}
+
+	claudeTools := make([]any, 0)
+	for _, tool := range geminiRequest.GetTools() {
+		if tool.FunctionDeclarations == nil {
+			continue
+		}
+		declarations, err := common.Any2Type[[]geminiFunctionDeclaration](tool.FunctionDeclarations)
+		if err != nil {
+			return nil, fmt.Errorf("invalid function declarations: %w", err)
+		}
+		for _, declaration := range declarations {
+			schema := declaration.ParametersJsonSchema
+			if schema == nil {
+				schema = geminiSchemaToJSONSchema(declaration.Parameters)
+			}
+			inputSchema, _ := schema.(map[string]any)
+			if inputSchema == nil {
+				inputSchema = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			claudeTools = append(claudeTools, &dto.Tool{
+				Name:        declaration.Name,
+				Description: declaration.Description,
+				InputSchema: inputSchema,
+			})
+		}
+	}
+	if len(claudeTools) > 0 {
+		claudeRequest.Tools = claudeTools
+	}
+	if geminiRequest.ToolConfig != nil && geminiRequest.ToolConfig.FunctionCallingConfig != nil {
+		callingConfig := geminiRequest.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(string(callingConfig.Mode)) {
+		case "AUTO":
+			claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "auto"}
+		case "ANY":
+			if len(callingConfig.AllowedFunctionNames) == 1 {
+				claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "tool", Name: callingConfig.AllowedFunctionNames[0]}
+			} else {
+				claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "any"}
+			}
+		case "NONE":
+			claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "none"}
+		}
+	}
+
+	if geminiRequest.SystemInstructions != nil {
+		systemMessages := make([]dto.ClaudeMediaMessage, 0, len(geminiRequest.SystemInstructions.Parts))
+		for _, part := range geminiRequest.SystemInstructions.Parts {
+			if part.Text != "" {
+				systemMessages = append(systemMessages, dto.ClaudeMediaMessage{
+					Type: "text",
+					Text: common.GetPointer[string](part.Text),
+				})
+			}
+		}
+		if len(systemMessages) > 0 {
+			claudeRequest.System = systemMessages
+		}
+	}
+
+	// Gemini 的函数调用没有 ID，按函数名顺序匹配调用与结果
+	pendingCalls := make(map[string][]string)
+	callCount := 0
+	claudeMessages := make([]dto.ClaudeMessage, 0, len(geminiRequest.Contents))
+	for _, content := range geminiRequest.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		var thinkingBlocks []dto.ClaudeMediaMessage
+		blocks := make([]dto.ClaudeMediaMessage, 0, len(content.Parts))
+		for _, part := range content.Parts {
+			switch {
+			case part.Thought:
+				// 思考摘要无法作为 Claude 的思考块回传
+				continue
+			case part.Text != "":
+				blocks = append(blocks, dto.ClaudeMediaMessage{
+					Type: "text",
+					Text: common.GetPointer[string](part.Text),
+				})
+			case part.InlineData != nil:
+				block, err := geminiMediaToClaude(part.InlineData.MimeType, part.InlineData.Data)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, block)
+			case part.FileData != nil:
+				if !strings.HasPrefix(part.FileData.FileUri, "http") {
+					return nil, fmt.Errorf("unsupported file uri %s for claude", part.FileData.FileUri)
+				}
+				base64Data, mimeType, err := service.GetBase64Data(c, types.NewURLFileSource(part.FileData.FileUri), "formatting file for Claude")
+				if err != nil {
+					return nil, fmt.Errorf("get file data failed: %s", err.Error())
+				}
+				if part.FileData.MimeType != "" {
+					mimeType = part.FileData.MimeType
+				}
+				block, err := geminiMediaToClaude(mimeType, base64Data)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, block)
+			case part.FunctionCall != nil:
+				if thinkingBlocks == nil {
+					thinkingBlocks = decodeClaudeThinkingSignature(part.ThoughtSignature)
+				}
+				callCount++
+				id := fmt.Sprintf("toolu_%d", callCount)
+				name := part.FunctionCall.FunctionName
+				pendingCalls[name] = append(pendingCalls[name], id)
+				input := part.FunctionCall.Arguments
+				if input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, dto.ClaudeMediaMessage{
+					Type:  "tool_use",
+					Id:    id,
+					Name:  name,
+					Input: input,
+				})
+			case part.FunctionResponse != nil:
+				name := part.FunctionResponse.Name
+				var id string
+				if ids := pendingCalls[name]; len(ids) > 0 {
+					id = ids[0]
+					pendingCalls[name] = ids[1:]
+				} else {
+					return nil, fmt.Errorf("functionResponse %s has no matching functionCall", name)
+				}
+				blocks = append(blocks, dto.ClaudeMediaMessage{
+					Type:      "tool_result",
+					ToolUseId: id,
+					Content:   common.GetJsonString(part.FunctionResponse.Response),
+				})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if role == "assistant" && len(thinkingBlocks) > 0 {
+			blocks = append(thinkingBlocks, blocks...)
+		}
+		// 合并相同角色的连续消息
+		if last := len(claudeMessages) - 1; last >= 0 && claudeMessages[last].Role == role {
+			claudeMessages[last].Content = append(claudeMessages[last].Content.([]dto.ClaudeMediaMessage), blocks...)
+			continue
+		}
+		if len(claudeMessages) == 0 && role != "user" {
+			// fix: first message is assistant, add user message
+			claudeMessages = append(claudeMessages, dto.ClaudeMessage{
+				Role: "user",
+				Content: []dto.ClaudeMediaMessage{
+					{
+						Type: "text",
+						Text: common.GetPointer[string]("..."),
+					},
+				},
+			})
+		}
+		claudeMessages = append(claudeMessages, dto.ClaudeMessage{
+			Role:    role,
+			Content: blocks,
+		})
+	}
+	claudeRequest.Messages = claudeMessages
+	return claudeRequest, nil
+}
+
+func usageToGeminiUsageMetadata(usage *dto.Usage) dto.GeminiUsageMetadata {
+	if usage == nil {
+		return dto.GeminiUsageMetadata{}
+	}
+	// Claude 的 input_tokens 不包含缓存部分，Gemini 的 promptTokenCount 包含
+	promptTokens := usage.PromptTokens + usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	return dto.GeminiUsageMetadata{
+		PromptTokenCount:        promptTokens,
+		CandidatesTokenCount:    usage.CompletionTokens,
+		TotalTokenCount:         promptTokens + usage.CompletionTokens,
+		CachedContentTokenCount: usage.PromptTokensDetails.CachedTokens,
+	}
+}
+
+func functionCallPart(name string, input any, thinkingBlocks []dto.ClaudeMediaMessage) dto.GeminiPart {
+	if input == nil {
+		input = map[string]any{}
+	}
+	part := dto.GeminiPart{
+		FunctionCall: &dto.FunctionCall{
+			FunctionName: name,
+			Arguments:    input,
+		},
+	}
+	if len(thinkingBlocks) > 0 {
+		part.ThoughtSignature = encodeClaudeThinkingSignature(thinkingBlocks)
+	}
+	return part
+}
+
+// ResponseClaude2Gemini 将 Claude 非流式响应转换为 Gemini 响应
+func ResponseClaude2Gemini(claudeResponse *dto.ClaudeResponse, usage *dto.Usage, includeThoughts bool) *dto.GeminiChatResponse {
+	parts := make([]dto.GeminiPart, 0, len(claudeResponse.Content))
+	var thinkingBlocks []dto.ClaudeMediaMessage
+	for _, block := range claudeResponse.Content {
+		switch block.Type {
+		case "thinking":
+			thinkingBlocks = append(thinkingBlocks, dto.ClaudeMediaMessage{
+				Type:      "thinking",
+				Thinking:  block.Thinking,
+				Signature: block.Signature,
+			})
+			if includeThoughts && block.Thinking != nil && *block.Thinking != "" {
+				parts = append(parts, dto.GeminiPart{Text: *block.Thinking, Thought: true})
+			}
+		case "text":
+			parts = append(parts, dto.GeminiPart{Text: block.GetText()})
+		case "tool_use":
+			parts = append(parts, functionCallPart(block.Name, block.Input, thinkingBlocks))
+			thinkingBlocks = nil
+		}
+	}
+	finishReason := reasonmap.ClaudeStopReasonToGeminiFinishReason(claudeResponse.StopReason)
+	return &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{
+			Content: dto.GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason:  &finishReason,
+			SafetyRatings: []dto.GeminiChatSafetyRating{},
+		}},
+		UsageMetadata: usageToGeminiUsageMetadata(usage),
+	}
+}
+
+// geminiStreamState Claude 流式事件转换为 Gemini 流式响应时的状态，函数调用在参数完整后一次性输出
+type geminiStreamState struct {
+	includeThoughts bool
+	blockType       string
+	toolName        string
+	toolArgs        strings.Builder
+	thinking        strings.Builder
+	signature       strings.Builder
+	thinkingBlocks  []dto.ClaudeMediaMessage
+}
+
+func newGeminiStreamState(includeThoughts bool) *geminiStreamState {
+	return &geminiStreamState{includeThoughts: includeThoughts}
+}
+
+// Convert 返回 nil 表示该事件无需输出
+func (s *geminiStreamState) Convert(claudeResponse *dto.ClaudeResponse, usage *dto.Usage) *dto.GeminiChatResponse {
+	var parts []dto.GeminiPart
+	var finishReason *string
+	switch claudeResponse.Type {
+	case "content_block_start":
+		if claudeResponse.ContentBlock == nil {
+			return nil
+		}
+		s.blockType = claudeResponse.ContentBlock.Type
+		switch s.blockType {
+		case "text":
+			if text := claudeResponse.ContentBlock.GetText(); text != "" {
+				parts = append(parts, dto.GeminiPart{Text: text})
+			}
+		case "thinking":
+			s.thinking.Reset()
+			s.signature.Reset()
+		case "tool_use":
+			s.toolName = claudeResponse.ContentBlock.Name
+			s.toolArgs.Reset()
+		}
+	case "content_block_delta":
+		if claudeResponse.Delta == nil {
+			return nil
+		}
+		switch claudeResponse.Delta.Type {
+		case "text_delta":
+			if text := claudeResponse.Delta.GetText(); text != "" {
+				parts = append(parts, dto.GeminiPart{Text: text})
+			}
+		case "thinking_delta":
+			if claudeResponse.Delta.Thinking != nil {
+				s.thinking.WriteString(*claudeResponse.Delta.Thinking)
+				if s.includeThoughts && *claudeResponse.Delta.Thinking != "" {
+					parts = append(parts, dto.GeminiPart{Text: *claudeResponse.Delta.Thinking, Thought: true})
+				}
+			}
+		case "signature_delta":
+			s.signature.WriteString(claudeResponse.Delta.Signature)
+		case "input_json_delta":
+			if claudeResponse.Delta.PartialJson != nil {
+				s.toolArgs.WriteString(*claudeResponse.Delta.PartialJson)
+			}
+		}
+	case "content_block_stop":
+		switch s.blockType {
+		case "thinking":
+			s.thinkingBlocks = append(s.thinkingBlocks, dto.ClaudeMediaMessage{
+				Type:      "thinking",
+				Thinking:  common.GetPointer[string](s.thinking.String()),
+				Signature: s.signature.String(),
+			})
+		case "tool_use":
+			var input any
+			if args := s.toolArgs.String(); args != "" {
+				if err := common.UnmarshalJsonStr(args, &input); err != nil {
+					input = map[string]any{"arguments": args}
+				}
+			}
+			parts = append(parts, functionCallPart(s.toolName, input, s.thinkingBlocks))
+			s.thinkingBlocks = nil
+		}
+		s.blockType = ""
+	case "message_delta":
+		if claudeResponse.Delta == nil || claudeResponse.Delta.StopReason == nil {
+			return nil
+		}
+		finishReason = common.GetPointer[string](reasonmap.ClaudeStopReasonToGeminiFinishReason(*claudeResponse.Delta.StopReason))
+	default:
+		return nil
+	}
+	if len(parts) == 0 && finishReason == nil {
+		return nil
+	}
+	if parts == nil {
+		parts = make([]dto.GeminiPart, 0)
+	}
+	return &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{
+			Content: dto.GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason:  finishReason,
+			SafetyRatings: []dto.GeminiChatSafetyRating{},
+		}},
+		UsageMetadata: usageToGeminiUsageMetadata(usage),
+	}
+}
@@ -233,8 +233,10 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 			textRequest.Messages[i].Role = "user"
 		}
 		fmtMessage := dto.Message{
-			Role:    message.Role,
-			Content: message.Content,
+			Role:               message.Role,
+			Content:            message.Content,
+			ReasoningContent:   message.ReasoningContent,
+			ReasoningSignature: message.ReasoningSignature,
 		}
 		if message.Role == "tool" {
 			fmtMessage.ToolCallId = message.ToolCallId
@@ -329,10 +331,18 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 						},
 					}
 				}
-			} else if message.IsStringContent() && message.ToolCalls == nil {
+			} else if message.IsStringContent() && message.ToolCalls == nil && message.ReasoningSignature == "" {
 				claudeMessage.Content = message.StringContent()
 			} else {
 				claudeMediaMessages := make([]dto.ClaudeMediaMessage, 0)
+				// 回传带签名的思考块，启用思考的多轮工具调用要求思考块位于助手消息开头
+				if message.Role == "assistant" && message.ReasoningSignature != "" {
+					claudeMediaMessages = append(claudeMediaMessages, dto.ClaudeMediaMessage{
+						Type:      "thinking",
+						Thinking:  common.GetPointer[string](message.ReasoningContent),
+						Signature: message.ReasoningSignature,
+					})
+				}
 				for _, mediaMessage := range message.ParseContent() {
 					claudeMediaMessage := dto.ClaudeMediaMessage{
 						Type: mediaMessage.Type,
@@ -447,9 +457,9 @@ func StreamResponseClaude2OpenAI(claudeResponse *dto.ClaudeResponse) *dto.ChatCo
 					},
 				})
 			case "signature_delta":
-				// 加密的不处理
-				signatureContent := "\n"
-				choice.Delta.ReasoningContent = &signatureContent
+				// 签名不计入推理内容，单独下发以便多轮对话回传
+				signature := claudeResponse.Delta.Signature
+				choice.Delta.ReasoningSignature = &signature
 			case "thinking_delta":
 				choice.Delta.ReasoningContent = claudeResponse.Delta.Thinking
 			}
@@ -493,6 +503,7 @@ func ResponseClaude2OpenAI(claudeResponse *dto.ClaudeResponse) *dto.OpenAITextRe
 	}
 	tools := make([]dto.ToolCallResponse, 0)
 	thinkingContent := ""
+	thinkingSignature := ""
 
 	fullTextResponse.Id = claudeResponse.Id
 	for _, message := range claudeResponse.Content {
@@ -508,10 +519,11 @@ func ResponseClaude2OpenAI(claudeResponse *dto.ClaudeResponse) *dto.OpenAITextRe
 				},
 			})
 		case "thinking":
-			// 加密的不管， 只输出明文的推理过程
+			// 只输出明文的推理过程，签名单独保留以便多轮对话回传
 			if message.Thinking != nil {
 				thinkingContent = *message.Thinking
 			}
+			thinkingSignature = message.Signature
 		case "text":
 			responseText = message.GetText()
 		}
@@ -531,6 +543,7 @@ func ResponseClaude2OpenAI(claudeResponse *dto.ClaudeResponse) *dto.OpenAITextRe
 		choice.Message.SetToolCalls(tools)
 	}
 	choice.Message.ReasoningContent = thinkingContent
+	choice.Message.ReasoningSignature = thinkingSignature
 	fullTextResponse.Model = claudeResponse.Model
 	choices = append(choices, choice)
 	fullTextResponse.Choices = choices
@@ -544,6 +557,7 @@ type ClaudeResponseInfo struct {
 	ResponseText strings.Builder
 	Usage        *dto.Usage
 	Done         bool
+	geminiStream *geminiStreamState
 }
 
 func buildMessageDeltaPatchUsage(claudeResponse *dto.ClaudeResponse, claudeInfo *ClaudeResponseInfo) *dto.ClaudeUsage {
@@ -727,6 +741,30 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 			return nil
 		}
 
+		err = helper.ObjectData(c, response)
+		if err != nil {
+			logger.LogError(c, "send_stream_response_failed: "+err.Error())
+		}
+	} else if info.RelayFormat == types.RelayFormatOpenAIResponses {
+		response := StreamResponseClaude2OpenAI(&claudeResponse)
+
+		if !FormatClaudeResponseInfo(&claudeResponse, response, claudeInfo) || response == nil {
+			return nil
+		}
+
+		err = helper.ResponsesData(c, info.GetResponsesStreamState().Convert(response))
+		if err != nil {
+			logger.LogError(c, "send_stream_response_failed: "+err.Error())
+		}
+	} else if info.RelayFormat == types.RelayFormatGemini {
+		FormatClaudeResponseInfo(&claudeResponse, nil, claudeInfo)
+		if claudeInfo.geminiStream == nil {
+			claudeInfo.geminiStream = newGeminiStreamState(service.GeminiIncludeThoughts(info))
+		}
+		response := claudeInfo.geminiStream.Convert(&claudeResponse, claudeInfo.Usage)
+		if response == nil {
+			return nil
+		}
 		err = helper.ObjectData(c, response)
 		if err != nil {
 			logger.LogError(c, "send_stream_response_failed: "+err.Error())
@@ -757,6 +795,11 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 			}
 		}
 		helper.Done(c)
+	} else if info.RelayFormat == types.RelayFormatOpenAIResponses {
+		err := helper.ResponsesData(c, info.GetResponsesStreamState().Finish(claudeInfo.Usage))
+		if err != nil {
+			common.SysLog("send final response failed: " + err.Error())
+		}
 	}
 }
 
@@ -817,6 +860,17 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		}
 	case types.RelayFormatClaude:
 		responseData = data
+	case types.RelayFormatOpenAIResponses:
+		openaiResponse := ResponseClaude2OpenAI(&claudeResponse)
+		responseData, err = json.Marshal(service.ChatCompletionsResponseToResponsesResponse(openaiResponse, claudeInfo.Usage))
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+	case types.RelayFormatGemini:
+		responseData, err = json.Marshal(ResponseClaude2Gemini(&claudeResponse, claudeInfo.Usage, service.GeminiIncludeThoughts(info)))
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
 	}
 
 	if claudeResponse.Usage != nil && claudeResponse.Usage.ServerToolUse != nil && claudeResponse.Usage.ServerToolUse.WebSearchRequests > 0 {
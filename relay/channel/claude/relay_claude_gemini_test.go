@@ -0,0 +1,126 @@
+package claude
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+func TestRequestGemini2ClaudeMessage(t *testing.T) {
+	var geminiRequest dto.GeminiChatRequest
+	err := common.Unmarshal([]byte(`{
+		"systemInstruction": {"parts": [{"text": "be brief"}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "weather?"}, {"inlineData": {"mimeType": "image/png", "data": "aGVsbG8="}}]},
+			{"role": "model", "parts": [{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"name": "get_weather", "response": {"result": "sunny"}}}]}
+		],
+		"tools": [{"functionDeclarations": [{"name": "get_weather", "parameters": {"type": "OBJECT", "properties": {"city": {"type": "STRING"}}}}]}],
+		"generationConfig": {"maxOutputTokens": 2000, "temperature": 1.5, "thinkingConfig": {"thinkingBudget": 4096, "includeThoughts": true}}
+	}`), &geminiRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &relaycommon.RelayInfo{
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "claude-sonnet-4"},
+	}
+
+	claudeRequest, err := RequestGemini2ClaudeMessage(nil, &geminiRequest, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claudeRequest.Model != "claude-sonnet-4" {
+		t.Errorf("Model = %s, want claude-sonnet-4", claudeRequest.Model)
+	}
+	if len(claudeRequest.Messages) != 3 {
+		t.Fatalf("len(Messages) = %d, want 3", len(claudeRequest.Messages))
+	}
+	userBlocks := claudeRequest.Messages[0].Content.([]dto.ClaudeMediaMessage)
+	if len(userBlocks) != 2 || userBlocks[1].Type != "image" || userBlocks[1].Source.MediaType != "image/png" {
+		t.Errorf("unexpected user blocks: %+v", userBlocks)
+	}
+	toolUse := claudeRequest.Messages[1].Content.([]dto.ClaudeMediaMessage)[0]
+	toolResult := claudeRequest.Messages[2].Content.([]dto.ClaudeMediaMessage)[0]
+	if toolUse.Type != "tool_use" || toolResult.Type != "tool_result" || toolUse.Id != toolResult.ToolUseId {
+		t.Errorf("tool_use %+v does not match tool_result %+v", toolUse, toolResult)
+	}
+
+	// 思考预算超过 max_tokens 时按比例收缩，且强制 temperature 为 1
+	if claudeRequest.Thinking == nil || claudeRequest.Thinking.GetBudgetTokens() >= int(claudeRequest.MaxTokens) {
+		t.Errorf("unexpected thinking %+v with max_tokens %d", claudeRequest.Thinking, claudeRequest.MaxTokens)
+	}
+	if *claudeRequest.Temperature != 1 {
+		t.Errorf("Temperature = %v, want 1", *claudeRequest.Temperature)
+	}
+
+	tools := claudeRequest.Tools.([]any)
+	schema := tools[0].(*dto.Tool).InputSchema
+	if schema["type"] != "object" {
+		t.Errorf("schema type = %v, want object", schema["type"])
+	}
+	city := schema["properties"].(map[string]any)["city"].(map[string]any)
+	if city["type"] != "string" {
+		t.Errorf("city type = %v, want string", city["type"])
+	}
+}
+
+func TestGeminiStreamStateConvertsClaudeEvents(t *testing.T) {
+	state := newGeminiStreamState(true)
+	usage := &dto.Usage{PromptTokens: 10, CompletionTokens: 5}
+	index := func(i int) *int { return &i }
+	events := []dto.ClaudeResponse{
+		{Type: "message_start"},
+		{Type: "content_block_start", Index: index(0), ContentBlock: &dto.ClaudeMediaMessage{Type: "thinking"}},
+		{Type: "content_block_delta", Index: index(0), Delta: &dto.ClaudeMediaMessage{Type: "thinking_delta", Thinking: common.GetPointer("hmm")}},
+		{Type: "content_block_delta", Index: index(0), Delta: &dto.ClaudeMediaMessage{Type: "signature_delta", Signature: "sig"}},
+		{Type: "content_block_stop", Index: index(0)},
+		{Type: "content_block_start", Index: index(1), ContentBlock: &dto.ClaudeMediaMessage{Type: "text"}},
+		{Type: "content_block_delta", Index: index(1), Delta: &dto.ClaudeMediaMessage{Type: "text_delta", Text: common.GetPointer("Hi")}},
+		{Type: "content_block_stop", Index: index(1)},
+		{Type: "content_block_start", Index: index(2), ContentBlock: &dto.ClaudeMediaMessage{Type: "tool_use", Id: "toolu_1", Name: "get_weather"}},
+		{Type: "content_block_delta", Index: index(2), Delta: &dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: common.GetPointer(`{"city":`)}},
+		{Type: "content_block_delta", Index: index(2), Delta: &dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: common.GetPointer(`"Paris"}`)}},
+		{Type: "content_block_stop", Index: index(2)},
+		{Type: "message_delta", Delta: &dto.ClaudeMediaMessage{StopReason: common.GetPointer("tool_use")}},
+		{Type: "message_stop"},
+	}
+
+	var parts []dto.GeminiPart
+	var finishReason string
+	for i := range events {
+		response := state.Convert(&events[i], usage)
+		if response == nil {
+			continue
+		}
+		candidate := response.Candidates[0]
+		parts = append(parts, candidate.Content.Parts...)
+		if candidate.FinishReason != nil {
+			finishReason = *candidate.FinishReason
+		}
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("len(parts) = %d, want 3", len(parts))
+	}
+	if !parts[0].Thought || parts[0].Text != "hmm" {
+		t.Errorf("unexpected thought part %+v", parts[0])
+	}
+	if parts[1].Text != "Hi" {
+		t.Errorf("unexpected text part %+v", parts[1])
+	}
+	call := parts[2].FunctionCall
+	if call == nil || call.FunctionName != "get_weather" || call.Arguments.(map[string]any)["city"] != "Paris" {
+		t.Errorf("unexpected function call %+v", call)
+	}
+	if finishReason != "STOP" {
+		t.Errorf("finishReason = %s, want STOP", finishReason)
+	}
+
+	// 思考块随 thoughtSignature 回传后还原到助手消息开头
+	blocks := decodeClaudeThinkingSignature(parts[2].ThoughtSignature)
+	if len(blocks) != 1 || *blocks[0].Thinking != "hmm" || blocks[0].Signature != "sig" {
+		t.Errorf("unexpected thinking blocks %+v", blocks)
+	}
+}
@@ -0,0 +1,106 @@
+package claude
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testThinking  = "The user wants the weather, call the tool."
+	testSignature = "EqoBCkgIARABGAIiQL2"
+)
+
+// nextTurnClaudeRequest 将上一轮 Responses 输出与工具结果作为下一轮输入，经兼容层转换后再转为 Claude 请求
+func nextTurnClaudeRequest(t *testing.T, output []dto.ResponsesOutput) *dto.ClaudeRequest {
+	t.Helper()
+	input := []any{map[string]any{"type": "message", "role": "user", "content": "weather in Paris?"}}
+	for _, item := range output {
+		input = append(input, item)
+	}
+	input = append(input, map[string]any{"type": "function_call_output", "call_id": "toolu_1", "output": "sunny"})
+	raw, err := common.Marshal(input)
+	require.NoError(t, err)
+
+	chatRequest, err := openaicompat.ResponsesRequestToChatCompletionsRequest(&dto.OpenAIResponsesRequest{
+		Model: "claude-sonnet-4-5",
+		Input: raw,
+	})
+	require.NoError(t, err)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	claudeRequest, err := RequestOpenAI2ClaudeMessage(c, *chatRequest)
+	require.NoError(t, err)
+	return claudeRequest
+}
+
+func assertThinkingReplayed(t *testing.T, claudeRequest *dto.ClaudeRequest) {
+	t.Helper()
+	require.Len(t, claudeRequest.Messages, 3)
+	assistant := claudeRequest.Messages[1]
+	assert.Equal(t, "assistant", assistant.Role)
+	blocks, ok := assistant.Content.([]dto.ClaudeMediaMessage)
+	require.True(t, ok)
+	require.NotEmpty(t, blocks)
+	assert.Equal(t, "thinking", blocks[0].Type)
+	require.NotNil(t, blocks[0].Thinking)
+	assert.Equal(t, testThinking, *blocks[0].Thinking)
+	assert.Equal(t, testSignature, blocks[0].Signature)
+	last := blocks[len(blocks)-1]
+	assert.Equal(t, "tool_use", last.Type)
+	assert.Equal(t, "toolu_1", last.Id)
+}
+
+func TestResponsesReasoningRoundTrip_NonStream(t *testing.T) {
+	claudeResponse := &dto.ClaudeResponse{
+		Id:         "msg_1",
+		Model:      "claude-sonnet-4-5",
+		StopReason: "tool_use",
+		Content: []dto.ClaudeMediaMessage{
+			{Type: "thinking", Thinking: common.GetPointer(testThinking), Signature: testSignature},
+			{Type: "tool_use", Id: "toolu_1", Name: "get_weather", Input: map[string]any{"city": "Paris"}},
+		},
+	}
+	resp := openaicompat.ChatCompletionsResponseToResponsesResponse(ResponseClaude2OpenAI(claudeResponse), &dto.Usage{})
+	require.Len(t, resp.Output, 2)
+	assert.Equal(t, "reasoning", resp.Output[0].Type)
+	assert.Equal(t, testSignature, resp.Output[0].EncryptedContent)
+
+	assertThinkingReplayed(t, nextTurnClaudeRequest(t, resp.Output))
+}
+
+func TestResponsesReasoningRoundTrip_Stream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"The user wants the weather, "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"call the tool."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"` + testSignature + `"}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":\"Paris\"}"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+	}
+	state := openaicompat.NewResponsesStreamState("", "", 0)
+	for _, event := range events {
+		var claudeResponse dto.ClaudeResponse
+		require.NoError(t, common.Unmarshal([]byte(event), &claudeResponse))
+		if chunk := StreamResponseClaude2OpenAI(&claudeResponse); chunk != nil {
+			state.Convert(chunk)
+		}
+	}
+	final := state.Finish(nil)
+	require.NotEmpty(t, final)
+	output := final[len(final)-1].Response.Output
+	require.Len(t, output, 2)
+	assert.Equal(t, "reasoning", output[0].Type)
+	assert.Equal(t, testSignature, output[0].EncryptedContent)
+	require.Len(t, output[0].Summary, 1)
+	assert.Equal(t, testThinking, output[0].Summary[0].Text)
+
+	assertThinkingReplayed(t, nextTurnClaudeRequest(t, output))
+}
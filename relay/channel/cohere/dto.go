@@ -1,7 +1,5 @@
 package cohere
 
-import "github.com/QuantumNous/new-api/dto"
-
 type CohereRequest struct {
 	Model       string        `json:"model"`
 	ChatHistory []ChatHistory `json:"chat_history"`
@@ -31,19 +29,6 @@ type CohereResponseResult struct {
 	Meta         CohereMeta `json:"meta"`
 }
 
-type CohereRerankRequest struct {
-	Documents       []any  `json:"documents"`
-	Query           string `json:"query"`
-	Model           string `json:"model"`
-	TopN            int    `json:"top_n"`
-	ReturnDocuments bool   `json:"return_documents"`
-}
-
-type CohereRerankResponseResult struct {
-	Results []dto.RerankResponseResult `json:"results"`
-	Meta    CohereMeta                 `json:"meta"`
-}
-
 type CohereMeta struct {
 	//Tokens CohereTokens `json:"tokens"`
 	BilledUnits CohereBilledUnits `json:"billed_units"`
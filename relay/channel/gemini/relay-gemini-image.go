@@ -0,0 +1,261 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsGeminiImageModel Gemini 原生图片模型（如 gemini-2.5-flash-image），通过 generateContent 生成图片
+func IsGeminiImageModel(modelName string) bool {
+	return strings.HasPrefix(modelName, "gemini") && strings.Contains(modelName, "-image")
+}
+
+// IsImageRelayMode 是否为 /v1/images/generations 或 /v1/images/edits 请求
+func IsImageRelayMode(relayMode int) bool {
+	return relayMode == constant.RelayModeImagesGenerations || relayMode == constant.RelayModeImagesEdits
+}
+
+var geminiAspectRatios = map[string]struct{}{
+	"1:1": {}, "2:3": {}, "3:2": {}, "3:4": {}, "4:3": {}, "4:5": {}, "5:4": {}, "9:16": {}, "16:9": {}, "21:9": {},
+}
+
+// imageSizeToAspectRatio 将 OpenAI size（如 1792x1024）转换为宽高比，允许直接传入宽高比，无法识别时返回空
+func imageSizeToAspectRatio(size string) string {
+	size = strings.TrimSpace(size)
+	if strings.Contains(size, ":") {
+		return size
+	}
+	switch size {
+	case "256x256", "512x512", "1024x1024":
+		return "1:1"
+	case "1536x1024":
+		return "3:2"
+	case "1024x1536":
+		return "2:3"
+	case "1024x1792":
+		return "9:16"
+	case "1792x1024":
+		return "16:9"
+	}
+	parts := strings.Split(size, "x")
+	if len(parts) != 2 {
+		return ""
+	}
+	w, err1 := strconv.Atoi(parts[0])
+	h, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return ""
+	}
+	a, b := w, h
+	for b != 0 {
+		a, b = b, a%b
+	}
+	ratio := fmt.Sprintf("%d:%d", w/a, h/a)
+	if _, ok := geminiAspectRatios[ratio]; ok {
+		return ratio
+	}
+	return ""
+}
+
+// imagenImageSize 将 quality 映射为 Imagen 的 imageSize（仅 Standard 与 Ultra 模型支持）
+// quality values: auto, high, medium, low (for gpt-image-1), hd, standard (for dall-e-3)
+// imageSize values: 1K (default), 2K
+// https://ai.google.dev/gemini-api/docs/imagen
+func imagenImageSize(quality string) string {
+	switch quality {
+	case "":
+		return ""
+	case "hd", "high", "2K":
+		return "2K"
+	default:
+		return "1K"
+	}
+}
+
+// ConvertImagenRequest 转换为 Imagen predict 请求，编辑请求仅 Vertex AI 的 Imagen 编辑模型支持
+func ConvertImagenRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*dto.GeminiImageRequest, error) {
+	aspectRatio := imageSizeToAspectRatio(request.Size)
+	if aspectRatio == "" {
+		aspectRatio = "1:1"
+	}
+	imagenRequest := &dto.GeminiImageRequest{
+		Instances: []dto.GeminiImageInstance{
+			{
+				Prompt: request.Prompt,
+			},
+		},
+		Parameters: dto.GeminiImageParameters{
+			SampleCount:      int(request.N),
+			AspectRatio:      aspectRatio,
+			PersonGeneration: "allow_adult", // default allow adult
+			ImageSize:        imagenImageSize(request.Quality),
+		},
+	}
+	if info.RelayMode != constant.RelayModeImagesEdits {
+		return imagenRequest, nil
+	}
+
+	image, mask, err := service.GetImageEditInputs(c, &request)
+	if err != nil {
+		return nil, err
+	}
+	if mask == nil {
+		return nil, errors.New("mask is required for imagen image edits")
+	}
+	// Imagen 蒙版中白色为待编辑区域
+	mask, err = service.ConvertImageMask(mask, true)
+	if err != nil {
+		return nil, err
+	}
+	imagenRequest.Instances[0].ReferenceImages = []dto.GeminiImageReference{
+		{
+			ReferenceType:  "REFERENCE_TYPE_RAW",
+			ReferenceId:    1,
+			ReferenceImage: dto.GeminiImageBytes{BytesBase64Encoded: image.Data},
+		},
+		{
+			ReferenceType:   "REFERENCE_TYPE_MASK",
+			ReferenceId:     2,
+			ReferenceImage:  dto.GeminiImageBytes{BytesBase64Encoded: mask.Data},
+			MaskImageConfig: &dto.GeminiMaskImageConfig{MaskMode: "MASK_MODE_USER_PROVIDED"},
+		},
+	}
+	imagenRequest.Parameters.EditMode = "EDIT_MODE_INPAINT_INSERTION"
+	// 编辑模式下输出尺寸跟随原图
+	imagenRequest.Parameters.AspectRatio = ""
+	imagenRequest.Parameters.ImageSize = ""
+	return imagenRequest, nil
+}
+
+// ConvertGeminiImageRequest 将 OpenAI Images 请求转换为 Gemini 原生图片模型的 generateContent 请求
+func ConvertGeminiImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*dto.GeminiChatRequest, error) {
+	parts := []dto.GeminiPart{{Text: request.Prompt}}
+	if info.RelayMode == constant.RelayModeImagesEdits {
+		image, mask, err := service.GetImageEditInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, dto.GeminiPart{
+			InlineData: &dto.GeminiInlineData{MimeType: image.MimeType, Data: image.Data},
+		})
+		if mask != nil {
+			parts[0].Text = request.Prompt + "\n\nThe second image is a mask: only modify the regions of the first image that are transparent in the mask, keep everything else unchanged."
+			parts = append(parts, dto.GeminiPart{
+				InlineData: &dto.GeminiInlineData{MimeType: mask.MimeType, Data: mask.Data},
+			})
+		}
+	}
+
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: parts,
+			},
+		},
+	}
+	geminiRequest.GenerationConfig.ResponseModalities = []string{"TEXT", "IMAGE"}
+	if request.N > 1 {
+		geminiRequest.GenerationConfig.CandidateCount = int(request.N)
+	}
+
+	imageConfig := make(map[string]string)
+	if aspectRatio := imageSizeToAspectRatio(request.Size); aspectRatio != "" {
+		imageConfig["aspectRatio"] = aspectRatio
+	}
+	switch {
+	case request.Size == "1K" || request.Size == "2K" || request.Size == "4K":
+		imageConfig["imageSize"] = request.Size
+	case request.Quality == "hd" || request.Quality == "high":
+		imageConfig["imageSize"] = "2K"
+	}
+	if len(imageConfig) > 0 {
+		imageConfigBytes, err := common.Marshal(imageConfig)
+		if err != nil {
+			return nil, err
+		}
+		geminiRequest.GenerationConfig.ImageConfig = imageConfigBytes
+	}
+	return geminiRequest, nil
+}
+
+// writeImageResponse 按请求的 response_format 处理后写回 OpenAI Images 响应
+func writeImageResponse(c *gin.Context, info *relaycommon.RelayInfo, statusCode int, imageResponse *dto.ImageResponse) *types.NewAPIError {
+	if err := service.ApplyImageResponseFormat(info, imageResponse); err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	jsonResponse, err := common.Marshal(imageResponse)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(statusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+	return nil
+}
+
+// GeminiImageGenerateContentHandler 将 Gemini 原生图片模型的 generateContent 响应转换为 OpenAI Images 响应
+func GeminiImageGenerateContentHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	imageResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	var revisedPrompt strings.Builder
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				imageResponse.Data = append(imageResponse.Data, dto.ImageData{B64Json: part.InlineData.Data})
+			} else if part.Text != "" && !part.Thought {
+				revisedPrompt.WriteString(part.Text)
+			}
+		}
+	}
+	if len(imageResponse.Data) == 0 {
+		message := "no images generated"
+		if geminiResponse.PromptFeedback != nil && geminiResponse.PromptFeedback.BlockReason != nil {
+			message = fmt.Sprintf("no images generated, block reason: %s", *geminiResponse.PromptFeedback.BlockReason)
+		} else if revisedPrompt.Len() > 0 {
+			message = fmt.Sprintf("no images generated: %s", revisedPrompt.String())
+		}
+		return nil, types.NewOpenAIError(errors.New(message), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	imageResponse.Data[0].RevisedPrompt = revisedPrompt.String()
+
+	service.SetImageCountRatio(info, len(imageResponse.Data))
+	if newAPIError := writeImageResponse(c, info, resp.StatusCode, &imageResponse); newAPIError != nil {
+		return nil, newAPIError
+	}
+
+	metadata := geminiResponse.UsageMetadata
+	usage := &dto.Usage{
+		PromptTokens:     metadata.PromptTokenCount,
+		CompletionTokens: metadata.CandidatesTokenCount + metadata.ThoughtsTokenCount,
+		TotalTokens:      metadata.TotalTokenCount,
+	}
+	usage.CompletionTokenDetails.ReasoningTokens = metadata.ThoughtsTokenCount
+	return usage, nil
+}
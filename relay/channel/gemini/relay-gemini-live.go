@@ -0,0 +1,907 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// OpenAI Realtime 的 pcm16 固定为 24kHz 单声道，Gemini Live 输出同为 24kHz
+	geminiLiveInputAudioMimeType = "audio/pcm;rate=24000"
+	geminiLiveSetupTimeout       = 15 * time.Second
+	geminiLiveDefaultVoice       = "Puck"
+)
+
+var geminiLiveVoices = map[string]bool{
+	"Puck":   true,
+	"Charon": true,
+	"Kore":   true,
+	"Fenrir": true,
+	"Aoede":  true,
+	"Leda":   true,
+	"Orus":   true,
+	"Zephyr": true,
+}
+
+// geminiLiveResponse 对应一次 OpenAI Realtime response 的生命周期
+type geminiLiveResponse struct {
+	id          string
+	itemId      string
+	contentType string
+	text        strings.Builder
+	transcript  strings.Builder
+	output      []dto.RealtimeItem
+}
+
+// geminiLiveBridge 在 OpenAI Realtime 客户端与 Gemini Live 上游之间转换事件
+type geminiLiveBridge struct {
+	c          *gin.Context
+	info       *relaycommon.RelayInfo
+	clientConn *websocket.Conn
+	targetConn *websocket.Conn
+	// 两个读协程都会写客户端连接
+	clientMu sync.Mutex
+
+	mu              sync.Mutex
+	session         dto.RealtimeSession
+	manualVAD       bool
+	activityStarted bool
+	pendingTurn     bool
+	callNames       map[string]string
+	response        *geminiLiveResponse
+	inputItemId     string
+	inputTranscript strings.Builder
+
+	usage      *dto.RealtimeUsage
+	localUsage *dto.RealtimeUsage
+	sumUsage   *dto.RealtimeUsage
+}
+
+func defaultGeminiLiveSession() dto.RealtimeSession {
+	return dto.RealtimeSession{
+		Modalities:        []string{"text", "audio"},
+		Voice:             geminiLiveDefaultVoice,
+		InputAudioFormat:  "pcm16",
+		OutputAudioFormat: "pcm16",
+		TurnDetection:     map[string]any{"type": "server_vad"},
+		Tools:             []dto.RealTimeTool{},
+	}
+}
+
+// GeminiLiveRealtimeHandler 将 /v1/realtime 会话桥接到 Gemini Live (BidiGenerateContent)
+func GeminiLiveRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.RealtimeUsage) {
+	if info == nil || info.ClientWs == nil || info.TargetWs == nil {
+		return types.NewError(fmt.Errorf("invalid websocket connection"), types.ErrorCodeBadResponse), nil
+	}
+	info.IsStream = true
+
+	b := &geminiLiveBridge{
+		c:          c,
+		info:       info,
+		clientConn: info.ClientWs,
+		targetConn: info.TargetWs,
+		session:    defaultGeminiLiveSession(),
+		callNames:  make(map[string]string),
+		usage:      &dto.RealtimeUsage{},
+		localUsage: &dto.RealtimeUsage{},
+		sumUsage:   &dto.RealtimeUsage{},
+	}
+
+	if err := b.sendClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionCreated, Session: &b.session}); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponse), nil
+	}
+
+	// Gemini Live 的会话配置只能在 setup 中一次性下发，因此以客户端第一条事件为准
+	_, firstMessage, err := b.clientConn.ReadMessage()
+	if err != nil {
+		return nil, b.sumUsage
+	}
+	firstEvent := &dto.RealtimeEvent{}
+	if err := common.Unmarshal(firstMessage, firstEvent); err != nil {
+		return types.NewError(fmt.Errorf("error unmarshalling message: %v", err), types.ErrorCodeBadRequestBody, types.ErrOptionWithSkipRetry()), nil
+	}
+	if firstEvent.Type == dto.RealtimeEventTypeSessionUpdate && firstEvent.Session != nil {
+		b.mergeSession(firstEvent.Session, firstMessage)
+	}
+	if err := b.setup(); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponse), nil
+	}
+	if firstEvent.Type == dto.RealtimeEventTypeSessionUpdate {
+		b.countInput(firstEvent)
+		if firstEvent.Session != nil && !isGeminiLiveAudioFormat(firstEvent.Session.InputAudioFormat, firstEvent.Session.OutputAudioFormat) {
+			_ = b.sendClientError("unsupported_audio_format", "gemini live only supports pcm16 audio, falling back to pcm16")
+		}
+		if err := b.sendClient(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdated, Session: &b.session}); err != nil {
+			return nil, b.sumUsage
+		}
+	} else if err := b.handleClientEvent(firstEvent); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponse), nil
+	}
+
+	clientClosed := make(chan struct{})
+	targetClosed := make(chan struct{})
+	errChan := make(chan error, 2)
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in client reader: %v", r)
+			}
+		}()
+		for {
+			select {
+			case <-c.Done():
+				return
+			default:
+				_, message, err := b.clientConn.ReadMessage()
+				if err != nil {
+					if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+						errChan <- fmt.Errorf("error reading from client: %v", err)
+					}
+					close(clientClosed)
+					return
+				}
+				realtimeEvent := &dto.RealtimeEvent{}
+				if err := common.Unmarshal(message, realtimeEvent); err != nil {
+					errChan <- fmt.Errorf("error unmarshalling message: %v", err)
+					return
+				}
+				if err := b.handleClientEvent(realtimeEvent); err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}
+	})
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in target reader: %v", r)
+			}
+		}()
+		for {
+			select {
+			case <-c.Done():
+				return
+			default:
+				_, message, err := b.targetConn.ReadMessage()
+				if err != nil {
+					if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+						errChan <- fmt.Errorf("error reading from target: %v", err)
+					}
+					close(targetClosed)
+					return
+				}
+				info.SetFirstResponseTime()
+				serverMessage := &dto.GeminiLiveServerMessage{}
+				if err := common.Unmarshal(message, serverMessage); err != nil {
+					errChan <- fmt.Errorf("error unmarshalling message: %v", err)
+					return
+				}
+				if err := b.handleServerMessage(serverMessage); err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}
+	})
+
+	select {
+	case <-clientClosed:
+	case <-targetClosed:
+	case err := <-errChan:
+		logger.LogError(c, "gemini live realtime error: "+err.Error())
+	case <-c.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.usage.TotalTokens != 0 {
+		_ = openai.PreConsumeRealtimeUsage(c, info, b.usage, b.sumUsage)
+	} else if b.localUsage.TotalTokens != 0 {
+		_ = openai.PreConsumeRealtimeUsage(c, info, b.localUsage, b.sumUsage)
+	}
+	return nil, b.sumUsage
+}
+
+// mergeSession 合并客户端 session.update 中给出的字段
+func (b *geminiLiveBridge) mergeSession(session *dto.RealtimeSession, raw []byte) {
+	if len(session.Modalities) > 0 {
+		b.session.Modalities = session.Modalities
+	}
+	if session.Instructions != "" {
+		b.session.Instructions = session.Instructions
+	}
+	if geminiLiveVoices[session.Voice] {
+		b.session.Voice = session.Voice
+	}
+	if session.InputAudioTranscription.Model != "" {
+		b.session.InputAudioTranscription = session.InputAudioTranscription
+	}
+	// turn_detection 显式为 null 表示由客户端手动提交音频
+	if turnDetection := gjson.GetBytes(raw, "session.turn_detection"); turnDetection.Exists() {
+		b.session.TurnDetection = session.TurnDetection
+		b.manualVAD = turnDetection.Type == gjson.Null
+	}
+	if session.Tools != nil {
+		b.session.Tools = session.Tools
+		b.info.RealtimeTools = session.Tools
+	}
+	if session.ToolChoice != "" {
+		b.session.ToolChoice = session.ToolChoice
+	}
+	if session.Temperature != 0 {
+		b.session.Temperature = session.Temperature
+	}
+}
+
+// isGeminiLiveAudioFormat Gemini Live 仅支持 pcm16，其它格式忽略
+func isGeminiLiveAudioFormat(formats ...string) bool {
+	for _, format := range formats {
+		if format != "" && format != "pcm16" {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *geminiLiveBridge) isAudio() bool {
+	for _, modality := range b.session.Modalities {
+		if modality == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *geminiLiveBridge) buildSetup() *dto.GeminiLiveSetup {
+	setup := &dto.GeminiLiveSetup{
+		Model:            "models/" + b.info.UpstreamModelName,
+		GenerationConfig: &dto.GeminiLiveGenerationConfig{},
+	}
+	if b.isAudio() {
+		setup.GenerationConfig.ResponseModalities = []string{"AUDIO"}
+		setup.GenerationConfig.SpeechConfig = &dto.GeminiLiveSpeechConfig{}
+		setup.GenerationConfig.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName = b.session.Voice
+		setup.OutputAudioTranscription = &struct{}{}
+	} else {
+		setup.GenerationConfig.ResponseModalities = []string{"TEXT"}
+	}
+	if b.session.Temperature != 0 {
+		setup.GenerationConfig.Temperature = common.GetPointer(b.session.Temperature)
+	}
+	if b.session.Instructions != "" {
+		setup.SystemInstruction = &dto.GeminiChatContent{
+			Parts: []dto.GeminiPart{{Text: b.session.Instructions}},
+		}
+	}
+	if b.session.InputAudioTranscription.Model != "" {
+		setup.InputAudioTranscription = &struct{}{}
+	}
+	if b.manualVAD {
+		setup.RealtimeInputConfig = &dto.GeminiLiveRealtimeInputConfig{
+			AutomaticActivityDetection: &dto.GeminiLiveActivityDetection{Disabled: true},
+		}
+	}
+	if b.session.ToolChoice != "none" && len(b.session.Tools) > 0 {
+		functions := make([]dto.FunctionRequest, 0, len(b.session.Tools))
+		for _, tool := range b.session.Tools {
+			functions = append(functions, dto.FunctionRequest{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  cleanFunctionParameters(tool.Parameters),
+			})
+		}
+		setup.Tools = []dto.GeminiChatTool{{FunctionDeclarations: functions}}
+	}
+	return setup
+}
+
+// setup 下发会话配置并等待上游 setupComplete
+func (b *geminiLiveBridge) setup() error {
+	if err := helper.WssObject(b.c, b.targetConn, &dto.GeminiLiveClientMessage{Setup: b.buildSetup()}); err != nil {
+		return fmt.Errorf("error writing setup to target: %w", err)
+	}
+	_ = b.targetConn.SetReadDeadline(time.Now().Add(geminiLiveSetupTimeout))
+	defer b.targetConn.SetReadDeadline(time.Time{})
+	for {
+		_, message, err := b.targetConn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("error waiting for gemini live setup: %w", err)
+		}
+		serverMessage := &dto.GeminiLiveServerMessage{}
+		if err := common.Unmarshal(message, serverMessage); err != nil {
+			return fmt.Errorf("error unmarshalling setup response: %w", err)
+		}
+		if serverMessage.SetupComplete != nil {
+			return nil
+		}
+	}
+}
+
+func (b *geminiLiveBridge) sendClient(event *dto.RealtimeEvent) error {
+	if event.EventId == "" {
+		event.EventId = "event_" + common.GetUUID()
+	}
+	b.clientMu.Lock()
+	defer b.clientMu.Unlock()
+	return helper.WssObject(b.c, b.clientConn, event)
+}
+
+func (b *geminiLiveBridge) sendClientError(code string, message string) error {
+	return b.sendClient(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeError,
+		Error: &types.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+func (b *geminiLiveBridge) sendTarget(message *dto.GeminiLiveClientMessage) error {
+	if err := helper.WssObject(b.c, b.targetConn, message); err != nil {
+		return fmt.Errorf("error writing to target: %w", err)
+	}
+	return nil
+}
+
+func (b *geminiLiveBridge) countInput(event *dto.RealtimeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.countLocal(event, false)
+}
+
+// countLocal 本地估算 token 用量，调用方需持有 b.mu
+func (b *geminiLiveBridge) countLocal(event *dto.RealtimeEvent, output bool) {
+	textToken, audioToken, err := service.CountTokenRealtime(b.info, *event, b.info.UpstreamModelName)
+	if err != nil {
+		logger.LogError(b.c, fmt.Sprintf("error counting realtime token: %v", err))
+		return
+	}
+	b.localUsage.TotalTokens += textToken + audioToken
+	if output {
+		b.localUsage.OutputTokens += textToken + audioToken
+		b.localUsage.OutputTokenDetails.TextTokens += textToken
+		b.localUsage.OutputTokenDetails.AudioTokens += audioToken
+	} else {
+		b.localUsage.InputTokens += textToken + audioToken
+		b.localUsage.InputTokenDetails.TextTokens += textToken
+		b.localUsage.InputTokenDetails.AudioTokens += audioToken
+	}
+}
+
+func (b *geminiLiveBridge) handleClientEvent(event *dto.RealtimeEvent) error {
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		return b.sendClientError("session_update_not_supported", "gemini live does not support updating the session after it has started")
+	case dto.RealtimeEventInputAudioBufferAppend:
+		b.countInput(event)
+		b.mu.Lock()
+		startActivity := b.manualVAD && !b.activityStarted
+		b.activityStarted = true
+		b.mu.Unlock()
+		if startActivity {
+			if err := b.sendTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{ActivityStart: &struct{}{}}}); err != nil {
+				return err
+			}
+		}
+		return b.sendTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{
+			Audio: &dto.GeminiInlineData{MimeType: geminiLiveInputAudioMimeType, Data: event.Audio},
+		}})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		b.mu.Lock()
+		endActivity := b.manualVAD && b.activityStarted
+		b.activityStarted = false
+		b.mu.Unlock()
+		input := &dto.GeminiLiveRealtimeInput{AudioStreamEnd: true}
+		if b.manualVAD {
+			if !endActivity {
+				return b.sendClientError("input_audio_buffer_commit_empty", "input audio buffer is empty")
+			}
+			input = &dto.GeminiLiveRealtimeInput{ActivityEnd: &struct{}{}}
+		}
+		if err := b.sendTarget(&dto.GeminiLiveClientMessage{RealtimeInput: input}); err != nil {
+			return err
+		}
+		return b.sendClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: "item_" + common.GetUUID()})
+	case dto.RealtimeEventInputAudioBufferClear:
+		// Gemini Live 无法撤回已发送的音频，仅回执
+		return b.sendClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventTypeConversationCreate:
+		return b.handleConversationItem(event.Item)
+	case dto.RealtimeEventTypeResponseCreate:
+		b.mu.Lock()
+		pendingTurn := b.pendingTurn
+		b.pendingTurn = false
+		b.mu.Unlock()
+		if !pendingTurn {
+			// 音频输入由上游的活动检测或 activityEnd 触发生成
+			return nil
+		}
+		return b.sendTarget(&dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{TurnComplete: true}})
+	case dto.RealtimeEventTypeResponseCancel:
+		// Gemini Live 没有取消生成的消息，用户开口说话时上游会自行打断
+		return nil
+	default:
+		return b.sendClientError("unsupported_event", fmt.Sprintf("event type %s is not supported by gemini live", event.Type))
+	}
+}
+
+func (b *geminiLiveBridge) handleConversationItem(item *dto.RealtimeItem) error {
+	if item == nil {
+		return b.sendClientError("invalid_item", "item is required")
+	}
+	if item.Id == "" {
+		item.Id = "item_" + common.GetUUID()
+	}
+	switch item.Type {
+	case "message":
+		role := "user"
+		if item.Role == "assistant" {
+			role = "model"
+		}
+		parts := make([]dto.GeminiPart, 0, len(item.Content))
+		for _, content := range item.Content {
+			switch content.Type {
+			case "input_text", "text":
+				parts = append(parts, dto.GeminiPart{Text: content.Text})
+			case "input_audio", "audio":
+				if content.Audio != "" {
+					parts = append(parts, dto.GeminiPart{InlineData: &dto.GeminiInlineData{MimeType: geminiLiveInputAudioMimeType, Data: content.Audio}})
+				} else if content.Transcript != "" {
+					parts = append(parts, dto.GeminiPart{Text: content.Transcript})
+				}
+			}
+		}
+		if len(parts) == 0 {
+			return b.sendClientError("invalid_item", "message item has no supported content")
+		}
+		err := b.sendTarget(&dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{
+			Turns: []dto.GeminiChatContent{{Role: role, Parts: parts}},
+		}})
+		if err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.pendingTurn = true
+		b.mu.Unlock()
+	case "function_call_output":
+		b.mu.Lock()
+		name := b.callNames[item.CallId]
+		b.mu.Unlock()
+		var response map[string]any
+		if err := common.UnmarshalJsonStr(item.Output, &response); err != nil || response == nil {
+			response = map[string]any{"output": item.Output}
+		}
+		err := b.sendTarget(&dto.GeminiLiveClientMessage{ToolResponse: &dto.GeminiLiveToolResponse{
+			FunctionResponses: []dto.GeminiLiveFunctionResponse{{Id: item.CallId, Name: name, Response: response}},
+		}})
+		if err != nil {
+			return err
+		}
+	default:
+		return b.sendClientError("invalid_item", fmt.Sprintf("item type %s is not supported by gemini live", item.Type))
+	}
+	created := &dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: item}
+	b.countInput(created)
+	return b.sendClient(created)
+}
+
+func (b *geminiLiveBridge) handleServerMessage(message *dto.GeminiLiveServerMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if message.GoAway != nil {
+		logger.LogWarn(b.c, "gemini live goAway received, time left: "+message.GoAway.TimeLeft)
+	}
+	if message.UsageMetadata != nil {
+		b.addUpstreamUsage(message.UsageMetadata)
+	}
+	if content := message.ServerContent; content != nil {
+		if content.InputTranscription != nil && content.InputTranscription.Text != "" {
+			if b.inputItemId == "" {
+				b.inputItemId = "item_" + common.GetUUID()
+			}
+			b.inputTranscript.WriteString(content.InputTranscription.Text)
+			err := b.sendClient(&dto.RealtimeEvent{
+				Type:         dto.RealtimeEventInputAudioTranscriptionDelta,
+				ItemId:       b.inputItemId,
+				ContentIndex: common.GetPointer(0),
+				Delta:        content.InputTranscription.Text,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if content.Interrupted {
+			if err := b.sendClient(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferSpeechStarted}); err != nil {
+				return err
+			}
+			if err := b.finishResponse("cancelled"); err != nil {
+				return err
+			}
+		}
+		if content.ModelTurn != nil {
+			for _, part := range content.ModelTurn.Parts {
+				if part.Thought {
+					continue
+				}
+				if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+					if err := b.sendDelta(dto.RealtimeEventResponseAudioDelta, part.InlineData.Data); err != nil {
+						return err
+					}
+				} else if part.Text != "" {
+					eventType := dto.RealtimeEventResponseTextDelta
+					if b.isAudio() {
+						eventType = dto.RealtimeEventResponseAudioTranscriptionDelta
+					}
+					if err := b.sendDelta(eventType, part.Text); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if content.OutputTranscription != nil && content.OutputTranscription.Text != "" {
+			if err := b.sendDelta(dto.RealtimeEventResponseAudioTranscriptionDelta, content.OutputTranscription.Text); err != nil {
+				return err
+			}
+		}
+		if content.TurnComplete {
+			if err := b.finishInputTranscription(); err != nil {
+				return err
+			}
+			if err := b.finishResponse("completed"); err != nil {
+				return err
+			}
+		}
+	}
+	if message.ToolCall != nil && len(message.ToolCall.FunctionCalls) > 0 {
+		if err := b.finishInputTranscription(); err != nil {
+			return err
+		}
+		for _, call := range message.ToolCall.FunctionCalls {
+			if err := b.sendFunctionCall(call); err != nil {
+				return err
+			}
+		}
+		// OpenAI Realtime 在函数调用后结束本次 response，等待客户端回传结果
+		if err := b.finishResponse("completed"); err != nil {
+			return err
+		}
+	}
+	// usage 晚于 turnComplete 到达时直接计费
+	if b.response == nil && b.usage.TotalTokens != 0 {
+		if err := openai.PreConsumeRealtimeUsage(b.c, b.info, b.usage, b.sumUsage); err != nil {
+			return fmt.Errorf("error consume usage: %v", err)
+		}
+		b.usage = &dto.RealtimeUsage{}
+		b.localUsage = &dto.RealtimeUsage{}
+	}
+	return nil
+}
+
+func (b *geminiLiveBridge) addUpstreamUsage(metadata *dto.GeminiLiveUsageMetadata) {
+	usage := geminiLiveUsageToRealtimeUsage(metadata)
+	b.usage.TotalTokens += usage.TotalTokens
+	b.usage.InputTokens += usage.InputTokens
+	b.usage.OutputTokens += usage.OutputTokens
+	b.usage.InputTokenDetails.CachedTokens += usage.InputTokenDetails.CachedTokens
+	b.usage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
+	b.usage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
+	b.usage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
+	b.usage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+}
+
+// ensureResponse 调用方需持有 b.mu
+func (b *geminiLiveBridge) ensureResponse() error {
+	if b.response != nil {
+		return nil
+	}
+	b.response = &geminiLiveResponse{id: "resp_" + common.GetUUID()}
+	return b.sendClient(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeResponseCreated,
+		Response: &dto.RealtimeResponse{
+			Id:     b.response.id,
+			Object: "realtime.response",
+			Status: "in_progress",
+			Output: []dto.RealtimeItem{},
+		},
+	})
+}
+
+// ensureMessageItem 调用方需持有 b.mu
+func (b *geminiLiveBridge) ensureMessageItem() error {
+	if err := b.ensureResponse(); err != nil {
+		return err
+	}
+	response := b.response
+	if response.itemId != "" {
+		return nil
+	}
+	response.itemId = "item_" + common.GetUUID()
+	response.contentType = "text"
+	if b.isAudio() {
+		response.contentType = "audio"
+	}
+	response.output = append(response.output, dto.RealtimeItem{
+		Id:      response.itemId,
+		Type:    "message",
+		Status:  "in_progress",
+		Role:    "assistant",
+		Content: []dto.RealtimeContent{},
+	})
+	outputIndex := len(response.output) - 1
+	err := b.sendClient(&dto.RealtimeEvent{
+		Type:        dto.RealtimeEventResponseOutputItemAdded,
+		ResponseId:  response.id,
+		OutputIndex: common.GetPointer(outputIndex),
+		Item:        &response.output[outputIndex],
+	})
+	if err != nil {
+		return err
+	}
+	return b.sendClient(&dto.RealtimeEvent{
+		Type:         dto.RealtimeEventResponseContentPartAdded,
+		ResponseId:   response.id,
+		ItemId:       response.itemId,
+		OutputIndex:  common.GetPointer(outputIndex),
+		ContentIndex: common.GetPointer(0),
+		Part:         &dto.RealtimeContent{Type: response.contentType},
+	})
+}
+
+func (b *geminiLiveBridge) messageOutputIndex() int {
+	for i, item := range b.response.output {
+		if item.Id == b.response.itemId {
+			return i
+		}
+	}
+	return 0
+}
+
+// sendDelta 调用方需持有 b.mu
+func (b *geminiLiveBridge) sendDelta(eventType string, delta string) error {
+	if err := b.ensureMessageItem(); err != nil {
+		return err
+	}
+	switch eventType {
+	case dto.RealtimeEventResponseTextDelta:
+		b.response.text.WriteString(delta)
+	case dto.RealtimeEventResponseAudioTranscriptionDelta:
+		b.response.transcript.WriteString(delta)
+	}
+	event := &dto.RealtimeEvent{
+		Type:         eventType,
+		ResponseId:   b.response.id,
+		ItemId:       b.response.itemId,
+		OutputIndex:  common.GetPointer(b.messageOutputIndex()),
+		ContentIndex: common.GetPointer(0),
+		Delta:        delta,
+	}
+	b.countLocal(event, true)
+	return b.sendClient(event)
+}
+
+// sendFunctionCall 调用方需持有 b.mu
+func (b *geminiLiveBridge) sendFunctionCall(call dto.GeminiLiveFunctionCall) error {
+	if err := b.ensureResponse(); err != nil {
+		return err
+	}
+	response := b.response
+	callId := call.Id
+	if callId == "" {
+		callId = "call_" + common.GetUUID()
+	}
+	b.callNames[callId] = call.Name
+	arguments := "{}"
+	if call.Args != nil {
+		if data, err := common.Marshal(call.Args); err == nil {
+			arguments = string(data)
+		}
+	}
+	item := dto.RealtimeItem{
+		Id:     "item_" + common.GetUUID(),
+		Type:   "function_call",
+		Status: "in_progress",
+		Name:   common.GetPointer(call.Name),
+		CallId: callId,
+	}
+	response.output = append(response.output, item)
+	outputIndex := len(response.output) - 1
+	err := b.sendClient(&dto.RealtimeEvent{
+		Type:        dto.RealtimeEventResponseOutputItemAdded,
+		ResponseId:  response.id,
+		OutputIndex: common.GetPointer(outputIndex),
+		Item:        &item,
+	})
+	if err != nil {
+		return err
+	}
+	delta := &dto.RealtimeEvent{
+		Type:        dto.RealtimeEventResponseFunctionCallArgumentsDelta,
+		ResponseId:  response.id,
+		ItemId:      item.Id,
+		OutputIndex: common.GetPointer(outputIndex),
+		CallId:      callId,
+		Delta:       arguments,
+	}
+	b.countLocal(delta, true)
+	if err := b.sendClient(delta); err != nil {
+		return err
+	}
+	err = b.sendClient(&dto.RealtimeEvent{
+		Type:        dto.RealtimeEventResponseFunctionCallArgumentsDone,
+		ResponseId:  response.id,
+		ItemId:      item.Id,
+		OutputIndex: common.GetPointer(outputIndex),
+		CallId:      callId,
+		Name:        call.Name,
+		Arguments:   arguments,
+	})
+	if err != nil {
+		return err
+	}
+	item.Status = "completed"
+	item.Arguments = arguments
+	response.output[outputIndex] = item
+	return b.sendClient(&dto.RealtimeEvent{
+		Type:        dto.RealtimeEventResponseOutputItemDone,
+		ResponseId:  response.id,
+		OutputIndex: common.GetPointer(outputIndex),
+		Item:        &item,
+	})
+}
+
+// finishInputTranscription 调用方需持有 b.mu
+func (b *geminiLiveBridge) finishInputTranscription() error {
+	if b.inputItemId == "" {
+		return nil
+	}
+	event := &dto.RealtimeEvent{
+		Type:         dto.RealtimeEventInputAudioTranscriptionCompleted,
+		ItemId:       b.inputItemId,
+		ContentIndex: common.GetPointer(0),
+		Transcript:   b.inputTranscript.String(),
+	}
+	b.inputItemId = ""
+	b.inputTranscript.Reset()
+	return b.sendClient(event)
+}
+
+// finishResponse 补齐各 done 事件并结算本次 response，调用方需持有 b.mu
+func (b *geminiLiveBridge) finishResponse(status string) error {
+	response := b.response
+	if response == nil {
+		return nil
+	}
+	if response.itemId != "" {
+		outputIndex := b.messageOutputIndex()
+		base := dto.RealtimeEvent{
+			ResponseId:   response.id,
+			ItemId:       response.itemId,
+			OutputIndex:  common.GetPointer(outputIndex),
+			ContentIndex: common.GetPointer(0),
+		}
+		part := dto.RealtimeContent{Type: response.contentType}
+		if response.contentType == "audio" {
+			part.Transcript = response.transcript.String()
+			audioDone := base
+			audioDone.Type = dto.RealtimeEventResponseAudioDone
+			if err := b.sendClient(&audioDone); err != nil {
+				return err
+			}
+			transcriptDone := base
+			transcriptDone.Type = dto.RealtimeEventResponseAudioTranscriptionDone
+			transcriptDone.Transcript = part.Transcript
+			if err := b.sendClient(&transcriptDone); err != nil {
+				return err
+			}
+		} else {
+			part.Text = response.text.String()
+			textDone := base
+			textDone.Type = dto.RealtimeEventResponseTextDone
+			textDone.Text = part.Text
+			if err := b.sendClient(&textDone); err != nil {
+				return err
+			}
+		}
+		partDone := base
+		partDone.Type = dto.RealtimeEventResponseContentPartDone
+		partDone.Part = &part
+		if err := b.sendClient(&partDone); err != nil {
+			return err
+		}
+		item := &response.output[outputIndex]
+		item.Status = "completed"
+		if status != "completed" {
+			item.Status = "incomplete"
+		}
+		item.Content = []dto.RealtimeContent{part}
+		err := b.sendClient(&dto.RealtimeEvent{
+			Type:        dto.RealtimeEventResponseOutputItemDone,
+			ResponseId:  response.id,
+			OutputIndex: common.GetPointer(outputIndex),
+			Item:        item,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	done := &dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeResponseDone,
+		Response: &dto.RealtimeResponse{
+			Id:     response.id,
+			Object: "realtime.response",
+			Status: status,
+			Output: response.output,
+		},
+	}
+	// 上游给出 usage 时以上游为准，否则按本地估算计费
+	billed := b.usage
+	if b.usage.TotalTokens == 0 {
+		b.countLocal(done, false)
+		billed = b.localUsage
+	}
+	b.info.IsFirstRequest = false
+	done.Response.Usage = billed
+	if billed.TotalTokens != 0 {
+		if err := openai.PreConsumeRealtimeUsage(b.c, b.info, billed, b.sumUsage); err != nil {
+			return fmt.Errorf("error consume usage: %v", err)
+		}
+	}
+	b.usage = &dto.RealtimeUsage{}
+	b.localUsage = &dto.RealtimeUsage{}
+	b.response = nil
+	return b.sendClient(done)
+}
+
+// geminiLiveUsageToRealtimeUsage 按模态拆分 Gemini Live 的 usageMetadata
+func geminiLiveUsageToRealtimeUsage(metadata *dto.GeminiLiveUsageMetadata) *dto.RealtimeUsage {
+	usage := &dto.RealtimeUsage{
+		InputTokens:  metadata.PromptTokenCount,
+		OutputTokens: metadata.ResponseTokenCount + metadata.ThoughtsTokenCount,
+	}
+	usage.InputTokenDetails.CachedTokens = metadata.CachedContentTokenCount
+	if len(metadata.PromptTokensDetails) == 0 {
+		usage.InputTokenDetails.TextTokens = metadata.PromptTokenCount
+	}
+	for _, detail := range metadata.PromptTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.InputTokenDetails.AudioTokens += detail.TokenCount
+		} else {
+			usage.InputTokenDetails.TextTokens += detail.TokenCount
+		}
+	}
+	usage.OutputTokenDetails.TextTokens = metadata.ThoughtsTokenCount
+	if len(metadata.ResponseTokensDetails) == 0 {
+		usage.OutputTokenDetails.TextTokens += metadata.ResponseTokenCount
+	}
+	for _, detail := range metadata.ResponseTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.OutputTokenDetails.AudioTokens += detail.TokenCount
+		} else {
+			usage.OutputTokenDetails.TextTokens += detail.TokenCount
+		}
+	}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	return usage
+}
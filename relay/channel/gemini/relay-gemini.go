@@ -481,6 +481,12 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 			Role: message.Role,
 		}
 		shouldAttachThoughtSignature := attachThoughtSignature && (message.Role == "assistant" || message.Role == "model")
+		thoughtSignature := json.RawMessage(strconv.Quote(thoughtSignatureBypassValue))
+		// 客户端回传了上游的思考签名时使用真实签名
+		if message.ReasoningSignature != "" && (message.Role == "assistant" || message.Role == "model") {
+			shouldAttachThoughtSignature = true
+			thoughtSignature = json.RawMessage(strconv.Quote(message.ReasoningSignature))
+		}
 		signatureAttached := false
 		// isToolCall := false
 		if message.ToolCalls != nil {
@@ -500,7 +506,7 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 					},
 				}
 				if shouldAttachThoughtSignature && !signatureAttached && hasFunctionCallContent(toolCall.FunctionCall) && len(toolCall.ThoughtSignature) == 0 {
-					toolCall.ThoughtSignature = json.RawMessage(strconv.Quote(thoughtSignatureBypassValue))
+					toolCall.ThoughtSignature = thoughtSignature
 					signatureAttached = true
 				}
 				parts = append(parts, toolCall)
@@ -635,7 +641,7 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 		if shouldAttachThoughtSignature && !signatureAttached && len(parts) > 0 {
 			for i := range parts {
 				if parts[i].Text != "" {
-					parts[i].ThoughtSignature = json.RawMessage(strconv.Quote(thoughtSignatureBypassValue))
+					parts[i].ThoughtSignature = thoughtSignature
 					break
 				}
 			}
@@ -690,6 +696,18 @@ func parseStopSequences(stop any) []string {
 	return nil
 }
 
+// geminiThoughtSignature 解析 part 上的思考签名，忽略兼容用的占位签名
+func geminiThoughtSignature(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var signature string
+	if err := common.Unmarshal(raw, &signature); err != nil || signature == thoughtSignatureBypassValue {
+		return ""
+	}
+	return signature
+}
+
 func hasFunctionCallContent(call *dto.FunctionCall) bool {
 	if call == nil {
 		return false
@@ -1093,6 +1111,9 @@ func responseGeminiChat2OpenAI(c *gin.Context, response *dto.GeminiChatResponse)
 			var texts []string
 			var toolCalls []dto.ToolCallResponse
 			for _, part := range candidate.Content.Parts {
+				if choice.Message.ReasoningSignature == "" {
+					choice.Message.ReasoningSignature = geminiThoughtSignature(part.ThoughtSignature)
+				}
 				if part.InlineData != nil {
 					// 媒体内容
 					if strings.HasPrefix(part.InlineData.MimeType, "image") {
@@ -1216,6 +1237,9 @@ func streamResponseGeminiChat2OpenAI(geminiResponse *dto.GeminiChatResponse) (*d
 			}
 		}
 		for _, part := range candidate.Content.Parts {
+			if signature := geminiThoughtSignature(part.ThoughtSignature); signature != "" && choice.Delta.ReasoningSignature == nil {
+				choice.Delta.ReasoningSignature = &signature
+			}
 			if part.InlineData != nil {
 				if strings.HasPrefix(part.InlineData.MimeType, "image") {
 					imgText := "![image](data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data + ")"
@@ -1496,6 +1520,11 @@ func GeminiChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 		responseBody = claudeRespStr
 	case types.RelayFormatGemini:
 		break
+	case types.RelayFormatOpenAIResponses:
+		responseBody, err = common.Marshal(service.ChatCompletionsResponseToResponsesResponse(fullTextResponse, &usage))
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
 	}
 
 	service.IOCopyBytesGracefully(c, resp, responseBody)
@@ -1539,7 +1568,7 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 	usage := service.ResponseText2Usage(c, "", info.UpstreamModelName, info.GetEstimatePromptTokens())
 	openAIResponse.Usage = *usage
 
-	jsonResponse, jsonErr := common.Marshal(openAIResponse)
+	jsonResponse, jsonErr := service.MarshalEmbeddingResponse(info, &openAIResponse)
 	if jsonErr != nil {
 		return nil, types.NewOpenAIError(jsonErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
@@ -1579,15 +1608,11 @@ func GeminiImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 		})
 	}
 
-	jsonResponse, jsonErr := json.Marshal(openAIResponse)
-	if jsonErr != nil {
-		return nil, types.NewError(jsonErr, types.ErrorCodeBadResponseBody)
+	service.SetImageCountRatio(info, len(openAIResponse.Data))
+	if newAPIError := writeImageResponse(c, info, resp.StatusCode, &openAIResponse); newAPIError != nil {
+		return nil, newAPIError
 	}
 
-	c.Writer.Header().Set("Content-Type", "application/json")
-	c.Writer.WriteHeader(resp.StatusCode)
-	_, _ = c.Writer.Write(jsonResponse)
-
 	// https://github.com/google-gemini/cookbook/blob/719a27d752aac33f39de18a8d3cb42a70874917e/quickstarts/Counting_Tokens.ipynb
 	// each image has fixed 258 tokens
 	const imageTokens = 258
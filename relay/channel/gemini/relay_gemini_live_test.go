@@ -0,0 +1,65 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiLiveUsageToRealtimeUsage(t *testing.T) {
+	t.Parallel()
+
+	usage := geminiLiveUsageToRealtimeUsage(&dto.GeminiLiveUsageMetadata{
+		PromptTokenCount:        120,
+		CachedContentTokenCount: 20,
+		ResponseTokenCount:      80,
+		ThoughtsTokenCount:      5,
+		PromptTokensDetails: []dto.GeminiPromptTokensDetails{
+			{Modality: "TEXT", TokenCount: 30},
+			{Modality: "AUDIO", TokenCount: 90},
+		},
+		ResponseTokensDetails: []dto.GeminiPromptTokensDetails{
+			{Modality: "AUDIO", TokenCount: 80},
+		},
+	})
+
+	require.Equal(t, 120, usage.InputTokens)
+	require.Equal(t, 85, usage.OutputTokens)
+	require.Equal(t, 205, usage.TotalTokens)
+	require.Equal(t, 30, usage.InputTokenDetails.TextTokens)
+	require.Equal(t, 90, usage.InputTokenDetails.AudioTokens)
+	require.Equal(t, 20, usage.InputTokenDetails.CachedTokens)
+	require.Equal(t, 5, usage.OutputTokenDetails.TextTokens)
+	require.Equal(t, 80, usage.OutputTokenDetails.AudioTokens)
+}
+
+func TestGeminiLiveBuildSetupFromSessionUpdate(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"type":"session.update","session":{"modalities":["text","audio"],"instructions":"be brief","voice":"Kore","turn_detection":null,"input_audio_transcription":{"model":"whisper-1"},"tools":[{"type":"function","name":"get_weather","parameters":{"type":"object"}}]}}`)
+	event := &dto.RealtimeEvent{}
+	require.NoError(t, common.Unmarshal(raw, event))
+
+	b := &geminiLiveBridge{
+		info: &relaycommon.RelayInfo{
+			ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "gemini-2.5-flash-native-audio"},
+		},
+		session: defaultGeminiLiveSession(),
+	}
+	b.mergeSession(event.Session, raw)
+	setup := b.buildSetup()
+
+	require.True(t, b.manualVAD)
+	require.Equal(t, "models/gemini-2.5-flash-native-audio", setup.Model)
+	require.Equal(t, []string{"AUDIO"}, setup.GenerationConfig.ResponseModalities)
+	require.Equal(t, "Kore", setup.GenerationConfig.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName)
+	require.Equal(t, "be brief", setup.SystemInstruction.Parts[0].Text)
+	require.True(t, setup.RealtimeInputConfig.AutomaticActivityDetection.Disabled)
+	require.NotNil(t, setup.InputAudioTranscription)
+	require.NotNil(t, setup.OutputAudioTranscription)
+	require.Len(t, setup.Tools, 1)
+	require.Len(t, b.info.RealtimeTools, 1)
+}
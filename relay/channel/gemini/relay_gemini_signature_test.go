@@ -0,0 +1,61 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiThoughtSignatureRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	const signature = "CiQB0e2Kb7signature"
+
+	response := &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{
+			Content: dto.GeminiChatContent{
+				Role: "model",
+				Parts: []dto.GeminiPart{{
+					FunctionCall:     &dto.FunctionCall{FunctionName: "get_weather", Arguments: map[string]any{"city": "Paris"}},
+					ThoughtSignature: json.RawMessage(strconv.Quote(signature)),
+				}},
+			},
+		}},
+	}
+	chat := responseGeminiChat2OpenAI(c, response)
+	require.Len(t, chat.Choices, 1)
+	assistant := chat.Choices[0].Message
+	assert.Equal(t, signature, assistant.ReasoningSignature)
+
+	// 回传的助手消息使用真实签名，而不是兼容用的占位签名
+	info := &relaycommon.RelayInfo{
+		OriginModelName: "gemini-3-pro-preview",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelType:       constant.ChannelTypeGemini,
+			UpstreamModelName: "gemini-3-pro-preview",
+		},
+	}
+	geminiRequest, err := CovertOpenAI2Gemini(c, dto.GeneralOpenAIRequest{
+		Model: "gemini-3-pro-preview",
+		Messages: []dto.Message{
+			{Role: "user", Content: "weather in Paris?"},
+			assistant,
+		},
+	}, info)
+	require.NoError(t, err)
+	require.Len(t, geminiRequest.Contents, 2)
+	parts := geminiRequest.Contents[1].Parts
+	require.NotEmpty(t, parts)
+	require.NotNil(t, parts[0].FunctionCall)
+	assert.Equal(t, strconv.Quote(signature), string(parts[0].ThoughtSignature))
+}
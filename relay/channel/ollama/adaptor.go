@@ -6,11 +6,13 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -19,8 +21,21 @@ import (
 type Adaptor struct {
 }
 
-func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
-	return nil, errors.New("not implemented")
+func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
+	openaiRequest, err := service.GeminiToOpenAIRequest(request, info)
+	if err != nil {
+		return nil, err
+	}
+	openaiRequest.StreamOptions = &dto.StreamOptions{
+		IncludeUsage: true,
+	}
+	if thinkingConfig := request.GenerationConfig.ThinkingConfig; thinkingConfig != nil {
+		// thinkingBudget 为 0 时关闭思考
+		think := thinkingConfig.ThinkingBudget == nil || *thinkingConfig.ThinkingBudget != 0
+		openaiRequest.Think, _ = common.Marshal(think)
+	}
+	// map to ollama chat request (Gemini -> OpenAI -> Ollama chat)
+	return openAIChatToOllamaChat(c, openaiRequest)
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
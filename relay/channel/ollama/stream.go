@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
@@ -78,7 +79,7 @@ func ollamaStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 	var toolCallIndex int
 	start := helper.GenerateStartEmptyResponse(responseId, created, model, nil)
 	if data, err := common.Marshal(start); err == nil {
-		_ = helper.StringData(c, string(data))
+		_ = openai.HandleStreamFormat(c, info, string(data), false, false)
 	}
 
 	for scanner.Scan() {
@@ -145,7 +146,7 @@ func ollamaStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 				}
 			}
 			if data, err := common.Marshal(delta); err == nil {
-				_ = helper.StringData(c, string(data))
+				_ = openai.HandleStreamFormat(c, info, string(data), false, false)
 			}
 			continue
 		}
@@ -158,6 +159,15 @@ func ollamaStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		if finishReason == "" {
 			finishReason = "stop"
 		}
+		if info.RelayFormat != types.RelayFormatOpenAI {
+			// Claude、Gemini 等格式由最后一个响应生成结束事件
+			stop := helper.GenerateStopResponse(responseId, created, model, finishReason)
+			stop.Usage = usage
+			if data, err := common.Marshal(stop); err == nil {
+				openai.HandleFinalResponse(c, info, string(data), responseId, created, model, "", usage, true)
+			}
+			break
+		}
 		// emit stop delta
 		if stop := helper.GenerateStopResponse(responseId, created, model, finishReason); stop != nil {
 			if data, err := common.Marshal(stop); err == nil {
@@ -287,7 +297,19 @@ func ollamaChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 		}},
 		Usage: *usage,
 	}
-	out, _ := common.Marshal(full)
+	var out []byte
+	if info.RelayFormat != types.RelayFormatOpenAI {
+		// 格式转换只识别字符串内容
+		full.Choices[0].Message.Content = content
+	}
+	switch info.RelayFormat {
+	case types.RelayFormatClaude:
+		out, _ = common.Marshal(service.ResponseOpenAI2Claude(&full, info))
+	case types.RelayFormatGemini:
+		out, _ = common.Marshal(service.ResponseOpenAI2Gemini(&full, info))
+	default:
+		out, _ = common.Marshal(full)
+	}
 	service.IOCopyBytesGracefully(c, resp, out)
 	return usage, nil
 }
@@ -29,10 +29,20 @@ func HandleStreamFormat(c *gin.Context, info *relaycommon.RelayInfo, data string
 		return handleClaudeFormat(c, data, info)
 	case types.RelayFormatGemini:
 		return handleGeminiFormat(c, data, info)
+	case types.RelayFormatOpenAIResponses:
+		return handleResponsesFormat(c, data, info)
 	}
 	return nil
 }
 
+func handleResponsesFormat(c *gin.Context, data string, info *relaycommon.RelayInfo) error {
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := common.Unmarshal(common.StringToByteSlice(data), &streamResponse); err != nil {
+		return err
+	}
+	return helper.ResponsesData(c, info.GetResponsesStreamState().Convert(&streamResponse))
+}
+
 func handleClaudeFormat(c *gin.Context, data string, info *relaycommon.RelayInfo) error {
 	var streamResponse dto.ChatCompletionsStreamResponse
 	if err := common.Unmarshal(common.StringToByteSlice(data), &streamResponse); err != nil {
@@ -250,6 +260,11 @@ func HandleFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, lastStream
 		// 发送最终的 Gemini 响应
 		c.Render(-1, common.CustomEvent{Data: "data: " + string(geminiResponseStr)})
 		_ = helper.FlushWriter(c)
+
+	case types.RelayFormatOpenAIResponses:
+		if err := helper.ResponsesData(c, info.GetResponsesStreamState().Finish(usage)); err != nil {
+			common.SysLog("send final response failed: " + err.Error())
+		}
 	}
 }
 
@@ -132,6 +132,16 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 			return nil, errors.New("replicate adaptor: image file is required for edits")
 		}
 		inputPayload["image_prompt"] = imageURL
+		// 带蒙版的编辑请求（如 flux-fill 系列）使用 image 与 mask 字段
+		if form := c.Request.MultipartForm; form != nil && len(form.File["mask"]) > 0 {
+			maskURL, err := uploadFileFromForm(c, info, "mask")
+			if err != nil {
+				return nil, err
+			}
+			delete(inputPayload, "image_prompt")
+			inputPayload["image"] = imageURL
+			inputPayload["mask"] = maskURL
+		}
 	}
 
 	if len(request.ExtraFields) > 0 {
@@ -239,38 +249,16 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		return nil, types.NewError(errors.New("replicate adaptor: empty prediction output"), types.ErrorCodeBadResponseBody)
 	}
 
-	var imageReq *dto.ImageRequest
-	if info != nil {
-		if req, ok := info.Request.(*dto.ImageRequest); ok {
-			imageReq = req
-		}
-	}
-
-	wantsBase64 := imageReq != nil && strings.EqualFold(imageReq.ResponseFormat, "b64_json")
-
 	imageResponse := dto.ImageResponse{
 		Created: common.GetTimestamp(),
-		Data:    make([]dto.ImageData, 0),
+		Data:    make([]dto.ImageData, 0, len(urls)),
 	}
-
-	if wantsBase64 {
-		converted, convErr := downloadImagesToBase64(urls)
-		if convErr != nil {
-			return nil, types.NewError(convErr, types.ErrorCodeBadResponse)
-		}
-		for _, content := range converted {
-			if content == "" {
-				continue
-			}
-			imageResponse.Data = append(imageResponse.Data, dto.ImageData{B64Json: content})
-		}
-	} else {
-		for _, url := range urls {
-			if url == "" {
-				continue
-			}
-			imageResponse.Data = append(imageResponse.Data, dto.ImageData{Url: url})
-		}
+	for _, url := range urls {
+		imageResponse.Data = append(imageResponse.Data, dto.ImageData{Url: url})
+	}
+	service.SetImageCountRatio(info, len(imageResponse.Data))
+	if err := service.ApplyImageResponseFormat(info, &imageResponse); err != nil {
+		return nil, types.NewError(fmt.Errorf("replicate adaptor: %w", err), types.ErrorCodeBadResponse)
 	}
 
 	if len(imageResponse.Data) == 0 {
@@ -298,21 +286,6 @@ func (a *Adaptor) GetChannelName() string {
 	return ChannelName
 }
 
-func downloadImagesToBase64(urls []string) ([]string, error) {
-	results := make([]string, 0, len(urls))
-	for _, url := range urls {
-		if strings.TrimSpace(url) == "" {
-			continue
-		}
-		_, data, err := service.GetImageFromUrl(url)
-		if err != nil {
-			return nil, fmt.Errorf("replicate adaptor: failed to download image from %s: %w", url, err)
-		}
-		results = append(results, data)
-	}
-	return results, nil
-}
-
 func mapOpenAISizeToFlux(size string) (aspect string, width int, height int, ok bool) {
 	parts := strings.Split(size, "x")
 	if len(parts) != 2 {
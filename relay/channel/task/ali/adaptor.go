@@ -261,7 +261,7 @@ func (a *TaskAdaptor) convertToAliRequest(info *relaycommon.RelayInfo, req relay
 		Model: upstreamModel,
 		Input: AliVideoInput{
 			Prompt: req.Prompt,
-			ImgURL: req.InputReference,
+			ImgURL: taskcommon.DefaultString(req.InputReference, req.Image),
 		},
 		Parameters: &AliVideoParameters{
 			PromptExtend: true, // 默认开启智能改写
@@ -269,22 +269,25 @@ func (a *TaskAdaptor) convertToAliRequest(info *relaycommon.RelayInfo, req relay
 		},
 	}
 
-	// 处理分辨率映射
-	if req.Size != "" {
+	// 处理分辨率映射，兼容 OpenAI 风格的 1280x720
+	size := strings.ReplaceAll(strings.ToLower(req.Size), "x", "*")
+	if size != "" {
 		// text to video size must be contained *
-		if strings.Contains(req.Model, "t2v") && !strings.Contains(req.Size, "*") {
+		if strings.Contains(req.Model, "t2v") && !strings.Contains(size, "*") {
 			return nil, fmt.Errorf("invalid size: %s, example: %s", req.Size, "1920*1080")
 		}
-		if strings.Contains(req.Size, "*") {
-			aliReq.Parameters.Size = req.Size
+		if strings.Contains(size, "*") {
+			aliReq.Parameters.Size = size
 		} else {
-			resolution := strings.ToUpper(req.Size)
+			resolution := strings.ToUpper(size)
 			// 支持 480p, 720p, 1080p 或 480P, 720P, 1080P
 			if !strings.HasSuffix(resolution, "P") {
 				resolution = resolution + "P"
 			}
 			aliReq.Parameters.Resolution = resolution
 		}
+	} else if req.Resolution != "" && !strings.Contains(req.Model, "t2v") {
+		aliReq.Parameters.Resolution = strings.ToUpper(req.Resolution)
 	} else {
 		// 根据模型设置默认分辨率
 		if strings.Contains(req.Model, "t2v") { // image to video
@@ -131,6 +131,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if err != nil {
 		return nil, errors.Wrap(err, "convert request payload failed")
 	}
+	body.CallbackURL = relaycommon.BuildUpstreamTaskCallbackURL(info)
 	if info.IsModelMapped {
 		body.Model = info.UpstreamModelName
 	} else {
@@ -214,8 +215,17 @@ func (a *TaskAdaptor) GetChannelName() string {
 
 func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
 	r := requestPayload{
-		Model:   req.Model,
-		Content: []ContentItem{},
+		Model:    req.Model,
+		Content:  []ContentItem{},
+		Duration: dto.IntValue(req.Duration),
+	}
+	switch req.Resolution {
+	case "480p", "720p", "1080p":
+		r.Resolution = req.Resolution
+	}
+	switch req.AspectRatio {
+	case "16:9", "4:3", "1:1", "3:4", "9:16", "21:9":
+		r.Ratio = req.AspectRatio
 	}
 
 	// Add text prompt
@@ -90,16 +90,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, params); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
-	if params.DurationSeconds == 0 && req.Duration > 0 {
-		params.DurationSeconds = req.Duration
-	}
-	if params.Resolution == "" && req.Size != "" {
-		params.Resolution = SizeToVeoResolution(req.Size)
-	}
-	if params.AspectRatio == "" && req.Size != "" {
-		params.AspectRatio = SizeToVeoAspectRatio(req.Size)
-	}
-	params.Resolution = strings.ToLower(params.Resolution)
+	ApplyVeoVideoParams(params, &req)
 	params.SampleCount = 1
 
 	body := VeoRequestPayload{
@@ -169,7 +160,7 @@ func (a *TaskAdaptor) EstimateBilling(c *gin.Context, info *relaycommon.RelayInf
 	}
 
 	seconds := ResolveVeoDuration(req.Metadata, req.Duration, req.Seconds)
-	resolution := ResolveVeoResolution(req.Metadata, req.Size)
+	resolution := ResolveVeoResolution(req.Metadata, req.Size, req.Resolution)
 	resRatio := VeoResolutionRatio(info.UpstreamModelName, resolution)
 
 	return map[string]float64{
@@ -273,6 +264,28 @@ func (a *TaskAdaptor) ConvertToOpenAIVideo(task *model.Task) ([]byte, error) {
 // helpers
 // ============================
 
+// ApplyVeoVideoParams 将统一参数中的时长、分辨率与宽高比填充到 Veo 参数，metadata 中显式指定的值优先
+func ApplyVeoVideoParams(params *VeoParameters, req *relaycommon.TaskSubmitReq) {
+	if params.DurationSeconds == 0 && req.Duration > 0 {
+		params.DurationSeconds = req.Duration
+	}
+	if params.Resolution == "" {
+		if req.Size != "" {
+			params.Resolution = SizeToVeoResolution(req.Size)
+		} else {
+			params.Resolution = req.Resolution
+		}
+	}
+	if params.AspectRatio == "" {
+		if req.AspectRatio == "16:9" || req.AspectRatio == "9:16" {
+			params.AspectRatio = req.AspectRatio
+		} else if req.Size != "" {
+			params.AspectRatio = SizeToVeoAspectRatio(req.Size)
+		}
+	}
+	params.Resolution = strings.ToLower(params.Resolution)
+}
+
 var modelRe = regexp.MustCompile(`models/([^/]+)/operations/`)
 
 func extractModelFromOperationName(name string) string {
@@ -64,8 +64,8 @@ func ResolveVeoDuration(metadata map[string]any, stdDuration int, stdSeconds str
 }
 
 // ResolveVeoResolution returns the effective resolution string (lowercase).
-// Priority: metadata["resolution"] > SizeToVeoResolution(stdSize) > default ("720p").
-func ResolveVeoResolution(metadata map[string]any, stdSize string) string {
+// Priority: metadata["resolution"] > SizeToVeoResolution(stdSize) > stdResolution > default ("720p").
+func ResolveVeoResolution(metadata map[string]any, stdSize string, stdResolution string) string {
 	if metadata != nil {
 		if _, exists := metadata["resolution"]; exists {
 			if r := ParseVeoResolution(metadata); r != "" {
@@ -76,6 +76,9 @@ func ResolveVeoResolution(metadata map[string]any, stdSize string) string {
 	if stdSize != "" {
 		return SizeToVeoResolution(stdSize)
 	}
+	if stdResolution != "" {
+		return strings.ToLower(stdResolution)
+	}
 	return "720p"
 }
 
@@ -65,6 +65,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if err != nil {
 		return nil, errors.Wrap(err, "convert request payload failed")
 	}
+	body.CallbackURL = relaycommon.BuildUpstreamTaskCallbackURL(info)
 
 	data, err := common.Marshal(body)
 	if err != nil {
@@ -151,6 +152,8 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	resolution := modelConfig.DefaultResolution
 	if req.Size != "" {
 		resolution = a.parseResolutionFromSize(req.Size, modelConfig)
+	} else if req.Resolution != "" {
+		resolution = a.parseResolutionFromSize(req.Resolution, modelConfig)
 	}
 
 	videoRequest := &VideoRequest{
@@ -159,6 +162,13 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 		Duration:   &duration,
 		Resolution: resolution,
 	}
+	// 参考图：一张为首帧，两张为首尾帧
+	if len(req.Images) > 0 {
+		videoRequest.FirstFrameImage = req.Images[0]
+	}
+	if len(req.Images) > 1 {
+		videoRequest.LastFrameImage = req.Images[1]
+	}
 	if err := req.UnmarshalMetadata(&videoRequest); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata to video request failed")
 	}
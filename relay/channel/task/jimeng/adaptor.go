@@ -396,9 +396,19 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 		if strings.HasPrefix(req.Images[0], "http") {
 			r.ImageUrls = req.Images
 		} else {
-			r.BinaryDataBase64 = req.Images
+			// 去掉 data URL 前缀，仅保留 base64 数据
+			r.BinaryDataBase64 = lo.Map(req.Images, func(image string, _ int) string {
+				if idx := strings.Index(image, ";base64,"); strings.HasPrefix(image, "data:") && idx > 0 {
+					return image[idx+len(";base64,"):]
+				}
+				return image
+			})
 		}
 	}
+	switch req.AspectRatio {
+	case "16:9", "4:3", "1:1", "3:4", "9:16", "21:9":
+		r.AspectRatio = req.AspectRatio
+	}
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, &r); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
@@ -154,6 +154,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if err != nil {
 		return nil, err
 	}
+	body.CallbackUrl = relaycommon.BuildUpstreamTaskCallbackURL(info)
 	if body.Image == "" && body.ImageTail == "" {
 		c.Set("action", constant.TaskActionTextGenerate)
 	}
@@ -252,16 +253,15 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	r := requestPayload{
 		Prompt:         req.Prompt,
 		Image:          req.Image,
-		Mode:           taskcommon.DefaultString(req.Mode, "std"),
+		Mode:           taskcommon.DefaultString(req.Mode, a.getMode(req.Resolution)),
 		Duration:       fmt.Sprintf("%d", taskcommon.DefaultInt(req.Duration, 5)),
-		AspectRatio:    a.getAspectRatio(req.Size),
+		AspectRatio:    a.getAspectRatio(req.AspectRatio, req.Size),
 		ModelName:      info.UpstreamModelName,
 		Model:          info.UpstreamModelName,
 		CfgScale:       0.5,
 		StaticMask:     "",
 		DynamicMasks:   []DynamicMask{},
 		CameraControl:  nil,
-		CallbackUrl:    "",
 		ExternalTaskId: "",
 	}
 	if r.ModelName == "" {
@@ -274,7 +274,19 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	return &r, nil
 }
 
-func (a *TaskAdaptor) getAspectRatio(size string) string {
+// getMode 1080p 及以上使用 pro 模式
+func (a *TaskAdaptor) getMode(resolution string) string {
+	if relaycommon.ResolutionHeight(resolution) >= 1080 {
+		return "pro"
+	}
+	return "std"
+}
+
+func (a *TaskAdaptor) getAspectRatio(aspectRatio string, size string) string {
+	switch aspectRatio {
+	case "16:9", "9:16", "1:1":
+		return aspectRatio
+	}
 	switch size {
 	case "1024x1024", "512x512":
 		return "1:1"
@@ -131,7 +131,7 @@ func (a *TaskAdaptor) EstimateBilling(c *gin.Context, info *relaycommon.RelayInf
 
 func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	if info.Action == constant.TaskActionRemix {
-		return fmt.Sprintf("%s/v1/videos/%s/remix", a.baseURL, info.OriginTaskID), nil
+		return fmt.Sprintf("%s/v1/videos/%s/remix", a.baseURL, taskcommon.DefaultString(info.OriginUpstreamTaskID, info.OriginTaskID)), nil
 	}
 	return fmt.Sprintf("%s/v1/videos", a.baseURL), nil
 }
@@ -144,6 +144,8 @@ func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info
 }
 
 func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	// OpenAI 的视频 webhook 在项目级配置，渠道设置了签名密钥即视为已注册回调
+	info.UpstreamCallback = relaycommon.HasChannelTaskWebhook(info)
 	storage, err := common.GetBodyStorage(c)
 	if err != nil {
 		return nil, errors.Wrap(err, "get_request_body_failed")
@@ -158,6 +160,8 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 		var bodyMap map[string]interface{}
 		if err := common.Unmarshal(cachedBody, &bodyMap); err == nil {
 			bodyMap["model"] = info.UpstreamModelName
+			// callback_url 由网关处理，不透传给上游
+			delete(bodyMap, "callback_url")
 			if newBody, err := common.Marshal(bodyMap); err == nil {
 				return bytes.NewReader(newBody), nil
 			}
@@ -174,7 +178,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 		writer := multipart.NewWriter(&buf)
 		writer.WriteField("model", info.UpstreamModelName)
 		for key, values := range formData.Value {
-			if key == "model" {
+			if key == "model" || key == "callback_url" {
 				continue
 			}
 			for _, v := range values {
@@ -279,6 +283,32 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// DeleteOpenAIVideo 删除上游视频
+func (a *TaskAdaptor) DeleteOpenAIVideo(baseUrl, key, proxy string, task *model.Task) error {
+	uri := fmt.Sprintf("%s/v1/videos/%s", baseUrl, task.GetUpstreamTaskID())
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 上游已不存在视为删除成功
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upstream returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return ModelList
 }
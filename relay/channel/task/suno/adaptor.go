@@ -77,10 +77,15 @@ func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info
 }
 
 func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
-	sunoRequest, ok := c.Get("task_request")
+	v, ok := c.Get("task_request")
 	if !ok {
 		return nil, fmt.Errorf("task_request not found in context")
 	}
+	sunoRequest, ok := v.(*dto.SunoSubmitReq)
+	if !ok {
+		return nil, fmt.Errorf("invalid request type in context")
+	}
+	sunoRequest.NotifyHook = relaycommon.BuildUpstreamTaskCallbackURL(info)
 	data, err := common.Marshal(sunoRequest)
 	if err != nil {
 		return nil, err
@@ -33,6 +33,7 @@ type requestPayload struct {
 	Duration          int      `json:"duration,omitempty"`
 	Seed              int      `json:"seed,omitempty"`
 	Resolution        string   `json:"resolution,omitempty"`
+	AspectRatio       string   `json:"aspect_ratio,omitempty"`
 	MovementAmplitude string   `json:"movement_amplitude,omitempty"`
 	Bgm               bool     `json:"bgm,omitempty"`
 	Payload           string   `json:"payload,omitempty"`
@@ -120,6 +121,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if err != nil {
 		return nil, err
 	}
+	body.CallbackUrl = relaycommon.BuildUpstreamTaskCallbackURL(info)
 
 	if info.Action == constant.TaskActionReferenceGenerate {
 		if strings.Contains(body.Model, "viduq2") {
@@ -230,10 +232,17 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 		Images:            req.Images,
 		Prompt:            req.Prompt,
 		Duration:          taskcommon.DefaultInt(req.Duration, 5),
-		Resolution:        taskcommon.DefaultString(req.Size, "1080p"),
+		Resolution:        taskcommon.DefaultString(req.Resolution, taskcommon.DefaultString(req.Size, "1080p")),
 		MovementAmplitude: "auto",
 		Bgm:               false,
 	}
+	// 仅文生视频支持指定宽高比
+	if !req.HasImage() {
+		switch req.AspectRatio {
+		case "16:9", "9:16", "1:1":
+			r.AspectRatio = req.AspectRatio
+		}
+	}
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, &r); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
@@ -16,6 +16,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/reasoning"
 	"github.com/QuantumNous/new-api/types"
@@ -47,6 +48,14 @@ var claudeModelMap = map[string]string{
 
 const anthropicVersion = "vertex-2023-10-16"
 
+// getClaudeModelName 将 Claude 模型名转换为 Vertex AI 的模型 ID
+func getClaudeModelName(modelName string) string {
+	if v, ok := claudeModelMap[modelName]; ok {
+		return v
+	}
+	return modelName
+}
+
 type Adaptor struct {
 	RequestMode        int
 	AccountCredentials Credentials
@@ -106,8 +115,14 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	geminiAdaptor := gemini.Adaptor{}
-	return geminiAdaptor.ConvertImageRequest(c, info, request)
+	if gemini.IsGeminiImageModel(info.UpstreamModelName) {
+		return gemini.ConvertGeminiImageRequest(c, info, request)
+	}
+	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
+		return nil, errors.New("not supported model for image generation")
+	}
+	// Vertex AI 的 Imagen 编辑模型（如 imagen-3.0-capability-001）支持蒙版编辑
+	return gemini.ConvertImagenRequest(c, info, request)
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -227,21 +242,20 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 			suffix = "generateContent"
 		}
 
-		if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+		if strings.HasPrefix(info.UpstreamModelName, "imagen") || info.RelayMode == constant.RelayModeEmbeddings {
 			suffix = "predict"
 		}
 		return a.getRequestUrl(info, info.UpstreamModelName, suffix)
 	} else if a.RequestMode == RequestModeClaude {
+		if info.RelayMode == constant.RelayModeClaudeCountTokens {
+			return a.getRequestUrl(info, "count-tokens", "rawPredict")
+		}
 		if info.IsStream {
 			suffix = "streamRawPredict?alt=sse"
 		} else {
 			suffix = "rawPredict"
 		}
-		model := info.UpstreamModelName
-		if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
-			model = v
-		}
-		return a.getRequestUrl(info, model, suffix)
+		return a.getRequestUrl(info, getClaudeModelName(info.UpstreamModelName), suffix)
 	} else if a.RequestMode == RequestModeOpenSource {
 		return a.getRequestUrl(info, "", "")
 	}
@@ -351,13 +365,21 @@ func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dt
 }
 
 func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if a.RequestMode != RequestModeGemini {
+		return nil, errors.New("embeddings are only supported for google models")
+	}
+	return convertEmbeddingRequest(request)
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
-	// TODO implement me
-	return nil, errors.New("not implemented")
+	if a.RequestMode != RequestModeClaude && a.RequestMode != RequestModeGemini {
+		return nil, errors.New("responses api is only supported for claude and gemini models")
+	}
+	chatRequest, err := service.ResponsesRequestToChatCompletionsRequest(&request)
+	if err != nil {
+		return nil, err
+	}
+	return a.ConvertOpenAIRequest(c, info, chatRequest)
 }
 
 func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
@@ -365,6 +387,9 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	if info.RelayMode == constant.RelayModeEmbeddings {
+		return vertexEmbeddingHandler(c, info, resp)
+	}
 	claudeAdaptor := claude.Adaptor{}
 	if info.IsStream {
 		switch a.RequestMode {
@@ -390,6 +415,9 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 				if strings.HasPrefix(info.UpstreamModelName, "imagen") {
 					return gemini.GeminiImageHandler(c, info, resp)
 				}
+				if gemini.IsImageRelayMode(info.RelayMode) && gemini.IsGeminiImageModel(info.UpstreamModelName) {
+					return gemini.GeminiImageGenerateContentHandler(c, info, resp)
+				}
 				return gemini.GeminiChatHandler(c, info, resp)
 			}
 		case RequestModeOpenSource:
@@ -399,6 +427,16 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	return
 }
 
+// CountClaudeTokens Vertex AI 仅在服务账号鉴权下提供 Claude 的 count-tokens
+func (a *Adaptor) CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error) {
+	if a.RequestMode != RequestModeClaude || info.ChannelOtherSettings.VertexKeyType == dto.VertexKeyTypeAPIKey {
+		return 0, errors.New("count tokens is only supported for claude models with service account credentials")
+	}
+	countRequest := dto.NewClaudeCountTokensRequest(request)
+	countRequest.Model = getClaudeModelName(info.UpstreamModelName)
+	return claude.DoCountTokensRequest(a, c, info, countRequest)
+}
+
 func (a *Adaptor) GetModelList() []string {
 	var modelList []string
 	for i, s := range ModelList {
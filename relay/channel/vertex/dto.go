@@ -39,3 +39,29 @@ func copyRequest(req *dto.ClaudeRequest, version string) *VertexAIClaudeRequest
 		OutputConfig:     req.OutputConfig,
 	}
 }
+
+// Vertex AI 文本向量模型（text-embedding-*、gemini-embedding-*）的 predict 请求
+// https://cloud.google.com/vertex-ai/generative-ai/docs/model-reference/text-embeddings-api
+type VertexEmbeddingRequest struct {
+	Instances  []VertexEmbeddingInstance  `json:"instances"`
+	Parameters *VertexEmbeddingParameters `json:"parameters,omitempty"`
+}
+
+type VertexEmbeddingInstance struct {
+	Content string `json:"content"`
+}
+
+type VertexEmbeddingParameters struct {
+	OutputDimensionality int `json:"outputDimensionality,omitempty"`
+}
+
+type VertexEmbeddingResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Statistics struct {
+				TokenCount float64 `json:"token_count"`
+			} `json:"statistics"`
+			Values []float64 `json:"values"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
@@ -0,0 +1,69 @@
+package vertex
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func convertEmbeddingRequest(request dto.EmbeddingRequest) (*VertexEmbeddingRequest, error) {
+	inputs := request.ParseInput()
+	if len(inputs) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	vertexRequest := &VertexEmbeddingRequest{
+		Instances: make([]VertexEmbeddingInstance, 0, len(inputs)),
+	}
+	for _, input := range inputs {
+		vertexRequest.Instances = append(vertexRequest.Instances, VertexEmbeddingInstance{Content: input})
+	}
+	if request.Dimensions > 0 {
+		vertexRequest.Parameters = &VertexEmbeddingParameters{OutputDimensionality: request.Dimensions}
+	}
+	return vertexRequest, nil
+}
+
+func vertexEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	var vertexResponse VertexEmbeddingResponse
+	if err := common.Unmarshal(responseBody, &vertexResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	openAIResponse := dto.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.OpenAIEmbeddingResponseItem, 0, len(vertexResponse.Predictions)),
+		Model:  info.UpstreamModelName,
+	}
+	promptTokens := 0
+	for i, prediction := range vertexResponse.Predictions {
+		openAIResponse.Data = append(openAIResponse.Data, dto.OpenAIEmbeddingResponseItem{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: prediction.Embeddings.Values,
+		})
+		promptTokens += int(prediction.Embeddings.Statistics.TokenCount)
+	}
+	usage := service.EmbeddingUsage(c, info, promptTokens)
+	openAIResponse.Usage = *usage
+
+	jsonResponse, err := service.MarshalEmbeddingResponse(info, &openAIResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return usage, nil
+}
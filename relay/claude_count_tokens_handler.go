@@ -0,0 +1,53 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaudeCountTokensHelper 统计 Claude 请求的输入 token，不计费
+// 渠道支持时转发到上游 count_tokens，否则使用本地估算
+func ClaudeCountTokensHelper(c *gin.Context, info *relaycommon.RelayInfo) (int, *types.NewAPIError) {
+	info.InitChannelMeta(c)
+
+	claudeReq, ok := info.Request.(*dto.ClaudeRequest)
+	if !ok {
+		return 0, types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected *dto.ClaudeRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	request, err := common.DeepCopy(claudeReq)
+	if err != nil {
+		return 0, types.NewError(fmt.Errorf("failed to copy request to ClaudeRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	err = helper.ModelMappedHelper(c, info, request)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return 0, types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+	}
+	adaptor.Init(info)
+
+	if counter, ok := adaptor.(channel.ClaudeTokenCounter); ok {
+		tokens, err := counter.CountClaudeTokens(c, info, request)
+		if err == nil {
+			return tokens, nil
+		}
+		logger.LogWarn(c, fmt.Sprintf("upstream count_tokens failed, fallback to local estimate: %s", err.Error()))
+	}
+	return service.CountClaudeRequestTokens(request), nil
+}
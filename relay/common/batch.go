@@ -0,0 +1,20 @@
+package common
+
+import "context"
+
+type batchRequestKey struct{}
+
+// WithBatchRequest 标记请求由 Batch API worker 发起
+// 使用私有的 context key，外部请求无法通过 header 等方式伪造
+func WithBatchRequest(ctx context.Context, batchId string) context.Context {
+	return context.WithValue(ctx, batchRequestKey{}, batchId)
+}
+
+// GetBatchRequestId 获取发起请求的批处理任务 ID，非批处理请求返回空字符串
+func GetBatchRequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	batchId, _ := ctx.Value(batchRequestKey{}).(string)
+	return batchId
+}
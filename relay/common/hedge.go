@@ -0,0 +1,164 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+// ErrHedgeLost 对冲竞速中落败的尝试返回该错误，不计入渠道错误
+var ErrHedgeLost = errors.New("hedged request lost the race")
+
+// HedgeRace 对冲请求的竞速状态，第一个开始返回响应正文的尝试胜出，其余尝试被取消
+type HedgeRace struct {
+	mu      sync.Mutex
+	winner  int
+	cancels []context.CancelFunc
+	claimed chan struct{}
+}
+
+func NewHedgeRace() *HedgeRace {
+	return &HedgeRace{
+		winner:  -1,
+		claimed: make(chan struct{}),
+	}
+}
+
+// Join 加入一个尝试，返回其序号；cancel 用于胜负已分后中止该尝试
+func (r *HedgeRace) Join(cancel context.CancelFunc) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancels = append(r.cancels, cancel)
+	return len(r.cancels) - 1
+}
+
+// Claim 尝试成为胜者，成功时取消其余尝试
+func (r *HedgeRace) Claim(index int) bool {
+	r.mu.Lock()
+	if r.winner >= 0 {
+		won := r.winner == index
+		r.mu.Unlock()
+		return won
+	}
+	r.winner = index
+	cancels := make([]context.CancelFunc, 0, len(r.cancels))
+	for i, cancel := range r.cancels {
+		if i != index {
+			cancels = append(cancels, cancel)
+		}
+	}
+	r.mu.Unlock()
+	close(r.claimed)
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return true
+}
+
+// Winner 返回胜者序号，尚未决出时返回 -1
+func (r *HedgeRace) Winner() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+// Size 返回参与竞速的尝试数量
+func (r *HedgeRace) Size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cancels)
+}
+
+// Claimed 胜者决出时关闭
+func (r *HedgeRace) Claimed() <-chan struct{} {
+	return r.claimed
+}
+
+// HedgeAttempt 参与对冲竞速的一次尝试
+type HedgeAttempt struct {
+	Race  *HedgeRace
+	Index int
+	// OnWin 在胜出时调用，用于将该尝试的输出切换到真实的响应
+	OnWin func()
+}
+
+// Claim 尝试成为胜者，首次胜出时调用 OnWin
+func (a *HedgeAttempt) Claim() bool {
+	if a == nil {
+		return true
+	}
+	first := a.Race.Winner() < 0
+	if !a.Race.Claim(a.Index) {
+		return false
+	}
+	if first && a.OnWin != nil {
+		a.OnWin()
+	}
+	return true
+}
+
+// Lost 是否已被其他尝试抢先
+func (a *HedgeAttempt) Lost() bool {
+	if a == nil {
+		return false
+	}
+	winner := a.Race.Winner()
+	return winner >= 0 && winner != a.Index
+}
+
+// Hedged 是否实际发出了对冲请求
+func (a *HedgeAttempt) Hedged() bool {
+	return a != nil && a.Race.Size() > 1
+}
+
+// CloneForHedge 复制一份 RelayInfo 供并发尝试使用，转换过程中会被修改的状态各自独立
+func (info *RelayInfo) CloneForHedge() *RelayInfo {
+	cloned := *info
+	if info.RequestHeaders != nil {
+		cloned.RequestHeaders = make(map[string]string, len(info.RequestHeaders))
+		for k, v := range info.RequestHeaders {
+			cloned.RequestHeaders[k] = v
+		}
+	}
+	if info.RuntimeHeadersOverride != nil {
+		cloned.RuntimeHeadersOverride = make(map[string]interface{}, len(info.RuntimeHeadersOverride))
+		for k, v := range info.RuntimeHeadersOverride {
+			cloned.RuntimeHeadersOverride[k] = v
+		}
+	}
+	cloned.RequestConversionChain = append([]types.RelayFormat(nil), info.RequestConversionChain...)
+	if info.ClaudeConvertInfo != nil {
+		claudeConvertInfo := *info.ClaudeConvertInfo
+		if claudeConvertInfo.Usage != nil {
+			usage := *claudeConvertInfo.Usage
+			claudeConvertInfo.Usage = &usage
+		}
+		cloned.ClaudeConvertInfo = &claudeConvertInfo
+	}
+	cloned.ResponsesStreamState = nil
+	if info.RerankerInfo != nil {
+		rerankerInfo := *info.RerankerInfo
+		cloned.RerankerInfo = &rerankerInfo
+	}
+	if info.ResponsesUsageInfo != nil {
+		responsesUsageInfo := ResponsesUsageInfo{}
+		if info.ResponsesUsageInfo.BuiltInTools != nil {
+			responsesUsageInfo.BuiltInTools = make(map[string]*BuildInToolInfo, len(info.ResponsesUsageInfo.BuiltInTools))
+			for name, tool := range info.ResponsesUsageInfo.BuiltInTools {
+				if tool == nil {
+					continue
+				}
+				toolInfo := *tool
+				responsesUsageInfo.BuiltInTools[name] = &toolInfo
+			}
+		}
+		cloned.ResponsesUsageInfo = &responsesUsageInfo
+	}
+	if info.ChannelMeta != nil {
+		channelMeta := *info.ChannelMeta
+		cloned.ChannelMeta = &channelMeta
+	}
+	return &cloned
+}
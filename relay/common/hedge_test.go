@@ -0,0 +1,66 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgeRaceFirstClaimWinsAndCancelsOthers(t *testing.T) {
+	race := NewHedgeRace()
+	primaryCtx, primaryCancel := context.WithCancel(context.Background())
+	hedgeCtx, hedgeCancel := context.WithCancel(context.Background())
+	defer primaryCancel()
+	defer hedgeCancel()
+
+	won := 0
+	primary := &HedgeAttempt{Race: race, Index: race.Join(primaryCancel), OnWin: func() { won++ }}
+	hedge := &HedgeAttempt{Race: race, Index: race.Join(hedgeCancel), OnWin: func() { won++ }}
+
+	require.True(t, hedge.Hedged())
+	require.True(t, hedge.Claim())
+	require.False(t, primary.Claim())
+	require.True(t, hedge.Claim())
+
+	require.Equal(t, 1, won)
+	require.Equal(t, 1, race.Winner())
+	require.True(t, primary.Lost())
+	require.False(t, hedge.Lost())
+	require.Error(t, primaryCtx.Err())
+	require.NoError(t, hedgeCtx.Err())
+
+	select {
+	case <-race.Claimed():
+	default:
+		t.Fatal("claimed channel should be closed")
+	}
+}
+
+func TestHedgeAttemptNilIsNotRacing(t *testing.T) {
+	var attempt *HedgeAttempt
+	require.True(t, attempt.Claim())
+	require.False(t, attempt.Lost())
+	require.False(t, attempt.Hedged())
+}
+
+func TestRelayInfoCloneForHedgeIsolatesMutableState(t *testing.T) {
+	info := &RelayInfo{
+		RequestHeaders:     map[string]string{"a": "1"},
+		ResponsesUsageInfo: &ResponsesUsageInfo{BuiltInTools: map[string]*BuildInToolInfo{"web_search": {ToolName: "web_search"}}},
+		ClaudeConvertInfo:  &ClaudeConvertInfo{Index: 1},
+		ChannelMeta:        &ChannelMeta{ChannelId: 1},
+	}
+
+	cloned := info.CloneForHedge()
+	cloned.RequestHeaders["a"] = "2"
+	cloned.ResponsesUsageInfo.BuiltInTools["web_search"].CallCount++
+	cloned.ClaudeConvertInfo.Index = 2
+	cloned.ChannelId = 2
+
+	require.False(t, cloned.DisablePing)
+	require.Equal(t, "1", info.RequestHeaders["a"])
+	require.Equal(t, 0, info.ResponsesUsageInfo.BuiltInTools["web_search"].CallCount)
+	require.Equal(t, 1, info.ClaudeConvertInfo.Index)
+	require.Equal(t, 1, info.ChannelId)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service/openaicompat"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -97,6 +98,7 @@ type RelayInfo struct {
 	IsStream               bool
 	IsGeminiBatchEmbedding bool
 	IsPlayground           bool
+	BatchId                string // 非空表示由 Batch API worker 发起的请求
 	UsePrice               bool
 	RelayMode              int
 	OriginModelName        string
@@ -127,8 +129,10 @@ type RelayInfo struct {
 	// 免费模型时为 nil。
 	Billing BillingSettler
 	// BillingSource indicates whether this request is billed from wallet quota or subscription.
-	// "" or "wallet" => wallet; "subscription" => subscription
+	// "" or "wallet" => wallet; "subscription" => subscription; "org" => organization wallet
 	BillingSource string
+	// OrgId is set when the request uses an organization token and is billed from the organization wallet.
+	OrgId int
 	// SubscriptionId is the user_subscriptions.id used when BillingSource == "subscription"
 	SubscriptionId int
 	// SubscriptionPreConsumed is the amount pre-consumed on subscription item (quota units or 1)
@@ -140,6 +144,8 @@ type RelayInfo struct {
 	SubscriptionPlanTitle string
 	// RequestId is used for idempotent pre-consume/refund
 	RequestId string
+	// PostConsumeSeq counts PostConsumeQuota calls of this request, used to derive ledger idempotency keys
+	PostConsumeSeq int
 	// SubscriptionAmountTotal / SubscriptionAmountUsedAfterPreConsume are used to compute remaining in logs.
 	SubscriptionAmountTotal               int64
 	SubscriptionAmountUsedAfterPreConsume int64
@@ -149,6 +155,12 @@ type RelayInfo struct {
 	LastError                             *types.NewAPIError
 	RuntimeHeadersOverride                map[string]interface{}
 	UseRuntimeHeadersOverride             bool
+	// Hedge 非空表示本次尝试参与对冲竞速
+	Hedge *HedgeAttempt
+	// ResponseCacheHit 表示响应来自响应缓存
+	ResponseCacheHit bool
+	// ResponseCacheSimilarity 语义缓存命中时的余弦相似度，精确匹配命中时为 0
+	ResponseCacheSimilarity float64
 
 	PriceData types.PriceData
 
@@ -164,6 +176,8 @@ type RelayInfo struct {
 	ThinkingContentInfo
 	TokenCountMeta
 	*ClaudeConvertInfo
+	// 对话接口的响应转换为 Responses 流式事件时的状态
+	ResponsesStreamState *openaicompat.ResponsesStreamState
 	*RerankerInfo
 	*ResponsesUsageInfo
 	*ChannelMeta
@@ -330,6 +344,14 @@ func GenRelayInfoWs(c *gin.Context, ws *websocket.Conn) *RelayInfo {
 	return info
 }
 
+// GetResponsesStreamState 返回 Responses 流式转换状态，首次调用时创建
+func (info *RelayInfo) GetResponsesStreamState() *openaicompat.ResponsesStreamState {
+	if info.ResponsesStreamState == nil {
+		info.ResponsesStreamState = openaicompat.NewResponsesStreamState("", "", 0)
+	}
+	return info.ResponsesStreamState
+}
+
 func GenRelayInfoClaude(c *gin.Context, request dto.Request) *RelayInfo {
 	info := genBaseRelayInfo(c, request)
 	info.RelayFormat = types.RelayFormatClaude
@@ -462,6 +484,7 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 		TokenGroup:     tokenGroup,
+		OrgId:          common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -485,6 +508,8 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		info.RelayMode = c.GetInt("relay_mode")
 	}
 
+	info.BatchId = GetBatchRequestId(c.Request.Context())
+
 	if strings.HasPrefix(c.Request.URL.Path, "/pg") {
 		info.IsPlayground = true
 		info.RequestURLPath = strings.TrimPrefix(info.RequestURLPath, "/pg")
@@ -655,9 +680,16 @@ func (info *RelayInfo) HasSendResponse() bool {
 type TaskRelayInfo struct {
 	Action       string
 	OriginTaskID string
+	// OriginTaskInput 原始任务提交时的统一参数（JSON），
+	// 供不支持原生 remix 的平台在原参数基础上重新生成。
+	OriginTaskInput string
+	// OriginUpstreamTaskID 原始任务的上游 ID，原生支持 remix 的平台使用
+	OriginUpstreamTaskID string
 	// PublicTaskID 是提交时预生成的 task_xxxx 格式公开 ID，
 	// 供 DoResponse 在返回给客户端时使用（避免暴露上游真实 ID）。
 	PublicTaskID string
+	// UpstreamCallback 本次提交已向上游注册完成回调，任务轮询仅作为兜底
+	UpstreamCallback bool
 
 	ConsumeQuota bool
 
@@ -677,6 +709,8 @@ type TaskSubmitReq struct {
 	Duration       int                    `json:"duration,omitempty"`
 	Seconds        string                 `json:"seconds,omitempty"`
 	InputReference string                 `json:"input_reference,omitempty"`
+	AspectRatio    string                 `json:"aspect_ratio,omitempty"`
+	Resolution     string                 `json:"resolution,omitempty"`
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
 }
 
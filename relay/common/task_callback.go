@@ -0,0 +1,38 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// UpstreamTaskCallbackSign 上游任务回调地址中的签名，绑定公开任务 ID，防止伪造回调
+func UpstreamTaskCallbackSign(taskId string) string {
+	return common.GenerateHMAC("task_callback:" + taskId)
+}
+
+// BuildUpstreamTaskCallbackURL 生成注册给上游的任务完成回调地址并记录到 info，
+// 未启用上游回调或未配置服务器地址时返回空（此时仅依赖轮询）
+func BuildUpstreamTaskCallbackURL(info *RelayInfo) string {
+	if !operation_setting.GetTaskCallbackSetting().Enabled || system_setting.ServerAddress == "" {
+		return ""
+	}
+	if info.TaskRelayInfo == nil || info.PublicTaskID == "" {
+		return ""
+	}
+	info.UpstreamCallback = true
+	return fmt.Sprintf("%s/api/task/callback/task/%s?sign=%s",
+		strings.TrimRight(system_setting.ServerAddress, "/"),
+		url.PathEscape(info.PublicTaskID),
+		UpstreamTaskCallbackSign(info.PublicTaskID))
+}
+
+// HasChannelTaskWebhook 渠道是否配置了渠道级的上游任务 webhook（如 Sora/OpenAI 项目 webhook）
+func HasChannelTaskWebhook(info *RelayInfo) bool {
+	return operation_setting.GetTaskCallbackSetting().Enabled &&
+		info.ChannelMeta != nil && info.ChannelOtherSettings.TaskWebhookSecret != ""
+}
@@ -0,0 +1,260 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+var videoAspectRatios = []struct {
+	label string
+	value float64
+}{
+	{"16:9", 16.0 / 9}, {"9:16", 9.0 / 16}, {"1:1", 1}, {"4:3", 4.0 / 3}, {"3:4", 3.0 / 4},
+	{"3:2", 3.0 / 2}, {"2:3", 2.0 / 3}, {"21:9", 21.0 / 9},
+}
+
+func parseVideoSize(size string) (int, int, bool) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	sep := "x"
+	if strings.Contains(size, "*") {
+		sep = "*"
+	}
+	parts := strings.Split(size, sep)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	w, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	h, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// VideoSizeToAspectRatio 将 WxH（或 W*H）尺寸转换为最接近的常用宽高比，无法识别时返回空
+func VideoSizeToAspectRatio(size string) string {
+	w, h, ok := parseVideoSize(size)
+	if !ok {
+		return ""
+	}
+	ratio := float64(w) / float64(h)
+	best := videoAspectRatios[0]
+	for _, candidate := range videoAspectRatios[1:] {
+		if math.Abs(candidate.value-ratio) < math.Abs(best.value-ratio) {
+			best = candidate
+		}
+	}
+	return best.label
+}
+
+// VideoSizeToResolution 将 WxH（或 W*H）尺寸转换为按短边计的分辨率标签（如 720p、1080p、4k），无法识别时返回空
+func VideoSizeToResolution(size string) string {
+	w, h, ok := parseVideoSize(size)
+	if !ok {
+		return ""
+	}
+	short := min(w, h)
+	if short >= 2160 {
+		return "4k"
+	}
+	return fmt.Sprintf("%dp", short)
+}
+
+// ResolutionHeight 解析分辨率标签（如 720p、1080P、4k）对应的短边像素
+func ResolutionHeight(resolution string) int {
+	resolution = strings.ToLower(strings.TrimSpace(resolution))
+	if resolution == "4k" {
+		return 2160
+	}
+	height, _ := strconv.Atoi(strings.TrimSuffix(resolution, "p"))
+	return height
+}
+
+// NormalizeVideoParams 将 OpenAI /v1/videos 与各平台原生字段统一到同一组参数：
+// seconds/duration 互补，image/input_reference 合并到 images，并由 size 推导宽高比与分辨率
+func (t *TaskSubmitReq) NormalizeVideoParams() {
+	if t.Duration <= 0 && t.Seconds != "" {
+		if seconds, err := strconv.Atoi(strings.TrimSpace(t.Seconds)); err == nil {
+			t.Duration = seconds
+		}
+	}
+	if t.Seconds == "" && t.Duration > 0 {
+		t.Seconds = strconv.Itoa(t.Duration)
+	}
+	if len(t.Images) == 0 {
+		if image := strings.TrimSpace(t.Image); image != "" {
+			t.Images = []string{image}
+		} else if reference := strings.TrimSpace(t.InputReference); reference != "" {
+			t.Images = []string{reference}
+		}
+	}
+	if t.Image == "" && len(t.Images) > 0 {
+		t.Image = t.Images[0]
+	}
+	if t.AspectRatio == "" {
+		t.AspectRatio = VideoSizeToAspectRatio(t.Size)
+	}
+	if t.Resolution == "" {
+		t.Resolution = VideoSizeToResolution(t.Size)
+	}
+	t.Resolution = strings.ToLower(strings.TrimSpace(t.Resolution))
+}
+
+// MergeOriginVideoInput remix 时以原始任务的参数为基础，本次请求中显式传入的字段优先
+func (t *TaskSubmitReq) MergeOriginVideoInput(originInput string) error {
+	if strings.TrimSpace(originInput) == "" {
+		return nil
+	}
+	var origin TaskSubmitReq
+	if err := common.UnmarshalJsonStr(originInput, &origin); err != nil {
+		return err
+	}
+	if t.Model == "" {
+		t.Model = origin.Model
+	}
+	if t.Mode == "" {
+		t.Mode = origin.Mode
+	}
+	if t.Duration <= 0 && t.Seconds == "" {
+		t.Duration = origin.Duration
+		t.Seconds = origin.Seconds
+	}
+	// 本次请求指定了 size 时，宽高比与分辨率由新的 size 推导，不再继承
+	if t.Size == "" {
+		t.Size = origin.Size
+		if t.AspectRatio == "" {
+			t.AspectRatio = origin.AspectRatio
+		}
+		if t.Resolution == "" {
+			t.Resolution = origin.Resolution
+		}
+	}
+	if len(t.Images) == 0 && t.Image == "" && t.InputReference == "" {
+		t.Images = origin.Images
+	}
+	for k, v := range origin.Metadata {
+		if t.Metadata == nil {
+			t.Metadata = make(map[string]interface{})
+		}
+		if _, ok := t.Metadata[k]; !ok {
+			t.Metadata[k] = v
+		}
+	}
+	return nil
+}
+
+// VideoInputJSON 序列化用于保存到任务的统一参数，内联的 base64 图片不落库
+func (t *TaskSubmitReq) VideoInputJSON() string {
+	input := *t
+	input.Images = make([]string, 0, len(t.Images))
+	for _, image := range t.Images {
+		if strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
+			input.Images = append(input.Images, image)
+		}
+	}
+	input.Image = ""
+	input.InputReference = ""
+	data, err := common.Marshal(input)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// GetTaskCallbackURL 读取任务提交请求中的 callback_url（JSON 字段或 multipart 表单字段）
+func GetTaskCallbackURL(c *gin.Context) string {
+	if strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/form-data") {
+		form, err := common.ParseMultipartFormReusable(c)
+		if err != nil {
+			return ""
+		}
+		defer form.RemoveAll()
+		if values := form.Value["callback_url"]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return ""
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(gjson.GetBytes(body, "callback_url").String())
+}
+
+// readInputReferenceFile 读取 multipart 请求中以文件形式上传的 input_reference，转换为 data URL
+func readInputReferenceFile(c *gin.Context) (string, error) {
+	form := c.Request.MultipartForm
+	if form == nil {
+		// 从可复用的请求体中解析，避免消费原始 body（部分平台需要原样透传）
+		_, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || params["boundary"] == "" {
+			return "", nil
+		}
+		storage, err := common.GetBodyStorage(c)
+		if err != nil {
+			return "", err
+		}
+		body, err := storage.Bytes()
+		if err != nil {
+			return "", err
+		}
+		form, err = multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(32 << 20)
+		if err != nil {
+			return "", err
+		}
+		defer form.RemoveAll()
+	}
+	files := form.File["input_reference"]
+	if len(files) == 0 {
+		return "", nil
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	mimeType := files[0].Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// prepareVideoTaskRequest 统一参数并处理 remix 的参数继承
+func prepareVideoTaskRequest(c *gin.Context, info *RelayInfo, req *TaskSubmitReq) error {
+	if strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/form-data") && req.InputReference == "" {
+		reference, err := readInputReferenceFile(c)
+		if err != nil {
+			return fmt.Errorf("read input_reference failed: %w", err)
+		}
+		req.InputReference = reference
+	}
+	if info.TaskRelayInfo != nil && info.OriginTaskInput != "" {
+		if err := req.MergeOriginVideoInput(info.OriginTaskInput); err != nil {
+			return fmt.Errorf("read origin task input failed: %w", err)
+		}
+	}
+	req.NormalizeVideoParams()
+	return nil
+}
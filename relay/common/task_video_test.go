@@ -0,0 +1,72 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeVideoParams(t *testing.T) {
+	req := TaskSubmitReq{
+		Prompt:         "a cat",
+		Size:           "1280x720",
+		Seconds:        "8",
+		InputReference: "https://example.com/a.png",
+	}
+	req.NormalizeVideoParams()
+
+	assert.Equal(t, 8, req.Duration)
+	assert.Equal(t, "16:9", req.AspectRatio)
+	assert.Equal(t, "720p", req.Resolution)
+	assert.Equal(t, []string{"https://example.com/a.png"}, req.Images)
+	assert.Equal(t, "https://example.com/a.png", req.Image)
+}
+
+func TestNormalizeVideoParamsKeepsExplicitValues(t *testing.T) {
+	req := TaskSubmitReq{
+		Size:        "1792x1024",
+		Duration:    5,
+		AspectRatio: "1:1",
+		Resolution:  "1080P",
+	}
+	req.NormalizeVideoParams()
+
+	assert.Equal(t, "5", req.Seconds)
+	assert.Equal(t, "1:1", req.AspectRatio)
+	assert.Equal(t, "1080p", req.Resolution)
+}
+
+func TestVideoSizeConversion(t *testing.T) {
+	assert.Equal(t, "9:16", VideoSizeToAspectRatio("720x1280"))
+	assert.Equal(t, "16:9", VideoSizeToAspectRatio("1920*1080"))
+	assert.Equal(t, "", VideoSizeToAspectRatio("720p"))
+	assert.Equal(t, "1080p", VideoSizeToResolution("1080x1920"))
+	assert.Equal(t, "4k", VideoSizeToResolution("3840x2160"))
+	assert.Equal(t, 2160, ResolutionHeight("4K"))
+	assert.Equal(t, 720, ResolutionHeight("720P"))
+}
+
+func TestMergeOriginVideoInput(t *testing.T) {
+	origin := TaskSubmitReq{
+		Model:    "kling-v1",
+		Size:     "1280x720",
+		Duration: 10,
+		Images:   []string{"https://example.com/a.png", "data:image/png;base64,AAAA"},
+		Metadata: map[string]interface{}{"cfg_scale": 0.3},
+	}
+	origin.NormalizeVideoParams()
+	input := origin.VideoInputJSON()
+
+	req := TaskSubmitReq{Prompt: "make it snow", Size: "720x1280"}
+	require.NoError(t, req.MergeOriginVideoInput(input))
+	req.NormalizeVideoParams()
+
+	assert.Equal(t, "kling-v1", req.Model)
+	assert.Equal(t, "720x1280", req.Size)
+	assert.Equal(t, "9:16", req.AspectRatio)
+	assert.Equal(t, 10, req.Duration)
+	// 内联图片不落库，仅继承 URL 形式的参考图
+	assert.Equal(t, []string{"https://example.com/a.png"}, req.Images)
+	assert.Equal(t, 0.3, req.Metadata["cfg_scale"])
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
-	"github.com/QuantumNous/new-api/relay/channel/xinference"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
@@ -15,6 +14,145 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+type cohereRerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	TopN            int      `json:"top_n,omitempty"`
+	ReturnDocuments bool     `json:"return_documents"`
+}
+
+type voyageRerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	TopK            int      `json:"top_k,omitempty"`
+	ReturnDocuments bool     `json:"return_documents"`
+}
+
+type rerankTokens struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// upstreamRerankResponse 兼容 Jina / Cohere / Voyage / Xinference 的响应字段
+type upstreamRerankResponse struct {
+	Results []dto.RerankResponseResult `json:"results"`
+	Data    []dto.RerankResponseResult `json:"data"` // Voyage
+	Usage   *dto.Usage                 `json:"usage"`
+	Meta    *struct {
+		BilledUnits *rerankTokens `json:"billed_units"`
+		Tokens      *rerankTokens `json:"tokens"`
+	} `json:"meta"`
+}
+
+// GetRerankFormat 优先使用渠道设置中的重排序格式，否则按渠道类型推断
+func GetRerankFormat(c *gin.Context) dto.RerankFormat {
+	settings, _ := common.GetContextKeyType[dto.ChannelOtherSettings](c, constant.ContextKeyChannelOtherSetting)
+	if settings.RerankFormat != "" {
+		return settings.RerankFormat
+	}
+	switch common.GetContextKeyInt(c, constant.ContextKeyChannelType) {
+	case constant.ChannelTypeCohere:
+		return dto.RerankFormatCohere
+	case constant.ChannelTypeXinference:
+		return dto.RerankFormatXinference
+	}
+	return dto.RerankFormatJina
+}
+
+func rerankDocumentText(document any) string {
+	switch doc := document.(type) {
+	case string:
+		return doc
+	case map[string]any:
+		if text, ok := doc["text"].(string); ok {
+			return text
+		}
+	}
+	data, _ := common.Marshal(document)
+	return string(data)
+}
+
+func rerankDocumentTexts(documents []any) []string {
+	texts := make([]string, 0, len(documents))
+	for _, document := range documents {
+		texts = append(texts, rerankDocumentText(document))
+	}
+	return texts
+}
+
+// ConvertRerankRequest 将 Jina 格式的重排序请求转换为上游格式
+func ConvertRerankRequest(format dto.RerankFormat, request dto.RerankRequest) any {
+	switch format {
+	case dto.RerankFormatCohere:
+		return cohereRerankRequest{
+			Model:           request.Model,
+			Query:           request.Query,
+			Documents:       rerankDocumentTexts(request.Documents),
+			TopN:            request.TopN,
+			ReturnDocuments: request.GetReturnDocuments(),
+		}
+	case dto.RerankFormatVoyage:
+		return voyageRerankRequest{
+			Model:           request.Model,
+			Query:           request.Query,
+			Documents:       rerankDocumentTexts(request.Documents),
+			TopK:            request.TopN,
+			ReturnDocuments: request.GetReturnDocuments(),
+		}
+	}
+	return request
+}
+
+// NormalizeRerankResponse 将上游重排序响应统一为 Jina 格式，上游未返回用量时使用预估值
+func NormalizeRerankResponse(info *relaycommon.RelayInfo, responseBody []byte) (*dto.RerankResponse, error) {
+	var upstreamResp upstreamRerankResponse
+	if err := common.Unmarshal(responseBody, &upstreamResp); err != nil {
+		return nil, err
+	}
+	results := upstreamResp.Results
+	if len(results) == 0 {
+		results = upstreamResp.Data
+	}
+	rerankResp := &dto.RerankResponse{
+		Results: make([]dto.RerankResponseResult, 0, len(results)),
+	}
+	for _, result := range results {
+		respResult := dto.RerankResponseResult{
+			Index:          result.Index,
+			RelevanceScore: result.RelevanceScore,
+		}
+		if info.ReturnDocuments {
+			document := result.Document
+			if doc, ok := document.(string); document == nil || (ok && doc == "") {
+				if result.Index >= 0 && result.Index < len(info.Documents) {
+					document = info.Documents[result.Index]
+				}
+			}
+			respResult.Document = document
+		}
+		rerankResp.Results = append(rerankResp.Results, respResult)
+	}
+
+	usage := &rerankResp.Usage
+	switch {
+	case upstreamResp.Usage != nil && (upstreamResp.Usage.PromptTokens > 0 || upstreamResp.Usage.TotalTokens > 0):
+		usage.PromptTokens = upstreamResp.Usage.PromptTokens
+		if usage.PromptTokens == 0 {
+			usage.PromptTokens = upstreamResp.Usage.TotalTokens
+		}
+	case upstreamResp.Meta != nil && upstreamResp.Meta.BilledUnits != nil && upstreamResp.Meta.BilledUnits.InputTokens > 0:
+		usage.PromptTokens = upstreamResp.Meta.BilledUnits.InputTokens
+	case upstreamResp.Meta != nil && upstreamResp.Meta.Tokens != nil && upstreamResp.Meta.Tokens.InputTokens > 0:
+		usage.PromptTokens = upstreamResp.Meta.Tokens.InputTokens
+	default:
+		usage.PromptTokens = info.GetEstimatePromptTokens()
+	}
+	usage.TotalTokens = usage.PromptTokens
+	return rerankResp, nil
+}
+
 func RerankHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -24,52 +162,12 @@ func RerankHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Respo
 	if common.DebugEnabled {
 		println("reranker response body: ", string(responseBody))
 	}
-	var jinaResp dto.RerankResponse
-	if info.ChannelType == constant.ChannelTypeXinference {
-		var xinRerankResponse xinference.XinRerankResponse
-		err = common.Unmarshal(responseBody, &xinRerankResponse)
-		if err != nil {
-			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
-		}
-		jinaRespResults := make([]dto.RerankResponseResult, len(xinRerankResponse.Results))
-		for i, result := range xinRerankResponse.Results {
-			respResult := dto.RerankResponseResult{
-				Index:          result.Index,
-				RelevanceScore: result.RelevanceScore,
-			}
-			if info.ReturnDocuments {
-				var document any
-				if result.Document != nil {
-					if doc, ok := result.Document.(string); ok {
-						if doc == "" {
-							document = info.Documents[result.Index]
-						} else {
-							document = doc
-						}
-					} else {
-						document = result.Document
-					}
-				}
-				respResult.Document = document
-			}
-			jinaRespResults[i] = respResult
-		}
-		jinaResp = dto.RerankResponse{
-			Results: jinaRespResults,
-			Usage: dto.Usage{
-				PromptTokens: info.GetEstimatePromptTokens(),
-				TotalTokens:  info.GetEstimatePromptTokens(),
-			},
-		}
-	} else {
-		err = common.Unmarshal(responseBody, &jinaResp)
-		if err != nil {
-			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
-		}
-		jinaResp.Usage.PromptTokens = jinaResp.Usage.TotalTokens
+	rerankResp, err := NormalizeRerankResponse(info, responseBody)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
 
 	c.Writer.Header().Set("Content-Type", "application/json")
-	c.JSON(http.StatusOK, jinaResp)
-	return &jinaResp.Usage, nil
+	c.JSON(http.StatusOK, rerankResp)
+	return &rerankResp.Usage, nil
 }
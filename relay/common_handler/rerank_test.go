@@ -0,0 +1,63 @@
+package common_handler
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertRerankRequestCohereAndVoyage(t *testing.T) {
+	returnDocuments := true
+	request := dto.RerankRequest{
+		Model:           "rerank-v3.5",
+		Query:           "q",
+		Documents:       []any{"a", map[string]any{"text": "b"}},
+		TopN:            2,
+		ReturnDocuments: &returnDocuments,
+	}
+
+	cohereReq, ok := ConvertRerankRequest(dto.RerankFormatCohere, request).(cohereRerankRequest)
+	require.True(t, ok)
+	require.Equal(t, []string{"a", "b"}, cohereReq.Documents)
+	require.Equal(t, 2, cohereReq.TopN)
+	require.True(t, cohereReq.ReturnDocuments)
+
+	voyageReq, ok := ConvertRerankRequest(dto.RerankFormatVoyage, request).(voyageRerankRequest)
+	require.True(t, ok)
+	require.Equal(t, 2, voyageReq.TopK)
+
+	_, ok = ConvertRerankRequest(dto.RerankFormatJina, request).(dto.RerankRequest)
+	require.True(t, ok)
+}
+
+func TestNormalizeRerankResponse(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RerankerInfo: &relaycommon.RerankerInfo{
+			Documents:       []any{"a", "b"},
+			ReturnDocuments: true,
+		},
+	}
+	info.SetEstimatePromptTokens(7)
+
+	// Voyage: data + usage.total_tokens
+	resp, err := NormalizeRerankResponse(info, []byte(`{"object":"list","data":[{"index":1,"relevance_score":0.9}],"usage":{"total_tokens":12}}`))
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 1)
+	require.Equal(t, "b", resp.Results[0].Document)
+	require.Equal(t, 12, resp.Usage.PromptTokens)
+	require.Equal(t, 12, resp.Usage.TotalTokens)
+
+	// Cohere: meta.billed_units
+	resp, err = NormalizeRerankResponse(info, []byte(`{"results":[{"index":0,"relevance_score":0.5,"document":{"text":"a"}}],"meta":{"billed_units":{"search_units":1,"input_tokens":30}}}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"text": "a"}, resp.Results[0].Document)
+	require.Equal(t, 30, resp.Usage.PromptTokens)
+
+	// Xinference: 空文档回填，无用量时使用预估值
+	resp, err = NormalizeRerankResponse(info, []byte(`{"results":[{"index":0,"relevance_score":0.5,"document":""}],"meta":null}`))
+	require.NoError(t, err)
+	require.Equal(t, "a", resp.Results[0].Document)
+	require.Equal(t, 7, resp.Usage.PromptTokens)
+}
@@ -69,13 +69,28 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 	info.ShouldIncludeUsage = includeUsage
 
+	passThroughGlobal := model_setting.GetGlobalSettings().PassThroughRequestEnabled
+	// 仅支持 chat 的渠道：completions 请求转换为 chat 请求，响应再转换回 text_completion
+	restoreCompletions := func() {}
+	if info.RelayMode == relayconstant.RelayModeCompletions &&
+		!passThroughGlobal &&
+		!info.ChannelSetting.PassThroughBodyEnabled &&
+		service.ShouldCompletionsUseChatGlobal(info.ChannelId, info.ChannelType, info.OriginModelName) {
+		chatReq, restore, newApiErr := completionsViaChat(c, info, request)
+		if newApiErr != nil {
+			return newApiErr
+		}
+		defer restore()
+		restoreCompletions = restore
+		request = chatReq
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
 
-	passThroughGlobal := model_setting.GetGlobalSettings().PassThroughRequestEnabled
 	if info.RelayMode == relayconstant.RelayModeChatCompletions &&
 		!passThroughGlobal &&
 		!info.ChannelSetting.PassThroughBodyEnabled &&
@@ -111,7 +126,9 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 		requestBody = common.ReaderOnly(storage)
 	} else {
-		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, request)
+		convertedRequest, err := traceConvertRequest(c, info, func() (any, error) {
+			return adaptor.ConvertOpenAIRequest(c, info, request)
+		})
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
@@ -178,13 +195,19 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 			}
 		}
 
+		// replace gateway file ids with upstream file ids
+		jsonData, err = service.ResolveUpstreamFileIds(c, info, jsonData)
+		if err != nil {
+			return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+		}
+
 		logger.LogDebug(c, fmt.Sprintf("text request body: %s", string(jsonData)))
 
 		requestBody = bytes.NewBuffer(jsonData)
 	}
 
 	var httpResp *http.Response
-	resp, err := adaptor.DoRequest(c, info, requestBody)
+	resp, err := doAdaptorRequest(c, adaptor, info, requestBody)
 	if err != nil {
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
 	}
@@ -202,12 +225,14 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
-	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	usage, newApiErr := doAdaptorResponse(c, adaptor, httpResp, info)
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
+	// 计费与写入响应缓存前先输出转换后的响应
+	restoreCompletions()
 
 	var containAudioTokens = usage.(*dto.Usage).CompletionTokenDetails.AudioTokens > 0 || usage.(*dto.Usage).PromptTokensDetails.AudioTokens > 0
 	var containsAudioRatios = ratio_setting.ContainsAudioRatio(info.OriginModelName) || ratio_setting.ContainsAudioCompletionRatio(info.OriginModelName)
@@ -231,8 +256,19 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		extraContent = append(extraContent, "上游无计费信息")
 	}
 
+	// 按实际用量匹配计价规则（长上下文分段、错峰等），输入 token 按包含缓存的总量判断
+	ruleInputTokens := usage.PromptTokens
+	if relayInfo.GetFinalRequestRelayFormat() == types.RelayFormatClaude {
+		ruleInputTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	service.ApplyPricingRule(relayInfo, ruleInputTokens, usage.CompletionTokens)
+	if ruleContent := service.PricingRuleLogContent(relayInfo); ruleContent != "" {
+		extraContent = append(extraContent, ruleContent)
+	}
+
 	if originUsage != nil {
 		service.ObserveChannelAffinityUsageCacheByRelayFormat(ctx, usage, relayInfo.GetFinalRequestRelayFormat())
+		storeResponseCache(ctx, relayInfo, usage)
 	}
 
 	adminRejectReason := common.GetContextKeyString(ctx, constant.ContextKeyAdminRejectReason)
@@ -273,6 +309,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 
 	ratio := dModelRatio.Mul(dGroupRatio)
+	// Batch API 折扣与分组倍率分开记录，作用于包括工具调用在内的全部额度
+	batchRatio := relayInfo.PriceData.BatchDiscount()
 
 	// openai web search 工具计费
 	var dWebSearchQuota decimal.Decimal
@@ -403,6 +441,10 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 			extraContent = append(extraContent, fmt.Sprintf("其他倍率 %s: %f", key, otherRatio))
 		}
 	}
+	if relayInfo.PriceData.HasBatchRatio {
+		quotaCalculateDecimal = quotaCalculateDecimal.Mul(decimal.NewFromFloat(batchRatio))
+		extraContent = append(extraContent, fmt.Sprintf("批处理倍率 %.2f", batchRatio))
+	}
 
 	quota := int(quotaCalculateDecimal.Round(0).IntPart())
 	totalTokens := promptTokens + completionTokens
@@ -418,11 +460,14 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, relayInfo.FinalPreConsumedQuota))
 	} else {
-		if !ratio.IsZero() && quota == 0 {
+		if !ratio.IsZero() && batchRatio != 0 && quota == 0 {
 			quota = 1
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		// 命中响应缓存时没有实际使用渠道，不计入渠道用量
+		if !relayInfo.ResponseCacheHit {
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		}
 	}
 
 	if err := service.SettleBilling(ctx, relayInfo, quota); err != nil {
@@ -490,6 +535,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		other["image_generation_call"] = true
 		other["image_generation_call_price"] = imageGenerationCallPrice
 	}
+	service.ReportRelayUsage(ctx, relayInfo, logModel, promptTokens, completionTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -0,0 +1,157 @@
+package relay
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// completionsViaChat 将 completions 请求改写为 chat 请求，并替换 c.Writer 把渠道输出的 chat 响应转换回 text_completion。
+// 返回的 restore 需在本次尝试结束时调用（可重复调用），用于写出缓冲的响应并恢复 RelayMode 与 c.Writer，以免影响重试到其他渠道
+func completionsViaChat(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*dto.GeneralOpenAIRequest, func(), *types.NewAPIError) {
+	chatReq, err := service.CompletionsRequestToChatRequest(request, model_setting.GetGlobalSettings().CompletionsToChatPolicy)
+	if err != nil {
+		return nil, nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	echo := ""
+	if request.Echo {
+		echo = service.CompletionsPromptText(request)
+	}
+
+	savedRelayMode := info.RelayMode
+	savedRequestURLPath := info.RequestURLPath
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+
+	writer := &completionsChatWriter{
+		ResponseWriter: c.Writer,
+		echo:           echo,
+		echoed:         make(map[int]bool),
+		status:         http.StatusOK,
+	}
+	c.Writer = writer
+	restored := false
+	restore := func() {
+		if restored {
+			return
+		}
+		restored = true
+		writer.finish()
+		c.Writer = writer.ResponseWriter
+		info.RelayMode = savedRelayMode
+		info.RequestURLPath = savedRequestURLPath
+	}
+	return chatReq, restore, nil
+}
+
+// completionsChatWriter 将 chat 格式的响应改写为 text_completion：
+// 流式响应逐行转换 SSE 数据，非流式响应缓冲后整体转换；错误响应与无法识别的内容原样输出
+type completionsChatWriter struct {
+	gin.ResponseWriter
+	echo        string
+	echoed      map[int]bool
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+}
+
+func (w *completionsChatWriter) isStream() bool {
+	return strings.HasPrefix(w.ResponseWriter.Header().Get("Content-Type"), "text/event-stream")
+}
+
+func (w *completionsChatWriter) WriteHeader(code int) {
+	if w.isStream() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+}
+
+func (w *completionsChatWriter) Flush() {
+	if w.isStream() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *completionsChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *completionsChatWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	if !w.isStream() {
+		return len(data), nil
+	}
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(w.buf.Next(idx + 1))
+		if _, err := w.ResponseWriter.WriteString(w.convertStreamLine(line)); err != nil {
+			return len(data), err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *completionsChatWriter) convertStreamLine(line string) string {
+	payload, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "data:")
+	if !ok {
+		return line
+	}
+	payload = strings.TrimSpace(payload)
+	if payload == "[DONE]" || !gjson.Valid(payload) || gjson.Get(payload, "error").Exists() {
+		return line
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+		return line
+	}
+	converted, err := common.Marshal(service.ChatStreamResponseToCompletionsResponse(&chunk, w.echo, w.echoed))
+	if err != nil {
+		return line
+	}
+	return "data: " + string(converted) + "\n"
+}
+
+// finish 写出剩余内容：流式时输出未以换行结尾的残留数据，非流式时转换整个响应体
+func (w *completionsChatWriter) finish() {
+	if w.isStream() {
+		if w.buf.Len() > 0 {
+			_, _ = w.ResponseWriter.WriteString(w.convertStreamLine(w.buf.String()))
+			w.buf.Reset()
+		}
+		return
+	}
+	if w.buf.Len() == 0 && !w.wroteHeader {
+		return
+	}
+	body := w.buf.Bytes()
+	if w.status == http.StatusOK && gjson.GetBytes(body, "choices").Exists() {
+		var chatResp dto.OpenAITextResponse
+		if err := common.Unmarshal(body, &chatResp); err == nil {
+			if converted, err := common.Marshal(service.ChatResponseToCompletionsResponse(&chatResp, w.echo)); err == nil {
+				body = converted
+			}
+		}
+	}
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+	w.buf.Reset()
+}
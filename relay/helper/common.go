@@ -78,6 +78,18 @@ func ResponseChunkData(c *gin.Context, resp dto.ResponsesStreamResponse, data st
 	_ = FlushWriter(c)
 }
 
+// ResponsesData 发送由其他格式转换而来的 Responses 流式事件
+func ResponsesData(c *gin.Context, events []dto.ResponsesStreamResponse) error {
+	for _, event := range events {
+		jsonData, err := common.Marshal(event)
+		if err != nil {
+			return err
+		}
+		ResponseChunkData(c, event, string(jsonData))
+	}
+	return nil
+}
+
 func StringData(c *gin.Context, str string) error {
 	if c == nil || c.Writer == nil {
 		return errors.New("context or writer is nil")
@@ -91,6 +103,11 @@ func StringData(c *gin.Context, str string) error {
 	return FlushWriter(c)
 }
 
+// PingWriter 需要自行决定 ping 输出位置的响应写入器，例如尚未决出胜负的对冲尝试
+type PingWriter interface {
+	WritePing(data []byte) error
+}
+
 func PingData(c *gin.Context) error {
 	if c == nil || c.Writer == nil {
 		return errors.New("context or writer is nil")
@@ -100,6 +117,9 @@ func PingData(c *gin.Context) error {
 		return fmt.Errorf("request context done: %w", c.Request.Context().Err())
 	}
 
+	if writer, ok := c.Writer.(PingWriter); ok {
+		return writer.WritePing([]byte(": PING\n\n"))
+	}
 	if _, err := c.Writer.Write([]byte(": PING\n\n")); err != nil {
 		return fmt.Errorf("write ping data failed: %w", err)
 	}
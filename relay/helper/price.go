@@ -49,6 +49,11 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
 
 	groupRatioInfo := HandleGroupRatio(c, info)
+	// Batch API 折扣单独记录，不乘入分组倍率
+	batchRatio := 1.0
+	if info.BatchId != "" {
+		batchRatio = ratio_setting.GetBatchRatio(info.OriginModelName)
+	}
 
 	var preConsumedQuota int
 	var modelRatio float64
@@ -61,6 +66,8 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var audioRatio float64
 	var audioCompletionRatio float64
 	var freeModel bool
+	// 预扣费按估算用量匹配计价规则，仅影响预扣额度，结算时按实际用量重新匹配
+	pricingRule, pricingRuleMatched := ratio_setting.EvaluatePricingRule(info.OriginModelName, info.UsingGroup, promptTokens, meta.MaxTokens, info.StartTime)
 	if !usePrice {
 		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
@@ -87,19 +94,26 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
 		audioRatio = ratio_setting.GetAudioRatio(info.OriginModelName)
 		audioCompletionRatio = ratio_setting.GetAudioCompletionRatio(info.OriginModelName)
-		ratio := modelRatio * groupRatioInfo.GroupRatio
+		preConsumedRatio := modelRatio
+		if pricingRuleMatched {
+			if pricingRule.ModelRatio > 0 {
+				preConsumedRatio = pricingRule.ModelRatio
+			}
+			preConsumedRatio *= pricingRule.OffPeakRatio
+		}
+		ratio := preConsumedRatio * groupRatioInfo.GroupRatio * batchRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
 		if meta.ImagePriceRatio != 0 {
 			modelPrice = modelPrice * meta.ImagePriceRatio
 		}
-		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
+		preConsumedQuota = int(modelPrice * pricingRule.OffPeakRatio * common.QuotaPerUnit * groupRatioInfo.GroupRatio * batchRatio)
 	}
 
 	// check if free model pre-consume is disabled
 	if !operation_setting.GetQuotaSetting().EnableFreeModelPreConsume {
 		// if model price or ratio is 0, do not pre-consume quota
-		if groupRatioInfo.GroupRatio == 0 {
+		if groupRatioInfo.GroupRatio == 0 || batchRatio == 0 {
 			preConsumedQuota = 0
 			freeModel = true
 		} else if usePrice {
@@ -121,6 +135,8 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		ModelRatio:           modelRatio,
 		CompletionRatio:      completionRatio,
 		GroupRatioInfo:       groupRatioInfo,
+		BatchRatio:           batchRatio,
+		HasBatchRatio:        info.BatchId != "",
 		UsePrice:             usePrice,
 		CacheRatio:           cacheRatio,
 		ImageRatio:           imageRatio,
@@ -0,0 +1,88 @@
+package helper
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelPriceHelper_PreConsumeAppliesPricingRule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ratio_setting.InitRatioSettings()
+
+	setting := ratio_setting.GetPricingRuleSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+
+	newInfo := func() *relaycommon.RelayInfo {
+		return &relaycommon.RelayInfo{
+			OriginModelName: "gpt-4o",
+			UsingGroup:      "default",
+			StartTime:       time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		}
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	meta := &types.TokenCountMeta{MaxTokens: 1000}
+
+	setting.Enabled = false
+	base, err := ModelPriceHelper(c, newInfo(), 5000, meta)
+	require.NoError(t, err)
+	require.Positive(t, base.QuotaToPreConsume)
+
+	setting.Enabled = true
+	setting.Rules = map[string]ratio_setting.PricingRule{
+		"gpt-4o": {
+			InputTiers: []ratio_setting.PricingTier{
+				{MinTokens: 4000, ModelRatio: base.ModelRatio * 4},
+			},
+			OffPeakWindows: []ratio_setting.OffPeakWindow{
+				{Start: "10:00", End: "14:00", Timezone: "UTC", Ratio: 0.5},
+			},
+		},
+	}
+	info := newInfo()
+	priced, err := ModelPriceHelper(c, info, 5000, meta)
+	require.NoError(t, err)
+
+	// 预扣按命中档位与错峰倍率估算：4 × 0.5 = 2 倍
+	assert.InDelta(t, base.QuotaToPreConsume*2, priced.QuotaToPreConsume, 1)
+	// 倍率与命中记录留给结算时按实际用量重新匹配
+	assert.Equal(t, base.ModelRatio, priced.ModelRatio)
+	assert.Nil(t, info.PriceData.PricingRule)
+}
+
+func TestModelPriceHelper_BatchRatioKeptSeparateFromGroupRatio(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ratio_setting.InitRatioSettings()
+
+	setting := ratio_setting.GetBatchRatioSetting()
+	saved := *setting
+	setting.DefaultRatio = 0.5
+	t.Cleanup(func() { *setting = saved })
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	meta := &types.TokenCountMeta{MaxTokens: 1000}
+	newInfo := func(batchId string) *relaycommon.RelayInfo {
+		return &relaycommon.RelayInfo{OriginModelName: "gpt-4o", UsingGroup: "default", BatchId: batchId}
+	}
+
+	base, err := ModelPriceHelper(c, newInfo(""), 5000, meta)
+	require.NoError(t, err)
+	assert.False(t, base.HasBatchRatio)
+	assert.Equal(t, 1.0, base.BatchDiscount())
+
+	batch, err := ModelPriceHelper(c, newInfo("batch_1"), 5000, meta)
+	require.NoError(t, err)
+	// 分组倍率保持不变，批处理折扣单独记录并作用于预扣额度
+	assert.Equal(t, base.GroupRatioInfo.GroupRatio, batch.GroupRatioInfo.GroupRatio)
+	assert.True(t, batch.HasBatchRatio)
+	assert.Equal(t, 0.5, batch.BatchDiscount())
+	assert.InDelta(t, base.QuotaToPreConsume/2, batch.QuotaToPreConsume, 1)
+}
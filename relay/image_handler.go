@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -53,7 +54,9 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		}
 		requestBody = common.ReaderOnly(storage)
 	} else {
-		convertedRequest, err := adaptor.ConvertImageRequest(c, info, *request)
+		convertedRequest, err := traceConvertRequest(c, info, func() (any, error) {
+			return adaptor.ConvertImageRequest(c, info, *request)
+		})
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed)
 		}
@@ -85,7 +88,7 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
 
-	resp, err := adaptor.DoRequest(c, info, requestBody)
+	resp, err := doAdaptorRequest(c, adaptor, info, requestBody)
 	if err != nil {
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
 	}
@@ -106,13 +109,24 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		}
 	}
 
-	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	usage, newAPIError := doAdaptorResponse(c, adaptor, httpResp, info)
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 		return newAPIError
 	}
 
+	// 按次计费时按生成张数与分辨率计入其他倍率，适配器已按实际生成张数设置 n 时以适配器为准
+	if info.PriceData.UsePrice {
+		if _, ok := info.PriceData.OtherRatios["n"]; !ok && request.N > 1 {
+			info.PriceData.AddOtherRatio("n", float64(request.N))
+		}
+		sizeRatio := imageReq.GetPriceRatio() * ratio_setting.GetImageSizeRatio(info.OriginModelName, request.Size, request.Quality)
+		if sizeRatio != 1 {
+			info.PriceData.AddOtherRatio("size", sizeRatio)
+		}
+	}
+
 	if usage.(*dto.Usage).TotalTokens == 0 {
 		usage.(*dto.Usage).TotalTokens = int(request.N)
 	}
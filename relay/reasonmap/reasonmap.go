@@ -39,3 +39,14 @@ func OpenAIFinishReasonToClaudeStopReason(finishReason string) string {
 		return finishReason
 	}
 }
+
+func ClaudeStopReasonToGeminiFinishReason(stopReason string) string {
+	switch strings.ToLower(stopReason) {
+	case "max_tokens":
+		return "MAX_TOKENS"
+	case "refusal":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
@@ -0,0 +1,43 @@
+package relay
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// claimHedgeResponse 对冲请求在上游开始返回正文时参与竞速
+// 失败状态码交由原有流程处理，不参与竞速；落败的尝试直接关闭响应，不会进入响应处理与计费
+func claimHedgeResponse(info *relaycommon.RelayInfo, resp any, err error) (any, error) {
+	if info.Hedge == nil || err != nil {
+		return resp, err
+	}
+	httpResp, ok := resp.(*http.Response)
+	if !ok || httpResp == nil {
+		if !info.Hedge.Claim() {
+			return nil, relaycommon.ErrHedgeLost
+		}
+		return resp, nil
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	reader := bufio.NewReader(httpResp.Body)
+	if _, peekErr := reader.Peek(1); peekErr != nil && peekErr != io.EOF {
+		_ = httpResp.Body.Close()
+		return nil, peekErr
+	}
+	httpResp.Body = peekedBody{Reader: reader, Closer: httpResp.Body}
+	if !info.Hedge.Claim() {
+		_ = httpResp.Body.Close()
+		return nil, relaycommon.ErrHedgeLost
+	}
+	return httpResp, nil
+}
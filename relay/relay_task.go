@@ -20,6 +20,8 @@ import (
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 type TaskSubmitResult struct {
@@ -105,6 +107,8 @@ func ResolveOriginTask(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskErr
 
 	// 提取 remix 参数（时长、分辨率 → OtherRatios）
 	if info.Action == constant.TaskActionRemix {
+		info.OriginUpstreamTaskID = originTask.GetUpstreamTaskID()
+		info.OriginTaskInput = originTask.Properties.Input
 		if originTask.PrivateData.BillingContext != nil {
 			// 新的 remix 逻辑：直接从原始任务的 BillingContext 中提取 OtherRatios（如果存在）
 			for s, f := range originTask.PrivateData.BillingContext.OtherRatios {
@@ -141,6 +145,8 @@ func ResolveOriginTask(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskErr
 // 控制器负责 defer Refund 和成功后 Settle。
 func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitResult, *dto.TaskError) {
 	info.InitChannelMeta(c)
+	// 回调地址由本次尝试的适配器决定，重试切换渠道时需重置
+	info.UpstreamCallback = false
 
 	// 1. 确定 platform → 创建适配器 → 验证请求
 	platform := constant.TaskPlatform(c.GetString("platform"))
@@ -380,21 +386,10 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 
 	// OpenAI Video API 格式: 走各 adaptor 的 ConvertToOpenAIVideo
 	if isOpenAIVideoAPI {
-		adaptor := GetTaskAdaptor(originTask.Platform)
-		if adaptor == nil {
-			taskResp = service.TaskErrorWrapperLocal(fmt.Errorf("invalid channel id: %d", originTask.ChannelId), "invalid_channel_id", http.StatusBadRequest)
-			return
-		}
-		if converter, ok := adaptor.(channel.OpenAIVideoConverter); ok {
-			openAIVideoData, err := converter.ConvertToOpenAIVideo(originTask)
-			if err != nil {
-				taskResp = service.TaskErrorWrapper(err, "convert_to_openai_video_failed", http.StatusInternalServerError)
-				return
-			}
-			respBody = openAIVideoData
-			return
+		respBody, err = OpenAIVideoResponse(originTask)
+		if err != nil {
+			taskResp = service.TaskErrorWrapper(err, "convert_to_openai_video_failed", http.StatusInternalServerError)
 		}
-		taskResp = service.TaskErrorWrapperLocal(fmt.Errorf("not_implemented:%s", originTask.Platform), "not_implemented", http.StatusNotImplemented)
 		return
 	}
 
@@ -409,6 +404,62 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 	return
 }
 
+// OpenAIVideoResponse 将任务转换为 OpenAI Video 对象：优先使用 adaptor 的 ConvertToOpenAIVideo，
+// 再用提交时保存的统一参数补全上游未返回的通用字段（时长、尺寸、remix 来源等）
+func OpenAIVideoResponse(task *model.Task) ([]byte, error) {
+	var data []byte
+	var err error
+	if converter, ok := GetTaskAdaptor(task.Platform).(channel.OpenAIVideoConverter); ok {
+		data, err = converter.ConvertToOpenAIVideo(task)
+	} else {
+		data, err = common.Marshal(task.ToOpenAIVideo())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var input relaycommon.TaskSubmitReq
+	if task.Properties.Input != "" {
+		_ = common.UnmarshalJsonStr(task.Properties.Input, &input)
+	}
+	fields := []struct {
+		path  string
+		value string
+	}{
+		{"id", task.TaskID},
+		{"model", task.Properties.OriginModelName},
+		{"seconds", input.Seconds},
+		{"size", input.Size},
+		{"remixed_from_video_id", task.Properties.RemixedFrom},
+	}
+	for _, field := range fields {
+		if field.value == "" || gjson.GetBytes(data, field.path).String() != "" {
+			continue
+		}
+		if data, err = sjson.SetBytes(data, field.path, field.value); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// DeleteUpstreamVideo 上游支持删除时同步删除上游视频，其他平台直接返回
+func DeleteUpstreamVideo(task *model.Task) error {
+	deleter, ok := GetTaskAdaptor(task.Platform).(channel.OpenAIVideoDeleter)
+	if !ok {
+		return nil
+	}
+	channelModel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return err
+	}
+	baseURL := channelModel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channelModel.Type]
+	}
+	return deleter.DeleteOpenAIVideo(baseURL, channelModel.Key, channelModel.GetSetting().Proxy, task)
+}
+
 // tryRealtimeFetch 尝试从上游实时拉取 Gemini/Vertex 任务状态。
 // 仅当渠道类型为 Gemini 或 Vertex 时触发；其他渠道或出错时返回 nil。
 // 当非 OpenAI Video API 时，还会构建自定义格式的响应体。
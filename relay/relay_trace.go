@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/pkg/tracing"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func adaptorSpanAttributes(info *relaycommon.RelayInfo) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.Int("relay.mode", info.RelayMode),
+		attribute.Bool("relay.stream", info.IsStream),
+	}
+	if info.ChannelMeta != nil {
+		attrs = append(attrs,
+			attribute.Int("channel.type", info.ChannelType),
+			attribute.String("model.upstream", info.UpstreamModelName),
+		)
+	}
+	return attrs
+}
+
+// traceConvertRequest 在 adaptor.convert_request span 中执行请求转换
+func traceConvertRequest[T any](c *gin.Context, info *relaycommon.RelayInfo, convert func() (T, error)) (T, error) {
+	span := tracing.StartGin(c, "adaptor.convert_request", adaptorSpanAttributes(info)...)
+	convertedRequest, err := convert()
+	tracing.End(span, err)
+	return convertedRequest, err
+}
+
+// doAdaptorRequest 在 adaptor.do_request span 中发起上游请求，出站 HTTP span 作为其子节点
+func doAdaptorRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	span, end := tracing.StartGinScope(c, "adaptor.do_request", adaptorSpanAttributes(info)...)
+	defer end()
+	resp, err := adaptor.DoRequest(c, info, requestBody)
+	resp, err = claimHedgeResponse(info, resp, err)
+	tracing.RecordError(span, err)
+	if httpResp, ok := resp.(*http.Response); ok && httpResp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", httpResp.StatusCode))
+	}
+	return resp, err
+}
+
+// doAdaptorResponse 在 adaptor.do_response span 中处理上游响应
+func doAdaptorResponse(c *gin.Context, adaptor channel.Adaptor, resp *http.Response, info *relaycommon.RelayInfo) (any, *types.NewAPIError) {
+	span := tracing.StartGin(c, "adaptor.do_response", adaptorSpanAttributes(info)...)
+	defer span.End()
+	usage, newAPIError := adaptor.DoResponse(c, resp, info)
+	if newAPIError != nil {
+		tracing.RecordError(span, newAPIError)
+		span.SetAttributes(attribute.String("error.code", string(newAPIError.GetErrorCode())))
+	}
+	return usage, newAPIError
+}
@@ -0,0 +1,152 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TryResponseCache 命中响应缓存时直接回放缓存的响应并按命中倍率计费，返回 true
+// 未命中但请求可缓存时开始记录响应，请求成功计费时写入缓存
+func TryResponseCache(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	key, ttl, ok := responseCacheKey(c, info)
+	if !ok {
+		return false
+	}
+	entry, hit := service.GetResponseCache(key)
+	if hit && entry.IsStream == info.IsStream {
+		replayCachedResponse(c, info, entry)
+		return true
+	}
+	scope, vector := semanticResponseCacheVector(c, info)
+	if vector != nil {
+		if similarKey, similarity, found := service.FindSemanticResponseCache(scope, vector); found {
+			if entry, hit := service.GetResponseCache(similarKey); hit && entry.IsStream == info.IsStream {
+				info.ResponseCacheSimilarity = similarity
+				replayCachedResponse(c, info, entry)
+				return true
+			}
+		}
+	}
+	capture := service.StartResponseCapture(c, key, ttl)
+	capture.SemanticScope = scope
+	capture.SemanticVector = vector
+	return false
+}
+
+// semanticResponseCacheVector 启用语义缓存时计算 chat 请求消息内容的向量，不适用或计算失败时返回 nil
+func semanticResponseCacheVector(c *gin.Context, info *relaycommon.RelayInfo) (string, []float64) {
+	if !operation_setting.IsSemanticResponseCacheEnabled() || info.RelayFormat != types.RelayFormatOpenAI {
+		return "", nil
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return "", nil
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return "", nil
+	}
+	scope, text, ok := service.SemanticCacheScope(info.UsingGroup, info.OriginModelName, c.Request.URL.Path, body)
+	if !ok {
+		return "", nil
+	}
+	vector, err := service.FetchSemanticEmbedding(c.Request.Context(), text)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("semantic response cache embedding failed: %s", err.Error()))
+		return "", nil
+	}
+	return scope, vector
+}
+
+func responseCacheKey(c *gin.Context, info *relaycommon.RelayInfo) (string, time.Duration, bool) {
+	switch info.RelayFormat {
+	case types.RelayFormatOpenAI:
+		if info.RelayMode != relayconstant.RelayModeChatCompletions {
+			return "", 0, false
+		}
+		if operation_setting.GetResponseCacheSetting().TemperatureZeroOnly {
+			request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+			if !ok || request.Temperature == nil || *request.Temperature != 0 {
+				return "", 0, false
+			}
+		}
+	case types.RelayFormatEmbedding, types.RelayFormatRerank:
+	default:
+		return "", 0, false
+	}
+	ttl, ok := operation_setting.GetResponseCacheTTL(info.OriginModelName, common.GetContextKeyInt(c, constant.ContextKeyTokenResponseCache))
+	if !ok {
+		return "", 0, false
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return "", 0, false
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return "", 0, false
+	}
+	key, err := service.ResponseCacheKey(info.UsingGroup, info.OriginModelName, c.Request.URL.Path, body)
+	if err != nil {
+		return "", 0, false
+	}
+	return key, ttl, true
+}
+
+func replayCachedResponse(c *gin.Context, info *relaycommon.RelayInfo, entry *service.ResponseCacheEntry) {
+	// 命中缓存时没有实际使用渠道
+	info.ChannelMeta = &relaycommon.ChannelMeta{UpstreamModelName: info.OriginModelName}
+	info.ResponseCacheHit = true
+	info.SetFirstResponseTime()
+	if entry.IsStream {
+		helper.SetEventStreamHeaders(c)
+		for _, event := range entry.Events {
+			if err := helper.StringData(c, event); err != nil {
+				logger.LogWarn(c, fmt.Sprintf("response cache replay interrupted: %s", err.Error()))
+				break
+			}
+		}
+	} else {
+		contentType := entry.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		c.Data(http.StatusOK, contentType, []byte(entry.Body))
+	}
+	info.PriceData.AddOtherRatio("response_cache_hit", operation_setting.GetResponseCacheHitRatio())
+	usage := entry.Usage
+	postConsumeQuota(c, info, &usage)
+}
+
+// storeResponseCache 请求成功计费时将记录的响应写入缓存
+func storeResponseCache(c *gin.Context, info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if info.ResponseCacheHit {
+		return
+	}
+	capture := service.GetResponseCapture(c)
+	if capture == nil {
+		return
+	}
+	entry, ok := capture.Entry(info.IsStream, usage)
+	if !ok {
+		return
+	}
+	service.SetResponseCache(capture.Key, entry, capture.TTL)
+	if capture.SemanticVector != nil {
+		service.AddSemanticResponseCache(capture.SemanticScope, capture.SemanticVector, capture.Key, capture.TTL)
+	}
+}
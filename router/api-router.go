@@ -74,6 +74,7 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
+				selfRoute.GET("/task_webhook_secret", controller.GetTaskWebhookSecret)
 				selfRoute.GET("/passkey", controller.PasskeyStatus)
 				selfRoute.POST("/passkey/register/begin", controller.PasskeyRegisterBegin)
 				selfRoute.POST("/passkey/register/finish", controller.PasskeyRegisterFinish)
@@ -91,6 +92,9 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.RequestCreemPay)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
+				selfRoute.GET("/statement", controller.GetSelfStatements)
+				selfRoute.GET("/statement/:period", controller.GetSelfStatement)
+				selfRoute.POST("/statement/:period/email", middleware.CriticalRateLimit(), controller.EmailSelfStatement)
 
 				// 2FA routes
 				selfRoute.GET("/2fa/status", controller.Get2FAStatus)
@@ -119,6 +123,7 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", controller.UnbindCustomOAuthByAdmin)
 				adminRoute.DELETE("/:id/bindings/:binding_type", controller.AdminClearUserBinding)
 				adminRoute.GET("/:id", controller.GetUser)
+				adminRoute.GET("/:id/statement/:period", controller.AdminGetUserStatement)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
 				adminRoute.PUT("/", controller.UpdateUser)
@@ -170,6 +175,7 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.GET("/channel_affinity_cache", controller.GetChannelAffinityCacheStats)
 			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
+			optionRoute.DELETE("/response_cache", controller.ClearResponseCache)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
@@ -206,6 +212,10 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/scores", controller.GetChannelScores)
+			channelRoute.DELETE("/scores", controller.ResetChannelScores)
+			channelRoute.GET("/breakers", controller.GetChannelCircuitBreakers)
+			channelRoute.DELETE("/breakers", controller.ResetChannelCircuitBreakers)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
@@ -260,6 +270,55 @@ func SetApiRouter(router *gin.Engine) {
 			}
 		}
 
+		orgRoute := apiRouter.Group("/org")
+		orgRoute.Use(middleware.UserAuth())
+		{
+			orgRoute.GET("/self", controller.GetSelfOrganizations)
+			orgRoute.POST("/", controller.CreateOrganization)
+			orgRoute.GET("/:id", controller.GetOrganization)
+			orgRoute.PUT("/:id", controller.UpdateOrganization)
+			orgRoute.DELETE("/:id", controller.DeleteOrganization)
+			orgRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			orgRoute.POST("/:id/members", controller.AddOrganizationMember)
+			orgRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+			orgRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			orgRoute.POST("/:id/transfer", middleware.CriticalRateLimit(), controller.TransferQuotaToOrganization)
+			orgRoute.GET("/:id/tokens", controller.GetOrganizationTokens)
+			orgRoute.DELETE("/:id/tokens/:token_id", controller.DeleteOrganizationToken)
+			orgRoute.GET("/:id/usage", controller.GetOrganizationUsage)
+		}
+		orgAdminRoute := apiRouter.Group("/org/admin")
+		orgAdminRoute.Use(middleware.AdminAuth())
+		{
+			orgAdminRoute.GET("/", controller.AdminGetAllOrganizations)
+			orgAdminRoute.PUT("/:id/status", controller.AdminUpdateOrganizationStatus)
+			orgAdminRoute.POST("/:id/quota", controller.AdminAdjustOrganizationQuota)
+		}
+
+		budgetSelfRoute := apiRouter.Group("/budget/self")
+		budgetSelfRoute.Use(middleware.UserAuth())
+		{
+			budgetSelfRoute.GET("/", controller.GetSelfBudgets)
+			budgetSelfRoute.POST("/", controller.AddSelfBudget)
+			budgetSelfRoute.PUT("/:id", controller.UpdateSelfBudget)
+			budgetSelfRoute.DELETE("/:id", controller.DeleteSelfBudget)
+		}
+		budgetRoute := apiRouter.Group("/budget")
+		budgetRoute.Use(middleware.AdminAuth())
+		{
+			budgetRoute.GET("/", controller.GetAllBudgets)
+			budgetRoute.POST("/", controller.AddBudget)
+			budgetRoute.PUT("/:id", controller.UpdateBudget)
+			budgetRoute.DELETE("/:id", controller.DeleteBudget)
+		}
+
+		ledgerRoute := apiRouter.Group("/ledger")
+		ledgerRoute.Use(middleware.AdminAuth())
+		{
+			ledgerRoute.GET("/reconcile", controller.ReconcileLedger)
+			ledgerRoute.GET("/:type/:id", controller.GetLedgerAccount)
+		}
+
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
@@ -312,6 +371,11 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/webhook", middleware.AdminAuth(), controller.GetTaskWebhooks)
+			taskRoute.POST("/webhook/:id/retry", middleware.AdminAuth(), controller.RetryTaskWebhook)
+			// 上游任务完成回调，通过签名校验而非登录鉴权
+			taskRoute.POST("/callback/task/:task_id", controller.TaskUpstreamCallback)
+			taskRoute.POST("/callback/channel/:channel_id", controller.TaskChannelCallback)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetMetricsRouter(router *gin.Engine) {
+	metrics.RegisterGaugeFunc("http_active_connections", "Number of in-flight relay HTTP requests.", func() float64 {
+		return float64(middleware.GetStats().ActiveConnections)
+	})
+	metricsRouter := router.Group("/metrics")
+	metricsRouter.Use(middleware.RouteTag("api"))
+	metricsRouter.Use(middleware.MetricsAuth())
+	metricsRouter.GET("", gin.WrapH(metrics.Handler()))
+}
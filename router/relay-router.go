@@ -59,6 +59,13 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 	}
 
+	// 网关临时托管的生成图片，链接本身即访问凭证，无需令牌鉴权
+	hostedImageRouter := router.Group("/v1/images/hosted")
+	hostedImageRouter.Use(middleware.RouteTag("relay"))
+	{
+		hostedImageRouter.GET("/:id", controller.GetHostedImage)
+	}
+
 	playgroundRouter := router.Group("/pg")
 	playgroundRouter.Use(middleware.RouteTag("relay"))
 	playgroundRouter.Use(middleware.SystemPerformanceCheck())
@@ -79,6 +86,32 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// files 路由不经过 Distribute，文件由网关存储，引用时再上传到具体渠道
+		filesRouter := relayV1Router.Group("/files")
+		filesRouter.POST("", controller.UploadFile)
+		filesRouter.GET("", controller.ListFiles)
+		filesRouter.GET("/:id", controller.RetrieveFile)
+		filesRouter.GET("/:id/content", controller.RetrieveFileContent)
+		filesRouter.DELETE("/:id", controller.DeleteFile)
+	}
+	{
+		// batches 路由同样不经过 Distribute，逐行请求由后台 worker 走完整中继流程
+		batchesRouter := relayV1Router.Group("/batches")
+		batchesRouter.POST("", controller.CreateBatch)
+		batchesRouter.GET("", controller.ListBatches)
+		batchesRouter.GET("/:id", controller.RetrieveBatch)
+		batchesRouter.POST("/:id/cancel", controller.CancelBatch)
+	}
+	{
+		// Anthropic Message Batches，复用 /v1/batches 的 worker 与计费
+		messageBatchesRouter := relayV1Router.Group("/messages/batches")
+		messageBatchesRouter.POST("", controller.CreateClaudeBatch)
+		messageBatchesRouter.GET("", controller.ListClaudeBatches)
+		messageBatchesRouter.GET("/:id", controller.RetrieveClaudeBatch)
+		messageBatchesRouter.POST("/:id/cancel", controller.CancelClaudeBatch)
+		messageBatchesRouter.GET("/:id/results", controller.RetrieveClaudeBatchResults)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -88,6 +121,7 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/messages", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatClaude)
 		})
+		httpRouter.POST("/messages/count_tokens", controller.ClaudeCountTokens)
 
 		// chat related routes
 		httpRouter.POST("/completions", func(c *gin.Context) {
@@ -152,11 +186,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
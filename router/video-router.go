@@ -31,6 +31,15 @@ func SetVideoRouter(router *gin.Engine) {
 		videoV1Router.GET("/videos/:task_id", controller.RelayTaskFetch)
 	}
 
+	// 列表与删除只操作本地任务记录，无需选择渠道
+	videoManageRouter := router.Group("/v1")
+	videoManageRouter.Use(middleware.RouteTag("relay"))
+	videoManageRouter.Use(middleware.TokenAuth())
+	{
+		videoManageRouter.GET("/videos", controller.ListVideos)
+		videoManageRouter.DELETE("/videos/:task_id", controller.DeleteVideo)
+	}
+
 	klingV1Router := router.Group("/kling/v1")
 	klingV1Router.Use(middleware.RouteTag("relay"))
 	klingV1Router.Use(middleware.KlingRequestConvert(), middleware.TokenAuth(), middleware.Distribute())
@@ -0,0 +1,525 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	batchWorkerTickInterval = 5 * time.Second
+	batchProgressInterval   = 3 * time.Second
+	batchMaxValidateErrors  = 100
+)
+
+// BatchRelayHandler 批处理请求的执行入口（即完整的 HTTP 路由），在 main 中注入
+// 每行请求都会经过 TokenAuth、Distribute、渠道选择与计费等完整中继流程
+var BatchRelayHandler http.Handler
+
+var (
+	batchWorkerOnce    sync.Once
+	batchRunningCount  atomic.Int32
+	batchDispatchGuard atomic.Bool
+)
+
+type batchLine struct {
+	req dto.OpenAIBatchRequestLine
+}
+
+// StartBatchWorker 启动批处理后台 worker，仅在主节点运行
+func StartBatchWorker() {
+	batchWorkerOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("batch worker started: tick=%s", batchWorkerTickInterval))
+			recoverInterruptedBatches()
+			ticker := time.NewTicker(batchWorkerTickInterval)
+			defer ticker.Stop()
+
+			dispatchBatches()
+			for range ticker.C {
+				dispatchBatches()
+			}
+		})
+	})
+}
+
+// recoverInterruptedBatches 处理上次进程退出时未执行完的任务
+// 已执行的请求已完成计费，无法安全地断点续跑，因此直接标记为失败
+func recoverInterruptedBatches() {
+	ctx := context.Background()
+	batches, err := model.GetBatchesByStatus([]string{
+		model.BatchStatusInProgress,
+		model.BatchStatusFinalizing,
+		model.BatchStatusCancelling,
+	}, 1000)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("batch recover failed: %v", err))
+		return
+	}
+	now := common.GetTimestamp()
+	for _, batch := range batches {
+		updates := map[string]interface{}{
+			"status":    model.BatchStatusFailed,
+			"failed_at": now,
+			"errors": model.BatchErrors{{
+				Code:    "batch_interrupted",
+				Message: "The batch was interrupted by a server restart.",
+			}},
+		}
+		if batch.Status == model.BatchStatusCancelling {
+			updates = map[string]interface{}{
+				"status":       model.BatchStatusCancelled,
+				"cancelled_at": now,
+			}
+		}
+		if _, err := model.UpdateBatchWithStatus(batch.Id, batch.Status, updates); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("batch %s recover failed: %v", batch.BatchId, err))
+		}
+	}
+}
+
+func dispatchBatches() {
+	if !batchDispatchGuard.CompareAndSwap(false, true) {
+		return
+	}
+	defer batchDispatchGuard.Store(false)
+
+	setting := operation_setting.GetBatchSetting()
+	if !setting.Enabled || BatchRelayHandler == nil {
+		return
+	}
+	slots := setting.MaxConcurrentBatches - int(batchRunningCount.Load())
+	if slots <= 0 {
+		return
+	}
+	ctx := context.Background()
+	batches, err := model.GetBatchesByStatus([]string{model.BatchStatusValidating}, slots)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("batch dispatch failed: %v", err))
+		return
+	}
+	now := common.GetTimestamp()
+	for _, batch := range batches {
+		if batch.ExpiresAt > 0 && now >= batch.ExpiresAt {
+			_, _ = model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+				"status":     model.BatchStatusExpired,
+				"expired_at": now,
+			})
+			continue
+		}
+		ok, err := model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+			"status":         model.BatchStatusInProgress,
+			"in_progress_at": now,
+		})
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("batch %s claim failed: %v", batch.BatchId, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		batch.Status = model.BatchStatusInProgress
+		batch.InProgressAt = now
+		batchRunningCount.Add(1)
+		b := batch
+		gopool.Go(func() {
+			defer batchRunningCount.Add(-1)
+			runBatch(b)
+		})
+	}
+}
+
+func failBatch(batch *model.Batch, errs model.BatchErrors) {
+	_, err := model.UpdateBatchWithStatus(batch.Id, model.BatchStatusInProgress, map[string]interface{}{
+		"status":    model.BatchStatusFailed,
+		"failed_at": common.GetTimestamp(),
+		"errors":    errs,
+	})
+	if err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("batch %s mark failed error: %v", batch.BatchId, err))
+	}
+}
+
+// loadBatchInput 读取并校验输入文件，校验失败时返回错误列表
+func loadBatchInput(batch *model.Batch) ([]batchLine, model.BatchErrors) {
+	file, err := model.GetUserFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		return nil, model.BatchErrors{{Code: "invalid_file", Message: fmt.Sprintf("Input file %s not found.", batch.InputFileId)}}
+	}
+	content, err := OpenFileContent(file)
+	if err != nil {
+		return nil, model.BatchErrors{{Code: "invalid_file", Message: "Failed to read the input file."}}
+	}
+	defer content.Close()
+
+	maxRequests := operation_setting.GetBatchSetting().MaxRequestsPerBatch
+	var errs model.BatchErrors
+	addErr := func(e model.BatchError) bool {
+		errs = append(errs, e)
+		return len(errs) >= batchMaxValidateErrors
+	}
+
+	lines := make([]batchLine, 0)
+	customIds := make(map[string]struct{})
+	reader := bufio.NewReader(content)
+	lineNo := 0
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		if len(raw) > 0 {
+			lineNo++
+			raw = bytes.TrimSpace(raw)
+		}
+		if len(raw) > 0 {
+			var req dto.OpenAIBatchRequestLine
+			var lineErr *model.BatchError
+			if err := common.Unmarshal(raw, &req); err != nil {
+				lineErr = &model.BatchError{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON."}
+			} else if req.CustomId == "" {
+				lineErr = &model.BatchError{Code: "missing_required_parameter", Message: "Missing required parameter: 'custom_id'.", Param: "custom_id"}
+			} else if req.Method != http.MethodPost {
+				lineErr = &model.BatchError{Code: "invalid_value", Message: "Invalid value for 'method': only POST is supported.", Param: "method"}
+			} else if req.Url != batch.Endpoint {
+				lineErr = &model.BatchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("The URL provided for this request does not match the batch endpoint %s.", batch.Endpoint), Param: "url"}
+			} else if !gjson.ValidBytes(req.Body) || !gjson.ParseBytes(req.Body).IsObject() {
+				lineErr = &model.BatchError{Code: "invalid_value", Message: "Invalid value for 'body': expected a JSON object.", Param: "body"}
+			} else if gjson.GetBytes(req.Body, "model").String() == "" {
+				lineErr = &model.BatchError{Code: "missing_required_parameter", Message: "Missing required parameter: 'body.model'.", Param: "body.model"}
+			} else if _, dup := customIds[req.CustomId]; dup {
+				lineErr = &model.BatchError{Code: "duplicate_custom_id", Message: fmt.Sprintf("The custom_id '%s' is duplicated.", req.CustomId), Param: "custom_id"}
+			}
+			if lineErr != nil {
+				lineErr.Line = lineNo
+				if addErr(*lineErr) {
+					break
+				}
+			} else {
+				customIds[req.CustomId] = struct{}{}
+				lines = append(lines, batchLine{req: req})
+				if maxRequests > 0 && len(lines) > maxRequests {
+					addErr(model.BatchError{Code: "too_many_requests", Message: fmt.Sprintf("The batch exceeds the maximum of %d requests.", maxRequests)})
+					break
+				}
+			}
+		}
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				addErr(model.BatchError{Code: "invalid_file", Message: "Failed to read the input file."})
+			}
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(lines) == 0 {
+		return nil, model.BatchErrors{{Code: "empty_file", Message: "The input file contains no requests."}}
+	}
+	return lines, nil
+}
+
+// batchResultWriter 将每行结果写入临时的输出 / 错误文件
+type batchResultWriter struct {
+	mu        sync.Mutex
+	output    *os.File
+	errorFile *os.File
+	completed int
+	failed    int
+}
+
+func newBatchResultWriter() (*batchResultWriter, error) {
+	output, err := os.CreateTemp("", "batch-output-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	errorFile, err := os.CreateTemp("", "batch-error-*.jsonl")
+	if err != nil {
+		output.Close()
+		_ = os.Remove(output.Name())
+		return nil, err
+	}
+	return &batchResultWriter{output: output, errorFile: errorFile}, nil
+}
+
+func (w *batchResultWriter) write(result dto.OpenAIBatchResponseLine, success bool) error {
+	data, err := common.Marshal(result)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if success {
+		w.completed++
+		_, err = w.output.Write(data)
+	} else {
+		w.failed++
+		_, err = w.errorFile.Write(data)
+	}
+	return err
+}
+
+func (w *batchResultWriter) counts() (int, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.completed, w.failed
+}
+
+func (w *batchResultWriter) close() {
+	for _, f := range []*os.File{w.output, w.errorFile} {
+		f.Close()
+		_ = os.Remove(f.Name())
+	}
+}
+
+// save 将非空的结果文件保存为用户文件，返回输出与错误文件 ID
+func (w *batchResultWriter) save(batch *model.Batch) (string, string, error) {
+	var ids [2]string
+	for i, f := range []*os.File{w.output, w.errorFile} {
+		info, err := f.Stat()
+		if err != nil {
+			return "", "", err
+		}
+		if info.Size() == 0 {
+			continue
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", "", err
+		}
+		name := fmt.Sprintf("%s_output.jsonl", batch.BatchId)
+		if i == 1 {
+			name = fmt.Sprintf("%s_error.jsonl", batch.BatchId)
+		}
+		saved, err := SaveGeneratedFile(batch.UserId, batch.TokenId, name, FilePurposeBatchOutput, "application/jsonl", f)
+		if err != nil {
+			return "", "", err
+		}
+		ids[i] = saved.FileId
+	}
+	return ids[0], ids[1], nil
+}
+
+func runBatch(batch *model.Batch) {
+	ctx := context.Background()
+	lines, errs := loadBatchInput(batch)
+	if len(errs) > 0 {
+		failBatch(batch, errs)
+		return
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		failBatch(batch, model.BatchErrors{{Code: "invalid_token", Message: "The token that created this batch is no longer available."}})
+		return
+	}
+	writer, err := newBatchResultWriter()
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s create result writer failed: %v", batch.BatchId, err))
+		failBatch(batch, model.BatchErrors{{Code: "server_error", Message: "Failed to prepare batch output."}})
+		return
+	}
+	defer writer.close()
+	if err := model.UpdateBatchCounts(batch.Id, len(lines), 0, 0); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("batch %s update counts failed: %v", batch.BatchId, err))
+	}
+
+	dispatchCtx, stopDispatch := context.WithDeadline(ctx, time.Unix(batch.ExpiresAt, 0))
+	defer stopDispatch()
+
+	// 定期同步进度，并检查是否被取消
+	watchDone := make(chan struct{})
+	watchStopped := make(chan struct{})
+	gopool.Go(func() {
+		defer close(watchStopped)
+		ticker := time.NewTicker(batchProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watchDone:
+				return
+			case <-ticker.C:
+				completed, failed := writer.counts()
+				_ = model.UpdateBatchCounts(batch.Id, len(lines), completed, failed)
+				if status, err := model.GetBatchStatus(batch.Id); err == nil && status == model.BatchStatusCancelling {
+					stopDispatch()
+				}
+			}
+		}
+	})
+
+	concurrency := operation_setting.GetBatchSetting().RequestConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	jobs := make(chan batchLine)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		gopool.Go(func() {
+			defer wg.Done()
+			for line := range jobs {
+				result, success := executeBatchLine(batch, token, line)
+				if err := writer.write(result, success); err != nil {
+					logger.LogError(ctx, fmt.Sprintf("batch %s write result failed: %v", batch.BatchId, err))
+				}
+			}
+		})
+	}
+	dispatched := 0
+dispatch:
+	for _, line := range lines {
+		select {
+		case <-dispatchCtx.Done():
+			break dispatch
+		case jobs <- line:
+			dispatched++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(watchDone)
+	<-watchStopped
+
+	// 未派发的请求写入错误文件
+	finalStatus := model.BatchStatusCompleted
+	if dispatched < len(lines) {
+		status, _ := model.GetBatchStatus(batch.Id)
+		code, message := "batch_expired", "This request could not be executed before the completion window expired."
+		finalStatus = model.BatchStatusExpired
+		if status == model.BatchStatusCancelling {
+			code, message = "batch_cancelled", "This request was not executed because the batch was cancelled."
+			finalStatus = model.BatchStatusCancelled
+		}
+		for _, line := range lines[dispatched:] {
+			_ = writer.write(dto.OpenAIBatchResponseLine{
+				ID:       "batch_req_" + common.GetRandomString(24),
+				CustomId: line.req.CustomId,
+				Error:    &dto.OpenAIBatchError{Code: code, Message: message},
+			}, false)
+		}
+	}
+	finalizeBatch(batch, writer, len(lines), len(lines)-dispatched, finalStatus)
+}
+
+func finalizeBatch(batch *model.Batch, writer *batchResultWriter, total int, unprocessed int, finalStatus string) {
+	ctx := context.Background()
+	now := common.GetTimestamp()
+	// 取消请求可能与执行结束并发，因此依次尝试从 in_progress / cancelling 进入 finalizing
+	fromStatus := model.BatchStatusInProgress
+	ok, err := model.UpdateBatchWithStatus(batch.Id, fromStatus, map[string]interface{}{
+		"status":        model.BatchStatusFinalizing,
+		"finalizing_at": now,
+	})
+	if err == nil && !ok {
+		fromStatus = model.BatchStatusCancelling
+		ok, err = model.UpdateBatchWithStatus(batch.Id, fromStatus, map[string]interface{}{
+			"status":        model.BatchStatusFinalizing,
+			"finalizing_at": now,
+		})
+		if ok && finalStatus == model.BatchStatusCompleted {
+			// 所有请求均已执行完毕，仍按取消处理以符合用户预期
+			finalStatus = model.BatchStatusCancelled
+		}
+	}
+	if err != nil || !ok {
+		logger.LogWarn(ctx, fmt.Sprintf("batch %s finalize skipped: ok=%t, err=%v", batch.BatchId, ok, err))
+		return
+	}
+
+	completed, failed := writer.counts()
+	updates := map[string]interface{}{
+		"status":            finalStatus,
+		"total_count":       total,
+		"completed_count":   completed,
+		"failed_count":      failed,
+		"unprocessed_count": unprocessed,
+	}
+	outputFileId, errorFileId, err := writer.save(batch)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s save result files failed: %v", batch.BatchId, err))
+		finalStatus = model.BatchStatusFailed
+		updates["status"] = finalStatus
+		updates["errors"] = model.BatchErrors{{Code: "server_error", Message: "Failed to save batch output."}}
+	}
+	updates["output_file_id"] = outputFileId
+	updates["error_file_id"] = errorFileId
+	now = common.GetTimestamp()
+	switch finalStatus {
+	case model.BatchStatusCompleted:
+		updates["completed_at"] = now
+	case model.BatchStatusExpired:
+		updates["expired_at"] = now
+	case model.BatchStatusCancelled:
+		updates["cancelled_at"] = now
+	case model.BatchStatusFailed:
+		updates["failed_at"] = now
+	}
+	if _, err := model.UpdateBatchWithStatus(batch.Id, model.BatchStatusFinalizing, updates); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s finalize failed: %v", batch.BatchId, err))
+	}
+}
+
+// executeBatchLine 通过完整中继流程执行单行请求
+func executeBatchLine(batch *model.Batch, token *model.Token, line batchLine) (dto.OpenAIBatchResponseLine, bool) {
+	result := dto.OpenAIBatchResponseLine{
+		ID:       "batch_req_" + common.GetRandomString(24),
+		CustomId: line.req.CustomId,
+	}
+	body := []byte(line.req.Body)
+	// 批处理不支持流式输出
+	if gjson.GetBytes(body, "stream").Exists() {
+		if newBody, err := sjson.SetBytes(body, "stream", false); err == nil {
+			body = newBody
+		}
+	}
+
+	reqCtx := relaycommon.WithBatchRequest(context.Background(), batch.BatchId)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, line.req.Url, bytes.NewReader(body))
+	if err != nil {
+		result.Error = &dto.OpenAIBatchError{Code: "server_error", Message: err.Error()}
+		return result, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+	if batch.ClientIp != "" {
+		// 沿用创建批处理时的客户端 IP，使令牌的 IP 限制保持生效
+		req.RemoteAddr = net.JoinHostPort(batch.ClientIp, "0")
+		req.Header.Set("X-Forwarded-For", batch.ClientIp)
+	}
+
+	recorder := httptest.NewRecorder()
+	BatchRelayHandler.ServeHTTP(recorder, req)
+
+	respBody := recorder.Body.Bytes()
+	if !gjson.ValidBytes(respBody) {
+		respBody, _ = common.Marshal(string(respBody))
+	}
+	result.Response = &dto.OpenAIBatchResponseBody{
+		StatusCode: recorder.Code,
+		RequestId:  recorder.Header().Get(common.RequestIdKey),
+		Body:       respBody,
+	}
+	return result, recorder.Code >= 200 && recorder.Code < 300
+}
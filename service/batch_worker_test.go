@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// memoryFileStorage 测试用的内存存储后端
+type memoryFileStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memoryFileStorage) Name() string { return "memory" }
+
+func (s *memoryFileStorage) Save(key string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = data
+	return int64(len(data)), nil
+}
+
+func (s *memoryFileStorage) Open(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryFileStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+func useMemoryFileStorage(t *testing.T) {
+	t.Helper()
+	RegisterFileStorage(&memoryFileStorage{files: map[string][]byte{}})
+	setting := operation_setting.GetFileSetting()
+	original := setting.StorageBackend
+	setting.StorageBackend = "memory"
+	t.Cleanup(func() {
+		setting.StorageBackend = original
+		model.DB.Exec("DELETE FROM files")
+		model.DB.Exec("DELETE FROM batches")
+	})
+}
+
+func readFileContent(t *testing.T, userId int, fileId string) string {
+	t.Helper()
+	file, err := model.GetUserFileByFileId(userId, fileId)
+	require.NoError(t, err)
+	content, err := OpenFileContent(file)
+	require.NoError(t, err)
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRunBatch_ExecutesLinesAndWritesResults(t *testing.T) {
+	truncate(t)
+	useMemoryFileStorage(t)
+	seedUser(t, 1, 100000)
+	seedToken(t, 1, 1, "batchkey", 100000)
+
+	input := strings.Join([]string{
+		`{"custom_id":"ok-1","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","stream":true}}`,
+		`{"custom_id":"bad-1","method":"POST","url":"/v1/chat/completions","body":{"model":"bad-model"}}`,
+		`{"custom_id":"ok-2","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+	}, "\n")
+	inputFile, err := SaveGeneratedFile(1, 1, "input.jsonl", FilePurposeBatch, "application/jsonl", strings.NewReader(input))
+	require.NoError(t, err)
+
+	original := BatchRelayHandler
+	t.Cleanup(func() { BatchRelayHandler = original })
+	BatchRelayHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, relaycommon.GetBatchRequestId(r.Context()))
+		assert.Equal(t, "Bearer sk-batchkey", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		assert.False(t, gjson.GetBytes(body, "stream").Bool(), "stream must be disabled for batch lines")
+		if gjson.GetBytes(body, "model").String() == "bad-model" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1"}`))
+	})
+
+	batch, err := CreateBatch(1, 1, "", &dto.OpenAIBatchCreateRequest{
+		InputFileId:      inputFile.FileId,
+		Endpoint:         "/v1/chat/completions",
+		CompletionWindow: BatchCompletionWindow,
+	})
+	require.NoError(t, err)
+	ok, err := model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+		"status": model.BatchStatusInProgress,
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	runBatch(batch)
+
+	got, err := model.GetBatchById(batch.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BatchStatusCompleted, got.Status)
+	assert.Equal(t, 3, got.TotalCount)
+	assert.Equal(t, 2, got.CompletedCount)
+	assert.Equal(t, 1, got.FailedCount)
+	assert.NotZero(t, got.CompletedAt)
+
+	output := readFileContent(t, 1, got.OutputFileId)
+	assert.Contains(t, output, `"custom_id":"ok-1"`)
+	assert.Contains(t, output, `"custom_id":"ok-2"`)
+	assert.NotContains(t, output, `"custom_id":"bad-1"`)
+	errorOutput := readFileContent(t, 1, got.ErrorFileId)
+	assert.Contains(t, errorOutput, `"custom_id":"bad-1"`)
+	assert.Contains(t, errorOutput, `"status_code":400`)
+}
+
+func TestRunBatch_PreservesIPv6ClientIp(t *testing.T) {
+	truncate(t)
+	useMemoryFileStorage(t)
+	seedUser(t, 1, 100000)
+	seedToken(t, 1, 1, "batchkey", 100000)
+
+	input := `{"custom_id":"ok-1","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`
+	inputFile, err := SaveGeneratedFile(1, 1, "input.jsonl", FilePurposeBatch, "application/jsonl", strings.NewReader(input))
+	require.NoError(t, err)
+
+	original := BatchRelayHandler
+	t.Cleanup(func() { BatchRelayHandler = original })
+	var remoteHost string
+	BatchRelayHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteHost, _, _ = net.SplitHostPort(r.RemoteAddr)
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1"}`))
+	})
+
+	batch, err := CreateBatch(1, 1, "2001:db8::1", &dto.OpenAIBatchCreateRequest{
+		InputFileId:      inputFile.FileId,
+		Endpoint:         "/v1/chat/completions",
+		CompletionWindow: BatchCompletionWindow,
+	})
+	require.NoError(t, err)
+	ok, err := model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+		"status": model.BatchStatusInProgress,
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	runBatch(batch)
+
+	// IPv6 地址需加方括号拼接端口，否则 RemoteAddr 无法解析，令牌 IP 限制会失效
+	assert.Equal(t, "2001:db8::1", remoteHost)
+}
+
+func TestRunBatch_ValidationFailure(t *testing.T) {
+	truncate(t)
+	useMemoryFileStorage(t)
+
+	input := strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/embeddings","body":{"model":"gpt-4o"}}`,
+		`not json`,
+	}, "\n")
+	inputFile, err := SaveGeneratedFile(1, 1, "input.jsonl", FilePurposeBatch, "application/jsonl", strings.NewReader(input))
+	require.NoError(t, err)
+	batch, err := CreateBatch(1, 1, "", &dto.OpenAIBatchCreateRequest{
+		InputFileId:      inputFile.FileId,
+		Endpoint:         "/v1/chat/completions",
+		CompletionWindow: BatchCompletionWindow,
+	})
+	require.NoError(t, err)
+	_, err = model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+		"status": model.BatchStatusInProgress,
+	})
+	require.NoError(t, err)
+
+	runBatch(batch)
+
+	got, err := model.GetBatchById(batch.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BatchStatusFailed, got.Status)
+	require.Len(t, got.Errors, 3)
+	assert.Equal(t, "duplicate_custom_id", got.Errors[0].Code)
+	assert.Equal(t, 2, got.Errors[0].Line)
+	assert.Equal(t, "mismatched_endpoint", got.Errors[1].Code)
+	assert.Equal(t, "invalid_json_line", got.Errors[2].Code)
+}
+
+func TestCancelBatch_BeforeExecution(t *testing.T) {
+	truncate(t)
+	useMemoryFileStorage(t)
+
+	batch, err := CreateBatch(1, 1, "", &dto.OpenAIBatchCreateRequest{
+		InputFileId:      "file-x",
+		Endpoint:         "/v1/chat/completions",
+		CompletionWindow: BatchCompletionWindow,
+	})
+	require.NoError(t, err)
+
+	got, err := CancelBatch(batch)
+	require.NoError(t, err)
+	assert.Equal(t, model.BatchStatusCancelled, got.Status)
+	assert.NotZero(t, got.CancelledAt)
+	assert.True(t, got.IsFinished())
+}
+
+func TestRunClaudeBatch_ResultsAndCounts(t *testing.T) {
+	truncate(t)
+	useMemoryFileStorage(t)
+	seedUser(t, 1, 100000)
+	seedToken(t, 1, 1, "batchkey", 100000)
+
+	original := BatchRelayHandler
+	t.Cleanup(func() { BatchRelayHandler = original })
+	BatchRelayHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ClaudeBatchEndpoint, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		if gjson.GetBytes(body, "model").String() == "bad-model" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message"}`))
+	})
+
+	req := &dto.ClaudeMessageBatchCreateRequest{Requests: []dto.ClaudeMessageBatchRequest{
+		{CustomId: "ok-1", Params: []byte(`{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[]}`)},
+		{CustomId: "bad-1", Params: []byte(`{"model":"bad-model","max_tokens":16,"messages":[]}`)},
+	}}
+	require.NoError(t, ValidateClaudeBatchRequest(req))
+	batch, err := CreateClaudeBatch(1, 1, "", req)
+	require.NoError(t, err)
+	assert.True(t, batch.IsClaudeBatch())
+	assert.Equal(t, 2, ClaudeBatchToAnthropic(batch).RequestCounts.Processing)
+
+	_, err = model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+		"status": model.BatchStatusInProgress,
+	})
+	require.NoError(t, err)
+	runBatch(batch)
+
+	got, err := model.GetBatchById(batch.Id)
+	require.NoError(t, err)
+	resp := ClaudeBatchToAnthropic(got)
+	assert.Equal(t, ClaudeBatchStatusEnded, resp.ProcessingStatus)
+	assert.Equal(t, dto.ClaudeMessageBatchRequestCounts{Succeeded: 1, Errored: 1}, resp.RequestCounts)
+	require.NotNil(t, resp.ResultsUrl)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteClaudeBatchResults(&buf, got))
+	results := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, results, 2)
+	assert.Equal(t, "ok-1", gjson.Get(results[0], "custom_id").String())
+	assert.Equal(t, "succeeded", gjson.Get(results[0], "result.type").String())
+	assert.Equal(t, "msg_1", gjson.Get(results[0], "result.message.id").String())
+	assert.Equal(t, "errored", gjson.Get(results[1], "result.type").String())
+	assert.Equal(t, "invalid_request_error", gjson.Get(results[1], "result.error.error.type").String())
+}
+
+func TestCancelClaudeBatch_BeforeExecutionResults(t *testing.T) {
+	truncate(t)
+	useMemoryFileStorage(t)
+
+	batch, err := CreateClaudeBatch(1, 1, "", &dto.ClaudeMessageBatchCreateRequest{Requests: []dto.ClaudeMessageBatchRequest{
+		{CustomId: "a", Params: []byte(`{"model":"claude-sonnet-4-5"}`)},
+	}})
+	require.NoError(t, err)
+	got, err := CancelBatch(batch)
+	require.NoError(t, err)
+
+	resp := ClaudeBatchToAnthropic(got)
+	assert.Equal(t, ClaudeBatchStatusEnded, resp.ProcessingStatus)
+	assert.Equal(t, 1, resp.RequestCounts.Canceled)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteClaudeBatchResults(&buf, got))
+	assert.JSONEq(t, `{"custom_id":"a","result":{"type":"canceled"}}`, strings.TrimSpace(buf.String()))
+}
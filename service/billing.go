@@ -3,24 +3,35 @@ package service
 import (
 	"fmt"
 
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/pkg/multikey"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	BillingSourceWallet       = "wallet"
 	BillingSourceSubscription = "subscription"
+	BillingSourceOrg          = "org"
 )
 
 // PreConsumeBilling 根据用户计费偏好创建 BillingSession 并执行预扣费。
 // 会话存储在 relayInfo.Billing 上，供后续 Settle / Refund 使用。
 func PreConsumeBilling(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	span := tracing.StartGin(c, "billing.pre_consume", attribute.Int("billing.pre_consumed_quota", preConsumedQuota))
+	defer span.End()
 	session, apiErr := NewBillingSession(c, relayInfo, preConsumedQuota)
 	if apiErr != nil {
+		tracing.RecordError(span, apiErr)
 		return apiErr
 	}
+	span.SetAttributes(attribute.String("billing.source", relayInfo.BillingSource))
 	relayInfo.Billing = session
 	return nil
 }
@@ -32,6 +43,13 @@ func PreConsumeBilling(c *gin.Context, preConsumedQuota int, relayInfo *relaycom
 // SettleBilling 执行计费结算。如果 RelayInfo 上有 BillingSession 则通过 session 结算，
 // 否则回退到旧的 PostConsumeQuota 路径（兼容按次计费等场景）。
 func SettleBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuota int) error {
+	span := tracing.StartGin(ctx, "billing.settle", attribute.Int("billing.actual_quota", actualQuota))
+	err := settleBilling(ctx, relayInfo, actualQuota)
+	tracing.End(span, err)
+	return err
+}
+
+func settleBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuota int) error {
 	if relayInfo.Billing != nil {
 		preConsumed := relayInfo.Billing.GetPreConsumedQuota()
 		delta := actualQuota - preConsumed
@@ -60,9 +78,12 @@ func SettleBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuo
 
 		// 发送额度通知（订阅计费使用订阅剩余额度）
 		if actualQuota != 0 {
-			if relayInfo.BillingSource == BillingSourceSubscription {
+			switch relayInfo.BillingSource {
+			case BillingSourceSubscription:
 				checkAndSendSubscriptionQuotaNotify(relayInfo)
-			} else {
+			case BillingSourceOrg:
+				// 组织钱包不按个人额度发送提醒
+			default:
 				checkAndSendQuotaNotify(relayInfo, actualQuota-preConsumed, preConsumed)
 			}
 		}
@@ -76,3 +97,14 @@ func SettleBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuo
 	}
 	return nil
 }
+
+// ReportRelayUsage 结算后上报本次请求的实际用量，不依赖消费日志是否记录
+func ReportRelayUsage(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string, promptTokens int, completionTokens int, quota int) {
+	metrics.AddUsage(modelName, relayInfo.UsingGroup, relayInfo.ChannelId, ctx.GetInt("channel_type"),
+		promptTokens, completionTokens, quota)
+	ReconcileRelayRateLimit(ctx, promptTokens+completionTokens)
+	// quota_aware 模式按实际用量累计 Key 的每分钟 Token 数，命中响应缓存时未使用 Key
+	if !relayInfo.ResponseCacheHit && common.GetContextKeyString(ctx, constant.ContextKeyChannelMultiKeyMode) == string(constant.MultiKeyModeQuotaAware) {
+		multikey.AddTokens(relayInfo.ChannelId, common.GetContextKeyInt(ctx, constant.ContextKeyChannelMultiKeyIndex), promptTokens+completionTokens)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -31,6 +32,9 @@ type BillingSession struct {
 	settled          bool // Settle 全部完成（资金 + 令牌）
 	refunded         bool // Refund 已调用
 	mu               sync.Mutex
+
+	// 周期预算预留，无适用预算时为 nil
+	budgets *budgetReservation
 }
 
 // Settle 根据实际消耗额度进行结算。
@@ -42,6 +46,11 @@ func (s *BillingSession) Settle(actualQuota int) error {
 	if s.settled {
 		return nil
 	}
+	// 周期预算按实际消耗结算（预算预留的是未经信任旁路的完整额度，需单独计算差额）
+	if s.budgets != nil {
+		s.budgets.settle(actualQuota)
+		s.budgets.notify(s.relayInfo)
+	}
 	delta := actualQuota - s.preConsumedQuota
 	if delta == 0 {
 		s.settled = true
@@ -57,10 +66,11 @@ func (s *BillingSession) Settle(actualQuota int) error {
 	// 2) 调整令牌额度
 	var tokenErr error
 	if !s.relayInfo.IsPlayground {
+		ref := model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventSettle, s.relayInfo.RequestId).WithModel(s.relayInfo.OriginModelName)
 		if delta > 0 {
-			tokenErr = model.DecreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, delta)
+			tokenErr = model.DecreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, delta, ref)
 		} else {
-			tokenErr = model.IncreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, -delta)
+			tokenErr = model.IncreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, -delta, ref)
 		}
 		if tokenErr != nil {
 			// 资金来源已提交，令牌调整失败只能记录日志；标记 settled 防止 Refund 误退资金
@@ -79,6 +89,12 @@ func (s *BillingSession) Settle(actualQuota int) error {
 // Refund 退还所有预扣费，幂等安全，异步执行。
 func (s *BillingSession) Refund(c *gin.Context) {
 	s.mu.Lock()
+	if !s.settled && s.budgets != nil {
+		// 预算预留与资金来源独立，即使无需退还预扣费也要释放
+		budgets := s.budgets
+		s.budgets = nil
+		gopool.Go(budgets.release)
+	}
 	if s.settled || s.refunded || !s.needsRefundLocked() {
 		s.mu.Unlock()
 		return
@@ -98,6 +114,7 @@ func (s *BillingSession) Refund(c *gin.Context) {
 	isPlayground := s.relayInfo.IsPlayground
 	tokenConsumed := s.tokenConsumed
 	funding := s.funding
+	ref := model.NewEventLedgerRef(model.LedgerReasonRefund, model.LedgerEventRefund, s.relayInfo.RequestId).WithModel(s.relayInfo.OriginModelName)
 
 	gopool.Go(func() {
 		// 1) 退还资金来源
@@ -106,7 +123,7 @@ func (s *BillingSession) Refund(c *gin.Context) {
 		}
 		// 2) 退还令牌额度
 		if tokenConsumed > 0 && !isPlayground {
-			if err := model.IncreaseTokenQuota(tokenId, tokenKey, tokenConsumed); err != nil {
+			if err := model.IncreaseTokenQuota(tokenId, tokenKey, tokenConsumed, ref); err != nil {
 				common.SysLog("error refunding token quota: " + err.Error())
 			}
 		}
@@ -144,11 +161,18 @@ func (s *BillingSession) GetPreConsumedQuota() int {
 // PreConsume — 统一预扣费入口（含信任额度旁路）
 // ---------------------------------------------------------------------------
 
-// preConsume 执行预扣费：信任检查 -> 令牌预扣 -> 资金来源预扣。
+// preConsume 执行预扣费：周期预算预留 -> 信任检查 -> 令牌预扣 -> 资金来源预扣。
 // 任一步骤失败时原子回滚已完成的步骤。
 func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIError {
 	effectiveQuota := quota
 
+	// ---- 0) 周期预算：按完整预估额度预留，不受信任旁路影响 ----
+	budgets, apiErr := reserveBudgets(s.relayInfo, quota)
+	if apiErr != nil {
+		return apiErr
+	}
+	s.budgets = budgets
+
 	// ---- 信任额度旁路 ----
 	if s.shouldTrust(c) {
 		effectiveQuota = 0
@@ -160,6 +184,7 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	// ---- 1) 预扣令牌额度 ----
 	if effectiveQuota > 0 {
 		if err := PreConsumeTokenQuota(s.relayInfo, effectiveQuota); err != nil {
+			s.releaseBudgets()
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 		s.tokenConsumed = effectiveQuota
@@ -167,15 +192,20 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 
 	// ---- 2) 预扣资金来源 ----
 	if err := s.funding.PreConsume(effectiveQuota); err != nil {
+		s.releaseBudgets()
 		// 预扣费失败，回滚令牌额度
 		if s.tokenConsumed > 0 && !s.relayInfo.IsPlayground {
-			if rollbackErr := model.IncreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, s.tokenConsumed); rollbackErr != nil {
+			ref := model.NewEventLedgerRef(model.LedgerReasonRefund, model.LedgerEventRollback, s.relayInfo.RequestId).WithModel(s.relayInfo.OriginModelName)
+			if rollbackErr := model.IncreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, s.tokenConsumed, ref); rollbackErr != nil {
 				common.SysLog(fmt.Sprintf("error rolling back token quota (userId=%d, tokenId=%d, amount=%d, fundingErr=%s): %s",
 					s.relayInfo.UserId, s.relayInfo.TokenId, s.tokenConsumed, err.Error(), rollbackErr.Error()))
 			}
 			s.tokenConsumed = 0
 		}
 		// TODO: model 层应定义哨兵错误（如 ErrNoActiveSubscription），用 errors.Is 替代字符串匹配
+		if errors.Is(err, model.ErrOrgQuotaInsufficient) || errors.Is(err, model.ErrOrgMemberQuotaExceeded) {
+			return types.NewErrorWithStatusCode(fmt.Errorf("组织额度不足或超出成员消费上限: %s", err.Error()), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "no active subscription") || strings.Contains(errMsg, "subscription quota insufficient") {
 			return types.NewErrorWithStatusCode(fmt.Errorf("订阅额度不足或未配置订阅: %s", errMsg), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
@@ -191,6 +221,14 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	return nil
 }
 
+// releaseBudgets 预扣费失败时同步释放周期预算预留
+func (s *BillingSession) releaseBudgets() {
+	if s.budgets != nil {
+		s.budgets.release()
+		s.budgets = nil
+	}
+}
+
 // shouldTrust 统一信任额度检查，适用于钱包和订阅。
 func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 	// 异步任务（ForcePreConsume=true）必须预扣全额，不允许信任旁路
@@ -222,6 +260,9 @@ func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 		// 2. SubscriptionFunding.PreConsume 忽略参数，始终用 s.amount 预扣
 		// 3. 若信任旁路将 effectiveQuota 设为 0，会导致 preConsumedQuota 与实际订阅预扣不一致
 		return false
+	case BillingSourceOrg:
+		// 组织钱包需要预扣以执行成员消费上限，不启用信任旁路
+		return false
 	default:
 		return false
 	}
@@ -245,6 +286,9 @@ func (s *BillingSession) syncRelayInfo() {
 		info.SubscriptionId = 0
 		info.SubscriptionPreConsumed = 0
 	}
+	if org, ok := s.funding.(*OrgFunding); ok {
+		info.OrgId = org.orgId
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -257,6 +301,11 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		return nil, types.NewError(fmt.Errorf("relayInfo is nil"), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 	}
 
+	// 组织令牌只从组织钱包扣费，不回退到个人钱包或订阅
+	if relayInfo.OrgId > 0 {
+		return newOrgBillingSession(c, relayInfo, preConsumedQuota)
+	}
+
 	pref := common.NormalizeBillingPreference(relayInfo.UserSetting.BillingPreference)
 
 	// 钱包路径需要先检查用户额度
@@ -281,7 +330,7 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 
 		session := &BillingSession{
 			relayInfo: relayInfo,
-			funding:   &WalletFunding{userId: relayInfo.UserId},
+			funding:   &WalletFunding{userId: relayInfo.UserId, requestId: relayInfo.RequestId, modelName: relayInfo.OriginModelName},
 		}
 		if apiErr := session.preConsume(c, preConsumedQuota); apiErr != nil {
 			return nil, apiErr
@@ -345,3 +394,35 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		return session, nil
 	}
 }
+
+// newOrgBillingSession 为组织令牌创建从组织钱包扣费的会话，检查组织状态、成员角色、余额与成员消费上限。
+func newOrgBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preConsumedQuota int) (*BillingSession, *types.NewAPIError) {
+	org, _, err := model.CheckOrganizationFunding(relayInfo.OrgId, relayInfo.UserId, preConsumedQuota)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrOrgQuotaInsufficient):
+			return nil, types.NewErrorWithStatusCode(
+				fmt.Errorf("组织额度不足, 剩余额度: %s, 需要预扣费额度: %s", logger.FormatQuota(org.Quota), logger.FormatQuota(preConsumedQuota)),
+				types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
+				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		case errors.Is(err, model.ErrOrgMemberQuotaExceeded):
+			return nil, types.NewErrorWithStatusCode(
+				fmt.Errorf("已超出组织成员消费上限"),
+				types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
+				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		case errors.Is(err, model.ErrOrgNotFound), errors.Is(err, model.ErrOrgDisabled),
+			errors.Is(err, model.ErrOrgNotMember), errors.Is(err, model.ErrOrgPermissionDenied):
+			return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeAccessDenied, http.StatusForbidden,
+				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	session := &BillingSession{
+		relayInfo: relayInfo,
+		funding:   &OrgFunding{orgId: relayInfo.OrgId, userId: relayInfo.UserId, requestId: relayInfo.RequestId, modelName: relayInfo.OriginModelName},
+	}
+	if apiErr := session.preConsume(c, preConsumedQuota); apiErr != nil {
+		return nil, apiErr
+	}
+	return session, nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// budgetHold 单个预算在本次请求中的预留记录
+type budgetHold struct {
+	budget      *model.Budget
+	periodStart int64
+}
+
+// budgetReservation 一次请求在所有适用预算上的预留，随 BillingSession 结算或退还
+type budgetReservation struct {
+	holds    []budgetHold
+	reserved int
+	done     bool
+}
+
+// reserveBudgets 在令牌、用户和分组预算中预留 quota，任一硬上限预算不足时回滚已预留部分并拒绝请求
+func reserveBudgets(relayInfo *relaycommon.RelayInfo, quota int) (*budgetReservation, *types.NewAPIError) {
+	if relayInfo.IsPlayground {
+		return nil, nil
+	}
+	budgets, err := model.GetActiveBudgetsCached(relayInfo.TokenId, relayInfo.UserId, relayInfo.UserGroup)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	reservation := &budgetReservation{reserved: quota}
+	for _, budget := range budgets {
+		ok, err := model.ReserveBudget(budget, now, quota)
+		if err != nil {
+			reservation.release()
+			return nil, types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+		}
+		if !ok {
+			// 缓存中的预算可能已被删除或停用，以数据库为准
+			fresh, err := model.GetBudgetById(budget.Id)
+			if err != nil || !fresh.Enabled || !fresh.HardLimit {
+				continue
+			}
+			model.RefreshBudgetUsage(fresh)
+			reservation.release()
+			return nil, budgetExceededError(fresh, quota, now)
+		}
+		reservation.holds = append(reservation.holds, budgetHold{budget: budget, periodStart: model.BudgetPeriodStart(budget.Period, now).Unix()})
+	}
+	return reservation, nil
+}
+
+func budgetExceededError(budget *model.Budget, quota int, now time.Time) *types.NewAPIError {
+	scope := map[string]string{
+		model.BudgetScopeToken: "令牌",
+		model.BudgetScopeUser:  "用户",
+		model.BudgetScopeGroup: "分组",
+	}[budget.Scope]
+	resetAt := model.BudgetPeriodEnd(budget.Period, now).Format("2006-01-02 15:04:05")
+	return types.NewErrorWithStatusCode(
+		fmt.Errorf("%s预算已达上限（%s）：本周期预算 %s，已用 %s，本次需要 %s，将于 %s 重置",
+			scope, budget.Period,
+			logger.FormatQuota(budget.QuotaLimit), logger.FormatQuota(budget.UsedQuota), logger.FormatQuota(quota),
+			resetAt),
+		types.ErrorCodeBudgetExceeded, http.StatusForbidden,
+		types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+}
+
+// settle 按实际消耗调整预算用量
+func (r *budgetReservation) settle(actualQuota int) {
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+	delta := actualQuota - r.reserved
+	for i := range r.holds {
+		hold := &r.holds[i]
+		if err := model.AdjustBudgetUsage(hold.budget.Id, hold.periodStart, delta); err != nil {
+			common.SysLog(fmt.Sprintf("error settling budget %d: %s", hold.budget.Id, err.Error()))
+		}
+	}
+}
+
+// release 退还全部预留额度
+func (r *budgetReservation) release() {
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+	for _, hold := range r.holds {
+		if err := model.AdjustBudgetUsage(hold.budget.Id, hold.periodStart, -r.reserved); err != nil {
+			common.SysLog(fmt.Sprintf("error releasing budget %d: %s", hold.budget.Id, err.Error()))
+		}
+	}
+}
+
+// notify 检查各预算是否跨过提醒阈值，每个阈值每周期只提醒一次
+func (r *budgetReservation) notify(relayInfo *relaycommon.RelayInfo) {
+	if r == nil || len(r.holds) == 0 {
+		return
+	}
+	holds := r.holds
+	userId := relayInfo.UserId
+	userEmail := relayInfo.UserEmail
+	userSetting := relayInfo.UserSetting
+	gopool.Go(func() {
+		for _, hold := range holds {
+			if len(model.ParseBudgetAlertThresholds(hold.budget.AlertThresholds)) == 0 {
+				continue
+			}
+			// 缓存中的用量与提醒记录可能已过期，提醒前重新读取
+			budget, err := model.GetBudgetById(hold.budget.Id)
+			if err != nil {
+				continue
+			}
+			model.RefreshBudgetUsage(budget)
+			sendBudgetAlert(budget, userId, userEmail, userSetting)
+		}
+	})
+}
+
+func sendBudgetAlert(budget *model.Budget, userId int, userEmail string, userSetting dto.UserSetting) {
+	threshold := budget.PendingAlertThreshold()
+	if threshold == 0 {
+		return
+	}
+	marked, err := model.MarkBudgetNotified(budget, threshold)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("error marking budget %d notified: %s", budget.Id, err.Error()))
+		return
+	}
+	if !marked {
+		return
+	}
+	target := fmt.Sprintf("令牌 #%d", budget.TargetId)
+	switch budget.Scope {
+	case model.BudgetScopeUser:
+		target = fmt.Sprintf("用户 #%d", budget.TargetId)
+	case model.BudgetScopeGroup:
+		target = fmt.Sprintf("分组 %s", budget.GroupName)
+	}
+	title := "预算用量提醒"
+	resetAt := model.BudgetPeriodEnd(budget.Period, time.Now()).Format("2006-01-02 15:04:05")
+
+	if budget.Scope == model.BudgetScopeGroup {
+		// 分组预算由管理员维护，提醒发送给管理员
+		NotifyRootUser(dto.NotifyTypeBudgetAlert, title,
+			fmt.Sprintf("%s 的%s预算已使用 %d%%（%s / %s），将于 %s 重置。",
+				target, budget.Period, threshold,
+				logger.FormatQuota(budget.UsedQuota), logger.FormatQuota(budget.QuotaLimit), resetAt))
+		return
+	}
+
+	notifyType := userSetting.NotifyType
+	if notifyType == "" {
+		notifyType = dto.NotifyTypeEmail
+	}
+	var content string
+	if notifyType == dto.NotifyTypeBark || notifyType == dto.NotifyTypeGotify {
+		content = "{{value}} 的{{value}}预算已使用 {{value}}%（{{value}} / {{value}}），将于 {{value}} 重置"
+	} else {
+		content = "{{value}} 的{{value}}预算已使用 {{value}}%（{{value}} / {{value}}），将于 {{value}} 重置。<br/>达到硬上限后请求将被拒绝，请合理安排用量。"
+	}
+	values := []interface{}{target, budget.Period, threshold,
+		logger.FormatQuota(budget.UsedQuota), logger.FormatQuota(budget.QuotaLimit), resetAt}
+	if err := NotifyUser(userId, userEmail, userSetting, dto.NewNotify(dto.NotifyTypeBudgetAlert, title, content, values)); err != nil {
+		common.SysError(fmt.Sprintf("failed to send budget notify to user %d: %s", userId, err.Error()))
+	}
+}
+
+// adjustTaskBudgets 异步任务差额结算或退款时调整预算用量，仅作用于任务提交时所在的周期
+func adjustTaskBudgets(task *model.Task, delta int) {
+	adjustBudgetsAt(task.UserId, task.PrivateData.TokenId, time.Unix(task.SubmitTime, 0), task.TaskID, delta)
+}
+
+// adjustBudgetsAt 调整提交时刻所在周期的预算用量，用于异步任务的退款与差额结算
+func adjustBudgetsAt(userId int, tokenId int, submitAt time.Time, taskID string, delta int) {
+	if delta == 0 {
+		return
+	}
+	group, err := model.GetUserGroup(userId, false)
+	if err != nil {
+		group = ""
+	}
+	budgets, err := model.GetActiveBudgetsCached(tokenId, userId, group)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("error loading budgets for task %s: %s", taskID, err.Error()))
+		return
+	}
+	for _, budget := range budgets {
+		periodStart := model.BudgetPeriodStart(budget.Period, submitAt).Unix()
+		if err := model.AdjustBudgetUsage(budget.Id, periodStart, delta); err != nil {
+			common.SysLog(fmt.Sprintf("error adjusting budget %d for task %s: %s", budget.Id, taskID, err.Error()))
+		}
+	}
+}
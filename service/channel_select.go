@@ -7,8 +7,10 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type RetryParam struct {
@@ -81,6 +83,21 @@ func (p *RetryParam) ResetRetryNextTry() {
 //	Retry=3: GroupB, priority1 (startRetryIndex=2, priorityRetry=1)
 //	         分组B, 优先级1
 func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string, error) {
+	span := tracing.StartGin(param.Ctx, "channel.select",
+		attribute.String("model", param.ModelName),
+		attribute.String("group", param.TokenGroup),
+		attribute.Int("relay.retry_index", param.GetRetry()),
+	)
+	channel, selectGroup, err := cacheGetRandomSatisfiedChannel(param)
+	span.SetAttributes(attribute.String("group.selected", selectGroup))
+	if channel != nil {
+		span.SetAttributes(attribute.Int("channel.id", channel.Id), attribute.Int("channel.type", channel.Type))
+	}
+	tracing.End(span, err)
+	return channel, selectGroup, err
+}
+
+func cacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string, error) {
 	var channel *model.Channel
 	var err error
 	selectGroup := param.TokenGroup
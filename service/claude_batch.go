@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/tidwall/gjson"
+)
+
+// ClaudeBatchEndpoint Message Batches 中每个请求对应的中继路径
+const ClaudeBatchEndpoint = "/v1/messages"
+
+const (
+	ClaudeBatchStatusInProgress = "in_progress"
+	ClaudeBatchStatusCanceling  = "canceling"
+	ClaudeBatchStatusEnded      = "ended"
+)
+
+var claudeBatchCustomIdPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidateClaudeBatchRequest 校验创建请求，返回的错误信息可直接返回给用户
+func ValidateClaudeBatchRequest(req *dto.ClaudeMessageBatchCreateRequest) error {
+	if len(req.Requests) == 0 {
+		return errors.New("requests: at least one request is required")
+	}
+	maxRequests := operation_setting.GetBatchSetting().MaxRequestsPerBatch
+	if maxRequests > 0 && len(req.Requests) > maxRequests {
+		return fmt.Errorf("requests: the batch exceeds the maximum of %d requests", maxRequests)
+	}
+	customIds := make(map[string]struct{}, len(req.Requests))
+	for i, item := range req.Requests {
+		if !claudeBatchCustomIdPattern.MatchString(item.CustomId) {
+			return fmt.Errorf("requests.%d.custom_id: must be 1-64 characters of letters, digits, underscores or hyphens", i)
+		}
+		if _, dup := customIds[item.CustomId]; dup {
+			return fmt.Errorf("requests.%d.custom_id: duplicate custom_id '%s'", i, item.CustomId)
+		}
+		customIds[item.CustomId] = struct{}{}
+		if !gjson.ValidBytes(item.Params) || !gjson.ParseBytes(item.Params).IsObject() {
+			return fmt.Errorf("requests.%d.params: expected a JSON object", i)
+		}
+		if gjson.GetBytes(item.Params, "model").String() == "" {
+			return fmt.Errorf("requests.%d.params.model: field required", i)
+		}
+	}
+	return nil
+}
+
+// CreateClaudeBatch 将请求写为内部 JSONL 输入文件后创建批处理任务，执行、计费与 /v1/batches 共用同一 worker
+func CreateClaudeBatch(userId int, tokenId int, clientIp string, req *dto.ClaudeMessageBatchCreateRequest) (*model.Batch, error) {
+	var buf bytes.Buffer
+	for _, item := range req.Requests {
+		line, err := common.Marshal(dto.OpenAIBatchRequestLine{
+			CustomId: item.CustomId,
+			Method:   http.MethodPost,
+			Url:      ClaudeBatchEndpoint,
+			Body:     item.Params,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	batchId := model.GenerateClaudeBatchId()
+	inputFile, err := SaveGeneratedFile(userId, tokenId, batchId+"_input.jsonl", FilePurposeBatch, "application/jsonl", &buf)
+	if err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          batchId,
+		UserId:           userId,
+		TokenId:          tokenId,
+		ClientIp:         clientIp,
+		Endpoint:         ClaudeBatchEndpoint,
+		InputFileId:      inputFile.FileId,
+		CompletionWindow: BatchCompletionWindow,
+		Status:           model.BatchStatusValidating,
+		TotalCount:       len(req.Requests),
+		CreatedAt:        now,
+		ExpiresAt:        now + batchCompletionWindowSeconds,
+	}
+	if err := batch.Insert(); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func claudeBatchTime(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+func optionalClaudeBatchTime(ts int64) *string {
+	if ts == 0 {
+		return nil
+	}
+	t := claudeBatchTime(ts)
+	return &t
+}
+
+// ClaudeBatchToAnthropic 转换为 Anthropic message_batch 对象
+func ClaudeBatchToAnthropic(batch *model.Batch) dto.ClaudeMessageBatch {
+	resp := dto.ClaudeMessageBatch{
+		Id:                batch.BatchId,
+		Type:              "message_batch",
+		ProcessingStatus:  ClaudeBatchStatusInProgress,
+		CreatedAt:         claudeBatchTime(batch.CreatedAt),
+		ExpiresAt:         claudeBatchTime(batch.ExpiresAt),
+		CancelInitiatedAt: optionalClaudeBatchTime(batch.CancellingAt),
+	}
+	counts := &resp.RequestCounts
+	counts.Succeeded = batch.CompletedCount
+	if !batch.IsFinished() {
+		if batch.Status == model.BatchStatusCancelling {
+			resp.ProcessingStatus = ClaudeBatchStatusCanceling
+		}
+		counts.Errored = batch.FailedCount
+		counts.Processing = max(batch.TotalCount-batch.CompletedCount-batch.FailedCount, 0)
+		return resp
+	}
+
+	resp.ProcessingStatus = ClaudeBatchStatusEnded
+	// 未执行的请求按批处理终态计入 canceled / expired，已执行失败的计入 errored
+	counts.Errored = batch.FailedCount - batch.UnprocessedCount
+	remaining := max(batch.TotalCount-counts.Succeeded-counts.Errored, 0)
+	var endedAt int64
+	switch batch.Status {
+	case model.BatchStatusCancelled:
+		counts.Canceled = remaining
+		endedAt = batch.CancelledAt
+	case model.BatchStatusExpired:
+		counts.Expired = remaining
+		endedAt = batch.ExpiredAt
+	case model.BatchStatusFailed:
+		counts.Errored += remaining
+		endedAt = batch.FailedAt
+	default:
+		endedAt = batch.CompletedAt
+	}
+	resp.EndedAt = optionalClaudeBatchTime(endedAt)
+	resultsUrl := fmt.Sprintf("%s/v1/messages/batches/%s/results", system_setting.ServerAddress, batch.BatchId)
+	resp.ResultsUrl = &resultsUrl
+	return resp
+}
+
+func claudeBatchErrorBody(errorType string, message string) []byte {
+	body, _ := common.Marshal(map[string]any{
+		"type": "error",
+		"error": map[string]string{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+	return body
+}
+
+// claudeBatchResultFromLine 将 worker 写出的结果行转换为 Anthropic 结果行
+func claudeBatchResultFromLine(line *dto.OpenAIBatchResponseLine) dto.ClaudeMessageBatchResultLine {
+	result := dto.ClaudeMessageBatchResultLine{CustomId: line.CustomId}
+	switch {
+	case line.Error != nil && line.Error.Code == "batch_cancelled":
+		result.Result.Type = "canceled"
+	case line.Error != nil && line.Error.Code == "batch_expired":
+		result.Result.Type = "expired"
+	case line.Error != nil:
+		result.Result.Type = "errored"
+		result.Result.Error = claudeBatchErrorBody("api_error", line.Error.Message)
+	case line.Response == nil:
+		result.Result.Type = "errored"
+		result.Result.Error = claudeBatchErrorBody("api_error", "missing response")
+	case line.Response.StatusCode >= 200 && line.Response.StatusCode < 300:
+		result.Result.Type = "succeeded"
+		result.Result.Message = line.Response.Body
+	default:
+		result.Result.Type = "errored"
+		if gjson.GetBytes(line.Response.Body, "type").String() == "error" {
+			result.Result.Error = line.Response.Body
+		} else {
+			message := gjson.GetBytes(line.Response.Body, "error.message").String()
+			if message == "" {
+				message = fmt.Sprintf("upstream returned status %d", line.Response.StatusCode)
+			}
+			result.Result.Error = claudeBatchErrorBody("api_error", message)
+		}
+	}
+	return result
+}
+
+// forEachBatchLine 逐行读取用户文件并回调
+func forEachBatchLine(userId int, fileId string, fn func(raw []byte) error) error {
+	file, err := model.GetUserFileByFileId(userId, fileId)
+	if err != nil {
+		return err
+	}
+	content, err := OpenFileContent(file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	reader := bufio.NewReader(content)
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 {
+			if err := fn(raw); err != nil {
+				return err
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return nil
+			}
+			return readErr
+		}
+	}
+}
+
+// WriteClaudeBatchResults 以 Anthropic 结果 JSONL 格式输出已结束批处理的全部结果
+func WriteClaudeBatchResults(w io.Writer, batch *model.Batch) error {
+	seen := make(map[string]struct{}, batch.TotalCount)
+	writeLine := func(result dto.ClaudeMessageBatchResultLine) error {
+		data, err := common.Marshal(result)
+		if err != nil {
+			return err
+		}
+		seen[result.CustomId] = struct{}{}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	for _, fileId := range []string{batch.OutputFileId, batch.ErrorFileId} {
+		if fileId == "" {
+			continue
+		}
+		err := forEachBatchLine(batch.UserId, fileId, func(raw []byte) error {
+			var line dto.OpenAIBatchResponseLine
+			if err := common.Unmarshal(raw, &line); err != nil {
+				return err
+			}
+			return writeLine(claudeBatchResultFromLine(&line))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// 未开始执行即取消、过期或失败的批处理没有结果文件，按输入文件补齐
+	missing := dto.ClaudeMessageBatchResult{}
+	switch batch.Status {
+	case model.BatchStatusCancelled:
+		missing.Type = "canceled"
+	case model.BatchStatusExpired:
+		missing.Type = "expired"
+	case model.BatchStatusFailed:
+		message := "The batch failed before this request was processed."
+		if len(batch.Errors) > 0 {
+			message = batch.Errors[0].Message
+		}
+		missing.Type = "errored"
+		missing.Error = claudeBatchErrorBody("api_error", message)
+	default:
+		return nil
+	}
+	return forEachBatchLine(batch.UserId, batch.InputFileId, func(raw []byte) error {
+		customId := gjson.GetBytes(raw, "custom_id").String()
+		if _, ok := seen[customId]; ok {
+			return nil
+		}
+		return writeLine(dto.ClaudeMessageBatchResultLine{CustomId: customId, Result: missing})
+	})
+}
@@ -618,6 +618,9 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 
 	// 转换 messages
 	var messages []dto.Message
+	// Gemini 的函数调用没有 ID，按函数名顺序匹配调用与结果
+	pendingCalls := make(map[string][]string)
+	callCount := 0
 	for _, content := range geminiRequest.Contents {
 		message := dto.Message{
 			Role: convertGeminiRoleToOpenAI(content.Role),
@@ -627,7 +630,9 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		var mediaContents []dto.MediaContent
 		var toolCalls []dto.ToolCallRequest
 		for _, part := range content.Parts {
-			if part.Text != "" {
+			if part.Thought {
+				continue
+			} else if part.Text != "" {
 				mediaContent := dto.MediaContent{
 					Type: "text",
 					Text: part.Text,
@@ -655,8 +660,11 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.FunctionCall != nil {
 				// 处理 Gemini 的工具调用
+				callCount++
+				toolCallId := fmt.Sprintf("call_%d", callCount)
+				pendingCalls[part.FunctionCall.FunctionName] = append(pendingCalls[part.FunctionCall.FunctionName], toolCallId)
 				toolCall := dto.ToolCallRequest{
-					ID:   fmt.Sprintf("call_%d", len(toolCalls)+1), // 生成唯一ID
+					ID:   toolCallId,
 					Type: "function",
 					Function: dto.FunctionRequest{
 						Name:      part.FunctionCall.FunctionName,
@@ -666,9 +674,14 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				toolCalls = append(toolCalls, toolCall)
 			} else if part.FunctionResponse != nil {
 				// 处理 Gemini 的工具响应，创建单独的 tool 消息
+				toolCallId := ""
+				if ids := pendingCalls[part.FunctionResponse.Name]; len(ids) > 0 {
+					toolCallId = ids[0]
+					pendingCalls[part.FunctionResponse.Name] = ids[1:]
+				}
 				toolMessage := dto.Message{
 					Role:       "tool",
-					ToolCallId: fmt.Sprintf("call_%d", len(toolCalls)), // 使用对应的调用ID
+					ToolCallId: toolCallId, // 使用对应的调用ID
 				}
 				toolMessage.SetStringContent(toJSONString(part.FunctionResponse.Response))
 				messages = append(messages, toolMessage)
@@ -708,8 +721,8 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		openaiRequest.MaxTokens = geminiRequest.GenerationConfig.MaxOutputTokens
 	}
 	// gemini stop sequences 最多 5 个，openai stop 最多 4 个
-	if len(geminiRequest.GenerationConfig.StopSequences) > 0 {
-		openaiRequest.Stop = geminiRequest.GenerationConfig.StopSequences[:4]
+	if stopSequences := geminiRequest.GenerationConfig.StopSequences; len(stopSequences) > 0 {
+		openaiRequest.Stop = stopSequences[:min(len(stopSequences), 4)]
 	}
 	if geminiRequest.GenerationConfig.CandidateCount > 0 {
 		openaiRequest.N = geminiRequest.GenerationConfig.CandidateCount
@@ -779,6 +792,18 @@ func extractTextFromGeminiParts(parts []dto.GeminiPart) string {
 	return strings.Join(texts, "\n")
 }
 
+// GeminiIncludeThoughts Gemini 请求开启 includeThoughts 时才返回思考内容
+func GeminiIncludeThoughts(info *relaycommon.RelayInfo) bool {
+	if info == nil {
+		return false
+	}
+	request, ok := info.Request.(*dto.GeminiChatRequest)
+	if !ok || request.GenerationConfig.ThinkingConfig == nil {
+		return false
+	}
+	return request.GenerationConfig.ThinkingConfig.IncludeThoughts
+}
+
 // ResponseOpenAI2Gemini 将 OpenAI 响应转换为 Gemini 格式
 func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
 	geminiResponse := &dto.GeminiChatResponse{
@@ -818,6 +843,10 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 			Parts: make([]dto.GeminiPart, 0),
 		}
 
+		if reasoningContent := choice.Message.ReasoningContent; reasoningContent != "" && GeminiIncludeThoughts(info) {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: reasoningContent, Thought: true})
+		}
+
 		// 处理工具调用
 		toolCalls := choice.Message.ParseToolCalls()
 		if len(toolCalls) > 0 {
@@ -863,8 +892,10 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 	// 检查是否有实际内容或结束标志
 	hasContent := false
 	hasFinishReason := false
+	includeThoughts := GeminiIncludeThoughts(info)
 	for _, choice := range openAIResponse.Choices {
-		if len(choice.Delta.GetContentString()) > 0 || (choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0) {
+		if len(choice.Delta.GetContentString()) > 0 || (choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0) ||
+			(includeThoughts && len(choice.Delta.GetReasoningContent()) > 0) {
 			hasContent = true
 		}
 		if choice.FinishReason != nil {
@@ -922,6 +953,10 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 			Parts: make([]dto.GeminiPart, 0),
 		}
 
+		if reasoningContent := choice.Delta.GetReasoningContent(); reasoningContent != "" && includeThoughts {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: reasoningContent, Thought: true})
+		}
+
 		// 处理工具调用
 		if choice.Delta.ToolCalls != nil {
 			for _, toolCall := range choice.Delta.ToolCalls {
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmbeddingUsage 上游未返回输入 token 数时使用本地预估值计费
+func EmbeddingUsage(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int) *dto.Usage {
+	if promptTokens <= 0 {
+		return ResponseText2Usage(c, "", info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	return &dto.Usage{PromptTokens: promptTokens, TotalTokens: promptTokens}
+}
+
+// EncodeEmbeddingBase64 与 OpenAI 一致，将向量编码为 float32 小端字节序的 base64
+func EncodeEmbeddingBase64(embedding []float64) string {
+	buf := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// MarshalEmbeddingResponse 按请求的 encoding_format 序列化转换后的 OpenAI embedding 响应
+func MarshalEmbeddingResponse(info *relaycommon.RelayInfo, response *dto.OpenAIEmbeddingResponse) ([]byte, error) {
+	request, ok := info.Request.(*dto.EmbeddingRequest)
+	if !ok || request.EncodingFormat != "base64" {
+		return common.Marshal(response)
+	}
+	flexible := dto.FlexibleEmbeddingResponse{
+		Object: response.Object,
+		Data:   make([]dto.FlexibleEmbeddingResponseItem, 0, len(response.Data)),
+		Model:  response.Model,
+		Usage:  response.Usage,
+	}
+	for _, item := range response.Data {
+		flexible.Data = append(flexible.Data, dto.FlexibleEmbeddingResponseItem{
+			Object:    item.Object,
+			Index:     item.Index,
+			Embedding: EncodeEmbeddingBase64(item.Embedding),
+		})
+	}
+	return common.Marshal(flexible)
+}
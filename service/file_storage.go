@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// FileStorage Files API 的存储后端抽象
+// 内置 local（磁盘）实现，其他后端（如对象存储）可通过 RegisterFileStorage 注册
+type FileStorage interface {
+	Name() string
+	// Save 保存文件内容并返回实际写入字节数
+	Save(key string, r io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+var (
+	fileStoragesMu sync.RWMutex
+	fileStorages   = map[string]FileStorage{}
+)
+
+func init() {
+	RegisterFileStorage(&localFileStorage{})
+}
+
+// RegisterFileStorage 注册存储后端，同名后端会被覆盖
+func RegisterFileStorage(storage FileStorage) {
+	fileStoragesMu.Lock()
+	defer fileStoragesMu.Unlock()
+	fileStorages[storage.Name()] = storage
+}
+
+// GetFileStorage 根据名称获取存储后端
+func GetFileStorage(name string) (FileStorage, error) {
+	fileStoragesMu.RLock()
+	defer fileStoragesMu.RUnlock()
+	storage, ok := fileStorages[name]
+	if !ok {
+		return nil, fmt.Errorf("file storage backend %q not registered", name)
+	}
+	return storage, nil
+}
+
+// localFileStorage 基于磁盘缓存目录的存储实现
+type localFileStorage struct{}
+
+func (s *localFileStorage) Name() string {
+	return "local"
+}
+
+func (s *localFileStorage) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || filepath.IsAbs(key) {
+		return "", errors.New("invalid storage key")
+	}
+	return filepath.Join(common.GetDiskFileStoreDir(), filepath.FromSlash(key)), nil
+}
+
+func (s *localFileStorage) Save(key string, r io.Reader) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create file store dir: %w", err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(p)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *localFileStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *localFileStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -7,12 +7,12 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// FundingSource — 资金来源接口（钱包 or 订阅）
+// FundingSource — 资金来源接口（钱包 / 订阅 / 组织钱包）
 // ---------------------------------------------------------------------------
 
 // FundingSource 抽象了预扣费的资金来源。
 type FundingSource interface {
-	// Source 返回资金来源标识："wallet" 或 "subscription"
+	// Source 返回资金来源标识："wallet"、"subscription" 或 "org"
 	Source() string
 	// PreConsume 从该资金来源预扣 amount 额度
 	PreConsume(amount int) error
@@ -27,8 +27,10 @@ type FundingSource interface {
 // ---------------------------------------------------------------------------
 
 type WalletFunding struct {
-	userId   int
-	consumed int // 实际预扣的用户额度
+	userId    int
+	requestId string
+	modelName string
+	consumed  int // 实际预扣的用户额度
 }
 
 func (w *WalletFunding) Source() string { return BillingSourceWallet }
@@ -37,7 +39,7 @@ func (w *WalletFunding) PreConsume(amount int) error {
 	if amount <= 0 {
 		return nil
 	}
-	if err := model.DecreaseUserQuota(w.userId, amount); err != nil {
+	if err := model.DecreaseUserQuota(w.userId, amount, model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventPreConsume, w.requestId).WithModel(w.modelName)); err != nil {
 		return err
 	}
 	w.consumed = amount
@@ -48,19 +50,22 @@ func (w *WalletFunding) Settle(delta int) error {
 	if delta == 0 {
 		return nil
 	}
+	ref := model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventSettle, w.requestId).WithModel(w.modelName)
 	if delta > 0 {
-		return model.DecreaseUserQuota(w.userId, delta)
+		return model.DecreaseUserQuota(w.userId, delta, ref)
 	}
-	return model.IncreaseUserQuota(w.userId, -delta, false)
+	return model.IncreaseUserQuota(w.userId, -delta, false, ref)
 }
 
 func (w *WalletFunding) Refund() error {
 	if w.consumed <= 0 {
 		return nil
 	}
-	// IncreaseUserQuota 是 quota += N 的非幂等操作，不能重试，否则会多退额度。
-	// 订阅的 RefundSubscriptionPreConsume 有 requestId 幂等保护所以可以重试。
-	return model.IncreaseUserQuota(w.userId, w.consumed, false)
+	// 退款凭证的幂等键由 requestId 派生，重试不会多退额度
+	ref := model.NewEventLedgerRef(model.LedgerReasonRefund, model.LedgerEventRefund, w.requestId).WithModel(w.modelName)
+	return refundWithRetry(func() error {
+		return model.IncreaseUserQuota(w.userId, w.consumed, false, ref)
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -105,7 +110,7 @@ func (s *SubscriptionFunding) Settle(delta int) error {
 	if delta == 0 {
 		return nil
 	}
-	return model.PostConsumeUserSubscriptionDelta(s.subscriptionId, int64(delta))
+	return model.PostConsumeUserSubscriptionDelta(s.subscriptionId, int64(delta), model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventSettle, s.requestId).WithModel(s.modelName))
 }
 
 func (s *SubscriptionFunding) Refund() error {
@@ -117,6 +122,47 @@ func (s *SubscriptionFunding) Refund() error {
 	})
 }
 
+// ---------------------------------------------------------------------------
+// OrgFunding — 组织钱包资金来源实现
+// ---------------------------------------------------------------------------
+
+// OrgFunding 从组织钱包扣费，同时累计成员消费以执行成员消费上限。
+type OrgFunding struct {
+	orgId     int
+	userId    int
+	requestId string
+	modelName string
+	consumed  int // 实际预扣的组织额度
+}
+
+func (o *OrgFunding) Source() string { return BillingSourceOrg }
+
+func (o *OrgFunding) PreConsume(amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	if err := model.PreConsumeOrganizationQuota(o.orgId, o.userId, amount, model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventPreConsume, o.requestId).WithModel(o.modelName)); err != nil {
+		return err
+	}
+	o.consumed = amount
+	return nil
+}
+
+func (o *OrgFunding) Settle(delta int) error {
+	return model.PostConsumeOrganizationQuota(o.orgId, o.userId, delta, model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventSettle, o.requestId).WithModel(o.modelName))
+}
+
+func (o *OrgFunding) Refund() error {
+	if o.consumed <= 0 {
+		return nil
+	}
+	// 与钱包相同，退款凭证按 requestId 幂等，可以重试
+	ref := model.NewEventLedgerRef(model.LedgerReasonRefund, model.LedgerEventRefund, o.requestId).WithModel(o.modelName)
+	return refundWithRetry(func() error {
+		return model.PostConsumeOrganizationQuota(o.orgId, o.userId, -o.consumed, ref)
+	})
+}
+
 // refundWithRetry 尝试多次执行退款操作以提高成功率，只能用于基于事务的退款函数！！！！！！
 // try to refund with retries, only for refund functions based on transactions!!!
 func refundWithRetry(fn func() error) error {
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FilePurposeImageHosting 网关临时托管的生成图片，仅内部使用，不允许用户上传
+const FilePurposeImageHosting = "image_hosting"
+
+// ImageInput 图片编辑接口的输入图片（base64，不含 data URL 前缀）
+type ImageInput struct {
+	MimeType string
+	Data     string
+}
+
+// HostedImageURL 托管图片的公开访问地址
+func HostedImageURL(fileId string) string {
+	return fmt.Sprintf("%s/v1/images/hosted/%s", strings.TrimRight(system_setting.ServerAddress, "/"), fileId)
+}
+
+// HostImage 将 base64 图片保存为临时文件并返回公开访问地址
+func HostImage(userId int, tokenId int, b64 string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = "image/png"
+	}
+	filename := "image." + strings.TrimPrefix(mimeType, "image/")
+	expireSeconds := int64(operation_setting.GetFileSetting().ImageHostingExpireMinutes) * 60
+	file, err := storeFile(userId, tokenId, filename, FilePurposeImageHosting, mimeType, bytes.NewReader(data), 0, expireSeconds)
+	if err != nil {
+		return "", err
+	}
+	return HostedImageURL(file.FileId), nil
+}
+
+// ApplyImageResponseFormat 按请求的 response_format 统一转换后的图片响应：
+// 请求 url 而上游仅返回 base64 时由网关临时托管；请求 b64_json 而上游仅返回 URL 时下载转码
+func ApplyImageResponseFormat(info *relaycommon.RelayInfo, response *dto.ImageResponse) error {
+	request, ok := info.Request.(*dto.ImageRequest)
+	if !ok {
+		return nil
+	}
+	format := strings.ToLower(request.ResponseFormat)
+	hostingEnabled := operation_setting.GetFileSetting().ImageHostingEnabled
+	for i := range response.Data {
+		item := &response.Data[i]
+		switch {
+		case format == "url" && item.Url == "" && item.B64Json != "" && hostingEnabled:
+			url, err := HostImage(info.UserId, info.TokenId, item.B64Json)
+			if err != nil {
+				return fmt.Errorf("failed to host image: %w", err)
+			}
+			item.Url = url
+			item.B64Json = ""
+		case format == "b64_json" && item.B64Json == "" && item.Url != "":
+			_, data, err := GetImageFromUrl(item.Url)
+			if err != nil {
+				return err
+			}
+			item.B64Json = data
+			item.Url = ""
+		}
+	}
+	return nil
+}
+
+func readImageFormFile(header *multipart.FileHeader) (*ImageInput, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	return &ImageInput{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+func parseImageInput(value string) (*ImageInput, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		mimeType, data, err := GetImageFromUrl(value)
+		if err != nil {
+			return nil, err
+		}
+		return &ImageInput{MimeType: mimeType, Data: data}, nil
+	}
+	mimeType, data, err := DecodeBase64FileData(value)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageInput{MimeType: mimeType, Data: data}, nil
+}
+
+// parseImageInputJSON 解析 JSON 请求中的图片字段，支持字符串、字符串数组与 {"image_url": ...} 对象，仅取第一张
+func parseImageInputJSON(raw []byte) (*ImageInput, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var value any
+	if err := common.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	if list, ok := value.([]any); ok {
+		if len(list) == 0 {
+			return nil, nil
+		}
+		value = list[0]
+	}
+	switch v := value.(type) {
+	case string:
+		return parseImageInput(v)
+	case map[string]any:
+		if url, ok := v["image_url"].(string); ok {
+			return parseImageInput(url)
+		}
+		if url, ok := v["url"].(string); ok {
+			return parseImageInput(url)
+		}
+	}
+	return nil, errors.New("unsupported image format")
+}
+
+// GetImageEditInputs 读取 /v1/images/edits 的原图与蒙版，兼容 multipart 表单与 JSON 请求
+func GetImageEditInputs(c *gin.Context, request *dto.ImageRequest) (img *ImageInput, mask *ImageInput, err error) {
+	if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse multipart form: %w", err)
+		}
+		for _, key := range []string{"image", "image[]"} {
+			if files := form.File[key]; len(files) > 0 {
+				if img, err = readImageFormFile(files[0]); err != nil {
+					return nil, nil, err
+				}
+				break
+			}
+		}
+		if files := form.File["mask"]; len(files) > 0 {
+			if mask, err = readImageFormFile(files[0]); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		if img, err = parseImageInputJSON(request.Image); err != nil {
+			return nil, nil, fmt.Errorf("invalid image: %w", err)
+		}
+		if mask, err = parseImageInputJSON(request.Extra["mask"]); err != nil {
+			return nil, nil, fmt.Errorf("invalid mask: %w", err)
+		}
+	}
+	if img == nil {
+		return nil, nil, errors.New("image is required")
+	}
+	return img, mask, nil
+}
+
+// SetImageCountRatio 按次计费时按上游实际生成的张数计费
+func SetImageCountRatio(info *relaycommon.RelayInfo, count int) {
+	if info.PriceData.UsePrice && count > 0 {
+		info.PriceData.AddOtherRatio("n", float64(count))
+	}
+}
+
+// ConvertImageMask 将 OpenAI 风格的透明蒙版（透明区域为待编辑区域）转换为黑白蒙版，
+// editWhite 为 true 时待编辑区域为白色，否则为黑色；不含透明像素的蒙版视为已是黑白蒙版，原样返回
+func ConvertImageMask(mask *ImageInput, editWhite bool) (*ImageInput, error) {
+	data, err := base64.StdEncoding.DecodeString(mask.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mask: %w", err)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mask: %w", err)
+	}
+	bounds := src.Bounds()
+	dst := image.NewGray(bounds)
+	hasTransparent := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := src.At(x, y).RGBA()
+			edit := a == 0
+			if edit {
+				hasTransparent = true
+			}
+			if edit == editWhite {
+				dst.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	if !hasTransparent {
+		return mask, nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return &ImageInput{MimeType: "image/png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestPNG(t *testing.T, img image.Image) *ImageInput {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return &ImageInput{MimeType: "image/png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}
+}
+
+func decodeTestGray(t *testing.T, input *ImageInput) image.Image {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(input.Data)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	return img
+}
+
+func TestConvertImageMask(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.NRGBA{A: 0})
+	src.Set(1, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	mask := encodeTestPNG(t, src)
+
+	white, err := ConvertImageMask(mask, true)
+	require.NoError(t, err)
+	img := decodeTestGray(t, white)
+	assert.Equal(t, uint8(255), color.GrayModel.Convert(img.At(0, 0)).(color.Gray).Y)
+	assert.Equal(t, uint8(0), color.GrayModel.Convert(img.At(1, 0)).(color.Gray).Y)
+
+	black, err := ConvertImageMask(mask, false)
+	require.NoError(t, err)
+	img = decodeTestGray(t, black)
+	assert.Equal(t, uint8(0), color.GrayModel.Convert(img.At(0, 0)).(color.Gray).Y)
+	assert.Equal(t, uint8(255), color.GrayModel.Convert(img.At(1, 0)).(color.Gray).Y)
+}
+
+func TestConvertImageMaskOpaqueUnchanged(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 1, 1))
+	mask := encodeTestPNG(t, src)
+
+	converted, err := ConvertImageMask(mask, true)
+	require.NoError(t, err)
+	assert.Same(t, mask, converted)
+}
@@ -49,6 +49,20 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
+	if relayInfo.ResponseCacheHit {
+		other["response_cache_hit"] = true
+		other["response_cache_ratio"] = relayInfo.PriceData.OtherRatios["response_cache_hit"]
+		if relayInfo.ResponseCacheSimilarity > 0 {
+			other["response_cache_similarity"] = relayInfo.ResponseCacheSimilarity
+		}
+	}
+	if relayInfo.BatchId != "" {
+		other["batch_id"] = relayInfo.BatchId
+		other["batch_ratio"] = relayInfo.PriceData.BatchDiscount()
+	}
+	if relayInfo.PriceData.PricingRule != nil {
+		other["pricing_rule"] = relayInfo.PriceData.PricingRule
+	}
 
 	isSystemPromptOverwritten := common.GetContextKeyBool(ctx, constant.ContextKeySystemPromptOverride)
 	if isSystemPromptOverwritten {
@@ -68,6 +82,11 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		adminInfo["local_count_tokens"] = isLocalCountTokens
 	}
 
+	if relayInfo.Hedge.Hedged() {
+		other["hedged"] = true
+		adminInfo["hedge_attempt"] = relayInfo.Hedge.Index
+	}
+
 	AppendChannelAffinityAdminInfo(ctx, adminInfo)
 
 	other["admin_info"] = adminInfo
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const (
+	BatchCompletionWindow              = "24h"
+	batchCompletionWindowSeconds int64 = 24 * 3600
+
+	FilePurposeBatch       = "batch"
+	FilePurposeBatchOutput = "batch_output"
+)
+
+func optionalTimestamp(ts int64) *int64 {
+	if ts == 0 {
+		return nil
+	}
+	return &ts
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// BatchToOpenAI 转换为 OpenAI 批处理对象
+func BatchToOpenAI(batch *model.Batch) dto.OpenAIBatch {
+	resp := dto.OpenAIBatch{
+		ID:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		OutputFileId:     optionalString(batch.OutputFileId),
+		ErrorFileId:      optionalString(batch.ErrorFileId),
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optionalTimestamp(batch.InProgressAt),
+		ExpiresAt:        optionalTimestamp(batch.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(batch.FinalizingAt),
+		CompletedAt:      optionalTimestamp(batch.CompletedAt),
+		FailedAt:         optionalTimestamp(batch.FailedAt),
+		ExpiredAt:        optionalTimestamp(batch.ExpiredAt),
+		CancellingAt:     optionalTimestamp(batch.CancellingAt),
+		CancelledAt:      optionalTimestamp(batch.CancelledAt),
+		RequestCounts: dto.OpenAIBatchRequestCounts{
+			Total:     batch.TotalCount,
+			Completed: batch.CompletedCount,
+			Failed:    batch.FailedCount,
+		},
+		Metadata: batch.Metadata,
+	}
+	if len(batch.Errors) > 0 {
+		resp.Errors = &dto.OpenAIBatchErrors{
+			Object: "list",
+			Data:   make([]dto.OpenAIBatchError, 0, len(batch.Errors)),
+		}
+		for _, e := range batch.Errors {
+			resp.Errors.Data = append(resp.Errors.Data, dto.OpenAIBatchError{
+				Code:    e.Code,
+				Message: e.Message,
+				Param:   e.Param,
+				Line:    e.Line,
+			})
+		}
+	}
+	return resp
+}
+
+// CreateBatch 创建批处理任务，任务进入 validating 状态后由后台 worker 领取执行
+func CreateBatch(userId int, tokenId int, clientIp string, req *dto.OpenAIBatchCreateRequest) (*model.Batch, error) {
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          model.GenerateBatchId(),
+		UserId:           userId,
+		TokenId:          tokenId,
+		ClientIp:         clientIp,
+		Endpoint:         req.Endpoint,
+		InputFileId:      req.InputFileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           model.BatchStatusValidating,
+		Metadata:         req.Metadata,
+		CreatedAt:        now,
+		ExpiresAt:        now + batchCompletionWindowSeconds,
+	}
+	if err := batch.Insert(); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// CancelBatch 取消批处理任务
+// 尚未开始执行的任务直接取消；执行中的任务进入 cancelling，由 worker 停止派发后完成取消
+func CancelBatch(batch *model.Batch) (*model.Batch, error) {
+	now := common.GetTimestamp()
+	ok, err := model.UpdateBatchWithStatus(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+		"status":        model.BatchStatusCancelled,
+		"cancelling_at": now,
+		"cancelled_at":  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if _, err = model.UpdateBatchWithStatus(batch.Id, model.BatchStatusInProgress, map[string]interface{}{
+			"status":        model.BatchStatusCancelling,
+			"cancelling_at": now,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return model.GetBatchById(batch.Id)
+}
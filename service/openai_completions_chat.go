@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
+
+func ShouldCompletionsUseChatGlobal(channelID int, channelType int, model string) bool {
+	return openaicompat.ShouldCompletionsUseChatGlobal(channelID, channelType, model)
+}
+
+func CompletionsRequestToChatRequest(req *dto.GeneralOpenAIRequest, policy model_setting.CompletionsToChatPolicy) (*dto.GeneralOpenAIRequest, error) {
+	return openaicompat.CompletionsRequestToChatRequest(req, policy)
+}
+
+func CompletionsPromptText(req *dto.GeneralOpenAIRequest) string {
+	return openaicompat.CompletionsPromptText(req)
+}
+
+func ChatResponseToCompletionsResponse(resp *dto.OpenAITextResponse, echo string) *dto.CompletionsResponse {
+	return openaicompat.ChatResponseToCompletionsResponse(resp, echo)
+}
+
+func ChatStreamResponseToCompletionsResponse(chunk *dto.ChatCompletionsStreamResponse, echo string, echoed map[int]bool) *dto.CompletionsResponse {
+	return openaicompat.ChatStreamResponseToCompletionsResponse(chunk, echo, echoed)
+}
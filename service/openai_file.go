@@ -0,0 +1,308 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	fileCleanupTickInterval = 10 * time.Minute
+	fileCleanupBatchSize    = 200
+)
+
+var (
+	ErrFileTooLarge           = errors.New("file exceeds the maximum allowed size")
+	ErrFileStorageQuotaExceed = errors.New("file storage quota exceeded")
+
+	fileIdPattern = regexp.MustCompile(`"(file-[0-9A-Za-z]{24})"`)
+
+	fileCleanupOnce    sync.Once
+	fileCleanupRunning atomic.Bool
+)
+
+// FileToOpenAI 转换为 OpenAI 文件对象
+func FileToOpenAI(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		ID:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		ExpiresAt: file.ExpiresAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+// UploadFile 保存上传文件，校验单文件大小与用户存储配额
+func UploadFile(userId int, tokenId int, header *multipart.FileHeader, purpose string) (*model.File, error) {
+	maxSize := operation_setting.GetMaxFileSizeBytes()
+	if maxSize > 0 && header.Size > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	if quota := operation_setting.GetUserStorageQuotaBytes(); quota > 0 {
+		used, err := model.GetUserFileStorageUsage(userId)
+		if err != nil {
+			return nil, err
+		}
+		if used+header.Size > quota {
+			return nil, ErrFileStorageQuotaExceed
+		}
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return storeFile(userId, tokenId, header.Filename, purpose, header.Header.Get("Content-Type"), src, maxSize, 0)
+}
+
+// SaveGeneratedFile 保存网关生成的文件（如批处理输出），不受单文件大小与存储配额限制
+func SaveGeneratedFile(userId int, tokenId int, filename string, purpose string, mimeType string, r io.Reader) (*model.File, error) {
+	return storeFile(userId, tokenId, filename, purpose, mimeType, r, 0, 0)
+}
+
+// storeFile 写入存储并创建文件记录，expireSeconds 为 0 时使用默认保留时长
+func storeFile(userId int, tokenId int, filename string, purpose string, mimeType string, src io.Reader, maxSize int64, expireSeconds int64) (*model.File, error) {
+	setting := operation_setting.GetFileSetting()
+	storage, err := GetFileStorage(setting.StorageBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	fileId := model.GenerateFileId()
+	storageKey := fmt.Sprintf("%d/%s", userId, fileId)
+	reader := src
+	if maxSize > 0 {
+		// 多读一个字节用于判断实际内容是否超限（header.Size 由客户端提供，不完全可信）
+		reader = io.LimitReader(src, maxSize+1)
+	}
+	written, err := storage.Save(storageKey, reader)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && written > maxSize {
+		_ = storage.Delete(storageKey)
+		return nil, ErrFileTooLarge
+	}
+
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	file := &model.File{
+		FileId:         fileId,
+		UserId:         userId,
+		TokenId:        tokenId,
+		Filename:       filename,
+		Purpose:        purpose,
+		Bytes:          written,
+		MimeType:       mimeType,
+		Status:         model.FileStatusProcessed,
+		StorageBackend: storage.Name(),
+		StorageKey:     storageKey,
+		CreatedAt:      common.GetTimestamp(),
+	}
+	if expireSeconds > 0 {
+		file.ExpiresAt = file.CreatedAt + expireSeconds
+	} else if setting.DefaultExpireHours > 0 {
+		file.ExpiresAt = file.CreatedAt + int64(setting.DefaultExpireHours)*3600
+	}
+	if err := file.Insert(); err != nil {
+		_ = storage.Delete(storageKey)
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenFileContent 打开文件内容，调用方负责关闭
+func OpenFileContent(file *model.File) (io.ReadCloser, error) {
+	storage, err := GetFileStorage(file.StorageBackend)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(file.StorageKey)
+}
+
+// DeleteFile 删除文件记录及存储内容
+func DeleteFile(file *model.File) error {
+	if err := model.DeleteFileById(file.Id); err != nil {
+		return err
+	}
+	storage, err := GetFileStorage(file.StorageBackend)
+	if err != nil {
+		return err
+	}
+	return storage.Delete(file.StorageKey)
+}
+
+// EnsureUpstreamFile 确保文件已上传到当前渠道，返回上游文件 ID
+// 同一渠道同一密钥上传过的文件直接复用记录的上游 ID，多 Key 渠道按实际使用的 Key 区分
+func EnsureUpstreamFile(ctx context.Context, info *relaycommon.RelayInfo, file *model.File) (string, error) {
+	if upstreamId := file.GetUpstreamFileId(info.ChannelId, info.ApiKey); upstreamId != "" {
+		return upstreamId, nil
+	}
+	content, err := OpenFileContent(file)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField("purpose", file.Purpose); err != nil {
+		return "", err
+	}
+	part, err := writer.CreateFormFile("file", file.Filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	url := strings.TrimSuffix(info.ChannelBaseUrl, "/") + "/v1/files"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	if info.Organization != "" {
+		req.Header.Set("OpenAI-Organization", info.Organization)
+	}
+	client, err := GetHttpClientWithProxy(info.ChannelSetting.Proxy)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upstream file upload failed: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+	var uploaded dto.OpenAIFile
+	if err := common.Unmarshal(respBody, &uploaded); err != nil {
+		return "", err
+	}
+	if uploaded.ID == "" {
+		return "", errors.New("upstream file upload returned empty id")
+	}
+	if err := file.SetUpstreamFileId(info.ChannelId, info.ApiKey, uploaded.ID); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("failed to save upstream file id for %s: %v", file.FileId, err))
+	}
+	return uploaded.ID, nil
+}
+
+// ResolveUpstreamFileIds 将请求体中引用的网关文件 ID 替换为当前渠道的上游文件 ID
+// 仅适用于 OpenAI 兼容渠道；未命中网关文件的 ID 保持原样透传
+func ResolveUpstreamFileIds(ctx context.Context, info *relaycommon.RelayInfo, body []byte) ([]byte, error) {
+	if !operation_setting.GetFileSetting().ForwardToUpstream {
+		return body, nil
+	}
+	if info.ApiType != constant.APITypeOpenAI || info.ChannelType == constant.ChannelTypeAzure {
+		return body, nil
+	}
+	if !bytes.Contains(body, []byte(`"file-`)) {
+		return body, nil
+	}
+	matches := fileIdPattern.FindAllSubmatch(body, -1)
+	replaced := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		fileId := string(match[1])
+		if replaced[fileId] {
+			continue
+		}
+		replaced[fileId] = true
+		file, err := model.GetUserFileByFileId(info.UserId, fileId)
+		if err != nil {
+			continue
+		}
+		upstreamId, err := EnsureUpstreamFile(ctx, info, file)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.ReplaceAll(body, []byte(`"`+fileId+`"`), []byte(`"`+upstreamId+`"`))
+	}
+	return body, nil
+}
+
+// StartFileCleanupTask 定期清理过期文件
+func StartFileCleanupTask() {
+	fileCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("file cleanup task started: tick=%s", fileCleanupTickInterval))
+			ticker := time.NewTicker(fileCleanupTickInterval)
+			defer ticker.Stop()
+
+			runFileCleanupOnce()
+			for range ticker.C {
+				runFileCleanupOnce()
+			}
+		})
+	})
+}
+
+func runFileCleanupOnce() {
+	if !fileCleanupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer fileCleanupRunning.Store(false)
+
+	ctx := context.Background()
+	total := 0
+	for {
+		files, err := model.GetExpiredFiles(fileCleanupBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("file cleanup task failed: %v", err))
+			return
+		}
+		deleted := 0
+		for _, file := range files {
+			if err := DeleteFile(file); err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("failed to delete expired file %s: %v", file.FileId, err))
+				continue
+			}
+			deleted++
+		}
+		total += deleted
+		if len(files) < fileCleanupBatchSize || deleted == 0 {
+			break
+		}
+	}
+	if common.DebugEnabled && total > 0 {
+		logger.LogDebug(ctx, "file cleanup: deleted_count=%d", total)
+	}
+}
@@ -0,0 +1,369 @@
+package openaicompat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ChatUsageToResponsesUsage 将 Chat Completions 的用量转换为 Responses 的用量字段
+func ChatUsageToResponsesUsage(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	totalTokens := usage.TotalTokens
+	if totalTokens == 0 {
+		totalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &dto.Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  totalTokens,
+		InputTokensDetails: &dto.InputTokenDetails{
+			CachedTokens: usage.PromptTokensDetails.CachedTokens,
+			ImageTokens:  usage.PromptTokensDetails.ImageTokens,
+			AudioTokens:  usage.PromptTokensDetails.AudioTokens,
+		},
+		CompletionTokenDetails: dto.OutputTokenDetails{
+			ReasoningTokens: usage.CompletionTokenDetails.ReasoningTokens,
+		},
+	}
+}
+
+func responsesID(id string) string {
+	if strings.HasPrefix(id, "resp_") {
+		return id
+	}
+	id = strings.TrimPrefix(id, "chatcmpl-")
+	if id == "" {
+		id = common.GetUUID()
+	}
+	return "resp_" + id
+}
+
+// responsesStatus 根据 finish_reason 返回响应状态与未完成原因
+func responsesStatus(finishReason string) (string, *dto.IncompleteDetails) {
+	switch finishReason {
+	case "length":
+		return "incomplete", &dto.IncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		return "incomplete", &dto.IncompleteDetails{Reason: "content_filter"}
+	}
+	return "completed", nil
+}
+
+func newResponsesMessageItem(id string, status string, text string) dto.ResponsesOutput {
+	item := dto.ResponsesOutput{
+		Type:    "message",
+		ID:      id,
+		Status:  status,
+		Role:    "assistant",
+		Content: []dto.ResponsesOutputContent{},
+	}
+	if status == "completed" {
+		item.Content = append(item.Content, dto.ResponsesOutputContent{
+			Type:        "output_text",
+			Text:        text,
+			Annotations: []interface{}{},
+		})
+	}
+	return item
+}
+
+// newResponsesReasoningItem 构造推理输出项，上游的思考签名放入 encrypted_content，回传时还原
+func newResponsesReasoningItem(id string, text string, signature string) dto.ResponsesOutput {
+	item := dto.ResponsesOutput{
+		Type:             "reasoning",
+		ID:               id,
+		Summary:          []dto.ResponsesReasoningSummaryPart{},
+		EncryptedContent: signature,
+	}
+	if text != "" {
+		item.Summary = append(item.Summary, dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: text})
+	}
+	return item
+}
+
+// ChatCompletionsResponseToResponsesResponse 将 Chat Completions 非流式响应转换为 Responses 响应
+func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	id := responsesID(resp.Id)
+	out := &dto.OpenAIResponsesResponse{
+		ID:        id,
+		Object:    "response",
+		CreatedAt: int(common.GetTimestamp()),
+		Model:     resp.Model,
+		Output:    []dto.ResponsesOutput{},
+		Usage:     ChatUsageToResponsesUsage(usage),
+	}
+	if created, ok := resp.Created.(int64); ok {
+		out.CreatedAt = int(created)
+	}
+	out.Status = "completed"
+	if len(resp.Choices) == 0 {
+		return out
+	}
+
+	choice := resp.Choices[0]
+	out.Status, out.IncompleteDetails = responsesStatus(choice.FinishReason)
+	if reasoning, signature := choice.Message.ReasoningContent, choice.Message.ReasoningSignature; reasoning != "" || signature != "" {
+		out.Output = append(out.Output, newResponsesReasoningItem(fmt.Sprintf("rs_%s_%d", id, len(out.Output)), reasoning, signature))
+	}
+	if text := choice.Message.StringContent(); text != "" {
+		out.Output = append(out.Output, newResponsesMessageItem(fmt.Sprintf("msg_%s_%d", id, len(out.Output)), "completed", text))
+	}
+	for _, toolCall := range choice.Message.ParseToolCalls() {
+		out.Output = append(out.Output, dto.ResponsesOutput{
+			Type:      "function_call",
+			ID:        "fc_" + toolCall.ID,
+			Status:    "completed",
+			CallId:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+	return out
+}
+
+// ResponsesStreamState 将 Chat Completions 流式响应逐块转换为 Responses 流式事件
+// 输出项按顺序依次打开与关闭，同一时刻最多只有一个输出项处于进行中
+type ResponsesStreamState struct {
+	Id        string
+	Model     string
+	CreatedAt int64
+
+	started      bool
+	finished     bool
+	output       []dto.ResponsesOutput
+	current      *dto.ResponsesOutput
+	buffer       strings.Builder
+	finishReason string
+	usage        *dto.Usage
+}
+
+func NewResponsesStreamState(id string, model string, createdAt int64) *ResponsesStreamState {
+	return &ResponsesStreamState{Id: id, Model: model, CreatedAt: createdAt}
+}
+
+func (s *ResponsesStreamState) snapshot(status string) *dto.OpenAIResponsesResponse {
+	resp := &dto.OpenAIResponsesResponse{
+		ID:        s.Id,
+		Object:    "response",
+		CreatedAt: int(s.CreatedAt),
+		Status:    status,
+		Model:     s.Model,
+		Output:    []dto.ResponsesOutput{},
+	}
+	if status != "in_progress" {
+		resp.Output = append(resp.Output, s.output...)
+		resp.Usage = ChatUsageToResponsesUsage(s.usage)
+		_, resp.IncompleteDetails = responsesStatus(s.finishReason)
+	}
+	return resp
+}
+
+func (s *ResponsesStreamState) start(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	s.started = true
+	if chunk != nil {
+		if s.Id == "" {
+			s.Id = chunk.Id
+		}
+		if s.Model == "" {
+			s.Model = chunk.Model
+		}
+	}
+	s.Id = responsesID(s.Id)
+	if s.CreatedAt == 0 {
+		s.CreatedAt = common.GetTimestamp()
+	}
+	return []dto.ResponsesStreamResponse{
+		{Type: "response.created", Response: s.snapshot("in_progress")},
+		{Type: "response.in_progress", Response: s.snapshot("in_progress")},
+	}
+}
+
+func (s *ResponsesStreamState) outputIndex() *int {
+	return common.GetPointer(len(s.output))
+}
+
+func (s *ResponsesStreamState) open(item dto.ResponsesOutput) []dto.ResponsesStreamResponse {
+	events := s.closeCurrent()
+	s.current = &item
+	s.buffer.Reset()
+	added := item
+	events = append(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: s.outputIndex(), Item: &added})
+	switch item.Type {
+	case "message":
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:         "response.content_part.added",
+			ItemID:       item.ID,
+			OutputIndex:  s.outputIndex(),
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "output_text"},
+		})
+	case "reasoning":
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.added",
+			ItemID:       item.ID,
+			OutputIndex:  s.outputIndex(),
+			SummaryIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "summary_text"},
+		})
+	}
+	return events
+}
+
+func (s *ResponsesStreamState) closeCurrent() []dto.ResponsesStreamResponse {
+	if s.current == nil {
+		return nil
+	}
+	item := *s.current
+	text := s.buffer.String()
+	var events []dto.ResponsesStreamResponse
+	switch item.Type {
+	case "message":
+		item = newResponsesMessageItem(item.ID, "completed", text)
+		events = append(events,
+			dto.ResponsesStreamResponse{Type: "response.output_text.done", ItemID: item.ID, OutputIndex: s.outputIndex(), ContentIndex: common.GetPointer(0), Text: text},
+			dto.ResponsesStreamResponse{Type: "response.content_part.done", ItemID: item.ID, OutputIndex: s.outputIndex(), ContentIndex: common.GetPointer(0), Part: &dto.ResponsesReasoningSummaryPart{Type: "output_text", Text: text}},
+		)
+	case "reasoning":
+		item = newResponsesReasoningItem(item.ID, text, item.EncryptedContent)
+		events = append(events,
+			dto.ResponsesStreamResponse{Type: "response.reasoning_summary_text.done", ItemID: item.ID, OutputIndex: s.outputIndex(), SummaryIndex: common.GetPointer(0), Text: text},
+			dto.ResponsesStreamResponse{Type: "response.reasoning_summary_part.done", ItemID: item.ID, OutputIndex: s.outputIndex(), SummaryIndex: common.GetPointer(0), Part: &dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: text}},
+		)
+	case "function_call":
+		item.Status = "completed"
+		item.Arguments = text
+		events = append(events, dto.ResponsesStreamResponse{Type: "response.function_call_arguments.done", ItemID: item.ID, OutputIndex: s.outputIndex(), Arguments: text})
+	}
+	done := item
+	events = append(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: s.outputIndex(), Item: &done})
+	s.output = append(s.output, item)
+	s.current = nil
+	s.buffer.Reset()
+	return events
+}
+
+func (s *ResponsesStreamState) appendReasoning(delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.current == nil || s.current.Type != "reasoning" {
+		events = s.open(newResponsesReasoningItem(fmt.Sprintf("rs_%s_%d", s.Id, len(s.output)), "", ""))
+	}
+	s.buffer.WriteString(delta)
+	return append(events, dto.ResponsesStreamResponse{
+		Type:         "response.reasoning_summary_text.delta",
+		ItemID:       s.current.ID,
+		OutputIndex:  s.outputIndex(),
+		SummaryIndex: common.GetPointer(0),
+		Delta:        delta,
+	})
+}
+
+// setReasoningSignature 将思考签名记录到进行中的推理项，没有时（如只返回签名的 Gemini）新开一个空推理项
+func (s *ResponsesStreamState) setReasoningSignature(signature string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.current == nil || s.current.Type != "reasoning" {
+		events = s.open(newResponsesReasoningItem(fmt.Sprintf("rs_%s_%d", s.Id, len(s.output)), "", ""))
+	}
+	s.current.EncryptedContent = signature
+	return events
+}
+
+func (s *ResponsesStreamState) appendText(delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.current == nil || s.current.Type != "message" {
+		events = s.open(newResponsesMessageItem(fmt.Sprintf("msg_%s_%d", s.Id, len(s.output)), "in_progress", ""))
+	}
+	s.buffer.WriteString(delta)
+	return append(events, dto.ResponsesStreamResponse{
+		Type:         "response.output_text.delta",
+		ItemID:       s.current.ID,
+		OutputIndex:  s.outputIndex(),
+		ContentIndex: common.GetPointer(0),
+		Delta:        delta,
+	})
+}
+
+func (s *ResponsesStreamState) appendToolCall(toolCall dto.ToolCallResponse) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if toolCall.ID != "" && (s.current == nil || s.current.Type != "function_call" || s.current.CallId != toolCall.ID) {
+		events = s.open(dto.ResponsesOutput{
+			Type:   "function_call",
+			ID:     "fc_" + toolCall.ID,
+			Status: "in_progress",
+			CallId: toolCall.ID,
+			Name:   toolCall.Function.Name,
+		})
+	}
+	// 没有调用 ID 的增量只能追加到当前的函数调用上
+	if s.current == nil || s.current.Type != "function_call" || toolCall.Function.Arguments == "" {
+		return events
+	}
+	s.buffer.WriteString(toolCall.Function.Arguments)
+	return append(events, dto.ResponsesStreamResponse{
+		Type:        "response.function_call_arguments.delta",
+		ItemID:      s.current.ID,
+		OutputIndex: s.outputIndex(),
+		Delta:       toolCall.Function.Arguments,
+	})
+}
+
+// Convert 转换一个 Chat Completions 流式块，返回需要发送的 Responses 事件
+func (s *ResponsesStreamState) Convert(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	if s.finished || chunk == nil {
+		return nil
+	}
+	var events []dto.ResponsesStreamResponse
+	if !s.started {
+		events = s.start(chunk)
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			events = append(events, s.appendReasoning(reasoning)...)
+		}
+		if signature := choice.Delta.ReasoningSignature; signature != nil && *signature != "" {
+			events = append(events, s.setReasoningSignature(*signature)...)
+		}
+		if text := choice.Delta.GetContentString(); text != "" {
+			events = append(events, s.appendText(text)...)
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			events = append(events, s.appendToolCall(toolCall)...)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish 关闭进行中的输出项并返回最终的 response.completed 事件，usage 为空时使用流中携带的用量
+func (s *ResponsesStreamState) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	if s.finished {
+		return nil
+	}
+	var events []dto.ResponsesStreamResponse
+	if !s.started {
+		events = s.start(nil)
+	}
+	events = append(events, s.closeCurrent()...)
+	s.finished = true
+	if usage != nil {
+		s.usage = usage
+	}
+	status, _ := responsesStatus(s.finishReason)
+	eventType := "response.completed"
+	if status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	return append(events, dto.ResponsesStreamResponse{Type: eventType, Response: s.snapshot(status)})
+}
@@ -0,0 +1,172 @@
+package openaicompat
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
+
+const defaultCompletionSystemPrompt = "You are a text completion engine. Continue the text in the user message exactly from where it ends. " +
+	"Reply with only the continuation, without repeating the given text, without explanations and without markdown code fences."
+
+const defaultFIMSystemPrompt = "You are a code completion engine. The user message contains the text before the cursor inside <prefix> tags " +
+	"and the text after the cursor inside <suffix> tags. Reply with only the text to insert at the cursor so that prefix + insertion + suffix is coherent, " +
+	"without repeating the prefix or suffix, without explanations and without markdown code fences."
+
+func ShouldCompletionsUseChatPolicy(policy model_setting.CompletionsToChatPolicy, channelID int, channelType int, model string) bool {
+	if !policy.IsChannelEnabled(channelID, channelType) {
+		return false
+	}
+	return len(policy.ModelPatterns) == 0 || matchAnyRegex(policy.ModelPatterns, model)
+}
+
+func ShouldCompletionsUseChatGlobal(channelID int, channelType int, model string) bool {
+	return ShouldCompletionsUseChatPolicy(
+		model_setting.GetGlobalSettings().CompletionsToChatPolicy,
+		channelID,
+		channelType,
+		model,
+	)
+}
+
+// completionsText 解析 completions 的 prompt/suffix，仅支持单条文本（字符串或只含一个字符串的数组）
+func completionsText(value any, field string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		if len(v) == 0 {
+			return "", nil
+		}
+		if len(v) == 1 {
+			if s, ok := v[0].(string); ok {
+				return s, nil
+			}
+		}
+	}
+	return "", errors.New("only a single text " + field + " is supported when converting completions to chat")
+}
+
+// CompletionsPromptText 返回 completions 请求的 prompt 文本，用于 echo
+func CompletionsPromptText(req *dto.GeneralOpenAIRequest) string {
+	prompt, _ := completionsText(req.Prompt, "prompt")
+	return prompt
+}
+
+// CompletionsRequestToChatRequest 将 completions 请求转换为 chat 请求：
+// 普通续写以 prompt 作为用户消息，携带 suffix 时按 FIM（fill-in-the-middle）方式组织 prefix/suffix。
+// echo、logprobs 等 chat 不支持的参数会被移除，由响应转换负责回显 prompt
+func CompletionsRequestToChatRequest(req *dto.GeneralOpenAIRequest, policy model_setting.CompletionsToChatPolicy) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+	prompt, err := completionsText(req.Prompt, "prompt")
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := completionsText(req.Suffix, "suffix")
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt := policy.CompletionSystemPrompt
+	userContent := prompt
+	if suffix != "" {
+		systemPrompt = policy.FIMSystemPrompt
+		if systemPrompt == "" {
+			systemPrompt = defaultFIMSystemPrompt
+		}
+		userContent = "<prefix>" + prompt + "</prefix>\n<suffix>" + suffix + "</suffix>"
+	} else if systemPrompt == "" {
+		systemPrompt = defaultCompletionSystemPrompt
+	}
+
+	chatReq := *req
+	chatReq.Prompt = nil
+	chatReq.Prefix = nil
+	chatReq.Suffix = nil
+	chatReq.Echo = false
+	chatReq.LogProbs = nil
+	chatReq.TopLogProbs = 0
+	chatReq.Messages = []dto.Message{
+		{Role: chatReq.GetSystemRoleName(), Content: systemPrompt},
+		{Role: "user", Content: userContent},
+	}
+	return &chatReq, nil
+}
+
+func completionsFinishReason(reason *string) *string {
+	if reason == nil || *reason == "" {
+		return reason
+	}
+	switch *reason {
+	case "length", "content_filter":
+		return reason
+	}
+	stop := "stop"
+	return &stop
+}
+
+func completionsID(id string) string {
+	id = strings.TrimPrefix(id, "chatcmpl-")
+	if id == "" {
+		id = common.GetUUID()
+	}
+	return "cmpl-" + id
+}
+
+// ChatResponseToCompletionsResponse 将非流式 chat 响应转换为 text_completion 响应，echo 不为空时拼接到文本之前
+func ChatResponseToCompletionsResponse(resp *dto.OpenAITextResponse, echo string) *dto.CompletionsResponse {
+	created, _ := resp.Created.(float64)
+	out := &dto.CompletionsResponse{
+		Id:      completionsID(resp.Id),
+		Object:  "text_completion",
+		Created: int64(created),
+		Model:   resp.Model,
+		Choices: make([]dto.CompletionsChoice, 0, len(resp.Choices)),
+		Usage:   &resp.Usage,
+	}
+	if out.Created == 0 {
+		out.Created = common.GetTimestamp()
+	}
+	for _, choice := range resp.Choices {
+		finishReason := choice.FinishReason
+		out.Choices = append(out.Choices, dto.CompletionsChoice{
+			Text:         echo + choice.Message.StringContent(),
+			Index:        choice.Index,
+			FinishReason: completionsFinishReason(&finishReason),
+		})
+	}
+	return out
+}
+
+// ChatStreamResponseToCompletionsResponse 将 chat 流式分块转换为 text_completion 分块，
+// echoed 记录各候选是否已回显过 prompt，首个分块携带 echo 内容
+func ChatStreamResponseToCompletionsResponse(chunk *dto.ChatCompletionsStreamResponse, echo string, echoed map[int]bool) *dto.CompletionsResponse {
+	out := &dto.CompletionsResponse{
+		Id:      completionsID(chunk.Id),
+		Object:  "text_completion",
+		Created: chunk.Created,
+		Model:   chunk.Model,
+		Choices: make([]dto.CompletionsChoice, 0, len(chunk.Choices)),
+		Usage:   chunk.Usage,
+	}
+	for _, choice := range chunk.Choices {
+		text := choice.Delta.GetContentString()
+		if echo != "" && !echoed[choice.Index] {
+			echoed[choice.Index] = true
+			text = echo + text
+		}
+		out.Choices = append(out.Choices, dto.CompletionsChoice{
+			Text:         text,
+			Index:        choice.Index,
+			FinishReason: completionsFinishReason(choice.FinishReason),
+		})
+	}
+	return out
+}
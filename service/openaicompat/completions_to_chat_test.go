@@ -0,0 +1,70 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionsRequestToChatRequest(t *testing.T) {
+	req := &dto.GeneralOpenAIRequest{}
+	require.NoError(t, common.Unmarshal([]byte(`{"model":"claude-sonnet-4","prompt":["def add(a, b):\n"],"suffix":"\nprint(add(1, 2))","echo":true,"logprobs":3,"max_tokens":64,"stop":["\n\n"]}`), req))
+
+	chatReq, err := CompletionsRequestToChatRequest(req, model_setting.CompletionsToChatPolicy{FIMSystemPrompt: "fim"})
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 2)
+	assert.Equal(t, "fim", chatReq.Messages[0].StringContent())
+	assert.Equal(t, "<prefix>def add(a, b):\n</prefix>\n<suffix>\nprint(add(1, 2))</suffix>", chatReq.Messages[1].StringContent())
+	assert.Nil(t, chatReq.Prompt)
+	assert.Nil(t, chatReq.Suffix)
+	assert.Nil(t, chatReq.LogProbs)
+	assert.False(t, chatReq.Echo)
+	assert.Equal(t, uint(64), chatReq.MaxTokens)
+	assert.Equal(t, "def add(a, b):\n", CompletionsPromptText(req))
+
+	plain, err := CompletionsRequestToChatRequest(&dto.GeneralOpenAIRequest{Prompt: "Once upon"}, model_setting.CompletionsToChatPolicy{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultCompletionSystemPrompt, plain.Messages[0].StringContent())
+	assert.Equal(t, "Once upon", plain.Messages[1].StringContent())
+
+	_, err = CompletionsRequestToChatRequest(&dto.GeneralOpenAIRequest{Prompt: []any{"a", "b"}}, model_setting.CompletionsToChatPolicy{})
+	assert.Error(t, err)
+}
+
+func TestChatResponseToCompletionsResponse(t *testing.T) {
+	resp := &dto.OpenAITextResponse{}
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"claude-sonnet-4","choices":[{"index":0,"message":{"role":"assistant","content":" a time"},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`), resp))
+
+	out := ChatResponseToCompletionsResponse(resp, "Once upon")
+	assert.Equal(t, "cmpl-1", out.Id)
+	assert.Equal(t, "text_completion", out.Object)
+	assert.Equal(t, int64(1700000000), out.Created)
+	require.Len(t, out.Choices, 1)
+	assert.Equal(t, "Once upon a time", out.Choices[0].Text)
+	assert.Equal(t, "stop", *out.Choices[0].FinishReason)
+	assert.Equal(t, 7, out.Usage.TotalTokens)
+}
+
+func TestChatStreamResponseToCompletionsResponse(t *testing.T) {
+	echoed := make(map[int]bool)
+	content := " a"
+	chunk := &dto.ChatCompletionsStreamResponse{
+		Id:      "chatcmpl-2",
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0, Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	}
+
+	out := ChatStreamResponseToCompletionsResponse(chunk, "Once upon", echoed)
+	assert.Equal(t, "Once upon a", out.Choices[0].Text)
+	assert.Nil(t, out.Choices[0].FinishReason)
+
+	length := "length"
+	chunk.Choices[0].FinishReason = &length
+	out = ChatStreamResponseToCompletionsResponse(chunk, "Once upon", echoed)
+	assert.Equal(t, " a", out.Choices[0].Text)
+	assert.Equal(t, "length", *out.Choices[0].FinishReason)
+}
@@ -0,0 +1,132 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesRequestToChatCompletionsRequest(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "claude-sonnet-4",
+		Instructions: json.RawMessage(`"be brief"`),
+		Input: json.RawMessage(`[
+			{"role":"user","content":[{"type":"input_text","text":"weather?"},{"type":"input_image","image_url":"https://example.com/a.png"}]},
+			{"type":"reasoning","summary":[{"type":"summary_text","text":"thinking"}]},
+			{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},
+			{"type":"function_call","call_id":"call_2","name":"get_weather","arguments":"{\"city\":\"Rome\"}"},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+		]`),
+		Tools:      json.RawMessage(`[{"type":"function","name":"get_weather","parameters":{"type":"object"}}]`),
+		ToolChoice: json.RawMessage(`{"type":"function","name":"get_weather"}`),
+		Reasoning:  &dto.Reasoning{Effort: "high"},
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 4)
+	assert.Equal(t, "system", chatReq.Messages[0].Role)
+	assert.Equal(t, "be brief", chatReq.Messages[0].StringContent())
+
+	parts := chatReq.Messages[1].ParseContent()
+	require.Len(t, parts, 2)
+	assert.Equal(t, dto.ContentTypeImageURL, parts[1].Type)
+
+	assistant := chatReq.Messages[2]
+	assert.Equal(t, "assistant", assistant.Role)
+	toolCalls := assistant.ParseToolCalls()
+	require.Len(t, toolCalls, 2)
+	assert.Equal(t, "call_2", toolCalls[1].ID)
+
+	assert.Equal(t, "tool", chatReq.Messages[3].Role)
+	assert.Equal(t, "call_1", chatReq.Messages[3].ToolCallId)
+	assert.Equal(t, "sunny", chatReq.Messages[3].StringContent())
+
+	require.Len(t, chatReq.Tools, 1)
+	assert.Equal(t, "get_weather", chatReq.Tools[0].Function.Name)
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}, chatReq.ToolChoice)
+	assert.Equal(t, "high", chatReq.ReasoningEffort)
+
+	req.PreviousResponseID = "resp_1"
+	_, err = ResponsesRequestToChatCompletionsRequest(req)
+	assert.Error(t, err)
+}
+
+func TestResponsesStreamStateConvertsChatChunks(t *testing.T) {
+	state := NewResponsesStreamState("chatcmpl-1", "claude-sonnet-4", 1700000000)
+	chunk := func(delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason string) *dto.ChatCompletionsStreamResponse {
+		choice := dto.ChatCompletionsStreamResponseChoice{Delta: delta}
+		if finishReason != "" {
+			choice.FinishReason = common.GetPointer(finishReason)
+		}
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+	}
+
+	var events []dto.ResponsesStreamResponse
+	events = append(events, state.Convert(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: common.GetPointer("let me think")}, ""))...)
+	events = append(events, state.Convert(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: common.GetPointer("Hello")}, ""))...)
+	events = append(events, state.Convert(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: common.GetPointer(" world")}, ""))...)
+	events = append(events, state.Convert(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{ID: "call_1", Function: dto.FunctionResponse{Name: "get_weather"}}}}, ""))...)
+	events = append(events, state.Convert(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Function: dto.FunctionResponse{Arguments: `{"city":"Paris"}`}}}}, "tool_calls"))...)
+	events = append(events, state.Finish(&dto.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})...)
+
+	eventTypes := make([]string, 0, len(events))
+	for _, event := range events {
+		eventTypes = append(eventTypes, event.Type)
+	}
+	assert.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.reasoning_summary_part.added",
+		"response.reasoning_summary_text.delta",
+		"response.reasoning_summary_text.done",
+		"response.reasoning_summary_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}, eventTypes)
+
+	completed := events[len(events)-1].Response
+	assert.Equal(t, "resp_1", completed.ID)
+	assert.Equal(t, "completed", completed.Status)
+	require.Len(t, completed.Output, 3)
+	assert.Equal(t, "let me think", completed.Output[0].Summary[0].Text)
+	assert.Equal(t, "Hello world", completed.Output[1].Content[0].Text)
+	assert.Equal(t, `{"city":"Paris"}`, completed.Output[2].Arguments)
+	assert.Equal(t, 2, *events[len(events)-2].OutputIndex)
+	assert.Equal(t, 10, completed.Usage.InputTokens)
+	assert.Equal(t, 5, completed.Usage.OutputTokens)
+	assert.Empty(t, state.Finish(nil))
+}
+
+func TestChatCompletionsResponseToResponsesResponseIncomplete(t *testing.T) {
+	resp := &dto.OpenAITextResponse{
+		Id:    "msg_1",
+		Model: "gemini-2.5-pro",
+		Choices: []dto.OpenAITextResponseChoice{{
+			Message:      dto.Message{Role: "assistant", Content: "partial"},
+			FinishReason: "length",
+		}},
+	}
+	out := ChatCompletionsResponseToResponsesResponse(resp, &dto.Usage{PromptTokens: 3, CompletionTokens: 4})
+	assert.Equal(t, "incomplete", out.Status)
+	assert.Equal(t, "max_output_tokens", out.IncompleteDetails.Reason)
+	require.Len(t, out.Output, 1)
+	assert.Equal(t, "partial", out.Output[0].Content[0].Text)
+	assert.Equal(t, 7, out.Usage.TotalTokens)
+}
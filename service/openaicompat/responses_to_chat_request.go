@@ -0,0 +1,341 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+type responsesInputItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallId    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+	// reasoning
+	Summary          []dto.ResponsesReasoningSummaryPart `json:"summary"`
+	EncryptedContent string                              `json:"encrypted_content"`
+}
+
+type responsesContentPart struct {
+	Type       string          `json:"type"`
+	Text       string          `json:"text"`
+	Refusal    string          `json:"refusal"`
+	ImageUrl   json.RawMessage `json:"image_url"`
+	Detail     string          `json:"detail"`
+	FileId     string          `json:"file_id"`
+	FileData   string          `json:"file_data"`
+	FileUrl    string          `json:"file_url"`
+	Filename   string          `json:"filename"`
+	InputAudio any             `json:"input_audio"`
+}
+
+type responsesFunctionTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parameters  any    `json:"parameters"`
+}
+
+func rawString(raw json.RawMessage) (string, bool) {
+	if common.GetJsonType(raw) != "string" {
+		return "", false
+	}
+	var s string
+	if err := common.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// convertResponsesContentParts 将 Responses 的内容片段转换为 Chat Completions 的内容片段
+func convertResponsesContentParts(raw json.RawMessage) ([]any, error) {
+	var parts []responsesContentPart
+	if err := common.Unmarshal(raw, &parts); err != nil {
+		return nil, fmt.Errorf("invalid content: %w", err)
+	}
+	contents := make([]any, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			contents = append(contents, map[string]any{
+				"type": dto.ContentTypeText,
+				"text": part.Text,
+			})
+		case "refusal":
+			contents = append(contents, map[string]any{
+				"type": dto.ContentTypeText,
+				"text": part.Refusal,
+			})
+		case "input_image":
+			url, ok := rawString(part.ImageUrl)
+			if !ok {
+				var image struct {
+					Url string `json:"url"`
+				}
+				_ = common.Unmarshal(part.ImageUrl, &image)
+				url = image.Url
+			}
+			if url == "" {
+				return nil, errors.New("input_image without image_url is not supported in responses compatibility mode")
+			}
+			imageUrl := map[string]any{"url": url}
+			if part.Detail != "" {
+				imageUrl["detail"] = part.Detail
+			}
+			contents = append(contents, map[string]any{
+				"type":      dto.ContentTypeImageURL,
+				"image_url": imageUrl,
+			})
+		case "input_file":
+			if part.FileId == "" && part.FileData == "" {
+				return nil, errors.New("input_file without file_id or file_data is not supported in responses compatibility mode")
+			}
+			file := map[string]any{}
+			if part.FileId != "" {
+				file["file_id"] = part.FileId
+			} else {
+				file["file_data"] = part.FileData
+				file["filename"] = part.Filename
+			}
+			contents = append(contents, map[string]any{
+				"type": dto.ContentTypeFile,
+				"file": file,
+			})
+		case "input_audio":
+			contents = append(contents, map[string]any{
+				"type":        dto.ContentTypeInputAudio,
+				"input_audio": part.InputAudio,
+			})
+		default:
+			return nil, fmt.Errorf("unsupported content type %q in responses compatibility mode", part.Type)
+		}
+	}
+	return contents, nil
+}
+
+// functionCallOutputToString 将 function_call_output 的输出转换为工具消息的文本内容
+func functionCallOutputToString(raw json.RawMessage) string {
+	if s, ok := rawString(raw); ok {
+		return s
+	}
+	var parts []responsesContentPart
+	if err := common.Unmarshal(raw, &parts); err == nil {
+		var sb strings.Builder
+		for _, part := range parts {
+			sb.WriteString(part.Text)
+		}
+		return sb.String()
+	}
+	return string(raw)
+}
+
+func convertResponsesTextFormat(textRaw json.RawMessage) *dto.ResponseFormat {
+	if len(textRaw) == 0 {
+		return nil
+	}
+	var text struct {
+		Format map[string]any `json:"format"`
+	}
+	if err := common.Unmarshal(textRaw, &text); err != nil || text.Format == nil {
+		return nil
+	}
+	formatType, _ := text.Format["type"].(string)
+	switch formatType {
+	case "json_object":
+		return &dto.ResponseFormat{Type: formatType}
+	case "json_schema":
+		schema := make(map[string]any, len(text.Format))
+		for key, value := range text.Format {
+			if key == "type" {
+				continue
+			}
+			schema[key] = value
+		}
+		schemaRaw, _ := common.Marshal(schema)
+		return &dto.ResponseFormat{Type: formatType, JsonSchema: schemaRaw}
+	}
+	return nil
+}
+
+func convertResponsesToolChoice(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	if s, ok := rawString(raw); ok {
+		return s
+	}
+	var m map[string]any
+	if err := common.Unmarshal(raw, &m); err != nil {
+		return nil
+	}
+	// Responses: {"type":"function","name":"..."}
+	// Chat: {"type":"function","function":{"name":"..."}}
+	if t, _ := m["type"].(string); t == "function" {
+		if name, _ := m["name"].(string); name != "" {
+			return map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": name},
+			}
+		}
+	}
+	return m
+}
+
+func reasoningSummaryText(parts []dto.ResponsesReasoningSummaryPart) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ResponsesRequestToChatCompletionsRequest 将 Responses 请求转换为 Chat Completions 请求，供只支持对话接口的渠道使用
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+	if req.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if req.PreviousResponseID != "" {
+		return nil, errors.New("previous_response_id is not supported in responses compatibility mode")
+	}
+
+	messages := make([]dto.Message, 0)
+	if len(req.Instructions) > 0 {
+		if instructions, ok := rawString(req.Instructions); ok && strings.TrimSpace(instructions) != "" {
+			messages = append(messages, dto.Message{Role: "system", Content: instructions})
+		}
+	}
+
+	if input, ok := rawString(req.Input); ok {
+		messages = append(messages, dto.Message{Role: "user", Content: input})
+	} else if len(req.Input) > 0 {
+		var items []responsesInputItem
+		if err := common.Unmarshal(req.Input, &items); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		// 推理项回填到同一轮的助手消息中，还原思考内容与签名
+		var pendingReasoning *responsesInputItem
+		attachReasoning := func(message *dto.Message) {
+			if pendingReasoning == nil {
+				return
+			}
+			message.ReasoningContent = reasoningSummaryText(pendingReasoning.Summary)
+			message.ReasoningSignature = pendingReasoning.EncryptedContent
+			pendingReasoning = nil
+		}
+		for i := range items {
+			item := items[i]
+			switch item.Type {
+			case "", "message":
+				role := item.Role
+				if role == "developer" {
+					role = "system"
+				}
+				message := dto.Message{Role: role}
+				if role == "assistant" {
+					attachReasoning(&message)
+				}
+				if s, ok := rawString(item.Content); ok {
+					message.Content = s
+				} else if len(item.Content) > 0 {
+					contents, err := convertResponsesContentParts(item.Content)
+					if err != nil {
+						return nil, err
+					}
+					message.Content = contents
+				}
+				messages = append(messages, message)
+			case "function_call":
+				toolCall := dto.ToolCallRequest{
+					ID:   item.CallId,
+					Type: "function",
+					Function: dto.FunctionRequest{
+						Name:      item.Name,
+						Arguments: item.Arguments,
+					},
+				}
+				// 连续的函数调用合并到同一条助手消息中
+				if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" {
+					toolCalls := append(messages[last].ParseToolCalls(), toolCall)
+					messages[last].SetToolCalls(toolCalls)
+					continue
+				}
+				message := dto.Message{Role: "assistant"}
+				attachReasoning(&message)
+				message.SetToolCalls([]dto.ToolCallRequest{toolCall})
+				messages = append(messages, message)
+			case "function_call_output":
+				messages = append(messages, dto.Message{
+					Role:       "tool",
+					Content:    functionCallOutputToString(item.Output),
+					ToolCallId: item.CallId,
+				})
+			case "reasoning":
+				// 没有签名的推理内容上游无法校验，部分渠道还会拒绝回传的推理内容，因此不回传
+				if item.EncryptedContent == "" {
+					continue
+				}
+				pendingReasoning = &item
+				// 函数调用之后才下发的签名属于已生成的助手消息
+				if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" &&
+					messages[last].ReasoningSignature == "" && messages[last].ReasoningContent == "" {
+					attachReasoning(&messages[last])
+				}
+			default:
+				return nil, fmt.Errorf("unsupported input item type %q in responses compatibility mode", item.Type)
+			}
+		}
+	}
+
+	out := &dto.GeneralOpenAIRequest{
+		Model:          req.Model,
+		Messages:       messages,
+		Stream:         req.Stream,
+		MaxTokens:      req.MaxOutputTokens,
+		Temperature:    req.Temperature,
+		ResponseFormat: convertResponsesTextFormat(req.Text),
+		ToolChoice:     convertResponsesToolChoice(req.ToolChoice),
+		User:           req.User,
+	}
+	if req.TopP != nil {
+		out.TopP = *req.TopP
+	}
+	if req.Reasoning != nil {
+		out.ReasoningEffort = req.Reasoning.Effort
+	}
+	if len(req.ParallelToolCalls) > 0 {
+		var parallelToolCalls bool
+		if err := common.Unmarshal(req.ParallelToolCalls, &parallelToolCalls); err == nil {
+			out.ParallelTooCalls = &parallelToolCalls
+		}
+	}
+	if len(req.Tools) > 0 {
+		var tools []responsesFunctionTool
+		if err := common.Unmarshal(req.Tools, &tools); err != nil {
+			return nil, fmt.Errorf("invalid tools: %w", err)
+		}
+		for _, tool := range tools {
+			if tool.Type != "function" {
+				return nil, fmt.Errorf("unsupported tool type %q in responses compatibility mode", tool.Type)
+			}
+			out.Tools = append(out.Tools, dto.ToolCallRequest{
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.Parameters,
+				},
+			})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// ApplyPricingRule 按实际用量匹配模型计价规则，覆盖 PriceData 中的倍率并记录命中的档位，需在结算读取倍率之前调用。
+// promptTokens 为包含缓存在内的全部输入 token；按次计费的模型只受错峰倍率影响
+func ApplyPricingRule(relayInfo *relaycommon.RelayInfo, promptTokens int, completionTokens int) {
+	if relayInfo == nil || relayInfo.PriceData.FreeModel || relayInfo.PriceData.PricingRule != nil {
+		return
+	}
+	result, ok := ratio_setting.EvaluatePricingRule(relayInfo.OriginModelName, relayInfo.UsingGroup, promptTokens, completionTokens, relayInfo.StartTime)
+	if !ok {
+		return
+	}
+	priceData := &relayInfo.PriceData
+	if priceData.UsePrice {
+		// 分段倍率仅适用于按量计费
+		result.Match.InputTier = ""
+		result.Match.OutputTier = ""
+		if result.OffPeakRatio == 1 {
+			return
+		}
+		priceData.ModelPrice *= result.OffPeakRatio
+	} else {
+		if result.ModelRatio > 0 {
+			priceData.ModelRatio = result.ModelRatio
+		}
+		if result.CompletionRatio > 0 {
+			priceData.CompletionRatio = result.CompletionRatio
+		}
+		if result.CacheRatio > 0 {
+			priceData.CacheRatio = result.CacheRatio
+		}
+		priceData.ModelRatio *= result.OffPeakRatio
+	}
+	match := result.Match
+	priceData.PricingRule = &match
+}
+
+// PricingRuleLogContent 生成命中计价规则的日志说明，未命中时返回空
+func PricingRuleLogContent(relayInfo *relaycommon.RelayInfo) string {
+	match := relayInfo.PriceData.PricingRule
+	if match == nil {
+		return ""
+	}
+	parts := make([]string, 0, 4)
+	if match.InputTier != "" {
+		parts = append(parts, "输入档位 "+match.InputTier)
+	}
+	if match.OutputTier != "" {
+		parts = append(parts, "输出档位 "+match.OutputTier)
+	}
+	if match.OffPeakWindow != "" {
+		parts = append(parts, fmt.Sprintf("错峰时段 %s（倍率 %.2f）", match.OffPeakWindow, match.OffPeakRatio))
+	}
+	if match.Group != "" {
+		parts = append(parts, "分组规则 "+match.Group)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "计价规则：" + strings.Join(parts, "，")
+}
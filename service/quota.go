@@ -157,6 +157,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
 
+	ApplyPricingRule(relayInfo, usage.InputTokens, usage.OutputTokens)
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
 	textOutTokens := usage.OutputTokenDetails.TextTokens
@@ -217,8 +218,12 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	if extraContent != "" {
 		logContent += ", " + extraContent
 	}
+	if ruleContent := PricingRuleLogContent(relayInfo); ruleContent != "" {
+		logContent += ", " + ruleContent
+	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	ReportRelayUsage(ctx, relayInfo, logModel, usage.InputTokens, usage.OutputTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -240,6 +245,13 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		ObserveChannelAffinityUsageCacheByRelayFormat(ctx, usage, relayInfo.GetFinalRequestRelayFormat())
 	}
 
+	ruleInputTokens := usage.PromptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		// Anthropic 语义下 input_tokens 不含缓存
+		ruleInputTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	ApplyPricingRule(relayInfo, ruleInputTokens, usage.CompletionTokens)
+
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
@@ -287,8 +299,11 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	} else {
 		calculateQuota = modelPrice * common.QuotaPerUnit * groupRatio
 	}
+	// Batch API 折扣单独相乘，倍率记录在日志 other.batch_ratio 中
+	batchRatio := relayInfo.PriceData.BatchDiscount()
+	calculateQuota *= batchRatio
 
-	if modelRatio != 0 && calculateQuota <= 0 {
+	if modelRatio != 0 && batchRatio != 0 && calculateQuota <= 0 {
 		calculateQuota = 1
 	}
 
@@ -314,12 +329,16 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
 
+	if ruleContent := PricingRuleLogContent(relayInfo); ruleContent != "" {
+		logContent += ", " + ruleContent
+	}
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio,
 		cacheCreationTokens, cacheCreationRatio,
 		cacheCreationTokens5m, cacheCreationRatio5m,
 		cacheCreationTokens1h, cacheCreationRatio1h,
 		modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	ReportRelayUsage(ctx, relayInfo, modelName, promptTokens, completionTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -359,6 +378,7 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	ApplyPricingRule(relayInfo, usage.PromptTokens, usage.CompletionTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -393,6 +413,10 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
+	// Batch API 折扣单独相乘，倍率记录在日志 other.batch_ratio 中
+	if relayInfo.PriceData.HasBatchRatio {
+		quota = int(decimal.NewFromInt(int64(quota)).Mul(decimal.NewFromFloat(relayInfo.PriceData.BatchRatio)).IntPart())
+	}
 
 	totalTokens := usage.TotalTokens
 	var logContent string
@@ -424,8 +448,12 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	if extraContent != "" {
 		logContent += ", " + extraContent
 	}
+	if ruleContent := PricingRuleLogContent(relayInfo); ruleContent != "" {
+		logContent += ", " + ruleContent
+	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	ReportRelayUsage(ctx, relayInfo, logModel, usage.PromptTokens, usage.CompletionTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -459,7 +487,7 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
-	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
+	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota, model.NewEventLedgerRef(model.LedgerReasonConsume, model.LedgerEventPreConsume, relayInfo.RequestId).WithModel(relayInfo.OriginModelName))
 	if err != nil {
 		return err
 	}
@@ -467,25 +495,41 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 }
 
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
+	return postConsumeQuota(relayInfo, quota, preConsumedQuota, sendEmail, model.LedgerReasonConsume)
+}
+
+// postConsumeQuota 按指定账本事由扣费，违规扣费使用单独的事由以便对账单区分
+func postConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool, reason string) (err error) {
+	if relayInfo == nil {
+		return errors.New("relay info is nil")
+	}
+	// 同一请求可能多次调用（如实时音频），按调用序号区分记账事件
+	relayInfo.PostConsumeSeq++
+	ref := model.NewEventLedgerRef(reason,
+		fmt.Sprintf("%s_%d", model.LedgerEventPostConsume, relayInfo.PostConsumeSeq), relayInfo.RequestId).WithModel(relayInfo.OriginModelName)
 
-	// 1) Consume from wallet quota OR subscription item
-	if relayInfo != nil && relayInfo.BillingSource == BillingSourceSubscription {
+	// 1) Consume from wallet quota, subscription item OR organization wallet
+	if relayInfo.BillingSource == BillingSourceSubscription {
 		if relayInfo.SubscriptionId == 0 {
 			return errors.New("subscription id is missing")
 		}
 		delta := int64(quota)
 		if delta != 0 {
-			if err := model.PostConsumeUserSubscriptionDelta(relayInfo.SubscriptionId, delta); err != nil {
+			if err := model.PostConsumeUserSubscriptionDelta(relayInfo.SubscriptionId, delta, ref); err != nil {
 				return err
 			}
 			relayInfo.SubscriptionPostDelta += delta
 		}
+	} else if relayInfo.BillingSource == BillingSourceOrg {
+		if err := model.PostConsumeOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, quota, ref); err != nil {
+			return err
+		}
 	} else {
 		// Wallet
 		if quota > 0 {
-			err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+			err = model.DecreaseUserQuota(relayInfo.UserId, quota, ref)
 		} else {
-			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false, ref)
 		}
 		if err != nil {
 			return err
@@ -494,9 +538,9 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 
 	if !relayInfo.IsPlayground {
 		if quota > 0 {
-			err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
+			err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota, ref)
 		} else {
-			err = model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, -quota)
+			err = model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, -quota, ref)
 		}
 		if err != nil {
 			return err
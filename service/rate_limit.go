@@ -0,0 +1,52 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/ratelimit"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AcquireRelayRateLimit 按用户与令牌的 RPM、TPM 与并发限制占用额度，TPM 按预估 Token 数预扣
+// 超限时写入 x-ratelimit-* 与 Retry-After 响应头并返回 429
+func AcquireRelayRateLimit(c *gin.Context, estimatedTokens int) *types.NewAPIError {
+	subjects := make([]ratelimit.Subject, 0, 2)
+	if limits, ok := common.GetContextKeyType[ratelimit.Limits](c, constant.ContextKeyUserRateLimit); ok && !limits.Empty() {
+		subjects = append(subjects, ratelimit.Subject{Scope: ratelimit.ScopeUser, Id: c.GetInt("id"), Limits: limits})
+	}
+	if limits, ok := common.GetContextKeyType[ratelimit.Limits](c, constant.ContextKeyTokenRateLimit); ok && !limits.Empty() {
+		subjects = append(subjects, ratelimit.Subject{Scope: ratelimit.ScopeToken, Id: c.GetInt("token_id"), Limits: limits})
+	}
+	if len(subjects) == 0 {
+		return nil
+	}
+	lease, rejection := ratelimit.Acquire(subjects, estimatedTokens)
+	lease.WriteHeaders(c.Writer.Header())
+	if rejection != nil {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rejection.RetryAfter.Seconds()))))
+		return types.NewOpenAIError(rejection, types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests,
+			types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	common.SetContextKey(c, constant.ContextKeyRelayRateLimit, lease)
+	return nil
+}
+
+// ReconcileRelayRateLimit 结算时按实际消耗的 Token 数调整令牌与用户的 TPM
+func ReconcileRelayRateLimit(c *gin.Context, tokens int) {
+	if lease, ok := common.GetContextKeyType[*ratelimit.Lease](c, constant.ContextKeyRelayRateLimit); ok {
+		lease.Reconcile(tokens)
+	}
+}
+
+// ReleaseRelayRateLimit 请求结束时释放并发占用，未计费的请求退回预扣的 TPM
+func ReleaseRelayRateLimit(c *gin.Context) {
+	if lease, ok := common.GetContextKeyType[*ratelimit.Lease](c, constant.ContextKeyRelayRateLimit); ok {
+		lease.Release()
+	}
+}
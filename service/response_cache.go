@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+)
+
+const responseCacheNamespace = "new-api:response_cache:v1"
+
+// ResponseCacheEntry 缓存的上游响应
+type ResponseCacheEntry struct {
+	IsStream    bool      `json:"is_stream"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        string    `json:"body,omitempty"`   // 非流式响应正文
+	Events      []string  `json:"events,omitempty"` // 流式响应的 SSE data 内容，按顺序回放
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+}
+
+var (
+	responseCacheOnce sync.Once
+	responseCache     *cachex.HybridCache[ResponseCacheEntry]
+)
+
+func getResponseCache() *cachex.HybridCache[ResponseCacheEntry] {
+	responseCacheOnce.Do(func() {
+		capacity := common.GetEnvOrDefault("RESPONSE_CACHE_CAP", 10000)
+		if capacity <= 0 {
+			capacity = 10000
+		}
+		responseCache = cachex.NewHybridCache[ResponseCacheEntry](cachex.HybridCacheConfig[ResponseCacheEntry]{
+			Namespace: cachex.Namespace(responseCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[ResponseCacheEntry]{},
+			Memory: func() *hot.HotCache[string, ResponseCacheEntry] {
+				return hot.NewHotCache[string, ResponseCacheEntry](hot.LRU, capacity).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return responseCache
+}
+
+// ResponseCacheKey 按 分组 + 模型 + 请求路径 + 规范化后的请求体 生成缓存键
+// 请求体重新序列化后字段顺序与空白不影响命中
+func ResponseCacheKey(group string, modelName string, path string, body []byte) (string, error) {
+	var payload any
+	if err := common.Unmarshal(body, &payload); err != nil {
+		return "", err
+	}
+	normalized, err := common.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(normalized)
+	return group + ":" + modelName + ":" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func GetResponseCache(key string) (*ResponseCacheEntry, bool) {
+	entry, found, err := getResponseCache().Get(key)
+	if err != nil {
+		common.SysError("response cache get failed: " + err.Error())
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry, ttl time.Duration) {
+	if err := getResponseCache().SetWithTTL(key, *entry, ttl); err != nil {
+		common.SysError("response cache set failed: " + err.Error())
+	}
+}
+
+// PurgeResponseCache 清空全部响应缓存
+func PurgeResponseCache() error {
+	PurgeSemanticResponseCache()
+	return getResponseCache().Purge()
+}
+
+// ResponseCaptureWriter 在写出响应的同时记录正文，用于写入响应缓存
+type ResponseCaptureWriter struct {
+	gin.ResponseWriter
+	Key string
+	TTL time.Duration
+	// 语义缓存的范围与请求向量，写入缓存时同时记录到向量索引
+	SemanticScope  string
+	SemanticVector []float64
+	buf            bytes.Buffer
+	maxBytes       int
+	overflow       bool
+}
+
+// StartResponseCapture 替换 c.Writer 以记录响应正文
+func StartResponseCapture(c *gin.Context, key string, ttl time.Duration) *ResponseCaptureWriter {
+	writer := &ResponseCaptureWriter{
+		ResponseWriter: c.Writer,
+		Key:            key,
+		TTL:            ttl,
+		maxBytes:       operation_setting.GetResponseCacheSetting().MaxBodyBytes,
+	}
+	c.Writer = writer
+	common.SetContextKey(c, constant.ContextKeyResponseCapture, writer)
+	return writer
+}
+
+// GetResponseCapture 获取当前请求的响应记录器
+func GetResponseCapture(c *gin.Context) *ResponseCaptureWriter {
+	value, ok := common.GetContextKey(c, constant.ContextKeyResponseCapture)
+	if !ok {
+		return nil
+	}
+	writer, _ := value.(*ResponseCaptureWriter)
+	return writer
+}
+
+func (w *ResponseCaptureWriter) capture(n int, data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.maxBytes > 0 && w.buf.Len()+n > w.maxBytes {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(data[:n])
+}
+
+func (w *ResponseCaptureWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.capture(n, data)
+	return n, err
+}
+
+func (w *ResponseCaptureWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.capture(n, []byte(s))
+	return n, err
+}
+
+// Reset 丢弃之前尝试写入的内容，每次重试前调用
+func (w *ResponseCaptureWriter) Reset() {
+	w.buf.Reset()
+	w.overflow = false
+}
+
+// Entry 根据已写出的响应生成缓存条目，响应不完整或不可缓存时返回 false
+func (w *ResponseCaptureWriter) Entry(isStream bool, usage *dto.Usage) (*ResponseCacheEntry, bool) {
+	if w.overflow || w.buf.Len() == 0 || usage == nil || w.Status() != 200 {
+		return nil, false
+	}
+	entry := &ResponseCacheEntry{
+		IsStream:    isStream,
+		ContentType: w.Header().Get("Content-Type"),
+		Usage:       *usage,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	if !isStream {
+		entry.Body = w.buf.String()
+		return entry, true
+	}
+	entry.Events = ParseSSEDataEvents(w.buf.String())
+	if len(entry.Events) == 0 || entry.Events[len(entry.Events)-1] != "[DONE]" {
+		// 流未正常结束
+		return nil, false
+	}
+	return entry, true
+}
+
+// ParseSSEDataEvents 提取 SSE 中的 data 内容，忽略注释（如 PING）与其他字段
+func ParseSSEDataEvents(raw string) []string {
+	events := make([]string, 0)
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		events = append(events, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+	}
+	return events
+}
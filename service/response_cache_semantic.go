@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 语义缓存：chat 请求精确匹配未命中时，按消息内容的 embedding 在 分组 + 模型 + 其他请求参数 相同的范围内
+// 查找余弦相似度达到阈值的已缓存请求，命中后复用该请求的缓存响应。
+// 向量索引只保存在本进程内存中，指向的响应仍存放在响应缓存（可为 Redis）中，响应过期后索引项随之失效
+
+type semanticCacheItem struct {
+	key       string // 对应的精确匹配缓存键
+	vector    []float64
+	norm      float64
+	expiresAt int64
+}
+
+var (
+	semanticCacheLock    sync.Mutex
+	semanticCacheBuckets = make(map[string][]semanticCacheItem)
+)
+
+// SemanticCacheScope 解析 chat 请求，返回语义缓存的范围与用于计算 embedding 的消息文本。
+// 范围由 分组 + 模型 + 请求路径 + 去掉 messages 后规范化的请求体 组成，消息包含非文本内容时不使用语义缓存
+func SemanticCacheScope(group string, modelName string, path string, body []byte) (string, string, bool) {
+	var payload map[string]any
+	if err := common.Unmarshal(body, &payload); err != nil {
+		return "", "", false
+	}
+	messages, ok := payload["messages"].([]any)
+	if !ok || len(messages) == 0 {
+		return "", "", false
+	}
+	var text strings.Builder
+	for _, item := range messages {
+		message, ok := item.(map[string]any)
+		if !ok {
+			return "", "", false
+		}
+		role, _ := message["role"].(string)
+		content, ok := semanticMessageText(message["content"])
+		if !ok {
+			return "", "", false
+		}
+		text.WriteString(role)
+		text.WriteString(": ")
+		text.WriteString(content)
+		text.WriteString("\n")
+	}
+	delete(payload, "messages")
+	params, err := common.Marshal(payload)
+	if err != nil {
+		return "", "", false
+	}
+	hash := sha256.New()
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(params)
+	return group + ":" + modelName + ":" + hex.EncodeToString(hash.Sum(nil)), text.String(), true
+}
+
+// semanticMessageText 提取消息的纯文本内容，content 包含图片等非文本内容时返回 false
+func semanticMessageText(content any) (string, bool) {
+	switch value := content.(type) {
+	case string:
+		return value, true
+	case []any:
+		parts := make([]string, 0, len(value))
+		for _, item := range value {
+			part, ok := item.(map[string]any)
+			if !ok || part["type"] != "text" {
+				return "", false
+			}
+			text, _ := part["text"].(string)
+			parts = append(parts, text)
+		}
+		return strings.Join(parts, "\n"), true
+	default:
+		return "", false
+	}
+}
+
+type semanticEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// FetchSemanticEmbedding 调用配置的 OpenAI 兼容 embeddings 接口计算文本向量
+func FetchSemanticEmbedding(ctx context.Context, text string) ([]float64, error) {
+	setting := operation_setting.GetResponseCacheSetting()
+	timeout := time.Duration(setting.EmbeddingTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload, err := common.Marshal(map[string]any{
+		"model": setting.EmbeddingModel,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSuffix(setting.EmbeddingBaseURL, "/") + "/v1/embeddings"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if setting.EmbeddingAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+setting.EmbeddingAPIKey)
+	}
+	client := GetHttpClient()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result semanticEmbeddingResponse
+	if err := common.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("embedding response status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || len(result.Data) == 0 || len(result.Data[0].Embedding) == 0 {
+		message := ""
+		if result.Error != nil {
+			message = result.Error.Message
+		}
+		return nil, fmt.Errorf("embedding request failed: status %d %s", resp.StatusCode, message)
+	}
+	return result.Data[0].Embedding, nil
+}
+
+func vectorNorm(vector []float64) float64 {
+	sum := 0.0
+	for _, v := range vector {
+		sum += v * v
+	}
+	return math.Sqrt(sum)
+}
+
+// FindSemanticResponseCache 在范围内查找与向量最相似且达到阈值的缓存键
+func FindSemanticResponseCache(scope string, vector []float64) (string, float64, bool) {
+	norm := vectorNorm(vector)
+	if norm == 0 {
+		return "", 0, false
+	}
+	threshold := operation_setting.GetResponseCacheSetting().SemanticThreshold
+	now := common.GetTimestamp()
+
+	semanticCacheLock.Lock()
+	defer semanticCacheLock.Unlock()
+	items := semanticCacheBuckets[scope]
+	bestKey := ""
+	best := 0.0
+	alive := items[:0]
+	for _, item := range items {
+		if item.expiresAt <= now {
+			continue
+		}
+		alive = append(alive, item)
+		if len(item.vector) != len(vector) {
+			continue
+		}
+		dot := 0.0
+		for i, v := range vector {
+			dot += v * item.vector[i]
+		}
+		if similarity := dot / (norm * item.norm); similarity > best {
+			best = similarity
+			bestKey = item.key
+		}
+	}
+	if len(alive) == 0 {
+		delete(semanticCacheBuckets, scope)
+	} else {
+		semanticCacheBuckets[scope] = alive
+	}
+	if bestKey == "" || best < threshold {
+		return "", best, false
+	}
+	return bestKey, best, true
+}
+
+// AddSemanticResponseCache 记录已缓存请求的向量，超过数量上限时淘汰最早的记录
+func AddSemanticResponseCache(scope string, vector []float64, key string, ttl time.Duration) {
+	norm := vectorNorm(vector)
+	if norm == 0 {
+		return
+	}
+	maxEntries := operation_setting.GetResponseCacheSetting().SemanticMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	item := semanticCacheItem{key: key, vector: vector, norm: norm, expiresAt: common.GetTimestamp() + int64(ttl.Seconds())}
+
+	semanticCacheLock.Lock()
+	defer semanticCacheLock.Unlock()
+	items := semanticCacheBuckets[scope]
+	for i := range items {
+		if items[i].key == key {
+			items[i] = item
+			return
+		}
+	}
+	items = append(items, item)
+	if len(items) > maxEntries {
+		items = items[len(items)-maxEntries:]
+	}
+	semanticCacheBuckets[scope] = items
+}
+
+// PurgeSemanticResponseCache 清空语义缓存的向量索引
+func PurgeSemanticResponseCache() {
+	semanticCacheLock.Lock()
+	defer semanticCacheLock.Unlock()
+	semanticCacheBuckets = make(map[string][]semanticCacheItem)
+}
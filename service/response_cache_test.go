@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCacheKeyIgnoresFieldOrderAndWhitespace(t *testing.T) {
+	a, err := ResponseCacheKey("default", "gpt-4o", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	require.NoError(t, err)
+	b, err := ResponseCacheKey("default", "gpt-4o", "/v1/chat/completions", []byte("{\n  \"messages\": [{\"content\": \"hi\", \"role\": \"user\"}],\n  \"temperature\": 0.0,\n  \"model\": \"gpt-4o\"\n}"))
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+
+	otherGroup, err := ResponseCacheKey("vip", "gpt-4o", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	require.NoError(t, err)
+	require.NotEqual(t, a, otherGroup)
+
+	_, err = ResponseCacheKey("default", "gpt-4o", "/v1/chat/completions", []byte(`not json`))
+	require.Error(t, err)
+}
+
+func TestParseSSEDataEventsSkipsComments(t *testing.T) {
+	raw := "data: {\"id\":1}\r\n\r\n: PING\n\ndata: {\"id\":2}\n\ndata: [DONE]\n\n"
+	require.Equal(t, []string{`{"id":1}`, `{"id":2}`, "[DONE]"}, ParseSSEDataEvents(raw))
+}
+
+func TestResponseCaptureWriterEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	capture := StartResponseCapture(c, "key", 0)
+	require.Same(t, capture, GetResponseCapture(c))
+
+	_, _ = c.Writer.WriteString("data: {\"id\":1}\n\n")
+	_, incomplete := capture.Entry(true, &dto.Usage{TotalTokens: 1})
+	require.False(t, incomplete)
+
+	_, _ = c.Writer.WriteString("data: [DONE]\n\n")
+	entry, ok := capture.Entry(true, &dto.Usage{TotalTokens: 1})
+	require.True(t, ok)
+	require.Equal(t, []string{`{"id":1}`, "[DONE]"}, entry.Events)
+	require.Equal(t, "data: {\"id\":1}\n\ndata: [DONE]\n\n", recorder.Body.String())
+
+	_, ok = capture.Entry(true, nil)
+	require.False(t, ok)
+}
+
+func TestSemanticCacheScopeSeparatesParameters(t *testing.T) {
+	scope, text, ok := SemanticCacheScope("default", "gpt-4o", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	require.True(t, ok)
+	require.Equal(t, "user: hi\n", text)
+
+	sameParams, _, ok := SemanticCacheScope("default", "gpt-4o", "/v1/chat/completions", []byte(`{"temperature":0,"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"hello"}]}]}`))
+	require.True(t, ok)
+	require.Equal(t, scope, sameParams)
+
+	otherParams, _, ok := SemanticCacheScope("default", "gpt-4o", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0,"max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+	require.True(t, ok)
+	require.NotEqual(t, scope, otherParams)
+
+	_, _, ok = SemanticCacheScope("default", "gpt-4o", "/v1/chat/completions", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"x"}}]}]}`))
+	require.False(t, ok, "non-text content is not matched semantically")
+}
+
+func TestSemanticResponseCacheThreshold(t *testing.T) {
+	setting := operation_setting.GetResponseCacheSetting()
+	saved := *setting
+	setting.SemanticThreshold = 0.95
+	t.Cleanup(func() {
+		*setting = saved
+		PurgeSemanticResponseCache()
+	})
+
+	AddSemanticResponseCache("scope", []float64{1, 0, 0}, "cached", time.Minute)
+
+	key, similarity, ok := FindSemanticResponseCache("scope", []float64{0.99, 0.1, 0})
+	require.True(t, ok)
+	require.Equal(t, "cached", key)
+	require.Greater(t, similarity, 0.95)
+
+	_, _, ok = FindSemanticResponseCache("scope", []float64{0.7, 0.7, 0})
+	require.False(t, ok, "below threshold")
+	_, _, ok = FindSemanticResponseCache("other", []float64{1, 0, 0})
+	require.False(t, ok, "different scope")
+}
+
+func TestFetchSemanticEmbedding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/embeddings", r.URL.Path)
+		require.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"data":[{"embedding":[0.1,0.2]}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	setting := operation_setting.GetResponseCacheSetting()
+	saved := *setting
+	setting.EmbeddingBaseURL = server.URL + "/"
+	setting.EmbeddingAPIKey = "sk-test"
+	t.Cleanup(func() { *setting = saved })
+
+	vector, err := FetchSemanticEmbedding(context.Background(), "user: hi")
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.1, 0.2}, vector)
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	statementTickInterval = 1 * time.Hour
+	// statementCloseDelay 月末之后等待批量更新写完账本再结账
+	statementCloseDelay = 10 * time.Minute
+)
+
+var (
+	statementTaskOnce    sync.Once
+	statementTaskRunning atomic.Bool
+)
+
+func statementTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).Format("2006-01-02 15:04:05")
+}
+
+// statementUSD 将额度换算为美元金额，CSV 与 PDF 统一使用美元以便财务核对
+func statementUSD(quota int) string {
+	return strconv.FormatFloat(float64(quota)/common.QuotaPerUnit, 'f', 6, 64)
+}
+
+func statementMoney(money float64) string {
+	return strconv.FormatFloat(money, 'f', 2, 64)
+}
+
+// StatementFileName 返回对账单下载文件名
+func StatementFileName(data *model.StatementData, ext string) string {
+	if data.TokenId > 0 {
+		return fmt.Sprintf("statement-%s-user%d-token%d.%s", data.Period, data.UserId, data.TokenId, ext)
+	}
+	return fmt.Sprintf("statement-%s-user%d.%s", data.Period, data.UserId, ext)
+}
+
+// RenderStatementCSV 以单表形式输出对账单，section 列区分充值、兑换、订阅、模型消费与汇总
+func RenderStatementCSV(data *model.StatementData) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{
+		{"section", "reference", "description", "time", "count", "prompt_tokens", "completion_tokens", "quota", "amount_usd", "money"},
+	}
+	for _, item := range data.TopUps {
+		rows = append(rows, []string{"top_up", item.TradeNo, item.PaymentMethod, statementTime(item.CompleteTime),
+			"", "", "", "", strconv.FormatInt(item.Amount, 10), statementMoney(item.Money)})
+	}
+	for _, item := range data.Redemptions {
+		rows = append(rows, []string{"redemption", strconv.Itoa(item.Id), item.Name, statementTime(item.RedeemedTime),
+			"", "", "", strconv.Itoa(item.Quota), statementUSD(item.Quota), ""})
+	}
+	for _, item := range data.Subscriptions {
+		rows = append(rows, []string{"subscription", item.TradeNo, item.PlanTitle, statementTime(item.CompleteTime),
+			"", "", "", "", "", statementMoney(item.Money)})
+	}
+	for _, item := range data.Consumption {
+		rows = append(rows, []string{"consumption", item.ModelName, "", "",
+			strconv.Itoa(item.Count), strconv.Itoa(item.PromptTokens), strconv.Itoa(item.CompletionTokens),
+			strconv.Itoa(item.Quota), statementUSD(item.Quota), ""})
+	}
+	s := data.Summary
+	rows = append(rows,
+		[]string{"refund", "", "", "", strconv.Itoa(s.RefundCount), "", "", strconv.Itoa(s.RefundQuota), statementUSD(s.RefundQuota), ""},
+		[]string{"violation_fee", "", "", "", strconv.Itoa(s.ViolationFeeCount), "", "", strconv.Itoa(s.ViolationFeeQuota), statementUSD(s.ViolationFeeQuota), ""},
+		[]string{"total_consumption", "", "", "", strconv.Itoa(s.RequestCount), strconv.Itoa(s.PromptTokens), strconv.Itoa(s.CompletionTokens),
+			strconv.Itoa(s.NetConsumeQuota), statementUSD(s.NetConsumeQuota), ""},
+		[]string{"total_top_up", "", "", "", strconv.Itoa(len(data.TopUps)), "", "", "", strconv.FormatInt(s.TopUpAmount, 10), statementMoney(s.TopUpMoney)},
+		[]string{"total_redemption", "", "", "", strconv.Itoa(len(data.Redemptions)), "", "", strconv.Itoa(s.RedemptionQuota), statementUSD(s.RedemptionQuota), ""},
+		[]string{"total_subscription", "", "", "", strconv.Itoa(len(data.Subscriptions)), "", "", "", "", statementMoney(s.SubscriptionMoney)},
+	)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func statementHeaderLines(data *model.StatementData) []string {
+	lines := []string{
+		fmt.Sprintf("%s - Statement %s", common.SystemName, data.Period),
+		"",
+		fmt.Sprintf("User:      #%d %s", data.UserId, data.Username),
+	}
+	if data.TokenId > 0 {
+		lines = append(lines, fmt.Sprintf("Token:     #%d %s", data.TokenId, data.TokenName))
+	}
+	status := "OPEN (preview, figures may still change)"
+	if data.Closed {
+		status = "CLOSED"
+	}
+	lines = append(lines,
+		fmt.Sprintf("Period:    %s - %s", statementTime(data.PeriodStart), statementTime(data.PeriodEnd)),
+		fmt.Sprintf("Status:    %s", status),
+		fmt.Sprintf("Generated: %s", statementTime(data.GeneratedAt)),
+	)
+	if data.Checksum != "" {
+		lines = append(lines, fmt.Sprintf("Checksum:  %s", data.Checksum))
+	}
+	return lines
+}
+
+// RenderStatementPDF 以等宽文本排版输出对账单 PDF
+func RenderStatementPDF(data *model.StatementData) []byte {
+	lines := statementHeaderLines(data)
+	section := func(title string) {
+		lines = append(lines, "", title, strings.Repeat("-", 104))
+	}
+	if data.TokenId == 0 {
+		section("Top-ups")
+		lines = append(lines, fmt.Sprintf("%-19s  %-36s  %-16s  %12s  %12s", "Time", "Trade No", "Method", "Amount", "Paid"))
+		for _, item := range data.TopUps {
+			lines = append(lines, fmt.Sprintf("%-19s  %-36.36s  %-16.16s  %12d  %12s",
+				statementTime(item.CompleteTime), item.TradeNo, item.PaymentMethod, item.Amount, statementMoney(item.Money)))
+		}
+		section("Redemptions")
+		lines = append(lines, fmt.Sprintf("%-19s  %-8s  %-36s  %14s  %16s", "Time", "ID", "Name", "Quota", "Amount (USD)"))
+		for _, item := range data.Redemptions {
+			lines = append(lines, fmt.Sprintf("%-19s  %-8d  %-36.36s  %14d  %16s",
+				statementTime(item.RedeemedTime), item.Id, item.Name, item.Quota, statementUSD(item.Quota)))
+		}
+		section("Subscription orders")
+		lines = append(lines, fmt.Sprintf("%-19s  %-36s  %-28s  %12s", "Time", "Trade No", "Plan", "Paid"))
+		for _, item := range data.Subscriptions {
+			lines = append(lines, fmt.Sprintf("%-19s  %-36.36s  %-28.28s  %12s",
+				statementTime(item.CompleteTime), item.TradeNo, item.PlanTitle, statementMoney(item.Money)))
+		}
+	}
+	section("Consumption by model")
+	lines = append(lines, fmt.Sprintf("%-36s  %8s  %14s  %14s  %12s  %12s", "Model", "Requests", "Prompt tokens", "Output tokens", "Quota", "USD"))
+	for _, item := range data.Consumption {
+		lines = append(lines, fmt.Sprintf("%-36.36s  %8d  %14d  %14d  %12d  %12s",
+			item.ModelName, item.Count, item.PromptTokens, item.CompletionTokens, item.Quota, statementUSD(item.Quota)))
+	}
+
+	s := data.Summary
+	section("Summary")
+	summary := [][2]string{
+		{"Consumption", fmt.Sprintf("%d requests, %d quota (%s USD)", s.RequestCount, s.ConsumeQuota, statementUSD(s.ConsumeQuota))},
+		{"Refunds", fmt.Sprintf("%d records, -%d quota (%s USD)", s.RefundCount, s.RefundQuota, statementUSD(s.RefundQuota))},
+		{"Violation fees", fmt.Sprintf("%d records, %d quota (%s USD)", s.ViolationFeeCount, s.ViolationFeeQuota, statementUSD(s.ViolationFeeQuota))},
+		{"Net consumption", fmt.Sprintf("%d quota (%s USD)", s.NetConsumeQuota, statementUSD(s.NetConsumeQuota))},
+	}
+	if data.TokenId == 0 {
+		summary = append(summary,
+			[2]string{"Top-ups", fmt.Sprintf("%d records, amount %d, paid %s", len(data.TopUps), s.TopUpAmount, statementMoney(s.TopUpMoney))},
+			[2]string{"Redemptions", fmt.Sprintf("%d records, %d quota (%s USD)", len(data.Redemptions), s.RedemptionQuota, statementUSD(s.RedemptionQuota))},
+			[2]string{"Subscriptions", fmt.Sprintf("%d orders, paid %s", len(data.Subscriptions), statementMoney(s.SubscriptionMoney))},
+		)
+	}
+	for _, row := range summary {
+		lines = append(lines, fmt.Sprintf("%-18s %s", row[0]+":", row[1]))
+	}
+	return renderTextPDF(lines)
+}
+
+// renderStatementEmail 生成对账单邮件正文（HTML）
+func renderStatementEmail(data *model.StatementData) string {
+	s := data.Summary
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>您好，%s：</p><p>以下是您 %s 的对账单摘要，完整明细可在控制台下载 CSV 或 PDF。</p>",
+		html.EscapeString(data.Username), data.Period)
+	b.WriteString("<table border='1' cellpadding='6' cellspacing='0' style='border-collapse:collapse'>")
+	rows := [][2]string{
+		{"充值", fmt.Sprintf("%d 笔，实付 %s", len(data.TopUps), statementMoney(s.TopUpMoney))},
+		{"兑换码", fmt.Sprintf("%d 笔，%s", len(data.Redemptions), logger.FormatQuota(s.RedemptionQuota))},
+		{"订阅订单", fmt.Sprintf("%d 笔，实付 %s", len(data.Subscriptions), statementMoney(s.SubscriptionMoney))},
+		{"模型消费", fmt.Sprintf("%d 次请求，%s", s.RequestCount, logger.FormatQuota(s.ConsumeQuota))},
+		{"退款", fmt.Sprintf("%d 笔，%s", s.RefundCount, logger.FormatQuota(s.RefundQuota))},
+		{"违规扣费", fmt.Sprintf("%d 笔，%s", s.ViolationFeeCount, logger.FormatQuota(s.ViolationFeeQuota))},
+		{"净消费", logger.FormatQuota(s.NetConsumeQuota)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td></tr>", row[0], html.EscapeString(row[1]))
+	}
+	b.WriteString("</table>")
+	if len(data.Consumption) > 0 {
+		b.WriteString("<p>按模型统计：</p><table border='1' cellpadding='6' cellspacing='0' style='border-collapse:collapse'>")
+		b.WriteString("<tr><th>模型</th><th>请求次数</th><th>输入 Tokens</th><th>输出 Tokens</th><th>消费</th></tr>")
+		for _, item := range data.Consumption {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%s</td></tr>",
+				html.EscapeString(item.ModelName), item.Count, item.PromptTokens, item.CompletionTokens, logger.FormatQuota(item.Quota))
+		}
+		b.WriteString("</table>")
+	}
+	if data.Checksum != "" {
+		fmt.Fprintf(&b, "<p style='color:#888'>校验值：%s</p>", data.Checksum)
+	}
+	return b.String()
+}
+
+// SendStatementEmail 发送对账单邮件
+func SendStatementEmail(email string, data *model.StatementData) error {
+	if email == "" {
+		return fmt.Errorf("user email is empty")
+	}
+	subject := fmt.Sprintf("%s 对账单 %s", common.SystemName, data.Period)
+	return common.SendEmail(subject, email, renderStatementEmail(data))
+}
+
+// StartStatementTask 每月初为上月有账本变动的用户与令牌结账，并向开启对账单邮件的用户发送上月对账单
+func StartStatementTask() {
+	statementTaskOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("statement task started: tick=%s", statementTickInterval))
+			ticker := time.NewTicker(statementTickInterval)
+			defer ticker.Stop()
+
+			runStatementTaskOnce()
+			for range ticker.C {
+				runStatementTaskOnce()
+			}
+		})
+	})
+}
+
+func runStatementTaskOnce() {
+	if !statementTaskRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer statementTaskRunning.Store(false)
+
+	ctx := context.Background()
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if now.Sub(monthStart) < statementCloseDelay {
+		return
+	}
+	period := monthStart.AddDate(0, -1, 0).Format(model.StatementPeriodLayout)
+	closed, err := model.CloseStatements(period)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("close statements %s failed: %v", period, err))
+		return
+	}
+	if closed > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("statement task: closed %d statements for %s", closed, period))
+	}
+	sendStatementEmails(ctx, period)
+}
+
+// sendStatementEmails 向开启对账单邮件且尚未发送的用户发送已结账的对账单
+func sendStatementEmails(ctx context.Context, period string) {
+	users, err := model.GetStatementEmailUsers()
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("statement email task failed: %v", err))
+		return
+	}
+	sent := 0
+	for _, user := range users {
+		if !user.GetSetting().StatementEmailEnabled || user.Email == "" {
+			continue
+		}
+		data, statement, err := model.GetStatement(user.Id, 0, period)
+		if errors.Is(err, model.ErrStatementNotFound) {
+			continue
+		}
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("generate statement %s for user %d failed: %v", period, user.Id, err))
+			continue
+		}
+		if statement == nil || statement.EmailedTime > 0 {
+			continue
+		}
+		if err := SendStatementEmail(user.Email, data); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("send statement %s to user %d failed: %v", period, user.Id, err))
+			continue
+		}
+		if err := model.MarkStatementEmailed(statement.Id); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("mark statement %d emailed failed: %v", statement.Id, err))
+		}
+		sent++
+	}
+	if sent > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("statement email task: sent %d statements for %s", sent, period))
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// 对账单 PDF 使用 A4 纸张。ASCII 字符使用内置 Courier 等宽字体；其他字符使用 Type0 复合字体，
+// 字形取自 Adobe-GB1 预定义的 STSong-Light（UniGB-UTF16-H 编码，覆盖简繁中文与常用符号），
+// 阅读器自带或替换该字体，无需嵌入字体文件；附带的 ToUnicode 保证文本可复制、可检索
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 40
+	pdfFontSize     = 8
+	pdfLineHeight   = 11
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+const (
+	pdfCJKFont = "<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UTF16-H /DescendantFonts [5 0 R] /ToUnicode 7 0 R >>"
+	pdfCIDFont = "<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 6 0 R /DW 1000 >>"
+	pdfCJKFontDescriptor = "<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>"
+)
+
+// pdfToUnicodeCMap 编码即 UTF-16BE 码元，按高字节分段原样映射（不含代理区）
+func pdfToUnicodeCMap() string {
+	var ranges []string
+	for hi := 0; hi <= 0xff; hi++ {
+		if hi >= 0xd8 && hi <= 0xdf {
+			continue
+		}
+		ranges = append(ranges, fmt.Sprintf("<%02X00> <%02XFF> <%02X00>", hi, hi, hi))
+	}
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// 每段 bfrange 最多 100 条
+	for start := 0; start < len(ranges); start += 100 {
+		end := min(start+100, len(ranges))
+		fmt.Fprintf(&b, "%d beginbfrange\n%s\nendbfrange\n", end-start, strings.Join(ranges[start:end], "\n"))
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return b.String()
+}
+
+// pdfTextOps 将一行文本按字符集拆分为 Courier（F1）与中文字体（F2）的绘制指令，
+// 控制字符与基本多文种平面以外的字符替换为 '?'
+func pdfTextOps(line string) string {
+	var ops, run strings.Builder
+	cjk := false
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if cjk {
+			fmt.Fprintf(&ops, "/F2 %d Tf <%s> Tj ", pdfFontSize, run.String())
+		} else {
+			fmt.Fprintf(&ops, "/F1 %d Tf (%s) Tj ", pdfFontSize, run.String())
+		}
+		run.Reset()
+	}
+	for _, r := range line {
+		if r < 0x20 || r == 0x7f || r > 0xffff {
+			r = '?'
+		}
+		if isCJK := r > 0x7e; isCJK != cjk {
+			flush()
+			cjk = isCJK
+		}
+		switch {
+		case cjk:
+			fmt.Fprintf(&run, "%04X", r)
+		case r == '(' || r == ')' || r == '\\':
+			run.WriteByte('\\')
+			run.WriteRune(r)
+		default:
+			run.WriteRune(r)
+		}
+	}
+	flush()
+	return ops.String()
+}
+
+// renderTextPDF 将文本行排版为多页 PDF
+func renderTextPDF(lines []string) []byte {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	var pages [][]string
+	for start := 0; start < len(lines); start += pdfLinesPerPage {
+		end := min(start+pdfLinesPerPage, len(lines))
+		pages = append(pages, lines[start:end])
+	}
+
+	// 对象编号：1 Catalog，2 Pages，3 Courier，4-7 中文字体及其描述与 ToUnicode，之后每页依次为 Page 与内容流
+	const firstPage = 8
+	objects := make([]string, 0, firstPage-1+2*len(pages))
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	toUnicode := pdfToUnicodeCMap()
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		pdfCJKFont,
+		pdfCIDFont,
+		pdfCJKFontDescriptor,
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode), toUnicode),
+	)
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT\n%d TL\n%d %d Td\n", pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "%sT*\n", pdfTextOps(line))
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, firstPage+1+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
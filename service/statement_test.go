@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderStatementPDF(t *testing.T) {
+	data := &model.StatementData{UserId: 1, Username: "用户(a)", Period: "2026-03", Closed: true}
+	for i := 0; i < 100; i++ {
+		data.Consumption = append(data.Consumption, model.StatementModelUsage{ModelName: fmt.Sprintf("model-%d", i), Count: 1, Quota: 10})
+	}
+	pdf := RenderStatementPDF(data)
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "/Count 2", "long statements span multiple pages")
+	assert.Contains(t, string(pdf), "/F2 8 Tf <75286237> Tj /F1 8 Tf (\\(a\\)) Tj", "CJK uses the UTF-16 composite font and parentheses are escaped")
+	assert.Contains(t, string(pdf), "/Encoding /UniGB-UTF16-H")
+	assert.Contains(t, string(pdf), "/ToUnicode 7 0 R")
+
+	// xref 中记录的偏移量必须指向对应对象
+	xref := bytes.LastIndex(pdf, []byte("xref\n"))
+	entries := strings.Split(string(pdf[xref:]), "\n")[3:]
+	for i, entry := range entries {
+		if !strings.HasSuffix(entry, " n ") {
+			break
+		}
+		var offset int
+		_, err := fmt.Sscanf(entry, "%010d", &offset)
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))))
+	}
+}
+
+func TestRenderStatementCSV(t *testing.T) {
+	data := &model.StatementData{
+		Period:      "2026-03",
+		Consumption: []model.StatementModelUsage{{ModelName: "gpt-4o", Count: 2, PromptTokens: 140, CompletionTokens: 60, Quota: 1500}},
+		Summary:     model.StatementSummary{ConsumeQuota: 1500, NetConsumeQuota: 1500, RequestCount: 2},
+	}
+	content, err := RenderStatementCSV(data)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Equal(t, "section,reference,description,time,count,prompt_tokens,completion_tokens,quota,amount_usd,money", lines[0])
+	assert.Equal(t, "consumption,gpt-4o,,,2,140,60,1500,0.003000,", lines[1])
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
@@ -58,6 +59,7 @@ func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
 	})
 	model.UpdateUserUsedQuotaAndRequestCount(info.UserId, info.PriceData.Quota)
 	model.UpdateChannelUsedQuota(info.ChannelId, info.PriceData.Quota)
+	ReportRelayUsage(c, info, info.OriginModelName, 0, 0, info.PriceData.Quota)
 }
 
 // ---------------------------------------------------------------------------
@@ -80,35 +82,47 @@ func taskIsSubscription(task *model.Task) bool {
 	return task.PrivateData.BillingSource == BillingSourceSubscription && task.PrivateData.SubscriptionId > 0
 }
 
-// taskAdjustFunding 调整任务的资金来源（钱包或订阅），delta > 0 表示扣费，delta < 0 表示退还。
-func taskAdjustFunding(task *model.Task, delta int) error {
-	if taskIsSubscription(task) {
-		return model.PostConsumeUserSubscriptionDelta(task.PrivateData.SubscriptionId, int64(delta))
+// taskAdjustFunding 调整任务的资金来源（钱包、订阅或组织钱包），delta > 0 表示扣费，delta < 0 表示退还。
+func taskAdjustFunding(task *model.Task, delta int, ref model.LedgerRef) error {
+	return adjustFundingSource(task.UserId, task.PrivateData.BillingSource, task.PrivateData.SubscriptionId, task.PrivateData.OrgId, delta, ref)
+}
+
+// adjustFundingSource 按记录下来的计费来源调整资金，未记录来源的旧数据按钱包处理。
+func adjustFundingSource(userId int, billingSource string, subscriptionId int, orgId int, delta int, ref model.LedgerRef) error {
+	if billingSource == BillingSourceSubscription && subscriptionId > 0 {
+		return model.PostConsumeUserSubscriptionDelta(subscriptionId, int64(delta), ref)
+	}
+	if billingSource == BillingSourceOrg && orgId > 0 {
+		return model.PostConsumeOrganizationQuota(orgId, userId, delta, ref)
 	}
 	if delta > 0 {
-		return model.DecreaseUserQuota(task.UserId, delta)
+		return model.DecreaseUserQuota(userId, delta, ref)
 	}
-	return model.IncreaseUserQuota(task.UserId, -delta, false)
+	return model.IncreaseUserQuota(userId, -delta, false, ref)
 }
 
 // taskAdjustTokenQuota 调整任务的令牌额度，delta > 0 表示扣费，delta < 0 表示退还。
 // 需要通过 resolveTokenKey 运行时获取 key（不从 PrivateData 中读取）。
-func taskAdjustTokenQuota(ctx context.Context, task *model.Task, delta int) {
-	if task.PrivateData.TokenId <= 0 || delta == 0 {
+func taskAdjustTokenQuota(ctx context.Context, task *model.Task, delta int, ref model.LedgerRef) {
+	adjustTokenQuotaById(ctx, task.PrivateData.TokenId, task.TaskID, delta, ref)
+}
+
+func adjustTokenQuotaById(ctx context.Context, tokenId int, taskID string, delta int, ref model.LedgerRef) {
+	if tokenId <= 0 || delta == 0 {
 		return
 	}
-	tokenKey := resolveTokenKey(ctx, task.PrivateData.TokenId, task.TaskID)
+	tokenKey := resolveTokenKey(ctx, tokenId, taskID)
 	if tokenKey == "" {
 		return
 	}
 	var err error
 	if delta > 0 {
-		err = model.DecreaseTokenQuota(task.PrivateData.TokenId, tokenKey, delta)
+		err = model.DecreaseTokenQuota(tokenId, tokenKey, delta, ref)
 	} else {
-		err = model.IncreaseTokenQuota(task.PrivateData.TokenId, tokenKey, -delta)
+		err = model.IncreaseTokenQuota(tokenId, tokenKey, -delta, ref)
 	}
 	if err != nil {
-		logger.LogWarn(ctx, fmt.Sprintf("调整令牌额度失败 (delta=%d, task=%s): %s", delta, task.TaskID, err.Error()))
+		logger.LogWarn(ctx, fmt.Sprintf("调整令牌额度失败 (delta=%d, task=%s): %s", delta, taskID, err.Error()))
 	}
 }
 
@@ -148,14 +162,16 @@ func RefundTaskQuota(ctx context.Context, task *model.Task, reason string) {
 		return
 	}
 
-	// 1. 退还资金来源（钱包或订阅）
-	if err := taskAdjustFunding(task, -quota); err != nil {
+	// 1. 退还资金来源（钱包、订阅或组织钱包）
+	ref := model.NewEventLedgerRef(model.LedgerReasonRefund, model.LedgerEventTaskRefund, task.TaskID).WithModel(taskModelName(task))
+	if err := taskAdjustFunding(task, -quota, ref); err != nil {
 		logger.LogWarn(ctx, fmt.Sprintf("退还资金来源失败 task %s: %s", task.TaskID, err.Error()))
 		return
 	}
 
-	// 2. 退还令牌额度
-	taskAdjustTokenQuota(ctx, task, -quota)
+	// 2. 退还令牌额度与周期预算
+	taskAdjustTokenQuota(ctx, task, -quota, ref)
+	adjustTaskBudgets(task, -quota)
 
 	// 3. 记录日志
 	other := taskBillingOther(task)
@@ -170,10 +186,42 @@ func RefundTaskQuota(ctx context.Context, task *model.Task, reason string) {
 		Quota:     quota,
 		TokenId:   task.PrivateData.TokenId,
 		Group:     task.Group,
+		OrgId:     task.PrivateData.OrgId,
 		Other:     other,
 	})
 }
 
+// RefundMidjourneyQuota Midjourney 任务失败时按提交时记录的计费来源退款，并退还令牌额度与周期预算。
+func RefundMidjourneyQuota(ctx context.Context, task *model.Midjourney, reason string) {
+	quota := task.Quota
+	if quota == 0 {
+		return
+	}
+	ref := model.NewEventLedgerRef(model.LedgerReasonRefund, model.LedgerEventMjRefund, task.MjId).WithModel(CovertMjpActionToModelName(task.Action))
+	if err := adjustFundingSource(task.UserId, task.BillingSource, task.SubscriptionId, task.OrgId, -quota, ref); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("退还资金来源失败 mj task %s: %s", task.MjId, err.Error()))
+		return
+	}
+	adjustTokenQuotaById(ctx, task.TokenId, task.MjId, -quota, ref)
+	// SubmitTime 为毫秒时间戳
+	adjustBudgetsAt(task.UserId, task.TokenId, time.UnixMilli(task.SubmitTime), task.MjId, -quota)
+
+	model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
+		UserId:    task.UserId,
+		LogType:   model.LogTypeRefund,
+		Content:   "",
+		ChannelId: task.ChannelId,
+		ModelName: CovertMjpActionToModelName(task.Action),
+		Quota:     quota,
+		TokenId:   task.TokenId,
+		OrgId:     task.OrgId,
+		Other: map[string]interface{}{
+			"task_id": task.MjId,
+			"reason":  reason,
+		},
+	})
+}
+
 // RecalculateTaskQuota 通用的异步差额结算。
 // actualQuota 是任务完成后的实际应扣额度，与预扣额度 (task.Quota) 做差额结算。
 // reason 用于日志记录（例如 "token重算" 或 "adaptor调整"）。
@@ -199,13 +247,17 @@ func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int
 	))
 
 	// 调整资金来源
-	if err := taskAdjustFunding(task, quotaDelta); err != nil {
+	// 同一任务按不同实际额度多次结算时各记一次
+	ref := model.NewEventLedgerRef(model.LedgerReasonTaskAdjust,
+		fmt.Sprintf("%s_%d", model.LedgerEventTaskAdjust, actualQuota), task.TaskID).WithModel(taskModelName(task))
+	if err := taskAdjustFunding(task, quotaDelta, ref); err != nil {
 		logger.LogError(ctx, fmt.Sprintf("差额结算资金调整失败 task %s: %s", task.TaskID, err.Error()))
 		return
 	}
 
-	// 调整令牌额度
-	taskAdjustTokenQuota(ctx, task, quotaDelta)
+	// 调整令牌额度与周期预算
+	taskAdjustTokenQuota(ctx, task, quotaDelta, ref)
+	adjustTaskBudgets(task, quotaDelta)
 
 	task.Quota = actualQuota
 
@@ -234,6 +286,7 @@ func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int
 		Quota:     logQuota,
 		TokenId:   task.PrivateData.TokenId,
 		Group:     task.Group,
+		OrgId:     task.PrivateData.OrgId,
 		Other:     other,
 	})
 }
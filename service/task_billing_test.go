@@ -43,6 +43,10 @@ func TestMain(m *testing.M) {
 		&model.Log{},
 		&model.Channel{},
 		&model.UserSubscription{},
+		&model.File{},
+		&model.Batch{},
+		&model.LedgerJournal{},
+		&model.LedgerEntry{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -63,6 +67,8 @@ func truncate(t *testing.T) {
 		model.DB.Exec("DELETE FROM logs")
 		model.DB.Exec("DELETE FROM channels")
 		model.DB.Exec("DELETE FROM user_subscriptions")
+		model.DB.Exec("DELETE FROM ledger_journals")
+		model.DB.Exec("DELETE FROM ledger_entries")
 	})
 }
 
@@ -209,6 +215,14 @@ func TestRefundTaskQuota_Wallet(t *testing.T) {
 	assert.Equal(t, tokenRemain+preConsumed, getTokenRemainQuota(t, tokenID))
 	assert.Equal(t, -preConsumed, getTokenUsedQuota(t, tokenID))
 
+	// Both balances should stay derivable from the ledger
+	userBalance, err := model.GetLedgerBalance(model.LedgerAccountUser, userID)
+	require.NoError(t, err)
+	assert.EqualValues(t, initQuota+preConsumed, userBalance)
+	tokenBalance, err := model.GetLedgerBalance(model.LedgerAccountToken, tokenID)
+	require.NoError(t, err)
+	assert.EqualValues(t, tokenRemain+preConsumed, tokenBalance)
+
 	// A refund log should be created
 	log := getLastLog(t)
 	require.NotNil(t, log)
@@ -217,6 +231,36 @@ func TestRefundTaskQuota_Wallet(t *testing.T) {
 	assert.Equal(t, "test-model", log.ModelName)
 }
 
+func TestRefundTaskQuota_RepeatedRefundPostsOnce(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	const userID, tokenID, channelID = 1, 1, 1
+	const initQuota, preConsumed = 10000, 3000
+	const tokenRemain = 5000
+
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-test-key", tokenRemain)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceWallet, 0)
+
+	// Duplicate callbacks or a callback racing the poller must not refund twice
+	RefundTaskQuota(ctx, task, "task failed: upstream error")
+	RefundTaskQuota(ctx, task, "task failed: upstream error")
+
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+	assert.Equal(t, tokenRemain+preConsumed, getTokenRemainQuota(t, tokenID))
+
+	var journals int64
+	require.NoError(t, model.DB.Model(&model.LedgerJournal{}).
+		Where("reason = ? AND ref_id = ?", model.LedgerReasonRefund, task.TaskID).Count(&journals).Error)
+	assert.EqualValues(t, 2, journals) // one for the wallet, one for the token
+	userBalance, err := model.GetLedgerBalance(model.LedgerAccountUser, userID)
+	require.NoError(t, err)
+	assert.EqualValues(t, initQuota+preConsumed, userBalance)
+}
+
 func TestRefundTaskQuota_Subscription(t *testing.T) {
 	truncate(t)
 	ctx := context.Background()
@@ -246,6 +290,39 @@ func TestRefundTaskQuota_Subscription(t *testing.T) {
 	assert.Equal(t, model.LogTypeRefund, log.Type)
 }
 
+func TestRefundMidjourneyQuota_RefundsRecordedSource(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	const userID, tokenID, subID = 3, 3, 2
+	const quota = 1500
+	const subTotal, subUsed int64 = 100000, 50000
+	const initQuota, tokenRemain = 10000, 8000
+
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-mj-key", tokenRemain)
+	seedSubscription(t, subID, userID, subTotal, subUsed)
+
+	task := &model.Midjourney{UserId: userID, MjId: "mj_sub", Action: "IMAGINE", Quota: quota,
+		SubmitTime: time.Now().UnixMilli(), BillingSource: BillingSourceSubscription, SubscriptionId: subID, TokenId: tokenID}
+	RefundMidjourneyQuota(ctx, task, "构图失败")
+
+	// 订阅计费的任务退回订阅，钱包不变
+	assert.Equal(t, subUsed-int64(quota), getSubscriptionUsed(t, subID))
+	assert.Equal(t, initQuota, getUserQuota(t, userID))
+	assert.Equal(t, tokenRemain+quota, getTokenRemainQuota(t, tokenID))
+
+	// 未记录计费来源的旧任务退回钱包
+	legacy := &model.Midjourney{UserId: userID, MjId: "mj_legacy", Action: "IMAGINE", Quota: quota, SubmitTime: time.Now().UnixMilli()}
+	RefundMidjourneyQuota(ctx, legacy, "构图失败")
+	assert.Equal(t, initQuota+quota, getUserQuota(t, userID))
+
+	log := getLastLog(t)
+	require.NotNil(t, log)
+	assert.Equal(t, model.LogTypeRefund, log.Type)
+	assert.Equal(t, quota, log.Quota)
+}
+
 func TestRefundTaskQuota_ZeroQuota(t *testing.T) {
 	truncate(t)
 	ctx := context.Background()
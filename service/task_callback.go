@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// standardWebhookTolerance Standard Webhooks 时间戳允许的偏差，超出视为重放
+const standardWebhookTolerance = 5 * time.Minute
+
+// RefreshTaskFromUpstream 收到上游回调后立即向上游查询并更新单个任务。
+// 回调内容仅作为触发信号，任务状态以上游查询结果为准，
+// 状态流转（UpdateWithStatus CAS）、差额结算与完成回调均复用轮询逻辑，与轮询并发时不会重复计费。
+func RefreshTaskFromUpstream(ctx context.Context, task *model.Task) error {
+	upstreamID := task.GetUpstreamTaskID()
+	if upstreamID == "" {
+		return errors.New("task has no upstream id")
+	}
+	taskM := map[string]*model.Task{upstreamID: task}
+	if task.Platform == constant.TaskPlatformSuno {
+		return updateSunoTasks(ctx, task.ChannelId, []string{upstreamID}, taskM)
+	}
+	ch, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return fmt.Errorf("CacheGetChannel failed: %w", err)
+	}
+	adaptor := GetTaskAdaptorFunc(task.Platform)
+	if adaptor == nil {
+		return fmt.Errorf("video adaptor not found")
+	}
+	info := &relaycommon.RelayInfo{}
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelBaseUrl: ch.GetBaseURL(),
+	}
+	info.ApiKey = ch.Key
+	adaptor.Init(info)
+	return updateVideoSingleTask(ctx, adaptor, ch, upstreamID, taskM)
+}
+
+// standardWebhookSignature 按 Standard Webhooks 规范计算签名（不含 "v1," 前缀）
+func standardWebhookSignature(key []byte, msgId string, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msgId + "." + timestamp + "."))
+	h.Write(body)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// VerifyStandardWebhook 校验 Standard Webhooks 格式（OpenAI 等使用）的签名：
+// 签名内容为 "{webhook-id}.{webhook-timestamp}.{body}"，密钥为 whsec_ 前缀后的 base64 内容
+func VerifyStandardWebhook(secret string, header http.Header, body []byte) error {
+	msgId := header.Get("webhook-id")
+	timestamp := header.Get("webhook-timestamp")
+	signatures := header.Get("webhook-signature")
+	if msgId == "" || timestamp == "" || signatures == "" {
+		return errors.New("missing webhook signature headers")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid webhook timestamp")
+	}
+	if diff := time.Since(time.Unix(ts, 0)); diff > standardWebhookTolerance || diff < -standardWebhookTolerance {
+		return errors.New("webhook timestamp out of tolerance")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return errors.New("invalid webhook secret")
+	}
+	expected := standardWebhookSignature(key, msgId, timestamp, body)
+	// 密钥轮换期间可能携带多个签名，以空格分隔，任一匹配即可
+	for _, sig := range strings.Fields(signatures) {
+		version, value, ok := strings.Cut(sig, ",")
+		if ok && version == "v1" && hmac.Equal([]byte(value), []byte(expected)) {
+			return nil
+		}
+	}
+	return errors.New("webhook signature mismatch")
+}
+
+// ScheduleTaskFallbackPoll 已注册上游回调的新任务推迟到兜底间隔后再轮询，插入任务前调用
+func ScheduleTaskFallbackPoll(task *model.Task) {
+	interval := int64(operation_setting.GetTaskCallbackSetting().FallbackPollIntervalSeconds)
+	if !task.PrivateData.UpstreamCallback || interval <= 0 {
+		return
+	}
+	task.NextPollAt = time.Now().Unix() + interval
+}
+
+// deferFallbackPollTasks 本轮被轮询的回调任务把下一次轮询时间推迟一个兜底间隔。
+// 同时更新内存中的值，避免随后的 UpdateWithStatus 把旧值写回
+func deferFallbackPollTasks(ctx context.Context, tasks []*model.Task, now int64) {
+	interval := int64(operation_setting.GetTaskCallbackSetting().FallbackPollIntervalSeconds)
+	if interval <= 0 {
+		return
+	}
+	ids := make([]int64, 0)
+	for _, task := range tasks {
+		if !task.PrivateData.UpstreamCallback {
+			continue
+		}
+		task.NextPollAt = now + interval
+		ids = append(ids, task.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := model.TaskBulkUpdateByID(ids, map[string]any{"next_poll_at": now + interval}); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("defer fallback poll tasks error: %v", err))
+	}
+}
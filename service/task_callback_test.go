@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signStandardWebhook(key []byte, msgId string, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msgId + "." + timestamp + "."))
+	h.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestVerifyStandardWebhook(t *testing.T) {
+	key := []byte("test-webhook-key")
+	secret := "whsec_" + base64.StdEncoding.EncodeToString(key)
+	body := []byte(`{"type":"video.completed","data":{"id":"video_123"}}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	header := http.Header{}
+	header.Set("webhook-id", "msg_1")
+	header.Set("webhook-timestamp", timestamp)
+	header.Set("webhook-signature", "v1,bogus "+signStandardWebhook(key, "msg_1", timestamp, body))
+	assert.NoError(t, VerifyStandardWebhook(secret, header, body))
+
+	assert.Error(t, VerifyStandardWebhook(secret, header, []byte(`{"data":{"id":"video_456"}}`)))
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	header.Set("webhook-timestamp", stale)
+	header.Set("webhook-signature", signStandardWebhook(key, "msg_1", stale, body))
+	assert.Error(t, VerifyStandardWebhook(secret, header, body))
+
+	assert.Error(t, VerifyStandardWebhook(secret, http.Header{}, body))
+}
+
+func TestFallbackPollTasksDoNotStarveNewerTasks(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	// 较早提交、未到兜底时间的回调任务不应占用轮询名额
+	for i := 0; i < 3; i++ {
+		task := &model.Task{TaskID: fmt.Sprintf("task_cb_%d", i), Status: model.TaskStatusInProgress, Progress: "30%",
+			SubmitTime: now, PrivateData: model.TaskPrivateData{UpstreamCallback: true}}
+		ScheduleTaskFallbackPoll(task)
+		require.NoError(t, task.Insert())
+	}
+	plain := &model.Task{TaskID: "task_plain", Status: model.TaskStatusInProgress, Progress: "30%", SubmitTime: now}
+	require.NoError(t, plain.Insert())
+	overdue := &model.Task{TaskID: "task_overdue", Status: model.TaskStatusInProgress, Progress: "30%", SubmitTime: now - 3600,
+		NextPollAt: now - 1, PrivateData: model.TaskPrivateData{UpstreamCallback: true}}
+	require.NoError(t, overdue.Insert())
+
+	tasks := model.GetAllUnFinishSyncTasks(now, 2)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, plain.ID, tasks[0].ID)
+	assert.Equal(t, overdue.ID, tasks[1].ID)
+
+	// 兜底轮询过的任务在间隔内不再轮询
+	deferFallbackPollTasks(ctx, tasks, now)
+	tasks = model.GetAllUnFinishSyncTasks(now, 10)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, plain.ID, tasks[0].ID)
+}
@@ -80,6 +80,7 @@ func sweepTimedOutTasks(ctx context.Context) {
 		if !isLegacy && task.Quota != 0 {
 			RefundTaskQuota(ctx, task, reason)
 		}
+		EnqueueTaskWebhook(ctx, task)
 	}
 
 	if timedOutCount > 0 {
@@ -94,7 +95,10 @@ func TaskPollingLoop() {
 		common.SysLog("任务进度轮询开始")
 		ctx := context.TODO()
 		sweepTimedOutTasks(ctx)
-		allTasks := model.GetAllUnFinishSyncTasks(constant.TaskQueryLimit)
+		// 已注册上游回调的任务由回调驱动更新，轮询仅作为兜底
+		now := time.Now().Unix()
+		allTasks := model.GetAllUnFinishSyncTasks(now, constant.TaskQueryLimit)
+		deferFallbackPollTasks(ctx, allTasks, now)
 		platformTask := make(map[constant.TaskPlatform][]*model.Task)
 		for _, t := range allTasks {
 			platformTask[t.Platform] = append(platformTask[t.Platform], t)
@@ -225,26 +229,46 @@ func updateSunoTasks(ctx context.Context, channelId int, taskIds []string, taskM
 		if !taskNeedsUpdate(task, responseItem) {
 			continue
 		}
+		oldStatus := task.Status
 
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
 		task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
 		task.StartTime = lo.If(responseItem.StartTime != 0, responseItem.StartTime).Else(task.StartTime)
 		task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
+		shouldRefund := false
 		if responseItem.FailReason != "" || task.Status == model.TaskStatusFailure {
 			logger.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
+			task.Status = model.TaskStatusFailure
 			task.Progress = "100%"
-			RefundTaskQuota(ctx, task, task.FailReason)
+			shouldRefund = task.Quota != 0
 		}
 		if responseItem.Status == model.TaskStatusSuccess {
 			task.Progress = "100%"
 		}
 		task.Data = responseItem.Data
 
-		err = task.Update()
+		// 终态流转使用 CAS，轮询与回调（或重复回调）并发时只有赢得状态变更的一方退款并发送回调
+		isDone := task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+		if !isDone || oldStatus == task.Status {
+			if _, err := task.UpdateWithStatus(oldStatus); err != nil {
+				common.SysLog("UpdateSunoTask task error: " + err.Error())
+			}
+			continue
+		}
+		won, err := task.UpdateWithStatus(oldStatus)
 		if err != nil {
 			common.SysLog("UpdateSunoTask task error: " + err.Error())
+			continue
 		}
+		if !won {
+			logger.LogWarn(ctx, fmt.Sprintf("Task %s already transitioned by another process, skip billing", task.TaskID))
+			continue
+		}
+		if shouldRefund {
+			RefundTaskQuota(ctx, task, task.FailReason)
+		}
+		EnqueueTaskWebhook(ctx, task)
 	}
 	return nil
 }
@@ -451,6 +475,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	}
 
 	isDone := task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+	shouldNotify := false
 	if isDone && snap.Status != task.Status {
 		won, err := task.UpdateWithStatus(snap.Status)
 		if err != nil {
@@ -461,6 +486,8 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 			logger.LogWarn(ctx, fmt.Sprintf("Task %s already transitioned by another process, skip billing", task.TaskID))
 			shouldRefund = false
 			shouldSettle = false
+		} else {
+			shouldNotify = true
 		}
 	} else if !snap.Equal(task.Snapshot()) {
 		if _, err := task.UpdateWithStatus(snap.Status); err != nil {
@@ -477,6 +504,10 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	if shouldRefund {
 		RefundTaskQuota(ctx, task, task.FailReason)
 	}
+	// 回调在结算之后发送，以携带实际扣除的额度
+	if shouldNotify {
+		EnqueueTaskWebhook(ctx, task)
+	}
 
 	return nil
 }
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	taskWebhookTickInterval = 5 * time.Second
+	taskWebhookBatchSize    = 100
+	// 投递期间占用记录的时长，节点异常退出后到期可被重新投递
+	taskWebhookLeaseSeconds = 120
+	taskWebhookMaxErrorLen  = 1024
+)
+
+var (
+	taskWebhookWorkerOnce    sync.Once
+	taskWebhookDispatchGuard atomic.Bool
+)
+
+// ValidateTaskCallbackURL 校验任务回调地址，仅允许 http/https，并遵循系统的 SSRF 防护配置
+func ValidateTaskCallbackURL(callbackURL string) error {
+	if len(callbackURL) > 1024 {
+		return fmt.Errorf("callback url is too long")
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback url must be an absolute http(s) url")
+	}
+	if system_setting.EnableWorker() {
+		return nil
+	}
+	return validateWebhookURL(callbackURL)
+}
+
+// taskResultURLs 提取任务结果地址：视频类任务取 ResultURL，Suno 取各歌曲的音视频地址
+func taskResultURLs(task *model.Task) []string {
+	if task.Status != model.TaskStatusSuccess {
+		return nil
+	}
+	if task.Platform == constant.TaskPlatformSuno {
+		urls := make([]string, 0)
+		for _, song := range gjson.ParseBytes(task.Data).Array() {
+			for _, key := range []string{"audio_url", "video_url"} {
+				if u := song.Get(key).String(); u != "" {
+					urls = append(urls, u)
+				}
+			}
+		}
+		return urls
+	}
+	if u := task.GetResultURL(); u != "" && !strings.HasPrefix(u, "data:") {
+		return []string{u}
+	}
+	return nil
+}
+
+// BuildTaskWebhookPayload 构建任务终态回调内容
+func BuildTaskWebhookPayload(eventId string, task *model.Task) dto.TaskWebhookPayload {
+	eventType := dto.TaskWebhookEventSucceeded
+	quota := task.Quota
+	if task.Status == model.TaskStatusFailure {
+		eventType = dto.TaskWebhookEventFailed
+		// 失败任务已全额退款
+		quota = 0
+	}
+	return dto.TaskWebhookPayload{
+		Id:        eventId,
+		Type:      eventType,
+		CreatedAt: common.GetTimestamp(),
+		Data: dto.TaskWebhookData{
+			TaskId:     task.TaskID,
+			Platform:   string(task.Platform),
+			Action:     task.Action,
+			Model:      taskModelName(task),
+			Status:     string(task.Status),
+			Progress:   task.Progress,
+			FailReason: task.FailReason,
+			ResultUrls: taskResultURLs(task),
+			Quota:      quota,
+			SubmitTime: task.SubmitTime,
+			FinishTime: task.FinishTime,
+		},
+	}
+}
+
+// EnqueueTaskWebhook 任务到达终态（且已完成结算/退款）后调用，为设置了 callback_url 的任务创建回调记录，
+// 由后台 worker 负责投递与重试
+func EnqueueTaskWebhook(ctx context.Context, task *model.Task) {
+	if task == nil || task.PrivateData.CallbackURL == "" || !operation_setting.GetTaskWebhookSetting().Enabled {
+		return
+	}
+	if task.Status != model.TaskStatusSuccess && task.Status != model.TaskStatusFailure {
+		return
+	}
+	eventId := model.GenerateTaskWebhookEventId()
+	payload, err := common.Marshal(BuildTaskWebhookPayload(eventId, task))
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("marshal task webhook payload failed for task %s: %v", task.TaskID, err))
+		return
+	}
+	webhook := &model.TaskWebhook{
+		EventId:       eventId,
+		TaskId:        task.TaskID,
+		UserId:        task.UserId,
+		Url:           task.PrivateData.CallbackURL,
+		Payload:       string(payload),
+		Status:        model.TaskWebhookStatusPending,
+		NextAttemptAt: common.GetTimestamp(),
+	}
+	if err := webhook.Insert(); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("enqueue task webhook failed for task %s: %v", task.TaskID, err))
+	}
+}
+
+// StartTaskWebhookWorker 启动任务回调投递 worker，仅在主节点运行
+func StartTaskWebhookWorker() {
+	taskWebhookWorkerOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("task webhook worker started: tick=%s", taskWebhookTickInterval))
+			ticker := time.NewTicker(taskWebhookTickInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				dispatchTaskWebhooks()
+			}
+		})
+	})
+}
+
+func dispatchTaskWebhooks() {
+	if !taskWebhookDispatchGuard.CompareAndSwap(false, true) {
+		return
+	}
+	defer taskWebhookDispatchGuard.Store(false)
+
+	setting := operation_setting.GetTaskWebhookSetting()
+	if !setting.Enabled {
+		return
+	}
+	ctx := context.Background()
+	now := common.GetTimestamp()
+	webhooks, err := model.GetDueTaskWebhooks(now, taskWebhookBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task webhook dispatch failed: %v", err))
+		return
+	}
+	sem := make(chan struct{}, max(setting.DeliveryConcurrency, 1))
+	var wg sync.WaitGroup
+	for _, webhook := range webhooks {
+		ok, err := model.ClaimTaskWebhook(webhook.Id, webhook.NextAttemptAt, now+taskWebhookLeaseSeconds)
+		if err != nil || !ok {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		w := webhook
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			deliverTaskWebhook(ctx, w, setting)
+		})
+	}
+	wg.Wait()
+}
+
+// taskWebhookBackoff 第 attempts 次失败后的重试间隔（秒），按指数增长并受上限约束
+func taskWebhookBackoff(attempts int, setting *operation_setting.TaskWebhookSetting) int64 {
+	backoff := int64(max(setting.InitialBackoffSeconds, 1))
+	maxBackoff := int64(max(setting.MaxBackoffSeconds, setting.InitialBackoffSeconds, 1))
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+func truncateWebhookError(err error) string {
+	msg := []rune(err.Error())
+	if len(msg) <= taskWebhookMaxErrorLen {
+		return string(msg)
+	}
+	return string(msg[:taskWebhookMaxErrorLen])
+}
+
+// generateTaskWebhookSecret 生成 Standard Webhooks 格式的签名密钥
+func generateTaskWebhookSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// GetTaskWebhookSecret 返回用户任务回调的签名密钥，未设置时自动生成
+func GetTaskWebhookSecret(userId int) (string, error) {
+	return model.EnsureUserTaskWebhookSecret(userId, generateTaskWebhookSecret)
+}
+
+// taskWebhookSigningKey whsec_ 开头的密钥按规范解码，其余（用户自定义的 WebhookSecret）直接使用原始字节
+func taskWebhookSigningKey(secret string) []byte {
+	if encoded, ok := strings.CutPrefix(secret, "whsec_"); ok {
+		if key, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			return key
+		}
+	}
+	return []byte(secret)
+}
+
+// taskWebhookHeaders 按 Standard Webhooks 规范签名：签名内容包含事件 ID 与发送时间戳，接收方据此拒绝重放
+func taskWebhookHeaders(secret string, eventId string, eventType string, timestamp int64, payload []byte) map[string]string {
+	ts := strconv.FormatInt(timestamp, 10)
+	return map[string]string{
+		"webhook-id":        eventId,
+		"webhook-timestamp": ts,
+		"webhook-signature": "v1," + standardWebhookSignature(taskWebhookSigningKey(secret), eventId, ts, payload),
+		"X-Webhook-Event":   eventType,
+	}
+}
+
+func deliverTaskWebhook(ctx context.Context, webhook *model.TaskWebhook, setting *operation_setting.TaskWebhookSetting) {
+	// 回调始终签名：使用用户的 webhook 密钥，未设置时为用户生成专用密钥
+	secret, err := GetTaskWebhookSecret(webhook.UserId)
+	if err == nil {
+		payload := []byte(webhook.Payload)
+		headers := taskWebhookHeaders(secret, webhook.EventId, gjson.GetBytes(payload, "type").String(), common.GetTimestamp(), payload)
+		err = postSignedWebhook(webhook.Url, "", payload, headers)
+	} else {
+		err = fmt.Errorf("get webhook secret failed: %w", err)
+	}
+
+	attempts := webhook.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts": attempts,
+	}
+	switch {
+	case err == nil:
+		updates["status"] = model.TaskWebhookStatusDelivered
+		updates["last_error"] = ""
+	case attempts >= setting.MaxAttempts:
+		updates["status"] = model.TaskWebhookStatusDead
+		updates["last_error"] = truncateWebhookError(err)
+		logger.LogWarn(ctx, fmt.Sprintf("task webhook %s for task %s moved to dead letter after %d attempts: %v", webhook.EventId, webhook.TaskId, attempts, err))
+	default:
+		updates["status"] = model.TaskWebhookStatusPending
+		updates["next_attempt_at"] = common.GetTimestamp() + taskWebhookBackoff(attempts, setting)
+		updates["last_error"] = truncateWebhookError(err)
+	}
+	if err := model.UpdateTaskWebhookResult(webhook.Id, updates); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("update task webhook %s failed: %v", webhook.EventId, err))
+	}
+}
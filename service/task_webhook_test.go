@@ -0,0 +1,103 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskWebhookBackoff(t *testing.T) {
+	setting := &operation_setting.TaskWebhookSetting{InitialBackoffSeconds: 30, MaxBackoffSeconds: 200}
+	assert.Equal(t, int64(30), taskWebhookBackoff(1, setting))
+	assert.Equal(t, int64(60), taskWebhookBackoff(2, setting))
+	assert.Equal(t, int64(120), taskWebhookBackoff(3, setting))
+	assert.Equal(t, int64(200), taskWebhookBackoff(4, setting))
+	assert.Equal(t, int64(200), taskWebhookBackoff(20, setting))
+}
+
+func TestBuildTaskWebhookPayload(t *testing.T) {
+	task := &model.Task{
+		TaskID:   "task_abc",
+		Platform: constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeKling)),
+		Status:   model.TaskStatusSuccess,
+		Quota:    1500,
+		PrivateData: model.TaskPrivateData{
+			ResultURL: "https://cdn.example.com/video.mp4",
+		},
+		Properties: model.Properties{OriginModelName: "kling-v1"},
+	}
+	payload := BuildTaskWebhookPayload("evt_1", task)
+	assert.Equal(t, dto.TaskWebhookEventSucceeded, payload.Type)
+	assert.Equal(t, 1500, payload.Data.Quota)
+	assert.Equal(t, []string{"https://cdn.example.com/video.mp4"}, payload.Data.ResultUrls)
+	assert.Equal(t, "kling-v1", payload.Data.Model)
+
+	task.Status = model.TaskStatusFailure
+	task.FailReason = "content policy"
+	payload = BuildTaskWebhookPayload("evt_2", task)
+	assert.Equal(t, dto.TaskWebhookEventFailed, payload.Type)
+	assert.Equal(t, 0, payload.Data.Quota)
+	assert.Empty(t, payload.Data.ResultUrls)
+}
+
+func TestTaskResultURLsSuno(t *testing.T) {
+	task := &model.Task{
+		Platform: constant.TaskPlatformSuno,
+		Status:   model.TaskStatusSuccess,
+		Data:     []byte(`[{"id":"1","audio_url":"https://a/1.mp3","video_url":"https://a/1.mp4"},{"id":"2","audio_url":"https://a/2.mp3"}]`),
+	}
+	assert.Equal(t, []string{"https://a/1.mp3", "https://a/1.mp4", "https://a/2.mp3"}, taskResultURLs(task))
+}
+
+func TestTaskWebhookHeadersVerifiable(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"task.succeeded"}`)
+	secret, err := generateTaskWebhookSecret()
+	require.NoError(t, err)
+
+	header := http.Header{}
+	for k, v := range taskWebhookHeaders(secret, "evt_1", "task.succeeded", common.GetTimestamp(), payload) {
+		header.Set(k, v)
+	}
+	assert.NoError(t, VerifyStandardWebhook(secret, header, payload))
+
+	// 重放旧的签名会因时间戳过期被拒绝
+	header = http.Header{}
+	for k, v := range taskWebhookHeaders(secret, "evt_1", "task.succeeded", common.GetTimestamp()-3600, payload) {
+		header.Set(k, v)
+	}
+	assert.Error(t, VerifyStandardWebhook(secret, header, payload))
+}
+
+func TestGetTaskWebhookSecretGeneratedOnce(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 0)
+
+	secret, err := GetTaskWebhookSecret(1)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+
+	again, err := GetTaskWebhookSecret(1)
+	require.NoError(t, err)
+	assert.Equal(t, secret, again)
+
+	// 用户自行设置的 webhook 密钥优先
+	user, err := model.GetUserById(1, true)
+	require.NoError(t, err)
+	setting := user.GetSetting()
+	setting.WebhookSecret = "my-secret"
+	user.SetSetting(setting)
+	require.NoError(t, model.DB.Model(user).Update("setting", user.Setting).Error)
+	secret, err = GetTaskWebhookSecret(1)
+	require.NoError(t, err)
+	assert.Equal(t, "my-secret", secret)
+}
@@ -354,6 +354,26 @@ func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent,
 	return textToken, audioToken, nil
 }
 
+// CountClaudeRequestTokens 本地估算 Claude 请求的输入 token，用于上游不支持 count_tokens 时的回退
+func CountClaudeRequestTokens(request *dto.ClaudeRequest) int {
+	model := request.Model
+	if !strings.Contains(strings.ToLower(model), "claude") {
+		model = "claude"
+	}
+	meta := request.GetTokenCountMeta()
+	tkm := CountTokenInput(meta.CombineText, model)
+	tkm += meta.ToolsCount * 8
+	for _, file := range meta.Files {
+		switch file.FileType {
+		case types.FileTypeImage:
+			tkm += 520
+		default:
+			tkm += 4096
+		}
+	}
+	return tkm
+}
+
 func CountTokenInput(input any, model string) int {
 	switch v := input.(type) {
 	case string:
@@ -54,26 +54,35 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		return fmt.Errorf("failed to marshal webhook payload: %v", err)
 	}
 
-	// 创建 HTTP 请求
+	return postSignedWebhook(webhookURL, secret, payloadBytes, nil)
+}
+
+// postSignedWebhook 以 POST 发送 JSON 负载，secret 非空时附带 HMAC-SHA256 签名
+func postSignedWebhook(webhookURL string, secret string, payloadBytes []byte, extraHeaders map[string]string) error {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	for k, v := range extraHeaders {
+		headers[k] = v
+	}
+	if secret != "" {
+		headers["X-Webhook-Signature"] = generateSignature(secret, payloadBytes)
+	}
+
 	var req *http.Request
 	var resp *http.Response
+	var err error
 
 	if system_setting.EnableWorker() {
 		// 构建worker请求数据
 		workerReq := &WorkerRequest{
-			URL:    webhookURL,
-			Key:    system_setting.WorkerValidKey,
-			Method: http.MethodPost,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-			Body: payloadBytes,
+			URL:     webhookURL,
+			Key:     system_setting.WorkerValidKey,
+			Method:  http.MethodPost,
+			Headers: headers,
+			Body:    payloadBytes,
 		}
-
-		// 如果有secret，添加签名到headers
 		if secret != "" {
-			signature := generateSignature(secret, payloadBytes)
-			workerReq.Headers["X-Webhook-Signature"] = signature
 			workerReq.Headers["Authorization"] = "Bearer " + secret
 		}
 
@@ -89,8 +98,7 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		}
 	} else {
 		// SSRF防护：验证Webhook URL（非Worker模式）
-		fetchSetting := system_setting.GetFetchSetting()
-		if err := common.ValidateURLWithFetchSetting(webhookURL, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
+		if err := validateWebhookURL(webhookURL); err != nil {
 			return fmt.Errorf("request reject: %v", err)
 		}
 
@@ -100,12 +108,8 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		}
 
 		// 设置请求头
-		req.Header.Set("Content-Type", "application/json")
-
-		// 如果有 secret，生成签名
-		if secret != "" {
-			signature := generateSignature(secret, payloadBytes)
-			req.Header.Set("X-Webhook-Signature", signature)
+		for k, v := range headers {
+			req.Header.Set(k, v)
 		}
 
 		// 发送请求
@@ -124,3 +128,9 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 
 	return nil
 }
+
+// validateWebhookURL 按系统的 SSRF 防护配置校验 webhook 地址
+func validateWebhookURL(webhookURL string) error {
+	fetchSetting := system_setting.GetFetchSetting()
+	return common.ValidateURLWithFetchSetting(webhookURL, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain)
+}
@@ -4,6 +4,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/setting/config"
 )
 
@@ -16,17 +17,37 @@ type ChatCompletionsToResponsesPolicy struct {
 }
 
 func (p ChatCompletionsToResponsesPolicy) IsChannelEnabled(channelID int, channelType int) bool {
-	if !p.Enabled {
+	return matchPolicyChannel(p.Enabled, p.AllChannels, p.ChannelIDs, p.ChannelTypes, channelID, channelType)
+}
+
+// CompletionsToChatPolicy 将旧版 /v1/completions 请求转换为 chat 请求，用于仅支持 chat 的渠道。
+// ModelPatterns 为空时匹配渠道下的所有模型；系统提示词为空时使用内置提示词
+type CompletionsToChatPolicy struct {
+	Enabled                bool     `json:"enabled"`
+	AllChannels            bool     `json:"all_channels"`
+	ChannelIDs             []int    `json:"channel_ids,omitempty"`
+	ChannelTypes           []int    `json:"channel_types,omitempty"`
+	ModelPatterns          []string `json:"model_patterns,omitempty"`
+	CompletionSystemPrompt string   `json:"completion_system_prompt,omitempty"`
+	FIMSystemPrompt        string   `json:"fim_system_prompt,omitempty"`
+}
+
+func (p CompletionsToChatPolicy) IsChannelEnabled(channelID int, channelType int) bool {
+	return matchPolicyChannel(p.Enabled, p.AllChannels, p.ChannelIDs, p.ChannelTypes, channelID, channelType)
+}
+
+func matchPolicyChannel(enabled bool, allChannels bool, channelIDs []int, channelTypes []int, channelID int, channelType int) bool {
+	if !enabled {
 		return false
 	}
-	if p.AllChannels {
+	if allChannels {
 		return true
 	}
 
-	if channelID > 0 && len(p.ChannelIDs) > 0 && slices.Contains(p.ChannelIDs, channelID) {
+	if channelID > 0 && len(channelIDs) > 0 && slices.Contains(channelIDs, channelID) {
 		return true
 	}
-	if channelType > 0 && len(p.ChannelTypes) > 0 && slices.Contains(p.ChannelTypes, channelType) {
+	if channelType > 0 && len(channelTypes) > 0 && slices.Contains(channelTypes, channelType) {
 		return true
 	}
 	return false
@@ -36,6 +57,7 @@ type GlobalSettings struct {
 	PassThroughRequestEnabled        bool                             `json:"pass_through_request_enabled"`
 	ThinkingModelBlacklist           []string                         `json:"thinking_model_blacklist"`
 	ChatCompletionsToResponsesPolicy ChatCompletionsToResponsesPolicy `json:"chat_completions_to_responses_policy"`
+	CompletionsToChatPolicy          CompletionsToChatPolicy          `json:"completions_to_chat_policy"`
 }
 
 // 默认配置
@@ -49,6 +71,16 @@ var defaultOpenaiSettings = GlobalSettings{
 		Enabled:     false,
 		AllChannels: true,
 	},
+	CompletionsToChatPolicy: CompletionsToChatPolicy{
+		Enabled: false,
+		// 默认覆盖仅支持 chat 的主流渠道，启用后即可生效
+		ChannelTypes: []int{
+			constant.ChannelTypeAnthropic,
+			constant.ChannelTypeGemini,
+			constant.ChannelTypeAws,
+			constant.ChannelTypeVertexAi,
+		},
+	},
 }
 
 // 全局实例
@@ -0,0 +1,47 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// BatchSetting Batch API（/v1/batches）相关配置
+type BatchSetting struct {
+	Enabled              bool     `json:"enabled"`                // 是否启用网关侧批处理
+	MaxConcurrentBatches int      `json:"max_concurrent_batches"` // 同时执行的批处理任务数
+	RequestConcurrency   int      `json:"request_concurrency"`    // 单个批处理任务内并发执行的请求数
+	MaxRequestsPerBatch  int      `json:"max_requests_per_batch"` // 单个批处理任务的最大请求数
+	AllowedEndpoints     []string `json:"allowed_endpoints"`      // 允许的 endpoint 列表
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Enabled:              true,
+	MaxConcurrentBatches: 2,
+	RequestConcurrency:   4,
+	MaxRequestsPerBatch:  50000,
+	AllowedEndpoints: []string{
+		"/v1/chat/completions",
+		"/v1/completions",
+		"/v1/embeddings",
+		"/v1/responses",
+		"/v1/moderations",
+		"/v1/messages",
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+// GetBatchSetting 获取 Batch API 配置
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
+
+// IsBatchEndpointAllowed 判断 endpoint 是否允许批处理
+func IsBatchEndpointAllowed(endpoint string) bool {
+	return slices.Contains(batchSetting.AllowedEndpoints, endpoint)
+}
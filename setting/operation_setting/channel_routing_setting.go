@@ -0,0 +1,62 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	// ChannelRoutingStrategyWeighted 按优先级 + 静态权重随机选择（默认）
+	ChannelRoutingStrategyWeighted = "weighted"
+	// ChannelRoutingStrategyAdaptive 在静态权重基础上按渠道实时延迟、首字耗时与错误率调整权重
+	ChannelRoutingStrategyAdaptive = "adaptive"
+)
+
+// ChannelRoutingSetting 渠道选择策略配置
+type ChannelRoutingSetting struct {
+	DefaultStrategy string            `json:"default_strategy"`  // 默认策略：weighted / adaptive
+	GroupStrategies map[string]string `json:"group_strategies"`  // 按分组覆盖默认策略
+	EwmaAlpha       float64           `json:"ewma_alpha"`        // EWMA 平滑系数，越大越偏向最近的请求
+	WindowSeconds   int               `json:"window_seconds"`    // 统计窗口，超过窗口未更新的数据逐渐失去影响
+	MinSamples      int               `json:"min_samples"`       // 样本数不足时不调整权重
+	LatencyWeight   float64           `json:"latency_weight"`    // 延迟因子的指数，0 表示忽略延迟
+	ErrorPenalty    float64           `json:"error_penalty"`     // 错误率因子的指数，越大对失败越敏感
+	MinWeightFactor float64           `json:"min_weight_factor"` // 权重最低保留比例，保证劣化渠道仍有少量流量用于恢复探测
+}
+
+// 默认配置
+var channelRoutingSetting = ChannelRoutingSetting{
+	DefaultStrategy: ChannelRoutingStrategyWeighted,
+	GroupStrategies: map[string]string{},
+	EwmaAlpha:       0.2,
+	WindowSeconds:   600,
+	MinSamples:      5,
+	LatencyWeight:   1,
+	ErrorPenalty:    2,
+	MinWeightFactor: 0.05,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_routing_setting", &channelRoutingSetting)
+}
+
+// GetChannelRoutingSetting 获取渠道选择策略配置
+func GetChannelRoutingSetting() *ChannelRoutingSetting {
+	return &channelRoutingSetting
+}
+
+// GetChannelRoutingStrategy 获取分组使用的渠道选择策略
+func GetChannelRoutingStrategy(group string) string {
+	if strategy, ok := channelRoutingSetting.GroupStrategies[group]; ok && strategy != "" {
+		return strategy
+	}
+	if channelRoutingSetting.DefaultStrategy == "" {
+		return ChannelRoutingStrategyWeighted
+	}
+	return channelRoutingSetting.DefaultStrategy
+}
+
+// IsAdaptiveRouting 分组是否启用自适应渠道选择
+func IsAdaptiveRouting(group string) bool {
+	return GetChannelRoutingStrategy(group) == ChannelRoutingStrategyAdaptive
+}
@@ -0,0 +1,41 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// CircuitBreakerSetting 渠道熔断配置，熔断粒度为 渠道 + 模型（多 Key 渠道细化到每个 Key）
+type CircuitBreakerSetting struct {
+	Enabled              bool    `json:"enabled"`
+	ConsecutiveFailures  int     `json:"consecutive_failures"`   // 连续失败多少次后熔断，0 表示不按连续失败熔断
+	ErrorRateThreshold   float64 `json:"error_rate_threshold"`   // 窗口内错误率达到阈值后熔断，0 表示不按错误率熔断
+	MinRequests          int     `json:"min_requests"`           // 按错误率熔断所需的最少请求数
+	WindowSeconds        int     `json:"window_seconds"`         // 错误率统计窗口
+	OpenSeconds          int     `json:"open_seconds"`           // 首次熔断时长，半开探测失败后按倍数递增
+	MaxOpenSeconds       int     `json:"max_open_seconds"`       // 熔断时长上限
+	ProbeIntervalSeconds int     `json:"probe_interval_seconds"` // 半开状态下两次探测请求的最小间隔
+	HalfOpenSuccesses    int     `json:"half_open_successes"`    // 半开状态下连续成功多少次后恢复
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:              false,
+	ConsecutiveFailures:  5,
+	ErrorRateThreshold:   0.5,
+	MinRequests:          20,
+	WindowSeconds:        60,
+	OpenSeconds:          30,
+	MaxOpenSeconds:       600,
+	ProbeIntervalSeconds: 5,
+	HalfOpenSuccesses:    2,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+// GetCircuitBreakerSetting 获取熔断配置
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
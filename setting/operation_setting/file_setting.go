@@ -0,0 +1,69 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// FileSetting Files API（/v1/files）相关配置
+type FileSetting struct {
+	Enabled            bool     `json:"enabled"`               // 是否启用网关侧文件存储
+	StorageBackend     string   `json:"storage_backend"`       // 存储后端名称，默认 local（磁盘）
+	MaxFileSizeMB      int      `json:"max_file_size_mb"`      // 单文件大小上限（MB）
+	UserStorageQuotaMB int      `json:"user_storage_quota_mb"` // 每用户存储配额（MB），0 表示不限制
+	DefaultExpireHours int      `json:"default_expire_hours"`  // 文件默认保留时长（小时），0 表示永久保存
+	ForwardToUpstream  bool     `json:"forward_to_upstream"`   // 请求引用网关文件时，是否自动上传到目标渠道并替换为上游文件 ID
+	AllowedPurposes    []string `json:"allowed_purposes"`      // 允许的 purpose 列表
+	// 上游仅返回 base64 而请求 response_format=url 时，是否由网关临时托管图片并返回 URL
+	ImageHostingEnabled       bool `json:"image_hosting_enabled"`
+	ImageHostingExpireMinutes int  `json:"image_hosting_expire_minutes"` // 托管图片的保留时长（分钟）
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	Enabled:            true,
+	StorageBackend:     "local",
+	MaxFileSizeMB:      512,
+	UserStorageQuotaMB: 1024,
+	DefaultExpireHours: 0,
+	ForwardToUpstream:  true,
+	AllowedPurposes: []string{
+		"assistants",
+		"batch",
+		"fine-tune",
+		"vision",
+		"user_data",
+		"evals",
+	},
+	ImageHostingEnabled:       true,
+	ImageHostingExpireMinutes: 60,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+// GetFileSetting 获取 Files API 配置
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
+
+// IsFilePurposeAllowed 判断 purpose 是否在允许列表中
+func IsFilePurposeAllowed(purpose string) bool {
+	if len(fileSetting.AllowedPurposes) == 0 {
+		return true
+	}
+	return slices.Contains(fileSetting.AllowedPurposes, purpose)
+}
+
+// GetMaxFileSizeBytes 获取单文件大小上限（字节）
+func GetMaxFileSizeBytes() int64 {
+	return int64(fileSetting.MaxFileSizeMB) << 20
+}
+
+// GetUserStorageQuotaBytes 获取每用户存储配额（字节），0 表示不限制
+func GetUserStorageQuotaBytes() int64 {
+	return int64(fileSetting.UserStorageQuotaMB) << 20
+}
@@ -0,0 +1,41 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// HedgeSetting 对冲请求配置
+// 开启后，令牌开启对冲或分组在 Groups 中的请求，若首个渠道在 DelayMs 内没有返回首字节，
+// 会向另一个满足条件的渠道再发一次请求，先开始返回的响应胜出，另一个被取消
+type HedgeSetting struct {
+	Enabled bool     `json:"enabled"`  // 总开关
+	DelayMs int      `json:"delay_ms"` // 首字节等待多久后发出对冲请求
+	Groups  []string `json:"groups"`   // 对该分组的全部请求启用对冲
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled: false,
+	DelayMs: 1500,
+	Groups:  []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+// GetHedgeSetting 获取对冲请求配置
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// ShouldHedge 请求是否启用对冲：令牌单独开启或所在分组开启
+func ShouldHedge(group string, tokenHedge bool) bool {
+	if !hedgeSetting.Enabled || hedgeSetting.DelayMs <= 0 {
+		return false
+	}
+	return tokenHedge || slices.Contains(hedgeSetting.Groups, group)
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// MetricsSetting Prometheus /metrics 导出配置
+type MetricsSetting struct {
+	Enabled      bool   `json:"enabled"`       // 是否开放 /metrics 端点
+	ScrapeSecret string `json:"scrape_secret"` // 抓取密钥（Bearer），为空时仅允许管理员访问；key 以 secret 结尾，不会通过选项接口下发
+}
+
+// 默认配置
+var metricsSetting = MetricsSetting{
+	Enabled:      true,
+	ScrapeSecret: "",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("metrics_setting", &metricsSetting)
+}
+
+// GetMetricsSetting 获取 metrics 配置
+func GetMetricsSetting() *MetricsSetting {
+	return &metricsSetting
+}
@@ -0,0 +1,29 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// MultiKeySetting 多 Key 渠道的 Key 级调度配置
+type MultiKeySetting struct {
+	ParkSeconds          int `json:"park_seconds"`            // 上游返回 429 且未携带 Retry-After 时暂停使用该 Key 的时长
+	MaxParkSeconds       int `json:"max_park_seconds"`        // Retry-After 的上限，避免异常值长期停用 Key
+	InFlightLeaseSeconds int `json:"in_flight_lease_seconds"` // 进行中计数的最长保留时间，实例异常退出时自动释放
+}
+
+// 默认配置
+var multiKeySetting = MultiKeySetting{
+	ParkSeconds:          60,
+	MaxParkSeconds:       3600,
+	InFlightLeaseSeconds: 600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("multi_key_setting", &multiKeySetting)
+}
+
+// GetMultiKeySetting 获取多 Key 调度配置
+func GetMultiKeySetting() *MultiKeySetting {
+	return &multiKeySetting
+}
@@ -0,0 +1,93 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 令牌级响应缓存开关
+const (
+	ResponseCacheTokenDefault = 0 // 跟随系统设置
+	ResponseCacheTokenEnabled = 1 // 对该令牌的全部模型启用
+	ResponseCacheTokenDisable = 2 // 对该令牌禁用
+)
+
+// ResponseCacheSetting 响应缓存配置
+// 对 chat completions、embeddings 与 rerank 请求按 分组 + 模型 + 规范化后的请求体 精确匹配缓存上游响应；
+// 启用语义缓存后，chat 请求精确匹配未命中时再按消息内容的 embedding 余弦相似度查找其他参数相同的缓存
+type ResponseCacheSetting struct {
+	Enabled             bool           `json:"enabled"`               // 总开关
+	AllModels           bool           `json:"all_models"`            // 对全部模型启用，否则只对 ModelTTLSeconds 中的模型启用
+	DefaultTTLSeconds   int            `json:"default_ttl_seconds"`   // 默认缓存时长
+	ModelTTLSeconds     map[string]int `json:"model_ttl_seconds"`     // 按模型设置缓存时长，0 表示使用默认时长
+	TemperatureZeroOnly bool           `json:"temperature_zero_only"` // chat 请求只缓存 temperature 显式为 0 的请求
+	HitRatio            float64        `json:"hit_ratio"`             // 命中缓存时的计费倍率
+	MaxBodyBytes        int            `json:"max_body_bytes"`        // 超过该大小的响应不缓存
+
+	SemanticEnabled    bool    `json:"semantic_enabled"`     // 启用语义缓存
+	SemanticThreshold  float64 `json:"semantic_threshold"`   // 命中所需的最小余弦相似度
+	SemanticMaxEntries int     `json:"semantic_max_entries"` // 每个 分组 + 模型 + 参数 下保留的向量数
+	EmbeddingBaseURL   string  `json:"embedding_base_url"`   // OpenAI 兼容的 embeddings 接口地址，如 https://api.openai.com
+	EmbeddingAPIKey    string  `json:"embedding_api_key"`    // embeddings 接口的密钥
+	EmbeddingModel     string  `json:"embedding_model"`      // 计算相似度使用的 embedding 模型
+	EmbeddingTimeoutMs int     `json:"embedding_timeout_ms"` // embeddings 请求超时，超时按未命中处理
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:             false,
+	AllModels:           false,
+	DefaultTTLSeconds:   3600,
+	ModelTTLSeconds:     map[string]int{},
+	TemperatureZeroOnly: true,
+	HitRatio:            0.1,
+	MaxBodyBytes:        1 << 20,
+	SemanticEnabled:     false,
+	SemanticThreshold:   0.95,
+	SemanticMaxEntries:  1000,
+	EmbeddingModel:      "text-embedding-3-small",
+	EmbeddingTimeoutMs:  3000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+// GetResponseCacheSetting 获取响应缓存配置
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// GetResponseCacheTTL 返回模型在令牌设置下的缓存时长，未启用缓存时返回 false
+func GetResponseCacheTTL(modelName string, tokenMode int) (time.Duration, bool) {
+	if !responseCacheSetting.Enabled || tokenMode == ResponseCacheTokenDisable {
+		return 0, false
+	}
+	ttlSeconds, listed := responseCacheSetting.ModelTTLSeconds[modelName]
+	if !listed && !responseCacheSetting.AllModels && tokenMode != ResponseCacheTokenEnabled {
+		return 0, false
+	}
+	if ttlSeconds <= 0 {
+		ttlSeconds = responseCacheSetting.DefaultTTLSeconds
+	}
+	if ttlSeconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(ttlSeconds) * time.Second, true
+}
+
+// GetResponseCacheHitRatio 命中缓存的计费倍率，配置非法时按原价计费
+func GetResponseCacheHitRatio() float64 {
+	if responseCacheSetting.HitRatio < 0 {
+		return 1
+	}
+	return responseCacheSetting.HitRatio
+}
+
+// IsSemanticResponseCacheEnabled 语义缓存需要同时启用响应缓存并配置 embeddings 接口
+func IsSemanticResponseCacheEnabled() bool {
+	return responseCacheSetting.Enabled && responseCacheSetting.SemanticEnabled &&
+		responseCacheSetting.EmbeddingBaseURL != "" && responseCacheSetting.EmbeddingModel != ""
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TaskCallbackSetting 上游任务完成回调（推送）相关配置
+type TaskCallbackSetting struct {
+	Enabled                     bool `json:"enabled"`                        // 提交任务时向支持回调的上游注册回调地址，需配置服务器地址且可被上游访问
+	FallbackPollIntervalSeconds int  `json:"fallback_poll_interval_seconds"` // 已注册回调的任务的兜底轮询间隔
+}
+
+// 默认配置
+var taskCallbackSetting = TaskCallbackSetting{
+	Enabled:                     false,
+	FallbackPollIntervalSeconds: 600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_callback_setting", &taskCallbackSetting)
+}
+
+// GetTaskCallbackSetting 获取上游任务回调配置
+func GetTaskCallbackSetting() *TaskCallbackSetting {
+	return &taskCallbackSetting
+}
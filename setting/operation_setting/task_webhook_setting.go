@@ -0,0 +1,31 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TaskWebhookSetting 异步任务完成回调（callback_url）相关配置
+type TaskWebhookSetting struct {
+	Enabled               bool `json:"enabled"`                 // 是否启用任务完成回调
+	MaxAttempts           int  `json:"max_attempts"`            // 最大投递次数，超过后转入死信
+	InitialBackoffSeconds int  `json:"initial_backoff_seconds"` // 首次重试间隔，之后按指数退避
+	MaxBackoffSeconds     int  `json:"max_backoff_seconds"`     // 重试间隔上限
+	DeliveryConcurrency   int  `json:"delivery_concurrency"`    // 单次调度并发投递数
+}
+
+// 默认配置
+var taskWebhookSetting = TaskWebhookSetting{
+	Enabled:               true,
+	MaxAttempts:           8,
+	InitialBackoffSeconds: 30,
+	MaxBackoffSeconds:     6 * 3600,
+	DeliveryConcurrency:   8,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_webhook_setting", &taskWebhookSetting)
+}
+
+// GetTaskWebhookSetting 获取任务回调配置
+func GetTaskWebhookSetting() *TaskWebhookSetting {
+	return &taskWebhookSetting
+}
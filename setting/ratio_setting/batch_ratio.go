@@ -0,0 +1,36 @@
+package ratio_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// BatchRatioSetting Batch API 折扣倍率配置
+// 批处理请求在分组倍率基础上再乘以该倍率，用于提供异步批处理折扣
+type BatchRatioSetting struct {
+	DefaultRatio float64            `json:"default_ratio"` // 默认批处理倍率，1 表示不打折
+	ModelRatio   map[string]float64 `json:"model_ratio"`   // 按模型覆盖的批处理倍率
+}
+
+var batchRatioSetting = BatchRatioSetting{
+	DefaultRatio: 1,
+	ModelRatio:   map[string]float64{},
+}
+
+func init() {
+	config.GlobalConfig.Register("batch_ratio_setting", &batchRatioSetting)
+}
+
+func GetBatchRatioSetting() *BatchRatioSetting {
+	return &batchRatioSetting
+}
+
+// GetBatchRatio 获取模型的批处理倍率，未配置或配置非法时回退到默认倍率
+func GetBatchRatio(modelName string) float64 {
+	if ratio, ok := batchRatioSetting.ModelRatio[FormatMatchingModelName(modelName)]; ok && ratio >= 0 {
+		return ratio
+	}
+	if batchRatioSetting.DefaultRatio < 0 {
+		return 1
+	}
+	return batchRatioSetting.DefaultRatio
+}
@@ -0,0 +1,41 @@
+package ratio_setting
+
+import (
+	"github.com/QuantumNous/new-api/types"
+)
+
+// 按次计费图片模型的分辨率倍率，键为模型名称，值为 size（如 1792x1024、4K）或 quality（如 hd）到倍率的映射
+// dall-e 系列的尺寸与品质倍率已内置，无需在此配置
+var defaultImageSizeRatio = map[string]map[string]float64{
+	"gemini-3-pro-image-preview": {
+		"4K": 1.8,
+	},
+}
+
+var imageSizeRatioMap = types.NewRWMap[string, map[string]float64]()
+
+func ImageSizeRatio2JSONString() string {
+	return imageSizeRatioMap.MarshalJSONString()
+}
+
+func UpdateImageSizeRatioByJSONString(jsonStr string) error {
+	return types.LoadFromJsonString(imageSizeRatioMap, jsonStr)
+}
+
+// GetImageSizeRatio 返回图片请求的分辨率倍率，size 与 quality 均命中时相乘，未配置时为 1
+func GetImageSizeRatio(name string, size string, quality string) float64 {
+	ratios, ok := imageSizeRatioMap.Get(name)
+	if !ok {
+		return 1
+	}
+	ratio := 1.0
+	for _, key := range []string{size, quality} {
+		if key == "" {
+			continue
+		}
+		if r, ok := ratios[key]; ok && r > 0 {
+			ratio *= r
+		}
+	}
+	return ratio
+}
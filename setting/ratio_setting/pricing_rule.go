@@ -0,0 +1,168 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// PricingTier 按 token 数量分段的倍率，token 数超过 MinTokens 时命中，多档命中时取 MinTokens 最大的一档。
+// 倍率含义与全局倍率一致（补全倍率相对模型倍率），为 0 的字段保持原倍率
+type PricingTier struct {
+	Name            string  `json:"name,omitempty"`
+	MinTokens       int     `json:"min_tokens"`
+	ModelRatio      float64 `json:"model_ratio,omitempty"`
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      float64 `json:"cache_ratio,omitempty"`
+}
+
+// OffPeakWindow 错峰时段，按请求开始时间判断，End 早于 Start 时表示跨零点；Ratio 乘入模型倍率（按次计费时乘入模型价格）
+type OffPeakWindow struct {
+	Name     string  `json:"name,omitempty"`
+	Start    string  `json:"start"`              // HH:MM
+	End      string  `json:"end"`                // HH:MM
+	Timezone string  `json:"timezone,omitempty"` // IANA 时区，为空时使用服务器时区
+	Ratio    float64 `json:"ratio"`
+}
+
+// PricingRule 单个模型的计价规则：输入分段按 prompt tokens（含缓存）匹配，输出分段按 completion tokens 匹配，
+// 输出分段只覆盖补全倍率；GroupOverrides 中存在用户分组时整体替换为该分组的规则
+type PricingRule struct {
+	InputTiers     []PricingTier          `json:"input_tiers,omitempty"`
+	OutputTiers    []PricingTier          `json:"output_tiers,omitempty"`
+	OffPeakWindows []OffPeakWindow        `json:"off_peak_windows,omitempty"`
+	GroupOverrides map[string]PricingRule `json:"group_overrides,omitempty"`
+}
+
+// PricingRuleSetting 按实际用量结算的计价规则，键为模型名称
+type PricingRuleSetting struct {
+	Enabled bool                   `json:"enabled"`
+	Rules   map[string]PricingRule `json:"rules"`
+}
+
+var pricingRuleSetting = PricingRuleSetting{
+	Enabled: false,
+	Rules:   map[string]PricingRule{},
+}
+
+// 时区解析结果缓存，避免每次结算读取时区数据
+var pricingRuleLocations sync.Map // map[string]*time.Location
+
+func init() {
+	config.GlobalConfig.Register("pricing_rule_setting", &pricingRuleSetting)
+}
+
+func GetPricingRuleSetting() *PricingRuleSetting {
+	return &pricingRuleSetting
+}
+
+// PricingRuleResult 规则计算结果，倍率为 0 表示不覆盖
+type PricingRuleResult struct {
+	ModelRatio      float64
+	CompletionRatio float64
+	CacheRatio      float64
+	// 错峰倍率，1 表示不打折
+	OffPeakRatio float64
+	Match        types.PricingRuleMatch
+}
+
+func matchPricingTier(tiers []PricingTier, tokens int) (PricingTier, bool) {
+	var best PricingTier
+	found := false
+	for _, tier := range tiers {
+		if tokens > tier.MinTokens && (!found || tier.MinTokens > best.MinTokens) {
+			best = tier
+			found = true
+		}
+	}
+	return best, found
+}
+
+func tierLabel(tier PricingTier) string {
+	if tier.Name != "" {
+		return tier.Name
+	}
+	return fmt.Sprintf(">%d", tier.MinTokens)
+}
+
+func parseClock(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// inWindow 判断时间是否落在错峰时段内，配置非法时视为不命中
+func (w OffPeakWindow) inWindow(at time.Time) bool {
+	start, ok1 := parseClock(w.Start)
+	end, ok2 := parseClock(w.End)
+	if !ok1 || !ok2 || start == end {
+		return false
+	}
+	if w.Timezone != "" {
+		loc, ok := pricingRuleLocations.Load(w.Timezone)
+		if !ok {
+			parsed, err := time.LoadLocation(w.Timezone)
+			if err != nil {
+				return false
+			}
+			loc, _ = pricingRuleLocations.LoadOrStore(w.Timezone, parsed)
+		}
+		at = at.In(loc.(*time.Location))
+	}
+	minute := at.Hour()*60 + at.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// EvaluatePricingRule 根据实际用量与请求时间计算模型的计价规则，未启用或未命中任何档位时返回 false
+func EvaluatePricingRule(modelName string, group string, promptTokens int, completionTokens int, at time.Time) (PricingRuleResult, bool) {
+	result := PricingRuleResult{OffPeakRatio: 1}
+	if !pricingRuleSetting.Enabled {
+		return result, false
+	}
+	rule, ok := pricingRuleSetting.Rules[modelName]
+	if !ok {
+		rule, ok = pricingRuleSetting.Rules[FormatMatchingModelName(modelName)]
+	}
+	if !ok {
+		return result, false
+	}
+	if override, ok := rule.GroupOverrides[group]; ok {
+		rule = override
+		result.Match.Group = group
+	}
+
+	matched := false
+	if tier, ok := matchPricingTier(rule.InputTiers, promptTokens); ok {
+		result.ModelRatio = tier.ModelRatio
+		result.CompletionRatio = tier.CompletionRatio
+		result.CacheRatio = tier.CacheRatio
+		result.Match.InputTier = tierLabel(tier)
+		matched = true
+	}
+	if tier, ok := matchPricingTier(rule.OutputTiers, completionTokens); ok && tier.CompletionRatio > 0 {
+		result.CompletionRatio = tier.CompletionRatio
+		result.Match.OutputTier = tierLabel(tier)
+		matched = true
+	}
+	for _, window := range rule.OffPeakWindows {
+		if window.Ratio > 0 && window.inWindow(at) {
+			result.OffPeakRatio = window.Ratio
+			result.Match.OffPeakWindow = window.Name
+			if result.Match.OffPeakWindow == "" {
+				result.Match.OffPeakWindow = window.Start + "-" + window.End
+			}
+			result.Match.OffPeakRatio = window.Ratio
+			matched = true
+			break
+		}
+	}
+	return result, matched
+}
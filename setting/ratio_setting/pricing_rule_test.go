@@ -0,0 +1,63 @@
+package ratio_setting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluatePricingRule(t *testing.T) {
+	saved := pricingRuleSetting
+	defer func() { pricingRuleSetting = saved }()
+
+	pricingRuleSetting = PricingRuleSetting{
+		Enabled: true,
+		Rules: map[string]PricingRule{
+			"gemini-2.5-pro": {
+				InputTiers: []PricingTier{
+					{MinTokens: 200000, ModelRatio: 1.25, CompletionRatio: 6},
+					{Name: "long", MinTokens: 500000, ModelRatio: 2},
+				},
+				OutputTiers: []PricingTier{
+					{Name: "verbose", MinTokens: 8000, CompletionRatio: 10},
+				},
+				OffPeakWindows: []OffPeakWindow{
+					{Name: "night", Start: "22:00", End: "06:00", Timezone: "UTC", Ratio: 0.5},
+				},
+				GroupOverrides: map[string]PricingRule{
+					"vip": {},
+				},
+			},
+		},
+	}
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2025, 1, 1, 23, 30, 0, 0, time.UTC)
+
+	_, ok := EvaluatePricingRule("gemini-2.5-pro", "default", 1000, 100, day)
+	assert.False(t, ok)
+
+	result, ok := EvaluatePricingRule("gemini-2.5-pro", "default", 250000, 100, day)
+	assert.True(t, ok)
+	assert.Equal(t, 1.25, result.ModelRatio)
+	assert.Equal(t, 6.0, result.CompletionRatio)
+	assert.Equal(t, ">200000", result.Match.InputTier)
+	assert.Equal(t, 1.0, result.OffPeakRatio)
+
+	result, ok = EvaluatePricingRule("gemini-2.5-pro", "default", 600000, 9000, night)
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, result.ModelRatio)
+	assert.Equal(t, 10.0, result.CompletionRatio)
+	assert.Equal(t, "long", result.Match.InputTier)
+	assert.Equal(t, "verbose", result.Match.OutputTier)
+	assert.Equal(t, 0.5, result.OffPeakRatio)
+	assert.Equal(t, "night", result.Match.OffPeakWindow)
+
+	// 分组覆盖为空规则时不命中任何档位
+	_, ok = EvaluatePricingRule("gemini-2.5-pro", "vip", 600000, 9000, night)
+	assert.False(t, ok)
+
+	pricingRuleSetting.Enabled = false
+	_, ok = EvaluatePricingRule("gemini-2.5-pro", "default", 600000, 9000, night)
+	assert.False(t, ok)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 )
@@ -85,6 +86,8 @@ const (
 	// quota error
 	ErrorCodeInsufficientUserQuota      ErrorCode = "insufficient_user_quota"
 	ErrorCodePreConsumeTokenQuotaFailed ErrorCode = "pre_consume_token_quota_failed"
+	ErrorCodeRateLimitExceeded          ErrorCode = "rate_limit_exceeded"
+	ErrorCodeBudgetExceeded             ErrorCode = "budget_exceeded"
 )
 
 type NewAPIError struct {
@@ -96,6 +99,7 @@ type NewAPIError struct {
 	errorCode      ErrorCode
 	StatusCode     int
 	Metadata       json.RawMessage
+	RetryAfter     time.Duration // 上游限流时 Retry-After 指定的等待时长
 }
 
 // Unwrap enables errors.Is / errors.As to work with NewAPIError by exposing the underlying error.
@@ -25,6 +25,18 @@ type PriceData struct {
 	Quota                int // 按次计费的最终额度（MJ / Task）
 	QuotaToPreConsume    int // 按量计费的预消耗额度
 	GroupRatioInfo       GroupRatioInfo
+	BatchRatio           float64           // 批处理折扣倍率，与分组倍率分开记录，计费时单独相乘
+	HasBatchRatio        bool              // 仅批处理请求为 true
+	PricingRule          *PricingRuleMatch // 结算时命中的计价规则，未命中为 nil
+}
+
+// PricingRuleMatch 结算时命中的计价规则档位，记录到日志中用于对账
+type PricingRuleMatch struct {
+	InputTier     string  `json:"input_tier,omitempty"`
+	OutputTier    string  `json:"output_tier,omitempty"`
+	OffPeakWindow string  `json:"off_peak_window,omitempty"`
+	OffPeakRatio  float64 `json:"off_peak_ratio,omitempty"`
+	Group         string  `json:"group,omitempty"` // 命中分组覆盖规则时的分组
 }
 
 func (p *PriceData) AddOtherRatio(key string, ratio float64) {
@@ -37,6 +49,14 @@ func (p *PriceData) AddOtherRatio(key string, ratio float64) {
 	p.OtherRatios[key] = ratio
 }
 
+// BatchDiscount 返回计费使用的批处理折扣倍率，非批处理请求为 1
+func (p *PriceData) BatchDiscount() float64 {
+	if !p.HasBatchRatio {
+		return 1
+	}
+	return p.BatchRatio
+}
+
 func (p *PriceData) ToSetting() string {
-	return fmt.Sprintf("ModelPrice: %f, ModelRatio: %f, CompletionRatio: %f, CacheRatio: %f, GroupRatio: %f, UsePrice: %t, CacheCreationRatio: %f, CacheCreation5mRatio: %f, CacheCreation1hRatio: %f, QuotaToPreConsume: %d, ImageRatio: %f, AudioRatio: %f, AudioCompletionRatio: %f", p.ModelPrice, p.ModelRatio, p.CompletionRatio, p.CacheRatio, p.GroupRatioInfo.GroupRatio, p.UsePrice, p.CacheCreationRatio, p.CacheCreation5mRatio, p.CacheCreation1hRatio, p.QuotaToPreConsume, p.ImageRatio, p.AudioRatio, p.AudioCompletionRatio)
+	return fmt.Sprintf("ModelPrice: %f, ModelRatio: %f, CompletionRatio: %f, CacheRatio: %f, GroupRatio: %f, BatchRatio: %f, UsePrice: %t, CacheCreationRatio: %f, CacheCreation5mRatio: %f, CacheCreation1hRatio: %f, QuotaToPreConsume: %d, ImageRatio: %f, AudioRatio: %f, AudioCompletionRatio: %f", p.ModelPrice, p.ModelRatio, p.CompletionRatio, p.CacheRatio, p.GroupRatioInfo.GroupRatio, p.BatchDiscount(), p.UsePrice, p.CacheCreationRatio, p.CacheCreation5mRatio, p.CacheCreation1hRatio, p.QuotaToPreConsume, p.ImageRatio, p.AudioRatio, p.AudioCompletionRatio)
 }
@@ -33,6 +33,8 @@ import PasswordResetForm from './components/auth/PasswordResetForm';
 import PasswordResetConfirm from './components/auth/PasswordResetConfirm';
 import Channel from './pages/Channel';
 import Token from './pages/Token';
+import Organization from './pages/Organization';
+import Budget from './pages/Budget';
 import Redemption from './pages/Redemption';
 import TopUp from './pages/TopUp';
 import Log from './pages/Log';
@@ -147,6 +149,22 @@ function App() {
             </PrivateRoute>
           }
         />
+        <Route
+          path='/console/organization'
+          element={
+            <PrivateRoute>
+              <Organization />
+            </PrivateRoute>
+          }
+        />
+        <Route
+          path='/console/budget'
+          element={
+            <PrivateRoute>
+              <Budget />
+            </PrivateRoute>
+          }
+        />
         <Route
           path='/console/playground'
           element={
@@ -0,0 +1,438 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import {
+  Button,
+  Card,
+  Empty,
+  Input,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Progress,
+  Select,
+  Space,
+  Switch,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import {
+  API,
+  getUserIdFromLocalStorage,
+  isAdmin,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+
+const { Text, Title } = Typography;
+
+const SCOPE_COLORS = {
+  token: 'blue',
+  user: 'green',
+  group: 'orange',
+};
+
+const BudgetPage = () => {
+  const { t } = useTranslation();
+  const admin = isAdmin();
+  const [budgets, setBudgets] = useState([]);
+  const [tokens, setTokens] = useState([]);
+  const [groups, setGroups] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [budgetModal, setBudgetModal] = useState(null);
+
+  const scopeOptions = [
+    { value: 'token', label: t('令牌') },
+    ...(admin
+      ? [
+          { value: 'user', label: t('用户') },
+          { value: 'group', label: t('分组') },
+        ]
+      : []),
+  ];
+  const periodOptions = [
+    { value: 'daily', label: t('每日') },
+    { value: 'weekly', label: t('每周') },
+    { value: 'monthly', label: t('每月') },
+  ];
+  const scopeLabel = (scope) =>
+    scopeOptions.find((s) => s.value === scope)?.label || scope;
+  const periodLabel = (period) =>
+    periodOptions.find((p) => p.value === period)?.label || period;
+
+  // 普通用户只能管理自己为令牌创建的预算，管理员通过管理接口管理全部预算
+  const baseUrl = admin ? '/api/budget' : '/api/budget/self';
+  const canEdit = (record) =>
+    admin ||
+    (record.scope === 'token' &&
+      record.user_id === getUserIdFromLocalStorage() &&
+      record.creator_id === getUserIdFromLocalStorage());
+
+  const loadBudgets = async () => {
+    setLoading(true);
+    const res = await API.get(
+      admin ? '/api/budget/?p=1&page_size=100' : '/api/budget/self/',
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setBudgets((admin ? data.items : data) || []);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  const loadOptions = async () => {
+    const res = await API.get('/api/token/?p=1&size=100');
+    const { success, data } = res.data;
+    if (success) {
+      setTokens(data.items || []);
+    }
+    if (admin) {
+      const groupRes = await API.get('/api/group/');
+      if (groupRes.data.success) {
+        setGroups(groupRes.data.data || []);
+      }
+    }
+  };
+
+  useEffect(() => {
+    loadBudgets();
+    loadOptions();
+  }, []);
+
+  const saveBudget = async () => {
+    const payload = {
+      scope: budgetModal.scope,
+      target_id: parseInt(budgetModal.target_id, 10) || 0,
+      group_name: budgetModal.group_name || '',
+      period: budgetModal.period,
+      quota_limit: parseInt(budgetModal.quota_limit, 10) || 0,
+      hard_limit: !!budgetModal.hard_limit,
+      alert_thresholds: budgetModal.alert_thresholds || '',
+      enabled: !!budgetModal.enabled,
+    };
+    const res = budgetModal.id
+      ? await API.put(`${baseUrl}/${budgetModal.id}`, payload)
+      : await API.post(`${baseUrl}/`, payload);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('保存成功'));
+      setBudgetModal(null);
+      await loadBudgets();
+    } else {
+      showError(message);
+    }
+  };
+
+  const deleteBudget = async (id) => {
+    const res = await API.delete(`${baseUrl}/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadBudgets();
+    } else {
+      showError(message);
+    }
+  };
+
+  const renderTarget = (record) => {
+    if (record.scope === 'group') return record.group_name;
+    if (record.scope === 'token') {
+      const token = tokens.find((tk) => tk.id === record.target_id);
+      return token ? token.name : `#${record.target_id}`;
+    }
+    return `#${record.target_id}`;
+  };
+
+  const columns = [
+    {
+      title: t('范围'),
+      dataIndex: 'scope',
+      render: (scope) => (
+        <Tag color={SCOPE_COLORS[scope] || 'grey'} shape='circle'>
+          {scopeLabel(scope)}
+        </Tag>
+      ),
+    },
+    {
+      title: t('对象'),
+      dataIndex: 'target_id',
+      render: (_, record) => renderTarget(record),
+    },
+    {
+      title: t('周期'),
+      dataIndex: 'period',
+      render: (period) => periodLabel(period),
+    },
+    {
+      title: t('本周期用量'),
+      dataIndex: 'used_quota',
+      render: (used, record) => (
+        <div style={{ minWidth: 160 }}>
+          <Text size='small'>
+            {renderQuota(used)} / {renderQuota(record.quota_limit)}
+          </Text>
+          <Progress
+            percent={Math.min(
+              100,
+              Math.round((used * 100) / (record.quota_limit || 1)),
+            )}
+            size='small'
+          />
+        </div>
+      ),
+    },
+    {
+      title: t('超限处理'),
+      dataIndex: 'hard_limit',
+      render: (hard) =>
+        hard ? (
+          <Tag color='red' shape='circle'>
+            {t('拒绝请求')}
+          </Tag>
+        ) : (
+          <Tag color='grey' shape='circle'>
+            {t('仅提醒')}
+          </Tag>
+        ),
+    },
+    {
+      title: t('提醒阈值'),
+      dataIndex: 'alert_thresholds',
+      render: (thresholds) =>
+        thresholds
+          ? thresholds
+              .split(',')
+              .map((v) => `${v}%`)
+              .join(', ')
+          : '-',
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'enabled',
+      render: (enabled) =>
+        enabled ? (
+          <Tag color='green' shape='circle'>
+            {t('已启用')}
+          </Tag>
+        ) : (
+          <Tag color='grey' shape='circle'>
+            {t('已禁用')}
+          </Tag>
+        ),
+    },
+    {
+      title: t('周期开始'),
+      dataIndex: 'period_start',
+      render: (time) => timestamp2string(time),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        canEdit(record) ? (
+          <Space>
+            <Button size='small' onClick={() => setBudgetModal({ ...record })}>
+              {t('编辑')}
+            </Button>
+            <Popconfirm
+              title={t('确定要删除该预算吗？')}
+              onConfirm={() => deleteBudget(record.id)}
+            >
+              <Button size='small' type='danger'>
+                {t('删除')}
+              </Button>
+            </Popconfirm>
+          </Space>
+        ) : null,
+    },
+  ];
+
+  const renderTargetInput = () => {
+    if (budgetModal.scope === 'token') {
+      return (
+        <Select
+          value={budgetModal.target_id || undefined}
+          disabled={!!budgetModal.id}
+          onChange={(target_id) =>
+            setBudgetModal({ ...budgetModal, target_id })
+          }
+          optionList={tokens.map((token) => ({
+            value: token.id,
+            label: token.name,
+          }))}
+          placeholder={t('请选择令牌')}
+          style={{ width: '100%' }}
+        />
+      );
+    }
+    if (budgetModal.scope === 'group') {
+      return (
+        <Select
+          value={budgetModal.group_name || undefined}
+          disabled={!!budgetModal.id}
+          onChange={(group_name) =>
+            setBudgetModal({ ...budgetModal, group_name })
+          }
+          optionList={groups.map((group) => ({ value: group, label: group }))}
+          placeholder={t('请选择分组')}
+          style={{ width: '100%' }}
+        />
+      );
+    }
+    return (
+      <InputNumber
+        value={budgetModal.target_id}
+        disabled={!!budgetModal.id}
+        onChange={(target_id) => setBudgetModal({ ...budgetModal, target_id })}
+        min={1}
+        prefix={t('用户 ID')}
+        style={{ width: '100%' }}
+      />
+    );
+  };
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <Card className='!rounded-2xl shadow-sm border-0'>
+        <div className='flex flex-wrap items-center justify-between gap-2 mb-4'>
+          <Space vertical align='start' spacing={2}>
+            <Title heading={5} className='m-0'>
+              {t('预算管理')}
+            </Title>
+            <Text type='tertiary' size='small'>
+              {t(
+                '预算按周期自动重置，达到提醒阈值时通过通知设置中的方式提醒，开启硬上限后超出预算的请求将被拒绝',
+              )}
+            </Text>
+          </Space>
+          <Button
+            theme='solid'
+            onClick={() =>
+              setBudgetModal({
+                scope: 'token',
+                period: 'monthly',
+                quota_limit: 0,
+                hard_limit: true,
+                alert_thresholds: '80,95',
+                enabled: true,
+              })
+            }
+          >
+            {t('添加预算')}
+          </Button>
+        </div>
+
+        {budgets.length === 0 && !loading ? (
+          <Empty description={t('暂无预算')} />
+        ) : (
+          <Table
+            rowKey='id'
+            columns={columns}
+            dataSource={budgets}
+            loading={loading}
+            pagination={false}
+            size='small'
+          />
+        )}
+      </Card>
+
+      <Modal
+        title={budgetModal?.id ? t('编辑预算') : t('添加预算')}
+        visible={!!budgetModal}
+        onOk={saveBudget}
+        onCancel={() => setBudgetModal(null)}
+      >
+        {budgetModal && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Select
+              value={budgetModal.scope}
+              disabled={!!budgetModal.id}
+              onChange={(scope) =>
+                setBudgetModal({
+                  ...budgetModal,
+                  scope,
+                  target_id: 0,
+                  group_name: '',
+                })
+              }
+              optionList={scopeOptions}
+              style={{ width: '100%' }}
+            />
+            {renderTargetInput()}
+            <Select
+              value={budgetModal.period}
+              onChange={(period) => setBudgetModal({ ...budgetModal, period })}
+              optionList={periodOptions}
+              style={{ width: '100%' }}
+            />
+            <InputNumber
+              value={budgetModal.quota_limit}
+              onChange={(quota_limit) =>
+                setBudgetModal({ ...budgetModal, quota_limit })
+              }
+              min={0}
+              prefix={t('预算额度')}
+              style={{ width: '100%' }}
+            />
+            <Text type='tertiary' size='small'>
+              {renderQuotaWithPrompt(budgetModal.quota_limit || 0)}
+            </Text>
+            <Input
+              value={budgetModal.alert_thresholds}
+              onChange={(alert_thresholds) =>
+                setBudgetModal({ ...budgetModal, alert_thresholds })
+              }
+              prefix={t('提醒阈值')}
+              suffix='%'
+              placeholder='80,95'
+            />
+            <Space>
+              <Switch
+                checked={!!budgetModal.hard_limit}
+                onChange={(hard_limit) =>
+                  setBudgetModal({ ...budgetModal, hard_limit })
+                }
+              />
+              <Text>{t('硬上限（超出后拒绝请求）')}</Text>
+            </Space>
+            <Space>
+              <Switch
+                checked={!!budgetModal.enabled}
+                onChange={(enabled) =>
+                  setBudgetModal({ ...budgetModal, enabled })
+                }
+              />
+              <Text>{t('启用')}</Text>
+            </Space>
+          </Space>
+        )}
+      </Modal>
+    </div>
+  );
+};
+
+export default BudgetPage;
@@ -36,6 +36,8 @@ const routerMap = {
   token: '/console/token',
   redemption: '/console/redemption',
   topup: '/console/topup',
+  organization: '/console/organization',
+  budget: '/console/budget',
   user: '/console/user',
   subscription: '/console/subscription',
   log: '/console/log',
@@ -129,6 +131,16 @@ const SiderBar = ({ onNavigate = () => {} }) => {
         itemKey: 'topup',
         to: '/topup',
       },
+      {
+        text: t('组织管理'),
+        itemKey: 'organization',
+        to: '/organization',
+      },
+      {
+        text: t('预算管理'),
+        itemKey: 'budget',
+        to: '/budget',
+      },
       {
         text: t('个人设置'),
         itemKey: 'personal',
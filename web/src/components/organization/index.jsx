@@ -0,0 +1,597 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useMemo, useState } from 'react';
+import {
+  Button,
+  Card,
+  DatePicker,
+  Empty,
+  Input,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Select,
+  Space,
+  Switch,
+  Table,
+  TabPane,
+  Tabs,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { useTranslation } from 'react-i18next';
+import {
+  API,
+  getUserIdFromLocalStorage,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+
+const { Text, Title } = Typography;
+
+const ROLE_COLORS = {
+  owner: 'red',
+  admin: 'orange',
+  member: 'blue',
+  billing_viewer: 'grey',
+};
+
+const canManage = (role) => role === 'owner' || role === 'admin';
+const canViewBilling = (role) =>
+  role === 'owner' || role === 'admin' || role === 'billing_viewer';
+
+const OrganizationPage = () => {
+  const { t } = useTranslation();
+  const [orgs, setOrgs] = useState([]);
+  const [orgId, setOrgId] = useState(null);
+  const [members, setMembers] = useState([]);
+  const [tokens, setTokens] = useState([]);
+  const [usage, setUsage] = useState([]);
+  const [usageRange, setUsageRange] = useState([]);
+  const [loading, setLoading] = useState(false);
+
+  const [createVisible, setCreateVisible] = useState(false);
+  const [orgName, setOrgName] = useState('');
+  const [transferVisible, setTransferVisible] = useState(false);
+  const [transferQuota, setTransferQuota] = useState(0);
+  const [memberModal, setMemberModal] = useState(null);
+
+  const roleOptions = [
+    { value: 'admin', label: t('管理员') },
+    { value: 'member', label: t('成员') },
+    { value: 'billing_viewer', label: t('账单查看者') },
+  ];
+  const roleLabel = (role) =>
+    role === 'owner'
+      ? t('所有者')
+      : roleOptions.find((r) => r.value === role)?.label || role;
+
+  const currentOrg = useMemo(
+    () => orgs.find((org) => org.id === orgId),
+    [orgs, orgId],
+  );
+
+  const loadOrgs = async (selectId) => {
+    const res = await API.get('/api/org/self');
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    setOrgs(data || []);
+    if (selectId && data.some((org) => org.id === selectId)) {
+      setOrgId(selectId);
+    } else if (data.length > 0) {
+      setOrgId(data[0].id);
+    } else {
+      setOrgId(null);
+    }
+  };
+
+  const loadMembers = async (id) => {
+    const res = await API.get(`/api/org/${id}/members`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setMembers(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadTokens = async (id) => {
+    const res = await API.get(`/api/org/${id}/tokens`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setTokens(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadUsage = async (id, range = usageRange) => {
+    const params = new URLSearchParams();
+    if (range?.[0]) {
+      params.set('start_timestamp', Math.floor(range[0].getTime() / 1000));
+    }
+    if (range?.[1]) {
+      params.set('end_timestamp', Math.floor(range[1].getTime() / 1000));
+    }
+    const res = await API.get(`/api/org/${id}/usage?${params.toString()}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setUsage(data.items || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const refresh = async () => {
+    if (!orgId) return;
+    setLoading(true);
+    await Promise.all([
+      loadMembers(orgId),
+      loadTokens(orgId),
+      canViewBilling(currentOrg?.role) ? loadUsage(orgId) : Promise.resolve(),
+    ]);
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    loadOrgs();
+  }, []);
+
+  useEffect(() => {
+    refresh();
+  }, [orgId, currentOrg?.role]);
+
+  const createOrg = async () => {
+    const res = await API.post('/api/org/', { name: orgName });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(t('创建成功'));
+      setCreateVisible(false);
+      setOrgName('');
+      await loadOrgs(data.id);
+    } else {
+      showError(message);
+    }
+  };
+
+  const deleteOrg = async () => {
+    const res = await API.delete(`/api/org/${orgId}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadOrgs();
+    } else {
+      showError(message);
+    }
+  };
+
+  const leaveOrg = async () => {
+    const userId = getUserIdFromLocalStorage();
+    const res = await API.delete(`/api/org/${orgId}/members/${userId}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('操作成功完成！'));
+      await loadOrgs();
+    } else {
+      showError(message);
+    }
+  };
+
+  const transfer = async () => {
+    const res = await API.post(`/api/org/${orgId}/transfer`, {
+      quota: parseInt(transferQuota, 10),
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('划转成功'));
+      setTransferVisible(false);
+      setTransferQuota(0);
+      await loadOrgs(orgId);
+    } else {
+      showError(message);
+    }
+  };
+
+  const saveMember = async () => {
+    const payload = {
+      username: memberModal.username,
+      role: memberModal.role,
+      quota_limit: parseInt(memberModal.quota_limit, 10) || 0,
+      reset_used_quota: !!memberModal.reset_used_quota,
+    };
+    const res = memberModal.user_id
+      ? await API.put(
+          `/api/org/${orgId}/members/${memberModal.user_id}`,
+          payload,
+        )
+      : await API.post(`/api/org/${orgId}/members`, payload);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('保存成功'));
+      setMemberModal(null);
+      await loadMembers(orgId);
+    } else {
+      showError(message);
+    }
+  };
+
+  const removeMember = async (userId) => {
+    const res = await API.delete(`/api/org/${orgId}/members/${userId}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadMembers(orgId);
+    } else {
+      showError(message);
+    }
+  };
+
+  const deleteToken = async (tokenId) => {
+    const res = await API.delete(`/api/org/${orgId}/tokens/${tokenId}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadTokens(orgId);
+    } else {
+      showError(message);
+    }
+  };
+
+  const memberColumns = [
+    { title: t('用户名'), dataIndex: 'username' },
+    {
+      title: t('角色'),
+      dataIndex: 'role',
+      render: (role) => (
+        <Tag color={ROLE_COLORS[role] || 'grey'} shape='circle'>
+          {roleLabel(role)}
+        </Tag>
+      ),
+    },
+    {
+      title: t('消费上限'),
+      dataIndex: 'quota_limit',
+      render: (limit) => (limit > 0 ? renderQuota(limit) : t('不限')),
+    },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (used) => renderQuota(used),
+    },
+    {
+      title: t('加入时间'),
+      dataIndex: 'created_time',
+      render: (time) => timestamp2string(time),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        canManage(currentOrg?.role) && record.role !== 'owner' ? (
+          <Space>
+            <Button size='small' onClick={() => setMemberModal({ ...record })}>
+              {t('编辑')}
+            </Button>
+            <Popconfirm
+              title={t('确定要移除该成员吗？')}
+              onConfirm={() => removeMember(record.user_id)}
+            >
+              <Button size='small' type='danger'>
+                {t('移除')}
+              </Button>
+            </Popconfirm>
+          </Space>
+        ) : null,
+    },
+  ];
+
+  const tokenColumns = [
+    { title: t('名称'), dataIndex: 'name' },
+    {
+      title: t('创建者'),
+      dataIndex: 'user_id',
+      render: (userId) =>
+        members.find((m) => m.user_id === userId)?.username || userId,
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (status) =>
+        status === 1 ? (
+          <Tag color='green' shape='circle'>
+            {t('已启用')}
+          </Tag>
+        ) : (
+          <Tag color='grey' shape='circle'>
+            {t('已禁用')}
+          </Tag>
+        ),
+    },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (used) => renderQuota(used),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) =>
+        canManage(currentOrg?.role) ? (
+          <Popconfirm
+            title={t('确定是否要删除此令牌？')}
+            onConfirm={() => deleteToken(record.id)}
+          >
+            <Button size='small' type='danger'>
+              {t('删除')}
+            </Button>
+          </Popconfirm>
+        ) : null,
+    },
+  ];
+
+  const usageColumns = [
+    { title: t('用户名'), dataIndex: 'username' },
+    { title: t('模型'), dataIndex: 'model_name' },
+    { title: t('请求次数'), dataIndex: 'count' },
+    { title: t('输入'), dataIndex: 'prompt_tokens' },
+    { title: t('输出'), dataIndex: 'completion_tokens' },
+    {
+      title: t('消费额度'),
+      dataIndex: 'quota',
+      render: (quota) => renderQuota(quota),
+    },
+  ];
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <Card className='!rounded-2xl shadow-sm border-0'>
+        <div className='flex flex-wrap items-center justify-between gap-2 mb-4'>
+          <Space wrap>
+            <Title heading={5} className='m-0'>
+              {t('组织管理')}
+            </Title>
+            {orgs.length > 0 && (
+              <Select
+                value={orgId}
+                onChange={setOrgId}
+                style={{ width: 220 }}
+                optionList={orgs.map((org) => ({
+                  value: org.id,
+                  label: org.name,
+                }))}
+              />
+            )}
+          </Space>
+          <Button theme='solid' onClick={() => setCreateVisible(true)}>
+            {t('创建组织')}
+          </Button>
+        </div>
+
+        {!currentOrg ? (
+          <Empty description={t('您还没有加入任何组织')} />
+        ) : (
+          <>
+            <div className='flex flex-wrap items-center justify-between gap-2 mb-4'>
+              <Space wrap>
+                <Tag color={ROLE_COLORS[currentOrg.role]} shape='circle'>
+                  {roleLabel(currentOrg.role)}
+                </Tag>
+                <Text>
+                  {t('组织余额')}: {renderQuota(currentOrg.quota)}
+                </Text>
+                <Text type='tertiary'>
+                  {t('已用额度')}: {renderQuota(currentOrg.used_quota)}
+                </Text>
+                {currentOrg.member_quota_limit > 0 && (
+                  <Text type='tertiary'>
+                    {t('我的消费上限')}:{' '}
+                    {renderQuota(currentOrg.member_used_quota)} /{' '}
+                    {renderQuota(currentOrg.member_quota_limit)}
+                  </Text>
+                )}
+                {currentOrg.status !== 1 && (
+                  <Tag color='grey' shape='circle'>
+                    {t('已禁用')}
+                  </Tag>
+                )}
+              </Space>
+              <Space wrap>
+                <Button onClick={() => setTransferVisible(true)}>
+                  {t('划转额度')}
+                </Button>
+                {canManage(currentOrg.role) && (
+                  <Button
+                    onClick={() =>
+                      setMemberModal({
+                        username: '',
+                        role: 'member',
+                        quota_limit: 0,
+                      })
+                    }
+                  >
+                    {t('添加成员')}
+                  </Button>
+                )}
+                {currentOrg.role === 'owner' ? (
+                  <Popconfirm
+                    title={t('确定要删除该组织吗？')}
+                    content={t('组织令牌将被删除，剩余额度将退回所有者钱包')}
+                    onConfirm={deleteOrg}
+                  >
+                    <Button type='danger'>{t('删除组织')}</Button>
+                  </Popconfirm>
+                ) : (
+                  <Popconfirm
+                    title={t('确定要退出该组织吗？')}
+                    onConfirm={leaveOrg}
+                  >
+                    <Button type='danger'>{t('退出组织')}</Button>
+                  </Popconfirm>
+                )}
+              </Space>
+            </div>
+
+            <Tabs type='line'>
+              <TabPane tab={t('成员')} itemKey='members'>
+                <Table
+                  rowKey='id'
+                  columns={memberColumns}
+                  dataSource={members}
+                  loading={loading}
+                  pagination={false}
+                  size='small'
+                />
+              </TabPane>
+              <TabPane tab={t('组织令牌')} itemKey='tokens'>
+                <Text type='tertiary' size='small'>
+                  {t('在令牌管理中创建令牌时选择该组织，即可使用组织钱包')}
+                </Text>
+                <Table
+                  rowKey='id'
+                  columns={tokenColumns}
+                  dataSource={tokens}
+                  loading={loading}
+                  pagination={false}
+                  size='small'
+                />
+              </TabPane>
+              {canViewBilling(currentOrg.role) && (
+                <TabPane tab={t('用量报表')} itemKey='usage'>
+                  <Space className='mb-2'>
+                    <DatePicker
+                      type='dateTimeRange'
+                      value={usageRange}
+                      onChange={(range) => {
+                        setUsageRange(range || []);
+                        loadUsage(orgId, range || []);
+                      }}
+                    />
+                  </Space>
+                  <Table
+                    rowKey={(record) =>
+                      `${record.user_id}-${record.model_name}`
+                    }
+                    columns={usageColumns}
+                    dataSource={usage}
+                    loading={loading}
+                    pagination={false}
+                    size='small'
+                  />
+                </TabPane>
+              )}
+            </Tabs>
+          </>
+        )}
+      </Card>
+
+      <Modal
+        title={t('创建组织')}
+        visible={createVisible}
+        onOk={createOrg}
+        onCancel={() => setCreateVisible(false)}
+      >
+        <Input
+          value={orgName}
+          onChange={setOrgName}
+          placeholder={t('请输入组织名称')}
+        />
+      </Modal>
+
+      <Modal
+        title={t('划转额度')}
+        visible={transferVisible}
+        onOk={transfer}
+        onCancel={() => setTransferVisible(false)}
+      >
+        <InputNumber
+          value={transferQuota}
+          onChange={setTransferQuota}
+          min={0}
+          style={{ width: '100%' }}
+        />
+        <Text type='tertiary' size='small'>
+          {renderQuotaWithPrompt(transferQuota || 0)}
+        </Text>
+        <div>
+          <Text type='tertiary' size='small'>
+            {t('从个人钱包转入组织钱包，转入后不可撤回')}
+          </Text>
+        </div>
+      </Modal>
+
+      <Modal
+        title={memberModal?.user_id ? t('编辑成员') : t('添加成员')}
+        visible={!!memberModal}
+        onOk={saveMember}
+        onCancel={() => setMemberModal(null)}
+      >
+        {memberModal && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Input
+              value={memberModal.username}
+              disabled={!!memberModal.user_id}
+              onChange={(username) =>
+                setMemberModal({ ...memberModal, username })
+              }
+              placeholder={t('请输入用户名')}
+            />
+            <Select
+              value={memberModal.role}
+              onChange={(role) => setMemberModal({ ...memberModal, role })}
+              optionList={roleOptions}
+              style={{ width: '100%' }}
+            />
+            <InputNumber
+              value={memberModal.quota_limit}
+              onChange={(quota_limit) =>
+                setMemberModal({ ...memberModal, quota_limit })
+              }
+              min={0}
+              prefix={t('消费上限')}
+              style={{ width: '100%' }}
+            />
+            <Text type='tertiary' size='small'>
+              {t('成员累计消费上限，0 表示不限')}
+            </Text>
+            {memberModal.user_id && (
+              <Space>
+                <Switch
+                  checked={!!memberModal.reset_used_quota}
+                  onChange={(reset_used_quota) =>
+                    setMemberModal({ ...memberModal, reset_used_quota })
+                  }
+                />
+                <Text>{t('清零已用额度')}</Text>
+              </Space>
+            )}
+          </Space>
+        )}
+      </Modal>
+    </div>
+  );
+};
+
+export default OrganizationPage;
@@ -88,6 +88,7 @@ const PersonalSetting = () => {
     gotifyPriority: 5,
     acceptUnsetModelRatioModel: false,
     recordIpLog: false,
+    statementEmailEnabled: false,
   });
 
   useEffect(() => {
@@ -161,6 +162,7 @@ const PersonalSetting = () => {
         acceptUnsetModelRatioModel:
           settings.accept_unset_model_ratio_model || false,
         recordIpLog: settings.record_ip_log || false,
+        statementEmailEnabled: settings.statement_email_enabled || false,
       });
     }
   }, [userState?.user?.setting]);
@@ -429,6 +431,7 @@ const PersonalSetting = () => {
         accept_unset_model_ratio_model:
           notificationSettings.acceptUnsetModelRatioModel,
         record_ip_log: notificationSettings.recordIpLog,
+        statement_email_enabled: notificationSettings.statementEmailEnabled,
       });
 
       if (res.data.success) {
@@ -41,6 +41,7 @@ const RatioSetting = () => {
     GroupRatio: '',
     GroupGroupRatio: '',
     ImageRatio: '',
+    ImageSizeRatio: '',
     AudioRatio: '',
     AudioCompletionRatio: '',
     AutoGroups: '',
@@ -48,6 +49,8 @@ const RatioSetting = () => {
     ExposeRatioEnabled: false,
     UserUsableGroups: '',
     'group_ratio_setting.group_special_usable_group': '',
+    'pricing_rule_setting.enabled': false,
+    'pricing_rule_setting.rules': '',
   });
 
   const [loading, setLoading] = useState(false);
@@ -65,7 +68,13 @@ const RatioSetting = () => {
             // 如果后端返回的不是合法 JSON，直接展示
           }
         }
-        if (['DefaultUseAutoGroup', 'ExposeRatioEnabled'].includes(item.key)) {
+        if (
+          [
+            'DefaultUseAutoGroup',
+            'ExposeRatioEnabled',
+            'pricing_rule_setting.enabled',
+          ].includes(item.key)
+        ) {
           newInputs[item.key] = toBoolean(item.value);
         } else {
           newInputs[item.key] = item.value;
@@ -79,6 +79,8 @@ const NotificationSettings = ({
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
+      budget: true,
       personal: true,
     },
     admin: {
@@ -164,7 +166,13 @@ const NotificationSettings = ({
         midjourney: true,
         task: true,
       },
-      personal: { enabled: true, topup: true, personal: true },
+      personal: {
+        enabled: true,
+        topup: true,
+        organization: true,
+        budget: true,
+        personal: true,
+      },
       admin: {
         enabled: true,
         channel: true,
@@ -278,6 +286,16 @@ const NotificationSettings = ({
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('团队共享钱包与成员'),
+        },
+        {
+          key: 'budget',
+          title: t('预算管理'),
+          description: t('周期预算与用量提醒'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -728,6 +746,17 @@ const NotificationSettings = ({
                     </div>
                   </>
                 )}
+
+                <Form.Switch
+                  field='statementEmailEnabled'
+                  label={t('月度对账单邮件')}
+                  checkedText={t('开')}
+                  uncheckedText={t('关')}
+                  onChange={(value) =>
+                    handleFormChange('statementEmailEnabled', value)
+                  }
+                  extraText={t('每月初将上月对账单发送到您的账户邮箱')}
+                />
               </div>
             </TabPane>
 
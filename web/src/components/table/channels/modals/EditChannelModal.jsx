@@ -128,6 +128,31 @@ const MODEL_FETCHABLE_TYPES = new Set([
   1, 4, 14, 34, 17, 26, 27, 24, 47, 25, 20, 23, 31, 40, 42, 48, 43,
 ]);
 
+// 多密钥按序号配置的权重、RPM/TPM 上限，编辑时以 JSON 文本展示
+function formatMultiKeyMap(value) {
+  if (!value || Object.keys(value).length === 0) {
+    return '';
+  }
+  return JSON.stringify(value, null, 2);
+}
+
+function parseMultiKeyMap(value) {
+  if (value === undefined) {
+    return undefined;
+  }
+  if (typeof value !== 'string') {
+    return value || {};
+  }
+  if (value.trim() === '') {
+    return {};
+  }
+  const parsed = JSON.parse(value);
+  if (!parsed || typeof parsed !== 'object' || Array.isArray(parsed)) {
+    throw new Error('invalid multi key map');
+  }
+  return parsed;
+}
+
 function type2secretPrompt(type) {
   // inputs.type === 15 ? '按照如下格式输入：APIKey|SecretKey' : (inputs.type === 18 ? '按照如下格式输入：APPID|APISecret|APIKey' : '请输入渠道对应的鉴权密钥')
   switch (type) {
@@ -194,6 +219,10 @@ const EditChannelModal = (props) => {
     vertex_key_type: 'json',
     // 仅 AWS: 密钥格式和区域（存入 settings.aws_key_type 和 settings.aws_region）
     aws_key_type: 'ak_sk',
+    // 仅 OpenAI: 上游重排序格式（存入 settings.rerank_format），为空时自动推断
+    rerank_format: '',
+    // 仅 Sora: 上游任务 webhook 签名密钥（存入 settings.task_webhook_secret）
+    task_webhook_secret: '',
     // 企业账户设置
     is_enterprise_account: false,
     // 字段透传控制默认值
@@ -793,6 +822,13 @@ const EditChannelModal = (props) => {
         const modeVal = chInfo.multi_key_mode || 'random';
         setMultiKeyMode(modeVal);
         data.multi_key_mode = modeVal;
+        data.multi_key_weights = formatMultiKeyMap(chInfo.multi_key_weights);
+        data.multi_key_rpm_limits = formatMultiKeyMap(
+          chInfo.multi_key_rpm_limits,
+        );
+        data.multi_key_tpm_limits = formatMultiKeyMap(
+          chInfo.multi_key_tpm_limits,
+        );
       } else {
         setBatch(false);
         setMultiToSingle(false);
@@ -837,6 +873,8 @@ const EditChannelModal = (props) => {
           data.vertex_key_type = parsedSettings.vertex_key_type || 'json';
           // 读取 AWS 密钥格式和区域
           data.aws_key_type = parsedSettings.aws_key_type || 'ak_sk';
+          data.rerank_format = parsedSettings.rerank_format || '';
+          data.task_webhook_secret = parsedSettings.task_webhook_secret || '';
           // 读取企业账户设置
           data.is_enterprise_account =
             parsedSettings.openrouter_enterprise === true;
@@ -856,6 +894,8 @@ const EditChannelModal = (props) => {
           data.region = '';
           data.vertex_key_type = 'json';
           data.aws_key_type = 'ak_sk';
+          data.rerank_format = '';
+          data.task_webhook_secret = '';
           data.is_enterprise_account = false;
           data.allow_service_tier = false;
           data.disable_store = false;
@@ -868,6 +908,8 @@ const EditChannelModal = (props) => {
         // 兼容历史数据：老渠道没有 settings 时，默认按 json 展示
         data.vertex_key_type = 'json';
         data.aws_key_type = 'ak_sk';
+        data.rerank_format = '';
+        data.task_webhook_secret = '';
         data.is_enterprise_account = false;
         data.allow_service_tier = false;
         data.disable_store = false;
@@ -1665,6 +1707,11 @@ const EditChannelModal = (props) => {
       settings.allow_service_tier = localInputs.allow_service_tier === true;
       // 仅 OpenAI 渠道需要 store / safety_identifier / include_obfuscation
       if (localInputs.type === 1) {
+        if (localInputs.rerank_format) {
+          settings.rerank_format = localInputs.rerank_format;
+        } else {
+          delete settings.rerank_format;
+        }
         settings.disable_store = localInputs.disable_store === true;
         settings.allow_safety_identifier =
           localInputs.allow_safety_identifier === true;
@@ -1677,6 +1724,13 @@ const EditChannelModal = (props) => {
       }
     }
 
+    // type === 55 (Sora): 上游任务 webhook 签名密钥
+    if (localInputs.type === 55 && localInputs.task_webhook_secret) {
+      settings.task_webhook_secret = localInputs.task_webhook_secret.trim();
+    } else {
+      delete settings.task_webhook_secret;
+    }
+
     localInputs.settings = JSON.stringify(settings);
 
     // 清理不需要发送到后端的字段
@@ -1691,6 +1745,8 @@ const EditChannelModal = (props) => {
     delete localInputs.vertex_key_type;
     // 顶层的 aws_key_type 不应发送给后端
     delete localInputs.aws_key_type;
+    delete localInputs.rerank_format;
+    delete localInputs.task_webhook_secret;
     // 清理字段透传控制的临时字段
     delete localInputs.allow_service_tier;
     delete localInputs.disable_store;
@@ -1699,6 +1755,21 @@ const EditChannelModal = (props) => {
     delete localInputs.allow_inference_geo;
     delete localInputs.claude_beta_query;
 
+    try {
+      localInputs.multi_key_weights = parseMultiKeyMap(
+        localInputs.multi_key_weights,
+      );
+      localInputs.multi_key_rpm_limits = parseMultiKeyMap(
+        localInputs.multi_key_rpm_limits,
+      );
+      localInputs.multi_key_tpm_limits = parseMultiKeyMap(
+        localInputs.multi_key_tpm_limits,
+      );
+    } catch (error) {
+      showInfo(t('密钥权重与额度上限必须是合法的 JSON 对象'));
+      return;
+    }
+
     let res;
     localInputs.auto_ban = localInputs.auto_ban ? 1 : 0;
     localInputs.models = localInputs.models.join(',');
@@ -1719,6 +1790,9 @@ const EditChannelModal = (props) => {
       res = await API.post(`/api/channel/`, {
         mode: mode,
         multi_key_mode: mode === 'multi_to_single' ? multiKeyMode : undefined,
+        multi_key_weights: localInputs.multi_key_weights,
+        multi_key_rpm_limits: localInputs.multi_key_rpm_limits,
+        multi_key_tpm_limits: localInputs.multi_key_tpm_limits,
         channel: localInputs,
       });
     }
@@ -2651,6 +2725,10 @@ const EditChannelModal = (props) => {
                           optionList={[
                             { label: t('随机'), value: 'random' },
                             { label: t('轮询'), value: 'polling' },
+                            { label: t('最久未使用'), value: 'lru' },
+                            { label: t('最少进行中请求'), value: 'least_in_flight' },
+                            { label: t('按权重随机'), value: 'weighted' },
+                            { label: t('按额度分配'), value: 'quota_aware' },
                           ]}
                           style={{ width: '100%' }}
                           value={inputs.multi_key_mode || 'random'}
@@ -2668,6 +2746,55 @@ const EditChannelModal = (props) => {
                             className='!rounded-lg mt-2'
                           />
                         )}
+                        {inputs.multi_key_mode === 'weighted' && (
+                          <Form.TextArea
+                            field='multi_key_weights'
+                            label={t('密钥权重')}
+                            placeholder={'{"0": 3, "1": 1}'}
+                            extraText={t(
+                              '键为密钥序号（从 0 开始），未设置的密钥权重为 1',
+                            )}
+                            autosize
+                            onChange={(value) =>
+                              handleInputChange('multi_key_weights', value)
+                            }
+                          />
+                        )}
+                        {inputs.multi_key_mode === 'quota_aware' && (
+                          <>
+                            <Form.TextArea
+                              field='multi_key_rpm_limits'
+                              label={t('密钥 RPM 上限')}
+                              placeholder={'{"0": 500, "1": 60}'}
+                              extraText={t(
+                                '键为密钥序号（从 0 开始），未设置或为 0 表示不限',
+                              )}
+                              autosize
+                              onChange={(value) =>
+                                handleInputChange('multi_key_rpm_limits', value)
+                              }
+                            />
+                            <Form.TextArea
+                              field='multi_key_tpm_limits'
+                              label={t('密钥 TPM 上限')}
+                              placeholder={'{"0": 200000, "1": 40000}'}
+                              extraText={t(
+                                '键为密钥序号（从 0 开始），未设置或为 0 表示不限',
+                              )}
+                              autosize
+                              onChange={(value) =>
+                                handleInputChange('multi_key_tpm_limits', value)
+                              }
+                            />
+                            <Banner
+                              type='info'
+                              description={t(
+                                '上游返回 429 时会按 Retry-After 暂停使用该密钥，多实例部署时需启用 Redis 以共享计数',
+                              )}
+                              className='!rounded-lg mt-2'
+                            />
+                          </>
+                        )}
                       </>
                     )}
 
@@ -3500,6 +3627,29 @@ const EditChannelModal = (props) => {
                     {/* 字段透传控制 - OpenAI 渠道 */}
                     {inputs.type === 1 && (
                       <>
+                        <Form.Select
+                          field='rerank_format'
+                          label={t('重排序接口格式')}
+                          optionList={[
+                            { label: t('自动'), value: '' },
+                            { label: 'Jina', value: 'jina' },
+                            { label: 'Cohere', value: 'cohere' },
+                            { label: 'Voyage', value: 'voyage' },
+                            { label: 'Xinference', value: 'xinference' },
+                          ]}
+                          style={{ width: '100%' }}
+                          value={inputs.rerank_format || ''}
+                          onChange={(value) =>
+                            handleChannelOtherSettingsChange(
+                              'rerank_format',
+                              value,
+                            )
+                          }
+                          extraText={t(
+                            '/v1/rerank 请求将转换为所选格式发送到上游，响应统一转换为 Jina 格式',
+                          )}
+                        />
+
                         <div className='mt-4 mb-2 text-sm font-medium text-gray-700'>
                           {t('字段透传控制')}
                         </div>
@@ -3572,6 +3722,30 @@ const EditChannelModal = (props) => {
                       </>
                     )}
 
+                    {/* 上游任务 webhook - Sora 渠道 */}
+                    {inputs.type === 55 && (
+                      <Form.Input
+                        field='task_webhook_secret'
+                        label={t('上游 Webhook 签名密钥')}
+                        placeholder='whsec_...'
+                        mode='password'
+                        showClear
+                        value={inputs.task_webhook_secret || ''}
+                        onChange={(value) =>
+                          handleChannelOtherSettingsChange(
+                            'task_webhook_secret',
+                            value,
+                          )
+                        }
+                        extraText={t(
+                          '在上游平台注册 Webhook 地址 {{url}} 并填入签名密钥后，任务完成时将立即更新状态',
+                          {
+                            url: `/api/task/callback/channel/${channelId || ':id'}`,
+                          },
+                        )}
+                      />
+                    )}
+
                     {/* 字段透传控制 - Claude 渠道 */}
                     {inputs.type === 14 && (
                       <>
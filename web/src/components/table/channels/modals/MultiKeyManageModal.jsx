@@ -467,9 +467,14 @@ const MultiKeyManageModal = ({ visible, onCancel, channel, onRefresh }) => {
           </Tag>
           {channel?.channel_info?.multi_key_mode && (
             <Tag size='small' shape='circle' color='white'>
-              {channel.channel_info.multi_key_mode === 'random'
-                ? t('随机模式')
-                : t('轮询模式')}
+              {{
+                random: t('随机模式'),
+                polling: t('轮询模式'),
+                lru: t('最久未使用'),
+                least_in_flight: t('最少进行中请求'),
+                weighted: t('按权重随机'),
+                quota_aware: t('按额度分配'),
+              }[channel.channel_info.multi_key_mode] || t('轮询模式')}
             </Tag>
           )}
         </Space>
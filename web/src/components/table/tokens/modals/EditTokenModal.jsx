@@ -62,6 +62,7 @@ const EditTokenModal = (props) => {
   const formApiRef = useRef(null);
   const [models, setModels] = useState([]);
   const [groups, setGroups] = useState([]);
+  const [orgs, setOrgs] = useState([]);
   const isEdit = props.editingToken.id !== undefined;
 
   const getInitValues = () => ({
@@ -74,6 +75,13 @@ const EditTokenModal = (props) => {
     allow_ips: '',
     group: '',
     cross_group_retry: false,
+    hedge: false,
+    response_cache: 0,
+    rpm_limit: 0,
+    tpm_limit: 0,
+    concurrency_limit: 0,
+    callback_url: '',
+    org_id: 0,
     tokenCount: 1,
   });
 
@@ -149,6 +157,18 @@ const EditTokenModal = (props) => {
     }
   };
 
+  const loadOrgs = async () => {
+    let res = await API.get(`/api/org/self`);
+    const { success, data } = res.data;
+    if (success) {
+      setOrgs(
+        (data || [])
+          .filter((org) => org.role !== 'billing_viewer')
+          .map((org) => ({ label: org.name, value: org.id })),
+      );
+    }
+  };
+
   const loadToken = async () => {
     setLoading(true);
     let res = await API.get(`/api/token/${props.editingToken.id}`);
@@ -179,6 +199,7 @@ const EditTokenModal = (props) => {
     }
     loadModels();
     loadGroups();
+    loadOrgs();
   }, [props.editingToken.id]);
 
   useEffect(() => {
@@ -378,6 +399,23 @@ const EditTokenModal = (props) => {
                       />
                     )}
                   </Col>
+                  {orgs.length > 0 && (
+                    <Col span={24}>
+                      <Form.Select
+                        field='org_id'
+                        label={t('计费归属')}
+                        optionList={[
+                          { label: t('个人钱包'), value: 0 },
+                          ...orgs,
+                        ]}
+                        disabled={isEdit}
+                        extraText={t(
+                          '选择组织后，该令牌的消费从组织钱包扣除，创建后不可修改',
+                        )}
+                        style={{ width: '100%' }}
+                      />
+                    </Col>
+                  )}
                   <Col
                     span={24}
                     style={{
@@ -393,6 +431,60 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  <Col span={24}>
+                    <Form.Select
+                      field='response_cache'
+                      label={t('响应缓存')}
+                      optionList={[
+                        { value: 0, label: t('跟随系统设置') },
+                        { value: 1, label: t('启用') },
+                        { value: 2, label: t('禁用') },
+                      ]}
+                      extraText={t(
+                        '启用后，相同的请求在缓存有效期内直接返回缓存的响应，并按缓存命中倍率计费',
+                      )}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Form.Switch
+                      field='hedge'
+                      label={t('对冲请求')}
+                      size='default'
+                      extraText={t(
+                        '开启后，若渠道在管理员设置的延迟内未开始返回，会同时向另一个渠道发送请求并采用先返回的结果，只计费一次',
+                      )}
+                    />
+                  </Col>
+                  <Col span={8}>
+                    <Form.InputNumber
+                      field='rpm_limit'
+                      label={t('每分钟请求数')}
+                      min={0}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={8}>
+                    <Form.InputNumber
+                      field='tpm_limit'
+                      label={t('每分钟 Token 数')}
+                      min={0}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={8}>
+                    <Form.InputNumber
+                      field='concurrency_limit'
+                      label={t('最大并发数')}
+                      min={0}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Text type='tertiary' size='small'>
+                      {t('超出限制的请求返回 429，0 表示不限制')}
+                    </Text>
+                  </Col>
                   <Col xs={24} sm={24} md={24} lg={10} xl={10}>
                     <Form.DatePicker
                       field='expired_time'
@@ -573,6 +665,18 @@ const EditTokenModal = (props) => {
                       style={{ width: '100%' }}
                     />
                   </Col>
+                  <Col span={24}>
+                    <Form.Input
+                      field='callback_url'
+                      label={t('任务回调地址')}
+                      placeholder={t('例如：https://example.com/webhook')}
+                      extraText={t(
+                        '视频、音乐等异步任务完成时向该地址发送回调，请求中的 callback_url 优先；回调按 Standard Webhooks 规范签名，密钥为个人设置中的 Webhook 密钥，未设置时自动生成，可通过 /api/user/task_webhook_secret 获取',
+                      )}
+                      showClear
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
                 </Row>
               </Card>
             </div>
@@ -87,6 +87,9 @@ const EditUserModal = (props) => {
     quota: 0,
     group: 'default',
     remark: '',
+    rpm_limit: 0,
+    tpm_limit: 0,
+    concurrency_limit: 0,
   });
 
   const fetchGroups = async () => {
@@ -323,6 +326,36 @@ const EditUserModal = (props) => {
                           />
                         </Form.Slot>
                       </Col>
+
+                      <Col span={8}>
+                        <Form.InputNumber
+                          field='rpm_limit'
+                          label={t('每分钟请求数')}
+                          min={0}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
+                      <Col span={8}>
+                        <Form.InputNumber
+                          field='tpm_limit'
+                          label={t('每分钟 Token 数')}
+                          min={0}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
+                      <Col span={8}>
+                        <Form.InputNumber
+                          field='concurrency_limit'
+                          label={t('最大并发数')}
+                          min={0}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
+                      <Col span={24}>
+                        <Text type='tertiary' size='small'>
+                          {t('对该用户的全部令牌生效，0 表示不限制')}
+                        </Text>
+                      </Col>
                     </Row>
                   </Card>
                 )}
@@ -38,6 +38,7 @@ import InvitationCard from './InvitationCard';
 import TransferModal from './modals/TransferModal';
 import PaymentConfirmModal from './modals/PaymentConfirmModal';
 import TopupHistoryModal from './modals/TopupHistoryModal';
+import StatementModal from './modals/StatementModal';
 
 const TopUp = () => {
   const { t } = useTranslation();
@@ -86,6 +87,7 @@ const TopUp = () => {
 
   // 账单Modal状态
   const [openHistory, setOpenHistory] = useState(false);
+  const [openStatement, setOpenStatement] = useState(false);
 
   // 订阅相关
   const [subscriptionPlans, setSubscriptionPlans] = useState([]);
@@ -703,6 +705,13 @@ const TopUp = () => {
         t={t}
       />
 
+      {/* 月度对账单模态框 */}
+      <StatementModal
+        visible={openStatement}
+        onCancel={() => setOpenStatement(false)}
+        t={t}
+      />
+
       {/* Creem 充值确认模态框 */}
       <Modal
         title={t('确定要充值 $')}
@@ -768,6 +777,7 @@ const TopUp = () => {
           statusLoading={statusLoading}
           topupInfo={topupInfo}
           onOpenHistory={handleOpenHistory}
+          onOpenStatement={() => setOpenStatement(true)}
           subscriptionLoading={subscriptionLoading}
           subscriptionPlans={subscriptionPlans}
           billingPreference={billingPreference}
@@ -0,0 +1,234 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import {
+  Button,
+  DatePicker,
+  Descriptions,
+  Modal,
+  Select,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { API, renderQuota, showError, showSuccess } from '../../../helpers';
+
+const { Text } = Typography;
+
+const formatPeriod = (date) => {
+  const month = String(date.getMonth() + 1).padStart(2, '0');
+  return `${date.getFullYear()}-${month}`;
+};
+
+const lastMonth = () => {
+  const now = new Date();
+  return new Date(now.getFullYear(), now.getMonth() - 1, 1);
+};
+
+const StatementModal = ({ visible, onCancel, t }) => {
+  const [month, setMonth] = useState(lastMonth());
+  const [tokenId, setTokenId] = useState(0);
+  const [tokens, setTokens] = useState([]);
+  const [statement, setStatement] = useState(null);
+  const [loading, setLoading] = useState(false);
+  const [emailing, setEmailing] = useState(false);
+
+  const period = formatPeriod(month);
+  const query = tokenId ? `?token_id=${tokenId}` : '';
+
+  const loadTokens = async () => {
+    const res = await API.get('/api/token/?p=1&size=100');
+    const { success, data } = res.data;
+    if (success) {
+      setTokens(data.items || []);
+    }
+  };
+
+  const loadStatement = async () => {
+    setLoading(true);
+    try {
+      const res = await API.get(`/api/user/statement/${period}${query}`);
+      const { success, message, data } = res.data;
+      if (success) {
+        setStatement(data);
+      } else {
+        setStatement(null);
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    if (visible) {
+      loadTokens();
+    }
+  }, [visible]);
+
+  useEffect(() => {
+    if (visible) {
+      loadStatement();
+    }
+  }, [visible, period, tokenId]);
+
+  const download = async (format) => {
+    const separator = query ? '&' : '?';
+    const res = await API.get(
+      `/api/user/statement/${period}${query}${separator}format=${format}`,
+      { responseType: 'blob' },
+    );
+    const url = URL.createObjectURL(res.data);
+    const a = document.createElement('a');
+    a.href = url;
+    a.download = tokenId
+      ? `statement-${period}-token${tokenId}.${format}`
+      : `statement-${period}.${format}`;
+    a.click();
+    URL.revokeObjectURL(url);
+  };
+
+  const sendEmail = async () => {
+    setEmailing(true);
+    try {
+      const res = await API.post(
+        `/api/user/statement/${period}/email${query}`,
+      );
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(message);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setEmailing(false);
+    }
+  };
+
+  const summary = statement?.summary;
+  const summaryData = summary
+    ? [
+        ...(tokenId
+          ? []
+          : [
+              {
+                key: t('充值'),
+                value: `${statement.top_ups.length} / ${summary.top_up_money.toFixed(2)}`,
+              },
+              {
+                key: t('兑换码'),
+                value: `${statement.redemptions.length} / ${renderQuota(summary.redemption_quota)}`,
+              },
+              {
+                key: t('订阅订单'),
+                value: `${statement.subscriptions.length} / ${summary.subscription_money.toFixed(2)}`,
+              },
+            ]),
+        {
+          key: t('模型消费'),
+          value: `${summary.request_count} / ${renderQuota(summary.consume_quota)}`,
+        },
+        {
+          key: t('退款'),
+          value: `${summary.refund_count} / ${renderQuota(summary.refund_quota)}`,
+        },
+        {
+          key: t('违规扣费'),
+          value: `${summary.violation_fee_count} / ${renderQuota(summary.violation_fee_quota)}`,
+        },
+        { key: t('净消费'), value: renderQuota(summary.net_consume_quota) },
+      ]
+    : [];
+
+  const columns = [
+    { title: t('模型'), dataIndex: 'model_name' },
+    { title: t('请求次数'), dataIndex: 'count' },
+    { title: t('输入'), dataIndex: 'prompt_tokens' },
+    { title: t('输出'), dataIndex: 'completion_tokens' },
+    {
+      title: t('消费额度'),
+      dataIndex: 'quota',
+      render: (quota) => renderQuota(quota),
+    },
+  ];
+
+  return (
+    <Modal
+      title={t('月度对账单')}
+      visible={visible}
+      onCancel={onCancel}
+      footer={null}
+      size='large'
+    >
+      <Space wrap className='mb-3'>
+        <DatePicker
+          type='month'
+          value={month}
+          onChange={(date) => date && setMonth(date)}
+          disabledDate={(date) => date > new Date()}
+        />
+        <Select
+          value={tokenId}
+          onChange={setTokenId}
+          style={{ width: 200 }}
+          optionList={[
+            { value: 0, label: t('全部（用户对账单）') },
+            ...tokens.map((token) => ({ value: token.id, label: token.name })),
+          ]}
+        />
+        <Button onClick={() => download('csv')}>{t('下载 CSV')}</Button>
+        <Button onClick={() => download('pdf')}>{t('下载 PDF')}</Button>
+        <Button loading={emailing} onClick={sendEmail}>
+          {t('发送到邮箱')}
+        </Button>
+      </Space>
+      {statement && (
+        <div className='mb-3'>
+          {statement.closed ? (
+            <Tag color='green' shape='circle'>
+              {t('已结账')}
+            </Tag>
+          ) : (
+            <Tag color='orange' shape='circle'>
+              {t('本月尚未结账，数据仍可能变化')}
+            </Tag>
+          )}
+          {statement.checksum && (
+            <Text type='tertiary' size='small' className='ml-2'>
+              {t('校验值')}: {statement.checksum.slice(0, 16)}…
+            </Text>
+          )}
+        </div>
+      )}
+      <Descriptions data={summaryData} row size='small' className='mb-3' />
+      <Table
+        rowKey='model_name'
+        columns={columns}
+        dataSource={statement?.consumption || []}
+        loading={loading}
+        pagination={false}
+        size='small'
+      />
+    </Modal>
+  );
+};
+
+export default StatementModal;
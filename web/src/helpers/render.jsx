@@ -70,6 +70,8 @@ import {
   Layers,
   Gift,
   User,
+  Users,
+  PiggyBank,
   Settings,
   CircleUser,
   Package,
@@ -132,6 +134,10 @@ export function getLucideIcon(key, selected = false) {
       return <CheckSquare {...commonProps} color={iconColor} />;
     case 'topup':
       return <CreditCard {...commonProps} color={iconColor} />;
+    case 'organization':
+      return <Users {...commonProps} color={iconColor} />;
+    case 'budget':
+      return <PiggyBank {...commonProps} color={iconColor} />;
     case 'channel':
       return <Layers {...commonProps} color={iconColor} />;
     case 'redemption':
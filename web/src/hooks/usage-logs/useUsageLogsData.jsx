@@ -534,6 +534,32 @@ export const useLogsData = () => {
             value: other.reasoning_effort,
           });
         }
+        if (other?.pricing_rule) {
+          const rule = other.pricing_rule;
+          const parts = [];
+          if (rule.input_tier) {
+            parts.push(t('输入档位') + ' ' + rule.input_tier);
+          }
+          if (rule.output_tier) {
+            parts.push(t('输出档位') + ' ' + rule.output_tier);
+          }
+          if (rule.off_peak_window) {
+            parts.push(
+              t('错峰时段') +
+                ' ' +
+                rule.off_peak_window +
+                ' ×' +
+                (rule.off_peak_ratio ?? 1),
+            );
+          }
+          if (rule.group) {
+            parts.push(t('分组规则') + ' ' + rule.group);
+          }
+          expandDataLocal.push({
+            key: t('计价规则'),
+            value: parts.join(', '),
+          });
+        }
       }
       if (logs[i].type === 6) {
         if (other?.task_id) {
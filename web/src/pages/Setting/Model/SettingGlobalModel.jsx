@@ -66,10 +66,24 @@ const chatCompletionsToResponsesPolicyAllChannelsExample = JSON.stringify(
   2,
 );
 
+const completionsToChatPolicyExample = JSON.stringify(
+  {
+    enabled: true,
+    all_channels: false,
+    channel_types: [14, 24, 33, 41],
+    model_patterns: [],
+    completion_system_prompt: '',
+    fim_system_prompt: '',
+  },
+  null,
+  2,
+);
+
 const defaultGlobalSettingInputs = {
   'global.pass_through_request_enabled': false,
   'global.thinking_model_blacklist': '[]',
   'global.chat_completions_to_responses_policy': '{}',
+  'global.completions_to_chat_policy': '{}',
   'general_setting.ping_interval_enabled': false,
   'general_setting.ping_interval_seconds': 60,
 };
@@ -83,6 +97,12 @@ export default function SettingGlobalModel(props) {
   const [inputsRow, setInputsRow] = useState(defaultGlobalSettingInputs);
   const chatCompletionsToResponsesPolicyKey =
     'global.chat_completions_to_responses_policy';
+  const completionsToChatPolicyKey = 'global.completions_to_chat_policy';
+  const jsonSettingKeys = [
+    'global.thinking_model_blacklist',
+    chatCompletionsToResponsesPolicyKey,
+    completionsToChatPolicyKey,
+  ];
 
   const setChatCompletionsToResponsesPolicyValue = (value) => {
     setInputs((prev) => ({
@@ -99,7 +119,10 @@ export default function SettingGlobalModel(props) {
       const text = typeof value === 'string' ? value.trim() : '';
       return text === '' ? '[]' : value;
     }
-    if (key === 'global.chat_completions_to_responses_policy') {
+    if (
+      key === 'global.chat_completions_to_responses_policy' ||
+      key === 'global.completions_to_chat_policy'
+    ) {
       const text = typeof value === 'string' ? value.trim() : '';
       return text === '' ? '{}' : value;
     }
@@ -146,17 +169,7 @@ export default function SettingGlobalModel(props) {
     for (const key of Object.keys(defaultGlobalSettingInputs)) {
       if (props.options[key] !== undefined) {
         let value = props.options[key];
-        if (key === 'global.thinking_model_blacklist') {
-          try {
-            value =
-              value && String(value).trim() !== ''
-                ? JSON.stringify(JSON.parse(value), null, 2)
-                : defaultGlobalSettingInputs[key];
-          } catch (error) {
-            value = defaultGlobalSettingInputs[key];
-          }
-        }
-        if (key === 'global.chat_completions_to_responses_policy') {
+        if (jsonSettingKeys.includes(key)) {
           try {
             value =
               value && String(value).trim() !== ''
@@ -355,6 +368,68 @@ export default function SettingGlobalModel(props) {
               </Row>
             </Form.Section>
 
+            <Form.Section
+              text={
+                <span style={{ fontSize: 14, fontWeight: 600 }}>
+                  {t('Completions→Chat 兼容配置')}
+                </span>
+              }
+            >
+              <Row style={{ marginTop: 10 }}>
+                <Col span={24}>
+                  <Form.TextArea
+                    label={t('参数配置')}
+                    field={completionsToChatPolicyKey}
+                    placeholder={
+                      t('例如：') + '\n' + completionsToChatPolicyExample
+                    }
+                    rows={8}
+                    rules={[
+                      {
+                        validator: (rule, value) => {
+                          if (!value || value.trim() === '') return true;
+                          return verifyJSON(value);
+                        },
+                        message: t('不是合法的 JSON 字符串'),
+                      },
+                    ]}
+                    extraText={t(
+                      '命中的渠道会将 /v1/completions 请求（含 suffix 补全）转换为 chat 请求发送，并将响应转换回 text_completion 格式；model_patterns 为空时匹配所有模型',
+                    )}
+                    onChange={(value) =>
+                      setInputs((prev) => ({
+                        ...prev,
+                        [completionsToChatPolicyKey]: value,
+                      }))
+                    }
+                  />
+                </Col>
+              </Row>
+              <Row style={{ marginTop: 10, marginBottom: 16 }}>
+                <Col span={24}>
+                  <Button
+                    type='secondary'
+                    size='small'
+                    onClick={() => {
+                      setInputs((prev) => ({
+                        ...prev,
+                        [completionsToChatPolicyKey]:
+                          completionsToChatPolicyExample,
+                      }));
+                      if (refForm.current) {
+                        refForm.current.setValue(
+                          completionsToChatPolicyKey,
+                          completionsToChatPolicyExample,
+                        );
+                      }
+                    }}
+                  >
+                    {t('填入模板')}
+                  </Button>
+                </Col>
+              </Row>
+            </Form.Section>
+
             <Form.Section
               text={
                 <span style={{ fontSize: 14, fontWeight: 600 }}>
@@ -56,6 +56,8 @@ export default function SettingsSidebarModulesAdmin(props) {
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
+      budget: true,
       personal: true,
     },
     admin: {
@@ -117,6 +119,8 @@ export default function SettingsSidebarModulesAdmin(props) {
       personal: {
         enabled: true,
         topup: true,
+        organization: true,
+        budget: true,
         personal: true,
       },
       admin: {
@@ -187,7 +191,13 @@ export default function SettingsSidebarModulesAdmin(props) {
             midjourney: true,
             task: true,
           },
-          personal: { enabled: true, topup: true, personal: true },
+          personal: {
+            enabled: true,
+            topup: true,
+            organization: true,
+            budget: true,
+            personal: true,
+          },
           admin: {
             enabled: true,
             channel: true,
@@ -241,6 +251,16 @@ export default function SettingsSidebarModulesAdmin(props) {
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('团队共享钱包与成员'),
+        },
+        {
+          key: 'budget',
+          title: t('预算管理'),
+          description: t('周期预算与用量提醒'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -46,9 +46,12 @@ export default function ModelRatioSettings(props) {
     CreateCacheRatio: '',
     CompletionRatio: '',
     ImageRatio: '',
+    ImageSizeRatio: '',
     AudioRatio: '',
     AudioCompletionRatio: '',
     ExposeRatioEnabled: false,
+    'pricing_rule_setting.enabled': false,
+    'pricing_rule_setting.rules': '',
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -271,6 +274,32 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('图片分辨率倍率（仅按次计费的图片模型）')}
+              extraText={t(
+                '键为模型名称，值为 size 或 quality 到倍率的映射，生成图片时按请求的尺寸与品质计费，dall-e 系列已内置无需配置',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，例如：{"gemini-3-pro-image-preview": {"4K": 1.8}}',
+              )}
+              field={'ImageSizeRatio'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, ImageSizeRatio: value })
+              }
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col xs={24} sm={16}>
             <Form.TextArea
@@ -319,6 +348,46 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col span={16}>
+            <Form.Switch
+              label={t('启用计价规则')}
+              field={'pricing_rule_setting.enabled'}
+              extraText={t(
+                '按实际用量在结算时匹配输入/输出 token 分段、错峰时段与分组覆盖，命中的档位会记录在使用日志中',
+              )}
+              onChange={(value) =>
+                setInputs({ ...inputs, 'pricing_rule_setting.enabled': value })
+              }
+            />
+          </Col>
+        </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('计价规则')}
+              extraText={t(
+                '键为模型名称；分段在 token 数超过 min_tokens 时生效，倍率为 0 时保持原倍率；错峰时段的 ratio 乘入模型倍率',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，例如：{"gemini-2.5-pro": {"input_tiers": [{"name": ">200k", "min_tokens": 200000, "model_ratio": 1.25, "completion_ratio": 6}], "off_peak_windows": [{"name": "night", "start": "00:30", "end": "08:30", "timezone": "Asia/Shanghai", "ratio": 0.5}], "group_overrides": {"vip": {}}}}',
+              )}
+              field={'pricing_rule_setting.rules'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, 'pricing_rule_setting.rules': value })
+              }
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col span={16}>
             <Form.Switch